@@ -0,0 +1,499 @@
+package apply
+
+import (
+	"strconv"
+
+	"cloudware/cloudware/api"
+)
+
+func (service *Service) userChange(s *state, u *User) *Change {
+	role := userRole(u.Role)
+
+	stored := s.user(u.Username)
+	if stored == nil {
+		var d diff
+		d.compare("Role", "", roleName(role))
+		d.secret("Password", u.Password != "")
+		return &Change{Action: ActionCreate, Kind: "user", Name: u.Username, Diff: d, run: func() error {
+			user := &api.User{
+				Username: u.Username,
+				Role:     role,
+			}
+			if u.Password != "" {
+				hash, err := service.CryptoService.Hash(u.Password)
+				if err != nil {
+					return api.ErrCryptoHashFailure
+				}
+				user.Password = hash
+			}
+			return service.UserService.CreateUser(user)
+		}}
+	}
+
+	passwordChanged := u.Password != "" && service.CryptoService.CompareHashAndData(stored.Password, u.Password) != nil
+
+	var d diff
+	d.compare("Role", roleName(stored.Role), roleName(role))
+	d.secret("Password", passwordChanged)
+	if len(d) == 0 {
+		return nil
+	}
+
+	user := *stored
+	return &Change{Action: ActionUpdate, Kind: "user", Name: u.Username, Diff: d, run: func() error {
+		user.Role = role
+		if passwordChanged {
+			hash, err := service.CryptoService.Hash(u.Password)
+			if err != nil {
+				return api.ErrCryptoHashFailure
+			}
+			user.Password = hash
+		}
+		return service.UserService.UpdateUser(user.ID, &user)
+	}}
+}
+
+func (service *Service) teamChange(s *state, t *Team) *Change {
+	stored := s.team(t.Name)
+	if stored == nil {
+		var d diff
+		d.compareNames("Leaders", nil, t.Leaders)
+		d.compareNames("Members", nil, t.Members)
+		return &Change{Action: ActionCreate, Kind: "team", Name: t.Name, Diff: d, run: func() error {
+			team := &api.Team{Name: t.Name}
+			err := service.TeamService.CreateTeam(team)
+			if err != nil {
+				return err
+			}
+			return service.updateTeamMemberships(team.ID, t)
+		}}
+	}
+
+	var d diff
+	d.compareNames("Leaders", s.teamMembers(stored.ID, api.TeamLeader), t.Leaders)
+	d.compareNames("Members", s.teamMembers(stored.ID, api.TeamMember), t.Members)
+	if len(d) == 0 {
+		return nil
+	}
+
+	teamID := stored.ID
+	return &Change{Action: ActionUpdate, Kind: "team", Name: t.Name, Diff: d, run: func() error {
+		return service.updateTeamMemberships(teamID, t)
+	}}
+}
+
+// updateTeamMemberships makes the memberships of a team match its definition. The memberships of
+// deleted users are removed.
+func (service *Service) updateTeamMemberships(teamID api.TeamID, t *Team) error {
+	roles := make(map[string]api.MembershipRole)
+	for _, username := range t.Leaders {
+		roles[username] = api.TeamLeader
+	}
+	for _, username := range t.Members {
+		roles[username] = api.TeamMember
+	}
+
+	memberships, err := service.TeamMembershipService.TeamMembershipsByTeamID(teamID)
+	if err != nil {
+		return err
+	}
+
+	for idx := range memberships {
+		membership := &memberships[idx]
+
+		user, err := service.UserService.User(membership.UserID)
+		if err != nil && err != api.ErrUserNotFound {
+			return err
+		}
+
+		role, ok := api.MembershipRole(0), false
+		if user != nil {
+			role, ok = roles[user.Username]
+			delete(roles, user.Username)
+		}
+
+		if !ok {
+			err = service.TeamMembershipService.DeleteTeamMembership(membership.ID)
+		} else if membership.Role != role {
+			membership.Role = role
+			err = service.TeamMembershipService.UpdateTeamMembership(membership.ID, membership)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	// Create the missing memberships in the order of the definition.
+	for _, username := range append(append([]string{}, t.Leaders...), t.Members...) {
+		role, ok := roles[username]
+		if !ok {
+			continue
+		}
+
+		user, err := service.UserService.UserByUsername(username)
+		if err != nil {
+			return err
+		}
+
+		err = service.TeamMembershipService.CreateTeamMembership(&api.TeamMembership{
+			UserID: user.ID,
+			TeamID: teamID,
+			Role:   role,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// endpointConfiguration returns the endpoint fields managed by a definition, the access lists are not included.
+func endpointConfiguration(e *Endpoint) api.Endpoint {
+	endpoint := api.Endpoint{
+		Name:           e.Name,
+		URL:            e.URL,
+		PublicURL:      e.PublicURL,
+		RecordSessions: e.RecordSessions,
+	}
+	if e.TLS {
+		endpoint.TLSConfig = api.TLSConfiguration{
+			TLS:           true,
+			TLSSkipVerify: e.TLSSkipVerify,
+			TLSCACertPath: e.TLSCACert,
+			TLSCertPath:   e.TLSCert,
+			TLSKeyPath:    e.TLSKey,
+		}
+	}
+	return endpoint
+}
+
+func (service *Service) endpointChange(s *state, e *Endpoint) *Change {
+	desired := endpointConfiguration(e)
+
+	var current api.Endpoint
+	var currentUsers, currentTeams []string
+	stored := s.endpoint(e.Name)
+	if stored != nil {
+		current = *stored
+		currentUsers = s.usernames(stored.AuthorizedUsers)
+		currentTeams = s.teamNames(stored.AuthorizedTeams)
+	}
+
+	var d diff
+	d.compare("URL", current.URL, desired.URL)
+	d.compare("PublicURL", current.PublicURL, desired.PublicURL)
+	d.compare("TLS", current.TLSConfig.TLS, desired.TLSConfig.TLS)
+	d.compare("TLSSkipVerify", current.TLSConfig.TLSSkipVerify, desired.TLSConfig.TLSSkipVerify)
+	d.compare("TLSCACert", current.TLSConfig.TLSCACertPath, desired.TLSConfig.TLSCACertPath)
+	d.compare("TLSCert", current.TLSConfig.TLSCertPath, desired.TLSConfig.TLSCertPath)
+	d.compare("TLSKey", current.TLSConfig.TLSKeyPath, desired.TLSConfig.TLSKeyPath)
+	d.compare("RecordSessions", current.RecordSessions, desired.RecordSessions)
+	if e.AuthorizedUsers != nil {
+		d.compareNames("AuthorizedUsers", currentUsers, e.AuthorizedUsers)
+	}
+	if e.AuthorizedTeams != nil {
+		d.compareNames("AuthorizedTeams", currentTeams, e.AuthorizedTeams)
+	}
+
+	if stored == nil {
+		return &Change{Action: ActionCreate, Kind: "endpoint", Name: e.Name, Diff: d, run: func() error {
+			endpoint := desired
+			err := service.resolveAccess(e.AuthorizedUsers, e.AuthorizedTeams, &endpoint.AuthorizedUsers, &endpoint.AuthorizedTeams)
+			if err != nil {
+				return err
+			}
+			if endpoint.AuthorizedUsers == nil {
+				endpoint.AuthorizedUsers = []api.UserID{}
+			}
+			if endpoint.AuthorizedTeams == nil {
+				endpoint.AuthorizedTeams = []api.TeamID{}
+			}
+			return service.EndpointService.CreateEndpoint(&endpoint)
+		}}
+	}
+
+	if len(d) == 0 {
+		return nil
+	}
+
+	endpointID := stored.ID
+	return &Change{Action: ActionUpdate, Kind: "endpoint", Name: e.Name, Diff: d, run: func() error {
+		endpoint, err := service.EndpointService.Endpoint(endpointID)
+		if err != nil {
+			return err
+		}
+
+		endpoint.URL = desired.URL
+		endpoint.PublicURL = desired.PublicURL
+		endpoint.TLSConfig = desired.TLSConfig
+		endpoint.RecordSessions = desired.RecordSessions
+		err = service.resolveAccess(e.AuthorizedUsers, e.AuthorizedTeams, &endpoint.AuthorizedUsers, &endpoint.AuthorizedTeams)
+		if err != nil {
+			return err
+		}
+		return service.EndpointService.UpdateEndpoint(endpoint.ID, endpoint)
+	}}
+}
+
+// resolveAccess replaces the access lists which are specified by name.
+func (service *Service) resolveAccess(usernames, teamNames []string, userIDs *[]api.UserID, teamIDs *[]api.TeamID) error {
+	if usernames != nil {
+		resolved, err := service.userIDs(usernames)
+		if err != nil {
+			return err
+		}
+		*userIDs = resolved
+	}
+
+	if teamNames != nil {
+		resolved, err := service.teamIDs(teamNames)
+		if err != nil {
+			return err
+		}
+		*teamIDs = resolved
+	}
+	return nil
+}
+
+func (service *Service) registryChange(s *state, r *Registry) *Change {
+	username := ""
+	if r.Authentication {
+		username = r.Username
+	}
+
+	var current api.Registry
+	var currentUsers, currentTeams []string
+	stored := s.registry(r.Name)
+	if stored != nil {
+		current = *stored
+		currentUsers = s.usernames(stored.AuthorizedUsers)
+		currentTeams = s.teamNames(stored.AuthorizedTeams)
+	}
+
+	var d diff
+	d.compare("URL", current.URL, r.URL)
+	d.compare("Authentication", current.Authentication, r.Authentication)
+	d.compare("Username", current.Username, username)
+	d.secret("Password", r.Authentication && r.Password != "" && r.Password != current.Password)
+	if r.AuthorizedUsers != nil {
+		d.compareNames("AuthorizedUsers", currentUsers, r.AuthorizedUsers)
+	}
+	if r.AuthorizedTeams != nil {
+		d.compareNames("AuthorizedTeams", currentTeams, r.AuthorizedTeams)
+	}
+
+	if stored == nil {
+		return &Change{Action: ActionCreate, Kind: "registry", Name: r.Name, Diff: d, run: func() error {
+			registry := &api.Registry{
+				Name:            r.Name,
+				URL:             r.URL,
+				Authentication:  r.Authentication,
+				Username:        username,
+				AuthorizedUsers: []api.UserID{},
+				AuthorizedTeams: []api.TeamID{},
+			}
+			if r.Authentication {
+				registry.Password = r.Password
+			}
+			err := service.resolveAccess(r.AuthorizedUsers, r.AuthorizedTeams, &registry.AuthorizedUsers, &registry.AuthorizedTeams)
+			if err != nil {
+				return err
+			}
+			return service.RegistryService.CreateRegistry(registry)
+		}}
+	}
+
+	if len(d) == 0 {
+		return nil
+	}
+
+	registryID := stored.ID
+	return &Change{Action: ActionUpdate, Kind: "registry", Name: r.Name, Diff: d, run: func() error {
+		registry, err := service.RegistryService.Registry(registryID)
+		if err != nil {
+			return err
+		}
+
+		registry.URL = r.URL
+		registry.Authentication = r.Authentication
+		registry.Username = username
+		if !r.Authentication {
+			registry.Password = ""
+		} else if r.Password != "" {
+			registry.Password = r.Password
+		}
+		err = service.resolveAccess(r.AuthorizedUsers, r.AuthorizedTeams, &registry.AuthorizedUsers, &registry.AuthorizedTeams)
+		if err != nil {
+			return err
+		}
+		return service.RegistryService.UpdateRegistry(registry.ID, registry)
+	}}
+}
+
+func (service *Service) settingsChange(s *state, settings *Settings) *Change {
+	var d diff
+	if settings.TemplatesURL != nil {
+		d.compare("TemplatesURL", s.settings.TemplatesURL, *settings.TemplatesURL)
+	}
+	if settings.LogoURL != nil {
+		d.compare("LogoURL", s.settings.LogoURL, *settings.LogoURL)
+	}
+	if settings.BlackListedLabels != nil {
+		d.compareNames("BlackListedLabels", labelNames(s.settings.BlackListedLabels), labelNames(apiLabels(settings.BlackListedLabels)))
+	}
+	if settings.DisplayDonationHeader != nil {
+		d.compare("DisplayDonationHeader", s.settings.DisplayDonationHeader, *settings.DisplayDonationHeader)
+	}
+	if settings.DisplayExternalContributors != nil {
+		d.compare("DisplayExternalContributors", s.settings.DisplayExternalContributors, *settings.DisplayExternalContributors)
+	}
+	if settings.AllowBindMountsForRegularUsers != nil {
+		d.compare("AllowBindMountsForRegularUsers", s.settings.AllowBindMountsForRegularUsers, *settings.AllowBindMountsForRegularUsers)
+	}
+	if settings.AllowPrivilegedModeForRegularUsers != nil {
+		d.compare("AllowPrivilegedModeForRegularUsers", s.settings.AllowPrivilegedModeForRegularUsers, *settings.AllowPrivilegedModeForRegularUsers)
+	}
+	if settings.SessionRecordingRetention != nil {
+		d.compare("SessionRecordingRetention", s.settings.SessionRecordingRetention, *settings.SessionRecordingRetention)
+	}
+	if len(d) == 0 {
+		return nil
+	}
+
+	return &Change{Action: ActionUpdate, Kind: "settings", Name: "settings", Diff: d, run: func() error {
+		stored, err := service.SettingsService.Settings()
+		if err != nil {
+			return err
+		}
+
+		if settings.TemplatesURL != nil {
+			stored.TemplatesURL = *settings.TemplatesURL
+		}
+		if settings.LogoURL != nil {
+			stored.LogoURL = *settings.LogoURL
+		}
+		if settings.BlackListedLabels != nil {
+			stored.BlackListedLabels = apiLabels(settings.BlackListedLabels)
+		}
+		if settings.DisplayDonationHeader != nil {
+			stored.DisplayDonationHeader = *settings.DisplayDonationHeader
+		}
+		if settings.DisplayExternalContributors != nil {
+			stored.DisplayExternalContributors = *settings.DisplayExternalContributors
+		}
+		if settings.AllowBindMountsForRegularUsers != nil {
+			stored.AllowBindMountsForRegularUsers = *settings.AllowBindMountsForRegularUsers
+		}
+		if settings.AllowPrivilegedModeForRegularUsers != nil {
+			stored.AllowPrivilegedModeForRegularUsers = *settings.AllowPrivilegedModeForRegularUsers
+		}
+		if settings.SessionRecordingRetention != nil {
+			stored.SessionRecordingRetention = *settings.SessionRecordingRetention
+		}
+		return service.SettingsService.StoreSettings(stored)
+	}}
+}
+
+func apiLabels(labels []Label) []api.Pair {
+	pairs := make([]api.Pair, 0, len(labels))
+	for _, label := range labels {
+		pairs = append(pairs, api.Pair{Name: label.Name, Value: label.Value})
+	}
+	return pairs
+}
+
+func labelNames(pairs []api.Pair) []string {
+	names := make([]string, 0, len(pairs))
+	for _, pair := range pairs {
+		names = append(names, pair.Name+"="+pair.Value)
+	}
+	return names
+}
+
+// pruneChanges returns the deletions of the stored entities which are not listed in the specified sections
+// of the document. The entities referencing the others are deleted first.
+func (service *Service) pruneChanges(s *state, document *Document) []Change {
+	changes := make([]Change, 0)
+
+	if document.Registries != nil {
+		listed := make(map[string]bool)
+		for _, r := range document.Registries {
+			listed[r.Name] = true
+		}
+		for _, registry := range s.registries {
+			if listed[registry.Name] {
+				continue
+			}
+			registryID := registry.ID
+			changes = append(changes, Change{Action: ActionDelete, Kind: "registry", Name: registry.Name, run: func() error {
+				return service.RegistryService.DeleteRegistry(registryID)
+			}})
+		}
+	}
+
+	if document.Endpoints != nil {
+		listed := make(map[string]bool)
+		for _, e := range document.Endpoints {
+			listed[e.Name] = true
+		}
+		for idx := range s.endpoints {
+			// Only the first stored endpoint of a given name is reconciled, the others are pruned.
+			endpoint := &s.endpoints[idx]
+			if listed[endpoint.Name] && s.endpoint(endpoint.Name) == endpoint {
+				continue
+			}
+			changes = append(changes, Change{Action: ActionDelete, Kind: "endpoint", Name: endpoint.Name, run: func() error {
+				err := service.EndpointService.DeleteEndpoint(endpoint.ID)
+				if err != nil {
+					return err
+				}
+				if endpoint.TLSConfig.TLS && service.FileService != nil {
+					return service.FileService.DeleteTLSFiles(strconv.Itoa(int(endpoint.ID)))
+				}
+				return nil
+			}})
+		}
+	}
+
+	if document.Teams != nil {
+		listed := make(map[string]bool)
+		for _, t := range document.Teams {
+			listed[t.Name] = true
+		}
+		for _, team := range s.teams {
+			if listed[team.Name] {
+				continue
+			}
+			teamID := team.ID
+			changes = append(changes, Change{Action: ActionDelete, Kind: "team", Name: team.Name, run: func() error {
+				err := service.TeamService.DeleteTeam(teamID)
+				if err != nil {
+					return err
+				}
+				return service.TeamMembershipService.DeleteTeamMembershipByTeamID(teamID)
+			}})
+		}
+	}
+
+	if document.Users != nil {
+		listed := make(map[string]bool)
+		for _, u := range document.Users {
+			listed[u.Username] = true
+		}
+		for _, user := range s.users {
+			if listed[user.Username] {
+				continue
+			}
+			userID := user.ID
+			changes = append(changes, Change{Action: ActionDelete, Kind: "user", Name: user.Username, run: func() error {
+				err := service.UserService.DeleteUser(userID)
+				if err != nil {
+					return err
+				}
+				return service.TeamMembershipService.DeleteTeamMembershipByUserID(userID)
+			}})
+		}
+	}
+
+	return changes
+}
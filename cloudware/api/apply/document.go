@@ -0,0 +1,231 @@
+package apply
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"cloudware/cloudware/api"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	roleAdministrator = "administrator"
+	roleUser          = "user"
+)
+
+type (
+	// Document represents the desired state of an instance. Entities are referenced by name.
+	// A section that is not specified is left untouched, the entities of a specified section
+	// that are not listed in the document are only deleted when pruning.
+	Document struct {
+		Users      []User     `json:"Users,omitempty" yaml:"Users,omitempty"`
+		Teams      []Team     `json:"Teams,omitempty" yaml:"Teams,omitempty"`
+		Endpoints  []Endpoint `json:"Endpoints,omitempty" yaml:"Endpoints,omitempty"`
+		Registries []Registry `json:"Registries,omitempty" yaml:"Registries,omitempty"`
+		Settings   *Settings  `json:"Settings,omitempty" yaml:"Settings,omitempty"`
+	}
+
+	// User represents a user account. The role is either administrator or user, the password
+	// is only updated when it is specified and does not match the stored one.
+	User struct {
+		Username string `json:"Username" yaml:"Username"`
+		Role     string `json:"Role,omitempty" yaml:"Role,omitempty"`
+		Password string `json:"Password,omitempty" yaml:"Password,omitempty"`
+	}
+
+	// Team represents a team and its members, identified by username.
+	Team struct {
+		Name    string   `json:"Name" yaml:"Name"`
+		Leaders []string `json:"Leaders,omitempty" yaml:"Leaders,omitempty"`
+		Members []string `json:"Members,omitempty" yaml:"Members,omitempty"`
+	}
+
+	// Endpoint represents an endpoint, using the format of the external endpoint definitions.
+	// The access lists are only managed when they are specified.
+	Endpoint struct {
+		Name            string   `json:"Name" yaml:"Name"`
+		URL             string   `json:"URL" yaml:"URL"`
+		PublicURL       string   `json:"PublicURL,omitempty" yaml:"PublicURL,omitempty"`
+		TLS             bool     `json:"TLS,omitempty" yaml:"TLS,omitempty"`
+		TLSSkipVerify   bool     `json:"TLSSkipVerify,omitempty" yaml:"TLSSkipVerify,omitempty"`
+		TLSCACert       string   `json:"TLSCACert,omitempty" yaml:"TLSCACert,omitempty"`
+		TLSCert         string   `json:"TLSCert,omitempty" yaml:"TLSCert,omitempty"`
+		TLSKey          string   `json:"TLSKey,omitempty" yaml:"TLSKey,omitempty"`
+		RecordSessions  bool     `json:"RecordSessions,omitempty" yaml:"RecordSessions,omitempty"`
+		AuthorizedUsers []string `json:"AuthorizedUsers,omitempty" yaml:"AuthorizedUsers,omitempty"`
+		AuthorizedTeams []string `json:"AuthorizedTeams,omitempty" yaml:"AuthorizedTeams,omitempty"`
+	}
+
+	// Registry represents a registry. The stored password is kept when none is specified and
+	// the access lists are only managed when they are specified.
+	Registry struct {
+		Name            string   `json:"Name" yaml:"Name"`
+		URL             string   `json:"URL" yaml:"URL"`
+		Authentication  bool     `json:"Authentication,omitempty" yaml:"Authentication,omitempty"`
+		Username        string   `json:"Username,omitempty" yaml:"Username,omitempty"`
+		Password        string   `json:"Password,omitempty" yaml:"Password,omitempty"`
+		AuthorizedUsers []string `json:"AuthorizedUsers,omitempty" yaml:"AuthorizedUsers,omitempty"`
+		AuthorizedTeams []string `json:"AuthorizedTeams,omitempty" yaml:"AuthorizedTeams,omitempty"`
+	}
+
+	// Settings represents the application settings, only the specified fields are updated.
+	// The authentication and backup settings contain secrets and are not managed.
+	Settings struct {
+		TemplatesURL                       *string `json:"TemplatesURL,omitempty" yaml:"TemplatesURL,omitempty"`
+		LogoURL                            *string `json:"LogoURL,omitempty" yaml:"LogoURL,omitempty"`
+		BlackListedLabels                  []Label `json:"BlackListedLabels,omitempty" yaml:"BlackListedLabels,omitempty"`
+		DisplayDonationHeader              *bool   `json:"DisplayDonationHeader,omitempty" yaml:"DisplayDonationHeader,omitempty"`
+		DisplayExternalContributors        *bool   `json:"DisplayExternalContributors,omitempty" yaml:"DisplayExternalContributors,omitempty"`
+		AllowBindMountsForRegularUsers     *bool   `json:"AllowBindMountsForRegularUsers,omitempty" yaml:"AllowBindMountsForRegularUsers,omitempty"`
+		AllowPrivilegedModeForRegularUsers *bool   `json:"AllowPrivilegedModeForRegularUsers,omitempty" yaml:"AllowPrivilegedModeForRegularUsers,omitempty"`
+		SessionRecordingRetention          *int    `json:"SessionRecordingRetention,omitempty" yaml:"SessionRecordingRetention,omitempty"`
+	}
+
+	// Label represents a container label hidden from the users.
+	Label struct {
+		Name  string `json:"Name" yaml:"Name"`
+		Value string `json:"Value" yaml:"Value"`
+	}
+
+	// ValidationError lists the problems found in a document.
+	ValidationError struct {
+		Errors []string
+	}
+)
+
+func (err *ValidationError) Error() string {
+	return fmt.Sprintf("%s: %s", api.ErrInvalidConfigurationDocument, strings.Join(err.Errors, "; "))
+}
+
+// Decode decodes a document written in JSON or YAML. Unknown fields are rejected.
+func Decode(data []byte) (*Document, error) {
+	var document Document
+
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		err := decoder.Decode(&document)
+		return &document, err
+	}
+
+	err := yaml.UnmarshalStrict(data, &document)
+	return &document, err
+}
+
+// validate checks the content of a document that does not depend on the stored data.
+func validate(document *Document) []string {
+	var errors []string
+	report := func(section string, idx int, format string, args ...interface{}) {
+		errors = append(errors, fmt.Sprintf("%s[%d]: %s", section, idx, fmt.Sprintf(format, args...)))
+	}
+
+	usernames := make(map[string]int)
+	for idx, u := range document.Users {
+		if u.Username == "" {
+			report("Users", idx, "Username is required")
+		} else if strings.ContainsAny(u.Username, " ") {
+			report("Users", idx, "%s", api.ErrInvalidUsername)
+		} else if previous, ok := usernames[u.Username]; ok {
+			report("Users", idx, "Username is already used by entry %d", previous)
+		} else {
+			usernames[u.Username] = idx
+		}
+
+		if u.Role != "" && u.Role != roleAdministrator && u.Role != roleUser {
+			report("Users", idx, "Role must be %s or %s", roleAdministrator, roleUser)
+		}
+	}
+
+	teamNames := make(map[string]int)
+	for idx, t := range document.Teams {
+		if t.Name == "" {
+			report("Teams", idx, "Name is required")
+		} else if previous, ok := teamNames[t.Name]; ok {
+			report("Teams", idx, "Name is already used by entry %d", previous)
+		} else {
+			teamNames[t.Name] = idx
+		}
+
+		members := make(map[string]bool)
+		for _, username := range append(append([]string{}, t.Leaders...), t.Members...) {
+			if members[username] {
+				report("Teams", idx, "User %s is listed more than once", username)
+			}
+			members[username] = true
+		}
+	}
+
+	endpointNames := make(map[string]int)
+	for idx, e := range document.Endpoints {
+		if e.Name == "" {
+			report("Endpoints", idx, "Name is required")
+		} else if previous, ok := endpointNames[e.Name]; ok {
+			report("Endpoints", idx, "Name is already used by entry %d", previous)
+		} else {
+			endpointNames[e.Name] = idx
+		}
+
+		if e.URL == "" {
+			report("Endpoints", idx, "URL is required")
+		} else if !strings.HasPrefix(e.URL, "unix://") && !strings.HasPrefix(e.URL, "tcp://") {
+			report("Endpoints", idx, "URL must use the unix:// or tcp:// protocol")
+		}
+
+		if e.PublicURL != "" && strings.Contains(e.PublicURL, "://") {
+			report("Endpoints", idx, "PublicURL must not contain a protocol")
+		}
+
+		if !e.TLS && (e.TLSSkipVerify || e.TLSCACert != "" || e.TLSCert != "" || e.TLSKey != "") {
+			report("Endpoints", idx, "TLS options require TLS to be enabled")
+		}
+
+		if (e.TLSCert == "") != (e.TLSKey == "") {
+			report("Endpoints", idx, "TLSCert and TLSKey must be specified together")
+		}
+	}
+
+	registryNames := make(map[string]int)
+	for idx, r := range document.Registries {
+		if r.Name == "" {
+			report("Registries", idx, "Name is required")
+		} else if previous, ok := registryNames[r.Name]; ok {
+			report("Registries", idx, "Name is already used by entry %d", previous)
+		} else {
+			registryNames[r.Name] = idx
+		}
+
+		if r.URL == "" {
+			report("Registries", idx, "URL is required")
+		}
+
+		if r.Authentication && r.Username == "" {
+			report("Registries", idx, "Username is required when Authentication is enabled")
+		} else if !r.Authentication && (r.Username != "" || r.Password != "") {
+			report("Registries", idx, "Username and Password require Authentication to be enabled")
+		}
+	}
+
+	if document.Settings != nil && document.Settings.SessionRecordingRetention != nil && *document.Settings.SessionRecordingRetention < 0 {
+		errors = append(errors, "Settings: SessionRecordingRetention must not be negative")
+	}
+
+	return errors
+}
+
+// userRole converts the role of a user definition.
+func userRole(role string) api.UserRole {
+	if role == roleAdministrator {
+		return api.AdministratorRole
+	}
+	return api.StandardUserRole
+}
+
+// roleName returns the name of a role as written in a document.
+func roleName(role api.UserRole) string {
+	if role == api.AdministratorRole {
+		return roleAdministrator
+	}
+	return roleUser
+}
@@ -0,0 +1,391 @@
+package apply
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"cloudware/cloudware/api"
+)
+
+// Action represents the kind of modification of a change.
+type Action string
+
+const (
+	// ActionCreate creates an entity listed in the document.
+	ActionCreate Action = "create"
+	// ActionUpdate updates a stored entity to match the document.
+	ActionUpdate Action = "update"
+	// ActionDelete deletes a stored entity that is not listed in the document.
+	ActionDelete Action = "delete"
+)
+
+type (
+	// Change represents a modification required to reconcile a stored entity with the document.
+	// Diff describes the modified fields, secrets are reported as changed without their value.
+	Change struct {
+		Action Action   `json:"Action"`
+		Kind   string   `json:"Kind"`
+		Name   string   `json:"Name"`
+		Diff   []string `json:"Diff,omitempty"`
+		run    func() error
+	}
+
+	// Plan represents the ordered list of changes reconciling the instance with a document.
+	Plan struct {
+		Changes []Change `json:"Changes"`
+	}
+
+	// Service reconciles the stored users, teams, endpoints, registries and settings with a document.
+	// The document is validated against the stored data before any change is made, the changes are
+	// then written one by one: applying the same document again after a failure completes the work.
+	Service struct {
+		mu                    sync.Mutex
+		UserService           api.UserService
+		TeamService           api.TeamService
+		TeamMembershipService api.TeamMembershipService
+		EndpointService       api.EndpointService
+		RegistryService       api.RegistryService
+		SettingsService       api.SettingsService
+		CryptoService         api.CryptoService
+		FileService           api.FileService
+	}
+
+	// state represents the stored entities the document is compared to.
+	state struct {
+		users       []api.User
+		teams       []api.Team
+		memberships []api.TeamMembership
+		endpoints   []api.Endpoint
+		registries  []api.Registry
+		settings    *api.Settings
+	}
+)
+
+// Empty returns true when the instance already matches the document.
+func (plan *Plan) Empty() bool {
+	return len(plan.Changes) == 0
+}
+
+// Plan returns the changes required to reconcile the instance with a document, without applying them.
+// When prune is set, the stored entities of the sections specified in the document which are not
+// listed are deleted. A *ValidationError is returned when the document is invalid.
+func (service *Service) Plan(document *Document, prune bool) (*Plan, error) {
+	service.mu.Lock()
+	defer service.mu.Unlock()
+
+	return service.plan(document, prune)
+}
+
+// Apply reconciles the instance with a document and returns the applied changes.
+func (service *Service) Apply(document *Document, prune bool) (*Plan, error) {
+	service.mu.Lock()
+	defer service.mu.Unlock()
+
+	plan, err := service.plan(document, prune)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, change := range plan.Changes {
+		err := change.run()
+		if err != nil {
+			return plan, fmt.Errorf("Unable to %s %s %s: %s", change.Action, change.Kind, change.Name, err)
+		}
+	}
+	return plan, nil
+}
+
+func (service *Service) loadState() (*state, error) {
+	var s state
+	var err error
+
+	s.users, err = service.UserService.Users()
+	if err != nil {
+		return nil, err
+	}
+	s.teams, err = service.TeamService.Teams()
+	if err != nil {
+		return nil, err
+	}
+	s.memberships, err = service.TeamMembershipService.TeamMemberships()
+	if err != nil {
+		return nil, err
+	}
+	s.endpoints, err = service.EndpointService.Endpoints()
+	if err != nil {
+		return nil, err
+	}
+	s.registries, err = service.RegistryService.Registries()
+	if err != nil {
+		return nil, err
+	}
+	s.settings, err = service.SettingsService.Settings()
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+func (service *Service) plan(document *Document, prune bool) (*Plan, error) {
+	errors := validate(document)
+	if len(errors) > 0 {
+		return nil, &ValidationError{Errors: errors}
+	}
+
+	s, err := service.loadState()
+	if err != nil {
+		return nil, err
+	}
+
+	errors = s.checkReferences(document, prune)
+	if len(errors) > 0 {
+		return nil, &ValidationError{Errors: errors}
+	}
+
+	plan := &Plan{Changes: make([]Change, 0)}
+	for idx := range document.Users {
+		plan.add(service.userChange(s, &document.Users[idx]))
+	}
+	for idx := range document.Teams {
+		plan.add(service.teamChange(s, &document.Teams[idx]))
+	}
+	for idx := range document.Endpoints {
+		plan.add(service.endpointChange(s, &document.Endpoints[idx]))
+	}
+	for idx := range document.Registries {
+		plan.add(service.registryChange(s, &document.Registries[idx]))
+	}
+	if document.Settings != nil {
+		plan.add(service.settingsChange(s, document.Settings))
+	}
+
+	if prune {
+		plan.Changes = append(plan.Changes, service.pruneChanges(s, document)...)
+	}
+	return plan, nil
+}
+
+func (plan *Plan) add(change *Change) {
+	if change != nil {
+		plan.Changes = append(plan.Changes, *change)
+	}
+}
+
+// checkReferences ensures that the users and teams referenced by the document exist once it is applied,
+// and that at least one administrator remains.
+func (s *state) checkReferences(document *Document, prune bool) []string {
+	var errors []string
+
+	users := make(map[string]api.UserRole)
+	if !prune || document.Users == nil {
+		for _, user := range s.users {
+			users[user.Username] = user.Role
+		}
+	}
+	for _, user := range document.Users {
+		users[user.Username] = userRole(user.Role)
+	}
+
+	teams := make(map[string]bool)
+	if !prune || document.Teams == nil {
+		for _, team := range s.teams {
+			teams[team.Name] = true
+		}
+	}
+	for _, team := range document.Teams {
+		teams[team.Name] = true
+	}
+
+	checkUsers := func(section string, idx int, usernames []string) {
+		for _, username := range usernames {
+			if _, ok := users[username]; !ok {
+				errors = append(errors, fmt.Sprintf("%s[%d]: User %s not found", section, idx, username))
+			}
+		}
+	}
+	checkTeams := func(section string, idx int, names []string) {
+		for _, name := range names {
+			if !teams[name] {
+				errors = append(errors, fmt.Sprintf("%s[%d]: Team %s not found", section, idx, name))
+			}
+		}
+	}
+
+	for idx, team := range document.Teams {
+		checkUsers("Teams", idx, team.Leaders)
+		checkUsers("Teams", idx, team.Members)
+	}
+	for idx, endpoint := range document.Endpoints {
+		checkUsers("Endpoints", idx, endpoint.AuthorizedUsers)
+		checkTeams("Endpoints", idx, endpoint.AuthorizedTeams)
+	}
+	for idx, registry := range document.Registries {
+		checkUsers("Registries", idx, registry.AuthorizedUsers)
+		checkTeams("Registries", idx, registry.AuthorizedTeams)
+	}
+
+	hasAdministrator := false
+	for _, role := range users {
+		if role == api.AdministratorRole {
+			hasAdministrator = true
+		}
+	}
+	if !hasAdministrator {
+		errors = append(errors, "Users: at least one administrator is required")
+	}
+
+	return errors
+}
+
+func (s *state) user(username string) *api.User {
+	for idx := range s.users {
+		if s.users[idx].Username == username {
+			return &s.users[idx]
+		}
+	}
+	return nil
+}
+
+func (s *state) team(name string) *api.Team {
+	for idx := range s.teams {
+		if s.teams[idx].Name == name {
+			return &s.teams[idx]
+		}
+	}
+	return nil
+}
+
+func (s *state) endpoint(name string) *api.Endpoint {
+	for idx := range s.endpoints {
+		if s.endpoints[idx].Name == name {
+			return &s.endpoints[idx]
+		}
+	}
+	return nil
+}
+
+func (s *state) registry(name string) *api.Registry {
+	for idx := range s.registries {
+		if s.registries[idx].Name == name {
+			return &s.registries[idx]
+		}
+	}
+	return nil
+}
+
+// usernames returns the names of the specified users, unknown users are reported by identifier.
+func (s *state) usernames(userIDs []api.UserID) []string {
+	names := make([]string, 0, len(userIDs))
+	for _, userID := range userIDs {
+		name := "#" + strconv.Itoa(int(userID))
+		for _, user := range s.users {
+			if user.ID == userID {
+				name = user.Username
+				break
+			}
+		}
+		names = append(names, name)
+	}
+	return names
+}
+
+// teamNames returns the names of the specified teams, unknown teams are reported by identifier.
+func (s *state) teamNames(teamIDs []api.TeamID) []string {
+	names := make([]string, 0, len(teamIDs))
+	for _, teamID := range teamIDs {
+		name := "#" + strconv.Itoa(int(teamID))
+		for _, team := range s.teams {
+			if team.ID == teamID {
+				name = team.Name
+				break
+			}
+		}
+		names = append(names, name)
+	}
+	return names
+}
+
+// teamMembers returns the names of the users having the specified role in a team.
+func (s *state) teamMembers(teamID api.TeamID, role api.MembershipRole) []string {
+	var userIDs []api.UserID
+	for _, membership := range s.memberships {
+		if membership.TeamID == teamID && membership.Role == role {
+			userIDs = append(userIDs, membership.UserID)
+		}
+	}
+	return s.usernames(userIDs)
+}
+
+// diff lists the differences between the stored and the desired value of the fields of an entity.
+type diff []string
+
+func (d *diff) compare(field string, from, to interface{}) {
+	if from != to {
+		*d = append(*d, fmt.Sprintf("%s: %s -> %s", field, formatValue(from), formatValue(to)))
+	}
+}
+
+// compareNames compares two lists of names regardless of their order.
+func (d *diff) compareNames(field string, from, to []string) {
+	from = sortedNames(from)
+	to = sortedNames(to)
+	if strings.Join(from, "\n") != strings.Join(to, "\n") {
+		*d = append(*d, fmt.Sprintf("%s: [%s] -> [%s]", field, strings.Join(from, ", "), strings.Join(to, ", ")))
+	}
+}
+
+func (d *diff) secret(field string, changed bool) {
+	if changed {
+		*d = append(*d, field+": (changed)")
+	}
+}
+
+func formatValue(value interface{}) string {
+	if s, ok := value.(string); ok {
+		return strconv.Quote(s)
+	}
+	return fmt.Sprintf("%v", value)
+}
+
+func sortedNames(names []string) []string {
+	sorted := append([]string{}, names...)
+	sort.Strings(sorted)
+	return sorted
+}
+
+// userIDs resolves a list of usernames, a nil list is kept nil.
+func (service *Service) userIDs(usernames []string) ([]api.UserID, error) {
+	if usernames == nil {
+		return nil, nil
+	}
+
+	userIDs := make([]api.UserID, 0, len(usernames))
+	for _, username := range usernames {
+		user, err := service.UserService.UserByUsername(username)
+		if err != nil {
+			return nil, err
+		}
+		userIDs = append(userIDs, user.ID)
+	}
+	return userIDs, nil
+}
+
+// teamIDs resolves a list of team names, a nil list is kept nil.
+func (service *Service) teamIDs(names []string) ([]api.TeamID, error) {
+	if names == nil {
+		return nil, nil
+	}
+
+	teamIDs := make([]api.TeamID, 0, len(names))
+	for _, name := range names {
+		team, err := service.TeamService.TeamByName(name)
+		if err != nil {
+			return nil, err
+		}
+		teamIDs = append(teamIDs, team.ID)
+	}
+	return teamIDs, nil
+}
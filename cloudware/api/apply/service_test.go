@@ -0,0 +1,358 @@
+package apply
+
+import (
+	"strings"
+	"testing"
+
+	"cloudware/cloudware/api"
+	"cloudware/cloudware/api/crypto"
+	"cloudware/cloudware/bolt"
+)
+
+// newApplyService returns a service backed by a store containing the settings and an administrator.
+func newApplyService(t *testing.T) (*Service, *bolt.Store, func()) {
+	store, cleanup := bolt.NewTestStore(t)
+
+	err := store.SettingsService.StoreSettings(&api.Settings{LogoURL: "https://example.com/logo.png"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = store.UserService.CreateUser(&api.User{Username: "admin", Role: api.AdministratorRole})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	service := &Service{
+		UserService:           store.UserService,
+		TeamService:           store.TeamService,
+		TeamMembershipService: store.TeamMembershipService,
+		EndpointService:       store.EndpointService,
+		RegistryService:       store.RegistryService,
+		SettingsService:       store.SettingsService,
+		CryptoService:         &crypto.Service{},
+	}
+
+	return service, store, cleanup
+}
+
+// planSummary returns the action, kind and name of the changes of a plan.
+func planSummary(plan *Plan) []string {
+	summary := make([]string, 0, len(plan.Changes))
+	for _, change := range plan.Changes {
+		summary = append(summary, string(change.Action)+" "+change.Kind+" "+change.Name)
+	}
+	return summary
+}
+
+func mustDecode(t *testing.T, data string) *Document {
+	document, err := Decode([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return document
+}
+
+func TestApply(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		setup    func(t *testing.T, store *bolt.Store)
+		document string
+		prune    bool
+		expected []string
+		check    func(t *testing.T, store *bolt.Store)
+	}{
+		{
+			name: "create",
+			document: `
+Users:
+  - Username: admin
+    Role: administrator
+  - Username: alice
+    Password: secret
+Teams:
+  - Name: dev
+    Leaders: [alice]
+Endpoints:
+  - Name: local
+    URL: unix:///var/run/docker.sock
+    AuthorizedUsers: [alice]
+    AuthorizedTeams: [dev]
+Registries:
+  - Name: hub
+    URL: registry.example.com
+    Authentication: true
+    Username: deploy
+    Password: registry-secret
+Settings:
+  LogoURL: https://example.com/new.png
+`,
+			expected: []string{"create user alice", "create team dev", "create endpoint local", "create registry hub", "update settings settings"},
+			check: func(t *testing.T, store *bolt.Store) {
+				alice, err := store.UserService.UserByUsername("alice")
+				if err != nil {
+					t.Fatal(err)
+				}
+				if alice.Role != api.StandardUserRole || (&crypto.Service{}).CompareHashAndData(alice.Password, "secret") != nil {
+					t.Errorf("unexpected user %+v", alice)
+				}
+
+				team, err := store.TeamService.TeamByName("dev")
+				if err != nil {
+					t.Fatal(err)
+				}
+				memberships, _ := store.TeamMembershipService.TeamMembershipsByTeamID(team.ID)
+				if len(memberships) != 1 || memberships[0].UserID != alice.ID || memberships[0].Role != api.TeamLeader {
+					t.Errorf("expected alice to lead the team, got %+v", memberships)
+				}
+
+				endpoints, _ := store.EndpointService.Endpoints()
+				if len(endpoints) != 1 || len(endpoints[0].AuthorizedUsers) != 1 || endpoints[0].AuthorizedUsers[0] != alice.ID ||
+					len(endpoints[0].AuthorizedTeams) != 1 || endpoints[0].AuthorizedTeams[0] != team.ID {
+					t.Errorf("expected the access lists to be resolved, got %+v", endpoints)
+				}
+
+				registries, _ := store.RegistryService.Registries()
+				if len(registries) != 1 || registries[0].Username != "deploy" || registries[0].Password != "registry-secret" {
+					t.Errorf("unexpected registries %+v", registries)
+				}
+
+				settings, _ := store.SettingsService.Settings()
+				if settings.LogoURL != "https://example.com/new.png" {
+					t.Errorf("expected the settings to be updated, got %q", settings.LogoURL)
+				}
+			},
+		},
+		{
+			name: "update",
+			setup: func(t *testing.T, store *bolt.Store) {
+				alice := &api.User{Username: "alice", Role: api.StandardUserRole}
+				err := store.UserService.CreateUser(alice)
+				if err != nil {
+					t.Fatal(err)
+				}
+				err = store.EndpointService.CreateEndpoint(&api.Endpoint{Name: "remote", URL: "tcp://10.0.0.1:2375", AuthorizedUsers: []api.UserID{alice.ID}})
+				if err != nil {
+					t.Fatal(err)
+				}
+				err = store.RegistryService.CreateRegistry(&api.Registry{Name: "hub", URL: "registry.example.com", Authentication: true, Username: "deploy", Password: "kept"})
+				if err != nil {
+					t.Fatal(err)
+				}
+			},
+			document: `
+Users:
+  - Username: alice
+    Role: administrator
+Endpoints:
+  - Name: remote
+    URL: tcp://10.0.0.2:2375
+Registries:
+  - Name: hub
+    URL: registry.example.com
+    Authentication: true
+    Username: ci
+Settings:
+  LogoURL: https://example.com/logo.png
+`,
+			expected: []string{"update user alice", "update endpoint remote", "update registry hub"},
+			check: func(t *testing.T, store *bolt.Store) {
+				alice, _ := store.UserService.UserByUsername("alice")
+				if alice.Role != api.AdministratorRole {
+					t.Errorf("expected alice to be promoted, got %+v", alice)
+				}
+
+				endpoints, _ := store.EndpointService.Endpoints()
+				if len(endpoints) != 1 || endpoints[0].URL != "tcp://10.0.0.2:2375" || len(endpoints[0].AuthorizedUsers) != 1 {
+					t.Errorf("expected the URL to be updated and the access lists to be kept, got %+v", endpoints)
+				}
+
+				registries, _ := store.RegistryService.Registries()
+				if len(registries) != 1 || registries[0].Username != "ci" || registries[0].Password != "kept" {
+					t.Errorf("expected the password to be kept when none is specified, got %+v", registries)
+				}
+			},
+		},
+		{
+			name: "unlisted entities are kept without prune",
+			setup: func(t *testing.T, store *bolt.Store) {
+				err := store.UserService.CreateUser(&api.User{Username: "bob", Role: api.StandardUserRole})
+				if err != nil {
+					t.Fatal(err)
+				}
+			},
+			document: `
+Users:
+  - Username: admin
+    Role: administrator
+`,
+			expected: []string{},
+		},
+		{
+			name: "prune",
+			setup: func(t *testing.T, store *bolt.Store) {
+				bob := &api.User{Username: "bob", Role: api.StandardUserRole}
+				err := store.UserService.CreateUser(bob)
+				if err != nil {
+					t.Fatal(err)
+				}
+				team := &api.Team{Name: "ops"}
+				err = store.TeamService.CreateTeam(team)
+				if err != nil {
+					t.Fatal(err)
+				}
+				err = store.TeamMembershipService.CreateTeamMembership(&api.TeamMembership{UserID: bob.ID, TeamID: team.ID, Role: api.TeamMember})
+				if err != nil {
+					t.Fatal(err)
+				}
+				for _, name := range []string{"old", "local", "local"} {
+					err = store.EndpointService.CreateEndpoint(&api.Endpoint{Name: name, URL: "unix:///var/run/docker.sock"})
+					if err != nil {
+						t.Fatal(err)
+					}
+				}
+				err = store.RegistryService.CreateRegistry(&api.Registry{Name: "legacy", URL: "legacy.example.com"})
+				if err != nil {
+					t.Fatal(err)
+				}
+			},
+			document: `
+Users:
+  - Username: admin
+    Role: administrator
+Teams: []
+Endpoints:
+  - Name: local
+    URL: unix:///var/run/docker.sock
+Registries: []
+`,
+			prune:    true,
+			expected: []string{"delete registry legacy", "delete endpoint old", "delete endpoint local", "delete team ops", "delete user bob"},
+			check: func(t *testing.T, store *bolt.Store) {
+				users, _ := store.UserService.Users()
+				teams, _ := store.TeamService.Teams()
+				memberships, _ := store.TeamMembershipService.TeamMemberships()
+				endpoints, _ := store.EndpointService.Endpoints()
+				registries, _ := store.RegistryService.Registries()
+				if len(users) != 1 || len(teams) != 0 || len(memberships) != 0 || len(endpoints) != 1 || len(registries) != 0 {
+					t.Errorf("expected the unlisted entities to be deleted, got %d users, %d teams, %d memberships, %d endpoints and %d registries",
+						len(users), len(teams), len(memberships), len(endpoints), len(registries))
+				}
+			},
+		},
+	} {
+		service, store, cleanup := newApplyService(t)
+
+		if test.setup != nil {
+			test.setup(t, store)
+		}
+		document := mustDecode(t, test.document)
+
+		plan, err := service.Plan(document, test.prune)
+		if err != nil {
+			t.Fatalf("%s: %s", test.name, err)
+		}
+		if summary := planSummary(plan); strings.Join(summary, ", ") != strings.Join(test.expected, ", ") {
+			t.Errorf("%s: expected the changes %v, got %v", test.name, test.expected, summary)
+		}
+
+		// The plan is computed again, the dry run did not modify the instance.
+		applied, err := service.Apply(document, test.prune)
+		if err != nil {
+			t.Fatalf("%s: %s", test.name, err)
+		}
+		if strings.Join(planSummary(applied), ", ") != strings.Join(planSummary(plan), ", ") {
+			t.Errorf("%s: expected the dry run to leave the instance untouched, got %v", test.name, planSummary(applied))
+		}
+
+		if test.check != nil {
+			test.check(t, store)
+		}
+
+		plan, err = service.Plan(document, test.prune)
+		if err != nil {
+			t.Fatalf("%s: %s", test.name, err)
+		}
+		if !plan.Empty() {
+			t.Errorf("%s: expected the instance to match the document once applied, got %v", test.name, planSummary(plan))
+		}
+
+		cleanup()
+	}
+}
+
+func TestApplyDiff(t *testing.T) {
+	service, store, cleanup := newApplyService(t)
+	defer cleanup()
+
+	err := store.RegistryService.CreateRegistry(&api.Registry{Name: "hub", URL: "registry.example.com", Authentication: true, Username: "deploy", Password: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	plan, err := service.Plan(mustDecode(t, `
+Registries:
+  - Name: hub
+    URL: registry.example.com
+    Authentication: true
+    Username: deploy
+    Password: rotated
+`), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(plan.Changes) != 1 || strings.Join(plan.Changes[0].Diff, "\n") != "Password: (changed)" {
+		t.Fatalf("expected only the password to be reported as changed, got %+v", plan.Changes)
+	}
+	for _, line := range plan.Changes[0].Diff {
+		if strings.Contains(line, "rotated") || strings.Contains(line, "secret") {
+			t.Errorf("expected the secrets to be left out of the diff, got %q", line)
+		}
+	}
+}
+
+func TestApplyValidation(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		document string
+		prune    bool
+		expected string
+	}{
+		{"duplicate user", "Users:\n  - Username: alice\n  - Username: alice\n", false, "Users[1]: Username is already used by entry 0"},
+		{"invalid role", "Users:\n  - Username: alice\n    Role: owner\n", false, "Users[0]: Role must be administrator or user"},
+		{"invalid endpoint URL", "Endpoints:\n  - Name: local\n    URL: http://10.0.0.1\n", false, "Endpoints[0]: URL must use the unix:// or tcp:// protocol"},
+		{"unknown user", "Teams:\n  - Name: dev\n    Members: [nobody]\n", false, "Teams[0]: User nobody not found"},
+		{"unknown team", "Endpoints:\n  - Name: local\n    URL: unix:///var/run/docker.sock\n    AuthorizedTeams: [ops]\n", false, "Endpoints[0]: Team ops not found"},
+		{"last administrator pruned", "Users:\n  - Username: alice\n", true, "Users: at least one administrator is required"},
+	} {
+		service, store, cleanup := newApplyService(t)
+
+		_, err := service.Apply(mustDecode(t, test.document), test.prune)
+		validationErr, ok := err.(*ValidationError)
+		if !ok {
+			t.Errorf("%s: expected a validation error, got %v", test.name, err)
+		} else if strings.Join(validationErr.Errors, "; ") != test.expected {
+			t.Errorf("%s: expected %q, got %q", test.name, test.expected, validationErr.Errors)
+		}
+
+		users, _ := store.UserService.Users()
+		if len(users) != 1 {
+			t.Errorf("%s: expected the instance to be left untouched, got %d users", test.name, len(users))
+		}
+
+		cleanup()
+	}
+}
+
+func TestDecodeRejectsUnknownFields(t *testing.T) {
+	for _, data := range []string{
+		`{"Users":[{"Username":"alice","Group":"dev"}]}`,
+		"Users:\n  - Username: alice\n    Group: dev\n",
+	} {
+		_, err := Decode([]byte(data))
+		if err == nil {
+			t.Errorf("%q: expected the unknown field to be rejected", data)
+		}
+	}
+}
@@ -0,0 +1,443 @@
+package backup
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"cloudware/cloudware/api"
+	"cloudware/cloudware/api/file"
+	"cloudware/cloudware/bolt"
+)
+
+const (
+	manifestFileName = "manifest.json"
+	databaseFileName = "api.db"
+	stagingDirectory = ".restore"
+)
+
+// folders are the folders of the file store included in a backup, only their content can be restored.
+// The TLS folder contains the LDAP files and the certificate authority, in the LDAPStorePath and PKIStorePath.
+var folders = []string{file.TLSStorePath, file.ComposeStorePath, file.RecordingStorePath, file.TemplatesStorePath}
+
+// Store represents the database of a running instance.
+type Store interface {
+	// BackupDatabase calls fn with a consistent snapshot of the database and its size.
+	BackupDatabase(fn func(size int64, snapshot io.WriterTo) error) error
+	// Replace closes the database, calls fn to replace its file and opens it again.
+	// The transactions are blocked in the meantime.
+	Replace(fn func() error) error
+}
+
+// Scheduler runs the background jobs of a running instance.
+type Scheduler interface {
+	// Suspend stops the jobs and returns a function starting them again.
+	Suspend() func()
+}
+
+// manifest describes the content of a backup archive.
+type manifest struct {
+	Version   string `json:"Version"`
+	CreatedAt int64  `json:"CreatedAt"`
+}
+
+// Service implements the BackupService interface.
+// Backups are gzipped tar archives containing the database and the folders of the file store.
+type Service struct {
+	mu                sync.Mutex
+	dataPath          string
+	store             Store
+	scheduler         Scheduler
+	encryptionService api.EncryptionService
+}
+
+// NewService initializes a new service. The store can be nil when the instance is not running and
+// the scheduler when it runs no background jobs, the jobs are suspended during a restore.
+// The encryption service is used to re-encrypt the secrets of a restored database.
+func NewService(dataPath string, store Store, scheduler Scheduler, encryptionService api.EncryptionService) *Service {
+	return &Service{
+		dataPath:          dataPath,
+		store:             store,
+		scheduler:         scheduler,
+		encryptionService: encryptionService,
+	}
+}
+
+// CreateBackup writes a backup archive to w. The archive is encrypted when a password is specified.
+func (service *Service) CreateBackup(w io.Writer, password string) error {
+	service.mu.Lock()
+	defer service.mu.Unlock()
+
+	var encrypter *encryptingWriter
+	if password != "" {
+		var err error
+		encrypter, err = newEncryptingWriter(w, password)
+		if err != nil {
+			return err
+		}
+		w = encrypter
+	}
+
+	gzipWriter := gzip.NewWriter(w)
+	tarWriter := tar.NewWriter(gzipWriter)
+
+	err := service.writeArchive(tarWriter)
+	if err != nil {
+		return err
+	}
+
+	err = tarWriter.Close()
+	if err != nil {
+		return err
+	}
+
+	err = gzipWriter.Close()
+	if err != nil {
+		return err
+	}
+
+	if encrypter != nil {
+		return encrypter.Close()
+	}
+	return nil
+}
+
+func (service *Service) writeArchive(tarWriter *tar.Writer) error {
+	now := time.Now()
+
+	data, err := json.Marshal(&manifest{Version: api.APIVersion, CreatedAt: now.Unix()})
+	if err != nil {
+		return err
+	}
+
+	err = tarWriter.WriteHeader(&tar.Header{Name: manifestFileName, Mode: 0600, Size: int64(len(data)), ModTime: now})
+	if err != nil {
+		return err
+	}
+	_, err = tarWriter.Write(data)
+	if err != nil {
+		return err
+	}
+
+	err = service.store.BackupDatabase(func(size int64, snapshot io.WriterTo) error {
+		err := tarWriter.WriteHeader(&tar.Header{Name: databaseFileName, Mode: 0600, Size: size, ModTime: now})
+		if err != nil {
+			return err
+		}
+		_, err = snapshot.WriteTo(tarWriter)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, folder := range folders {
+		err = addFolder(tarWriter, service.dataPath, folder)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addFolder adds the content of a folder of the data directory to the archive.
+func addFolder(tarWriter *tar.Writer, dataPath, folder string) error {
+	root := filepath.Join(dataPath, folder)
+	if _, err := os.Stat(root); os.IsNotExist(err) {
+		return nil
+	}
+
+	return filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if !info.IsDir() && !info.Mode().IsRegular() {
+			return nil
+		}
+
+		name, err := filepath.Rel(dataPath, path)
+		if err != nil {
+			return err
+		}
+
+		header, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		header.Name = filepath.ToSlash(name)
+
+		err = tarWriter.WriteHeader(header)
+		if err != nil || info.IsDir() {
+			return err
+		}
+
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		_, err = io.Copy(tarWriter, f)
+		return err
+	})
+}
+
+// RestoreBackup validates a backup archive and replaces the data of the instance with its content.
+// The password is required when the archive is encrypted.
+func (service *Service) RestoreBackup(r io.Reader, password string) error {
+	service.mu.Lock()
+	defer service.mu.Unlock()
+
+	stagingPath := filepath.Join(service.dataPath, stagingDirectory)
+	err := os.RemoveAll(stagingPath)
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(stagingPath)
+
+	err = os.MkdirAll(stagingPath, 0700)
+	if err != nil {
+		return err
+	}
+
+	archive, err := storeArchive(r, filepath.Join(stagingPath, "archive"))
+	if err != nil {
+		return err
+	}
+	defer archive.Close()
+
+	content, err := openArchive(archive, password)
+	if err != nil {
+		return err
+	}
+
+	extractPath := filepath.Join(stagingPath, "data")
+	err = extractArchive(content, extractPath)
+	if err != nil {
+		return err
+	}
+
+	err = service.validateDatabase(extractPath)
+	if err != nil {
+		return err
+	}
+
+	return service.swap(extractPath, filepath.Join(stagingPath, "previous"))
+}
+
+func storeArchive(r io.Reader, path string) (*os.File, error) {
+	archive, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = io.Copy(archive, r)
+	if err != nil {
+		archive.Close()
+		return nil, err
+	}
+	return archive, nil
+}
+
+func openArchive(archive *os.File, password string) (io.Reader, error) {
+	encrypted, err := isEncrypted(archive)
+	if err != nil {
+		return nil, err
+	}
+
+	if !encrypted {
+		_, err = archive.Seek(0, io.SeekStart)
+		return archive, err
+	}
+
+	if password == "" {
+		return nil, api.ErrBackupPasswordRequired
+	}
+	return newDecryptingReader(archive, password)
+}
+
+// extractArchive extracts the archive in the target folder. Only the entries
+// expected in a backup archive are accepted.
+func extractArchive(r io.Reader, targetPath string) error {
+	gzipReader, err := gzip.NewReader(r)
+	if err != nil {
+		return api.ErrInvalidBackupArchive
+	}
+	defer gzipReader.Close()
+
+	hasManifest, hasDatabase := false, false
+	tarReader := tar.NewReader(gzipReader)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return api.ErrInvalidBackupArchive
+		}
+
+		name := filepath.Clean(filepath.FromSlash(header.Name))
+		if !isArchiveEntryAllowed(name) {
+			return api.ErrInvalidBackupArchive
+		}
+
+		path := filepath.Join(targetPath, name)
+		switch header.Typeflag {
+		case tar.TypeDir:
+			err = os.MkdirAll(path, 0700)
+		case tar.TypeReg:
+			err = extractFile(tarReader, path)
+		default:
+			return api.ErrInvalidBackupArchive
+		}
+		if err != nil {
+			return err
+		}
+
+		switch name {
+		case manifestFileName:
+			hasManifest = true
+		case databaseFileName:
+			hasDatabase = true
+		}
+	}
+
+	if !hasManifest || !hasDatabase {
+		return api.ErrInvalidBackupArchive
+	}
+
+	var m manifest
+	data, err := ioutil.ReadFile(filepath.Join(targetPath, manifestFileName))
+	if err != nil {
+		return err
+	}
+	if json.Unmarshal(data, &m) != nil || m.Version == "" {
+		return api.ErrInvalidBackupArchive
+	}
+	return nil
+}
+
+// isArchiveEntryAllowed returns true for the manifest, the database and the content of the backed up folders.
+// The name must be cleaned, which leaves parent directory references at its beginning only.
+func isArchiveEntryAllowed(name string) bool {
+	if name == manifestFileName || name == databaseFileName {
+		return true
+	}
+
+	root := strings.SplitN(filepath.ToSlash(name), "/", 2)[0]
+	for _, folder := range folders {
+		if root == folder {
+			return true
+		}
+	}
+	return false
+}
+
+func extractFile(r io.Reader, path string) error {
+	err := os.MkdirAll(filepath.Dir(path), 0700)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = io.Copy(f, r)
+	return err
+}
+
+// validateDatabase checks that the restored database can be used by this version of the application,
+// migrates its data and encrypts its secrets with the current encryption key.
+func (service *Service) validateDatabase(path string) error {
+	store, err := bolt.NewStore(path)
+	if err != nil {
+		return err
+	}
+	store.EncryptionService = service.encryptionService
+
+	err = store.Open()
+	if err != nil {
+		return api.ErrInvalidBackupArchive
+	}
+	defer store.Close()
+
+	err = store.MigrateData()
+	if err == api.ErrDBVersionTooRecent {
+		return api.ErrBackupDBVersionTooRecent
+	} else if err != nil {
+		return err
+	}
+
+	return store.EncryptSecrets()
+}
+
+// swap replaces the database and the folders of the data directory with the restored ones.
+// The background jobs are suspended and the requests wait for the database to be opened again.
+func (service *Service) swap(restoredPath, previousPath string) error {
+	err := os.MkdirAll(previousPath, 0700)
+	if err != nil {
+		return err
+	}
+
+	if service.scheduler != nil {
+		resume := service.scheduler.Suspend()
+		defer resume()
+	}
+
+	if service.store == nil {
+		return replaceEntries(service.dataPath, restoredPath, previousPath)
+	}
+	return service.store.Replace(func() error {
+		return replaceEntries(service.dataPath, restoredPath, previousPath)
+	})
+}
+
+// replaceEntries moves the current data to the previous folder and puts it back if the replacement fails.
+func replaceEntries(dataPath, restoredPath, previousPath string) error {
+	var err error
+	entries := append([]string{databaseFileName}, folders...)
+	for _, entry := range entries {
+		err = replaceEntry(dataPath, restoredPath, previousPath, entry)
+		if err != nil {
+			break
+		}
+	}
+
+	if err != nil {
+		for _, entry := range entries {
+			previous := filepath.Join(previousPath, entry)
+			if _, statErr := os.Stat(previous); statErr != nil {
+				continue
+			}
+			os.RemoveAll(filepath.Join(dataPath, entry))
+			os.Rename(previous, filepath.Join(dataPath, entry))
+		}
+	}
+	return err
+}
+
+func replaceEntry(dataPath, restoredPath, previousPath, entry string) error {
+	current := filepath.Join(dataPath, entry)
+	if _, err := os.Stat(current); err == nil {
+		err = os.Rename(current, filepath.Join(previousPath, entry))
+		if err != nil {
+			return err
+		}
+	}
+
+	restored := filepath.Join(restoredPath, entry)
+	if _, err := os.Stat(restored); os.IsNotExist(err) {
+		return os.MkdirAll(current, 0700)
+	}
+	return os.Rename(restored, current)
+}
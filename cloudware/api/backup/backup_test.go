@@ -0,0 +1,365 @@
+package backup
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"cloudware/cloudware/api"
+	"cloudware/cloudware/api/crypto"
+	"cloudware/cloudware/api/file"
+	"cloudware/cloudware/api/pki"
+	"cloudware/cloudware/bolt"
+)
+
+// stubScheduler records the suspension of the background jobs.
+type stubScheduler struct {
+	suspended int
+	resumed   int
+}
+
+func (scheduler *stubScheduler) Suspend() func() {
+	scheduler.suspended++
+	return func() {
+		scheduler.resumed++
+	}
+}
+
+// newBackupStore returns an opened store in a temporary data directory containing a user,
+// a TLS file and a compose file.
+func newBackupStore(t *testing.T, encryptionService api.EncryptionService) (*bolt.Store, string, func()) {
+	store, cleanup := bolt.NewTestStore(t)
+	store.EncryptionService = encryptionService
+	dataPath := store.Path
+
+	err := store.MigrateData()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = store.UserService.CreateUser(&api.User{Username: "admin", Role: api.AdministratorRole})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writeDataFile(t, dataPath, filepath.Join(file.TLSStorePath, "1", "cert.pem"), "certificate")
+	writeDataFile(t, dataPath, filepath.Join(file.ComposeStorePath, "web", "docker-compose.yml"), "version: '3'")
+
+	return store, dataPath, cleanup
+}
+
+func writeDataFile(t *testing.T, dataPath, name, content string) {
+	path := filepath.Join(dataPath, name)
+	err := os.MkdirAll(filepath.Dir(path), 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ioutil.WriteFile(path, []byte(content), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readDataFile(dataPath, name string) string {
+	content, err := ioutil.ReadFile(filepath.Join(dataPath, name))
+	if err != nil {
+		return ""
+	}
+	return string(content)
+}
+
+// usernames returns the names of the users of the store.
+func usernames(t *testing.T, store *bolt.Store) map[string]bool {
+	users, err := store.UserService.Users()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	names := make(map[string]bool)
+	for _, user := range users {
+		names[user.Username] = true
+	}
+	return names
+}
+
+func TestBackupRoundTrip(t *testing.T) {
+	store, dataPath, cleanup := newBackupStore(t, nil)
+	defer cleanup()
+
+	scheduler := &stubScheduler{}
+	service := NewService(dataPath, store, scheduler, nil)
+
+	var archive bytes.Buffer
+	err := service.CreateBackup(&archive, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = store.UserService.CreateUser(&api.User{Username: "alice", Role: api.StandardUserRole})
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeDataFile(t, dataPath, filepath.Join(file.TLSStorePath, "1", "cert.pem"), "renewed certificate")
+	writeDataFile(t, dataPath, filepath.Join(file.ComposeStorePath, "api", "docker-compose.yml"), "version: '3'")
+
+	err = service.RestoreBackup(&archive, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	names := usernames(t, store)
+	if len(names) != 1 || !names["admin"] {
+		t.Errorf("expected the users of the backup to be restored, got %v", names)
+	}
+	if content := readDataFile(dataPath, filepath.Join(file.TLSStorePath, "1", "cert.pem")); content != "certificate" {
+		t.Errorf("expected the TLS files of the backup to be restored, got %q", content)
+	}
+	if content := readDataFile(dataPath, filepath.Join(file.ComposeStorePath, "web", "docker-compose.yml")); content != "version: '3'" {
+		t.Errorf("expected the compose files of the backup to be restored, got %q", content)
+	}
+	if _, err := os.Stat(filepath.Join(dataPath, file.ComposeStorePath, "api")); !os.IsNotExist(err) {
+		t.Error("expected the files created after the backup to be removed")
+	}
+	if _, err := os.Stat(filepath.Join(dataPath, stagingDirectory)); !os.IsNotExist(err) {
+		t.Error("expected the staging directory to be removed")
+	}
+
+	if scheduler.suspended != 1 || scheduler.resumed != 1 {
+		t.Errorf("expected the background jobs to be suspended during the restore, got %d suspensions and %d resumptions", scheduler.suspended, scheduler.resumed)
+	}
+
+	// The store can still be written once restored
+	err = store.UserService.CreateUser(&api.User{Username: "bob", Role: api.StandardUserRole})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBackupCertificateAuthority(t *testing.T) {
+	store, dataPath, cleanup := newBackupStore(t, nil)
+	defer cleanup()
+
+	fileService, err := file.NewService(dataPath, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkiService := pki.NewService(fileService, true)
+	certificateAuthority, err := pkiService.CACertificate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeDataFile(t, dataPath, filepath.Join(file.RecordingStorePath, "1.cast"), "recording")
+	writeDataFile(t, dataPath, filepath.Join(file.TemplatesStorePath, "templates.json"), "[]")
+
+	service := NewService(dataPath, store, nil, nil)
+	var archive bytes.Buffer
+	err = service.CreateBackup(&archive, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = os.RemoveAll(filepath.Join(dataPath, file.TLSStorePath, file.PKIStorePath))
+	if err != nil {
+		t.Fatal(err)
+	}
+	renewedAuthority, err := pkiService.CACertificate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if renewedAuthority == certificateAuthority {
+		t.Fatal("expected a new certificate authority to be created")
+	}
+
+	err = service.RestoreBackup(&archive, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	restoredAuthority, err := pkiService.CACertificate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restoredAuthority != certificateAuthority {
+		t.Error("expected the certificate authority of the backup to be restored")
+	}
+	if content := readDataFile(dataPath, filepath.Join(file.RecordingStorePath, "1.cast")); content != "recording" {
+		t.Errorf("expected the recordings of the backup to be restored, got %q", content)
+	}
+	if content := readDataFile(dataPath, filepath.Join(file.TemplatesStorePath, "templates.json")); content != "[]" {
+		t.Errorf("expected the templates of the backup to be restored, got %q", content)
+	}
+}
+
+func TestBackupPassword(t *testing.T) {
+	store, dataPath, cleanup := newBackupStore(t, nil)
+	defer cleanup()
+
+	service := NewService(dataPath, store, nil, nil)
+
+	var archive bytes.Buffer
+	err := service.CreateBackup(&archive, "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(archive.Bytes(), []byte(encryptionMagic)) {
+		t.Fatal("expected the archive to be encrypted")
+	}
+	if bytes.Contains(archive.Bytes(), []byte("certificate")) {
+		t.Error("expected the content of the archive to be encrypted")
+	}
+
+	for _, test := range []struct {
+		password string
+		expected error
+	}{
+		{"", api.ErrBackupPasswordRequired},
+		{"wrong", api.ErrInvalidBackupPassword},
+		{"secret", nil},
+	} {
+		err := service.RestoreBackup(bytes.NewReader(archive.Bytes()), test.password)
+		if err != test.expected {
+			t.Errorf("%q: expected %v, got %v", test.password, test.expected, err)
+		}
+	}
+
+	tampered := append([]byte(nil), archive.Bytes()...)
+	tampered[len(tampered)/2] ^= 0xff
+	err = service.RestoreBackup(bytes.NewReader(tampered), "secret")
+	if err != api.ErrInvalidBackupPassword {
+		t.Errorf("expected a tampered archive to be rejected, got %v", err)
+	}
+}
+
+func TestRestoreEncryptsSecrets(t *testing.T) {
+	store, dataPath, cleanup := newBackupStore(t, nil)
+	defer cleanup()
+
+	err := store.RegistryService.CreateRegistry(&api.Registry{Name: "private", URL: "registry.example.com", Authentication: true, Username: "user", Password: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var archive bytes.Buffer
+	err = NewService(dataPath, store, nil, nil).CreateBackup(&archive, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.Close()
+
+	encryptionService, err := crypto.NewEncryptionService([]byte("key"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store = bolt.OpenTestStore(t, dataPath)
+	store.EncryptionService = encryptionService
+
+	err = NewService(dataPath, store, nil, encryptionService).RestoreBackup(&archive, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	registries, err := store.RegistryService.Registries()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(registries) != 1 || registries[0].Password != "secret" {
+		t.Errorf("expected the restored secrets to be readable with the encryption key, got %+v", registries)
+	}
+	store.Close()
+
+	content, err := ioutil.ReadFile(filepath.Join(dataPath, databaseFileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(content, []byte(`"Password":"secret"`)) {
+		t.Error("expected the restored secrets to be encrypted")
+	}
+}
+
+func TestRestoreRejectsInvalidArchives(t *testing.T) {
+	store, dataPath, cleanup := newBackupStore(t, nil)
+	defer cleanup()
+
+	service := NewService(dataPath, store, nil, nil)
+
+	newArchive := func(entries map[string]string) *bytes.Buffer {
+		var archive bytes.Buffer
+		gzipWriter := gzip.NewWriter(&archive)
+		tarWriter := tar.NewWriter(gzipWriter)
+		for name, content := range entries {
+			tarWriter.WriteHeader(&tar.Header{Name: name, Mode: 0600, Size: int64(len(content)), Typeflag: tar.TypeReg})
+			tarWriter.Write([]byte(content))
+		}
+		tarWriter.Close()
+		gzipWriter.Close()
+		return &archive
+	}
+
+	for _, test := range []struct {
+		name    string
+		archive *bytes.Buffer
+	}{
+		{"not an archive", bytes.NewBufferString("backup")},
+		{"missing database", newArchive(map[string]string{manifestFileName: `{"Version":"1.0"}`})},
+		{"missing manifest", newArchive(map[string]string{databaseFileName: "db"})},
+		{"invalid database", newArchive(map[string]string{manifestFileName: `{"Version":"1.0"}`, databaseFileName: "db"})},
+		{"unexpected entry", newArchive(map[string]string{manifestFileName: `{"Version":"1.0"}`, "../api.db": "db"})},
+	} {
+		err := service.RestoreBackup(test.archive, "")
+		if err != api.ErrInvalidBackupArchive {
+			t.Errorf("%s: expected %q, got %v", test.name, api.ErrInvalidBackupArchive, err)
+		}
+	}
+
+	names := usernames(t, store)
+	if len(names) != 1 || !names["admin"] {
+		t.Errorf("expected the data to be unchanged, got %v", names)
+	}
+	if content := readDataFile(dataPath, filepath.Join(file.TLSStorePath, "1", "cert.pem")); content != "certificate" {
+		t.Errorf("expected the files to be unchanged, got %q", content)
+	}
+}
+
+func TestRestoreBlocksTransactions(t *testing.T) {
+	store, dataPath, cleanup := newBackupStore(t, nil)
+	defer cleanup()
+
+	service := NewService(dataPath, store, nil, nil)
+
+	var archive bytes.Buffer
+	err := service.CreateBackup(&archive, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	errors := make(chan error, 200)
+	for idx := 0; idx < 4; idx++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for count := 0; count < 50; count++ {
+				_, err := store.UserService.Users()
+				if err != nil {
+					errors <- err
+				}
+			}
+		}()
+	}
+
+	err = service.RestoreBackup(&archive, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	wg.Wait()
+	close(errors)
+
+	for err := range errors {
+		t.Errorf("expected the transactions to wait for the restore, got %s", err)
+	}
+}
@@ -0,0 +1,143 @@
+package backup
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"hash"
+	"io"
+	"os"
+
+	"cloudware/cloudware/api"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// Encrypted archives are laid out as follows:
+//
+//	magic | salt | iv | AES-256-CTR ciphertext | HMAC-SHA256(magic | salt | iv | ciphertext)
+//
+// The encryption and authentication keys are derived from the password with PBKDF2.
+const (
+	encryptionMagic      = "CWBKENC1"
+	saltSize             = 16
+	keyDerivationRounds  = 100000
+	encryptionHeaderSize = len(encryptionMagic) + saltSize + aes.BlockSize
+)
+
+// encryptingWriter encrypts the data written to it. The authentication code
+// is written to the underlying writer when it is closed.
+type encryptingWriter struct {
+	w      io.Writer
+	stream cipher.StreamWriter
+	mac    hash.Hash
+}
+
+func newEncryptingWriter(w io.Writer, password string) (*encryptingWriter, error) {
+	header := make([]byte, encryptionHeaderSize)
+	copy(header, encryptionMagic)
+	_, err := io.ReadFull(rand.Reader, header[len(encryptionMagic):])
+	if err != nil {
+		return nil, err
+	}
+
+	salt := header[len(encryptionMagic) : len(encryptionMagic)+saltSize]
+	iv := header[len(encryptionMagic)+saltSize:]
+
+	block, mac, err := deriveKeys(password, salt)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = w.Write(header)
+	if err != nil {
+		return nil, err
+	}
+	mac.Write(header)
+
+	return &encryptingWriter{
+		w:      w,
+		mac:    mac,
+		stream: cipher.StreamWriter{S: cipher.NewCTR(block, iv), W: io.MultiWriter(w, mac)},
+	}, nil
+}
+
+func (writer *encryptingWriter) Write(p []byte) (int, error) {
+	return writer.stream.Write(p)
+}
+
+// Close writes the authentication code of the archive.
+func (writer *encryptingWriter) Close() error {
+	_, err := writer.w.Write(writer.mac.Sum(nil))
+	return err
+}
+
+// isEncrypted returns true when the archive starts with the header of an encrypted archive.
+func isEncrypted(archive *os.File) (bool, error) {
+	magic := make([]byte, len(encryptionMagic))
+	_, err := archive.ReadAt(magic, 0)
+	if err == io.EOF {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	return string(magic) == encryptionMagic, nil
+}
+
+// newDecryptingReader verifies the authentication code of an encrypted archive
+// and returns a reader decrypting its content.
+func newDecryptingReader(archive *os.File, password string) (io.Reader, error) {
+	info, err := archive.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	contentSize := info.Size() - int64(encryptionHeaderSize) - sha256.Size
+	if contentSize < 0 {
+		return nil, api.ErrInvalidBackupArchive
+	}
+
+	header := make([]byte, encryptionHeaderSize)
+	_, err = archive.ReadAt(header, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	salt := header[len(encryptionMagic) : len(encryptionMagic)+saltSize]
+	iv := header[len(encryptionMagic)+saltSize:]
+
+	block, mac, err := deriveKeys(password, salt)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = io.Copy(mac, io.NewSectionReader(archive, 0, int64(encryptionHeaderSize)+contentSize))
+	if err != nil {
+		return nil, err
+	}
+
+	expected := make([]byte, sha256.Size)
+	_, err = archive.ReadAt(expected, int64(encryptionHeaderSize)+contentSize)
+	if err != nil {
+		return nil, err
+	}
+
+	if !hmac.Equal(mac.Sum(nil), expected) {
+		return nil, api.ErrInvalidBackupPassword
+	}
+
+	content := io.NewSectionReader(archive, int64(encryptionHeaderSize), contentSize)
+	return cipher.StreamReader{S: cipher.NewCTR(block, iv), R: content}, nil
+}
+
+func deriveKeys(password string, salt []byte) (cipher.Block, hash.Hash, error) {
+	keys := pbkdf2.Key([]byte(password), salt, keyDerivationRounds, 64, sha256.New)
+
+	block, err := aes.NewCipher(keys[:32])
+	if err != nil {
+		return nil, nil, err
+	}
+	return block, hmac.New(sha256.New, keys[32:]), nil
+}
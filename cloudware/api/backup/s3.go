@@ -0,0 +1,286 @@
+package backup
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"cloudware/cloudware/api"
+)
+
+const (
+	s3DefaultRegion    = "us-east-1"
+	s3RequestTimeout   = 10 * time.Minute
+	s3UnsignedPayload  = "UNSIGNED-PAYLOAD"
+	s3SigningAlgorithm = "AWS4-HMAC-SHA256"
+	s3DateFormat       = "20060102T150405Z"
+)
+
+// s3Storage writes the archives in an S3-compatible bucket. Requests use path-style URLs
+// and are signed with AWS Signature Version 4.
+type s3Storage struct {
+	client   *http.Client
+	settings *api.S3Settings
+	now      func() time.Time
+}
+
+func newS3Storage(settings *api.S3Settings) *s3Storage {
+	return &s3Storage{
+		client:   &http.Client{Timeout: s3RequestTimeout},
+		settings: settings,
+		now:      time.Now,
+	}
+}
+
+type s3ListBucketResult struct {
+	Contents []struct {
+		Key string `xml:"Key"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+type s3Error struct {
+	Code    string `xml:"Code"`
+	Message string `xml:"Message"`
+}
+
+func (storage *s3Storage) Store(name string, r io.Reader, size int64) error {
+	request, err := storage.newRequest(http.MethodPut, storage.objectKey(name), nil, r)
+	if err != nil {
+		return err
+	}
+	request.ContentLength = size
+
+	response, err := storage.do(request, s3UnsignedPayload)
+	if err != nil {
+		return err
+	}
+	response.Body.Close()
+	return nil
+}
+
+func (storage *s3Storage) List() ([]string, error) {
+	prefix := storage.objectKey(archivePrefix)
+	names := make([]string, 0)
+
+	token := ""
+	for {
+		query := url.Values{}
+		query.Set("list-type", "2")
+		query.Set("prefix", prefix)
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+
+		request, err := storage.newRequest(http.MethodGet, "", query, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		response, err := storage.do(request, emptyPayloadHash())
+		if err != nil {
+			return nil, err
+		}
+
+		var result s3ListBucketResult
+		err = xml.NewDecoder(response.Body).Decode(&result)
+		response.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, object := range result.Contents {
+			names = append(names, strings.TrimPrefix(object.Key, storage.objectKey("")))
+		}
+
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			break
+		}
+		token = result.NextContinuationToken
+	}
+
+	sort.Strings(names)
+	return names, nil
+}
+
+func (storage *s3Storage) Delete(name string) error {
+	request, err := storage.newRequest(http.MethodDelete, storage.objectKey(name), nil, nil)
+	if err != nil {
+		return err
+	}
+
+	response, err := storage.do(request, emptyPayloadHash())
+	if err != nil {
+		return err
+	}
+	response.Body.Close()
+	return nil
+}
+
+// objectKey returns the key of an object stored under the prefix defined in the settings.
+func (storage *s3Storage) objectKey(name string) string {
+	prefix := strings.Trim(storage.settings.Prefix, "/")
+	if prefix == "" {
+		return name
+	}
+	return prefix + "/" + name
+}
+
+func (storage *s3Storage) newRequest(method, key string, query url.Values, body io.Reader) (*http.Request, error) {
+	endpoint, err := url.Parse(strings.TrimSuffix(storage.settings.Endpoint, "/"))
+	if err != nil {
+		return nil, err
+	}
+
+	path := endpoint.Path + "/" + storage.settings.Bucket
+	if key != "" {
+		path += "/" + key
+	}
+	endpoint.RawPath = s3EscapePath(path)
+	endpoint.Path = path
+	endpoint.RawQuery = s3CanonicalQuery(query)
+
+	return http.NewRequest(method, endpoint.String(), body)
+}
+
+// do signs and sends a request, an error is returned when the response is not successful.
+func (storage *s3Storage) do(request *http.Request, payloadHash string) (*http.Response, error) {
+	region := storage.settings.Region
+	if region == "" {
+		region = s3DefaultRegion
+	}
+	signS3Request(request, payloadHash, storage.settings.AccessKeyID, storage.settings.SecretAccessKey, region, storage.now())
+
+	response, err := storage.client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+
+	if response.StatusCode >= 300 {
+		defer response.Body.Close()
+		var data s3Error
+		body, _ := ioutil.ReadAll(response.Body)
+		if xml.Unmarshal(body, &data) == nil && data.Message != "" {
+			return nil, api.Error(data.Message)
+		}
+		return nil, api.ErrBackupStorageFailed
+	}
+	return response, nil
+}
+
+// signS3Request adds the AWS Signature Version 4 authorization header to a request.
+// The host and all the headers of the request are signed.
+func signS3Request(request *http.Request, payloadHash, accessKeyID, secretAccessKey, region string, now time.Time) {
+	amzDate := now.UTC().Format(s3DateFormat)
+	date := amzDate[:8]
+
+	request.Header.Set("X-Amz-Date", amzDate)
+	request.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	headers := map[string]string{"host": request.URL.Host}
+	for name, values := range request.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	canonicalHeaders := ""
+	for _, name := range names {
+		canonicalHeaders += name + ":" + headers[name] + "\n"
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		request.Method,
+		request.URL.EscapedPath(),
+		request.URL.RawQuery,
+		canonicalHeaders,
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + region + "/s3/aws4_request"
+	stringToSign := strings.Join([]string{s3SigningAlgorithm, amzDate, scope, sha256Hex([]byte(canonicalRequest))}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+secretAccessKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	request.Header.Set("Authorization", s3SigningAlgorithm+" Credential="+accessKeyID+"/"+scope+
+		", SignedHeaders="+signedHeaders+", Signature="+signature)
+}
+
+// s3EscapePath encodes each segment of a path as expected by the signature.
+func s3EscapePath(path string) string {
+	segments := strings.Split(path, "/")
+	for idx, segment := range segments {
+		segments[idx] = s3Escape(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+// s3CanonicalQuery encodes a query with its parameters sorted by name.
+func s3CanonicalQuery(query url.Values) string {
+	names := make([]string, 0, len(query))
+	for name := range query {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	parameters := make([]string, 0, len(names))
+	for _, name := range names {
+		for _, value := range query[name] {
+			parameters = append(parameters, s3Escape(name)+"="+s3Escape(value))
+		}
+	}
+	return strings.Join(parameters, "&")
+}
+
+// s3Escape encodes all the characters except the unreserved characters defined in RFC 3986.
+func s3Escape(value string) string {
+	const hexDigits = "0123456789ABCDEF"
+
+	var escaped bytes.Buffer
+	for idx := 0; idx < len(value); idx++ {
+		c := value[idx]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || c == '-' || c == '.' || c == '_' || c == '~' {
+			escaped.WriteByte(c)
+			continue
+		}
+		escaped.WriteByte('%')
+		escaped.WriteByte(hexDigits[c>>4])
+		escaped.WriteByte(hexDigits[c&15])
+	}
+	return escaped.String()
+}
+
+func emptyPayloadHash() string {
+	return sha256Hex(nil)
+}
+
+func sha256Hex(data []byte) string {
+	hash := sha256.Sum256(data)
+	return hex.EncodeToString(hash[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,159 @@
+package backup
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"cloudware/cloudware/api"
+)
+
+const (
+	archivePrefix = "cloudware-backup-"
+)
+
+// ArchiveName returns the name of a backup archive created at the specified time.
+// Archive names sort in chronological order.
+func ArchiveName(createdAt time.Time, encrypted bool) string {
+	name := archivePrefix + createdAt.UTC().Format("20060102-150405") + ".tar.gz"
+	if encrypted {
+		name += ".enc"
+	}
+	return name
+}
+
+// storage represents a location where the scheduled backups are written.
+type storage interface {
+	// Store writes an archive of the specified size.
+	Store(name string, r io.Reader, size int64) error
+	// List returns the names of the archives in chronological order.
+	List() ([]string, error)
+	Delete(name string) error
+}
+
+func newStorage(settings *api.BackupSettings) (storage, error) {
+	switch settings.StorageType {
+	case api.BackupStorageLocal:
+		return &localStorage{path: settings.LocalPath}, nil
+	case api.BackupStorageS3:
+		return newS3Storage(&settings.S3), nil
+	}
+	return nil, api.ErrInvalidBackupStorage
+}
+
+// localStorage writes the archives in a local directory.
+type localStorage struct {
+	path string
+}
+
+func (storage *localStorage) Store(name string, r io.Reader, size int64) error {
+	err := os.MkdirAll(storage.path, 0700)
+	if err != nil {
+		return err
+	}
+
+	// The archive is renamed once complete so that a partial archive is never listed.
+	temporaryPath := filepath.Join(storage.path, "."+name)
+	f, err := os.OpenFile(temporaryPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(f, r)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(temporaryPath)
+		return err
+	}
+
+	return os.Rename(temporaryPath, filepath.Join(storage.path, name))
+}
+
+func (storage *localStorage) List() ([]string, error) {
+	files, err := ioutil.ReadDir(storage.path)
+	if os.IsNotExist(err) {
+		return []string{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0)
+	for _, f := range files {
+		if f.Mode().IsRegular() && strings.HasPrefix(f.Name(), archivePrefix) {
+			names = append(names, f.Name())
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func (storage *localStorage) Delete(name string) error {
+	return os.Remove(filepath.Join(storage.path, name))
+}
+
+// StoreBackup creates a backup archive, writes it to the storage defined in the settings
+// and deletes the oldest archives exceeding the retention count. It returns the name of the archive.
+func (service *Service) StoreBackup(settings *api.BackupSettings) (string, error) {
+	target, err := newStorage(settings)
+	if err != nil {
+		return "", err
+	}
+
+	archive, err := ioutil.TempFile("", archivePrefix)
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(archive.Name())
+	defer archive.Close()
+
+	err = service.CreateBackup(archive, settings.Password)
+	if err != nil {
+		return "", err
+	}
+
+	size, err := archive.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return "", err
+	}
+
+	_, err = archive.Seek(0, io.SeekStart)
+	if err != nil {
+		return "", err
+	}
+
+	name := ArchiveName(time.Now(), settings.Password != "")
+	err = target.Store(name, archive, size)
+	if err != nil {
+		return "", err
+	}
+
+	return name, applyRetention(target, settings.RetentionCount)
+}
+
+// applyRetention deletes the oldest archives so that at most count archives are kept.
+// All the archives are kept when count is 0.
+func applyRetention(target storage, count int) error {
+	if count <= 0 {
+		return nil
+	}
+
+	names, err := target.List()
+	if err != nil {
+		return err
+	}
+
+	for len(names) > count {
+		err = target.Delete(names[0])
+		if err != nil {
+			return err
+		}
+		names = names[1:]
+	}
+	return nil
+}
@@ -1,35 +1,70 @@
 package cli
 
 import (
-	"log"
 	"time"
 
 	"cloudware/cloudware/api"
 
+	"net/url"
 	"os"
 	"strings"
+	"github.com/sirupsen/logrus"
 	"github.com/spf13/pflag"
 )
 const (
 	defaultBindAddress     = ":9000"
 	defaultDataDirectory   = "/data"
 	defaultAssetsDirectory = "./"
-	defaultNoAuth          = "false"
-	defaultNoAnalytics     = "false"
-	defaultTLSVerify       = "false"
+	defaultNoAuth          = false
+	defaultNoAnalytics     = false
+	defaultNoPKI           = false
+	defaultTLSVerify       = false
 	defaultTLSCACertPath   = "/certs/ca.pem"
 	defaultTLSCertPath     = "/certs/cert.pem"
 	defaultTLSKeyPath      = "/certs/key.pem"
-	defaultSSL             = "false"
+	defaultSSL             = false
 	defaultSSLCertPath     = "/certs/portainer.crt"
 	defaultSSLKeyPath      = "/certs/portainer.key"
 	defaultSyncInterval    = "60s"
+	defaultReadTimeout     = "5m"
+	defaultWriteTimeout    = "0s"
+	defaultIdleTimeout     = "2m"
+	defaultShutdownTimeout = "30s"
 )
 
 func InstallHttpServerFlags(flags *pflag.FlagSet) (*api.HttpCliFlags, error) {
-	hCliFlags := &api.HttpCliFlags{}
+	hCliFlags := &api.HttpCliFlags{
+		Labels: new([]api.Pair),
+	}
 
 	flags.StringVar(&hCliFlags.Addr, "bind", defaultBindAddress, "Http address and port to server cloudware.")
+	flags.StringVar(&hCliFlags.MetricsAddr, "metrics-addr", "", "Address and port of a separate listener serving the metrics on /metrics, they are served by the API to the administrators when not set.")
+	flags.StringVar(&hCliFlags.Assets, "assets", defaultAssetsDirectory, "Path to the assets, including the docker binary used to deploy the stacks.")
+	flags.StringVar(&hCliFlags.Data, "data", defaultDataDirectory, "Path to the folder where the data is stored.")
+	flags.StringVarP(&hCliFlags.Endpoint, "host", "H", "", "Dockerd endpoint created at the first start, unix:// or tcp://.")
+	flags.StringVar(&hCliFlags.ExternalEndpoints, "external-endpoints", "", "Path or HTTP(S) URL of a file defining the available endpoints.")
+	flags.StringVar(&hCliFlags.SyncInterval, "sync-interval", defaultSyncInterval, "Duration between each synchronization via the external endpoints source.")
+	flags.BoolVar(&hCliFlags.NoAuth, "no-auth", defaultNoAuth, "Disable authentication.")
+	flags.BoolVar(&hCliFlags.NoAnalytics, "no-analytics", defaultNoAnalytics, "Disable analytics.")
+	flags.BoolVar(&hCliFlags.NoPKI, "no-pki", defaultNoPKI, "Disable the built-in certificate authority issuing the endpoint certificates.")
+	flags.BoolVar(&hCliFlags.TLSVerify, "tlsverify", defaultTLSVerify, "TLS support for the endpoint defined with -H.")
+	flags.StringVar(&hCliFlags.TLSCacert, "tlscacert", defaultTLSCACertPath, "Path to the CA of the endpoint defined with -H.")
+	flags.StringVar(&hCliFlags.TLSCert, "tlscert", defaultTLSCertPath, "Path to the TLS certificate used to connect to the endpoint defined with -H.")
+	flags.StringVar(&hCliFlags.TLSKey, "tlskey", defaultTLSKeyPath, "Path to the TLS key used to connect to the endpoint defined with -H.")
+	flags.BoolVar(&hCliFlags.SSL, "ssl", defaultSSL, "Serve the API over HTTPS.")
+	flags.StringVar(&hCliFlags.SSLCert, "sslcert", defaultSSLCertPath, "Path to the SSL certificate used to serve the API over HTTPS.")
+	flags.StringVar(&hCliFlags.SSLKey, "sslkey", defaultSSLKeyPath, "Path to the SSL key used to serve the API over HTTPS.")
+	flags.StringVar(&hCliFlags.AdminPassword, "admin-password", "", "Hashed password of the admin user created at the first start.")
+	flags.StringVar(&hCliFlags.AdminPasswordFile, "admin-password-file", "", "Path to the file containing the password of the admin user created at the first start.")
+	flags.StringVar(&hCliFlags.EncryptionKeyFile, "encryption-key-file", "", "Path to the file containing the master key used to encrypt the stored secrets, defaults to the CLOUDWARE_ENCRYPTION_KEY environment variable.")
+	flags.StringVar(&hCliFlags.PreviousEncryptionKeyFile, "previous-encryption-key-file", "", "Path to the file containing the master key being rotated, defaults to the CLOUDWARE_PREVIOUS_ENCRYPTION_KEY environment variable.")
+	flags.StringVar(&hCliFlags.ReadTimeout, "read-timeout", defaultReadTimeout, "Maximum duration to read a request, including its body.")
+	flags.StringVar(&hCliFlags.WriteTimeout, "write-timeout", defaultWriteTimeout, "Maximum duration to write a response, 0s to let the streamed responses run indefinitely.")
+	flags.StringVar(&hCliFlags.IdleTimeout, "idle-timeout", defaultIdleTimeout, "Maximum duration to keep an idle connection open.")
+	flags.StringVar(&hCliFlags.ShutdownTimeout, "shutdown-timeout", defaultShutdownTimeout, "Maximum duration given to the requests and sessions in progress to end when the server shuts down.")
+	flags.StringVar(&hCliFlags.Logo, "logo", "", "URL of a logo displayed instead of the default one (deprecated).")
+	flags.StringVar(&hCliFlags.Templates, "templates", "", "URL of the templates definitions (deprecated).")
+	flags.Var((*pairList)(hCliFlags.Labels), "hide-label", "Hide the containers with a specific label, as NAME=VALUE (deprecated).")
 
 	return hCliFlags, nil
 }
@@ -41,10 +76,13 @@ const (
 	errInvalidEndpointProtocol       = api.Error("Invalid endpoint protocol: Cloudware only supports unix:// or tcp://")
 	errSocketNotFound                = api.Error("Unable to locate Unix socket")
 	errEndpointsFileNotFound         = api.Error("Unable to locate external endpoints file")
+	errInvalidEndpointsURL           = api.Error("Invalid external endpoints URL")
 	errInvalidSyncInterval           = api.Error("Invalid synchronization interval")
 	errEndpointExcludeExternal       = api.Error("Cannot use the -H flag mutually with --external-endpoints")
 	errNoAuthExcludeAdminPassword    = api.Error("Cannot use --no-auth with --admin-password or --admin-password-file")
 	errAdminPassExcludeAdminPassFile = api.Error("Cannot use --admin-password with --admin-password-file")
+	errInvalidTimeout                = api.Error("Invalid timeout")
+	errSSLCertificateNotFound        = api.Error("Unable to locate the SSL certificate or key")
 )
 
 // ParseFlags parse the CLI flags and return a api.Flags struct
@@ -82,7 +120,19 @@ func (*Service) ValidateFlags(flags *api.HttpCliFlags) error {
 		return errAdminPassExcludeAdminPassFile
 	}
 
-	displayDeprecationWarnings(flags.Templates, flags.Logo, *flags.Labels)
+	err = validateTimeouts(flags.ReadTimeout, flags.WriteTimeout, flags.IdleTimeout, flags.ShutdownTimeout)
+	if err != nil {
+		return err
+	}
+
+	if flags.SSL {
+		err = validateSSLCertificate(flags.SSLCert, flags.SSLKey)
+		if err != nil {
+			return err
+		}
+	}
+
+	displayDeprecationWarnings(flags.Templates, flags.Logo, flags.Labels)
 
 	return nil
 }
@@ -107,6 +157,13 @@ func validateEndpoint(endpoint string) error {
 }
 
 func validateExternalEndpoints(externalEndpoints string) error {
+	if strings.HasPrefix(externalEndpoints, "http://") || strings.HasPrefix(externalEndpoints, "https://") {
+		if _, err := url.ParseRequestURI(externalEndpoints); err != nil {
+			return errInvalidEndpointsURL
+		}
+		return nil
+	}
+
 	if externalEndpoints != "" {
 		if _, err := os.Stat(externalEndpoints); err != nil {
 			if os.IsNotExist(err) {
@@ -128,14 +185,38 @@ func validateSyncInterval(syncInterval string) error {
 	return nil
 }
 
-func displayDeprecationWarnings(templates, logo string, labels []api.Pair) {
+func validateTimeouts(timeouts ...string) error {
+	for _, timeout := range timeouts {
+		if timeout == "" {
+			continue
+		}
+		if _, err := time.ParseDuration(timeout); err != nil {
+			return errInvalidTimeout
+		}
+	}
+	return nil
+}
+
+func validateSSLCertificate(certPath, keyPath string) error {
+	for _, path := range []string{certPath, keyPath} {
+		if _, err := os.Stat(path); err != nil {
+			if os.IsNotExist(err) {
+				return errSSLCertificateNotFound
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+func displayDeprecationWarnings(templates, logo string, labels *[]api.Pair) {
 	if templates != "" {
-		log.Println("Warning: the --templates / -t flag is deprecated and will be removed in future versions.")
+		logrus.Warn("The --templates flag is deprecated and will be removed in future versions.")
 	}
 	if logo != "" {
-		log.Println("Warning: the --logo flag is deprecated and will be removed in future versions.")
+		logrus.Warn("The --logo flag is deprecated and will be removed in future versions.")
 	}
-	if labels != nil {
-		log.Println("Warning: the --hide-label / -l flag is deprecated and will be removed in future versions.")
+	if labels != nil && len(*labels) > 0 {
+		logrus.Warn("The --hide-label flag is deprecated and will be removed in future versions.")
 	}
 }
@@ -0,0 +1,62 @@
+package cli
+
+import (
+	"os"
+	"testing"
+
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"cloudware/cloudware/api"
+)
+
+func TestApplyEnvironment(t *testing.T) {
+	flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	cliFlags, err := InstallHttpServerFlags(flags)
+	require.NoError(t, err)
+	BindEnvironment(flags)
+
+	os.Setenv("CLOUDWARE_DATA", "/srv/cloudware")
+	os.Setenv("CLOUDWARE_NO_AUTH", "true")
+	os.Setenv("CLOUDWARE_BIND", ":8000")
+	defer os.Unsetenv("CLOUDWARE_DATA")
+	defer os.Unsetenv("CLOUDWARE_NO_AUTH")
+	defer os.Unsetenv("CLOUDWARE_BIND")
+
+	require.NoError(t, flags.Parse([]string{"--bind", ":7000"}))
+	require.NoError(t, ApplyEnvironment(flags))
+
+	assert.Equal(t, "/srv/cloudware", cliFlags.Data)
+	assert.True(t, cliFlags.NoAuth)
+	assert.Equal(t, ":7000", cliFlags.Addr)
+	assert.True(t, flags.Changed("data"))
+	assert.False(t, flags.Changed("assets"))
+
+	os.Setenv("CLOUDWARE_SSL", "maybe")
+	defer os.Unsetenv("CLOUDWARE_SSL")
+	err = ApplyEnvironment(flags)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "CLOUDWARE_SSL")
+}
+
+func TestValidateFlags(t *testing.T) {
+	service := &Service{}
+
+	tests := []struct {
+		flags *api.HttpCliFlags
+		err   error
+	}{
+		{&api.HttpCliFlags{SyncInterval: defaultSyncInterval}, nil},
+		{&api.HttpCliFlags{SyncInterval: defaultSyncInterval, Endpoint: "npipe://docker"}, errInvalidEndpointProtocol},
+		{&api.HttpCliFlags{SyncInterval: defaultSyncInterval, Endpoint: "tcp://docker:2375", ExternalEndpoints: "/endpoints.json"}, errEndpointExcludeExternal},
+		{&api.HttpCliFlags{SyncInterval: "often"}, errInvalidSyncInterval},
+		{&api.HttpCliFlags{SyncInterval: defaultSyncInterval, NoAuth: true, AdminPassword: "hash"}, errNoAuthExcludeAdminPassword},
+		{&api.HttpCliFlags{SyncInterval: defaultSyncInterval, ReadTimeout: "long"}, errInvalidTimeout},
+		{&api.HttpCliFlags{SyncInterval: defaultSyncInterval, SSL: true, SSLCert: "/nonexistent.crt"}, errSSLCertificateNotFound},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.err, service.ValidateFlags(test.flags), "%+v", test.flags)
+	}
+}
@@ -0,0 +1,59 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/pflag"
+)
+
+const (
+	envPrefix     = "CLOUDWARE_"
+	envAnnotation = "env"
+)
+
+// environmentVariable returns the name of the environment variable setting a flag,
+// such as CLOUDWARE_NO_AUTH for --no-auth.
+func environmentVariable(name string) string {
+	return envPrefix + strings.ToUpper(strings.Replace(name, "-", "_", -1))
+}
+
+// BindEnvironment allows the flags to be set by environment variables and documents them
+// in the usage. The flags listed in exclude are only available on the command line.
+func BindEnvironment(flags *pflag.FlagSet, exclude ...string) {
+	excluded := make(map[string]bool)
+	for _, name := range exclude {
+		excluded[name] = true
+	}
+
+	flags.VisitAll(func(f *pflag.Flag) {
+		if excluded[f.Name] {
+			return
+		}
+		variable := environmentVariable(f.Name)
+		flags.SetAnnotation(f.Name, envAnnotation, []string{variable})
+		f.Usage += fmt.Sprintf(" [$%s]", variable)
+	})
+}
+
+// ApplyEnvironment sets the flags which are not specified on the command line from
+// their environment variable. These flags are then considered as set by the user.
+func ApplyEnvironment(flags *pflag.FlagSet) error {
+	var err error
+	flags.VisitAll(func(f *pflag.Flag) {
+		variables := f.Annotations[envAnnotation]
+		if err != nil || f.Changed || len(variables) == 0 {
+			return
+		}
+
+		value, ok := os.LookupEnv(variables[0])
+		if !ok {
+			return
+		}
+		if setErr := flags.Set(f.Name, value); setErr != nil {
+			err = fmt.Errorf("invalid value %q for %s: %v", value, variables[0], setErr)
+		}
+	})
+	return err
+}
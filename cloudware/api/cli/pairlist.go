@@ -4,7 +4,6 @@ import (
 	"cloudware/cloudware/api"
 
 	"fmt"
-	"gopkg.in/alecthomas/kingpin.v2"
 	"strings"
 )
 
@@ -25,16 +24,14 @@ func (l *pairList) Set(value string) error {
 
 // String implementation for a list of pair
 func (l *pairList) String() string {
-	return ""
-}
-
-// IsCumulative implementation for a list of pair
-func (l *pairList) IsCumulative() bool {
-	return true
+	pairs := make([]string, len(*l))
+	for idx, pair := range *l {
+		pairs[idx] = pair.Name + "=" + pair.Value
+	}
+	return strings.Join(pairs, ",")
 }
 
-func pairs(s kingpin.Settings) (target *[]api.Pair) {
-	target = new([]api.Pair)
-	s.SetValue((*pairList)(target))
-	return
+// Type implementation for a list of pair
+func (l *pairList) Type() string {
+	return "list"
 }
@@ -1,6 +1,9 @@
 package api
 
-import "io"
+import (
+	"io"
+	"time"
+)
 
 type (
 	// Pair defines a key/value string pair
@@ -11,27 +14,35 @@ type (
 
 	// HttpCliFlags represents the available flags on the CLI.
 	HttpCliFlags struct {
-		Addr              string
-		Assets            string
-		Data              string
-		ExternalEndpoints string
-		SyncInterval      string
-		Endpoint          string
-		NoAuth            bool
-		NoAnalytics       bool
-		TLSVerify         bool
-		TLSCacert         string
-		TLSCert           string
-		TLSKey            string
-		SSL               bool
-		SSLCert           string
-		SSLKey            string
-		AdminPassword     string
-		AdminPasswordFile string
+		Addr                      string `json:"bind,omitempty"`
+		MetricsAddr               string `json:"metrics-addr,omitempty"`
+		Assets                    string `json:"assets,omitempty"`
+		Data                      string `json:"data,omitempty"`
+		ExternalEndpoints         string `json:"external-endpoints,omitempty"`
+		SyncInterval              string `json:"sync-interval,omitempty"`
+		Endpoint                  string `json:"host,omitempty"`
+		NoAuth                    bool   `json:"no-auth,omitempty"`
+		NoAnalytics               bool   `json:"no-analytics,omitempty"`
+		NoPKI                     bool   `json:"no-pki,omitempty"`
+		TLSVerify                 bool   `json:"tlsverify,omitempty"`
+		TLSCacert                 string `json:"tlscacert,omitempty"`
+		TLSCert                   string `json:"tlscert,omitempty"`
+		TLSKey                    string `json:"tlskey,omitempty"`
+		SSL                       bool   `json:"ssl,omitempty"`
+		SSLCert                   string `json:"sslcert,omitempty"`
+		SSLKey                    string `json:"sslkey,omitempty"`
+		AdminPassword             string `json:"admin-password,omitempty"`
+		AdminPasswordFile         string `json:"admin-password-file,omitempty"`
+		EncryptionKeyFile         string `json:"encryption-key-file,omitempty"`
+		PreviousEncryptionKeyFile string `json:"previous-encryption-key-file,omitempty"`
+		ReadTimeout               string `json:"read-timeout,omitempty"`
+		WriteTimeout              string `json:"write-timeout,omitempty"`
+		IdleTimeout               string `json:"idle-timeout,omitempty"`
+		ShutdownTimeout           string `json:"shutdown-timeout,omitempty"`
 		// Deprecated fields
-		Logo      string
-		Templates string
-		Labels    *[]Pair
+		Logo      string  `json:"logo,omitempty"`
+		Templates string  `json:"templates,omitempty"`
+		Labels    *[]Pair `json:"hide-label,omitempty"`
 	}
 
 	// Status represents the application status.
@@ -79,6 +90,42 @@ type (
 		LDAPSettings                       LDAPSettings         `json:"LDAPSettings"`
 		AllowBindMountsForRegularUsers     bool                 `json:"AllowBindMountsForRegularUsers"`
 		AllowPrivilegedModeForRegularUsers bool                 `json:"AllowPrivilegedModeForRegularUsers"`
+		SessionRecordingRetention          int                  `json:"SessionRecordingRetention"`
+		BackupSettings                     BackupSettings       `json:"BackupSettings"`
+	}
+
+	// BackupSettings represents the settings of the scheduled backups.
+	// Backups are disabled when no schedule is defined.
+	BackupSettings struct {
+		Schedule       string            `json:"Schedule"`
+		RetentionCount int               `json:"RetentionCount"`
+		Password       string            `json:"Password"`
+		StorageType    BackupStorageType `json:"StorageType"`
+		LocalPath      string            `json:"LocalPath"`
+		S3             S3Settings        `json:"S3"`
+	}
+
+	// BackupStorageType represents the type of storage where the scheduled backups are written.
+	BackupStorageType int
+
+	// S3Settings represents the settings used to connect to an S3-compatible bucket.
+	S3Settings struct {
+		Endpoint        string `json:"Endpoint"`
+		Region          string `json:"Region"`
+		Bucket          string `json:"Bucket"`
+		Prefix          string `json:"Prefix"`
+		AccessKeyID     string `json:"AccessKeyID"`
+		SecretAccessKey string `json:"SecretAccessKey"`
+	}
+
+	// BackupStatus represents the outcome of the scheduled backups.
+	BackupStatus struct {
+		LastRun     int64  `json:"LastRun"`
+		LastSuccess int64  `json:"LastSuccess"`
+		LastFailure int64  `json:"LastFailure"`
+		LastError   string `json:"LastError"`
+		LastArchive string `json:"LastArchive"`
+		NextRun     int64  `json:"NextRun"`
 	}
 
 	// User represents a user account.
@@ -158,6 +205,34 @@ type (
 		AuthorizedTeams []TeamID   `json:"AuthorizedTeams"`
 	}
 
+	// RegistryManifest represents the manifest of an image stored in a registry. The manifest
+	// of a multi-platform image lists the manifests of the platforms instead of layers.
+	RegistryManifest struct {
+		Digest       string                     `json:"Digest"`
+		MediaType    string                     `json:"MediaType"`
+		Size         int64                      `json:"Size"`
+		Created      time.Time                  `json:"Created,omitempty"`
+		Architecture string                     `json:"Architecture,omitempty"`
+		OS           string                     `json:"OS,omitempty"`
+		Layers       []RegistryLayer            `json:"Layers"`
+		Manifests    []RegistryPlatformManifest `json:"Manifests,omitempty"`
+	}
+
+	// RegistryLayer represents a layer of an image stored in a registry.
+	RegistryLayer struct {
+		Digest    string `json:"Digest"`
+		MediaType string `json:"MediaType"`
+		Size      int64  `json:"Size"`
+	}
+
+	// RegistryPlatformManifest represents the manifest of a platform of a multi-platform image.
+	RegistryPlatformManifest struct {
+		Digest       string `json:"Digest"`
+		Size         int64  `json:"Size"`
+		Architecture string `json:"Architecture"`
+		OS           string `json:"OS"`
+	}
+
 	// DockerHub represents all the required information to connect and use the
 	// Docker Hub.
 	DockerHub struct {
@@ -172,13 +247,15 @@ type (
 	// Endpoint represents a Docker endpoint with all the info required
 	// to connect to it.
 	Endpoint struct {
-		ID              EndpointID       `json:"Id"`
-		Name            string           `json:"Name"`
-		URL             string           `json:"URL"`
-		PublicURL       string           `json:"PublicURL"`
-		TLSConfig       TLSConfiguration `json:"TLSConfig"`
-		AuthorizedUsers []UserID         `json:"AuthorizedUsers"`
-		AuthorizedTeams []TeamID         `json:"AuthorizedTeams"`
+		ID                  EndpointID       `json:"Id"`
+		Name                string           `json:"Name"`
+		URL                 string           `json:"URL"`
+		PublicURL           string           `json:"PublicURL"`
+		TLSConfig           TLSConfiguration `json:"TLSConfig"`
+		AuthorizedUsers     []UserID         `json:"AuthorizedUsers"`
+		AuthorizedTeams     []TeamID         `json:"AuthorizedTeams"`
+		RecordSessions      bool             `json:"RecordSessions"`
+		ManagedCertificates bool             `json:"ManagedCertificates"`
 
 		// Deprecated fields
 		// Deprecated in DBVersion == 4
@@ -225,10 +302,98 @@ type (
 	// ResourceAccessLevel represents the level of control associated to a resource.
 	ResourceAccessLevel int
 
+	// RecordingID represents a session recording identifier.
+	RecordingID int
+
+	// RecordingType represents the type of interactive session that has been recorded.
+	// It can be either an exec session or an attach session.
+	RecordingType int
+
+	// Recording represents an interactive console session recorded in the asciicast v2 format.
+	Recording struct {
+		ID          RecordingID   `json:"Id"`
+		Type        RecordingType `json:"Type"`
+		UserID      UserID        `json:"UserId"`
+		Username    string        `json:"Username"`
+		EndpointID  EndpointID    `json:"EndpointId"`
+		ContainerID string        `json:"ContainerId"`
+		ExecID      string        `json:"ExecId,omitempty"`
+		StartedAt   int64         `json:"StartedAt"`
+		Duration    float64       `json:"Duration"`
+		Size        int64         `json:"Size"`
+		FilePath    string        `json:"FilePath"`
+	}
+
+	// TemplateID represents a template identifier.
+	TemplateID int
+
+	// TemplateType represents how a template is deployed, either as a container or as a stack.
+	TemplateType int
+
+	// Template represents an application template. The {{Name}} placeholders of the template are
+	// replaced by the values of its parameters when it is deployed. A template is visible to every
+	// user unless it is restricted to AuthorizedTeams. Source identifies the document the template
+	// was imported from, it is empty for the templates created through the API.
+	Template struct {
+		ID               TemplateID          `json:"Id"`
+		Type             TemplateType        `json:"Type"`
+		Title            string              `json:"Title"`
+		Description      string              `json:"Description"`
+		Note             string              `json:"Note"`
+		Categories       []string            `json:"Categories"`
+		Platform         string              `json:"Platform"`
+		Logo             string              `json:"Logo"`
+		Image            string              `json:"Image"`
+		Command          string              `json:"Command"`
+		Ports            []string            `json:"Ports"`
+		Volumes          []string            `json:"Volumes"`
+		Labels           []Pair              `json:"Labels"`
+		RestartPolicy    string              `json:"RestartPolicy"`
+		StackFileContent string              `json:"StackFileContent"`
+		Repository       TemplateRepository  `json:"Repository"`
+		Parameters       []TemplateParameter `json:"Parameters"`
+		AuthorizedTeams  []TeamID            `json:"AuthorizedTeams"`
+		Source           string              `json:"Source"`
+	}
+
+	// TemplateRepository represents the Git repository holding the stack file of a stack template.
+	TemplateRepository struct {
+		URL       string `json:"URL"`
+		StackFile string `json:"StackFile"`
+	}
+
+	// TemplateParameter represents a parameter of a template. Its value is set as an environment
+	// variable of the container or of the stack, Options restricts the values when it is not empty.
+	TemplateParameter struct {
+		Name        string   `json:"Name"`
+		Label       string   `json:"Label"`
+		Description string   `json:"Description"`
+		Default     string   `json:"Default"`
+		Required    bool     `json:"Required"`
+		Options     []string `json:"Options"`
+	}
+
 	// TLSFileType represents a type of TLS file required to connect to a Docker endpoint.
 	// It can be either a TLS CA file, a TLS certificate file or a TLS key file.
 	TLSFileType int
 
+	// CertificateBundle represents a certificate issued by the built-in certificate authority,
+	// all the fields are PEM encoded.
+	CertificateBundle struct {
+		CACert string `json:"CACert"`
+		Cert   string `json:"Cert"`
+		Key    string `json:"Key"`
+	}
+
+	// CertificateInfo represents the details of a stored TLS certificate.
+	CertificateInfo struct {
+		Path     string `json:"Path"`
+		Subject  string `json:"Subject"`
+		Issuer   string `json:"Issuer"`
+		NotAfter int64  `json:"NotAfter"`
+		Expired  bool   `json:"Expired"`
+	}
+
 	// CLIService represents a service for managing CLI.
 	CLIService interface {
 		ParseFlags(version string) (*HttpCliFlags, error)
@@ -254,6 +419,7 @@ type (
 		UserByUsername(username string) (*User, error)
 		Users() ([]User, error)
 		UsersByRole(role UserRole) ([]User, error)
+		ListUsers(query *UserQuery) ([]User, int, error)
 		CreateUser(user *User) error
 		UpdateUser(ID UserID, user *User) error
 		DeleteUser(ID UserID) error
@@ -264,6 +430,7 @@ type (
 		Team(ID TeamID) (*Team, error)
 		TeamByName(name string) (*Team, error)
 		Teams() ([]Team, error)
+		ListTeams(query *TeamQuery) ([]Team, int, error)
 		CreateTeam(team *Team) error
 		UpdateTeam(ID TeamID, team *Team) error
 		DeleteTeam(ID TeamID) error
@@ -286,6 +453,7 @@ type (
 	EndpointService interface {
 		Endpoint(ID EndpointID) (*Endpoint, error)
 		Endpoints() ([]Endpoint, error)
+		ListEndpoints(query *EndpointQuery) ([]Endpoint, int, error)
 		CreateEndpoint(endpoint *Endpoint) error
 		UpdateEndpoint(ID EndpointID, endpoint *Endpoint) error
 		DeleteEndpoint(ID EndpointID) error
@@ -296,6 +464,7 @@ type (
 	RegistryService interface {
 		Registry(ID RegistryID) (*Registry, error)
 		Registries() ([]Registry, error)
+		ListRegistries(query *RegistryQuery) ([]Registry, int, error)
 		CreateRegistry(registry *Registry) error
 		UpdateRegistry(ID RegistryID, registry *Registry) error
 		DeleteRegistry(ID RegistryID) error
@@ -306,6 +475,7 @@ type (
 		Stack(ID StackID) (*Stack, error)
 		Stacks() ([]Stack, error)
 		StacksBySwarmID(ID string) ([]Stack, error)
+		ListStacks(query *StackQuery) ([]Stack, int, error)
 		CreateStack(stack *Stack) error
 		UpdateStack(ID StackID, stack *Stack) error
 		DeleteStack(ID StackID) error
@@ -334,17 +504,51 @@ type (
 		ResourceControl(ID ResourceControlID) (*ResourceControl, error)
 		ResourceControlByResourceID(resourceID string) (*ResourceControl, error)
 		ResourceControls() ([]ResourceControl, error)
+		ListResourceControls(query *ResourceControlQuery) ([]ResourceControl, int, error)
 		CreateResourceControl(rc *ResourceControl) error
 		UpdateResourceControl(ID ResourceControlID, resourceControl *ResourceControl) error
 		DeleteResourceControl(ID ResourceControlID) error
 	}
 
+	// TemplateService represents a service for managing application templates.
+	TemplateService interface {
+		Template(ID TemplateID) (*Template, error)
+		Templates() ([]Template, error)
+		ListTemplates(query *TemplateQuery) ([]Template, int, error)
+		CreateTemplate(template *Template) error
+		UpdateTemplate(ID TemplateID, template *Template) error
+		DeleteTemplate(ID TemplateID) error
+		ReplaceTemplates(source string, templates []Template) error
+	}
+
+	// BackupStatusService represents a service for managing the status of the scheduled backups.
+	BackupStatusService interface {
+		BackupStatus() (*BackupStatus, error)
+		StoreBackupStatus(status *BackupStatus) error
+	}
+
+	// RecordingService represents a service for managing session recording data.
+	RecordingService interface {
+		Recording(ID RecordingID) (*Recording, error)
+		Recordings() ([]Recording, error)
+		CreateRecording(recording *Recording) error
+		UpdateRecording(ID RecordingID, recording *Recording) error
+		DeleteRecording(ID RecordingID) error
+	}
+
 	// CryptoService represents a service for encrypting/hashing data.
 	CryptoService interface {
 		Hash(data string) (string, error)
 		CompareHashAndData(hash string, data string) error
 	}
 
+	// EncryptionService represents a service for encrypting the secrets stored in the database.
+	EncryptionService interface {
+		Encrypt(value string) (string, error)
+		Decrypt(value string) (string, error)
+		Reencrypt(value string) (string, bool, error)
+	}
+
 	// JWTService represents a service for managing JWT tokens.
 	JWTService interface {
 		GenerateToken(data *TokenData) (string, error)
@@ -362,6 +566,25 @@ type (
 		GetStackProjectPath(stackIdentifier string) string
 		StoreStackFileFromString(stackIdentifier string, stackFileContent string) (string, error)
 		StoreStackFileFromReader(stackIdentifier string, r io.Reader) (string, error)
+		CreateRecordingFile(fileName string) (io.WriteCloser, string, error)
+		DeleteRecordingFile(filePath string) error
+		StoreTemplatesCache(source string, content []byte) error
+		GetTemplatesCache(source string) ([]byte, time.Time, error)
+	}
+
+	// PKIService represents a service managing a built-in certificate authority.
+	PKIService interface {
+		CACertificate() (string, error)
+		IssueServerCertificate(hosts []string, validityDays int) (*CertificateBundle, error)
+		IssueEndpointCertificate(endpoint *Endpoint) error
+		CertificateInfo(certificatePath string) (*CertificateInfo, error)
+	}
+
+	// BackupService represents a service for backing up and restoring the data of the instance.
+	BackupService interface {
+		CreateBackup(w io.Writer, password string) error
+		RestoreBackup(r io.Reader, password string) error
+		StoreBackup(settings *BackupSettings) (string, error)
 	}
 
 	// GitService represents a service for managing Git.
@@ -386,6 +609,7 @@ type (
 		Logout(endpoint *Endpoint) error
 		Deploy(stack *Stack, endpoint *Endpoint) error
 		Remove(stack *Stack, endpoint *Endpoint) error
+		Cancel()
 	}
 )
 
@@ -393,9 +617,12 @@ const (
 	// APIVersion is the version number of the Cloudware API.
 	APIVersion = "0.0.1"
 	// DBVersion is the version number of the Cloudware database.
-	DBVersion = 1
+	DBVersion = 7
 	// DefaultTemplatesURL represents the default URL for the templates definitions.
 	DefaultTemplatesURL = "https://raw.githubusercontent.com/portainer/templates/master/templates.json"
+	// EncryptedSecretPrefix is the prefix of the secrets encrypted with the master key,
+	// it is followed by the version of the encryption format.
+	EncryptedSecretPrefix = "enc:"
 )
 
 const (
@@ -431,12 +658,36 @@ const (
 	AuthenticationLDAP
 )
 
+const (
+	_ BackupStorageType = iota
+	// BackupStorageLocal represents backups written to a local directory
+	BackupStorageLocal
+	// BackupStorageS3 represents backups written to an S3-compatible bucket
+	BackupStorageS3
+)
+
 const (
 	_ ResourceAccessLevel = iota
 	// ReadWriteAccessLevel represents an access level with read-write permissions on a resource
 	ReadWriteAccessLevel
 )
 
+const (
+	_ RecordingType = iota
+	// ExecRecording represents a recording of a session started with docker exec
+	ExecRecording
+	// AttachRecording represents a recording of a session attached to the main process of a container
+	AttachRecording
+)
+
+const (
+	_ TemplateType = iota
+	// ContainerTemplate represents a template deployed as a container
+	ContainerTemplate
+	// StackTemplate represents a template deployed as a stack
+	StackTemplate
+)
+
 const (
 	_ ResourceControlType = iota
 	// ContainerResourceControl represents a resource control associated to a Docker container
@@ -0,0 +1,113 @@
+package cron
+
+import (
+	"sync/atomic"
+	"time"
+
+	"cloudware/cloudware/api"
+	"cloudware/cloudware/api/logging"
+	"github.com/robfig/cron"
+	"github.com/sirupsen/logrus"
+)
+
+// backupScheduleJob runs every minute and creates a backup when the schedule defined
+// in the settings is due, so that schedule changes apply without a restart.
+// A check is skipped while the previous backup is still running.
+type backupScheduleJob struct {
+	running             int32
+	logger              *logrus.Entry
+	settingsService     api.SettingsService
+	backupStatusService api.BackupStatusService
+	backupService       api.BackupService
+	startedAt           time.Time
+}
+
+func newBackupScheduleJob(settingsService api.SettingsService, backupStatusService api.BackupStatusService, backupService api.BackupService) *backupScheduleJob {
+	return &backupScheduleJob{
+		logger:              logging.WithComponent("backup_schedule"),
+		settingsService:     settingsService,
+		backupStatusService: backupStatusService,
+		backupService:       backupService,
+		startedAt:           time.Now(),
+	}
+}
+
+// Check creates a backup when its scheduled time is reached and records the outcome in the backup status.
+// The run is recorded before the backup is created so that a slow backup is not started again.
+func (job *backupScheduleJob) Check() error {
+	if !atomic.CompareAndSwapInt32(&job.running, 0, 1) {
+		job.logger.Debug("Previous backup still running, check skipped")
+		return nil
+	}
+	defer atomic.StoreInt32(&job.running, 0)
+
+	settings, err := job.settingsService.Settings()
+	if err != nil {
+		return err
+	}
+
+	status, err := job.backupStatusService.BackupStatus()
+	if err != nil {
+		return err
+	}
+
+	if settings.BackupSettings.Schedule == "" {
+		if status.NextRun == 0 {
+			return nil
+		}
+		status.NextRun = 0
+		return job.backupStatusService.StoreBackupStatus(status)
+	}
+
+	schedule, err := cron.ParseStandard(settings.BackupSettings.Schedule)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	lastRun := job.startedAt
+	if status.LastRun != 0 {
+		lastRun = time.Unix(status.LastRun, 0)
+	}
+
+	nextRun := schedule.Next(now).Unix()
+	if schedule.Next(lastRun).After(now) {
+		if status.NextRun == nextRun {
+			return nil
+		}
+		status.NextRun = nextRun
+		return job.backupStatusService.StoreBackupStatus(status)
+	}
+
+	status.LastRun = now.Unix()
+	status.NextRun = nextRun
+	err = job.backupStatusService.StoreBackupStatus(status)
+	if err != nil {
+		return err
+	}
+
+	job.run(&settings.BackupSettings, status, now)
+	return job.backupStatusService.StoreBackupStatus(status)
+}
+
+func (job *backupScheduleJob) run(settings *api.BackupSettings, status *api.BackupStatus, now time.Time) {
+	name, err := job.backupService.StoreBackup(settings)
+	if err != nil {
+		job.logger.Errorf("Scheduled backup failed: %s", err)
+		status.LastFailure = now.Unix()
+		status.LastError = err.Error()
+		return
+	}
+
+	job.logger.Infof("Scheduled backup created. [archive: %v]", name)
+	status.LastSuccess = now.Unix()
+	status.LastError = ""
+	status.LastArchive = name
+}
+
+func (job *backupScheduleJob) Run() {
+	err := job.Check()
+	if err != nil {
+		job.logger.Errorf("Backup schedule error: %s", err)
+	}
+}
@@ -0,0 +1,89 @@
+package cron
+
+import (
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"cloudware/cloudware/api"
+)
+
+// blockingBackupService counts the stored backups, which are blocked until release is closed.
+type blockingBackupService struct {
+	mu      sync.Mutex
+	stored  int
+	started chan struct{}
+	release chan struct{}
+}
+
+func (service *blockingBackupService) CreateBackup(w io.Writer, password string) error {
+	return nil
+}
+
+func (service *blockingBackupService) RestoreBackup(r io.Reader, password string) error {
+	return nil
+}
+
+func (service *blockingBackupService) StoreBackup(settings *api.BackupSettings) (string, error) {
+	service.mu.Lock()
+	service.stored++
+	service.mu.Unlock()
+
+	service.started <- struct{}{}
+	<-service.release
+	return "cloudware-backup.tar.gz", nil
+}
+
+func TestBackupScheduleSkipsRunningBackup(t *testing.T) {
+	store, cleanup := newEndpointSyncStore(t)
+	defer cleanup()
+
+	err := store.SettingsService.StoreSettings(&api.Settings{BackupSettings: api.BackupSettings{Schedule: "@every 1h"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	backupService := &blockingBackupService{started: make(chan struct{}, 1), release: make(chan struct{})}
+	job := newBackupScheduleJob(store.SettingsService, store.BackupStatusService, backupService)
+	job.startedAt = time.Now().Add(-2 * time.Hour)
+
+	done := make(chan struct{})
+	go func() {
+		job.Run()
+		close(done)
+	}()
+	<-backupService.started
+
+	status, err := store.BackupStatusService.BackupStatus()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.LastRun == 0 || status.NextRun <= status.LastRun {
+		t.Errorf("expected the run to be recorded before the backup is created, got %+v", status)
+	}
+
+	// The previous backup is still running
+	job.Run()
+
+	close(backupService.release)
+	<-done
+
+	if backupService.stored != 1 {
+		t.Errorf("expected a single backup to be created, got %d", backupService.stored)
+	}
+
+	status, err = store.BackupStatusService.BackupStatus()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.LastSuccess != status.LastRun || status.LastArchive != "cloudware-backup.tar.gz" {
+		t.Errorf("expected the outcome of the backup to be recorded, got %+v", status)
+	}
+
+	// The next check waits for the schedule
+	job.Run()
+	if backupService.stored != 1 {
+		t.Errorf("expected no backup before the next scheduled time, got %d", backupService.stored)
+	}
+}
@@ -0,0 +1,104 @@
+package cron
+
+import (
+	"net/http"
+	"time"
+
+	"cloudware/cloudware/api"
+	"cloudware/cloudware/api/logging"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// certificateRenewalPeriod is the period before the expiry of a certificate during which
+	// a warning is logged and the managed certificates are rotated.
+	certificateRenewalPeriod = 30 * 24 * time.Hour
+)
+
+// ProxyRegistrar represents a service able to replace the proxy of an endpoint after its TLS
+// configuration changed.
+type ProxyRegistrar interface {
+	CreateAndRegisterProxy(endpoint *api.Endpoint) (http.Handler, error)
+}
+
+type certificateWatchJob struct {
+	logger          *logrus.Entry
+	endpointService api.EndpointService
+	pkiService      api.PKIService
+	proxyRegistrar  ProxyRegistrar
+}
+
+func newCertificateWatchJob(endpointService api.EndpointService, pkiService api.PKIService, registrar ProxyRegistrar) certificateWatchJob {
+	return certificateWatchJob{
+		logger:          logging.WithComponent("certificate_watch"),
+		endpointService: endpointService,
+		pkiService:      pkiService,
+		proxyRegistrar:  registrar,
+	}
+}
+
+// Check logs a warning for each endpoint certificate expiring soon and rotates
+// the client certificates issued by the built-in certificate authority.
+func (job certificateWatchJob) Check() error {
+	endpoints, err := job.endpointService.Endpoints()
+	if err != nil {
+		return err
+	}
+
+	limit := time.Now().Add(certificateRenewalPeriod).Unix()
+	for idx := range endpoints {
+		endpoint := &endpoints[idx]
+		if !endpoint.TLSConfig.TLS || endpoint.TLSConfig.TLSCertPath == "" {
+			continue
+		}
+
+		info, err := job.pkiService.CertificateInfo(endpoint.TLSConfig.TLSCertPath)
+		if err != nil {
+			job.logger.Errorf("Unable to read endpoint certificate. [endpoint: %v] [err: %s]", endpoint.Name, err)
+			continue
+		}
+
+		if info.NotAfter > limit {
+			continue
+		}
+
+		if !endpoint.ManagedCertificates {
+			job.logger.Warnf("Endpoint certificate expires soon, it must be renewed manually. [endpoint: %v] [expiry: %v]", endpoint.Name, time.Unix(info.NotAfter, 0))
+			continue
+		}
+
+		err = job.rotate(endpoint)
+		if err == api.ErrPKIDisabled {
+			job.logger.Warnf("Endpoint certificate expires soon and the built-in certificate authority is disabled, it must be renewed manually. [endpoint: %v] [expiry: %v]", endpoint.Name, time.Unix(info.NotAfter, 0))
+			continue
+		} else if err != nil {
+			job.logger.Errorf("Unable to rotate endpoint certificate. [endpoint: %v] [err: %s]", endpoint.Name, err)
+			continue
+		}
+		job.logger.Infof("Endpoint certificate rotated. [endpoint: %v]", endpoint.Name)
+	}
+
+	return nil
+}
+
+func (job certificateWatchJob) rotate(endpoint *api.Endpoint) error {
+	err := job.pkiService.IssueEndpointCertificate(endpoint)
+	if err != nil {
+		return err
+	}
+
+	_, err = job.proxyRegistrar.CreateAndRegisterProxy(endpoint)
+	if err != nil {
+		return err
+	}
+
+	return job.endpointService.UpdateEndpoint(endpoint.ID, endpoint)
+}
+
+func (job certificateWatchJob) Run() {
+	err := job.Check()
+	if err != nil {
+		job.logger.Errorf("Certificate watch error: %s", err)
+	}
+}
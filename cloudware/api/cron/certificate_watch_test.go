@@ -0,0 +1,95 @@
+package cron
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"cloudware/cloudware/api"
+)
+
+// stubPKIService reports the expiry of the certificates by path and records the rotated endpoints.
+type stubPKIService struct {
+	disabled bool
+	expiry   map[string]time.Time
+	issued   []api.EndpointID
+}
+
+func (service *stubPKIService) CACertificate() (string, error) {
+	return "", nil
+}
+
+func (service *stubPKIService) IssueServerCertificate(hosts []string, validityDays int) (*api.CertificateBundle, error) {
+	return nil, nil
+}
+
+func (service *stubPKIService) IssueEndpointCertificate(endpoint *api.Endpoint) error {
+	if service.disabled {
+		return api.ErrPKIDisabled
+	}
+	service.issued = append(service.issued, endpoint.ID)
+	endpoint.TLSConfig.TLSCertPath = "renewed.pem"
+	return nil
+}
+
+func (service *stubPKIService) CertificateInfo(certificatePath string) (*api.CertificateInfo, error) {
+	return &api.CertificateInfo{Path: certificatePath, NotAfter: service.expiry[certificatePath].Unix()}, nil
+}
+
+type stubProxyRegistrar struct {
+	registered []api.EndpointID
+}
+
+func (registrar *stubProxyRegistrar) CreateAndRegisterProxy(endpoint *api.Endpoint) (http.Handler, error) {
+	registrar.registered = append(registrar.registered, endpoint.ID)
+	return nil, nil
+}
+
+func TestCertificateWatchRotatesManagedCertificates(t *testing.T) {
+	managedTLS := func(certPath string) api.TLSConfiguration {
+		return api.TLSConfiguration{TLS: true, TLSCertPath: certPath}
+	}
+
+	store, cleanup := newEndpointSyncStore(t,
+		&api.Endpoint{Name: "expiring", URL: "tcp://10.0.0.1:2376", TLSConfig: managedTLS("expiring.pem"), ManagedCertificates: true},
+		&api.Endpoint{Name: "valid", URL: "tcp://10.0.0.2:2376", TLSConfig: managedTLS("valid.pem"), ManagedCertificates: true},
+		&api.Endpoint{Name: "unmanaged", URL: "tcp://10.0.0.3:2376", TLSConfig: managedTLS("unmanaged.pem")},
+		&api.Endpoint{Name: "plain", URL: "tcp://10.0.0.4:2375"},
+	)
+	defer cleanup()
+
+	expiry := map[string]time.Time{
+		"expiring.pem":  time.Now().Add(24 * time.Hour),
+		"valid.pem":     time.Now().Add(2 * certificateRenewalPeriod),
+		"unmanaged.pem": time.Now().Add(-time.Hour),
+	}
+
+	pkiService := &stubPKIService{expiry: expiry}
+	registrar := &stubProxyRegistrar{}
+	err := newCertificateWatchJob(store.EndpointService, pkiService, registrar).Check()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(pkiService.issued) != 1 || len(registrar.registered) != 1 || registrar.registered[0] != pkiService.issued[0] {
+		t.Fatalf("expected only the expiring managed certificate to be rotated, got %v and %v", pkiService.issued, registrar.registered)
+	}
+
+	endpoint, err := store.EndpointService.Endpoint(pkiService.issued[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if endpoint.Name != "expiring" || endpoint.TLSConfig.TLSCertPath != "renewed.pem" {
+		t.Errorf("expected the renewed certificate to be stored, got %+v", endpoint)
+	}
+
+	pkiService = &stubPKIService{disabled: true, expiry: map[string]time.Time{"renewed.pem": time.Now()}}
+	registrar = &stubProxyRegistrar{}
+	err = newCertificateWatchJob(store.EndpointService, pkiService, registrar).Check()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(registrar.registered) != 0 {
+		t.Errorf("expected no certificate to be rotated when the certificate authority is disabled, got %v", registrar.registered)
+	}
+}
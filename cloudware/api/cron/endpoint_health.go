@@ -0,0 +1,65 @@
+package cron
+
+import (
+	"strconv"
+	"sync"
+
+	"cloudware/cloudware/api"
+	"cloudware/cloudware/api/logging"
+	"cloudware/cloudware/api/metrics"
+
+	"github.com/sirupsen/logrus"
+)
+
+// EndpointPinger represents a service able to check that the Docker API of an endpoint answers.
+type EndpointPinger interface {
+	Ping(endpoint *api.Endpoint) error
+}
+
+type endpointHealthJob struct {
+	logger          *logrus.Entry
+	endpointService api.EndpointService
+	pinger          EndpointPinger
+}
+
+func newEndpointHealthJob(endpointService api.EndpointService, pinger EndpointPinger) endpointHealthJob {
+	return endpointHealthJob{
+		logger:          logging.WithComponent("endpoint_health"),
+		endpointService: endpointService,
+		pinger:          pinger,
+	}
+}
+
+// Check pings every endpoint and records whether it is up in the metrics.
+func (job endpointHealthJob) Check() error {
+	endpoints, err := job.endpointService.Endpoints()
+	if err != nil {
+		return err
+	}
+
+	// The endpoints are pinged concurrently so that unreachable endpoints do not delay the others.
+	up := make([]bool, len(endpoints))
+	var wg sync.WaitGroup
+	for idx := range endpoints {
+		wg.Add(1)
+		go func(idx int) {
+			defer wg.Done()
+			up[idx] = job.pinger.Ping(&endpoints[idx]) == nil
+		}(idx)
+	}
+	wg.Wait()
+
+	health := make(map[string]bool)
+	for idx, endpoint := range endpoints {
+		health[strconv.Itoa(int(endpoint.ID))] = up[idx]
+	}
+	metrics.SetEndpointsUp(health)
+	return nil
+}
+
+func (job endpointHealthJob) Run() {
+	err := job.Check()
+	if err != nil {
+		job.logger.Errorf("Unable to check the health of the endpoints: %s", err)
+	}
+}
@@ -0,0 +1,181 @@
+package cron
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"cloudware/cloudware/api"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	// ErrEndpointSourceUnavailable is an error raised when the external endpoint source URL cannot be retrieved.
+	ErrEndpointSourceUnavailable = api.Error("Unable to retrieve external endpoint source")
+	endpointSourceTimeout        = 30 * time.Second
+
+	// endpointSourceJSON is the format of the sources written in JSON.
+	endpointSourceJSON = "json"
+	// endpointSourceYAML is the format of the sources written in YAML, the sources of an unknown
+	// format are read as YAML which also reads JSON.
+	endpointSourceYAML = "yaml"
+)
+
+// endpointSource represents a location where external endpoint definitions can be retrieved.
+type endpointSource interface {
+	// Fetch returns the content of the source along with its format.
+	Fetch() ([]byte, string, error)
+	// String returns the location of the source.
+	String() string
+}
+
+// newEndpointSource returns a source reading a local file or fetching a HTTP(S) URL.
+func newEndpointSource(location string) endpointSource {
+	if isEndpointSourceURL(location) {
+		return &httpEndpointSource{
+			url:    location,
+			client: &http.Client{Timeout: endpointSourceTimeout},
+		}
+	}
+	return &fileEndpointSource{path: location}
+}
+
+func isEndpointSourceURL(location string) bool {
+	return strings.HasPrefix(location, "http://") || strings.HasPrefix(location, "https://")
+}
+
+type fileEndpointSource struct {
+	path string
+}
+
+func (source *fileEndpointSource) Fetch() ([]byte, string, error) {
+	data, err := ioutil.ReadFile(source.path)
+	if err != nil {
+		return nil, "", err
+	}
+
+	format := endpointSourceYAML
+	if strings.ToLower(filepath.Ext(source.path)) == ".json" {
+		format = endpointSourceJSON
+	}
+	return data, format, nil
+}
+
+func (source *fileEndpointSource) String() string {
+	return source.path
+}
+
+// httpEndpointSource retrieves the endpoint definitions from a HTTP(S) URL.
+// The ETag of the last response is sent with each request so that the content
+// is only transferred when it changed on the remote server.
+type httpEndpointSource struct {
+	url         string
+	client      *http.Client
+	etag        string
+	cache       []byte
+	cacheFormat string
+}
+
+// Fetch retrieves the source, its format is given by the content type of the response,
+// or by the extension of the URL when the content type is not specific.
+func (source *httpEndpointSource) Fetch() ([]byte, string, error) {
+	request, err := http.NewRequest(http.MethodGet, source.url, nil)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if source.etag != "" {
+		request.Header.Set("If-None-Match", source.etag)
+	}
+
+	response, err := source.client.Do(request)
+	if err != nil {
+		return nil, "", err
+	}
+	defer response.Body.Close()
+
+	switch response.StatusCode {
+	case http.StatusNotModified:
+		return source.cache, source.cacheFormat, nil
+	case http.StatusOK:
+		data, err := ioutil.ReadAll(response.Body)
+		if err != nil {
+			return nil, "", err
+		}
+		source.etag = response.Header.Get("ETag")
+		source.cache = data
+		source.cacheFormat = httpEndpointSourceFormat(response.Header.Get("Content-Type"), response.Request.URL.Path)
+		return data, source.cacheFormat, nil
+	default:
+		return nil, "", ErrEndpointSourceUnavailable
+	}
+}
+
+func httpEndpointSourceFormat(contentType, path string) string {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch {
+	case mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"):
+		return endpointSourceJSON
+	case strings.Contains(mediaType, "yaml"):
+		return endpointSourceYAML
+	case strings.ToLower(filepath.Ext(path)) == ".json":
+		return endpointSourceJSON
+	}
+	return endpointSourceYAML
+}
+
+func (source *httpEndpointSource) String() string {
+	return source.url
+}
+
+// decodeFileEndpoints decodes a list of endpoint definitions written in JSON or YAML. Each entry is
+// decoded on its own: the entries that cannot be decoded are returned empty along with their errors,
+// indexed by their position in the source, so that they do not prevent the other entries from being
+// synchronized. The name of an entry is kept when it could be decoded, so that the matching stored
+// endpoint is left untouched. The unknown fields are ignored.
+func decodeFileEndpoints(data []byte, format string) ([]fileEndpoint, map[int][]string, error) {
+	decodeErrors := make(map[int][]string)
+
+	if format == endpointSourceJSON {
+		var entries []json.RawMessage
+		err := json.Unmarshal(data, &entries)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		fileEndpoints := make([]fileEndpoint, len(entries))
+		for idx, entry := range entries {
+			err := json.Unmarshal(entry, &fileEndpoints[idx])
+			if err != nil {
+				fileEndpoints[idx] = fileEndpoint{Name: fileEndpoints[idx].Name}
+				decodeErrors[idx] = []string{fmt.Sprintf("Invalid definition: %s", err)}
+			}
+		}
+		return fileEndpoints, decodeErrors, nil
+	}
+
+	var entries []interface{}
+	err := yaml.Unmarshal(data, &entries)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	fileEndpoints := make([]fileEndpoint, len(entries))
+	for idx, entry := range entries {
+		// The entry is decoded again on its own, there is no partial decoding of a YAML document.
+		content, err := yaml.Marshal(entry)
+		if err == nil {
+			err = yaml.Unmarshal(content, &fileEndpoints[idx])
+		}
+		if err != nil {
+			fileEndpoints[idx] = fileEndpoint{Name: fileEndpoints[idx].Name}
+			decodeErrors[idx] = []string{fmt.Sprintf("Invalid definition: %s", err)}
+		}
+	}
+	return fileEndpoints, decodeErrors, nil
+}
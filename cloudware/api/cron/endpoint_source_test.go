@@ -0,0 +1,308 @@
+package cron
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"cloudware/cloudware/api"
+	"cloudware/cloudware/bolt"
+)
+
+func TestDecodeFileEndpoints(t *testing.T) {
+	for _, test := range []struct {
+		name          string
+		data          string
+		format        string
+		expectedNames []string
+		invalid       []int
+	}{
+		{
+			name:          "json",
+			data:          `[{"Name":"local","URL":"unix:///var/run/docker.sock"},{"Name":"remote","URL":"tcp://10.0.0.1:2375","TLS":true}]`,
+			format:        endpointSourceJSON,
+			expectedNames: []string{"local", "remote"},
+		},
+		{
+			name:          "yaml",
+			data:          "- Name: local\n  URL: unix:///var/run/docker.sock\n- Name: remote\n  URL: tcp://10.0.0.1:2375\n  AuthorizedUsers: [alice]\n",
+			format:        endpointSourceYAML,
+			expectedNames: []string{"local", "remote"},
+		},
+		{
+			name:          "json read as yaml",
+			data:          `[{"Name":"local","URL":"unix:///var/run/docker.sock"}]`,
+			format:        endpointSourceYAML,
+			expectedNames: []string{"local"},
+		},
+		{
+			name:          "unknown fields are ignored",
+			data:          `[{"Name":"local","URL":"unix:///var/run/docker.sock","Group":"production"}]`,
+			format:        endpointSourceJSON,
+			expectedNames: []string{"local"},
+		},
+		{
+			name:          "unknown yaml fields are ignored",
+			data:          "- Name: local\n  URL: unix:///var/run/docker.sock\n  Group: production\n",
+			format:        endpointSourceYAML,
+			expectedNames: []string{"local"},
+		},
+		{
+			name:          "json entry with a wrong type",
+			data:          `[{"Name":"local","URL":"unix:///var/run/docker.sock"},{"Name":"remote","URL":"tcp://10.0.0.1:2375","TLS":"yes"},"invalid"]`,
+			format:        endpointSourceJSON,
+			expectedNames: []string{"local", "remote", ""},
+			invalid:       []int{1, 2},
+		},
+		{
+			name:          "yaml entry with a wrong type",
+			data:          "- Name: local\n  URL: unix:///var/run/docker.sock\n- Name: remote\n  URL: tcp://10.0.0.1:2375\n  AuthorizedTeams: {name: ops}\n",
+			format:        endpointSourceYAML,
+			expectedNames: []string{"local", "remote"},
+			invalid:       []int{1},
+		},
+	} {
+		fileEndpoints, decodeErrors, err := decodeFileEndpoints([]byte(test.data), test.format)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %s", test.name, err)
+		}
+
+		if len(fileEndpoints) != len(test.expectedNames) {
+			t.Fatalf("%s: expected %d entries, got %d", test.name, len(test.expectedNames), len(fileEndpoints))
+		}
+		for idx, name := range test.expectedNames {
+			if fileEndpoints[idx].Name != name {
+				t.Errorf("%s: expected entry %d to be named %q, got %q", test.name, idx, name, fileEndpoints[idx].Name)
+			}
+		}
+
+		if len(decodeErrors) != len(test.invalid) {
+			t.Errorf("%s: expected %d invalid entries, got %v", test.name, len(test.invalid), decodeErrors)
+		}
+		for _, idx := range test.invalid {
+			if len(decodeErrors[idx]) == 0 {
+				t.Errorf("%s: expected entry %d to be invalid", test.name, idx)
+			}
+			if fileEndpoints[idx].URL != "" {
+				t.Errorf("%s: expected invalid entry %d to be discarded, got %+v", test.name, idx, fileEndpoints[idx])
+			}
+		}
+	}
+}
+
+func TestDecodeFileEndpointsInvalidDocument(t *testing.T) {
+	for _, test := range []struct {
+		data   string
+		format string
+	}{
+		{`{"Name":"local"}`, endpointSourceJSON},
+		{`[{"Name":"local"}`, endpointSourceJSON},
+		{"Name: local\n", endpointSourceYAML},
+	} {
+		_, _, err := decodeFileEndpoints([]byte(test.data), test.format)
+		if err == nil {
+			t.Errorf("%q: expected the document to be rejected", test.data)
+		}
+	}
+}
+
+func TestFileEndpointSourceFormat(t *testing.T) {
+	dir, err := ioutil.TempDir("", "endpoint-source")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, test := range []struct {
+		fileName string
+		expected string
+	}{
+		{"endpoints.json", endpointSourceJSON},
+		{"endpoints.JSON", endpointSourceJSON},
+		{"endpoints.yml", endpointSourceYAML},
+		{"endpoints.yaml", endpointSourceYAML},
+		{"endpoints", endpointSourceYAML},
+	} {
+		path := filepath.Join(dir, test.fileName)
+		err := ioutil.WriteFile(path, []byte("[]"), 0600)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, format, err := newEndpointSource(path).Fetch()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if format != test.expected {
+			t.Errorf("%s: expected the %s format, got %s", test.fileName, test.expected, format)
+		}
+	}
+}
+
+func TestHTTPEndpointSource(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		switch r.URL.Path {
+		case "/endpoints":
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		case "/endpoints.json":
+			w.Header().Set("Content-Type", "text/plain")
+		case "/endpoints.yml":
+			w.Header().Set("Content-Type", "application/x-yaml")
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Write([]byte(`[]`))
+	}))
+	defer server.Close()
+
+	for _, test := range []struct {
+		path     string
+		expected string
+	}{
+		{"/endpoints", endpointSourceJSON},
+		{"/endpoints.json", endpointSourceJSON},
+		{"/endpoints.yml", endpointSourceYAML},
+		{"/other", endpointSourceYAML},
+	} {
+		source := newEndpointSource(server.URL + test.path)
+		for attempt := 0; attempt < 2; attempt++ {
+			data, format, err := source.Fetch()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(data) != "[]" {
+				t.Errorf("%s: expected the cached content to be returned, got %q", test.path, data)
+			}
+			if format != test.expected {
+				t.Errorf("%s: expected the %s format, got %s", test.path, test.expected, format)
+			}
+		}
+	}
+
+	if requests != 8 {
+		t.Errorf("expected 8 requests, got %d", requests)
+	}
+}
+
+// newEndpointSyncStore returns an opened store containing the specified endpoints.
+func newEndpointSyncStore(t *testing.T, endpoints ...*api.Endpoint) (*bolt.Store, func()) {
+	store, cleanup := bolt.NewTestStore(t)
+
+	for _, endpoint := range endpoints {
+		err := store.EndpointService.CreateEndpoint(endpoint)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return store, cleanup
+}
+
+func TestEndpointSyncRejectsInvalidEntries(t *testing.T) {
+	store, cleanup := newEndpointSyncStore(t,
+		&api.Endpoint{Name: "kept", URL: "tcp://10.0.0.1:2375"},
+		&api.Endpoint{Name: "updated", URL: "tcp://10.0.0.2:2375"},
+		&api.Endpoint{Name: "removed", URL: "tcp://10.0.0.3:2375"},
+	)
+	defer cleanup()
+
+	dir, err := ioutil.TempDir("", "endpoint-source")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "endpoints.json")
+	err = ioutil.WriteFile(path, []byte(`[
+		{"Name":"kept","URL":"tcp://10.0.0.10:2375","TLS":"yes"},
+		{"Name":"updated","URL":"tcp://10.0.0.20:2375","Group":"production"},
+		{"Name":"created","URL":"unix:///var/run/docker.sock"},
+		{"Name":"invalid","URL":"http://10.0.0.4:2375"},
+		{"Name":"unknown user","URL":"tcp://10.0.0.5:2375","AuthorizedUsers":["nobody"]}
+	]`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	job := newEndpointSyncJob(path, store.EndpointService, store.UserService, store.TeamService)
+	err = job.Sync()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	endpoints, err := store.EndpointService.Endpoints()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	urls := make(map[string]string)
+	for _, endpoint := range endpoints {
+		urls[endpoint.Name] = endpoint.URL
+	}
+
+	expected := map[string]string{
+		"kept":    "tcp://10.0.0.1:2375",
+		"updated": "tcp://10.0.0.20:2375",
+		"created": "unix:///var/run/docker.sock",
+	}
+	if len(urls) != len(expected) {
+		t.Errorf("expected the endpoints %v, got %v", expected, urls)
+	}
+	for name, url := range expected {
+		if urls[name] != url {
+			t.Errorf("%s: expected the URL %q, got %q", name, url, urls[name])
+		}
+	}
+}
+
+func TestWatcherStopsFileWatch(t *testing.T) {
+	store, cleanup := newEndpointSyncStore(t)
+	defer cleanup()
+
+	dir, err := ioutil.TempDir("", "endpoint-source")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "endpoints.yml")
+	err = ioutil.WriteFile(path, []byte("- Name: local\n  URL: unix:///var/run/docker.sock\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	watcher := NewWatcher(store.EndpointService, store.UserService, store.TeamService, "1h")
+	err = watcher.WatchEndpointFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stopped := make(chan struct{})
+	go func() {
+		err := watcher.Stop(context.Background())
+		if err != nil {
+			t.Error(err)
+		}
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the watcher to stop")
+	}
+
+	if watcher.stopFileWatch != nil {
+		t.Error("expected the file watch to be released")
+	}
+}
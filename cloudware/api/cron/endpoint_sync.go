@@ -1,20 +1,26 @@
 package cron
 
 import (
-	"encoding/json"
-	"io/ioutil"
-	"log"
-	"os"
+	"fmt"
 	"strings"
+	"sync"
+	"time"
 
 	"cloudware/cloudware/api"
+	"cloudware/cloudware/api/logging"
+	"cloudware/cloudware/api/metrics"
+
+	"github.com/sirupsen/logrus"
 )
 
 type (
 	endpointSyncJob struct {
-		logger           *log.Logger
-		endpointService  api.EndpointService
-		endpointFilePath string
+		mu              sync.Mutex
+		logger          *logrus.Entry
+		endpointService api.EndpointService
+		userService     api.UserService
+		teamService     api.TeamService
+		source          endpointSource
 	}
 
 	synchronization struct {
@@ -24,13 +30,16 @@ type (
 	}
 
 	fileEndpoint struct {
-		Name          string `json:"Name"`
-		URL           string `json:"URL"`
-		TLS           bool   `json:"TLS,omitempty"`
-		TLSSkipVerify bool   `json:"TLSSkipVerify,omitempty"`
-		TLSCACert     string `json:"TLSCACert,omitempty"`
-		TLSCert       string `json:"TLSCert,omitempty"`
-		TLSKey        string `json:"TLSKey,omitempty"`
+		Name            string   `json:"Name" yaml:"Name"`
+		URL             string   `json:"URL" yaml:"URL"`
+		PublicURL       string   `json:"PublicURL,omitempty" yaml:"PublicURL,omitempty"`
+		TLS             bool     `json:"TLS,omitempty" yaml:"TLS,omitempty"`
+		TLSSkipVerify   bool     `json:"TLSSkipVerify,omitempty" yaml:"TLSSkipVerify,omitempty"`
+		TLSCACert       string   `json:"TLSCACert,omitempty" yaml:"TLSCACert,omitempty"`
+		TLSCert         string   `json:"TLSCert,omitempty" yaml:"TLSCert,omitempty"`
+		TLSKey          string   `json:"TLSKey,omitempty" yaml:"TLSKey,omitempty"`
+		AuthorizedUsers []string `json:"AuthorizedUsers,omitempty" yaml:"AuthorizedUsers,omitempty"`
+		AuthorizedTeams []string `json:"AuthorizedTeams,omitempty" yaml:"AuthorizedTeams,omitempty"`
 	}
 )
 
@@ -39,17 +48,19 @@ const (
 	ErrEmptyEndpointArray = api.Error("External endpoint source is empty")
 )
 
-func newEndpointSyncJob(endpointFilePath string, endpointService api.EndpointService) endpointSyncJob {
-	return endpointSyncJob{
-		logger:           log.New(os.Stderr, "", log.LstdFlags),
-		endpointService:  endpointService,
-		endpointFilePath: endpointFilePath,
+func newEndpointSyncJob(endpointSourceLocation string, endpointService api.EndpointService, userService api.UserService, teamService api.TeamService) *endpointSyncJob {
+	return &endpointSyncJob{
+		logger:          logging.WithComponent("endpoint_sync"),
+		endpointService: endpointService,
+		userService:     userService,
+		teamService:     teamService,
+		source:          newEndpointSource(endpointSourceLocation),
 	}
 }
 
-func endpointSyncError(err error, logger *log.Logger) bool {
+func endpointSyncError(err error, logger *logrus.Entry) bool {
 	if err != nil {
-		logger.Printf("Endpoint synchronization error: %s", err)
+		logger.Errorf("Endpoint synchronization error: %s", err)
 		return true
 	}
 	return false
@@ -65,26 +76,126 @@ func isValidEndpoint(endpoint *api.Endpoint) bool {
 	return false
 }
 
-func convertFileEndpoints(fileEndpoints []fileEndpoint) []api.Endpoint {
+// validateFileEndpoints checks every endpoint definition and returns the errors found for each entry,
+// indexed by the position of the entry in the source.
+func validateFileEndpoints(fileEndpoints []fileEndpoint) map[int][]string {
+	validationErrors := make(map[int][]string)
+	names := make(map[string]int)
+
+	for idx, e := range fileEndpoints {
+		var errors []string
+
+		if e.Name == "" {
+			errors = append(errors, "Name is required")
+		} else if previous, ok := names[e.Name]; ok {
+			errors = append(errors, fmt.Sprintf("Name is already used by entry %d", previous))
+		} else {
+			names[e.Name] = idx
+		}
+
+		if e.URL == "" {
+			errors = append(errors, "URL is required")
+		} else if !strings.HasPrefix(e.URL, "unix://") && !strings.HasPrefix(e.URL, "tcp://") {
+			errors = append(errors, "URL must use the unix:// or tcp:// protocol")
+		}
+
+		if e.PublicURL != "" && strings.Contains(e.PublicURL, "://") {
+			errors = append(errors, "PublicURL must not contain a protocol")
+		}
+
+		if !e.TLS && (e.TLSSkipVerify || e.TLSCACert != "" || e.TLSCert != "" || e.TLSKey != "") {
+			errors = append(errors, "TLS options require TLS to be enabled")
+		}
+
+		if (e.TLSCert == "") != (e.TLSKey == "") {
+			errors = append(errors, "TLSCert and TLSKey must be specified together")
+		}
+
+		if len(errors) > 0 {
+			validationErrors[idx] = errors
+		}
+	}
+
+	return validationErrors
+}
+
+// convertFileEndpoint converts an endpoint definition, the access lists are resolved from
+// user and team names. An error is returned when a user or a team does not exist.
+func (job *endpointSyncJob) convertFileEndpoint(e *fileEndpoint) (api.Endpoint, []string) {
+	var errors []string
+
+	endpoint := api.Endpoint{
+		Name:      e.Name,
+		URL:       e.URL,
+		PublicURL: e.PublicURL,
+		TLSConfig: api.TLSConfiguration{},
+	}
+	if e.TLS {
+		endpoint.TLSConfig.TLS = true
+		endpoint.TLSConfig.TLSSkipVerify = e.TLSSkipVerify
+		endpoint.TLSConfig.TLSCACertPath = e.TLSCACert
+		endpoint.TLSConfig.TLSCertPath = e.TLSCert
+		endpoint.TLSConfig.TLSKeyPath = e.TLSKey
+	}
+
+	if e.AuthorizedUsers != nil {
+		endpoint.AuthorizedUsers = []api.UserID{}
+		for _, username := range e.AuthorizedUsers {
+			user, err := job.userService.UserByUsername(username)
+			if err != nil {
+				errors = append(errors, fmt.Sprintf("Unable to find user %s: %s", username, err))
+				continue
+			}
+			endpoint.AuthorizedUsers = append(endpoint.AuthorizedUsers, user.ID)
+		}
+	}
+
+	if e.AuthorizedTeams != nil {
+		endpoint.AuthorizedTeams = []api.TeamID{}
+		for _, name := range e.AuthorizedTeams {
+			team, err := job.teamService.TeamByName(name)
+			if err != nil {
+				errors = append(errors, fmt.Sprintf("Unable to find team %s: %s", name, err))
+				continue
+			}
+			endpoint.AuthorizedTeams = append(endpoint.AuthorizedTeams, team.ID)
+		}
+	}
+
+	return endpoint, errors
+}
+
+// convertFileEndpoints converts the valid endpoint definitions, the entries that could not be decoded
+// are skipped with their decode errors. The names of the invalid definitions are returned so that
+// the matching stored endpoints are left untouched.
+func (job *endpointSyncJob) convertFileEndpoints(fileEndpoints []fileEndpoint, decodeErrors map[int][]string) ([]api.Endpoint, map[string]bool) {
 	convertedEndpoints := make([]api.Endpoint, 0)
+	invalidNames := make(map[string]bool)
 
-	for _, e := range fileEndpoints {
-		endpoint := api.Endpoint{
-			Name:      e.Name,
-			URL:       e.URL,
-			TLSConfig: api.TLSConfiguration{},
+	validationErrors := validateFileEndpoints(fileEndpoints)
+	for idx := range fileEndpoints {
+		e := &fileEndpoints[idx]
+
+		errors := validationErrors[idx]
+		if decodeErrors[idx] != nil {
+			errors = decodeErrors[idx]
 		}
-		if e.TLS {
-			endpoint.TLSConfig.TLS = true
-			endpoint.TLSConfig.TLSSkipVerify = e.TLSSkipVerify
-			endpoint.TLSConfig.TLSCACertPath = e.TLSCACert
-			endpoint.TLSConfig.TLSCertPath = e.TLSCert
-			endpoint.TLSConfig.TLSKeyPath = e.TLSKey
+		if len(errors) == 0 {
+			var endpoint api.Endpoint
+			endpoint, errors = job.convertFileEndpoint(e)
+			if len(errors) == 0 {
+				convertedEndpoints = append(convertedEndpoints, endpoint)
+				continue
+			}
+		}
+
+		job.logger.Warnf("Invalid external endpoint definition, skipping. [entry: %d] [name: %v] [errors: %s]", idx, e.Name, strings.Join(errors, "; "))
+		if e.Name != "" {
+			invalidNames[e.Name] = true
 		}
-		convertedEndpoints = append(convertedEndpoints, endpoint)
 	}
 
-	return convertedEndpoints
+	return convertedEndpoints, invalidNames
 }
 
 func endpointExists(endpoint *api.Endpoint, endpoints []api.Endpoint) int {
@@ -96,15 +207,42 @@ func endpointExists(endpoint *api.Endpoint, endpoints []api.Endpoint) int {
 	return -1
 }
 
+func sameUserIDs(a, b []api.UserID) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for idx := range a {
+		if a[idx] != b[idx] {
+			return false
+		}
+	}
+	return true
+}
+
+func sameTeamIDs(a, b []api.TeamID) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for idx := range a {
+		if a[idx] != b[idx] {
+			return false
+		}
+	}
+	return true
+}
+
 func mergeEndpointIfRequired(original, updated *api.Endpoint) *api.Endpoint {
 	var endpoint *api.Endpoint
-	if original.URL != updated.URL || original.TLSConfig.TLS != updated.TLSConfig.TLS ||
+	if original.URL != updated.URL || original.PublicURL != updated.PublicURL || original.TLSConfig.TLS != updated.TLSConfig.TLS ||
 		(updated.TLSConfig.TLS && original.TLSConfig.TLSSkipVerify != updated.TLSConfig.TLSSkipVerify) ||
 		(updated.TLSConfig.TLS && original.TLSConfig.TLSCACertPath != updated.TLSConfig.TLSCACertPath) ||
 		(updated.TLSConfig.TLS && original.TLSConfig.TLSCertPath != updated.TLSConfig.TLSCertPath) ||
-		(updated.TLSConfig.TLS && original.TLSConfig.TLSKeyPath != updated.TLSConfig.TLSKeyPath) {
+		(updated.TLSConfig.TLS && original.TLSConfig.TLSKeyPath != updated.TLSConfig.TLSKeyPath) ||
+		(updated.AuthorizedUsers != nil && !sameUserIDs(original.AuthorizedUsers, updated.AuthorizedUsers)) ||
+		(updated.AuthorizedTeams != nil && !sameTeamIDs(original.AuthorizedTeams, updated.AuthorizedTeams)) {
 		endpoint = original
 		endpoint.URL = updated.URL
+		endpoint.PublicURL = updated.PublicURL
 		if updated.TLSConfig.TLS {
 			endpoint.TLSConfig.TLS = true
 			endpoint.TLSConfig.TLSSkipVerify = updated.TLSConfig.TLSSkipVerify
@@ -118,6 +256,13 @@ func mergeEndpointIfRequired(original, updated *api.Endpoint) *api.Endpoint {
 			endpoint.TLSConfig.TLSCertPath = ""
 			endpoint.TLSConfig.TLSKeyPath = ""
 		}
+		// Access lists are only managed by the source when they are specified
+		if updated.AuthorizedUsers != nil {
+			endpoint.AuthorizedUsers = updated.AuthorizedUsers
+		}
+		if updated.AuthorizedTeams != nil {
+			endpoint.AuthorizedTeams = updated.AuthorizedTeams
+		}
 	}
 	return endpoint
 }
@@ -130,7 +275,7 @@ func (sync synchronization) requireSync() bool {
 }
 
 // TMP: endpointSyncJob method to access logger, should be generic
-func (job endpointSyncJob) prepareSyncData(storedEndpoints, fileEndpoints []api.Endpoint) *synchronization {
+func (job *endpointSyncJob) prepareSyncData(storedEndpoints, fileEndpoints []api.Endpoint, invalidNames map[string]bool) *synchronization {
 	endpointsToCreate := make([]*api.Endpoint, 0)
 	endpointsToUpdate := make([]*api.Endpoint, 0)
 	endpointsToDelete := make([]*api.Endpoint, 0)
@@ -140,25 +285,29 @@ func (job endpointSyncJob) prepareSyncData(storedEndpoints, fileEndpoints []api.
 		if fidx != -1 {
 			endpoint := mergeEndpointIfRequired(&storedEndpoints[idx], &fileEndpoints[fidx])
 			if endpoint != nil {
-				job.logger.Printf("New definition for a stored endpoint found in file, updating database. [name: %v] [url: %v]\n", endpoint.Name, endpoint.URL)
+				job.logger.Infof("New definition for a stored endpoint found in file, updating database. [name: %v] [url: %v]", endpoint.Name, endpoint.URL)
 				endpointsToUpdate = append(endpointsToUpdate, endpoint)
 			} else {
-				job.logger.Printf("No change detected for a stored endpoint. [name: %v] [url: %v]\n", storedEndpoints[idx].Name, storedEndpoints[idx].URL)
+				job.logger.Infof("No change detected for a stored endpoint. [name: %v] [url: %v]", storedEndpoints[idx].Name, storedEndpoints[idx].URL)
 			}
+		} else if invalidNames[storedEndpoints[idx].Name] {
+			job.logger.Warnf("Invalid definition for a stored endpoint found in file, keeping the stored endpoint. [name: %v] [url: %v]", storedEndpoints[idx].Name, storedEndpoints[idx].URL)
 		} else {
-			job.logger.Printf("Stored endpoint not found in file (definition might be invalid), removing from database. [name: %v] [url: %v]", storedEndpoints[idx].Name, storedEndpoints[idx].URL)
+			job.logger.Warnf("Stored endpoint not found in file, removing from database. [name: %v] [url: %v]", storedEndpoints[idx].Name, storedEndpoints[idx].URL)
 			endpointsToDelete = append(endpointsToDelete, &storedEndpoints[idx])
 		}
 	}
 
-	for idx, endpoint := range fileEndpoints {
-		if !isValidEndpoint(&endpoint) {
-			job.logger.Printf("Invalid file endpoint definition, skipping. [name: %v] [url: %v]", endpoint.Name, endpoint.URL)
-			continue
-		}
+	for idx := range fileEndpoints {
 		sidx := endpointExists(&fileEndpoints[idx], storedEndpoints)
 		if sidx == -1 {
-			job.logger.Printf("File endpoint not found in database, adding to database. [name: %v] [url: %v]", fileEndpoints[idx].Name, fileEndpoints[idx].URL)
+			job.logger.Infof("File endpoint not found in database, adding to database. [name: %v] [url: %v]", fileEndpoints[idx].Name, fileEndpoints[idx].URL)
+			if fileEndpoints[idx].AuthorizedUsers == nil {
+				fileEndpoints[idx].AuthorizedUsers = []api.UserID{}
+			}
+			if fileEndpoints[idx].AuthorizedTeams == nil {
+				fileEndpoints[idx].AuthorizedTeams = []api.TeamID{}
+			}
 			endpointsToCreate = append(endpointsToCreate, &fileEndpoints[idx])
 		}
 	}
@@ -170,14 +319,25 @@ func (job endpointSyncJob) prepareSyncData(storedEndpoints, fileEndpoints []api.
 	}
 }
 
-func (job endpointSyncJob) Sync() error {
-	data, err := ioutil.ReadFile(job.endpointFilePath)
+// Sync synchronizes the endpoints with the external source, the result is recorded in the metrics.
+func (job *endpointSyncJob) Sync() error {
+	start := time.Now()
+	err := job.sync()
+	metrics.ObserveEndpointSync(time.Since(start), err)
+	return err
+}
+
+func (job *endpointSyncJob) sync() error {
+	// The synchronization can be triggered by the cron and by the file watcher at the same time
+	job.mu.Lock()
+	defer job.mu.Unlock()
+
+	data, format, err := job.source.Fetch()
 	if endpointSyncError(err, job.logger) {
 		return err
 	}
 
-	var fileEndpoints []fileEndpoint
-	err = json.Unmarshal(data, &fileEndpoints)
+	fileEndpoints, decodeErrors, err := decodeFileEndpoints(data, format)
 	if endpointSyncError(err, job.logger) {
 		return err
 	}
@@ -191,21 +351,21 @@ func (job endpointSyncJob) Sync() error {
 		return err
 	}
 
-	convertedFileEndpoints := convertFileEndpoints(fileEndpoints)
+	convertedFileEndpoints, invalidNames := job.convertFileEndpoints(fileEndpoints, decodeErrors)
 
-	sync := job.prepareSyncData(storedEndpoints, convertedFileEndpoints)
+	sync := job.prepareSyncData(storedEndpoints, convertedFileEndpoints, invalidNames)
 	if sync.requireSync() {
 		err = job.endpointService.Synchronize(sync.endpointsToCreate, sync.endpointsToUpdate, sync.endpointsToDelete)
 		if endpointSyncError(err, job.logger) {
 			return err
 		}
-		job.logger.Printf("Endpoint synchronization ended. [created: %v] [updated: %v] [deleted: %v]", len(sync.endpointsToCreate), len(sync.endpointsToUpdate), len(sync.endpointsToDelete))
+		job.logger.Infof("Endpoint synchronization ended. [created: %v] [updated: %v] [deleted: %v]", len(sync.endpointsToCreate), len(sync.endpointsToUpdate), len(sync.endpointsToDelete))
 	}
 	return nil
 }
 
-func (job endpointSyncJob) Run() {
-	job.logger.Println("Endpoint synchronization job started.")
+func (job *endpointSyncJob) Run() {
+	job.logger.Infoln("Endpoint synchronization job started.")
 	err := job.Sync()
 	endpointSyncError(err, job.logger)
 }
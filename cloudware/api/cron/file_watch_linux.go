@@ -0,0 +1,105 @@
+package cron
+
+import (
+	"bytes"
+	"path/filepath"
+	"sync"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// fileWatchDelay is the delay used to group the events raised while a file is being written.
+	fileWatchDelay = 500 * time.Millisecond
+	fileWatchMask  = unix.IN_CLOSE_WRITE | unix.IN_MOVED_TO | unix.IN_CREATE
+)
+
+// watchFile calls onChange each time the file at filePath is written or replaced.
+// The parent directory is watched so that files replaced through a rename (as done
+// by most editors and configuration management tools) are still detected.
+// The returned function stops watching the file.
+func watchFile(filePath string, onChange func()) (func(), error) {
+	fd, err := unix.InotifyInit1(unix.IN_CLOEXEC | unix.IN_NONBLOCK)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = unix.InotifyAddWatch(fd, filepath.Dir(filePath), fileWatchMask)
+	if err != nil {
+		unix.Close(fd)
+		return nil, err
+	}
+
+	// The reading goroutine is woken up through a pipe when the watch is stopped.
+	var stopFds [2]int
+	err = unix.Pipe2(stopFds[:], unix.O_CLOEXEC)
+	if err != nil {
+		unix.Close(fd)
+		return nil, err
+	}
+
+	done := make(chan struct{})
+	go readFileEvents(fd, stopFds[0], filepath.Base(filePath), onChange, done)
+
+	var once sync.Once
+	stop := func() {
+		once.Do(func() {
+			unix.Close(stopFds[1])
+			<-done
+		})
+	}
+	return stop, nil
+}
+
+func readFileEvents(fd, stopFd int, fileName string, onChange func(), done chan<- struct{}) {
+	var timer *time.Timer
+	defer func() {
+		if timer != nil {
+			timer.Stop()
+		}
+		unix.Close(fd)
+		unix.Close(stopFd)
+		close(done)
+	}()
+
+	buffer := make([]byte, 64*(unix.SizeofInotifyEvent+unix.PathMax))
+	fds := []unix.PollFd{{Fd: int32(fd), Events: unix.POLLIN}, {Fd: int32(stopFd), Events: unix.POLLIN}}
+	for {
+		_, err := unix.Poll(fds, -1)
+		if err == unix.EINTR {
+			continue
+		} else if err != nil || fds[1].Revents != 0 {
+			return
+		}
+
+		n, err := unix.Read(fd, buffer)
+		if err == unix.EINTR || err == unix.EAGAIN {
+			continue
+		} else if err != nil || n <= 0 {
+			return
+		}
+
+		changed := false
+		for offset := 0; offset+unix.SizeofInotifyEvent <= n; {
+			event := (*unix.InotifyEvent)(unsafe.Pointer(&buffer[offset]))
+			nameStart := offset + unix.SizeofInotifyEvent
+			name := bytes.TrimRight(buffer[nameStart:nameStart+int(event.Len)], "\x00")
+			if string(name) == fileName {
+				changed = true
+			}
+			offset = nameStart + int(event.Len)
+		}
+
+		if !changed {
+			continue
+		}
+
+		if timer == nil {
+			timer = time.AfterFunc(fileWatchDelay, onChange)
+		} else {
+			timer.Reset(fileWatchDelay)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package cron
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWatchFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "file-watch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "endpoints.yml")
+	changes := make(chan struct{}, 10)
+	stop, err := watchFile(path, func() { changes <- struct{}{} })
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Files replaced through a rename are detected, the other files of the directory are ignored.
+	err = ioutil.WriteFile(filepath.Join(dir, "other.yml"), []byte("[]"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ioutil.WriteFile(path+".tmp", []byte("[]"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.Rename(path+".tmp", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-changes:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the change to be detected")
+	}
+
+	stop()
+	stop()
+
+	err = ioutil.WriteFile(path, []byte("[]"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-changes:
+		t.Fatal("expected the changes to be ignored once the watch is stopped")
+	case <-time.After(2 * fileWatchDelay):
+	}
+}
@@ -0,0 +1,13 @@
+// +build !linux
+
+package cron
+
+import "cloudware/cloudware/api"
+
+// ErrFileWatchNotSupported is an error raised when file changes cannot be watched on the current platform.
+const ErrFileWatchNotSupported = api.Error("File watching is not supported on this platform")
+
+// watchFile is not supported on this platform, the file is only synchronized on the cron schedule.
+func watchFile(filePath string, onChange func()) (func(), error) {
+	return nil, ErrFileWatchNotSupported
+}
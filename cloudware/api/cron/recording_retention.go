@@ -0,0 +1,75 @@
+package cron
+
+import (
+	"time"
+
+	"cloudware/cloudware/api"
+	"cloudware/cloudware/api/logging"
+
+	"github.com/sirupsen/logrus"
+)
+
+type recordingRetentionJob struct {
+	logger           *logrus.Entry
+	recordingService api.RecordingService
+	settingsService  api.SettingsService
+	fileService      api.FileService
+}
+
+func newRecordingRetentionJob(recordingService api.RecordingService, settingsService api.SettingsService, fileService api.FileService) recordingRetentionJob {
+	return recordingRetentionJob{
+		logger:           logging.WithComponent("recording_retention"),
+		recordingService: recordingService,
+		settingsService:  settingsService,
+		fileService:      fileService,
+	}
+}
+
+// Purge removes the recordings older than the retention period defined in the settings.
+// A retention period of 0 days keeps the recordings forever.
+func (job recordingRetentionJob) Purge() error {
+	settings, err := job.settingsService.Settings()
+	if err != nil {
+		return err
+	}
+
+	if settings.SessionRecordingRetention <= 0 {
+		return nil
+	}
+
+	recordings, err := job.recordingService.Recordings()
+	if err != nil {
+		return err
+	}
+
+	limit := time.Now().AddDate(0, 0, -settings.SessionRecordingRetention).Unix()
+	deleted := 0
+	for _, recording := range recordings {
+		if recording.StartedAt >= limit {
+			continue
+		}
+
+		err = job.fileService.DeleteRecordingFile(recording.FilePath)
+		if err != nil {
+			return err
+		}
+
+		err = job.recordingService.DeleteRecording(recording.ID)
+		if err != nil {
+			return err
+		}
+		deleted++
+	}
+
+	if deleted > 0 {
+		job.logger.Infof("Session recording retention ended. [deleted: %v]", deleted)
+	}
+	return nil
+}
+
+func (job recordingRetentionJob) Run() {
+	err := job.Purge()
+	if err != nil {
+		job.logger.Errorf("Session recording retention error: %s", err)
+	}
+}
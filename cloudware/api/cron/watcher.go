@@ -1,6 +1,9 @@
 package cron
 
 import (
+	"context"
+	"sync"
+
 	"cloudware/cloudware/api"
 	"github.com/robfig/cron"
 )
@@ -9,28 +12,261 @@ import (
 type Watcher struct {
 	Cron            *cron.Cron
 	EndpointService api.EndpointService
+	UserService     api.UserService
+	TeamService     api.TeamService
+	mu              sync.Mutex
 	syncInterval    string
+	syncCron        *cron.Cron
+	endpointSyncJob *endpointSyncJob
+	stopFileWatch   func()
+	suspended       bool
+	jobs            jobTracker
+}
+
+// jobTracker counts the jobs running so that the watcher can wait for them to end when it is stopped.
+type jobTracker struct {
+	mu      sync.Mutex
+	running int
+	stopped bool
+	idle    chan struct{}
+}
+
+// trackedJob runs a job unless the watcher is stopped and keeps track of it while it runs.
+type trackedJob struct {
+	tracker *jobTracker
+	job     cron.Job
+}
+
+func (job trackedJob) Run() {
+	if !job.tracker.start() {
+		return
+	}
+	defer job.tracker.done()
+	job.job.Run()
+}
+
+// start registers a running job, it returns false once the tracker is stopped.
+func (tracker *jobTracker) start() bool {
+	tracker.mu.Lock()
+	defer tracker.mu.Unlock()
+
+	if tracker.stopped {
+		return false
+	}
+	tracker.running++
+	return true
+}
+
+func (tracker *jobTracker) done() {
+	tracker.mu.Lock()
+	defer tracker.mu.Unlock()
+
+	tracker.running--
+	if tracker.running == 0 && tracker.idle != nil {
+		close(tracker.idle)
+		tracker.idle = nil
+	}
+}
+
+// stop refuses the jobs not started yet and waits for the running ones to end or the context to be done.
+func (tracker *jobTracker) stop(ctx context.Context) error {
+	tracker.mu.Lock()
+	tracker.stopped = true
+	if tracker.running == 0 {
+		tracker.mu.Unlock()
+		return nil
+	}
+	if tracker.idle == nil {
+		tracker.idle = make(chan struct{})
+	}
+	idle := tracker.idle
+	tracker.mu.Unlock()
+
+	select {
+	case <-idle:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
 }
 
 // NewWatcher initializes a new service.
-func NewWatcher(endpointService api.EndpointService, syncInterval string) *Watcher {
+func NewWatcher(endpointService api.EndpointService, userService api.UserService, teamService api.TeamService, syncInterval string) *Watcher {
 	return &Watcher{
 		Cron:            cron.New(),
 		EndpointService: endpointService,
+		UserService:     userService,
+		TeamService:     teamService,
 		syncInterval:    syncInterval,
 	}
 }
 
-// WatchEndpointFile starts a cron job to synchronize the endpoints from a file
+// WatchEndpointFile starts a cron job to synchronize the endpoints from a file or a HTTP(S) URL.
+// Local files are also synchronized as soon as they are modified.
 func (watcher *Watcher) WatchEndpointFile(endpointFilePath string) error {
-	job := newEndpointSyncJob(endpointFilePath, watcher.EndpointService)
+	job := newEndpointSyncJob(endpointFilePath, watcher.EndpointService, watcher.UserService, watcher.TeamService)
 
 	err := job.Sync()
 	if err != nil {
 		return err
 	}
 
-	err = watcher.Cron.AddJob("@every "+watcher.syncInterval, job)
+	watcher.mu.Lock()
+	defer watcher.mu.Unlock()
+
+	watcher.endpointSyncJob = job
+	err = watcher.scheduleEndpointSync()
+	if err != nil {
+		return err
+	}
+
+	if watcher.stopFileWatch != nil {
+		watcher.stopFileWatch()
+		watcher.stopFileWatch = nil
+	}
+
+	if !isEndpointSourceURL(endpointFilePath) {
+		watcher.stopFileWatch, err = watchFile(endpointFilePath, watcher.track(job).Run)
+		if err != nil {
+			job.logger.Warnf("Unable to watch external endpoint file, changes will be applied every %s: %s", watcher.syncInterval, err)
+		}
+	}
+
+	return nil
+}
+
+// SetSyncInterval changes the interval between two synchronizations of the external endpoints.
+// The synchronization is rescheduled when it is already running.
+func (watcher *Watcher) SetSyncInterval(syncInterval string) error {
+	watcher.mu.Lock()
+	defer watcher.mu.Unlock()
+
+	previousInterval := watcher.syncInterval
+	watcher.syncInterval = syncInterval
+	if watcher.endpointSyncJob == nil {
+		return nil
+	}
+
+	err := watcher.scheduleEndpointSync()
+	if err != nil {
+		watcher.syncInterval = previousInterval
+		return err
+	}
+	return nil
+}
+
+// Stop stops the cron jobs and the watch of the external endpoint file, then waits for the jobs
+// already running to end. The context error is returned when it is done before the jobs end.
+func (watcher *Watcher) Stop(ctx context.Context) error {
+	watcher.mu.Lock()
+	watcher.Cron.Stop()
+	if watcher.syncCron != nil {
+		watcher.syncCron.Stop()
+	}
+	if watcher.stopFileWatch != nil {
+		watcher.stopFileWatch()
+		watcher.stopFileWatch = nil
+	}
+	watcher.mu.Unlock()
+
+	return watcher.jobs.stop(ctx)
+}
+
+// track wraps a job so that Stop waits for it.
+func (watcher *Watcher) track(job cron.Job) cron.Job {
+	return trackedJob{tracker: &watcher.jobs, job: job}
+}
+
+// Suspend stops the cron jobs and returns a function starting them again, the jobs already
+// running are not interrupted. The watch of the external endpoint file is kept.
+func (watcher *Watcher) Suspend() func() {
+	watcher.mu.Lock()
+	defer watcher.mu.Unlock()
+
+	watcher.suspended = true
+	watcher.Cron.Stop()
+	if watcher.syncCron != nil {
+		watcher.syncCron.Stop()
+	}
+
+	return func() {
+		watcher.mu.Lock()
+		defer watcher.mu.Unlock()
+
+		watcher.suspended = false
+		watcher.Cron.Start()
+		if watcher.syncCron != nil {
+			watcher.syncCron.Start()
+		}
+	}
+}
+
+// scheduleEndpointSync replaces the cron running the endpoint synchronization,
+// it runs on its own cron so that it can be rescheduled.
+func (watcher *Watcher) scheduleEndpointSync() error {
+	syncCron := cron.New()
+	err := syncCron.AddJob("@every "+watcher.syncInterval, watcher.track(watcher.endpointSyncJob))
+	if err != nil {
+		return err
+	}
+
+	if watcher.syncCron != nil {
+		watcher.syncCron.Stop()
+	}
+	watcher.syncCron = syncCron
+	if !watcher.suspended {
+		watcher.syncCron.Start()
+	}
+	return nil
+}
+
+// WatchRecordingRetention starts a cron job removing the session recordings
+// older than the retention period defined in the settings.
+func (watcher *Watcher) WatchRecordingRetention(recordingService api.RecordingService, settingsService api.SettingsService, fileService api.FileService) error {
+	job := newRecordingRetentionJob(recordingService, settingsService, fileService)
+
+	err := watcher.Cron.AddJob("@hourly", watcher.track(job))
+	if err != nil {
+		return err
+	}
+
+	watcher.Cron.Start()
+	return nil
+}
+
+// WatchCertificates starts a cron job checking the expiry of the endpoint certificates.
+// Certificates issued by the built-in certificate authority are rotated before they expire.
+func (watcher *Watcher) WatchCertificates(pkiService api.PKIService, registrar ProxyRegistrar) error {
+	job := newCertificateWatchJob(watcher.EndpointService, pkiService, registrar)
+
+	err := watcher.Cron.AddJob("@daily", watcher.track(job))
+	if err != nil {
+		return err
+	}
+
+	watcher.Cron.Start()
+	return nil
+}
+
+// WatchEndpointHealth starts a cron job checking that the Docker API of each endpoint answers,
+// the result is exposed in the metrics.
+func (watcher *Watcher) WatchEndpointHealth(pinger EndpointPinger) error {
+	job := newEndpointHealthJob(watcher.EndpointService, pinger)
+
+	err := watcher.Cron.AddJob("@every 1m", watcher.track(job))
+	if err != nil {
+		return err
+	}
+
+	watcher.Cron.Start()
+	return nil
+}
+
+// WatchBackups starts a cron job creating the backups scheduled in the settings.
+func (watcher *Watcher) WatchBackups(settingsService api.SettingsService, backupStatusService api.BackupStatusService, backupService api.BackupService) error {
+	job := newBackupScheduleJob(settingsService, backupStatusService, backupService)
+
+	err := watcher.Cron.AddJob("@every 1m", watcher.track(job))
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,63 @@
+package cron
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// blockingJob signals when it starts and runs until release is closed.
+type blockingJob struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func (job *blockingJob) Run() {
+	job.started <- struct{}{}
+	<-job.release
+}
+
+func TestWatcherStopWaitsForRunningJobs(t *testing.T) {
+	watcher := NewWatcher(nil, nil, nil, "1h")
+	job := &blockingJob{started: make(chan struct{}, 1), release: make(chan struct{})}
+	tracked := watcher.track(job)
+
+	go tracked.Run()
+	<-job.started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err := watcher.Stop(ctx)
+	if err != context.DeadlineExceeded {
+		t.Fatalf("expected the deadline to expire while the job is running, got %v", err)
+	}
+
+	stopped := make(chan error, 1)
+	go func() {
+		stopped <- watcher.Stop(context.Background())
+	}()
+
+	select {
+	case err := <-stopped:
+		t.Fatalf("expected Stop to wait for the running job, got %v", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	close(job.release)
+	select {
+	case err := <-stopped:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected Stop to return once the job ended")
+	}
+
+	// The jobs triggered after Stop are not run.
+	tracked.Run()
+	select {
+	case <-job.started:
+		t.Fatal("expected the job not to run once the watcher is stopped")
+	default:
+	}
+}
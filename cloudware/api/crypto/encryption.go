@@ -0,0 +1,240 @@
+package crypto
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+
+	"cloudware/cloudware/api"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+const (
+	// EncryptionKeyEnvVar is the environment variable used to specify the master key
+	// when no key file is configured.
+	EncryptionKeyEnvVar = "CLOUDWARE_ENCRYPTION_KEY"
+	// PreviousEncryptionKeyEnvVar is the environment variable used to specify the master key
+	// being rotated when no previous key file is configured.
+	PreviousEncryptionKeyEnvVar = "CLOUDWARE_PREVIOUS_ENCRYPTION_KEY"
+)
+
+const (
+	// legacySecretPrefix is the prefix of the secrets encrypted with a key derived through a single SHA-256 hash.
+	legacySecretPrefix = api.EncryptedSecretPrefix + "v1:"
+	// secretPrefix is the prefix of the secrets encrypted with a key derived through PBKDF2, the
+	// encrypted value holds the salt used to derive the key followed by the nonce and the ciphertext.
+	secretPrefix = api.EncryptedSecretPrefix + "v2:"
+
+	saltSize                = 16
+	keyDerivationIterations = 100000
+	keySize                 = 32
+)
+
+// EncryptionService implements the EncryptionService interface using AES-256-GCM.
+// The AES keys are derived from the master keys with PBKDF2, using a random salt stored
+// with each value. A single salt is used by the service to encrypt values, so that the
+// key is only derived once. Values encrypted with the previous key can still be decrypted,
+// which allows the stored secrets to be re-encrypted with a new key.
+type EncryptionService struct {
+	key         []byte
+	previousKey []byte
+	salt        []byte
+	current     cipher.AEAD
+
+	mu   sync.Mutex
+	keys map[string]cipher.AEAD
+}
+
+// NewEncryptionService initializes a new service. The previous key is optional.
+func NewEncryptionService(key, previousKey []byte) (*EncryptionService, error) {
+	if len(key) == 0 {
+		return nil, api.ErrInvalidEncryptionKey
+	}
+
+	salt := make([]byte, saltSize)
+	_, err := io.ReadFull(rand.Reader, salt)
+	if err != nil {
+		return nil, err
+	}
+
+	service := &EncryptionService{
+		key:         key,
+		previousKey: previousKey,
+		salt:        salt,
+		keys:        make(map[string]cipher.AEAD),
+	}
+
+	service.current, err = service.derivedAEAD(key, salt)
+	if err != nil {
+		return nil, err
+	}
+	return service, nil
+}
+
+// LoadEncryptionKey returns the content of the key file, or the value of the environment variable
+// when no key file is specified. It returns nil when no key is configured.
+func LoadEncryptionKey(keyFile, envVar string) ([]byte, error) {
+	var key string
+	if keyFile != "" {
+		content, err := ioutil.ReadFile(keyFile)
+		if err != nil {
+			return nil, err
+		}
+		key = string(content)
+	} else {
+		key = os.Getenv(envVar)
+	}
+
+	key = strings.TrimSpace(key)
+	if key == "" {
+		return nil, nil
+	}
+	return []byte(key), nil
+}
+
+// derivedAEAD returns the cipher using the AES-256 key derived from the key material and the salt.
+// The derivation is slow by design, the ciphers are cached by key and salt.
+func (service *EncryptionService) derivedAEAD(key, salt []byte) (cipher.AEAD, error) {
+	service.mu.Lock()
+	defer service.mu.Unlock()
+
+	cacheKey := string(key) + "\x00" + string(salt)
+	if aead, ok := service.keys[cacheKey]; ok {
+		return aead, nil
+	}
+
+	aead, err := newAEAD(pbkdf2.Key(key, salt, keyDerivationIterations, keySize, sha256.New))
+	if err != nil {
+		return nil, err
+	}
+	service.keys[cacheKey] = aead
+	return aead, nil
+}
+
+// legacyAEAD returns the cipher of the secrets encrypted before the keys were derived with PBKDF2.
+func legacyAEAD(key []byte) (cipher.AEAD, error) {
+	derived := sha256.Sum256(key)
+	return newAEAD(derived[:])
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// Encrypt encrypts a value with the current key. Empty values are not encrypted.
+func (service *EncryptionService) Encrypt(value string) (string, error) {
+	if value == "" {
+		return "", nil
+	}
+
+	nonce := make([]byte, service.current.NonceSize())
+	_, err := io.ReadFull(rand.Reader, nonce)
+	if err != nil {
+		return "", err
+	}
+
+	sealed := service.current.Seal(append(append([]byte(nil), service.salt...), nonce...), nonce, []byte(value), nil)
+	return secretPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt decrypts a value encrypted with the current or the previous key.
+// Values stored before encryption was enabled are returned as is.
+func (service *EncryptionService) Decrypt(value string) (string, error) {
+	if !strings.HasPrefix(value, api.EncryptedSecretPrefix) {
+		return value, nil
+	}
+
+	plaintext, err := service.open(service.key, value)
+	if err == nil {
+		return plaintext, nil
+	}
+
+	if len(service.previousKey) > 0 {
+		plaintext, err = service.open(service.previousKey, value)
+		if err == nil {
+			return plaintext, nil
+		}
+	}
+
+	return "", api.ErrEncryptionKeyMismatch
+}
+
+// Reencrypt returns the value encrypted with the current key. The boolean is false when
+// the value was already encrypted with the current key and does not need to be stored again.
+// The values encrypted with a key derived through SHA-256 are encrypted again.
+func (service *EncryptionService) Reencrypt(value string) (string, bool, error) {
+	if value == "" {
+		return value, false, nil
+	}
+
+	if strings.HasPrefix(value, secretPrefix) {
+		if _, err := service.open(service.key, value); err == nil {
+			return value, false, nil
+		}
+	}
+
+	plaintext, err := service.Decrypt(value)
+	if err != nil {
+		return "", false, err
+	}
+
+	encrypted, err := service.Encrypt(plaintext)
+	if err != nil {
+		return "", false, err
+	}
+	return encrypted, true, nil
+}
+
+// open decrypts a value with the key derived from the key material for its format.
+func (service *EncryptionService) open(key []byte, value string) (string, error) {
+	var aead cipher.AEAD
+	var sealed []byte
+	var err error
+
+	switch {
+	case strings.HasPrefix(value, secretPrefix):
+		sealed, err = base64.StdEncoding.DecodeString(strings.TrimPrefix(value, secretPrefix))
+		if err != nil {
+			return "", err
+		}
+		if len(sealed) < saltSize {
+			return "", api.ErrEncryptionKeyMismatch
+		}
+		aead, err = service.derivedAEAD(key, sealed[:saltSize])
+		sealed = sealed[saltSize:]
+	case strings.HasPrefix(value, legacySecretPrefix):
+		sealed, err = base64.StdEncoding.DecodeString(strings.TrimPrefix(value, legacySecretPrefix))
+		if err != nil {
+			return "", err
+		}
+		aead, err = legacyAEAD(key)
+	default:
+		return "", api.ErrEncryptionKeyMismatch
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if len(sealed) < aead.NonceSize() {
+		return "", api.ErrEncryptionKeyMismatch
+	}
+
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
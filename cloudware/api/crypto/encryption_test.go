@@ -0,0 +1,184 @@
+package crypto
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"io"
+	"strings"
+	"testing"
+
+	"cloudware/cloudware/api"
+)
+
+// legacyEncrypt encrypts a value as the secrets stored before the keys were derived with PBKDF2.
+func legacyEncrypt(t *testing.T, key []byte, value string) string {
+	aead, err := legacyAEAD(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	_, err = io.ReadFull(rand.Reader, nonce)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return legacySecretPrefix + base64.StdEncoding.EncodeToString(aead.Seal(nonce, nonce, []byte(value), nil))
+}
+
+func newTestEncryptionService(t *testing.T, key, previousKey string) *EncryptionService {
+	var previous []byte
+	if previousKey != "" {
+		previous = []byte(previousKey)
+	}
+
+	service, err := NewEncryptionService([]byte(key), previous)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return service
+}
+
+func TestEncryptionRoundTrip(t *testing.T) {
+	service := newTestEncryptionService(t, "master key", "")
+
+	for _, value := range []string{"secret", "a longer secret with spaces and ünicode", strings.Repeat("x", 4096)} {
+		encrypted, err := service.Encrypt(value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(encrypted, secretPrefix) || strings.Contains(encrypted, value) {
+			t.Errorf("expected the value to be encrypted, got %q", encrypted)
+		}
+
+		decrypted, err := service.Decrypt(encrypted)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if decrypted != value {
+			t.Errorf("expected %q, got %q", value, decrypted)
+		}
+
+		// Another instance derives the key from the salt stored with the value
+		decrypted, err = newTestEncryptionService(t, "master key", "").Decrypt(encrypted)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if decrypted != value {
+			t.Errorf("expected %q, got %q", value, decrypted)
+		}
+	}
+
+	first, _ := service.Encrypt("secret")
+	second, _ := service.Encrypt("secret")
+	if first == second {
+		t.Error("expected a random nonce to be used for each value")
+	}
+
+	encrypted, err := service.Encrypt("")
+	if err != nil || encrypted != "" {
+		t.Errorf("expected empty values not to be encrypted, got %q, %v", encrypted, err)
+	}
+
+	decrypted, err := service.Decrypt("clear text")
+	if err != nil || decrypted != "clear text" {
+		t.Errorf("expected the values stored in clear text to be returned as is, got %q, %v", decrypted, err)
+	}
+}
+
+func TestEncryptionSalt(t *testing.T) {
+	first := newTestEncryptionService(t, "master key", "")
+	second := newTestEncryptionService(t, "master key", "")
+
+	firstValue, _ := first.Encrypt("secret")
+	secondValue, _ := second.Encrypt("secret")
+
+	firstSealed, _ := base64.StdEncoding.DecodeString(strings.TrimPrefix(firstValue, secretPrefix))
+	secondSealed, _ := base64.StdEncoding.DecodeString(strings.TrimPrefix(secondValue, secretPrefix))
+	if string(firstSealed[:saltSize]) == string(secondSealed[:saltSize]) {
+		t.Error("expected each service to derive its key with a random salt")
+	}
+}
+
+func TestEncryptionWrongKey(t *testing.T) {
+	encrypted, err := newTestEncryptionService(t, "master key", "").Encrypt("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	service := newTestEncryptionService(t, "other key", "")
+	for _, value := range []string{encrypted, secretPrefix + "invalid", secretPrefix + "c2hvcnQ=", api.EncryptedSecretPrefix + "v9:data"} {
+		_, err = service.Decrypt(value)
+		if err != api.ErrEncryptionKeyMismatch {
+			t.Errorf("%q: expected %q, got %v", value, api.ErrEncryptionKeyMismatch, err)
+		}
+	}
+
+	_, err = NewEncryptionService(nil, nil)
+	if err != api.ErrInvalidEncryptionKey {
+		t.Errorf("expected %q, got %v", api.ErrInvalidEncryptionKey, err)
+	}
+}
+
+func TestEncryptionKeyRotation(t *testing.T) {
+	previous := newTestEncryptionService(t, "previous key", "")
+	encrypted, err := previous.Encrypt("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	legacy := legacyEncrypt(t, []byte("previous key"), "legacy secret")
+
+	service := newTestEncryptionService(t, "current key", "previous key")
+	for value, expected := range map[string]string{encrypted: "secret", legacy: "legacy secret"} {
+		decrypted, err := service.Decrypt(value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if decrypted != expected {
+			t.Errorf("expected %q, got %q", expected, decrypted)
+		}
+
+		reencrypted, updated, err := service.Reencrypt(value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !updated {
+			t.Errorf("expected the value encrypted with the previous key to be encrypted again")
+		}
+
+		decrypted, err = newTestEncryptionService(t, "current key", "").Decrypt(reencrypted)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if decrypted != expected {
+			t.Errorf("expected %q, got %q", expected, decrypted)
+		}
+
+		_, updated, err = service.Reencrypt(reencrypted)
+		if err != nil || updated {
+			t.Errorf("expected the value encrypted with the current key to be left untouched, got %v, %v", updated, err)
+		}
+	}
+
+	reencrypted, updated, err := service.Reencrypt("clear text")
+	if err != nil || !updated || !strings.HasPrefix(reencrypted, secretPrefix) {
+		t.Errorf("expected the value stored in clear text to be encrypted, got %q, %v, %v", reencrypted, updated, err)
+	}
+}
+
+func TestLegacyEncryptionUpgrade(t *testing.T) {
+	service := newTestEncryptionService(t, "master key", "")
+	legacy := legacyEncrypt(t, []byte("master key"), "secret")
+
+	decrypted, err := service.Decrypt(legacy)
+	if err != nil || decrypted != "secret" {
+		t.Fatalf("expected the legacy value to be decrypted, got %q, %v", decrypted, err)
+	}
+
+	reencrypted, updated, err := service.Reencrypt(legacy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !updated || !strings.HasPrefix(reencrypted, secretPrefix) {
+		t.Errorf("expected the legacy value to be encrypted with a derived key, got %q", reencrypted)
+	}
+}
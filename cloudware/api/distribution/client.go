@@ -0,0 +1,473 @@
+package distribution
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"cloudware/cloudware/api"
+)
+
+const (
+	requestTimeout = 30 * time.Second
+	pageSize       = 100
+	// maxManifestSize caps the size of the manifests and of the image configurations.
+	maxManifestSize = 4 << 20
+
+	mediaTypeManifest     = "application/vnd.docker.distribution.manifest.v2+json"
+	mediaTypeManifestList = "application/vnd.docker.distribution.manifest.list.v2+json"
+	mediaTypeOCIManifest  = "application/vnd.oci.image.manifest.v1+json"
+	mediaTypeOCIIndex     = "application/vnd.oci.image.index.v1+json"
+)
+
+var (
+	acceptedManifests = strings.Join([]string{mediaTypeManifest, mediaTypeManifestList, mediaTypeOCIManifest, mediaTypeOCIIndex}, ", ")
+
+	repositoryName   = regexp.MustCompile(`^[a-z0-9]+(?:(?:[._]|__|-+)[a-z0-9]+)*(?:/[a-z0-9]+(?:(?:[._]|__|-+)[a-z0-9]+)*)*$`)
+	tagName          = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9_.-]{0,127}$`)
+	digestName       = regexp.MustCompile(`^[a-z0-9]+(?:[.+_-][a-z0-9]+)*:[a-zA-Z0-9=_-]+$`)
+	challengeParam   = regexp.MustCompile(`(\w+)="([^"]*)"`)
+	linkNextPageHref = regexp.MustCompile(`<([^>]+)>\s*;\s*rel="?next"?`)
+)
+
+type (
+	// Client browses a registry through the version 2 of the registry API, with the
+	// credentials of the registry. It answers the token authentication challenges.
+	Client struct {
+		registry *api.Registry
+		baseURL  *url.URL
+		client   *http.Client
+		tokens   map[string]string
+	}
+
+	descriptor struct {
+		MediaType string `json:"mediaType"`
+		Digest    string `json:"digest"`
+		Size      int64  `json:"size"`
+		Platform  struct {
+			Architecture string `json:"architecture"`
+			OS           string `json:"os"`
+		} `json:"platform"`
+	}
+
+	manifest struct {
+		MediaType string       `json:"mediaType"`
+		Config    descriptor   `json:"config"`
+		Layers    []descriptor `json:"layers"`
+		Manifests []descriptor `json:"manifests"`
+	}
+
+	imageConfig struct {
+		Created      time.Time `json:"created"`
+		Architecture string    `json:"architecture"`
+		OS           string    `json:"os"`
+	}
+
+	// page is a page of the catalog or of the tags of a repository.
+	page struct {
+		Repositories []string `json:"repositories"`
+		Tags         []string `json:"tags"`
+	}
+)
+
+// NewClient returns a client of a registry, HTTPS is used when the URL of the registry has no scheme.
+func NewClient(registry *api.Registry) (*Client, error) {
+	address := registry.URL
+	if !strings.Contains(address, "://") {
+		address = "https://" + address
+	}
+	baseURL, err := url.Parse(strings.TrimSuffix(address, "/"))
+	if err != nil || baseURL.Host == "" {
+		return nil, api.ErrRegistryUnreachable
+	}
+
+	return &Client{
+		registry: registry,
+		baseURL:  baseURL,
+		client:   &http.Client{Timeout: requestTimeout},
+		tokens:   make(map[string]string),
+	}, nil
+}
+
+// Ping checks that the registry implements the version 2 of the API and accepts the credentials.
+func (client *Client) Ping() error {
+	response, err := client.do(http.MethodGet, client.url("/v2/", nil), "")
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	// A server answering 404 does not implement the registry API.
+	if response.StatusCode == http.StatusNotFound {
+		return api.ErrRegistryUnreachable
+	}
+	return checkStatus(response, http.StatusOK)
+}
+
+// Catalog returns the names of the repositories of the registry.
+func (client *Client) Catalog() ([]string, error) {
+	return client.list(client.url("/v2/_catalog", url.Values{"n": []string{strconv.Itoa(pageSize)}}))
+}
+
+// Tags returns the tags of a repository.
+func (client *Client) Tags(repository string) ([]string, error) {
+	if !repositoryName.MatchString(repository) {
+		return nil, api.ErrRegistryRepositoryNotFound
+	}
+	return client.list(client.url("/v2/"+repository+"/tags/list", url.Values{"n": []string{strconv.Itoa(pageSize)}}))
+}
+
+// Manifest returns the manifest of an image, identified by a tag or a digest, along with
+// the creation date and the platform of the image.
+func (client *Client) Manifest(repository, reference string) (*api.RegistryManifest, error) {
+	content, digest, mediaType, err := client.manifest(repository, reference)
+	if err != nil {
+		return nil, err
+	}
+
+	var m manifest
+	err = json.Unmarshal(content, &m)
+	if err != nil {
+		return nil, api.ErrRegistryUnreachable
+	}
+	if m.MediaType != "" {
+		mediaType = m.MediaType
+	}
+
+	result := &api.RegistryManifest{
+		Digest:    digest,
+		MediaType: mediaType,
+		Layers:    make([]api.RegistryLayer, 0, len(m.Layers)),
+	}
+
+	if mediaType == mediaTypeManifestList || mediaType == mediaTypeOCIIndex {
+		result.Size = int64(len(content))
+		for _, platform := range m.Manifests {
+			result.Manifests = append(result.Manifests, api.RegistryPlatformManifest{
+				Digest:       platform.Digest,
+				Size:         platform.Size,
+				Architecture: platform.Platform.Architecture,
+				OS:           platform.Platform.OS,
+			})
+		}
+		return result, nil
+	}
+
+	result.Size = m.Config.Size
+	for _, layer := range m.Layers {
+		result.Size += layer.Size
+		result.Layers = append(result.Layers, api.RegistryLayer{Digest: layer.Digest, MediaType: layer.MediaType, Size: layer.Size})
+	}
+
+	if m.Config.Digest != "" {
+		config, err := client.imageConfig(repository, m.Config.Digest)
+		if err != nil {
+			return nil, err
+		}
+		result.Created = config.Created
+		result.Architecture = config.Architecture
+		result.OS = config.OS
+	}
+	return result, nil
+}
+
+// DeleteTag deletes the manifest a tag refers to. The registry deletes the manifests by digest,
+// the other tags of the same manifest are deleted along with it.
+func (client *Client) DeleteTag(repository, tag string) error {
+	if !tagName.MatchString(tag) {
+		return api.ErrRegistryRepositoryNotFound
+	}
+
+	_, digest, _, err := client.manifest(repository, tag)
+	if err != nil {
+		return err
+	}
+
+	response, err := client.do(http.MethodDelete, client.url("/v2/"+repository+"/manifests/"+digest, nil), "")
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode == http.StatusMethodNotAllowed {
+		return api.ErrRegistryDeletionDisabled
+	}
+	return checkStatus(response, http.StatusAccepted, http.StatusOK)
+}
+
+// manifest returns the content, the digest and the media type of a manifest.
+func (client *Client) manifest(repository, reference string) ([]byte, string, string, error) {
+	if !repositoryName.MatchString(repository) || (!tagName.MatchString(reference) && !digestName.MatchString(reference)) {
+		return nil, "", "", api.ErrRegistryRepositoryNotFound
+	}
+
+	response, err := client.do(http.MethodGet, client.url("/v2/"+repository+"/manifests/"+reference, nil), acceptedManifests)
+	if err != nil {
+		return nil, "", "", err
+	}
+	defer response.Body.Close()
+
+	err = checkStatus(response, http.StatusOK)
+	if err != nil {
+		return nil, "", "", err
+	}
+
+	content, err := readLimited(response.Body)
+	if err != nil {
+		return nil, "", "", err
+	}
+
+	// The digest is the hash of the manifest when the registry does not send it.
+	digest := response.Header.Get("Docker-Content-Digest")
+	if digest == "" {
+		sum := sha256.Sum256(content)
+		digest = "sha256:" + hex.EncodeToString(sum[:])
+	}
+	mediaType, _, _ := mime.ParseMediaType(response.Header.Get("Content-Type"))
+	return content, digest, mediaType, nil
+}
+
+func (client *Client) imageConfig(repository, digest string) (*imageConfig, error) {
+	response, err := client.do(http.MethodGet, client.url("/v2/"+repository+"/blobs/"+digest, nil), "")
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	err = checkStatus(response, http.StatusOK)
+	if err != nil {
+		return nil, err
+	}
+
+	content, err := readLimited(response.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var config imageConfig
+	err = json.Unmarshal(content, &config)
+	if err != nil {
+		return nil, api.ErrRegistryUnreachable
+	}
+	return &config, nil
+}
+
+// list returns the names of every page of the catalog or of the tags of a repository,
+// the next page is specified by the Link header of the response.
+func (client *Client) list(pageURL *url.URL) ([]string, error) {
+	names := make([]string, 0)
+	for pageURL != nil {
+		response, err := client.do(http.MethodGet, pageURL, "")
+		if err != nil {
+			return nil, err
+		}
+
+		err = checkStatus(response, http.StatusOK)
+		if err != nil {
+			response.Body.Close()
+			return nil, err
+		}
+
+		var p page
+		err = json.NewDecoder(response.Body).Decode(&p)
+		response.Body.Close()
+		if err != nil {
+			return nil, api.ErrRegistryUnreachable
+		}
+		names = append(names, p.Repositories...)
+		names = append(names, p.Tags...)
+
+		pageURL = nextPage(pageURL, response.Header.Get("Link"))
+	}
+	return names, nil
+}
+
+func nextPage(current *url.URL, link string) *url.URL {
+	match := linkNextPageHref.FindStringSubmatch(link)
+	if match == nil {
+		return nil
+	}
+	next, err := current.Parse(match[1])
+	if err != nil || next.String() == current.String() {
+		return nil
+	}
+	// The pages are requested with the credentials of the registry, a link to another host is not followed.
+	if next.Scheme != current.Scheme || next.Host != current.Host {
+		return nil
+	}
+	return next
+}
+
+func (client *Client) url(path string, query url.Values) *url.URL {
+	u := *client.baseURL
+	u.Path = strings.TrimSuffix(u.Path, "/") + path
+	u.RawQuery = query.Encode()
+	return &u
+}
+
+// do executes a request against the registry. The credentials of the registry are sent with
+// basic authentication, or exchanged for a token when the registry answers with a token challenge.
+func (client *Client) do(method string, u *url.URL, accept string) (*http.Response, error) {
+	response, err := client.send(method, u, accept, "")
+	if err != nil || response.StatusCode != http.StatusUnauthorized {
+		return response, err
+	}
+	response.Body.Close()
+
+	scheme, params := parseChallenge(response.Header.Get("WWW-Authenticate"))
+	if !strings.EqualFold(scheme, "bearer") {
+		return nil, api.ErrRegistryAuthenticationFailed
+	}
+
+	token, err := client.token(params["realm"], params["service"], params["scope"])
+	if err != nil {
+		return nil, err
+	}
+
+	response, err = client.send(method, u, accept, "Bearer "+token)
+	if err != nil {
+		return nil, err
+	}
+	if response.StatusCode == http.StatusUnauthorized {
+		response.Body.Close()
+		return nil, api.ErrRegistryAuthenticationFailed
+	}
+	return response, nil
+}
+
+// send executes a request with an authorization header. The credentials of the registry are sent
+// with basic authentication when no authorization is specified and the request targets the registry.
+func (client *Client) send(method string, u *url.URL, accept, authorization string) (*http.Response, error) {
+	request, err := http.NewRequest(method, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	if accept != "" {
+		request.Header.Set("Accept", accept)
+	}
+	if authorization != "" {
+		request.Header.Set("Authorization", authorization)
+	} else if client.registry.Authentication && u.Scheme == client.baseURL.Scheme && u.Host == client.baseURL.Host {
+		request.SetBasicAuth(client.registry.Username, client.registry.Password)
+	}
+
+	response, err := client.client.Do(request)
+	if err != nil {
+		logrus.WithError(err).WithField("registry", client.registry.URL).Warn("Unable to reach the registry")
+		return nil, api.ErrRegistryUnreachable
+	}
+	return response, nil
+}
+
+// token returns a token of the authorization service of the registry for a scope.
+func (client *Client) token(realm, service, scope string) (string, error) {
+	key := realm + " " + service + " " + scope
+	if token, ok := client.tokens[key]; ok {
+		return token, nil
+	}
+
+	tokenURL, err := url.Parse(realm)
+	if err != nil || realm == "" {
+		return "", api.ErrRegistryAuthenticationFailed
+	}
+	query := tokenURL.Query()
+	if service != "" {
+		query.Set("service", service)
+	}
+	if scope != "" {
+		query.Set("scope", scope)
+	}
+	tokenURL.RawQuery = query.Encode()
+
+	// The authorization service designated by the registry is usually hosted elsewhere,
+	// the credentials are exchanged for the token there.
+	authorization := ""
+	if client.registry.Authentication {
+		authorization = "Basic " + base64.StdEncoding.EncodeToString([]byte(client.registry.Username+":"+client.registry.Password))
+	}
+	response, err := client.send(http.MethodGet, tokenURL, "", authorization)
+	if err != nil {
+		return "", err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode == http.StatusUnauthorized || response.StatusCode == http.StatusForbidden {
+		return "", api.ErrRegistryAuthenticationFailed
+	}
+	err = checkStatus(response, http.StatusOK)
+	if err != nil {
+		return "", err
+	}
+
+	var body struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	err = json.NewDecoder(response.Body).Decode(&body)
+	if err != nil {
+		return "", api.ErrRegistryUnreachable
+	}
+
+	token := body.Token
+	if token == "" {
+		token = body.AccessToken
+	}
+	if token == "" {
+		return "", api.ErrRegistryAuthenticationFailed
+	}
+	client.tokens[key] = token
+	return token, nil
+}
+
+// parseChallenge parses the scheme and the parameters of a WWW-Authenticate header.
+func parseChallenge(challenge string) (string, map[string]string) {
+	params := make(map[string]string)
+	parts := strings.SplitN(strings.TrimSpace(challenge), " ", 2)
+	if len(parts) == 2 {
+		for _, match := range challengeParam.FindAllStringSubmatch(parts[1], -1) {
+			params[strings.ToLower(match[1])] = match[2]
+		}
+	}
+	return parts[0], params
+}
+
+// checkStatus returns the error matching the status of a response of the registry.
+func checkStatus(response *http.Response, expected ...int) error {
+	for _, status := range expected {
+		if response.StatusCode == status {
+			return nil
+		}
+	}
+
+	switch response.StatusCode {
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return api.ErrRegistryAuthenticationFailed
+	case http.StatusNotFound:
+		return api.ErrRegistryRepositoryNotFound
+	}
+	logrus.WithField("url", response.Request.URL.String()).WithField("status", response.StatusCode).Warn("Unexpected response from the registry")
+	return api.ErrRegistryUnreachable
+}
+
+func readLimited(r io.Reader) ([]byte, error) {
+	content, err := ioutil.ReadAll(io.LimitReader(r, maxManifestSize+1))
+	if err != nil {
+		return nil, api.ErrRegistryUnreachable
+	}
+	if len(content) > maxManifestSize {
+		return nil, api.ErrRegistryUnreachable
+	}
+	return content, nil
+}
@@ -0,0 +1,157 @@
+package distribution
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"cloudware/cloudware/api"
+	"cloudware/cloudware/api/distribution/distributiontest"
+)
+
+func newTestClient(t *testing.T, server *distributiontest.Server, username, password string) *Client {
+	client, err := NewClient(&api.Registry{URL: server.URL, Authentication: username != "", Username: username, Password: password})
+	require.NoError(t, err)
+	return client
+}
+
+func TestBrowse(t *testing.T) {
+	server := distributiontest.NewServer()
+	defer server.Close()
+
+	created := time.Date(2018, 3, 1, 12, 0, 0, 0, time.UTC)
+	digest := server.PushImage("team/web", "1.0", created, 100, 200)
+	server.PushImage("team/web", "latest", created, 100, 200)
+	for idx := 0; idx < pageSize+5; idx++ {
+		server.PushImage("repository"+strconv.Itoa(idx), "latest", created, 1)
+	}
+
+	client := newTestClient(t, server, "", "")
+	require.NoError(t, client.Ping())
+
+	repositories, err := client.Catalog()
+	require.NoError(t, err)
+	assert.Len(t, repositories, pageSize+6, "every page of the catalog is returned")
+	assert.Contains(t, repositories, "team/web")
+
+	tags, err := client.Tags("team/web")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"1.0", "latest"}, tags)
+
+	manifest, err := client.Manifest("team/web", "1.0")
+	require.NoError(t, err)
+	assert.Equal(t, digest, manifest.Digest)
+	assert.Equal(t, mediaTypeManifest, manifest.MediaType)
+	assert.True(t, created.Equal(manifest.Created))
+	assert.Equal(t, "linux", manifest.OS)
+	require.Len(t, manifest.Layers, 2)
+	assert.True(t, manifest.Size > 300, "the size includes the configuration and the layers")
+
+	_, err = client.Manifest("team/web", "missing")
+	assert.Equal(t, api.ErrRegistryRepositoryNotFound, err)
+	_, err = client.Tags("../secret")
+	assert.Equal(t, api.ErrRegistryRepositoryNotFound, err)
+}
+
+func TestDeleteTag(t *testing.T) {
+	server := distributiontest.NewServer()
+	defer server.Close()
+
+	server.PushImage("web", "1.0", time.Now(), 10)
+	server.PushImage("web", "2.0", time.Now(), 20)
+	client := newTestClient(t, server, "", "")
+
+	require.NoError(t, client.DeleteTag("web", "1.0"))
+	tags, err := client.Tags("web")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"2.0"}, tags)
+
+	server.DeletionDisabled = true
+	assert.Equal(t, api.ErrRegistryDeletionDisabled, client.DeleteTag("web", "2.0"))
+}
+
+func TestAuthentication(t *testing.T) {
+	server := distributiontest.NewServer()
+	defer server.Close()
+	server.Username, server.Password = "user", "secret"
+	server.PushImage("web", "latest", time.Now(), 10)
+
+	assert.NoError(t, newTestClient(t, server, "user", "secret").Ping())
+	assert.Equal(t, api.ErrRegistryAuthenticationFailed, newTestClient(t, server, "user", "wrong").Ping())
+	assert.Equal(t, api.ErrRegistryAuthenticationFailed, newTestClient(t, server, "", "").Ping())
+
+	server.TokenAuthentication = true
+	repositories, err := newTestClient(t, server, "user", "secret").Catalog()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"web"}, repositories)
+	_, err = newTestClient(t, server, "user", "wrong").Catalog()
+	assert.Equal(t, api.ErrRegistryAuthenticationFailed, err)
+}
+
+func TestNextPage(t *testing.T) {
+	current, err := url.Parse("https://registry.example.com/v2/_catalog?n=100")
+	require.NoError(t, err)
+
+	for link, expected := range map[string]string{
+		`</v2/_catalog?n=100&last=web>; rel="next"`:                                  "https://registry.example.com/v2/_catalog?n=100&last=web",
+		`<https://registry.example.com/v2/_catalog?n=100&last=web>; rel="next"`:      "https://registry.example.com/v2/_catalog?n=100&last=web",
+		`<https://attacker.example.com/v2/_catalog?n=100&last=web>; rel="next"`:      "",
+		`<http://registry.example.com/v2/_catalog?n=100&last=web>; rel="next"`:       "",
+		`<https://registry.example.com:8443/v2/_catalog?n=100&last=web>; rel="next"`: "",
+		`</v2/_catalog?n=100>; rel="next"`:                                           "",
+		``:                                                                           "",
+	} {
+		next := nextPage(current, link)
+		if expected == "" {
+			assert.Nil(t, next, link)
+		} else if assert.NotNil(t, next, link) {
+			assert.Equal(t, expected, next.String(), link)
+		}
+	}
+}
+
+func TestListIgnoresOtherHosts(t *testing.T) {
+	var requests int
+	var authorization string
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		authorization = r.Header.Get("Authorization")
+		w.Write([]byte(`{"repositories":["other"]}`))
+	}))
+	defer other.Close()
+
+	registry := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Link", "<"+other.URL+`/v2/_catalog?last=web>; rel="next"`)
+		w.Write([]byte(`{"repositories":["web"]}`))
+	}))
+	defer registry.Close()
+
+	client, err := NewClient(&api.Registry{URL: registry.URL, Authentication: true, Username: "user", Password: "secret"})
+	require.NoError(t, err)
+
+	repositories, err := client.Catalog()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"web"}, repositories)
+	assert.Zero(t, requests, "the links to another host are not followed")
+
+	otherURL, err := url.Parse(other.URL + "/v2/_catalog")
+	require.NoError(t, err)
+	response, err := client.send(http.MethodGet, otherURL, "", "")
+	require.NoError(t, err)
+	response.Body.Close()
+	assert.Empty(t, authorization, "the credentials of the registry are not sent to another host")
+}
+
+func TestUnreachableRegistry(t *testing.T) {
+	server := distributiontest.NewServer()
+	client := newTestClient(t, server, "", "")
+	server.Close()
+
+	assert.Equal(t, api.ErrRegistryUnreachable, client.Ping())
+}
@@ -0,0 +1,227 @@
+// Package distributiontest provides an in-memory Docker registry implementing the parts of
+// the version 2 of the registry API browsed by cloudware, for use in tests.
+package distributiontest
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	mediaTypeManifest = "application/vnd.docker.distribution.manifest.v2+json"
+	mediaTypeConfig   = "application/vnd.docker.container.image.v1+json"
+	mediaTypeLayer    = "application/vnd.docker.image.rootfs.diff.tar.gzip"
+
+	testToken = "test-token"
+)
+
+// Server is a registry stand-in. It requires the credentials when Username is set, with basic
+// authentication or with a token authentication when TokenAuthentication is set. The images
+// can be deleted unless DeletionDisabled is set.
+type Server struct {
+	*httptest.Server
+	Username            string
+	Password            string
+	TokenAuthentication bool
+	DeletionDisabled    bool
+
+	mu        sync.Mutex
+	blobs     map[string][]byte
+	manifests map[string][]byte
+	tags      map[string]map[string]string
+}
+
+// NewServer starts a registry stand-in without any image.
+func NewServer() *Server {
+	server := &Server{
+		blobs:     make(map[string][]byte),
+		manifests: make(map[string][]byte),
+		tags:      make(map[string]map[string]string),
+	}
+	server.Server = httptest.NewServer(http.HandlerFunc(server.serveHTTP))
+	return server
+}
+
+// Host returns the address of the registry, as it appears in the image references.
+func (server *Server) Host() string {
+	return server.Listener.Addr().String()
+}
+
+// PushImage stores an image made of layers of the specified sizes and tags it, the digest
+// of its manifest is returned.
+func (server *Server) PushImage(repository, tag string, created time.Time, layerSizes ...int64) string {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+
+	config, _ := json.Marshal(map[string]interface{}{"created": created, "architecture": "amd64", "os": "linux"})
+	configDigest := server.storeBlob(config)
+
+	layers := make([]map[string]interface{}, 0, len(layerSizes))
+	for idx, size := range layerSizes {
+		layers = append(layers, map[string]interface{}{
+			"mediaType": mediaTypeLayer,
+			"size":      size,
+			"digest":    digest([]byte(repository + tag + strconv.Itoa(idx))),
+		})
+	}
+
+	manifest, _ := json.Marshal(map[string]interface{}{
+		"schemaVersion": 2,
+		"mediaType":     mediaTypeManifest,
+		"config":        map[string]interface{}{"mediaType": mediaTypeConfig, "size": len(config), "digest": configDigest},
+		"layers":        layers,
+	})
+	manifestDigest := digest(manifest)
+	server.manifests[manifestDigest] = manifest
+
+	if server.tags[repository] == nil {
+		server.tags[repository] = make(map[string]string)
+	}
+	server.tags[repository][tag] = manifestDigest
+	return manifestDigest
+}
+
+func (server *Server) storeBlob(content []byte) string {
+	d := digest(content)
+	server.blobs[d] = content
+	return d
+}
+
+func digest(content []byte) string {
+	sum := sha256.Sum256(content)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+func (server *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/token" {
+		server.serveToken(w, r)
+		return
+	}
+	if !server.authorized(w, r) {
+		return
+	}
+
+	server.mu.Lock()
+	defer server.mu.Unlock()
+
+	path := strings.TrimPrefix(r.URL.Path, "/v2/")
+	switch {
+	case r.URL.Path == "/v2/":
+		w.WriteHeader(http.StatusOK)
+	case path == "_catalog":
+		repositories := make([]string, 0, len(server.tags))
+		for repository, tags := range server.tags {
+			if len(tags) > 0 {
+				repositories = append(repositories, repository)
+			}
+		}
+		server.writePage(w, r, "repositories", repositories)
+	case strings.HasSuffix(path, "/tags/list"):
+		tags := make([]string, 0)
+		for tag := range server.tags[strings.TrimSuffix(path, "/tags/list")] {
+			tags = append(tags, tag)
+		}
+		server.writePage(w, r, "tags", tags)
+	case strings.Contains(path, "/manifests/"):
+		idx := strings.LastIndex(path, "/manifests/")
+		server.serveManifest(w, r, path[:idx], path[idx+len("/manifests/"):])
+	case strings.Contains(path, "/blobs/"):
+		blob, ok := server.blobs[path[strings.LastIndex(path, "/")+1:]]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(blob)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// writePage writes the names sorted, paginated with the n and last parameters and a Link header.
+func (server *Server) writePage(w http.ResponseWriter, r *http.Request, field string, names []string) {
+	sort.Strings(names)
+	if last := r.URL.Query().Get("last"); last != "" {
+		idx := sort.SearchStrings(names, last)
+		if idx < len(names) && names[idx] == last {
+			idx++
+		}
+		names = names[idx:]
+	}
+	if n, err := strconv.Atoi(r.URL.Query().Get("n")); err == nil && n > 0 && n < len(names) {
+		names = names[:n]
+		w.Header().Set("Link", "<"+r.URL.Path+"?n="+strconv.Itoa(n)+"&last="+names[n-1]+`>; rel="next"`)
+	}
+	json.NewEncoder(w).Encode(map[string][]string{field: names})
+}
+
+func (server *Server) serveManifest(w http.ResponseWriter, r *http.Request, repository, reference string) {
+	d := reference
+	if !strings.HasPrefix(reference, "sha256:") {
+		d = server.tags[repository][reference]
+	}
+	manifest, ok := server.manifests[d]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		w.Header().Set("Content-Type", mediaTypeManifest)
+		w.Header().Set("Docker-Content-Digest", d)
+		w.Write(manifest)
+	case http.MethodDelete:
+		if server.DeletionDisabled || d != reference {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		delete(server.manifests, d)
+		for tag, tagged := range server.tags[repository] {
+			if tagged == d {
+				delete(server.tags[repository], tag)
+			}
+		}
+		w.WriteHeader(http.StatusAccepted)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// authorized checks the credentials of a request, and answers with a challenge when they are invalid.
+func (server *Server) authorized(w http.ResponseWriter, r *http.Request) bool {
+	if server.Username == "" {
+		return true
+	}
+
+	if server.TokenAuthentication {
+		if r.Header.Get("Authorization") == "Bearer "+testToken {
+			return true
+		}
+		w.Header().Set("WWW-Authenticate", `Bearer realm="`+server.URL+`/token",service="registry",scope="registry:catalog:*"`)
+	} else {
+		username, password, ok := r.BasicAuth()
+		if ok && username == server.Username && password == server.Password {
+			return true
+		}
+		w.Header().Set("WWW-Authenticate", `Basic realm="registry"`)
+	}
+	w.WriteHeader(http.StatusUnauthorized)
+	return false
+}
+
+func (server *Server) serveToken(w http.ResponseWriter, r *http.Request) {
+	username, password, ok := r.BasicAuth()
+	if !ok || username != server.Username || password != server.Password {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]string{"token": testToken})
+}
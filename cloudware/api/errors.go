@@ -46,8 +46,13 @@ const (
 
 // Registry errors.
 const (
-	ErrRegistryNotFound      = Error("Registry not found")
-	ErrRegistryAlreadyExists = Error("A registry is already defined for this URL")
+	ErrRegistryNotFound             = Error("Registry not found")
+	ErrRegistryAlreadyExists        = Error("A registry is already defined for this URL")
+	ErrRegistryAccessDenied         = Error("Access denied to registry")
+	ErrRegistryUnreachable          = Error("Unable to reach the registry")
+	ErrRegistryAuthenticationFailed = Error("The registry rejected the credentials")
+	ErrRegistryRepositoryNotFound   = Error("Repository or tag not found in the registry")
+	ErrRegistryDeletionDisabled     = Error("The registry does not allow the deletion of images")
 )
 
 // Stack errors
@@ -55,11 +60,27 @@ const (
 	ErrStackNotFound                   = Error("Stack not found")
 	ErrStackAlreadyExists              = Error("A stack already exists with this name")
 	ErrComposeFileNotFoundInRepository = Error("Unable to find a Compose file in the repository")
+	ErrStackCommandCancelled           = Error("The stack operation was cancelled because the server is shutting down")
+	ErrStackSwarmMismatch              = Error("The swarm does not belong to the endpoint")
+)
+
+// Template errors.
+const (
+	ErrTemplateNotFound          = Error("Template not found")
+	ErrInvalidTemplateParameters = Error("Invalid template parameters")
+	ErrInvalidTemplatesDocument  = Error("Invalid templates document")
+	ErrTemplateSourceUnavailable = Error("Unable to retrieve the templates and no cached copy is available")
+)
+
+// Recording errors.
+const (
+	ErrRecordingNotFound = Error("Recording not found")
 )
 
 // Version errors.
 const (
-	ErrDBVersionNotFound = Error("DB version not found")
+	ErrDBVersionNotFound  = Error("DB version not found")
+	ErrDBVersionTooRecent = Error("The database was created by a more recent version of the application")
 )
 
 // Settings errors.
@@ -89,6 +110,41 @@ const (
 	ErrUndefinedTLSFileType = Error("Undefined TLS file type")
 )
 
+// PKI errors.
+const (
+	ErrInvalidCertificate     = Error("Invalid PEM encoded certificate")
+	ErrInvalidCertificateHost = Error("Invalid certificate host")
+	ErrPKIDisabled            = Error("The built-in certificate authority is disabled")
+)
+
+// Encryption errors.
+const (
+	ErrInvalidEncryptionKey  = Error("Invalid encryption key")
+	ErrEncryptionKeyMismatch = Error("Unable to decrypt secret with the configured encryption keys")
+	ErrEncryptionKeyRequired = Error("The database contains encrypted secrets but no encryption key is configured")
+)
+
+// Backup errors.
+const (
+	ErrInvalidBackupArchive     = Error("Invalid backup archive")
+	ErrBackupPasswordRequired   = Error("The backup archive is encrypted, a password is required")
+	ErrInvalidBackupPassword    = Error("Invalid backup password or corrupted archive")
+	ErrBackupDBVersionTooRecent = Error("The backup was created by a more recent version of the application")
+	ErrBackupStorageFailed      = Error("Unable to access the backup storage")
+	ErrInvalidBackupStorage     = Error("Invalid backup storage type")
+)
+
+// Configuration errors.
+const (
+	ErrInvalidConfigurationDocument = Error("Invalid configuration document")
+)
+
+// Export errors.
+const (
+	ErrUnsupportedExportVersion = Error("Unsupported export document version")
+	ErrImportConflicts          = Error("The export document conflicts with the existing data")
+)
+
 // Error represents an application error.
 type Error string
 
@@ -0,0 +1,35 @@
+package exec
+
+import (
+	"bytes"
+	"os/exec"
+	"strings"
+
+	"cloudware/cloudware/api"
+)
+
+// GitService represents a service for cloning Git repositories with the git binary.
+type GitService struct{}
+
+// NewGitService initializes a new GitService.
+func NewGitService() *GitService {
+	return &GitService{}
+}
+
+// CloneRepository clones the default branch of a repository in destination,
+// without the history of the repository.
+func (service *GitService) CloneRepository(url, destination string) error {
+	var stderr bytes.Buffer
+	cmd := exec.Command("git", "clone", "--depth", "1", "--", url, destination)
+	cmd.Stderr = &stderr
+
+	err := cmd.Run()
+	if err != nil {
+		message := strings.TrimSpace(stderr.String())
+		if message == "" {
+			message = err.Error()
+		}
+		return api.Error(message)
+	}
+	return nil
+}
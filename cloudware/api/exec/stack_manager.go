@@ -2,33 +2,47 @@ package exec
 
 import (
 	"bytes"
+	"context"
 	"os"
 	"os/exec"
 	"path"
 	"runtime"
+	"time"
 
 	"cloudware/cloudware/api"
+	"cloudware/cloudware/api/metrics"
 )
 
 // StackManager represents a service for managing stacks.
 type StackManager struct {
 	binaryPath string
+	ctx        context.Context
+	cancel     context.CancelFunc
 }
 
 // NewStackManager initializes a new StackManager service.
 func NewStackManager(binaryPath string) *StackManager {
+	ctx, cancel := context.WithCancel(context.Background())
 	return &StackManager{
 		binaryPath: binaryPath,
+		ctx:        ctx,
+		cancel:     cancel,
 	}
 }
 
+// Cancel kills the running docker commands, the commands executed afterwards fail immediately.
+// It is used to abort the deployments still running when the server shuts down.
+func (manager *StackManager) Cancel() {
+	manager.cancel()
+}
+
 // Login executes the docker login command against a list of registries (including DockerHub).
 func (manager *StackManager) Login(dockerhub *api.DockerHub, registries []api.Registry, endpoint *api.Endpoint) error {
 	command, args := prepareDockerCommandAndArgs(manager.binaryPath, endpoint)
 	for _, registry := range registries {
 		if registry.Authentication {
 			registryArgs := append(args, "login", "--username", registry.Username, "--password", registry.Password, registry.URL)
-			err := runCommandAndCaptureStdErr(command, registryArgs, nil)
+			err := manager.runCommandAndCaptureStdErr(command, registryArgs, nil)
 			if err != nil {
 				return err
 			}
@@ -37,7 +51,7 @@ func (manager *StackManager) Login(dockerhub *api.DockerHub, registries []api.Re
 
 	if dockerhub.Authentication {
 		dockerhubArgs := append(args, "login", "--username", dockerhub.Username, "--password", dockerhub.Password)
-		err := runCommandAndCaptureStdErr(command, dockerhubArgs, nil)
+		err := manager.runCommandAndCaptureStdErr(command, dockerhubArgs, nil)
 		if err != nil {
 			return err
 		}
@@ -50,7 +64,7 @@ func (manager *StackManager) Login(dockerhub *api.DockerHub, registries []api.Re
 func (manager *StackManager) Logout(endpoint *api.Endpoint) error {
 	command, args := prepareDockerCommandAndArgs(manager.binaryPath, endpoint)
 	args = append(args, "logout")
-	return runCommandAndCaptureStdErr(command, args, nil)
+	return manager.runCommandAndCaptureStdErr(command, args, nil)
 }
 
 // Deploy executes the docker stack deploy command.
@@ -64,19 +78,22 @@ func (manager *StackManager) Deploy(stack *api.Stack, endpoint *api.Endpoint) er
 		env = append(env, envvar.Name+"="+envvar.Value)
 	}
 
-	return runCommandAndCaptureStdErr(command, args, env)
+	start := time.Now()
+	err := manager.runCommandAndCaptureStdErr(command, args, env)
+	metrics.ObserveStackDeploy(time.Since(start), err)
+	return err
 }
 
 // Remove executes the docker stack rm command.
 func (manager *StackManager) Remove(stack *api.Stack, endpoint *api.Endpoint) error {
 	command, args := prepareDockerCommandAndArgs(manager.binaryPath, endpoint)
 	args = append(args, "stack", "rm", stack.Name)
-	return runCommandAndCaptureStdErr(command, args, nil)
+	return manager.runCommandAndCaptureStdErr(command, args, nil)
 }
 
-func runCommandAndCaptureStdErr(command string, args []string, env []string) error {
+func (manager *StackManager) runCommandAndCaptureStdErr(command string, args []string, env []string) error {
 	var stderr bytes.Buffer
-	cmd := exec.Command(command, args...)
+	cmd := exec.CommandContext(manager.ctx, command, args...)
 	cmd.Stderr = &stderr
 
 	if env != nil {
@@ -85,7 +102,9 @@ func runCommandAndCaptureStdErr(command string, args []string, env []string) err
 	}
 
 	err := cmd.Run()
-	if err != nil {
+	if manager.ctx.Err() != nil {
+		return api.ErrStackCommandCancelled
+	} else if err != nil {
 		return api.Error(stderr.String())
 	}
 
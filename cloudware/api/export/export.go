@@ -0,0 +1,170 @@
+package export
+
+import (
+	"path"
+	"sync"
+	"time"
+
+	"cloudware/cloudware/api"
+)
+
+const (
+	// FormatVersion is the version of the export document format.
+	FormatVersion = 1
+)
+
+type (
+	// Document represents all the entities of an instance. The entities keep the identifiers of the
+	// exporting instance, they are remapped when the document is imported.
+	Document struct {
+		Version          int                   `json:"Version"`
+		APIVersion       string                `json:"APIVersion"`
+		CreatedAt        int64                 `json:"CreatedAt"`
+		SecretsOmitted   bool                  `json:"SecretsOmitted"`
+		Users            []api.User            `json:"Users"`
+		Teams            []api.Team            `json:"Teams"`
+		TeamMemberships  []api.TeamMembership  `json:"TeamMemberships"`
+		Endpoints        []Endpoint            `json:"Endpoints"`
+		Registries       []api.Registry        `json:"Registries"`
+		ResourceControls []api.ResourceControl `json:"ResourceControls"`
+		Stacks           []Stack               `json:"Stacks"`
+	}
+
+	// Endpoint represents an endpoint along with the content of its TLS files.
+	Endpoint struct {
+		api.Endpoint
+		TLSCACertContent string `json:"TLSCACertContent,omitempty"`
+		TLSCertContent   string `json:"TLSCertContent,omitempty"`
+		TLSKeyContent    string `json:"TLSKeyContent,omitempty"`
+	}
+
+	// Stack represents a stack along with the content of its stack file.
+	Stack struct {
+		api.Stack
+		StackFileContent string `json:"StackFileContent"`
+	}
+
+	// Service exports the entities of the instance and imports the entities of another one.
+	Service struct {
+		mu                     sync.Mutex
+		UserService            api.UserService
+		TeamService            api.TeamService
+		TeamMembershipService  api.TeamMembershipService
+		EndpointService        api.EndpointService
+		RegistryService        api.RegistryService
+		ResourceControlService api.ResourceControlService
+		StackService           api.StackService
+		FileService            api.FileService
+	}
+)
+
+// Export returns a document containing all the entities. When omitSecrets is set, the password hashes
+// of the users, the passwords of the registries, the TLS keys of the endpoints and the values of the
+// environment variables of the stacks are left out.
+func (service *Service) Export(omitSecrets bool) (*Document, error) {
+	document := &Document{
+		Version:        FormatVersion,
+		APIVersion:     api.APIVersion,
+		CreatedAt:      time.Now().Unix(),
+		SecretsOmitted: omitSecrets,
+	}
+
+	var err error
+	document.Users, err = service.UserService.Users()
+	if err != nil {
+		return nil, err
+	}
+	if omitSecrets {
+		for idx := range document.Users {
+			document.Users[idx].Password = ""
+		}
+	}
+
+	document.Teams, err = service.TeamService.Teams()
+	if err != nil {
+		return nil, err
+	}
+
+	document.TeamMemberships, err = service.TeamMembershipService.TeamMemberships()
+	if err != nil {
+		return nil, err
+	}
+
+	endpoints, err := service.EndpointService.Endpoints()
+	if err != nil {
+		return nil, err
+	}
+	document.Endpoints = make([]Endpoint, 0, len(endpoints))
+	for _, endpoint := range endpoints {
+		exported, err := service.exportEndpoint(endpoint, omitSecrets)
+		if err != nil {
+			return nil, err
+		}
+		document.Endpoints = append(document.Endpoints, exported)
+	}
+
+	document.Registries, err = service.RegistryService.Registries()
+	if err != nil {
+		return nil, err
+	}
+	if omitSecrets {
+		for idx := range document.Registries {
+			document.Registries[idx].Password = ""
+		}
+	}
+
+	document.ResourceControls, err = service.ResourceControlService.ResourceControls()
+	if err != nil {
+		return nil, err
+	}
+
+	stacks, err := service.StackService.Stacks()
+	if err != nil {
+		return nil, err
+	}
+	document.Stacks = make([]Stack, 0, len(stacks))
+	for _, stack := range stacks {
+		content, err := service.FileService.GetFileContent(path.Join(stack.ProjectPath, stack.EntryPoint))
+		if err != nil {
+			return nil, err
+		}
+		if omitSecrets {
+			env := make([]api.Pair, 0, len(stack.Env))
+			for _, pair := range stack.Env {
+				env = append(env, api.Pair{Name: pair.Name})
+			}
+			stack.Env = env
+		}
+		document.Stacks = append(document.Stacks, Stack{Stack: stack, StackFileContent: content})
+	}
+
+	return document, nil
+}
+
+func (service *Service) exportEndpoint(endpoint api.Endpoint, omitSecrets bool) (Endpoint, error) {
+	exported := Endpoint{Endpoint: endpoint}
+	if !endpoint.TLSConfig.TLS {
+		return exported, nil
+	}
+
+	var err error
+	if endpoint.TLSConfig.TLSCACertPath != "" {
+		exported.TLSCACertContent, err = service.FileService.GetFileContent(endpoint.TLSConfig.TLSCACertPath)
+		if err != nil {
+			return exported, err
+		}
+	}
+	if endpoint.TLSConfig.TLSCertPath != "" {
+		exported.TLSCertContent, err = service.FileService.GetFileContent(endpoint.TLSConfig.TLSCertPath)
+		if err != nil {
+			return exported, err
+		}
+	}
+	if endpoint.TLSConfig.TLSKeyPath != "" && !omitSecrets {
+		exported.TLSKeyContent, err = service.FileService.GetFileContent(endpoint.TLSConfig.TLSKeyPath)
+		if err != nil {
+			return exported, err
+		}
+	}
+	return exported, nil
+}
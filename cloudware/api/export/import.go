@@ -0,0 +1,461 @@
+package export
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"cloudware/cloudware/api"
+	"cloudware/cloudware/api/file"
+)
+
+type (
+	// Conflict represents an entity of the document which could not be imported as is.
+	Conflict struct {
+		Kind   string `json:"Kind"`
+		Name   string `json:"Name"`
+		Reason string `json:"Reason"`
+	}
+
+	// ImportReport describes the outcome of an import, the number of created entities is given by kind.
+	ImportReport struct {
+		DryRun    bool           `json:"DryRun"`
+		Created   map[string]int `json:"Created"`
+		Conflicts []Conflict     `json:"Conflicts"`
+	}
+
+	// importer maps the identifiers of the document to the identifiers of the imported entities.
+	// In a dry run, nothing is written and the entities to create are mapped to a zero identifier.
+	importer struct {
+		service   *Service
+		document  *Document
+		dryRun    bool
+		report    *ImportReport
+		userIDs   map[api.UserID]api.UserID
+		teamIDs   map[api.TeamID]api.TeamID
+		usernames map[string]api.UserID
+		teamNames map[string]api.TeamID
+	}
+)
+
+// Import creates the entities of a document which do not exist yet. The entities sharing the name of
+// an existing user, team, endpoint or registry, the resource of an existing resource control or the
+// identifier of an existing stack are reported as conflicts and left untouched, the references to a
+// conflicting user or team are mapped to the existing one.
+// The document is always checked in a dry run first: nothing is written when dryRun is set, or when
+// failOnConflict is set and a conflict is found.
+func (service *Service) Import(document *Document, dryRun, failOnConflict bool) (*ImportReport, error) {
+	if document.Version < 1 || document.Version > FormatVersion {
+		return nil, api.ErrUnsupportedExportVersion
+	}
+
+	service.mu.Lock()
+	defer service.mu.Unlock()
+
+	report, err := service.importDocument(document, true)
+	if err != nil || dryRun {
+		return report, err
+	}
+	if failOnConflict && len(report.Conflicts) > 0 {
+		return report, api.ErrImportConflicts
+	}
+
+	return service.importDocument(document, false)
+}
+
+func (service *Service) importDocument(document *Document, dryRun bool) (*ImportReport, error) {
+	imp := &importer{
+		service:  service,
+		document: document,
+		dryRun:   dryRun,
+		report: &ImportReport{
+			DryRun:    dryRun,
+			Created:   make(map[string]int),
+			Conflicts: make([]Conflict, 0),
+		},
+		userIDs:   make(map[api.UserID]api.UserID),
+		teamIDs:   make(map[api.TeamID]api.TeamID),
+		usernames: make(map[string]api.UserID),
+		teamNames: make(map[string]api.TeamID),
+	}
+
+	steps := []func() error{
+		imp.importUsers,
+		imp.importTeams,
+		imp.importTeamMemberships,
+		imp.importEndpoints,
+		imp.importRegistries,
+		imp.importResourceControls,
+		imp.importStacks,
+	}
+	for _, step := range steps {
+		err := step()
+		if err != nil {
+			return imp.report, err
+		}
+	}
+	return imp.report, nil
+}
+
+func (imp *importer) conflict(kind, name, format string, args ...interface{}) {
+	imp.report.Conflicts = append(imp.report.Conflicts, Conflict{Kind: kind, Name: name, Reason: fmt.Sprintf(format, args...)})
+}
+
+func (imp *importer) importUsers() error {
+	for _, user := range imp.document.Users {
+		if userID, ok := imp.usernames[user.Username]; ok {
+			imp.conflict("user", user.Username, "The document contains several users with this name")
+			imp.userIDs[user.ID] = userID
+			continue
+		}
+
+		existing, err := imp.service.UserService.UserByUsername(user.Username)
+		if err == nil {
+			imp.conflict("user", user.Username, "A user with this name already exists, it is used instead")
+			imp.userIDs[user.ID] = existing.ID
+			imp.usernames[user.Username] = existing.ID
+			continue
+		} else if err != api.ErrUserNotFound {
+			return err
+		}
+
+		if user.Password == "" && imp.document.SecretsOmitted {
+			imp.conflict("user", user.Username, "The password hash was omitted from the document")
+		}
+
+		created := user
+		created.ID = 0
+		if !imp.dryRun {
+			err = imp.service.UserService.CreateUser(&created)
+			if err != nil {
+				return err
+			}
+		}
+		imp.userIDs[user.ID] = created.ID
+		imp.usernames[user.Username] = created.ID
+		imp.report.Created["user"]++
+	}
+	return nil
+}
+
+func (imp *importer) importTeams() error {
+	for _, team := range imp.document.Teams {
+		if teamID, ok := imp.teamNames[team.Name]; ok {
+			imp.conflict("team", team.Name, "The document contains several teams with this name")
+			imp.teamIDs[team.ID] = teamID
+			continue
+		}
+
+		existing, err := imp.service.TeamService.TeamByName(team.Name)
+		if err == nil {
+			imp.conflict("team", team.Name, "A team with this name already exists, it is used instead")
+			imp.teamIDs[team.ID] = existing.ID
+			imp.teamNames[team.Name] = existing.ID
+			continue
+		} else if err != api.ErrTeamNotFound {
+			return err
+		}
+
+		created := team
+		created.ID = 0
+		if !imp.dryRun {
+			err = imp.service.TeamService.CreateTeam(&created)
+			if err != nil {
+				return err
+			}
+		}
+		imp.teamIDs[team.ID] = created.ID
+		imp.teamNames[team.Name] = created.ID
+		imp.report.Created["team"]++
+	}
+	return nil
+}
+
+func (imp *importer) importTeamMemberships() error {
+	for _, membership := range imp.document.TeamMemberships {
+		name := strconv.Itoa(int(membership.ID))
+
+		userID, ok := imp.userIDs[membership.UserID]
+		if !ok {
+			imp.conflict("team membership", name, "The membership refers to the unknown user %d", membership.UserID)
+			continue
+		}
+		teamID, ok := imp.teamIDs[membership.TeamID]
+		if !ok {
+			imp.conflict("team membership", name, "The membership refers to the unknown team %d", membership.TeamID)
+			continue
+		}
+
+		// Memberships can only exist already when both the user and the team existed.
+		if userID != 0 && teamID != 0 {
+			memberships, err := imp.service.TeamMembershipService.TeamMembershipsByUserID(userID)
+			if err != nil {
+				return err
+			}
+			exists := false
+			for _, existing := range memberships {
+				if existing.TeamID == teamID {
+					exists = true
+				}
+			}
+			if exists {
+				imp.conflict("team membership", name, "The user is already a member of the team")
+				continue
+			}
+		}
+
+		created := membership
+		created.ID = 0
+		created.UserID = userID
+		created.TeamID = teamID
+		if !imp.dryRun {
+			err := imp.service.TeamMembershipService.CreateTeamMembership(&created)
+			if err != nil {
+				return err
+			}
+		}
+		imp.report.Created["team membership"]++
+	}
+	return nil
+}
+
+// mapUserIDs remaps an access list, the unknown users are removed and reported.
+func (imp *importer) mapUserIDs(kind, name string, userIDs []api.UserID) []api.UserID {
+	mapped := make([]api.UserID, 0, len(userIDs))
+	for _, userID := range userIDs {
+		target, ok := imp.userIDs[userID]
+		if !ok {
+			imp.conflict(kind, name, "The access of the unknown user %d was removed", userID)
+			continue
+		}
+		mapped = append(mapped, target)
+	}
+	return mapped
+}
+
+// mapTeamIDs remaps an access list, the unknown teams are removed and reported.
+func (imp *importer) mapTeamIDs(kind, name string, teamIDs []api.TeamID) []api.TeamID {
+	mapped := make([]api.TeamID, 0, len(teamIDs))
+	for _, teamID := range teamIDs {
+		target, ok := imp.teamIDs[teamID]
+		if !ok {
+			imp.conflict(kind, name, "The access of the unknown team %d was removed", teamID)
+			continue
+		}
+		mapped = append(mapped, target)
+	}
+	return mapped
+}
+
+func (imp *importer) importEndpoints() error {
+	endpoints, err := imp.service.EndpointService.Endpoints()
+	if err != nil {
+		return err
+	}
+	names := make(map[string]bool)
+	for _, endpoint := range endpoints {
+		names[endpoint.Name] = true
+	}
+
+	for _, exported := range imp.document.Endpoints {
+		if names[exported.Name] {
+			imp.conflict("endpoint", exported.Name, "An endpoint with this name already exists")
+			continue
+		}
+		names[exported.Name] = true
+
+		endpoint := exported.Endpoint
+		endpoint.ID = 0
+		endpoint.AuthorizedUsers = imp.mapUserIDs("endpoint", endpoint.Name, endpoint.AuthorizedUsers)
+		endpoint.AuthorizedTeams = imp.mapTeamIDs("endpoint", endpoint.Name, endpoint.AuthorizedTeams)
+		if endpoint.TLSConfig.TLS && missingTLSFiles(&exported) {
+			imp.conflict("endpoint", endpoint.Name, "The TLS files were omitted from the document, TLS is disabled until they are uploaded again")
+		}
+
+		if !imp.dryRun {
+			err = imp.createEndpoint(&endpoint, &exported)
+			if err != nil {
+				return err
+			}
+		}
+		imp.report.Created["endpoint"]++
+	}
+	return nil
+}
+
+// missingTLSFiles reports whether the content of a TLS file of an endpoint is missing from the document.
+func missingTLSFiles(exported *Endpoint) bool {
+	tlsConfig := exported.TLSConfig
+	return (tlsConfig.TLSCACertPath != "" && exported.TLSCACertContent == "") ||
+		(tlsConfig.TLSCertPath != "" && exported.TLSCertContent == "") ||
+		(tlsConfig.TLSKeyPath != "" && exported.TLSKeyContent == "")
+}
+
+// createEndpoint creates an endpoint and stores its TLS files in the folder of the endpoint.
+// The paths of the source instance are never kept, they refer to the folder of another endpoint:
+// the paths of the files missing from the document are cleared and TLS is disabled, as the
+// remaining files cannot be used without them.
+func (imp *importer) createEndpoint(endpoint *api.Endpoint, exported *Endpoint) error {
+	if endpoint.TLSConfig.TLS && missingTLSFiles(exported) {
+		endpoint.TLSConfig.TLS = false
+	}
+	endpoint.TLSConfig.TLSCACertPath = ""
+	endpoint.TLSConfig.TLSCertPath = ""
+	endpoint.TLSConfig.TLSKeyPath = ""
+
+	err := imp.service.EndpointService.CreateEndpoint(endpoint)
+	if err != nil {
+		return err
+	}
+
+	files := []struct {
+		fileType api.TLSFileType
+		content  string
+		path     *string
+	}{
+		{api.TLSFileCA, exported.TLSCACertContent, &endpoint.TLSConfig.TLSCACertPath},
+		{api.TLSFileCert, exported.TLSCertContent, &endpoint.TLSConfig.TLSCertPath},
+		{api.TLSFileKey, exported.TLSKeyContent, &endpoint.TLSConfig.TLSKeyPath},
+	}
+
+	folder := strconv.Itoa(int(endpoint.ID))
+	stored := false
+	for _, f := range files {
+		if f.content == "" {
+			continue
+		}
+
+		err = imp.service.FileService.StoreTLSFile(folder, f.fileType, strings.NewReader(f.content))
+		if err != nil {
+			return err
+		}
+		*f.path, err = imp.service.FileService.GetPathForTLSFile(folder, f.fileType)
+		if err != nil {
+			return err
+		}
+		stored = true
+	}
+
+	if !stored {
+		return nil
+	}
+	return imp.service.EndpointService.UpdateEndpoint(endpoint.ID, endpoint)
+}
+
+func (imp *importer) importRegistries() error {
+	registries, err := imp.service.RegistryService.Registries()
+	if err != nil {
+		return err
+	}
+	names := make(map[string]bool)
+	for _, registry := range registries {
+		names[registry.Name] = true
+	}
+
+	for _, registry := range imp.document.Registries {
+		if names[registry.Name] {
+			imp.conflict("registry", registry.Name, "A registry with this name already exists")
+			continue
+		}
+		names[registry.Name] = true
+
+		created := registry
+		created.ID = 0
+		created.AuthorizedUsers = imp.mapUserIDs("registry", registry.Name, registry.AuthorizedUsers)
+		created.AuthorizedTeams = imp.mapTeamIDs("registry", registry.Name, registry.AuthorizedTeams)
+		if registry.Authentication && registry.Password == "" && imp.document.SecretsOmitted {
+			imp.conflict("registry", registry.Name, "The password was omitted from the document")
+		}
+
+		if !imp.dryRun {
+			err = imp.service.RegistryService.CreateRegistry(&created)
+			if err != nil {
+				return err
+			}
+		}
+		imp.report.Created["registry"]++
+	}
+	return nil
+}
+
+func (imp *importer) importResourceControls() error {
+	resourceIDs := make(map[string]bool)
+
+	for _, resourceControl := range imp.document.ResourceControls {
+		name := resourceControl.ResourceID
+
+		_, err := imp.service.ResourceControlService.ResourceControlByResourceID(name)
+		if err == nil || resourceIDs[name] {
+			imp.conflict("resource control", name, "A resource control already exists for this resource")
+			continue
+		} else if err != api.ErrResourceControlNotFound {
+			return err
+		}
+		resourceIDs[name] = true
+
+		created := resourceControl
+		created.ID = 0
+		created.UserAccesses = make([]api.UserResourceAccess, 0, len(resourceControl.UserAccesses))
+		for _, access := range resourceControl.UserAccesses {
+			userID, ok := imp.userIDs[access.UserID]
+			if !ok {
+				imp.conflict("resource control", name, "The access of the unknown user %d was removed", access.UserID)
+				continue
+			}
+			created.UserAccesses = append(created.UserAccesses, api.UserResourceAccess{UserID: userID, AccessLevel: access.AccessLevel})
+		}
+		created.TeamAccesses = make([]api.TeamResourceAccess, 0, len(resourceControl.TeamAccesses))
+		for _, access := range resourceControl.TeamAccesses {
+			teamID, ok := imp.teamIDs[access.TeamID]
+			if !ok {
+				imp.conflict("resource control", name, "The access of the unknown team %d was removed", access.TeamID)
+				continue
+			}
+			created.TeamAccesses = append(created.TeamAccesses, api.TeamResourceAccess{TeamID: teamID, AccessLevel: access.AccessLevel})
+		}
+
+		if !imp.dryRun {
+			err = imp.service.ResourceControlService.CreateResourceControl(&created)
+			if err != nil {
+				return err
+			}
+		}
+		imp.report.Created["resource control"]++
+	}
+	return nil
+}
+
+func (imp *importer) importStacks() error {
+	stackIDs := make(map[api.StackID]bool)
+
+	for _, exported := range imp.document.Stacks {
+		_, err := imp.service.StackService.Stack(exported.ID)
+		if err == nil || stackIDs[exported.ID] {
+			imp.conflict("stack", exported.Name, "A stack with this identifier already exists")
+			continue
+		} else if err != api.ErrStackNotFound {
+			return err
+		}
+		stackIDs[exported.ID] = true
+
+		if len(exported.Env) > 0 && imp.document.SecretsOmitted {
+			imp.conflict("stack", exported.Name, "The values of the environment variables were omitted from the document")
+		}
+
+		stack := exported.Stack
+		if !imp.dryRun {
+			stack.ProjectPath, err = imp.service.FileService.StoreStackFileFromString(string(stack.ID), exported.StackFileContent)
+			if err != nil {
+				return err
+			}
+			stack.EntryPoint = file.ComposeFileDefaultName
+
+			err = imp.service.StackService.CreateStack(&stack)
+			if err != nil {
+				return err
+			}
+		}
+		imp.report.Created["stack"]++
+	}
+	return nil
+}
@@ -0,0 +1,400 @@
+package export
+
+import (
+	"path"
+	"strconv"
+	"strings"
+	"testing"
+
+	"cloudware/cloudware/api"
+	"cloudware/cloudware/api/file"
+	"cloudware/cloudware/bolt"
+)
+
+// newExportService returns a service backed by a store and a file store in a temporary directory.
+func newExportService(t *testing.T) (*Service, *bolt.Store, func()) {
+	store, cleanup := bolt.NewTestStore(t)
+
+	fileService, err := file.NewService(store.Path, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	service := &Service{
+		UserService:            store.UserService,
+		TeamService:            store.TeamService,
+		TeamMembershipService:  store.TeamMembershipService,
+		EndpointService:        store.EndpointService,
+		RegistryService:        store.RegistryService,
+		ResourceControlService: store.ResourceControlService,
+		StackService:           store.StackService,
+		FileService:            fileService,
+	}
+
+	return service, store, cleanup
+}
+
+func createUsers(t *testing.T, store *bolt.Store, usernames ...string) []*api.User {
+	users := make([]*api.User, 0, len(usernames))
+	for _, username := range usernames {
+		user := &api.User{Username: username, Role: api.StandardUserRole, Password: "hash-" + username}
+		err := store.UserService.CreateUser(user)
+		if err != nil {
+			t.Fatal(err)
+		}
+		users = append(users, user)
+	}
+	return users
+}
+
+// newSourceDocument exports an instance containing an entity of each kind, the access lists
+// referencing the user alice and the team dev.
+func newSourceDocument(t *testing.T, omitSecrets bool) *Document {
+	service, store, cleanup := newExportService(t)
+	defer cleanup()
+
+	users := createUsers(t, store, "admin", "alice")
+	alice := users[1]
+
+	team := &api.Team{Name: "dev"}
+	err := store.TeamService.CreateTeam(team)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = store.TeamMembershipService.CreateTeamMembership(&api.TeamMembership{UserID: alice.ID, TeamID: team.ID, Role: api.TeamLeader})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = store.EndpointService.CreateEndpoint(&api.Endpoint{Name: "local", URL: "unix:///var/run/docker.sock",
+		AuthorizedUsers: []api.UserID{alice.ID}, AuthorizedTeams: []api.TeamID{team.ID}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = store.RegistryService.CreateRegistry(&api.Registry{Name: "hub", URL: "registry.example.com", Authentication: true,
+		Username: "deploy", Password: "secret", AuthorizedUsers: []api.UserID{alice.ID}, AuthorizedTeams: []api.TeamID{team.ID}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = store.ResourceControlService.CreateResourceControl(&api.ResourceControl{ResourceID: "web", Type: api.ServiceResourceControl,
+		UserAccesses: []api.UserResourceAccess{{UserID: alice.ID, AccessLevel: api.ReadWriteAccessLevel}},
+		TeamAccesses: []api.TeamResourceAccess{{TeamID: team.ID, AccessLevel: api.ReadWriteAccessLevel}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	projectPath, err := service.FileService.StoreStackFileFromString("web_swarm", "version: '3'")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = store.StackService.CreateStack(&api.Stack{ID: "web_swarm", Name: "web", SwarmID: "swarm", EntryPoint: file.ComposeFileDefaultName,
+		ProjectPath: projectPath, Env: []api.Pair{{Name: "TOKEN", Value: "stack-secret"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	document, err := service.Export(omitSecrets)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return document
+}
+
+func TestExportOmitSecrets(t *testing.T) {
+	document := newSourceDocument(t, true)
+
+	if !document.SecretsOmitted {
+		t.Error("expected the document to be flagged")
+	}
+	for _, user := range document.Users {
+		if user.Password != "" {
+			t.Errorf("expected the password hash of %s to be omitted", user.Username)
+		}
+	}
+	if document.Registries[0].Password != "" {
+		t.Error("expected the registry password to be omitted")
+	}
+	if env := document.Stacks[0].Env; len(env) != 1 || env[0].Name != "TOKEN" || env[0].Value != "" {
+		t.Errorf("expected only the names of the environment variables to be exported, got %+v", env)
+	}
+
+	document = newSourceDocument(t, false)
+	if env := document.Stacks[0].Env; len(env) != 1 || env[0].Value != "stack-secret" {
+		t.Errorf("expected the environment variables to be exported, got %+v", env)
+	}
+}
+
+func TestImportRemapsIdentifiers(t *testing.T) {
+	document := newSourceDocument(t, false)
+
+	service, store, cleanup := newExportService(t)
+	defer cleanup()
+
+	// The existing users shift the identifiers of the imported ones, admin is reused.
+	existing := createUsers(t, store, "carol", "dave", "admin")
+	admin := existing[2]
+
+	report, err := service.Import(document, false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]int{"user": 1, "team": 1, "team membership": 1, "endpoint": 1, "registry": 1, "resource control": 1, "stack": 1}
+	for kind, count := range expected {
+		if report.Created[kind] != count {
+			t.Errorf("expected %d %s to be created, got %d", count, kind, report.Created[kind])
+		}
+	}
+	if len(report.Conflicts) != 1 || report.Conflicts[0].Kind != "user" || report.Conflicts[0].Name != "admin" {
+		t.Errorf("expected the existing user to be reported, got %+v", report.Conflicts)
+	}
+	if stored, _ := store.UserService.User(admin.ID); stored.Password != "hash-admin" {
+		t.Error("expected the existing user to be left untouched")
+	}
+
+	alice, err := store.UserService.UserByUsername("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	team, err := store.TeamService.TeamByName("dev")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, user := range document.Users {
+		if user.Username == "alice" && user.ID == alice.ID {
+			t.Fatal("expected the imported user to be given a new identifier")
+		}
+	}
+
+	memberships, _ := store.TeamMembershipService.TeamMembershipsByUserID(alice.ID)
+	if len(memberships) != 1 || memberships[0].TeamID != team.ID || memberships[0].Role != api.TeamLeader {
+		t.Errorf("expected the membership to be remapped, got %+v", memberships)
+	}
+
+	endpoints, _ := store.EndpointService.Endpoints()
+	if len(endpoints) != 1 || len(endpoints[0].AuthorizedUsers) != 1 || endpoints[0].AuthorizedUsers[0] != alice.ID ||
+		len(endpoints[0].AuthorizedTeams) != 1 || endpoints[0].AuthorizedTeams[0] != team.ID {
+		t.Errorf("expected the endpoint access lists to be remapped, got %+v", endpoints)
+	}
+
+	registries, _ := store.RegistryService.Registries()
+	if len(registries) != 1 || registries[0].Password != "secret" || len(registries[0].AuthorizedUsers) != 1 || registries[0].AuthorizedUsers[0] != alice.ID ||
+		len(registries[0].AuthorizedTeams) != 1 || registries[0].AuthorizedTeams[0] != team.ID {
+		t.Errorf("expected the registry access lists to be remapped, got %+v", registries)
+	}
+
+	resourceControl, err := store.ResourceControlService.ResourceControlByResourceID("web")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resourceControl.UserAccesses) != 1 || resourceControl.UserAccesses[0].UserID != alice.ID ||
+		len(resourceControl.TeamAccesses) != 1 || resourceControl.TeamAccesses[0].TeamID != team.ID {
+		t.Errorf("expected the resource control accesses to be remapped, got %+v", resourceControl)
+	}
+
+	stack, err := store.StackService.Stack("web_swarm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, err := service.FileService.GetFileContent(path.Join(stack.ProjectPath, stack.EntryPoint))
+	if err != nil || content != "version: '3'" || len(stack.Env) != 1 || stack.Env[0].Value != "stack-secret" {
+		t.Errorf("expected the stack and its file to be imported, got %+v and %q", stack, content)
+	}
+
+	// Importing the document again only reports conflicts
+	report, err = service.Import(document, false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Created) != 0 {
+		t.Errorf("expected nothing to be created, got %v", report.Created)
+	}
+}
+
+func TestImportUnknownReferences(t *testing.T) {
+	document := newSourceDocument(t, false)
+	document.Teams = nil
+	document.Users = document.Users[:1]
+
+	service, store, cleanup := newExportService(t)
+	defer cleanup()
+
+	report, err := service.Import(document, false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conflicts := make(map[string]int)
+	for _, conflict := range report.Conflicts {
+		conflicts[conflict.Kind]++
+	}
+	expected := map[string]int{"team membership": 1, "endpoint": 2, "registry": 2, "resource control": 2}
+	for kind, count := range expected {
+		if conflicts[kind] != count {
+			t.Errorf("expected %d %s conflicts, got %+v", count, kind, report.Conflicts)
+		}
+	}
+
+	endpoints, _ := store.EndpointService.Endpoints()
+	if len(endpoints) != 1 || len(endpoints[0].AuthorizedUsers) != 0 || len(endpoints[0].AuthorizedTeams) != 0 {
+		t.Errorf("expected the unknown accesses to be removed, got %+v", endpoints)
+	}
+}
+
+func TestImportDryRunAndConflicts(t *testing.T) {
+	document := newSourceDocument(t, true)
+
+	service, store, cleanup := newExportService(t)
+	defer cleanup()
+
+	report, err := service.Import(document, true, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.DryRun || report.Created["user"] != 2 || report.Created["team membership"] != 1 {
+		t.Errorf("expected the dry run to report the entities to create, got %+v", report)
+	}
+
+	conflicts := make(map[string]int)
+	for _, conflict := range report.Conflicts {
+		conflicts[conflict.Kind]++
+	}
+	if conflicts["user"] != 2 || conflicts["registry"] != 1 || conflicts["stack"] != 1 {
+		t.Errorf("expected the omitted secrets to be reported, got %+v", report.Conflicts)
+	}
+
+	_, err = service.Import(document, false, true)
+	if err != api.ErrImportConflicts {
+		t.Errorf("expected %q, got %v", api.ErrImportConflicts, err)
+	}
+
+	users, _ := store.UserService.Users()
+	stacks, _ := store.StackService.Stacks()
+	if len(users) != 0 || len(stacks) != 0 {
+		t.Errorf("expected nothing to be imported, got %d users and %d stacks", len(users), len(stacks))
+	}
+
+	document.Version = FormatVersion + 1
+	_, err = service.Import(document, false, false)
+	if err != api.ErrUnsupportedExportVersion {
+		t.Errorf("expected %q, got %v", api.ErrUnsupportedExportVersion, err)
+	}
+}
+
+// newTLSSourceDocument exports an instance containing an endpoint secured with TLS, stored after another
+// endpoint so that its TLS files are in the folder 2.
+func newTLSSourceDocument(t *testing.T, omitSecrets bool) *Document {
+	service, store, cleanup := newExportService(t)
+	defer cleanup()
+
+	err := store.EndpointService.CreateEndpoint(&api.Endpoint{Name: "local", URL: "unix:///var/run/docker.sock"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	endpoint := &api.Endpoint{Name: "remote", URL: "tcp://10.0.0.1:2376"}
+	err = store.EndpointService.CreateEndpoint(endpoint)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	endpoint.TLSConfig.TLS = true
+	for _, f := range []struct {
+		fileType api.TLSFileType
+		content  string
+		path     *string
+	}{
+		{api.TLSFileCA, "ca", &endpoint.TLSConfig.TLSCACertPath},
+		{api.TLSFileCert, "cert", &endpoint.TLSConfig.TLSCertPath},
+		{api.TLSFileKey, "key", &endpoint.TLSConfig.TLSKeyPath},
+	} {
+		err = service.FileService.StoreTLSFile("2", f.fileType, strings.NewReader(f.content))
+		if err != nil {
+			t.Fatal(err)
+		}
+		*f.path, err = service.FileService.GetPathForTLSFile("2", f.fileType)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = store.EndpointService.UpdateEndpoint(endpoint.ID, endpoint)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	document, err := service.Export(omitSecrets)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return document
+}
+
+func TestImportTLSFiles(t *testing.T) {
+	for _, omitSecrets := range []bool{false, true} {
+		document := newTLSSourceDocument(t, omitSecrets)
+
+		service, store, cleanup := newExportService(t)
+		defer cleanup()
+
+		// The existing endpoints shift the identifier of the imported one, and so the folder of its files.
+		for _, name := range []string{"first", "second"} {
+			err := store.EndpointService.CreateEndpoint(&api.Endpoint{Name: name, URL: "unix:///var/run/docker.sock"})
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		report, err := service.Import(document, false, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var endpoint *api.Endpoint
+		endpoints, _ := store.EndpointService.Endpoints()
+		for idx := range endpoints {
+			if endpoints[idx].Name == "remote" {
+				endpoint = &endpoints[idx]
+			}
+		}
+		if endpoint == nil {
+			t.Fatalf("expected the endpoint to be imported, got %+v", endpoints)
+		}
+		folder := strconv.Itoa(int(endpoint.ID))
+
+		for _, f := range []struct {
+			fileType api.TLSFileType
+			content  string
+			path     string
+		}{
+			{api.TLSFileCA, "ca", endpoint.TLSConfig.TLSCACertPath},
+			{api.TLSFileCert, "cert", endpoint.TLSConfig.TLSCertPath},
+			{api.TLSFileKey, "key", endpoint.TLSConfig.TLSKeyPath},
+		} {
+			if omitSecrets && f.fileType == api.TLSFileKey {
+				if f.path != "" {
+					t.Errorf("expected the path of the omitted key to be cleared, got %q", f.path)
+				}
+				continue
+			}
+			expectedPath, _ := service.FileService.GetPathForTLSFile(folder, f.fileType)
+			if f.path != expectedPath {
+				t.Errorf("omit secrets %v: expected the file to be stored in %q, got %q", omitSecrets, expectedPath, f.path)
+			}
+			if content, err := service.FileService.GetFileContent(f.path); err != nil || content != f.content {
+				t.Errorf("omit secrets %v: expected %q in %q, got %q, %v", omitSecrets, f.content, f.path, content, err)
+			}
+		}
+
+		if omitSecrets {
+			if endpoint.TLSConfig.TLS {
+				t.Error("expected TLS to be disabled without the key")
+			}
+			if len(report.Conflicts) != 1 || report.Conflicts[0].Kind != "endpoint" || report.Conflicts[0].Name != "remote" {
+				t.Errorf("expected the omitted key to be reported, got %+v", report.Conflicts)
+			}
+		} else if !endpoint.TLSConfig.TLS || len(report.Conflicts) != 0 {
+			t.Errorf("expected the endpoint to be imported with TLS, got %+v and the conflicts %+v", endpoint.TLSConfig, report.Conflicts)
+		}
+	}
+}
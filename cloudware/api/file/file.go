@@ -2,7 +2,10 @@ package file
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"io/ioutil"
+	"time"
 
 	"io"
 	"os"
@@ -16,6 +19,8 @@ const (
 	TLSStorePath = "tls"
 	// LDAPStorePath represents the subfolder where LDAP TLS files are stored in the TLSStorePath.
 	LDAPStorePath = "ldap"
+	// PKIStorePath represents the subfolder where the built-in certificate authority files are stored in the TLSStorePath.
+	PKIStorePath = "pki"
 	// TLSCACertFile represents the name on disk for a TLS CA file.
 	TLSCACertFile = "ca.pem"
 	// TLSCertFile represents the name on disk for a TLS certificate file.
@@ -26,6 +31,10 @@ const (
 	ComposeStorePath = "compose"
 	// ComposeFileDefaultName represents the default name of a compose file.
 	ComposeFileDefaultName = "docker-compose.yml"
+	// RecordingStorePath represents the subfolder where session recordings are stored in the file store folder.
+	RecordingStorePath = "recordings"
+	// TemplatesStorePath represents the subfolder where the cached templates documents are stored in the file store folder.
+	TemplatesStorePath = "templates"
 )
 
 // Service represents a service for managing files and directories.
@@ -60,6 +69,16 @@ func NewService(dataStorePath, fileStorePath string) (*Service, error) {
 		return nil, err
 	}
 
+	err = service.createDirectoryInStoreIfNotExist(RecordingStorePath)
+	if err != nil {
+		return nil, err
+	}
+
+	err = service.createDirectoryInStoreIfNotExist(TemplatesStorePath)
+	if err != nil {
+		return nil, err
+	}
+
 	return service, nil
 }
 
@@ -191,6 +210,57 @@ func (service *Service) DeleteTLSFile(folder string, fileType api.TLSFileType) e
 	return nil
 }
 
+// CreateRecordingFile creates a new file in the RecordingStorePath and returns it opened for writing
+// alongside its absolute path on the filesystem.
+func (service *Service) CreateRecordingFile(fileName string) (io.WriteCloser, string, error) {
+	filePath := path.Join(service.fileStorePath, RecordingStorePath, fileName)
+
+	out, err := os.OpenFile(filePath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return out, filePath, nil
+}
+
+// DeleteRecordingFile deletes a recording file from the RecordingStorePath.
+func (service *Service) DeleteRecordingFile(filePath string) error {
+	err := os.Remove(filePath)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// StoreTemplatesCache stores the last templates document retrieved from a source in the TemplatesStorePath.
+func (service *Service) StoreTemplatesCache(source string, content []byte) error {
+	return service.createFileInStore(path.Join(TemplatesStorePath, templatesCacheFileName(source)), bytes.NewReader(content))
+}
+
+// GetTemplatesCache returns the cached templates document of a source along with the time it was stored.
+// The error satisfies os.IsNotExist when no document is cached for the source.
+func (service *Service) GetTemplatesCache(source string) ([]byte, time.Time, error) {
+	filePath := path.Join(service.fileStorePath, TemplatesStorePath, templatesCacheFileName(source))
+
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	content, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	return content, info.ModTime(), nil
+}
+
+// templatesCacheFileName returns the name of the file caching the templates document of a source,
+// the sources are URLs that cannot be used as file names.
+func templatesCacheFileName(source string) string {
+	hash := sha256.Sum256([]byte(source))
+	return hex.EncodeToString(hash[:]) + ".json"
+}
+
 // GetFileContent returns a string content from file.
 func (service *Service) GetFileContent(filePath string) (string, error) {
 	content, err := ioutil.ReadFile(filePath)
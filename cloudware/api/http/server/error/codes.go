@@ -0,0 +1,161 @@
+package error
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+
+	"cloudware/cloudware/api"
+)
+
+// Generic error codes, used for the errors without a code of their own.
+const (
+	CodeInvalidRequest = "invalid_request"
+	CodeUnauthorized   = "unauthorized"
+	CodeForbidden      = "forbidden"
+	CodeNotFound       = "not_found"
+	CodeConflict       = "conflict"
+	CodeInternal       = "internal_error"
+	CodeBadGateway     = "bad_gateway"
+	CodeUnavailable    = "unavailable"
+)
+
+var (
+	codesMu sync.RWMutex
+	codes   = map[api.Error]string{
+		api.ErrUnauthorized:           "unauthorized",
+		api.ErrResourceAccessDenied:   "resource_access_denied",
+		api.ErrResourceNotFound:       "resource_not_found",
+		api.ErrUnsupportedDockerAPI:   "unsupported_docker_api",
+		api.ErrMissingSecurityContext: "missing_security_context",
+
+		api.ErrUserNotFound:            "user_not_found",
+		api.ErrUserAlreadyExists:       "user_already_exists",
+		api.ErrInvalidUsername:         "invalid_username",
+		api.ErrAdminAlreadyInitialized: "admin_already_initialized",
+		api.ErrCannotRemoveAdmin:       "cannot_remove_admin",
+		api.ErrAdminCannotRemoveSelf:   "admin_cannot_remove_self",
+
+		api.ErrTeamNotFound:      "team_not_found",
+		api.ErrTeamAlreadyExists: "team_already_exists",
+
+		api.ErrTeamMembershipNotFound:      "team_membership_not_found",
+		api.ErrTeamMembershipAlreadyExists: "team_membership_already_exists",
+
+		api.ErrResourceControlNotFound:      "resource_control_not_found",
+		api.ErrResourceControlAlreadyExists: "resource_control_already_exists",
+		api.ErrInvalidResourceControlType:   "invalid_resource_control_type",
+
+		api.ErrEndpointNotFound:     "endpoint_not_found",
+		api.ErrEndpointAccessDenied: "endpoint_access_denied",
+
+		api.ErrRegistryNotFound:             "registry_not_found",
+		api.ErrRegistryAlreadyExists:        "registry_already_exists",
+		api.ErrRegistryAccessDenied:         "registry_access_denied",
+		api.ErrRegistryUnreachable:          "registry_unreachable",
+		api.ErrRegistryAuthenticationFailed: "registry_authentication_failed",
+		api.ErrRegistryRepositoryNotFound:   "registry_repository_not_found",
+		api.ErrRegistryDeletionDisabled:     "registry_deletion_disabled",
+
+		api.ErrStackNotFound:                   "stack_not_found",
+		api.ErrStackAlreadyExists:              "stack_already_exists",
+		api.ErrComposeFileNotFoundInRepository: "compose_file_not_found",
+		api.ErrStackCommandCancelled:           "stack_command_cancelled",
+		api.ErrStackSwarmMismatch:              "stack_swarm_mismatch",
+
+		api.ErrTemplateNotFound:          "template_not_found",
+		api.ErrInvalidTemplateParameters: "invalid_template_parameters",
+		api.ErrInvalidTemplatesDocument:  "invalid_templates_document",
+		api.ErrTemplateSourceUnavailable: "template_source_unavailable",
+
+		api.ErrRecordingNotFound: "recording_not_found",
+
+		api.ErrDBVersionNotFound:  "db_version_not_found",
+		api.ErrDBVersionTooRecent: "db_version_too_recent",
+
+		api.ErrSettingsNotFound: "settings_not_found",
+
+		api.ErrDockerHubNotFound: "dockerhub_not_found",
+
+		api.ErrCryptoHashFailure: "hash_failure",
+
+		api.ErrSecretGeneration:   "secret_generation_failure",
+		api.ErrInvalidJWTToken:    "invalid_token",
+		api.ErrMissingContextData: "missing_token_data",
+
+		api.ErrUndefinedTLSFileType: "undefined_tls_file_type",
+
+		api.ErrInvalidCertificate:     "invalid_certificate",
+		api.ErrInvalidCertificateHost: "invalid_certificate_host",
+		api.ErrPKIDisabled:            "pki_disabled",
+
+		api.ErrInvalidEncryptionKey:  "invalid_encryption_key",
+		api.ErrEncryptionKeyMismatch: "encryption_key_mismatch",
+		api.ErrEncryptionKeyRequired: "encryption_key_required",
+
+		api.ErrInvalidBackupArchive:     "invalid_backup_archive",
+		api.ErrBackupPasswordRequired:   "backup_password_required",
+		api.ErrInvalidBackupPassword:    "invalid_backup_password",
+		api.ErrBackupDBVersionTooRecent: "backup_version_too_recent",
+		api.ErrBackupStorageFailed:      "backup_storage_failure",
+		api.ErrInvalidBackupStorage:     "invalid_backup_storage",
+
+		api.ErrInvalidConfigurationDocument: "invalid_configuration_document",
+
+		api.ErrUnsupportedExportVersion: "unsupported_export_version",
+		api.ErrImportConflicts:          "import_conflicts",
+	}
+)
+
+// RegisterErrorCodes associates codes with the errors of a package, the packages
+// defining errors returned by the API register them at initialization.
+func RegisterErrorCodes(errorCodes map[api.Error]string) {
+	codesMu.Lock()
+	defer codesMu.Unlock()
+	for err, code := range errorCodes {
+		codes[err] = code
+	}
+}
+
+// ErrorCode returns the code of an error. The errors without a code of their own
+// get the generic code of the HTTP status they are returned with.
+func ErrorCode(err error, status int) string {
+	if e, ok := err.(api.Error); ok {
+		codesMu.RLock()
+		code, ok := codes[e]
+		codesMu.RUnlock()
+		if ok {
+			return code
+		}
+	}
+	return StatusCode(status)
+}
+
+// StatusCode returns the generic code of an HTTP status.
+func StatusCode(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return CodeInvalidRequest
+	case http.StatusUnauthorized:
+		return CodeUnauthorized
+	case http.StatusForbidden:
+		return CodeForbidden
+	case http.StatusNotFound:
+		return CodeNotFound
+	case http.StatusConflict:
+		return CodeConflict
+	case http.StatusInternalServerError:
+		return CodeInternal
+	case http.StatusBadGateway:
+		return CodeBadGateway
+	case http.StatusServiceUnavailable:
+		return CodeUnavailable
+	}
+	if text := http.StatusText(status); text != "" {
+		return strings.ToLower(strings.Replace(text, " ", "_", -1))
+	}
+	if status >= http.StatusInternalServerError {
+		return CodeInternal
+	}
+	return CodeInvalidRequest
+}
@@ -2,21 +2,60 @@ package error
 
 import (
 	"encoding/json"
-	"log"
 	"net/http"
+
+	"cloudware/cloudware/api/logging"
 )
 
-// errorResponse is a generic response for sending a error.
-type errorResponse struct {
-	Err string `json:"err,omitempty"`
+// ErrorResponse is the body of the error responses of the API. Err holds the same
+// message as Message, it is kept for the clients matching the message of the errors.
+type ErrorResponse struct {
+	Err     string      `json:"err,omitempty"`
+	Code    string      `json:"code"`
+	Status  int         `json:"status"`
+	Message string      `json:"message"`
+	Details interface{} `json:"details,omitempty"`
+}
+
+// FieldError describes why a field of a request is invalid.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// NewErrorResponse returns the error response describing an error, details is optional.
+func NewErrorResponse(err error, status int, details interface{}) *ErrorResponse {
+	return &ErrorResponse{
+		Err:     err.Error(),
+		Code:    ErrorCode(err, status),
+		Status:  status,
+		Message: err.Error(),
+		Details: details,
+	}
+}
+
+// WriteErrorResponse writes an error message to the response and to the logger of the request.
+// Server errors are logged as errors, the other ones as information.
+func WriteErrorResponse(w http.ResponseWriter, r *http.Request, err error, status int) {
+	WriteErrorDetailsResponse(w, r, err, status, nil)
 }
 
-// WriteErrorResponse writes an error message to the response and logger.
-func WriteErrorResponse(w http.ResponseWriter, err error, code int, logger *log.Logger) {
-	if logger != nil {
-		logger.Printf("http error: %s (code=%d)", err, code)
+// WriteErrorDetailsResponse writes an error message along with its details to the response,
+// such as the invalid fields of a request.
+func WriteErrorDetailsResponse(w http.ResponseWriter, r *http.Request, err error, status int, details interface{}) {
+	response := NewErrorResponse(err, status, details)
+
+	logger := logging.FromRequest(r).WithField("status", status).WithField("code", response.Code)
+	if details != nil {
+		logger = logger.WithField("details", details)
+	}
+	if status >= http.StatusInternalServerError {
+		logger.Errorf("http error: %s", err)
+	} else {
+		logger.Infof("http error: %s", err)
 	}
 
-	w.WriteHeader(code)
-	json.NewEncoder(w).Encode(&errorResponse{Err: err.Error()})
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(response)
 }
@@ -0,0 +1,179 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"cloudware/cloudware/api"
+	httperror "cloudware/cloudware/api/http/server/error"
+	"cloudware/cloudware/api/http/server/proxy"
+	"cloudware/cloudware/api/http/server/security"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	// aggregateRequestTimeout is the maximum duration of a request sent to a single endpoint.
+	aggregateRequestTimeout = 10 * time.Second
+)
+
+// AggregateHandler represents an HTTP API handler for listing Docker resources across all the endpoints.
+type AggregateHandler struct {
+	*mux.Router
+	EndpointService api.EndpointService
+	ProxyManager    *proxy.Manager
+}
+
+// NewAggregateHandler returns a new instance of AggregateHandler.
+func NewAggregateHandler(bouncer *security.RequestBouncer) *AggregateHandler {
+	h := &AggregateHandler{
+		Router: mux.NewRouter(),
+	}
+	h.Handle("/containers",
+		bouncer.RestrictedAccess(h.aggregate("/containers/json", ""))).Methods(http.MethodGet)
+	h.Handle("/services",
+		bouncer.RestrictedAccess(h.aggregate("/services", ""))).Methods(http.MethodGet)
+	h.Handle("/volumes",
+		bouncer.RestrictedAccess(h.aggregate("/volumes", "Volumes"))).Methods(http.MethodGet)
+	h.Handle("/images",
+		bouncer.RestrictedAccess(h.aggregate("/images/json", ""))).Methods(http.MethodGet)
+	return h
+}
+
+type (
+	aggregateEndpoint struct {
+		ID   api.EndpointID `json:"Id"`
+		Name string         `json:"Name"`
+	}
+
+	aggregateError struct {
+		Endpoint aggregateEndpoint `json:"Endpoint"`
+		Err      string            `json:"err"`
+	}
+
+	aggregateResponse struct {
+		Items  []map[string]interface{} `json:"Items"`
+		Errors []aggregateError         `json:"Errors"`
+	}
+
+	aggregateResult struct {
+		items []map[string]interface{}
+		err   error
+	}
+)
+
+// aggregate returns a handler sending the same Docker API request to all the endpoints
+// accessible by the user. The query of the original request is forwarded to every endpoint.
+// Each item of the responses is annotated with the endpoint it comes from, endpoints that
+// cannot be reached are reported in the Errors field of the response.
+// When listKey is set, the items are extracted from this field of the Docker response.
+func (handler *AggregateHandler) aggregate(path, listKey string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		securityContext, err := security.RetrieveRestrictedRequestContext(r)
+		if err != nil {
+			httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
+			return
+		}
+
+		endpoints, err := handler.EndpointService.Endpoints()
+		if err != nil {
+			httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
+			return
+		}
+
+		filteredEndpoints, err := security.FilterEndpoints(endpoints, securityContext)
+		if err != nil {
+			httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
+			return
+		}
+
+		results := make([]aggregateResult, len(filteredEndpoints))
+		var wg sync.WaitGroup
+		for idx := range filteredEndpoints {
+			wg.Add(1)
+			go func(idx int) {
+				defer wg.Done()
+				items, err := handler.listEndpointResources(r, &filteredEndpoints[idx], path, listKey)
+				results[idx] = aggregateResult{items: items, err: err}
+			}(idx)
+		}
+		wg.Wait()
+
+		response := aggregateResponse{
+			Items:  make([]map[string]interface{}, 0),
+			Errors: make([]aggregateError, 0),
+		}
+		for idx, result := range results {
+			endpoint := aggregateEndpoint{ID: filteredEndpoints[idx].ID, Name: filteredEndpoints[idx].Name}
+			if result.err != nil {
+				response.Errors = append(response.Errors, aggregateError{Endpoint: endpoint, Err: result.err.Error()})
+				continue
+			}
+			for _, item := range result.items {
+				item["Endpoint"] = endpoint
+				response.Items = append(response.Items, item)
+			}
+		}
+
+		encodeJSON(w, r, response)
+	})
+}
+
+// listEndpointResources executes a list request against the Docker API of an endpoint.
+// The request goes through the proxy client, the resource controls are applied to the response.
+func (handler *AggregateHandler) listEndpointResources(r *http.Request, endpoint *api.Endpoint, path, listKey string) ([]map[string]interface{}, error) {
+	client, err := handler.ProxyManager.GetClient(endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	requestURL := path
+	if r.URL.RawQuery != "" {
+		requestURL += "?" + r.URL.RawQuery
+	}
+
+	request, err := http.NewRequest(http.MethodGet, requestURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), aggregateRequestTimeout)
+	defer cancel()
+
+	response, err := client.Do(request.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	// Services can only be listed on swarm managers, standalone endpoints are skipped.
+	if response.StatusCode == http.StatusServiceUnavailable && path == "/services" {
+		return nil, nil
+	}
+
+	if response.StatusCode != http.StatusOK {
+		return nil, dockerResponseError(response)
+	}
+
+	var items []map[string]interface{}
+	if listKey != "" {
+		var data map[string]json.RawMessage
+		err = json.NewDecoder(response.Body).Decode(&data)
+		if err != nil {
+			return nil, err
+		}
+		if list, ok := data[listKey]; ok && string(list) != "null" {
+			err = json.Unmarshal(list, &items)
+		}
+	} else {
+		err = json.NewDecoder(response.Body).Decode(&items)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return items, nil
+}
@@ -0,0 +1,99 @@
+package handler
+
+import (
+	"cloudware/cloudware/api/apply"
+	httperror "cloudware/cloudware/api/http/server/error"
+	"cloudware/cloudware/api/http/server/proxy"
+	"cloudware/cloudware/api/http/server/security"
+
+	"io/ioutil"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	// maxConfigurationDocumentSize is the maximum size of a configuration document sent to the API.
+	maxConfigurationDocumentSize = 4 << 20
+)
+
+// ApplyHandler represents an HTTP API handler for reconciling the instance with a configuration document.
+type ApplyHandler struct {
+	*mux.Router
+	authorizeEndpointManagement bool
+	ApplyService                *apply.Service
+	ProxyManager                *proxy.Manager
+}
+
+// NewApplyHandler returns a new instance of ApplyHandler.
+func NewApplyHandler(bouncer *security.RequestBouncer, authorizeEndpointManagement bool) *ApplyHandler {
+	h := &ApplyHandler{
+		Router:                      mux.NewRouter(),
+		authorizeEndpointManagement: authorizeEndpointManagement,
+	}
+	h.Handle("/apply",
+		bouncer.AdministratorAccess(http.HandlerFunc(h.handlePostApply))).Methods(http.MethodPost)
+	return h
+}
+
+// handlePostApply handles POST requests on /apply
+// The body is a JSON or YAML configuration document. The changes are only planned when the dryRun
+// query parameter is set, the stored entities missing from the document are deleted when prune is set.
+func (handler *ApplyHandler) handlePostApply(w http.ResponseWriter, r *http.Request) {
+	dryRun, err := parseBoolQuery(r, "dryRun")
+	if err != nil {
+		httperror.WriteErrorResponse(w, r, ErrInvalidQueryFormat, http.StatusBadRequest)
+		return
+	}
+	prune, err := parseBoolQuery(r, "prune")
+	if err != nil {
+		httperror.WriteErrorResponse(w, r, ErrInvalidQueryFormat, http.StatusBadRequest)
+		return
+	}
+
+	data, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxConfigurationDocumentSize))
+	if err != nil {
+		httperror.WriteErrorResponse(w, r, ErrInvalidRequestFormat, http.StatusBadRequest)
+		return
+	}
+
+	document, err := apply.Decode(data)
+	if err != nil {
+		httperror.WriteErrorResponse(w, r, ErrInvalidRequestFormat, http.StatusBadRequest)
+		return
+	}
+
+	if document.Endpoints != nil && !handler.authorizeEndpointManagement {
+		httperror.WriteErrorResponse(w, r, ErrEndpointManagementDisabled, http.StatusServiceUnavailable)
+		return
+	}
+
+	var plan *apply.Plan
+	if dryRun {
+		plan, err = handler.ApplyService.Plan(document, prune)
+	} else {
+		plan, err = handler.ApplyService.Apply(document, prune)
+		if plan != nil {
+			handler.deleteEndpointProxies(plan)
+		}
+	}
+	if _, ok := err.(*apply.ValidationError); ok {
+		httperror.WriteErrorResponse(w, r, err, http.StatusBadRequest)
+		return
+	} else if err != nil {
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
+		return
+	}
+
+	encodeJSON(w, r, plan)
+}
+
+// deleteEndpointProxies drops the proxies when endpoints were modified, they are recreated on their next use.
+func (handler *ApplyHandler) deleteEndpointProxies(plan *apply.Plan) {
+	for _, change := range plan.Changes {
+		if change.Kind == "endpoint" && change.Action != apply.ActionCreate {
+			handler.ProxyManager.DeleteProxies()
+			return
+		}
+	}
+}
@@ -2,9 +2,7 @@ package handler
 
 import (
 	"encoding/json"
-	"log"
 	"net/http"
-	"os"
 
 	"github.com/asaskevich/govalidator"
 	"github.com/gorilla/mux"
@@ -17,7 +15,6 @@ import (
 // AuthHandler represents an HTTP API handler for managing authentication.
 type AuthHandler struct {
 	*mux.Router
-	Logger          *log.Logger
 	authDisabled    bool
 	UserService     api.UserService
 	CryptoService   api.CryptoService
@@ -40,7 +37,6 @@ const (
 func NewAuthHandler(bouncer *security.RequestBouncer, authDisabled bool) *AuthHandler {
 	h := &AuthHandler{
 		Router:       mux.NewRouter(),
-		Logger:       log.New(os.Stderr, "", log.LstdFlags),
 		authDisabled: authDisabled,
 	}
 	h.Handle("/auth",
@@ -62,19 +58,19 @@ type (
 
 func (handler *AuthHandler) handlePostAuth(w http.ResponseWriter, r *http.Request) {
 	if handler.authDisabled {
-		httperror.WriteErrorResponse(w, ErrAuthDisabled, http.StatusServiceUnavailable, handler.Logger)
+		httperror.WriteErrorResponse(w, r, ErrAuthDisabled, http.StatusServiceUnavailable)
 		return
 	}
 
 	var req postAuthRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		httperror.WriteErrorResponse(w, ErrInvalidJSON, http.StatusBadRequest, handler.Logger)
+		httperror.WriteErrorResponse(w, r, ErrInvalidJSON, http.StatusBadRequest)
 		return
 	}
 
 	_, err := govalidator.ValidateStruct(req)
 	if err != nil {
-		httperror.WriteErrorResponse(w, ErrInvalidCredentialsFormat, http.StatusBadRequest, handler.Logger)
+		httperror.WriteErrorResponse(w, r, ErrInvalidCredentialsFormat, http.StatusBadRequest)
 		return
 	}
 
@@ -83,29 +79,29 @@ func (handler *AuthHandler) handlePostAuth(w http.ResponseWriter, r *http.Reques
 
 	u, err := handler.UserService.UserByUsername(username)
 	if err == api.ErrUserNotFound {
-		httperror.WriteErrorResponse(w, ErrInvalidCredentials, http.StatusBadRequest, handler.Logger)
+		httperror.WriteErrorResponse(w, r, ErrInvalidCredentials, http.StatusBadRequest)
 		return
 	} else if err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusInternalServerError, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
 		return
 	}
 
 	settings, err := handler.SettingsService.Settings()
 	if err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusInternalServerError, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
 		return
 	}
 
 	if settings.AuthenticationMethod == api.AuthenticationLDAP && u.ID != 1 {
 		err = handler.LDAPService.AuthenticateUser(username, password, &settings.LDAPSettings)
 		if err != nil {
-			httperror.WriteErrorResponse(w, err, http.StatusInternalServerError, handler.Logger)
+			httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
 			return
 		}
 	} else {
 		err = handler.CryptoService.CompareHashAndData(u.Password, password)
 		if err != nil {
-			httperror.WriteErrorResponse(w, ErrInvalidCredentials, http.StatusUnprocessableEntity, handler.Logger)
+			httperror.WriteErrorResponse(w, r, ErrInvalidCredentials, http.StatusUnprocessableEntity)
 			return
 		}
 	}
@@ -118,9 +114,9 @@ func (handler *AuthHandler) handlePostAuth(w http.ResponseWriter, r *http.Reques
 
 	token, err := handler.JWTService.GenerateToken(tokenData)
 	if err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusInternalServerError, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
 		return
 	}
 
-	encodeJSON(w, &postAuthResponse{JWT: token}, handler.Logger)
+	encodeJSON(w, r, &postAuthResponse{JWT: token})
 }
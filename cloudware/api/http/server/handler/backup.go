@@ -0,0 +1,98 @@
+package handler
+
+import (
+	"cloudware/cloudware/api"
+	"cloudware/cloudware/api/backup"
+	httperror "cloudware/cloudware/api/http/server/error"
+	"cloudware/cloudware/api/logging"
+	"cloudware/cloudware/api/http/server/proxy"
+	"cloudware/cloudware/api/http/server/security"
+
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	// backupPasswordHeader is the header used to specify the password encrypting a backup archive.
+	backupPasswordHeader = "X-Backup-Password"
+)
+
+// BackupHandler represents an HTTP API handler for backing up and restoring the instance.
+type BackupHandler struct {
+	*mux.Router
+	BackupService       api.BackupService
+	BackupStatusService api.BackupStatusService
+	ProxyManager        *proxy.Manager
+}
+
+// NewBackupHandler returns a new instance of BackupHandler.
+func NewBackupHandler(bouncer *security.RequestBouncer) *BackupHandler {
+	h := &BackupHandler{
+		Router: mux.NewRouter(),
+	}
+	h.Handle("/backup",
+		bouncer.AdministratorAccess(http.HandlerFunc(h.handleGetBackup))).Methods(http.MethodGet)
+	h.Handle("/backup/status",
+		bouncer.AdministratorAccess(http.HandlerFunc(h.handleGetBackupStatus))).Methods(http.MethodGet)
+	h.Handle("/restore",
+		bouncer.AdministratorAccess(http.HandlerFunc(h.handlePostRestore))).Methods(http.MethodPost)
+	return h
+}
+
+// handleGetBackup handles GET requests on /backup
+// The archive is encrypted when a password is specified in the X-Backup-Password header.
+func (handler *BackupHandler) handleGetBackup(w http.ResponseWriter, r *http.Request) {
+	password := r.Header.Get(backupPasswordHeader)
+
+	fileName := backup.ArchiveName(time.Now(), password != "")
+	contentType := "application/gzip"
+	if password != "" {
+		contentType = "application/octet-stream"
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", "attachment; filename=\""+fileName+"\"")
+
+	err := handler.BackupService.CreateBackup(w, password)
+	if err != nil {
+		// The response is already being streamed, the error can only be logged.
+		logging.FromRequest(r).Errorf("Unable to create backup: %s", err)
+	}
+}
+
+// handleGetBackupStatus handles GET requests on /backup/status
+func (handler *BackupHandler) handleGetBackupStatus(w http.ResponseWriter, r *http.Request) {
+	status, err := handler.BackupStatusService.BackupStatus()
+	if err != nil {
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
+		return
+	}
+
+	encodeJSON(w, r, status)
+}
+
+// handlePostRestore handles POST requests on /restore
+// The archive is sent in the file field of a multipart form, along with the optional Password field.
+func (handler *BackupHandler) handlePostRestore(w http.ResponseWriter, r *http.Request) {
+	archive, _, err := r.FormFile("file")
+	if err != nil {
+		httperror.WriteErrorResponse(w, r, ErrInvalidRequestFormat, http.StatusBadRequest)
+		return
+	}
+	defer archive.Close()
+
+	err = handler.BackupService.RestoreBackup(archive, r.FormValue("Password"))
+	if err == api.ErrInvalidBackupArchive || err == api.ErrBackupPasswordRequired ||
+		err == api.ErrInvalidBackupPassword || err == api.ErrBackupDBVersionTooRecent {
+		httperror.WriteErrorResponse(w, r, err, http.StatusBadRequest)
+		return
+	} else if err != nil {
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
+		return
+	}
+
+	// The proxies are recreated from the restored endpoints on their next use.
+	handler.ProxyManager.DeleteProxies()
+}
@@ -2,15 +2,16 @@ package handler
 
 import (
 	"strconv"
+	"strings"
+	"time"
 
 	httperror "cloudware/cloudware/api/http/server/error"
 	"cloudware/cloudware/api/http/server/proxy"
 	"cloudware/cloudware/api/http/server/security"
 	"cloudware/cloudware/api"
+	"cloudware/cloudware/api/metrics"
 
-	"log"
 	"net/http"
-	"os"
 
 	"github.com/gorilla/mux"
 )
@@ -18,7 +19,6 @@ import (
 // DockerHandler represents an HTTP API handler for proxying requests to the Docker API.
 type DockerHandler struct {
 	*mux.Router
-	Logger                *log.Logger
 	EndpointService       api.EndpointService
 	TeamMembershipService api.TeamMembershipService
 	ProxyManager          *proxy.Manager
@@ -28,7 +28,6 @@ type DockerHandler struct {
 func NewDockerHandler(bouncer *security.RequestBouncer) *DockerHandler {
 	h := &DockerHandler{
 		Router: mux.NewRouter(),
-		Logger: log.New(os.Stderr, "", log.LstdFlags),
 	}
 	h.PathPrefix("/{id}/docker").Handler(
 		bouncer.AuthenticatedAccess(http.HandlerFunc(h.proxyRequestsToDockerAPI)))
@@ -59,36 +58,41 @@ func (handler *DockerHandler) proxyRequestsToDockerAPI(w http.ResponseWriter, r
 
 	parsedID, err := strconv.Atoi(id)
 	if err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusBadRequest, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusBadRequest)
 		return
 	}
 
 	endpointID := api.EndpointID(parsedID)
 	endpoint, err := handler.EndpointService.Endpoint(endpointID)
 	if err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusInternalServerError, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
 		return
 	}
 
 	tokenData, err := security.RetrieveTokenData(r)
 	if err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusInternalServerError, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
 		return
 	}
 	if tokenData.Role != api.AdministratorRole && !handler.checkEndpointAccessControl(endpoint, tokenData.ID) {
-		httperror.WriteErrorResponse(w, api.ErrEndpointAccessDenied, http.StatusForbidden, handler.Logger)
+		httperror.WriteErrorResponse(w, r, api.ErrEndpointAccessDenied, http.StatusForbidden)
 		return
 	}
 
 	var proxy http.Handler
-	proxy = handler.ProxyManager.GetProxy(string(endpointID))
+	proxy = handler.ProxyManager.GetProxy(strconv.Itoa(int(endpointID)))
 	if proxy == nil {
 		proxy, err = handler.ProxyManager.CreateAndRegisterProxy(endpoint)
 		if err != nil {
-			httperror.WriteErrorResponse(w, err, http.StatusBadRequest, handler.Logger)
+			httperror.WriteErrorResponse(w, r, err, http.StatusBadRequest)
 			return
 		}
 	}
 
-	http.StripPrefix("/"+id+"/docker", proxy).ServeHTTP(w, r)
+	start := time.Now()
+	recorder := newStatusRecorder(w)
+	http.StripPrefix("/"+id+"/docker", proxy).ServeHTTP(recorder, r)
+
+	group := dockerRouteGroup(strings.TrimPrefix(r.URL.Path, "/"+id+"/docker"))
+	metrics.ObserveDockerProxyRequest(strconv.Itoa(int(endpointID)), group, recorder.status, time.Since(start))
 }
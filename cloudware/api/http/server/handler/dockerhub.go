@@ -7,9 +7,7 @@ import (
 	httperror "cloudware/cloudware/api/http/server/error"
 	"cloudware/cloudware/api/http/server/security"
 
-	"log"
 	"net/http"
-	"os"
 
 	"github.com/gorilla/mux"
 
@@ -19,7 +17,6 @@ import (
 // DockerHubHandler represents an HTTP API handler for managing DockerHub.
 type DockerHubHandler struct {
 	*mux.Router
-	Logger           *log.Logger
 	DockerHubService api.DockerHubService
 }
 
@@ -27,7 +24,6 @@ type DockerHubHandler struct {
 func NewDockerHubHandler(bouncer *security.RequestBouncer) *DockerHubHandler {
 	h := &DockerHubHandler{
 		Router: mux.NewRouter(),
-		Logger: log.New(os.Stderr, "", log.LstdFlags),
 	}
 	h.Handle("/dockerhub",
 		bouncer.AuthenticatedAccess(http.HandlerFunc(h.handleGetDockerHub))).Methods(http.MethodGet)
@@ -39,9 +35,9 @@ func NewDockerHubHandler(bouncer *security.RequestBouncer) *DockerHubHandler {
 
 type (
 	putDockerHubRequest struct {
-		Authentication bool   `valid:""`
-		Username       string `valid:""`
-		Password       string `valid:""`
+		Authentication bool    `valid:""`
+		Username       string  `valid:""`
+		Password       *string `valid:"-"`
 	}
 )
 
@@ -49,11 +45,12 @@ type (
 func (handler *DockerHubHandler) handleGetDockerHub(w http.ResponseWriter, r *http.Request) {
 	dockerhub, err := handler.DockerHubService.DockerHub()
 	if err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusInternalServerError, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
 		return
 	}
 
-	encodeJSON(w, dockerhub, handler.Logger)
+	dockerhub.Password = ""
+	encodeJSON(w, r, dockerhub)
 	return
 }
 
@@ -61,13 +58,13 @@ func (handler *DockerHubHandler) handleGetDockerHub(w http.ResponseWriter, r *ht
 func (handler *DockerHubHandler) handlePutDockerHub(w http.ResponseWriter, r *http.Request) {
 	var req putDockerHubRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		httperror.WriteErrorResponse(w, ErrInvalidJSON, http.StatusBadRequest, handler.Logger)
+		httperror.WriteErrorResponse(w, r, ErrInvalidJSON, http.StatusBadRequest)
 		return
 	}
 
 	_, err := govalidator.ValidateStruct(req)
 	if err != nil {
-		httperror.WriteErrorResponse(w, ErrInvalidRequestFormat, http.StatusBadRequest, handler.Logger)
+		httperror.WriteErrorResponse(w, r, ErrInvalidRequestFormat, http.StatusBadRequest)
 		return
 	}
 
@@ -80,11 +77,23 @@ func (handler *DockerHubHandler) handlePutDockerHub(w http.ResponseWriter, r *ht
 	if req.Authentication {
 		dockerhub.Authentication = true
 		dockerhub.Username = req.Username
-		dockerhub.Password = req.Password
+		if req.Password != nil {
+			dockerhub.Password = *req.Password
+		}
+	}
+
+	// The password is never sent back to the client, the current one is kept when it is omitted
+	if dockerhub.Authentication && req.Password == nil {
+		current, err := handler.DockerHubService.DockerHub()
+		if err != nil {
+			httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
+			return
+		}
+		dockerhub.Password = current.Password
 	}
 
 	err = handler.DockerHubService.StoreDockerHub(dockerhub)
 	if err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusInternalServerError, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
 	}
 }
@@ -7,9 +7,7 @@ import (
 	"cloudware/cloudware/api/http/server/security"
 
 	"encoding/json"
-	"log"
 	"net/http"
-	"os"
 	"strconv"
 
 	"github.com/asaskevich/govalidator"
@@ -19,7 +17,6 @@ import (
 // EndpointHandler represents an HTTP API handler for managing Docker endpoints.
 type EndpointHandler struct {
 	*mux.Router
-	Logger                      *log.Logger
 	authorizeEndpointManagement bool
 	EndpointService             api.EndpointService
 	FileService                 api.FileService
@@ -36,7 +33,6 @@ const (
 func NewEndpointHandler(bouncer *security.RequestBouncer, authorizeEndpointManagement bool) *EndpointHandler {
 	h := &EndpointHandler{
 		Router: mux.NewRouter(),
-		Logger: log.New(os.Stderr, "", log.LstdFlags),
 		authorizeEndpointManagement: authorizeEndpointManagement,
 	}
 	h.Handle("/endpoints",
@@ -63,6 +59,7 @@ type (
 		TLS                 bool
 		TLSSkipVerify       bool
 		TLSSkipClientVerify bool
+		RecordSessions      bool
 	}
 
 	postEndpointsResponse struct {
@@ -81,6 +78,7 @@ type (
 		TLS                 bool   `valid:"-"`
 		TLSSkipVerify       bool   `valid:"-"`
 		TLSSkipClientVerify bool   `valid:"-"`
+		RecordSessions      *bool  `valid:"-"`
 	}
 )
 
@@ -88,41 +86,46 @@ type (
 func (handler *EndpointHandler) handleGetEndpoints(w http.ResponseWriter, r *http.Request) {
 	securityContext, err := security.RetrieveRestrictedRequestContext(r)
 	if err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusInternalServerError, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
 		return
 	}
 
-	endpoints, err := handler.EndpointService.Endpoints()
+	options, err := parseListOptions(r, api.SortByURL)
 	if err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusInternalServerError, handler.Logger)
+		httperror.WriteErrorResponse(w, r, ErrInvalidQueryFormat, http.StatusBadRequest)
 		return
 	}
 
-	filteredEndpoints, err := security.FilterEndpoints(endpoints, securityContext)
+	endpoints, total, err := handler.EndpointService.ListEndpoints(&api.EndpointQuery{
+		ListOptions: options,
+		URL:         r.URL.Query().Get("url"),
+		Access:      security.ListAccessFilter(securityContext),
+	})
 	if err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusInternalServerError, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
 		return
 	}
 
-	encodeJSON(w, filteredEndpoints, handler.Logger)
+	writeTotalCount(w, total)
+	encodeJSON(w, r, endpoints)
 }
 
 // handlePostEndpoints handles POST requests on /endpoints
 func (handler *EndpointHandler) handlePostEndpoints(w http.ResponseWriter, r *http.Request) {
 	if !handler.authorizeEndpointManagement {
-		httperror.WriteErrorResponse(w, ErrEndpointManagementDisabled, http.StatusServiceUnavailable, handler.Logger)
+		httperror.WriteErrorResponse(w, r, ErrEndpointManagementDisabled, http.StatusServiceUnavailable)
 		return
 	}
 
 	var req postEndpointsRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		httperror.WriteErrorResponse(w, ErrInvalidJSON, http.StatusBadRequest, handler.Logger)
+		httperror.WriteErrorResponse(w, r, ErrInvalidJSON, http.StatusBadRequest)
 		return
 	}
 
 	_, err := govalidator.ValidateStruct(req)
 	if err != nil {
-		httperror.WriteErrorResponse(w, ErrInvalidRequestFormat, http.StatusBadRequest, handler.Logger)
+		httperror.WriteErrorResponse(w, r, ErrInvalidRequestFormat, http.StatusBadRequest)
 		return
 	}
 
@@ -136,11 +139,12 @@ func (handler *EndpointHandler) handlePostEndpoints(w http.ResponseWriter, r *ht
 		},
 		AuthorizedUsers: []api.UserID{},
 		AuthorizedTeams: []api.TeamID{},
+		RecordSessions:  req.RecordSessions,
 	}
 
 	err = handler.EndpointService.CreateEndpoint(endpoint)
 	if err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusInternalServerError, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
 		return
 	}
 
@@ -161,12 +165,12 @@ func (handler *EndpointHandler) handlePostEndpoints(w http.ResponseWriter, r *ht
 
 		err = handler.EndpointService.UpdateEndpoint(endpoint.ID, endpoint)
 		if err != nil {
-			httperror.WriteErrorResponse(w, err, http.StatusInternalServerError, handler.Logger)
+			httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
 			return
 		}
 	}
 
-	encodeJSON(w, &postEndpointsResponse{ID: int(endpoint.ID)}, handler.Logger)
+	encodeJSON(w, r, &postEndpointsResponse{ID: int(endpoint.ID)})
 }
 
 // handleGetEndpoint handles GET requests on /endpoints/:id
@@ -176,20 +180,20 @@ func (handler *EndpointHandler) handleGetEndpoint(w http.ResponseWriter, r *http
 
 	endpointID, err := strconv.Atoi(id)
 	if err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusBadRequest, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusBadRequest)
 		return
 	}
 
 	endpoint, err := handler.EndpointService.Endpoint(api.EndpointID(endpointID))
 	if err == api.ErrEndpointNotFound {
-		httperror.WriteErrorResponse(w, err, http.StatusNotFound, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusNotFound)
 		return
 	} else if err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusInternalServerError, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
 		return
 	}
 
-	encodeJSON(w, endpoint, handler.Logger)
+	encodeJSON(w, r, endpoint)
 }
 
 // handlePutEndpointAccess handles PUT requests on /endpoints/:id/access
@@ -199,28 +203,28 @@ func (handler *EndpointHandler) handlePutEndpointAccess(w http.ResponseWriter, r
 
 	endpointID, err := strconv.Atoi(id)
 	if err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusBadRequest, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusBadRequest)
 		return
 	}
 
 	var req putEndpointAccessRequest
 	if err = json.NewDecoder(r.Body).Decode(&req); err != nil {
-		httperror.WriteErrorResponse(w, ErrInvalidJSON, http.StatusBadRequest, handler.Logger)
+		httperror.WriteErrorResponse(w, r, ErrInvalidJSON, http.StatusBadRequest)
 		return
 	}
 
 	_, err = govalidator.ValidateStruct(req)
 	if err != nil {
-		httperror.WriteErrorResponse(w, ErrInvalidRequestFormat, http.StatusBadRequest, handler.Logger)
+		httperror.WriteErrorResponse(w, r, ErrInvalidRequestFormat, http.StatusBadRequest)
 		return
 	}
 
 	endpoint, err := handler.EndpointService.Endpoint(api.EndpointID(endpointID))
 	if err == api.ErrEndpointNotFound {
-		httperror.WriteErrorResponse(w, err, http.StatusNotFound, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusNotFound)
 		return
 	} else if err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusInternalServerError, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
 		return
 	}
 
@@ -242,7 +246,7 @@ func (handler *EndpointHandler) handlePutEndpointAccess(w http.ResponseWriter, r
 
 	err = handler.EndpointService.UpdateEndpoint(endpoint.ID, endpoint)
 	if err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusInternalServerError, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
 		return
 	}
 }
@@ -250,7 +254,7 @@ func (handler *EndpointHandler) handlePutEndpointAccess(w http.ResponseWriter, r
 // handlePutEndpoint handles PUT requests on /endpoints/:id
 func (handler *EndpointHandler) handlePutEndpoint(w http.ResponseWriter, r *http.Request) {
 	if !handler.authorizeEndpointManagement {
-		httperror.WriteErrorResponse(w, ErrEndpointManagementDisabled, http.StatusServiceUnavailable, handler.Logger)
+		httperror.WriteErrorResponse(w, r, ErrEndpointManagementDisabled, http.StatusServiceUnavailable)
 		return
 	}
 
@@ -259,28 +263,28 @@ func (handler *EndpointHandler) handlePutEndpoint(w http.ResponseWriter, r *http
 
 	endpointID, err := strconv.Atoi(id)
 	if err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusBadRequest, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusBadRequest)
 		return
 	}
 
 	var req putEndpointsRequest
 	if err = json.NewDecoder(r.Body).Decode(&req); err != nil {
-		httperror.WriteErrorResponse(w, ErrInvalidJSON, http.StatusBadRequest, handler.Logger)
+		httperror.WriteErrorResponse(w, r, ErrInvalidJSON, http.StatusBadRequest)
 		return
 	}
 
 	_, err = govalidator.ValidateStruct(req)
 	if err != nil {
-		httperror.WriteErrorResponse(w, ErrInvalidRequestFormat, http.StatusBadRequest, handler.Logger)
+		httperror.WriteErrorResponse(w, r, ErrInvalidRequestFormat, http.StatusBadRequest)
 		return
 	}
 
 	endpoint, err := handler.EndpointService.Endpoint(api.EndpointID(endpointID))
 	if err == api.ErrEndpointNotFound {
-		httperror.WriteErrorResponse(w, err, http.StatusNotFound, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusNotFound)
 		return
 	} else if err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusInternalServerError, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
 		return
 	}
 
@@ -296,8 +300,13 @@ func (handler *EndpointHandler) handlePutEndpoint(w http.ResponseWriter, r *http
 		endpoint.PublicURL = req.PublicURL
 	}
 
+	if req.RecordSessions != nil {
+		endpoint.RecordSessions = *req.RecordSessions
+	}
+
 	folder := strconv.Itoa(int(endpoint.ID))
-	if req.TLS {
+	// The TLS files of an endpoint managed by the built-in certificate authority are left untouched
+	if req.TLS && !endpoint.ManagedCertificates {
 		endpoint.TLSConfig.TLS = true
 		endpoint.TLSConfig.TLSSkipVerify = req.TLSSkipVerify
 		if !req.TLSSkipVerify {
@@ -319,28 +328,29 @@ func (handler *EndpointHandler) handlePutEndpoint(w http.ResponseWriter, r *http
 			endpoint.TLSConfig.TLSKeyPath = ""
 			handler.FileService.DeleteTLSFile(folder, api.TLSFileKey)
 		}
-	} else {
+	} else if !req.TLS {
 		endpoint.TLSConfig.TLS = false
 		endpoint.TLSConfig.TLSSkipVerify = true
 		endpoint.TLSConfig.TLSCACertPath = ""
 		endpoint.TLSConfig.TLSCertPath = ""
 		endpoint.TLSConfig.TLSKeyPath = ""
+		endpoint.ManagedCertificates = false
 		err = handler.FileService.DeleteTLSFiles(folder)
 		if err != nil {
-			httperror.WriteErrorResponse(w, err, http.StatusInternalServerError, handler.Logger)
+			httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
 			return
 		}
 	}
 
 	_, err = handler.ProxyManager.CreateAndRegisterProxy(endpoint)
 	if err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusInternalServerError, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
 		return
 	}
 
 	err = handler.EndpointService.UpdateEndpoint(endpoint.ID, endpoint)
 	if err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusInternalServerError, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
 		return
 	}
 }
@@ -348,7 +358,7 @@ func (handler *EndpointHandler) handlePutEndpoint(w http.ResponseWriter, r *http
 // handleDeleteEndpoint handles DELETE requests on /endpoints/:id
 func (handler *EndpointHandler) handleDeleteEndpoint(w http.ResponseWriter, r *http.Request) {
 	if !handler.authorizeEndpointManagement {
-		httperror.WriteErrorResponse(w, ErrEndpointManagementDisabled, http.StatusServiceUnavailable, handler.Logger)
+		httperror.WriteErrorResponse(w, r, ErrEndpointManagementDisabled, http.StatusServiceUnavailable)
 		return
 	}
 
@@ -357,32 +367,32 @@ func (handler *EndpointHandler) handleDeleteEndpoint(w http.ResponseWriter, r *h
 
 	endpointID, err := strconv.Atoi(id)
 	if err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusBadRequest, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusBadRequest)
 		return
 	}
 
 	endpoint, err := handler.EndpointService.Endpoint(api.EndpointID(endpointID))
 
 	if err == api.ErrEndpointNotFound {
-		httperror.WriteErrorResponse(w, err, http.StatusNotFound, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusNotFound)
 		return
 	} else if err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusInternalServerError, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
 		return
 	}
 
-	handler.ProxyManager.DeleteProxy(string(endpointID))
+	handler.ProxyManager.DeleteProxy(strconv.Itoa(endpointID))
 
 	err = handler.EndpointService.DeleteEndpoint(api.EndpointID(endpointID))
 	if err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusInternalServerError, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
 		return
 	}
 
 	if endpoint.TLSConfig.TLS {
 		err = handler.FileService.DeleteTLSFiles(id)
 		if err != nil {
-			httperror.WriteErrorResponse(w, err, http.StatusInternalServerError, handler.Logger)
+			httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
 			return
 		}
 	}
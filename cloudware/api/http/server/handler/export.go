@@ -0,0 +1,106 @@
+package handler
+
+import (
+	"cloudware/cloudware/api"
+	"cloudware/cloudware/api/export"
+	httperror "cloudware/cloudware/api/http/server/error"
+	"cloudware/cloudware/api/http/server/security"
+
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	// maxImportDocumentSize is the maximum size of an export document sent to the API, it includes
+	// the stack files and the TLS files of the endpoints.
+	maxImportDocumentSize = 32 << 20
+)
+
+// ExportHandler represents an HTTP API handler for exporting and importing the entities of the instance.
+type ExportHandler struct {
+	*mux.Router
+	ExportService *export.Service
+}
+
+// NewExportHandler returns a new instance of ExportHandler.
+func NewExportHandler(bouncer *security.RequestBouncer) *ExportHandler {
+	h := &ExportHandler{
+		Router: mux.NewRouter(),
+	}
+	h.Handle("/export",
+		bouncer.AdministratorAccess(http.HandlerFunc(h.handleGetExport))).Methods(http.MethodGet)
+	h.Handle("/import",
+		bouncer.AdministratorAccess(http.HandlerFunc(h.handlePostImport))).Methods(http.MethodPost)
+	return h
+}
+
+// handleGetExport handles GET requests on /export
+// The secrets are left out of the document when the omitSecrets query parameter is set.
+func (handler *ExportHandler) handleGetExport(w http.ResponseWriter, r *http.Request) {
+	omitSecrets, err := parseBoolQuery(r, "omitSecrets")
+	if err != nil {
+		httperror.WriteErrorResponse(w, r, ErrInvalidQueryFormat, http.StatusBadRequest)
+		return
+	}
+
+	document, err := handler.ExportService.Export(omitSecrets)
+	if err != nil {
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
+		return
+	}
+
+	fileName := "cloudware-export-" + time.Now().UTC().Format("20060102-150405") + ".json"
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition", "attachment; filename=\""+fileName+"\"")
+	encodeJSON(w, r, document)
+}
+
+// handlePostImport handles POST requests on /import
+// The conflicts are only reported when the dryRun query parameter is set, nothing is imported
+// when failOnConflict is set and conflicts are found.
+func (handler *ExportHandler) handlePostImport(w http.ResponseWriter, r *http.Request) {
+	dryRun, err := parseBoolQuery(r, "dryRun")
+	if err != nil {
+		httperror.WriteErrorResponse(w, r, ErrInvalidQueryFormat, http.StatusBadRequest)
+		return
+	}
+	failOnConflict, err := parseBoolQuery(r, "failOnConflict")
+	if err != nil {
+		httperror.WriteErrorResponse(w, r, ErrInvalidQueryFormat, http.StatusBadRequest)
+		return
+	}
+
+	var document export.Document
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxImportDocumentSize)).Decode(&document); err != nil {
+		httperror.WriteErrorResponse(w, r, ErrInvalidJSON, http.StatusBadRequest)
+		return
+	}
+
+	report, err := handler.ExportService.Import(&document, dryRun, failOnConflict)
+	if err == api.ErrUnsupportedExportVersion {
+		httperror.WriteErrorResponse(w, r, err, http.StatusBadRequest)
+		return
+	} else if err == api.ErrImportConflicts {
+		w.WriteHeader(http.StatusConflict)
+		encodeJSON(w, r, report)
+		return
+	} else if err != nil {
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
+		return
+	}
+
+	encodeJSON(w, r, report)
+}
+
+// parseBoolQuery returns the value of a boolean query parameter, false when it is not specified.
+func parseBoolQuery(r *http.Request, name string) (bool, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return false, nil
+	}
+	return strconv.ParseBool(value)
+}
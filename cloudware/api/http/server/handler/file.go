@@ -1,9 +1,6 @@
 package handler
 
 import (
-	"os"
-
-	"log"
 	"net/http"
 	"strings"
 )
@@ -11,14 +8,12 @@ import (
 // FileHandler represents an HTTP API handler for managing static files.
 type FileHandler struct {
 	http.Handler
-	Logger *log.Logger
 }
 
 // NewFileHandler returns a new instance of FileHandler.
 func NewFileHandler(assetPublicPath string) *FileHandler {
 	h := &FileHandler{
 		Handler: http.FileServer(http.Dir(assetPublicPath)),
-		Logger:  log.New(os.Stderr, "", log.LstdFlags),
 	}
 	return h
 }
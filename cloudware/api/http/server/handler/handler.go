@@ -2,21 +2,30 @@ package handler
 
 import (
 	"encoding/json"
-	"log"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
 
 	"cloudware/cloudware/api"
 	httperror "cloudware/cloudware/api/http/server/error"
+	"cloudware/cloudware/api/http/server/security"
+	"cloudware/cloudware/api/logging"
+	"cloudware/cloudware/api/metrics"
 )
 
 // Handler is a collection of all the service handlers.
 type Handler struct {
 	AuthHandler           *AuthHandler
+	ApplyHandler          *ApplyHandler
+	BackupHandler         *BackupHandler
 	UserHandler           *UserHandler
 	TeamHandler           *TeamHandler
 	TeamMembershipHandler *TeamMembershipHandler
 	EndpointHandler       *EndpointHandler
+	ExportHandler         *ExportHandler
 	RegistryHandler       *RegistryHandler
 	DockerHubHandler      *DockerHubHandler
 	ResourceHandler       *ResourceHandler
@@ -25,9 +34,18 @@ type Handler struct {
 	SettingsHandler       *SettingsHandler
 	TemplatesHandler      *TemplatesHandler
 	DockerHandler         *DockerHandler
+	AggregateHandler      *AggregateHandler
+	LogsHandler           *LogsHandler
+	LoggingHandler        *LoggingHandler
 	WebSocketHandler      *WebSocketHandler
+	RecordingHandler      *RecordingHandler
+	PKIHandler            *PKIHandler
 	UploadHandler         *UploadHandler
 	FileHandler           *FileHandler
+	// MetricsHandler serves the metrics on /metrics to the administrators, unless they are served by a separate listener.
+	MetricsHandler http.Handler
+	// RequestBouncer authenticates the requests before they are validated.
+	RequestBouncer *security.RequestBouncer
 }
 
 const (
@@ -37,52 +55,163 @@ const (
 	ErrInvalidRequestFormat = api.Error("Invalid request data format")
 	// ErrInvalidQueryFormat defines an error raised when the data sent in the query or the URL is invalid
 	ErrInvalidQueryFormat = api.Error("Invalid query format")
+	// ErrRouteNotFound defines an error raised when no route of the API matches the path of a request
+	ErrRouteNotFound = api.Error("No route matches the requested path")
+	// ErrMethodNotAllowed defines an error raised when the route matching a request does not support its method
+	ErrMethodNotAllowed = api.Error("Method not allowed on this route")
+	// ErrRequestBodyTooLarge defines an error raised when the body of a request exceeds the maximum size of its route
+	ErrRequestBodyTooLarge = api.Error("Request body too large")
 )
 
-// ServeHTTP delegates a request to the appropriate subhandler.
+func init() {
+	httperror.RegisterErrorCodes(map[api.Error]string{
+		ErrInvalidJSON:                "invalid_json",
+		ErrInvalidRequestFormat:       "invalid_request_format",
+		ErrInvalidQueryFormat:         "invalid_query_format",
+		ErrRouteNotFound:              "route_not_found",
+		ErrMethodNotAllowed:           "method_not_allowed",
+		ErrRequestBodyTooLarge:        "request_body_too_large",
+		ErrInvalidCredentialsFormat:   "invalid_credentials_format",
+		ErrInvalidCredentials:         "invalid_credentials",
+		ErrAuthDisabled:               "auth_disabled",
+		ErrEndpointManagementDisabled: "endpoint_management_disabled",
+		ErrLogStreamingNotSupported:   "log_streaming_not_supported",
+		ErrNoContainerFound:           "no_container_found",
+		ErrDockerRequestFailed:        "docker_request_failed",
+	})
+}
+
+// ServeHTTP validates a request against the API document, once the user is authenticated, and delegates
+// it to the appropriate subhandler with a logger identifying the request, then logs it and records it in the metrics.
 func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+
+	requestID := logging.RequestID(r)
+	w.Header().Set(logging.RequestIDHeader, requestID)
+	fields := logrus.Fields{
+		"request_id": requestID,
+		"method":     r.Method,
+		"path":       r.URL.Path,
+	}
+	if endpointID := endpointIDFromPath(r.URL.Path); endpointID != "" {
+		fields["endpoint"] = endpointID
+	}
+	ctx := logging.NewContext(r.Context(), logrus.WithFields(fields))
+
+	recorder := newStatusRecorder(w)
+	r = r.WithContext(ctx)
+	if operation, params, ok := findOperation(recorder, r); ok {
+		h.authenticate(operation, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if operation == nil || validateOperation(w, r, operation, params) {
+				h.route(w, r)
+			}
+		})).ServeHTTP(recorder, r)
+	}
+
+	duration := time.Since(start)
+	logging.FromContext(ctx).WithFields(logrus.Fields{
+		"status":   recorder.status,
+		"duration": duration.Seconds(),
+	}).Debug("http request")
+	metrics.ObserveHTTPRequest(handlerName(r.URL.Path), r.Method, recorder.status, duration)
+}
+
+func (h *Handler) route(w http.ResponseWriter, r *http.Request) {
+	handler, prefix := h.subhandler(r.URL.Path)
+	if handler == nil {
+		return
+	}
+	if prefix != "" {
+		handler = http.StripPrefix(prefix, handler)
+	}
+	handler.ServeHTTP(w, r)
+}
 
+// subhandler returns the handler serving a path along with the prefix removed from the
+// path before serving it, the handler is nil when no handler serves the path.
+func (h *Handler) subhandler(path string) (http.Handler, string) {
 	switch {
-	case strings.HasPrefix(r.URL.Path, "/api/auth"):
-		http.StripPrefix("/api", h.AuthHandler).ServeHTTP(w, r)
-	case strings.HasPrefix(r.URL.Path, "/api/dockerhub"):
-		http.StripPrefix("/api", h.DockerHubHandler).ServeHTTP(w, r)
-	case strings.HasPrefix(r.URL.Path, "/api/endpoints"):
-		if strings.Contains(r.URL.Path, "/docker/") {
-			http.StripPrefix("/api/endpoints", h.DockerHandler).ServeHTTP(w, r)
-		} else if strings.Contains(r.URL.Path, "/stacks") {
-			http.StripPrefix("/api/endpoints", h.StackHandler).ServeHTTP(w, r)
+	case strings.HasPrefix(path, "/api/apply"):
+		return h.ApplyHandler, "/api"
+	case strings.HasPrefix(path, "/api/auth"):
+		return h.AuthHandler, "/api"
+	case strings.HasPrefix(path, "/api/backup"),
+		strings.HasPrefix(path, "/api/restore"):
+		return h.BackupHandler, "/api"
+	case strings.HasPrefix(path, "/api/containers"),
+		strings.HasPrefix(path, "/api/services"),
+		strings.HasPrefix(path, "/api/volumes"),
+		strings.HasPrefix(path, "/api/images"):
+		return h.AggregateHandler, "/api"
+	case strings.HasPrefix(path, "/api/dockerhub"):
+		return h.DockerHubHandler, "/api"
+	case strings.HasPrefix(path, "/api/endpoints"):
+		if strings.Contains(path, "/docker/") {
+			return h.DockerHandler, "/api/endpoints"
+		} else if path == "/api/endpoints/"+endpointIDFromPath(path)+"/logs" {
+			return h.LogsHandler, "/api/endpoints"
+		} else if strings.Contains(path, "/templates/") {
+			return h.TemplatesHandler, "/api"
+		} else if strings.Contains(path, "/stacks") {
+			return h.StackHandler, "/api/endpoints"
 		} else {
-			http.StripPrefix("/api", h.EndpointHandler).ServeHTTP(w, r)
+			return h.EndpointHandler, "/api"
 		}
-	case strings.HasPrefix(r.URL.Path, "/api/registries"):
-		http.StripPrefix("/api", h.RegistryHandler).ServeHTTP(w, r)
-	case strings.HasPrefix(r.URL.Path, "/api/resource_controls"):
-		http.StripPrefix("/api", h.ResourceHandler).ServeHTTP(w, r)
-	case strings.HasPrefix(r.URL.Path, "/api/settings"):
-		http.StripPrefix("/api", h.SettingsHandler).ServeHTTP(w, r)
-	case strings.HasPrefix(r.URL.Path, "/api/status"):
-		http.StripPrefix("/api", h.StatusHandler).ServeHTTP(w, r)
-	case strings.HasPrefix(r.URL.Path, "/api/templates"):
-		http.StripPrefix("/api", h.TemplatesHandler).ServeHTTP(w, r)
-	case strings.HasPrefix(r.URL.Path, "/api/upload"):
-		http.StripPrefix("/api", h.UploadHandler).ServeHTTP(w, r)
-	case strings.HasPrefix(r.URL.Path, "/api/users"):
-		http.StripPrefix("/api", h.UserHandler).ServeHTTP(w, r)
-	case strings.HasPrefix(r.URL.Path, "/api/teams"):
-		http.StripPrefix("/api", h.TeamHandler).ServeHTTP(w, r)
-	case strings.HasPrefix(r.URL.Path, "/api/team_memberships"):
-		http.StripPrefix("/api", h.TeamMembershipHandler).ServeHTTP(w, r)
-	case strings.HasPrefix(r.URL.Path, "/api/websocket"):
-		http.StripPrefix("/api", h.WebSocketHandler).ServeHTTP(w, r)
-	case strings.HasPrefix(r.URL.Path, "/"):
-		h.FileHandler.ServeHTTP(w, r)
+	case strings.HasPrefix(path, "/api/export"),
+		strings.HasPrefix(path, "/api/import"):
+		return h.ExportHandler, "/api"
+	case strings.HasPrefix(path, "/api/logging"):
+		return h.LoggingHandler, "/api"
+	case path == "/api/openapi.json":
+		return http.HandlerFunc(handleGetOpenAPI), ""
+	case strings.HasPrefix(path, "/api/pki"):
+		return h.PKIHandler, "/api"
+	case strings.HasPrefix(path, "/api/recordings"):
+		return h.RecordingHandler, "/api"
+	case strings.HasPrefix(path, "/api/registries"):
+		return h.RegistryHandler, "/api"
+	case strings.HasPrefix(path, "/api/resource_controls"):
+		return h.ResourceHandler, "/api"
+	case strings.HasPrefix(path, "/api/settings"):
+		return h.SettingsHandler, "/api"
+	case strings.HasPrefix(path, "/api/status"):
+		return h.StatusHandler, "/api"
+	case strings.HasPrefix(path, "/api/templates"):
+		return h.TemplatesHandler, "/api"
+	case strings.HasPrefix(path, "/api/upload"):
+		return h.UploadHandler, "/api"
+	case strings.HasPrefix(path, "/api/users"):
+		return h.UserHandler, "/api"
+	case strings.HasPrefix(path, "/api/teams"):
+		return h.TeamHandler, "/api"
+	case strings.HasPrefix(path, "/api/team_memberships"):
+		return h.TeamMembershipHandler, "/api"
+	case strings.HasPrefix(path, "/api/websocket"):
+		return h.WebSocketHandler, "/api"
+	case path == "/metrics" && h.MetricsHandler != nil:
+		return h.MetricsHandler, ""
+	case strings.HasPrefix(path, "/"):
+		return h.FileHandler, ""
 	}
+	return nil, ""
 }
 
 // encodeJSON encodes v to w in JSON format. WriteErrorResponse() is called if encoding fails.
-func encodeJSON(w http.ResponseWriter, v interface{}, logger *log.Logger) {
+func encodeJSON(w http.ResponseWriter, r *http.Request, v interface{}) {
 	if err := json.NewEncoder(w).Encode(v); err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusInternalServerError, logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
+	}
+}
+
+// endpointIDFromPath returns the identifier of the endpoint targeted by a path
+// under /api/endpoints/, or an empty string for the other paths.
+func endpointIDFromPath(path string) string {
+	if !strings.HasPrefix(path, "/api/endpoints/") {
+		return ""
+	}
+	id := strings.SplitN(strings.TrimPrefix(path, "/api/endpoints/"), "/", 2)[0]
+	if _, err := strconv.Atoi(id); err != nil {
+		return ""
 	}
+	return id
 }
@@ -0,0 +1,73 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"cloudware/cloudware/api"
+)
+
+// totalCountHeader is the header of the list responses holding the number of objects
+// matching the request, regardless of the page returned.
+const totalCountHeader = "X-Total-Count"
+
+// Sort orders of the lists.
+const (
+	sortAscending  = "asc"
+	sortDescending = "desc"
+)
+
+// parseListOptions parses the limit, offset, search, sort and order parameters of a list request.
+// The lists can be sorted by identifier and by name, sortFields are the other supported fields.
+func parseListOptions(r *http.Request, sortFields ...string) (api.ListOptions, error) {
+	query := r.URL.Query()
+	options := api.ListOptions{
+		Search: query.Get("search"),
+		Sort:   query.Get("sort"),
+	}
+
+	var err error
+	options.Limit, err = parseCountQuery(query.Get("limit"))
+	if err != nil {
+		return options, err
+	}
+	options.Offset, err = parseCountQuery(query.Get("offset"))
+	if err != nil {
+		return options, err
+	}
+
+	switch query.Get("order") {
+	case "", sortAscending:
+	case sortDescending:
+		options.Descending = true
+	default:
+		return options, ErrInvalidQueryFormat
+	}
+
+	if options.Sort != "" && options.Sort != api.SortByID && options.Sort != api.SortByName {
+		supported := false
+		for _, field := range sortFields {
+			supported = supported || options.Sort == field
+		}
+		if !supported {
+			return options, ErrInvalidQueryFormat
+		}
+	}
+	return options, nil
+}
+
+func parseCountQuery(value string) (int, error) {
+	if value == "" {
+		return 0, nil
+	}
+	count, err := strconv.Atoi(value)
+	if err != nil || count < 0 {
+		return 0, ErrInvalidQueryFormat
+	}
+	return count, nil
+}
+
+// writeTotalCount writes the number of objects matching a list request to the response.
+func writeTotalCount(w http.ResponseWriter, total int) {
+	w.Header().Set(totalCountHeader, strconv.Itoa(total))
+}
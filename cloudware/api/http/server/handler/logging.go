@@ -0,0 +1,80 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/asaskevich/govalidator"
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+
+	httperror "cloudware/cloudware/api/http/server/error"
+	"cloudware/cloudware/api/http/server/security"
+	"cloudware/cloudware/api/logging"
+)
+
+// LoggingHandler represents an HTTP API handler for managing the logging of the daemon.
+type LoggingHandler struct {
+	*mux.Router
+}
+
+// NewLoggingHandler returns a new instance of LoggingHandler.
+func NewLoggingHandler(bouncer *security.RequestBouncer) *LoggingHandler {
+	h := &LoggingHandler{
+		Router: mux.NewRouter(),
+	}
+	h.Handle("/logging",
+		bouncer.AdministratorAccess(http.HandlerFunc(h.handleGetLogging))).Methods(http.MethodGet)
+	h.Handle("/logging",
+		bouncer.AdministratorAccess(http.HandlerFunc(h.handlePutLogging))).Methods(http.MethodPut)
+
+	return h
+}
+
+type (
+	loggingResponse struct {
+		Level  string `json:"Level"`
+		Format string `json:"Format"`
+	}
+
+	putLoggingRequest struct {
+		Level string `valid:"required"`
+	}
+)
+
+// handleGetLogging handles GET requests on /logging
+func (handler *LoggingHandler) handleGetLogging(w http.ResponseWriter, r *http.Request) {
+	encodeJSON(w, r, &loggingResponse{
+		Level:  logrus.GetLevel().String(),
+		Format: logging.Format(),
+	})
+}
+
+// handlePutLogging handles PUT requests on /logging
+func (handler *LoggingHandler) handlePutLogging(w http.ResponseWriter, r *http.Request) {
+	var req putLoggingRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		httperror.WriteErrorResponse(w, r, ErrInvalidJSON, http.StatusBadRequest)
+		return
+	}
+
+	_, err := govalidator.ValidateStruct(req)
+	if err != nil {
+		httperror.WriteErrorResponse(w, r, ErrInvalidRequestFormat, http.StatusBadRequest)
+		return
+	}
+
+	level, err := logrus.ParseLevel(req.Level)
+	if err != nil {
+		httperror.WriteErrorResponse(w, r, ErrInvalidRequestFormat, http.StatusBadRequest)
+		return
+	}
+
+	logging.SetLevel(level)
+	logging.FromRequest(r).Infof("Log level set to %s", level)
+
+	encodeJSON(w, r, &loggingResponse{
+		Level:  level.String(),
+		Format: logging.Format(),
+	})
+}
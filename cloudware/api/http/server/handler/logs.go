@@ -0,0 +1,604 @@
+package handler
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"cloudware/cloudware/api"
+	httperror "cloudware/cloudware/api/http/server/error"
+	"cloudware/cloudware/api/http/server/proxy"
+	"cloudware/cloudware/api/http/server/security"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	// ErrLogStreamingNotSupported defines an error raised when the response writer cannot be flushed
+	ErrLogStreamingNotSupported = api.Error("Streaming is not supported by the connection")
+	// ErrNoContainerFound defines an error raised when no container matches the log selection
+	ErrNoContainerFound = api.Error("No container found for the log selection")
+	// ErrDockerRequestFailed defines an error raised when the Docker API returned an unexpected status code
+	ErrDockerRequestFailed = api.Error("Docker API request failed")
+
+	serviceNameLabel = "com.docker.swarm.service.name"
+	stackNameLabel   = "com.docker.stack.namespace"
+
+	logStreamStdin  = 0
+	logStreamStdout = 1
+	logStreamStderr = 2
+
+	// maxLogLineSize is the size above which a log line is split in several entries.
+	maxLogLineSize = 64 * 1024
+	// logChunkSize is the size of the reads of the frames of a multiplexed log stream.
+	logChunkSize = 32 * 1024
+	// logEntryBufferSize is the number of entries of a container read ahead of the response.
+	logEntryBufferSize = 64
+)
+
+// LogsHandler represents an HTTP API handler for streaming the aggregated logs of several containers.
+type LogsHandler struct {
+	*mux.Router
+	EndpointService api.EndpointService
+	ProxyManager    *proxy.Manager
+}
+
+// NewLogsHandler returns a new instance of LogsHandler.
+func NewLogsHandler(bouncer *security.RequestBouncer) *LogsHandler {
+	h := &LogsHandler{
+		Router: mux.NewRouter(),
+	}
+	h.Handle("/{id}/logs",
+		bouncer.RestrictedAccess(http.HandlerFunc(h.handleGetLogs))).Methods(http.MethodGet)
+	return h
+}
+
+type (
+	logEntry struct {
+		ContainerID   string    `json:"ContainerId"`
+		ContainerName string    `json:"ContainerName"`
+		Stream        string    `json:"Stream"`
+		Timestamp     time.Time `json:"Timestamp"`
+		Message       string    `json:"Message"`
+	}
+
+	logErrorEvent struct {
+		ContainerID string `json:"ContainerId,omitempty"`
+		Err         string `json:"err"`
+	}
+
+	logContainer struct {
+		ID   string
+		Name string
+		TTY  bool
+	}
+
+	logOptions struct {
+		since  string
+		until  string
+		tail   string
+		follow bool
+		stdout bool
+		stderr bool
+		grep   *regexp.Regexp
+	}
+
+	containerInspectResponse struct {
+		ID     string `json:"Id"`
+		Name   string `json:"Name"`
+		Config struct {
+			Tty bool `json:"Tty"`
+		} `json:"Config"`
+	}
+
+	containerListEntry struct {
+		ID string `json:"Id"`
+	}
+)
+
+// handleGetLogs handles GET requests on /endpoints/:id/logs?containers=<id,id>|service=<name>|stack=<name>
+// The logs of the selected containers are merged by timestamp and streamed as server-sent events.
+func (handler *LogsHandler) handleGetLogs(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	endpointID, err := strconv.Atoi(id)
+	if err != nil {
+		httperror.WriteErrorResponse(w, r, err, http.StatusBadRequest)
+		return
+	}
+
+	endpoint, err := handler.EndpointService.Endpoint(api.EndpointID(endpointID))
+	if err == api.ErrEndpointNotFound {
+		httperror.WriteErrorResponse(w, r, err, http.StatusNotFound)
+		return
+	} else if err != nil {
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
+		return
+	}
+
+	securityContext, err := security.RetrieveRestrictedRequestContext(r)
+	if err != nil {
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
+		return
+	}
+
+	authorizedEndpoints, err := security.FilterEndpoints([]api.Endpoint{*endpoint}, securityContext)
+	if err != nil {
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	if len(authorizedEndpoints) == 0 {
+		httperror.WriteErrorResponse(w, r, api.ErrEndpointAccessDenied, http.StatusForbidden)
+		return
+	}
+
+	options, err := parseLogOptions(r.URL.Query())
+	if err != nil {
+		httperror.WriteErrorResponse(w, r, ErrInvalidQueryFormat, http.StatusBadRequest)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		httperror.WriteErrorResponse(w, r, ErrLogStreamingNotSupported, http.StatusInternalServerError)
+		return
+	}
+
+	client, err := handler.ProxyManager.GetClient(endpoint)
+	if err != nil {
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
+		return
+	}
+
+	containers, code, err := resolveLogContainers(r, client)
+	if err != nil {
+		httperror.WriteErrorResponse(w, r, err, code)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	stream := &eventStream{writer: w, flusher: flusher}
+	errors := streamLogs(r, client, containers, options, func(entry logEntry) {
+		stream.send("", entry)
+	})
+	for _, event := range errors {
+		stream.send("error", event)
+	}
+	stream.send("end", struct{}{})
+}
+
+// streamLogs reads the logs of the containers and calls send for each entry, it returns the errors
+// of the containers whose logs could not be read. Followed logs are sent as they arrive. Otherwise
+// the log stream of each container ends by itself and is ordered by timestamp, the streams are
+// merged by timestamp as they are read so that only a few entries of each container are buffered.
+func streamLogs(r *http.Request, client *proxy.Client, containers []logContainer, options *logOptions, send func(logEntry)) []logErrorEvent {
+	errors := make(chan logErrorEvent, len(containers))
+	streams := make([]<-chan logEntry, 0, len(containers))
+	for _, container := range containers {
+		entries := make(chan logEntry, logEntryBufferSize)
+		streams = append(streams, entries)
+		go func(container logContainer, entries chan<- logEntry) {
+			defer close(entries)
+			err := streamContainerLogs(r, client, container, options, entries)
+			if err != nil {
+				errors <- logErrorEvent{ContainerID: container.ID, Err: err.Error()}
+			}
+		}(container, entries)
+	}
+
+	if options.follow {
+		fanInLogs(streams, send)
+	} else {
+		mergeLogs(streams, send)
+	}
+
+	// Every stream is closed, the errors were all sent.
+	close(errors)
+	events := make([]logErrorEvent, 0)
+	for event := range errors {
+		events = append(events, event)
+	}
+	return events
+}
+
+// fanInLogs calls send for the entries of every stream as they arrive, until all the streams are closed.
+func fanInLogs(streams []<-chan logEntry, send func(logEntry)) {
+	entries := make(chan logEntry)
+	var wg sync.WaitGroup
+	for _, stream := range streams {
+		wg.Add(1)
+		go func(stream <-chan logEntry) {
+			defer wg.Done()
+			for entry := range stream {
+				entries <- entry
+			}
+		}(stream)
+	}
+	go func() {
+		wg.Wait()
+		close(entries)
+	}()
+
+	for entry := range entries {
+		send(entry)
+	}
+}
+
+// mergeLogs calls send for the entries of streams ordered by timestamp, in timestamp order.
+// Only the next entry of each stream is held, the entries with the same timestamp are sent
+// in the order of the streams.
+func mergeLogs(streams []<-chan logEntry, send func(logEntry)) {
+	heads := make([]*logEntry, len(streams))
+	next := func(idx int) {
+		heads[idx] = nil
+		if entry, ok := <-streams[idx]; ok {
+			heads[idx] = &entry
+		}
+	}
+	for idx := range streams {
+		next(idx)
+	}
+
+	for {
+		oldest := -1
+		for idx, head := range heads {
+			if head != nil && (oldest < 0 || head.Timestamp.Before(heads[oldest].Timestamp)) {
+				oldest = idx
+			}
+		}
+		if oldest < 0 {
+			return
+		}
+		send(*heads[oldest])
+		next(oldest)
+	}
+}
+
+func parseLogOptions(query url.Values) (*logOptions, error) {
+	options := &logOptions{
+		since:  query.Get("since"),
+		until:  query.Get("until"),
+		tail:   query.Get("tail"),
+		stdout: true,
+		stderr: true,
+	}
+
+	if options.tail == "" {
+		options.tail = "all"
+	} else if options.tail != "all" {
+		if _, err := strconv.Atoi(options.tail); err != nil {
+			return nil, err
+		}
+	}
+
+	var err error
+	if value := query.Get("follow"); value != "" {
+		options.follow, err = strconv.ParseBool(value)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if value := query.Get("stdout"); value != "" {
+		options.stdout, err = strconv.ParseBool(value)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if value := query.Get("stderr"); value != "" {
+		options.stderr, err = strconv.ParseBool(value)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if value := query.Get("grep"); value != "" {
+		options.grep, err = regexp.Compile(value)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	selections := 0
+	for _, key := range []string{"containers", "service", "stack"} {
+		if query.Get(key) != "" {
+			selections++
+		}
+	}
+	if selections != 1 {
+		return nil, ErrInvalidQueryFormat
+	}
+
+	return options, nil
+}
+
+// resolveLogContainers returns the containers matching the selection of the request.
+// Containers are retrieved through the proxy client so that the resource controls apply.
+func resolveLogContainers(r *http.Request, client *proxy.Client) ([]logContainer, int, error) {
+	query := r.URL.Query()
+
+	var identifiers []string
+	if value := query.Get("containers"); value != "" {
+		for _, identifier := range strings.Split(value, ",") {
+			if identifier = strings.TrimSpace(identifier); identifier != "" {
+				identifiers = append(identifiers, identifier)
+			}
+		}
+	} else {
+		label := stackNameLabel + "=" + query.Get("stack")
+		if query.Get("service") != "" {
+			label = serviceNameLabel + "=" + query.Get("service")
+		}
+
+		filters, err := json.Marshal(map[string][]string{"label": []string{label}})
+		if err != nil {
+			return nil, http.StatusInternalServerError, err
+		}
+
+		var list []containerListEntry
+		code, err := dockerJSONRequest(r, client, "/containers/json?all=1&filters="+url.QueryEscape(string(filters)), &list)
+		if err != nil {
+			return nil, code, err
+		}
+		for _, container := range list {
+			identifiers = append(identifiers, container.ID)
+		}
+	}
+
+	if len(identifiers) == 0 {
+		return nil, http.StatusNotFound, ErrNoContainerFound
+	}
+
+	containers := make([]logContainer, 0, len(identifiers))
+	for _, identifier := range identifiers {
+		var inspect containerInspectResponse
+		code, err := dockerJSONRequest(r, client, "/containers/"+url.PathEscape(identifier)+"/json", &inspect)
+		if err != nil {
+			return nil, code, err
+		}
+		containers = append(containers, logContainer{
+			ID:   inspect.ID,
+			Name: strings.TrimPrefix(inspect.Name, "/"),
+			TTY:  inspect.Config.Tty,
+		})
+	}
+
+	return containers, http.StatusOK, nil
+}
+
+// dockerJSONRequest executes a GET request against the Docker API and decodes the response in v.
+// The returned status code is the one that should be sent to the client in case of error.
+func dockerJSONRequest(r *http.Request, client *proxy.Client, path string, v interface{}) (int, error) {
+	request, err := http.NewRequest(http.MethodGet, path, nil)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	response, err := client.Do(request.WithContext(r.Context()))
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return response.StatusCode, dockerResponseError(response)
+	}
+
+	err = json.NewDecoder(response.Body).Decode(v)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	return http.StatusOK, nil
+}
+
+func dockerResponseError(response *http.Response) error {
+	var data struct {
+		Message string `json:"message"`
+		Err     string `json:"err"`
+	}
+
+	body, _ := ioutil.ReadAll(response.Body)
+	if json.Unmarshal(body, &data) == nil {
+		if data.Message != "" {
+			return api.Error(data.Message)
+		}
+		if data.Err != "" {
+			return api.Error(data.Err)
+		}
+	}
+	return ErrDockerRequestFailed
+}
+
+// streamContainerLogs retrieves the logs of a container and sends each line matching the options to entries.
+func streamContainerLogs(r *http.Request, client *proxy.Client, container logContainer, options *logOptions, entries chan<- logEntry) error {
+	query := url.Values{}
+	query.Set("timestamps", "1")
+	query.Set("stdout", strconv.FormatBool(options.stdout))
+	query.Set("stderr", strconv.FormatBool(options.stderr))
+	query.Set("follow", strconv.FormatBool(options.follow))
+	query.Set("tail", options.tail)
+	if options.since != "" {
+		query.Set("since", options.since)
+	}
+	if options.until != "" {
+		query.Set("until", options.until)
+	}
+
+	request, err := http.NewRequest(http.MethodGet, "/containers/"+container.ID+"/logs?"+query.Encode(), nil)
+	if err != nil {
+		return err
+	}
+
+	response, err := client.Do(request.WithContext(r.Context()))
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return dockerResponseError(response)
+	}
+
+	return demultiplexLogs(response.Body, container.TTY, func(stream int, line []byte) error {
+		entry := parseLogLine(line)
+		if options.grep != nil && !options.grep.MatchString(entry.Message) {
+			return nil
+		}
+
+		entry.ContainerID = container.ID
+		entry.ContainerName = container.Name
+		entry.Stream = "stdout"
+		if stream == logStreamStderr {
+			entry.Stream = "stderr"
+		}
+
+		select {
+		case entries <- entry:
+			return nil
+		case <-r.Context().Done():
+			return r.Context().Err()
+		}
+	})
+}
+
+// demultiplexLogs reads a Docker log stream and calls emit for each line, the lines longer than
+// maxLogLineSize are split. When the container does not use a TTY, the stdout and stderr streams
+// are multiplexed: each frame starts with an 8 bytes header containing the stream type and the frame size.
+// https://docs.docker.com/engine/api/v1.30/#operation/ContainerAttach
+func demultiplexLogs(r io.Reader, tty bool, emit func(stream int, line []byte) error) error {
+	if tty {
+		reader := bufio.NewReaderSize(r, maxLogLineSize)
+		for {
+			line, _, err := reader.ReadLine()
+			if err == io.EOF {
+				return nil
+			} else if err != nil {
+				return err
+			}
+			if err := emit(logStreamStdout, line); err != nil {
+				return err
+			}
+		}
+	}
+
+	pending := map[int][]byte{}
+	header := make([]byte, 8)
+	chunk := make([]byte, logChunkSize)
+	for {
+		_, err := io.ReadFull(r, header)
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+
+		stream := int(header[0])
+		remaining := int(binary.BigEndian.Uint32(header[4:]))
+		for remaining > 0 {
+			size := len(chunk)
+			if remaining < size {
+				size = remaining
+			}
+			_, err = io.ReadFull(r, chunk[:size])
+			if err != nil {
+				return err
+			}
+			remaining -= size
+
+			if stream != logStreamStdout && stream != logStreamStderr {
+				continue
+			}
+			pending[stream], err = emitLines(append(pending[stream], chunk[:size]...), stream, emit)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, stream := range []int{logStreamStdout, logStreamStderr} {
+		if len(pending[stream]) > 0 {
+			if err := emit(stream, pending[stream]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// emitLines calls emit for each complete line of buffer, or for its first maxLogLineSize bytes when
+// a line is longer, and returns the rest of the buffer.
+func emitLines(buffer []byte, stream int, emit func(stream int, line []byte) error) ([]byte, error) {
+	for {
+		index := bytes.IndexByte(buffer, '\n')
+		if index < 0 {
+			if len(buffer) < maxLogLineSize {
+				// The rest is copied so that the buffer of the emitted lines is not retained.
+				return append([]byte(nil), buffer...), nil
+			}
+			index = maxLogLineSize
+		}
+		line := buffer[:index]
+		if index < len(buffer) && buffer[index] == '\n' {
+			buffer = buffer[index+1:]
+		} else {
+			buffer = buffer[index:]
+		}
+		if err := emit(stream, bytes.TrimRight(line, "\r")); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// parseLogLine splits a log line retrieved with the timestamps option into its timestamp and message.
+func parseLogLine(line []byte) logEntry {
+	entry := logEntry{Message: string(line)}
+
+	index := bytes.IndexByte(line, ' ')
+	if index < 0 {
+		return entry
+	}
+
+	timestamp, err := time.Parse(time.RFC3339Nano, string(line[:index]))
+	if err != nil {
+		return entry
+	}
+
+	entry.Timestamp = timestamp
+	entry.Message = string(line[index+1:])
+	return entry
+}
+
+// eventStream writes server-sent events to a response.
+type eventStream struct {
+	writer  io.Writer
+	flusher http.Flusher
+}
+
+func (stream *eventStream) send(event string, v interface{}) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return
+	}
+
+	if event != "" {
+		fmt.Fprintf(stream.writer, "event: %s\n", event)
+	}
+	fmt.Fprintf(stream.writer, "data: %s\n\n", data)
+	stream.flusher.Flush()
+}
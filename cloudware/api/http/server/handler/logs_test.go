@@ -0,0 +1,148 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net/url"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type emittedLine struct {
+	stream int
+	line   string
+}
+
+func frame(stream int, content string) []byte {
+	header := make([]byte, 8)
+	header[0] = byte(stream)
+	binary.BigEndian.PutUint32(header[4:], uint32(len(content)))
+	return append(header, content...)
+}
+
+func demultiplex(t *testing.T, data []byte, tty bool) []emittedLine {
+	var lines []emittedLine
+	err := demultiplexLogs(bytes.NewReader(data), tty, func(stream int, line []byte) error {
+		lines = append(lines, emittedLine{stream, string(line)})
+		return nil
+	})
+	require.NoError(t, err)
+	return lines
+}
+
+func TestDemultiplexLogs(t *testing.T) {
+	var data []byte
+	data = append(data, frame(logStreamStdout, "first\nsec")...)
+	data = append(data, frame(logStreamStderr, "error\r\n")...)
+	data = append(data, frame(logStreamStdin, "ignored\n")...)
+	data = append(data, frame(logStreamStdout, "ond\nlast")...)
+
+	assert.Equal(t, []emittedLine{
+		{logStreamStdout, "first"},
+		{logStreamStderr, "error"},
+		{logStreamStdout, "second"},
+		{logStreamStdout, "last"},
+	}, demultiplex(t, data, false), "the lines are split across frames per stream")
+
+	assert.Equal(t, []emittedLine{
+		{logStreamStdout, "first"},
+		{logStreamStdout, "second"},
+	}, demultiplex(t, []byte("first\r\nsecond"), true), "the TTY streams are not multiplexed")
+
+	long := strings.Repeat("x", maxLogLineSize+10)
+	lines := demultiplex(t, frame(logStreamStdout, long+"\n"), false)
+	require.Len(t, lines, 2, "the long lines are split")
+	assert.Equal(t, long, lines[0].line+lines[1].line)
+	lines = demultiplex(t, []byte(long+"\n"), true)
+	require.Len(t, lines, 2, "the long lines of a TTY are split")
+	assert.Equal(t, long, lines[0].line+lines[1].line)
+
+	err := demultiplexLogs(bytes.NewReader(frame(logStreamStdout, "truncated")[:12]), false, func(int, []byte) error { return nil })
+	assert.Error(t, err, "a truncated frame is an error")
+}
+
+func TestParseLogLine(t *testing.T) {
+	entry := parseLogLine([]byte("2018-03-01T12:00:00.123456789Z listening on :80"))
+	assert.Equal(t, "listening on :80", entry.Message)
+	assert.True(t, entry.Timestamp.Equal(time.Date(2018, 3, 1, 12, 0, 0, 123456789, time.UTC)))
+
+	entry = parseLogLine([]byte("no timestamp"))
+	assert.Equal(t, "no timestamp", entry.Message)
+	assert.True(t, entry.Timestamp.IsZero())
+}
+
+func logStream(entries ...logEntry) <-chan logEntry {
+	stream := make(chan logEntry, len(entries))
+	for _, entry := range entries {
+		stream <- entry
+	}
+	close(stream)
+	return stream
+}
+
+func TestMergeLogs(t *testing.T) {
+	at := func(seconds int, message string) logEntry {
+		return logEntry{Timestamp: time.Unix(int64(seconds), 0), Message: message}
+	}
+
+	var messages []string
+	mergeLogs([]<-chan logEntry{
+		logStream(at(1, "a1"), at(4, "a4"), at(5, "a5")),
+		logStream(),
+		logStream(at(2, "b2"), at(4, "b4"), at(6, "b6")),
+	}, func(entry logEntry) {
+		messages = append(messages, entry.Message)
+	})
+	assert.Equal(t, []string{"a1", "b2", "a4", "b4", "a5", "b6"}, messages)
+
+	messages = nil
+	fanInLogs([]<-chan logEntry{logStream(at(2, "a")), logStream(at(1, "b"))}, func(entry logEntry) {
+		messages = append(messages, entry.Message)
+	})
+	sort.Strings(messages)
+	assert.Equal(t, []string{"a", "b"}, messages)
+}
+
+func TestParseLogOptions(t *testing.T) {
+	options, err := parseLogOptions(url.Values{"service": {"web"}, "follow": {"1"}, "stderr": {"false"}, "grep": {"err.r"}})
+	require.NoError(t, err)
+	assert.Equal(t, "all", options.tail)
+	assert.True(t, options.follow)
+	assert.True(t, options.stdout)
+	assert.False(t, options.stderr)
+	assert.True(t, options.grep.MatchString("error"))
+
+	for _, query := range []url.Values{
+		{},
+		{"service": {"web"}, "stack": {"web"}},
+		{"containers": {"web"}, "tail": {"last"}},
+		{"containers": {"web"}, "grep": {"("}},
+	} {
+		_, err := parseLogOptions(query)
+		assert.Error(t, err, "%v", query)
+	}
+}
+
+func TestLogsRouting(t *testing.T) {
+	h := &Handler{
+		LogsHandler:     &LogsHandler{},
+		DockerHandler:   &DockerHandler{},
+		StackHandler:    &StackHandler{},
+		EndpointHandler: &EndpointHandler{},
+	}
+
+	for path, expected := range map[string]interface{}{
+		"/api/endpoints/1/logs":                      h.LogsHandler,
+		"/api/endpoints/1/docker/containers/id/logs": h.DockerHandler,
+		"/api/endpoints/1/stacks/web/logs":           h.StackHandler,
+		"/api/endpoints/logs":                        h.EndpointHandler,
+	} {
+		handler, _ := h.subhandler(path)
+		assert.Equal(t, expected, handler, path)
+	}
+}
@@ -0,0 +1,102 @@
+package handler
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// statusRecorder records the status code of a response for the metrics.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func newStatusRecorder(w http.ResponseWriter) *statusRecorder {
+	return &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+}
+
+func (recorder *statusRecorder) WriteHeader(status int) {
+	recorder.status = status
+	recorder.ResponseWriter.WriteHeader(status)
+}
+
+// Flush allows the streamed responses, such as the logs, to be flushed through the recorder.
+func (recorder *statusRecorder) Flush() {
+	if flusher, ok := recorder.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Hijack allows the websocket connections to be upgraded through the recorder.
+func (recorder *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := recorder.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("the response writer does not support hijacking")
+	}
+	recorder.status = http.StatusSwitchingProtocols
+	return hijacker.Hijack()
+}
+
+// handlerName returns the name of the handler serving a path, used as a label of the metrics.
+func handlerName(path string) string {
+	if !strings.HasPrefix(path, "/api/") {
+		return "file"
+	}
+
+	name := strings.SplitN(strings.TrimPrefix(path, "/api/"), "/", 2)[0]
+	switch name {
+	case "endpoints":
+		switch {
+		case strings.Contains(path, "/docker/"):
+			return "docker"
+		case strings.HasSuffix(path, "/logs"):
+			return "logs"
+		case strings.Contains(path, "/stacks"):
+			return "stacks"
+		}
+	case "restore":
+		return "backup"
+	case "import":
+		return "export"
+	}
+
+	if _, ok := apiHandlerNames[name]; !ok {
+		return "unknown"
+	}
+	return name
+}
+
+// apiHandlerNames lists the first segment of the API paths, the other paths are
+// not used as label values to keep the number of series bounded.
+var apiHandlerNames = map[string]struct{}{
+	"apply": {}, "auth": {}, "backup": {}, "containers": {}, "services": {}, "volumes": {}, "images": {},
+	"dockerhub": {}, "endpoints": {}, "export": {}, "pki": {}, "recordings": {}, "registries": {},
+	"resource_controls": {}, "settings": {}, "status": {}, "templates": {}, "upload": {}, "users": {},
+	"teams": {}, "team_memberships": {}, "websocket": {},
+}
+
+// dockerRouteGroup returns the kind of resource targeted by a request to the Docker API,
+// such as containers, used as a label of the metrics.
+func dockerRouteGroup(path string) string {
+	segments := strings.Split(strings.TrimPrefix(path, "/"), "/")
+	// The path may be prefixed by the version of the Docker API, e.g. /v1.30/containers/json.
+	if len(segments) > 1 && strings.HasPrefix(segments[0], "v1.") {
+		segments = segments[1:]
+	}
+
+	group := segments[0]
+	if _, ok := dockerRouteGroups[group]; !ok {
+		return "other"
+	}
+	return group
+}
+
+var dockerRouteGroups = map[string]struct{}{
+	"_ping": {}, "auth": {}, "build": {}, "commit": {}, "configs": {}, "containers": {}, "distribution": {},
+	"events": {}, "exec": {}, "images": {}, "info": {}, "networks": {}, "nodes": {}, "plugins": {},
+	"secrets": {}, "services": {}, "session": {}, "swarm": {}, "system": {}, "tasks": {}, "version": {},
+	"volumes": {},
+}
@@ -0,0 +1,752 @@
+package handler
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+
+	"cloudware/cloudware/api"
+	httperror "cloudware/cloudware/api/http/server/error"
+	"cloudware/cloudware/api/http/server/openapi"
+)
+
+// The access levels of the operations, matching the checks of the request bouncer.
+const (
+	accessPublic        = "public"
+	accessAuthenticated = "authenticated"
+	accessRestricted    = "restricted"
+	accessAdministrator = "administrator"
+)
+
+const (
+	mediaTypeJSON      = "application/json"
+	mediaTypeMultipart = "multipart/form-data"
+)
+
+// apiDocument describes every route served by the handlers, it is served on /api/openapi.json
+// and the requests are validated against it before being routed.
+var apiDocument = newAPIDocument()
+
+// findOperation returns the operation of the API document matching a request along with the values
+// of its path parameters. It returns false, after writing the error response, when no operation of
+// the API matches the request. The operation is nil for the requests outside of the API, which are
+// left to the handlers, as well as for the ones proxied to the Docker API with another method.
+func findOperation(w http.ResponseWriter, r *http.Request) (*openapi.Operation, map[string]string, bool) {
+	item, params := apiDocument.FindPath(r.URL.Path)
+	if item == nil {
+		if strings.HasPrefix(r.URL.Path, "/api/") {
+			httperror.WriteErrorResponse(w, r, ErrRouteNotFound, http.StatusNotFound)
+			return nil, nil, false
+		}
+		return nil, nil, true
+	}
+
+	operation := item.Operation(r.Method)
+	if operation == nil {
+		if item.Prefix {
+			return nil, nil, true
+		}
+		methods := make([]string, 0, len(item.Operations()))
+		for method := range item.Operations() {
+			methods = append(methods, method)
+		}
+		sort.Strings(methods)
+		w.Header().Set("Allow", strings.Join(methods, ", "))
+		httperror.WriteErrorResponse(w, r, ErrMethodNotAllowed, http.StatusMethodNotAllowed)
+		return nil, nil, false
+	}
+	return operation, params, true
+}
+
+// authenticate returns next behind the access check of an operation, so that the parameters and the body
+// of a request are only read once its user is authenticated. The subhandlers still check the access of
+// their routes. The requests without operation are passed as is.
+func (h *Handler) authenticate(operation *openapi.Operation, next http.Handler) http.Handler {
+	if operation == nil || operation.Access == accessPublic {
+		return next
+	}
+
+	webSocket := len(operation.Tags) > 0 && operation.Tags[0] == "websocket"
+	switch {
+	case webSocket && operation.Access == accessAdministrator:
+		return h.RequestBouncer.AdministratorWebSocketAccess(next)
+	case webSocket:
+		return h.RequestBouncer.WebSocketAccess(next)
+	case operation.Access == accessAdministrator:
+		return h.RequestBouncer.AdministratorAccess(next)
+	}
+	return h.RequestBouncer.AuthenticatedAccess(next)
+}
+
+// validateOperation validates the parameters and the body of a request against its operation. It returns
+// false, after writing the error response, when the request does not match the schema of the operation.
+func validateOperation(w http.ResponseWriter, r *http.Request, operation *openapi.Operation, params map[string]string) bool {
+	fieldErrors, err := apiDocument.ValidateRequest(w, operation, params, r)
+	if err == openapi.ErrInvalidBody {
+		httperror.WriteErrorResponse(w, r, ErrInvalidJSON, http.StatusBadRequest)
+		return false
+	} else if err == openapi.ErrBodyTooLarge {
+		httperror.WriteErrorResponse(w, r, ErrRequestBodyTooLarge, http.StatusRequestEntityTooLarge)
+		return false
+	} else if err != nil {
+		httperror.WriteErrorResponse(w, r, err, http.StatusBadRequest)
+		return false
+	}
+
+	if len(fieldErrors) > 0 {
+		details := make([]httperror.FieldError, len(fieldErrors))
+		for idx, fieldError := range fieldErrors {
+			details[idx] = httperror.FieldError{Field: fieldError.Field, Message: fieldError.Message}
+		}
+		httperror.WriteErrorDetailsResponse(w, r, ErrInvalidRequestFormat, http.StatusBadRequest, details)
+		return false
+	}
+	return true
+}
+
+// handleGetOpenAPI handles GET requests on /api/openapi.json
+func handleGetOpenAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", mediaTypeJSON)
+	encodeJSON(w, r, apiDocument)
+}
+
+func newOperation(id, summary, tag, access string) *openapi.Operation {
+	operation := &openapi.Operation{
+		OperationID: id,
+		Summary:     summary,
+		Tags:        []string{tag},
+		Access:      access,
+		Responses: map[string]*openapi.Response{
+			"200": {Description: "Success"},
+			"default": {
+				Description: "Error",
+				Content:     map[string]*openapi.MediaType{mediaTypeJSON: {Schema: openapi.Ref("Error")}},
+			},
+		},
+	}
+	if access != accessPublic {
+		operation.Security = []openapi.SecurityRequirement{{"jwt": {}}}
+	}
+	return operation
+}
+
+func withParameters(operation *openapi.Operation, parameters ...*openapi.Parameter) *openapi.Operation {
+	operation.Parameters = append(operation.Parameters, parameters...)
+	return operation
+}
+
+// withListParameters describes the pagination, search and sort parameters of a list operation,
+// sortFields are the fields the list can be sorted by in addition to the identifier and the name.
+func withListParameters(operation *openapi.Operation, sortFields ...interface{}) *openapi.Operation {
+	fields := append([]interface{}{api.SortByID, api.SortByName}, sortFields...)
+	operation.Responses["200"].Headers = map[string]*openapi.Header{
+		totalCountHeader: {Description: "Number of objects matching the request, regardless of the page", Schema: integer()},
+	}
+	return withParameters(operation,
+		queryParameter("limit", "Maximum number of objects to return, all of them are returned when 0", false, minimum(integer(), 0)),
+		queryParameter("offset", "Number of objects to skip", false, minimum(integer(), 0)),
+		queryParameter("search", "Only return the objects whose name contains this text, ignoring the case", false, str()),
+		queryParameter("sort", "Field the objects are sorted by", false, enum(str(), fields...)),
+		queryParameter("order", "Sort order", false, enum(str(), sortAscending, sortDescending)))
+}
+
+func withJSONBody(operation *openapi.Operation, schema string) *openapi.Operation {
+	operation.RequestBody = &openapi.RequestBody{
+		Required: true,
+		Content:  map[string]*openapi.MediaType{mediaTypeJSON: {Schema: openapi.Ref(schema)}},
+	}
+	return operation
+}
+
+// withMaxBodySize raises the maximum size of the JSON body read by the validation.
+func withMaxBodySize(operation *openapi.Operation, maxSize int64) *openapi.Operation {
+	operation.RequestBody.MaxSize = maxSize
+	return operation
+}
+
+func withBody(operation *openapi.Operation, body *openapi.RequestBody) *openapi.Operation {
+	operation.RequestBody = body
+	return operation
+}
+
+func idParameter(name, description string) *openapi.Parameter {
+	return &openapi.Parameter{Name: name, In: openapi.InPath, Description: description, Required: true, Schema: integer()}
+}
+
+func pathParameter(name, description string, schema *openapi.Schema) *openapi.Parameter {
+	return &openapi.Parameter{Name: name, In: openapi.InPath, Description: description, Required: true, Schema: schema}
+}
+
+func queryParameter(name, description string, required bool, schema *openapi.Schema) *openapi.Parameter {
+	return &openapi.Parameter{Name: name, In: openapi.InQuery, Description: description, Required: required, Schema: schema}
+}
+
+func str() *openapi.Schema {
+	return &openapi.Schema{Type: openapi.TypeString}
+}
+
+func integer() *openapi.Schema {
+	return &openapi.Schema{Type: openapi.TypeInteger}
+}
+
+func boolean() *openapi.Schema {
+	return &openapi.Schema{Type: openapi.TypeBoolean}
+}
+
+func arrayOf(items *openapi.Schema) *openapi.Schema {
+	return &openapi.Schema{Type: openapi.TypeArray, Items: items}
+}
+
+func enum(schema *openapi.Schema, values ...interface{}) *openapi.Schema {
+	schema.Enum = values
+	return schema
+}
+
+func minimum(schema *openapi.Schema, value float64) *openapi.Schema {
+	schema.Minimum = openapi.Min(value)
+	return schema
+}
+
+func describe(schema *openapi.Schema, description string) *openapi.Schema {
+	schema.Description = description
+	return schema
+}
+
+func object(required []string, properties map[string]*openapi.Schema) *openapi.Schema {
+	return &openapi.Schema{Type: openapi.TypeObject, Required: required, Properties: properties}
+}
+
+func multipartBody(properties map[string]*openapi.Schema, required ...string) *openapi.RequestBody {
+	return &openapi.RequestBody{
+		Required: true,
+		Content: map[string]*openapi.MediaType{
+			mediaTypeMultipart: {Schema: object(required, properties)},
+		},
+	}
+}
+
+func upload() *openapi.Schema {
+	return &openapi.Schema{Type: openapi.TypeString, Format: "binary"}
+}
+
+func newAPIDocument() *openapi.Document {
+	userRole := enum(integer(), int(api.AdministratorRole), int(api.StandardUserRole))
+	membershipRole := enum(integer(), int(api.TeamLeader), int(api.TeamMember))
+
+	schemas := map[string]*openapi.Schema{
+		"Error": object([]string{"code", "status", "message"}, map[string]*openapi.Schema{
+			"err":     describe(str(), "Same as message, kept for backward compatibility"),
+			"code":    describe(str(), "Stable machine-readable code of the error"),
+			"status":  describe(integer(), "HTTP status of the response"),
+			"message": str(),
+			"details": describe(&openapi.Schema{}, "Invalid fields of the request, or the body of the error returned by the Docker API"),
+		}),
+		"Pair": object(nil, map[string]*openapi.Schema{
+			"name":  str(),
+			"value": str(),
+		}),
+		"TLSConfiguration": object(nil, map[string]*openapi.Schema{
+			"TLS":           boolean(),
+			"TLSSkipVerify": boolean(),
+			"TLSCACert":     str(),
+			"TLSCert":       str(),
+			"TLSKey":        str(),
+		}),
+		"LDAPSettings": object(nil, map[string]*openapi.Schema{
+			"ReaderDN":  str(),
+			"Password":  describe(str(), "The current password is kept when omitted, it is cleared when empty"),
+			"URL":       str(),
+			"TLSConfig": openapi.Ref("TLSConfiguration"),
+			"StartTLS":  boolean(),
+			"SearchSettings": arrayOf(object(nil, map[string]*openapi.Schema{
+				"BaseDN":            str(),
+				"Filter":            str(),
+				"UserNameAttribute": str(),
+			})),
+		}),
+		"BackupSettings": object(nil, map[string]*openapi.Schema{
+			"Schedule":       describe(str(), "Cron expression, the scheduled backups are disabled when empty"),
+			"RetentionCount": &openapi.Schema{Type: openapi.TypeInteger, Minimum: openapi.Min(0)},
+			"Password":       str(),
+			"StorageType":    describe(enum(integer(), 0, int(api.BackupStorageLocal), int(api.BackupStorageS3)), "Required when a schedule is set"),
+			"LocalPath":      str(),
+			"S3": object(nil, map[string]*openapi.Schema{
+				"Endpoint":        str(),
+				"Region":          str(),
+				"Bucket":          str(),
+				"Prefix":          str(),
+				"AccessKeyID":     str(),
+				"SecretAccessKey": str(),
+			}),
+		}),
+		"AuthenticationRequest": object([]string{"Username", "Password"}, map[string]*openapi.Schema{
+			"Username": str(),
+			"Password": str(),
+		}),
+		"AccessUpdateRequest": object(nil, map[string]*openapi.Schema{
+			"AuthorizedUsers": arrayOf(integer()),
+			"AuthorizedTeams": arrayOf(integer()),
+		}),
+		"DockerHubUpdateRequest": object(nil, map[string]*openapi.Schema{
+			"Authentication": boolean(),
+			"Username":       str(),
+			"Password":       describe(str(), "The current password is kept when omitted, it is cleared when empty"),
+		}),
+		"EndpointCreateRequest": object([]string{"Name", "URL"}, map[string]*openapi.Schema{
+			"Name":                str(),
+			"URL":                 str(),
+			"PublicURL":           str(),
+			"TLS":                 boolean(),
+			"TLSSkipVerify":       boolean(),
+			"TLSSkipClientVerify": boolean(),
+			"RecordSessions":      boolean(),
+		}),
+		"EndpointUpdateRequest": object(nil, map[string]*openapi.Schema{
+			"Name":                str(),
+			"URL":                 str(),
+			"PublicURL":           str(),
+			"TLS":                 boolean(),
+			"TLSSkipVerify":       boolean(),
+			"TLSSkipClientVerify": boolean(),
+			"RecordSessions":      &openapi.Schema{Type: openapi.TypeBoolean, Nullable: true},
+		}),
+		"LoggingUpdateRequest": object([]string{"Level"}, map[string]*openapi.Schema{
+			"Level": enum(str(), "debug", "info", "warning", "error", "fatal", "panic"),
+		}),
+		"ServerCertificatesRequest": object([]string{"Hosts"}, map[string]*openapi.Schema{
+			"Hosts":        arrayOf(str()),
+			"ValidityDays": &openapi.Schema{Type: openapi.TypeInteger, Minimum: openapi.Min(0)},
+		}),
+		"RegistryRequest": object([]string{"Name", "URL"}, map[string]*openapi.Schema{
+			"Name":           str(),
+			"URL":            str(),
+			"Authentication": boolean(),
+			"Username":       str(),
+			"Password":       describe(str(), "The current password is kept when omitted from an update, it is cleared when empty"),
+		}),
+		"ResourceControlCreateRequest": object([]string{"ResourceID", "Type"}, map[string]*openapi.Schema{
+			"ResourceID":         str(),
+			"Type":               enum(str(), "container", "service", "volume", "network", "secret", "stack", "config"),
+			"AdministratorsOnly": boolean(),
+			"Users":              arrayOf(integer()),
+			"Teams":              arrayOf(integer()),
+			"SubResourceIDs":     arrayOf(str()),
+		}),
+		"ResourceControlUpdateRequest": object(nil, map[string]*openapi.Schema{
+			"AdministratorsOnly": boolean(),
+			"Users":              arrayOf(integer()),
+			"Teams":              arrayOf(integer()),
+		}),
+		"SettingsUpdateRequest": object([]string{"TemplatesURL", "AuthenticationMethod"}, map[string]*openapi.Schema{
+			"TemplatesURL":                       str(),
+			"LogoURL":                            str(),
+			"BlackListedLabels":                  arrayOf(openapi.Ref("Pair")),
+			"DisplayDonationHeader":              boolean(),
+			"DisplayExternalContributors":        boolean(),
+			"AuthenticationMethod":               enum(integer(), int(api.AuthenticationInternal), int(api.AuthenticationLDAP)),
+			"LDAPSettings":                       openapi.Ref("LDAPSettings"),
+			"AllowBindMountsForRegularUsers":     boolean(),
+			"AllowPrivilegedModeForRegularUsers": boolean(),
+			"SessionRecordingRetention":          describe(&openapi.Schema{Type: openapi.TypeInteger, Minimum: openapi.Min(0)}, "Number of days, the recordings are kept forever when 0"),
+			"BackupSettings":                     openapi.Ref("BackupSettings"),
+		}),
+		"LDAPCheckRequest": object(nil, map[string]*openapi.Schema{
+			"LDAPSettings": openapi.Ref("LDAPSettings"),
+		}),
+		"StackCreateRequest": object([]string{"Name", "SwarmID"}, map[string]*openapi.Schema{
+			"Name":             str(),
+			"SwarmID":          str(),
+			"StackFileContent": describe(str(), "Required by the string method"),
+			"GitRepository":    describe(str(), "Required by the repository method"),
+			"PathInRepository": str(),
+			"Env":              arrayOf(openapi.Ref("Pair")),
+		}),
+		"StackUpdateRequest": object([]string{"StackFileContent"}, map[string]*openapi.Schema{
+			"StackFileContent": str(),
+			"Env":              describe(arrayOf(openapi.Ref("Pair")), "The current value of a variable is kept when its value is omitted, it is cleared when empty"),
+		}),
+		"TemplateParameter": object([]string{"Name"}, map[string]*openapi.Schema{
+			"Name":        str(),
+			"Label":       str(),
+			"Description": str(),
+			"Default":     str(),
+			"Required":    boolean(),
+			"Options":     describe(arrayOf(str()), "Values the parameter can take, any value is accepted when empty"),
+		}),
+		"TemplateRequest": object([]string{"Type", "Title"}, map[string]*openapi.Schema{
+			"Type":             describe(enum(integer(), int(api.ContainerTemplate), int(api.StackTemplate)), "1 for a container, 2 for a stack"),
+			"Title":            str(),
+			"Description":      str(),
+			"Note":             str(),
+			"Categories":       arrayOf(str()),
+			"Platform":         str(),
+			"Logo":             str(),
+			"Image":            describe(str(), "Required by the container templates"),
+			"Command":          str(),
+			"Ports":            arrayOf(str()),
+			"Volumes":          arrayOf(str()),
+			"Labels":           arrayOf(openapi.Ref("Pair")),
+			"RestartPolicy":    str(),
+			"StackFileContent": describe(str(), "Required by the stack templates without repository"),
+			"Repository": object(nil, map[string]*openapi.Schema{
+				"URL":       str(),
+				"StackFile": str(),
+			}),
+			"Parameters":      arrayOf(openapi.Ref("TemplateParameter")),
+			"AuthorizedTeams": describe(arrayOf(integer()), "Teams allowed to see the template, every user sees it when empty"),
+		}),
+		"TemplateImportRequest": object(nil, map[string]*openapi.Schema{
+			"URL":              describe(str(), "URL of the templates document, exclusive with GitRepository"),
+			"GitRepository":    str(),
+			"PathInRepository": str(),
+		}),
+		"TemplateDeployRequest": object(nil, map[string]*openapi.Schema{
+			"Name":       describe(str(), "Required by the stack templates"),
+			"SwarmID":    describe(str(), "Identifier of the swarm of the endpoint, required by the stack templates"),
+			"Parameters": arrayOf(openapi.Ref("Pair")),
+		}),
+		"TeamCreateRequest": object([]string{"Name"}, map[string]*openapi.Schema{
+			"Name": str(),
+		}),
+		"TeamUpdateRequest": object(nil, map[string]*openapi.Schema{
+			"Name": str(),
+		}),
+		"TeamMembershipRequest": object([]string{"UserID", "TeamID", "Role"}, map[string]*openapi.Schema{
+			"UserID": integer(),
+			"TeamID": integer(),
+			"Role":   membershipRole,
+		}),
+		"UserCreateRequest": object([]string{"Username", "Role"}, map[string]*openapi.Schema{
+			"Username": str(),
+			"Password": str(),
+			"Role":     userRole,
+		}),
+		"UserUpdateRequest": object(nil, map[string]*openapi.Schema{
+			"Password": str(),
+			"Role":     describe(enum(integer(), 0, int(api.AdministratorRole), int(api.StandardUserRole)), "The role is unchanged when 0"),
+		}),
+		"UserPasswordRequest": object([]string{"Password"}, map[string]*openapi.Schema{
+			"Password": str(),
+		}),
+		"ImportDocument": object([]string{"Version"}, map[string]*openapi.Schema{
+			"Version":          integer(),
+			"APIVersion":       str(),
+			"CreatedAt":        integer(),
+			"SecretsOmitted":   boolean(),
+			"Users":            arrayOf(object(nil, nil)),
+			"Teams":            arrayOf(object(nil, nil)),
+			"TeamMemberships":  arrayOf(object(nil, nil)),
+			"Endpoints":        arrayOf(object(nil, nil)),
+			"Registries":       arrayOf(object(nil, nil)),
+			"ResourceControls": arrayOf(object(nil, nil)),
+			"Stacks":           arrayOf(object(nil, nil)),
+		}),
+	}
+
+	dryRun := queryParameter("dryRun", "Only report the changes", false, boolean())
+	repositoryParameter := queryParameter("repository", "Name of the repository", true, str())
+	checkRegistryParameter := queryParameter("check", "Check that the registry is reachable with the credentials", false, boolean())
+	endpointID := idParameter("id", "Identifier of the endpoint")
+
+	dockerProxy := func(method string) *openapi.Operation {
+		return withParameters(newOperation("DockerProxy"+method, "Proxy a request to the Docker API of an endpoint", "docker", accessAuthenticated),
+			endpointID, pathParameter("path", "Path of the Docker API request", str()))
+	}
+	websocket := func(id, summary, access string, parameters ...*openapi.Parameter) *openapi.Operation {
+		operation := withParameters(newOperation(id, summary, "websocket", access), parameters...)
+		operation.Parameters = append(operation.Parameters,
+			queryParameter("token", "JWT, browsers cannot set the Authorization header on websocket connections", false, str()))
+		operation.Responses["101"] = &openapi.Response{Description: "Switching Protocols"}
+		return operation
+	}
+
+	paths := map[string]*openapi.PathItem{
+		"/api/openapi.json": {
+			Get: newOperation("OpenAPI", "Retrieve the OpenAPI document of the API", "status", accessPublic),
+		},
+		"/api/auth": {
+			Post: withJSONBody(newOperation("Authenticate", "Authenticate a user and return a JWT", "auth", accessPublic), "AuthenticationRequest"),
+		},
+		"/api/apply": {
+			Post: withBody(withParameters(newOperation("Apply", "Apply a configuration document", "configuration", accessAdministrator),
+				dryRun, queryParameter("prune", "Delete the entities missing from the document", false, boolean())),
+				&openapi.RequestBody{Required: true, Content: map[string]*openapi.MediaType{
+					mediaTypeJSON:        {},
+					"application/x-yaml": {},
+				}}),
+		},
+		"/api/backup": {
+			Get: withParameters(newOperation("Backup", "Download a backup archive", "backup", accessAdministrator),
+				&openapi.Parameter{Name: backupPasswordHeader, In: openapi.InHeader, Description: "Password encrypting the archive", Schema: str()}),
+		},
+		"/api/backup/status": {
+			Get: newOperation("BackupStatus", "Retrieve the status of the scheduled backups", "backup", accessAdministrator),
+		},
+		"/api/restore": {
+			Post: withBody(newOperation("Restore", "Restore a backup archive", "backup", accessAdministrator),
+				multipartBody(map[string]*openapi.Schema{"file": upload(), "Password": str()}, "file")),
+		},
+		"/api/containers": {
+			Get: newOperation("ContainerList", "List the containers of all the endpoints", "aggregate", accessRestricted),
+		},
+		"/api/services": {
+			Get: newOperation("ServiceList", "List the services of all the endpoints", "aggregate", accessRestricted),
+		},
+		"/api/volumes": {
+			Get: newOperation("VolumeList", "List the volumes of all the endpoints", "aggregate", accessRestricted),
+		},
+		"/api/images": {
+			Get: newOperation("ImageList", "List the images of all the endpoints", "aggregate", accessRestricted),
+		},
+		"/api/dockerhub": {
+			Get: newOperation("DockerHubInspect", "Retrieve the DockerHub configuration", "dockerhub", accessAuthenticated),
+			Put: withJSONBody(newOperation("DockerHubUpdate", "Update the DockerHub configuration", "dockerhub", accessAdministrator), "DockerHubUpdateRequest"),
+		},
+		"/api/endpoints": {
+			Get: withParameters(withListParameters(newOperation("EndpointList", "List the endpoints", "endpoints", accessRestricted), api.SortByURL),
+				queryParameter("url", "Only list the endpoints with this URL", false, str())),
+			Post: withJSONBody(newOperation("EndpointCreate", "Create an endpoint", "endpoints", accessAdministrator), "EndpointCreateRequest"),
+		},
+		"/api/endpoints/{id}": {
+			Get:    withParameters(newOperation("EndpointInspect", "Retrieve an endpoint", "endpoints", accessAdministrator), endpointID),
+			Put:    withJSONBody(withParameters(newOperation("EndpointUpdate", "Update an endpoint", "endpoints", accessAdministrator), endpointID), "EndpointUpdateRequest"),
+			Delete: withParameters(newOperation("EndpointDelete", "Delete an endpoint", "endpoints", accessAdministrator), endpointID),
+		},
+		"/api/endpoints/{id}/access": {
+			Put: withJSONBody(withParameters(newOperation("EndpointAccessUpdate", "Update the users and teams authorized to use an endpoint", "endpoints", accessAdministrator), endpointID), "AccessUpdateRequest"),
+		},
+		"/api/endpoints/{id}/docker/{path}": {
+			Prefix: true,
+			Get:    dockerProxy("Get"),
+			Put:    dockerProxy("Put"),
+			Post:   dockerProxy("Post"),
+			Delete: dockerProxy("Delete"),
+		},
+		"/api/endpoints/{id}/logs": {
+			Get: withParameters(newOperation("EndpointLogs", "Stream the logs of the containers of a stack or a service", "endpoints", accessRestricted),
+				endpointID,
+				queryParameter("containers", "Comma separated identifiers of the containers", false, str()),
+				queryParameter("stack", "Name of the stack", false, str()),
+				queryParameter("service", "Name of the service", false, str()),
+				queryParameter("since", "Only return the logs since this time", false, str()),
+				queryParameter("until", "Only return the logs before this time", false, str()),
+				queryParameter("tail", `Number of lines to return from the end of the logs, or "all"`, false, str()),
+				queryParameter("follow", "Keep streaming the new logs", false, boolean()),
+				queryParameter("stdout", "Return the standard output", false, boolean()),
+				queryParameter("stderr", "Return the standard error", false, boolean()),
+				queryParameter("grep", "Only return the lines matching this regular expression", false, str())),
+		},
+		"/api/endpoints/{endpointId}/stacks": {
+			Get: withParameters(withListParameters(newOperation("StackList", "List the stacks of an endpoint", "stacks", accessRestricted)),
+				idParameter("endpointId", "Identifier of the endpoint"),
+				queryParameter("swarmId", "Only list the stacks of this swarm", false, str())),
+			Post: withBody(withParameters(newOperation("StackCreate", "Deploy a stack", "stacks", accessRestricted),
+				idParameter("endpointId", "Identifier of the endpoint"),
+				queryParameter("method", "How the stack file is provided", true, enum(str(), "string", "repository", "file"))),
+				&openapi.RequestBody{Required: true, Content: map[string]*openapi.MediaType{
+					mediaTypeJSON: {Schema: openapi.Ref("StackCreateRequest")},
+					mediaTypeMultipart: {Schema: object([]string{"Name", "SwarmID", "file"}, map[string]*openapi.Schema{
+						"Name":    str(),
+						"SwarmID": str(),
+						"Env":     describe(str(), "JSON encoded list of name and value pairs"),
+						"file":    upload(),
+					})},
+				}}),
+		},
+		"/api/endpoints/{endpointId}/stacks/{id}": {
+			Get: withParameters(newOperation("StackInspect", "Retrieve a stack", "stacks", accessRestricted),
+				idParameter("endpointId", "Identifier of the endpoint"), pathParameter("id", "Identifier of the stack", str())),
+			Put: withJSONBody(withParameters(newOperation("StackUpdate", "Update and redeploy a stack", "stacks", accessRestricted),
+				idParameter("endpointId", "Identifier of the endpoint"), pathParameter("id", "Identifier of the stack", str())), "StackUpdateRequest"),
+			Delete: withParameters(newOperation("StackDelete", "Remove a stack", "stacks", accessRestricted),
+				idParameter("endpointId", "Identifier of the endpoint"), pathParameter("id", "Identifier of the stack", str())),
+		},
+		"/api/endpoints/{endpointId}/stacks/{id}/stackfile": {
+			Get: withParameters(newOperation("StackFileInspect", "Retrieve the stack file of a stack", "stacks", accessRestricted),
+				idParameter("endpointId", "Identifier of the endpoint"), pathParameter("id", "Identifier of the stack", str())),
+		},
+		"/api/endpoints/{endpointId}/templates/{id}/deploy": {
+			Post: withJSONBody(withParameters(newOperation("TemplateDeploy", "Deploy a template", "templates", accessRestricted),
+				idParameter("endpointId", "Identifier of the endpoint"), idParameter("id", "Identifier of the template")), "TemplateDeployRequest"),
+		},
+		"/api/export": {
+			Get: withParameters(newOperation("Export", "Export the configuration", "configuration", accessAdministrator),
+				queryParameter("omitSecrets", "Leave the passwords and the keys out of the document", false, boolean())),
+		},
+		"/api/import": {
+			Post: withMaxBodySize(withJSONBody(withParameters(newOperation("Import", "Import an exported configuration", "configuration", accessAdministrator),
+				dryRun, queryParameter("failOnConflict", "Import nothing when conflicts are found", false, boolean())), "ImportDocument"), maxImportDocumentSize),
+		},
+		"/api/logging": {
+			Get: newOperation("LoggingInspect", "Retrieve the log level and format of the daemon", "logging", accessAdministrator),
+			Put: withJSONBody(newOperation("LoggingUpdate", "Change the log level of the daemon", "logging", accessAdministrator), "LoggingUpdateRequest"),
+		},
+		"/api/pki/ca": {
+			Get: newOperation("PKICertificateAuthority", "Retrieve the certificate of the certificate authority", "pki", accessAdministrator),
+		},
+		"/api/pki/certificates": {
+			Get: newOperation("PKICertificateList", "List the issued certificates", "pki", accessAdministrator),
+		},
+		"/api/pki/server_certificates": {
+			Post: withJSONBody(newOperation("PKIServerCertificateCreate", "Issue a server certificate", "pki", accessAdministrator), "ServerCertificatesRequest"),
+		},
+		"/api/pki/endpoints/{id}": {
+			Post: withParameters(newOperation("PKIEndpointCertificateCreate", "Issue a client certificate for an endpoint", "pki", accessAdministrator), endpointID),
+		},
+		"/api/recordings": {
+			Get: newOperation("RecordingList", "List the session recordings", "recordings", accessAdministrator),
+		},
+		"/api/recordings/{id}": {
+			Get:    withParameters(newOperation("RecordingInspect", "Retrieve a session recording", "recordings", accessAdministrator), idParameter("id", "Identifier of the recording")),
+			Delete: withParameters(newOperation("RecordingDelete", "Delete a session recording", "recordings", accessAdministrator), idParameter("id", "Identifier of the recording")),
+		},
+		"/api/recordings/{id}/file": {
+			Get: withParameters(newOperation("RecordingFile", "Download the file of a session recording", "recordings", accessAdministrator), idParameter("id", "Identifier of the recording")),
+		},
+		"/api/registries": {
+			Get: withParameters(withListParameters(newOperation("RegistryList", "List the registries", "registries", accessRestricted), api.SortByURL),
+				queryParameter("url", "Only list the registries with this URL", false, str())),
+			Post: withJSONBody(withParameters(newOperation("RegistryCreate", "Create a registry", "registries", accessAdministrator), checkRegistryParameter), "RegistryRequest"),
+		},
+		"/api/registries/{id}": {
+			Get:    withParameters(newOperation("RegistryInspect", "Retrieve a registry", "registries", accessAdministrator), idParameter("id", "Identifier of the registry")),
+			Put:    withJSONBody(withParameters(newOperation("RegistryUpdate", "Update a registry", "registries", accessAdministrator), idParameter("id", "Identifier of the registry"), checkRegistryParameter), "RegistryRequest"),
+			Delete: withParameters(newOperation("RegistryDelete", "Delete a registry", "registries", accessAdministrator), idParameter("id", "Identifier of the registry")),
+		},
+		"/api/registries/{id}/access": {
+			Put: withJSONBody(withParameters(newOperation("RegistryAccessUpdate", "Update the users and teams authorized to use a registry", "registries", accessAdministrator), idParameter("id", "Identifier of the registry")), "AccessUpdateRequest"),
+		},
+		"/api/registries/{id}/repositories": {
+			Get: withParameters(newOperation("RegistryRepositoryList", "List the repositories of a registry", "registries", accessRestricted), idParameter("id", "Identifier of the registry")),
+		},
+		"/api/registries/{id}/tags": {
+			Get: withParameters(newOperation("RegistryTagList", "List the tags of a repository", "registries", accessRestricted),
+				idParameter("id", "Identifier of the registry"), repositoryParameter),
+		},
+		"/api/registries/{id}/tags/{tag}": {
+			Delete: withParameters(newOperation("RegistryTagDelete", "Delete the image a tag refers to, along with its other tags", "registries", accessAdministrator),
+				idParameter("id", "Identifier of the registry"), pathParameter("tag", "Tag of the image", str()), repositoryParameter),
+		},
+		"/api/registries/{id}/manifests/{reference}": {
+			Get: withParameters(newOperation("RegistryManifestInspect", "Retrieve the manifest of an image", "registries", accessRestricted),
+				idParameter("id", "Identifier of the registry"), pathParameter("reference", "Tag or digest of the image", str()), repositoryParameter),
+		},
+		"/api/resource_controls": {
+			Get: withParameters(withListParameters(newOperation("ResourceControlList", "List the resource controls", "resource_controls", accessRestricted), api.SortByType),
+				queryParameter("type", "Only list the resource controls of this type", false,
+					enum(str(), "container", "service", "volume", "network", "secret", "stack", "config"))),
+			Post: withJSONBody(newOperation("ResourceControlCreate", "Restrict the access to a resource", "resource_controls", accessRestricted), "ResourceControlCreateRequest"),
+		},
+		"/api/resource_controls/{id}": {
+			Put:    withJSONBody(withParameters(newOperation("ResourceControlUpdate", "Update a resource control", "resource_controls", accessRestricted), idParameter("id", "Identifier of the resource control")), "ResourceControlUpdateRequest"),
+			Delete: withParameters(newOperation("ResourceControlDelete", "Delete a resource control", "resource_controls", accessRestricted), idParameter("id", "Identifier of the resource control")),
+		},
+		"/api/settings": {
+			Get: newOperation("SettingsInspect", "Retrieve the settings", "settings", accessAdministrator),
+			Put: withJSONBody(newOperation("SettingsUpdate", "Update the settings", "settings", accessAdministrator), "SettingsUpdateRequest"),
+		},
+		"/api/settings/public": {
+			Get: newOperation("SettingsPublic", "Retrieve the settings available before authentication", "settings", accessPublic),
+		},
+		"/api/settings/authentication/checkLDAP": {
+			Put: withJSONBody(newOperation("SettingsLDAPCheck", "Check the connection to a LDAP server", "settings", accessAdministrator), "LDAPCheckRequest"),
+		},
+		"/api/status": {
+			Get: newOperation("Status", "Retrieve the status of the daemon", "status", accessPublic),
+		},
+		"/api/templates": {
+			Get: withParameters(withListParameters(newOperation("TemplateList", "List the application templates", "templates", accessRestricted), api.SortByType),
+				queryParameter("key", "Serve this templates document as is instead of the stored templates", false, enum(str(), "containers", "linuxserver.io")),
+				queryParameter("type", "Only list the templates of this type", false, enum(str(), "container", "stack")),
+				queryParameter("category", "Only list the templates of this category", false, str())),
+			Post: withJSONBody(newOperation("TemplateCreate", "Create a template", "templates", accessAdministrator), "TemplateRequest"),
+		},
+		"/api/templates/import": {
+			Post: withJSONBody(newOperation("TemplateImport", "Import the templates of a templates document", "templates", accessAdministrator), "TemplateImportRequest"),
+		},
+		"/api/templates/{id}": {
+			Get:    withParameters(newOperation("TemplateInspect", "Retrieve a template", "templates", accessRestricted), idParameter("id", "Identifier of the template")),
+			Put:    withJSONBody(withParameters(newOperation("TemplateUpdate", "Update a template", "templates", accessAdministrator), idParameter("id", "Identifier of the template")), "TemplateRequest"),
+			Delete: withParameters(newOperation("TemplateDelete", "Delete a template", "templates", accessAdministrator), idParameter("id", "Identifier of the template")),
+		},
+		"/api/upload/tls/{certificate}": {
+			Post: withBody(withParameters(newOperation("UploadTLS", "Upload a TLS file", "upload", accessAdministrator),
+				pathParameter("certificate", "Kind of TLS file", enum(str(), "ca", "cert", "key")),
+				queryParameter("folder", "Folder where the file is stored", true, str())),
+				multipartBody(map[string]*openapi.Schema{"file": upload()}, "file")),
+		},
+		"/api/teams": {
+			Get:  withListParameters(newOperation("TeamList", "List the teams", "teams", accessRestricted)),
+			Post: withJSONBody(newOperation("TeamCreate", "Create a team", "teams", accessAdministrator), "TeamCreateRequest"),
+		},
+		"/api/teams/{id}": {
+			Get:    withParameters(newOperation("TeamInspect", "Retrieve a team", "teams", accessRestricted), idParameter("id", "Identifier of the team")),
+			Put:    withJSONBody(withParameters(newOperation("TeamUpdate", "Update a team", "teams", accessAdministrator), idParameter("id", "Identifier of the team")), "TeamUpdateRequest"),
+			Delete: withParameters(newOperation("TeamDelete", "Delete a team", "teams", accessAdministrator), idParameter("id", "Identifier of the team")),
+		},
+		"/api/teams/{id}/memberships": {
+			Get: withParameters(newOperation("TeamMembershipsInspect", "List the memberships of a team", "teams", accessRestricted), idParameter("id", "Identifier of the team")),
+		},
+		"/api/team_memberships": {
+			Get:  newOperation("TeamMembershipList", "List the team memberships", "team_memberships", accessRestricted),
+			Post: withJSONBody(newOperation("TeamMembershipCreate", "Add a user to a team", "team_memberships", accessRestricted), "TeamMembershipRequest"),
+		},
+		"/api/team_memberships/{id}": {
+			Put:    withJSONBody(withParameters(newOperation("TeamMembershipUpdate", "Update a team membership", "team_memberships", accessRestricted), idParameter("id", "Identifier of the membership")), "TeamMembershipRequest"),
+			Delete: withParameters(newOperation("TeamMembershipDelete", "Remove a user from a team", "team_memberships", accessRestricted), idParameter("id", "Identifier of the membership")),
+		},
+		"/api/users": {
+			Get: withParameters(withListParameters(newOperation("UserList", "List the users", "users", accessRestricted)),
+				queryParameter("role", "Only list the users with this role", false, userRole)),
+			Post: withJSONBody(newOperation("UserCreate", "Create a user", "users", accessRestricted), "UserCreateRequest"),
+		},
+		"/api/users/{id}": {
+			Get:    withParameters(newOperation("UserInspect", "Retrieve a user", "users", accessAdministrator), idParameter("id", "Identifier of the user")),
+			Put:    withJSONBody(withParameters(newOperation("UserUpdate", "Update a user", "users", accessAuthenticated), idParameter("id", "Identifier of the user")), "UserUpdateRequest"),
+			Delete: withParameters(newOperation("UserDelete", "Delete a user", "users", accessAdministrator), idParameter("id", "Identifier of the user")),
+		},
+		"/api/users/{id}/memberships": {
+			Get: withParameters(newOperation("UserMembershipsInspect", "List the team memberships of a user", "users", accessAuthenticated), idParameter("id", "Identifier of the user")),
+		},
+		"/api/users/{id}/passwd": {
+			Post: withJSONBody(withParameters(newOperation("UserPasswordCheck", "Check the password of a user", "users", accessAuthenticated), idParameter("id", "Identifier of the user")), "UserPasswordRequest"),
+		},
+		"/api/users/admin/check": {
+			Get: newOperation("UserAdminCheck", "Check whether the administrator account exists", "users", accessPublic),
+		},
+		"/api/users/admin/init": {
+			Post: withJSONBody(newOperation("UserAdminInit", "Create the administrator account", "users", accessPublic), "AuthenticationRequest"),
+		},
+		"/api/websocket/exec": {
+			Get: websocket("WebSocketExec", "Start an exec session in a container", accessAuthenticated,
+				queryParameter("id", "Identifier of the exec instance", true, str()),
+				queryParameter("endpointId", "Identifier of the endpoint", true, integer())),
+		},
+		"/api/websocket/attach": {
+			Get: websocket("WebSocketAttach", "Attach to a container", accessAuthenticated,
+				queryParameter("id", "Identifier of the container", true, str()),
+				queryParameter("endpointId", "Identifier of the endpoint", true, integer())),
+		},
+		"/api/websocket/replay": {
+			Get: websocket("WebSocketReplay", "Replay a session recording", accessAdministrator,
+				queryParameter("id", "Identifier of the recording", true, integer()),
+				queryParameter("speed", "Speed of the replay", false, &openapi.Schema{Type: openapi.TypeNumber, Minimum: openapi.Min(0)})),
+		},
+	}
+
+	return &openapi.Document{
+		OpenAPI: openapi.Version,
+		Info: openapi.Info{
+			Title:       "Cloudware API",
+			Description: "REST API of the Cloudware daemon. The requests which do not match the schema of their operation are rejected with the details of the invalid fields.",
+			Version:     api.APIVersion,
+		},
+		Paths: paths,
+		Components: &openapi.Components{
+			Schemas: schemas,
+			SecuritySchemes: map[string]*openapi.SecurityScheme{
+				"jwt": {Type: "http", Scheme: "bearer", BearerFormat: "JWT"},
+			},
+		},
+	}
+}
@@ -0,0 +1,319 @@
+package handler
+
+import (
+	"encoding/json"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"cloudware/cloudware/api"
+	httperror "cloudware/cloudware/api/http/server/error"
+	"cloudware/cloudware/api/http/server/jwt"
+	"cloudware/cloudware/api/http/server/openapi"
+	"cloudware/cloudware/api/http/server/security"
+)
+
+func newTestHandler() *Handler {
+	bouncer := security.NewRequestBouncer(nil, nil, true)
+	return &Handler{
+		RequestBouncer:        bouncer,
+		AuthHandler:           NewAuthHandler(bouncer, true),
+		ApplyHandler:          NewApplyHandler(bouncer, true),
+		BackupHandler:         NewBackupHandler(bouncer),
+		UserHandler:           NewUserHandler(bouncer),
+		TeamHandler:           NewTeamHandler(bouncer),
+		TeamMembershipHandler: NewTeamMembershipHandler(bouncer),
+		EndpointHandler:       NewEndpointHandler(bouncer, true),
+		ExportHandler:         NewExportHandler(bouncer),
+		RegistryHandler:       NewRegistryHandler(bouncer),
+		DockerHubHandler:      NewDockerHubHandler(bouncer),
+		ResourceHandler:       NewResourceHandler(bouncer),
+		StackHandler:          NewStackHandler(bouncer),
+		StatusHandler:         NewStatusHandler(bouncer, &api.Status{}),
+		SettingsHandler:       NewSettingsHandler(bouncer),
+		TemplatesHandler:      NewTemplatesHandler(bouncer),
+		DockerHandler:         NewDockerHandler(bouncer),
+		AggregateHandler:      NewAggregateHandler(bouncer),
+		LogsHandler:           NewLogsHandler(bouncer),
+		LoggingHandler:        NewLoggingHandler(bouncer),
+		WebSocketHandler:      NewWebSocketHandler(bouncer),
+		RecordingHandler:      NewRecordingHandler(bouncer),
+		PKIHandler:            NewPKIHandler(bouncer),
+		UploadHandler:         NewUploadHandler(bouncer),
+		FileHandler:           NewFileHandler(""),
+	}
+}
+
+// samplePath replaces the parameters of a path template with values matching their schema.
+func samplePath(template string, item *openapi.PathItem, operation *openapi.Operation) string {
+	path := template
+	for _, parameter := range operation.Parameters {
+		if parameter.In != openapi.InPath {
+			continue
+		}
+		value := "sample"
+		switch {
+		case item.Prefix && strings.HasSuffix(template, "{"+parameter.Name+"}"):
+			value = "_ping"
+		case len(parameter.Schema.Enum) > 0:
+			value = fmtValue(parameter.Schema.Enum[0])
+		case parameter.Schema.Type == openapi.TypeInteger:
+			value = "1"
+		}
+		path = strings.Replace(path, "{"+parameter.Name+"}", value, 1)
+	}
+	return path
+}
+
+func fmtValue(value interface{}) string {
+	data, _ := json.Marshal(value)
+	return strings.Trim(string(data), `"`)
+}
+
+// routeTemplate returns the template of the route serving a path template of the document,
+// relative to the router of its subhandler.
+func routeTemplate(template, prefix string, item *openapi.PathItem) string {
+	template = strings.TrimPrefix(template, prefix)
+	if item.Prefix {
+		template = template[:strings.LastIndex(template, "/")]
+	}
+	return template
+}
+
+func TestAPIDocumentRoutesAreServed(t *testing.T) {
+	h := newTestHandler()
+
+	for template, item := range apiDocument.Paths {
+		for method, operation := range item.Operations() {
+			path := samplePath(template, item, operation)
+			subhandler, prefix := h.subhandler(path)
+			require.NotNil(t, subhandler, "%s %s", method, template)
+
+			router, ok := subhandler.(interface {
+				Match(*http.Request, *mux.RouteMatch) bool
+			})
+			if !ok {
+				continue
+			}
+			req := httptest.NewRequest(method, strings.TrimPrefix(path, prefix), nil)
+			assert.True(t, router.Match(req, &mux.RouteMatch{}), "%s %s is not served by any route", method, template)
+		}
+	}
+}
+
+var routeParameter = regexp.MustCompile(`\{(\w+):[^}]*\}`)
+
+// registeredRoutes parses the sources of the handlers and returns the templates of the
+// registered routes along with their methods, an empty method when any method matches.
+func registeredRoutes(t *testing.T) map[string][]string {
+	files, err := filepath.Glob("*.go")
+	require.NoError(t, err)
+
+	routes := make(map[string][]string)
+	fset := token.NewFileSet()
+	for _, file := range files {
+		if strings.HasSuffix(file, "_test.go") {
+			continue
+		}
+		f, err := parser.ParseFile(fset, file, nil, 0)
+		require.NoError(t, err)
+
+		chained := make(map[*ast.CallExpr]bool)
+		ast.Inspect(f, func(node ast.Node) bool {
+			call, ok := node.(*ast.CallExpr)
+			if !ok {
+				return true
+			}
+			if selector, ok := call.Fun.(*ast.SelectorExpr); ok && selector.Sel.Name == "Methods" {
+				if route, ok := selector.X.(*ast.CallExpr); ok {
+					if template, ok := routeCall(route); ok {
+						chained[route] = true
+						for _, arg := range call.Args {
+							if method, ok := arg.(*ast.SelectorExpr); ok {
+								routes[template] = append(routes[template], strings.ToUpper(strings.TrimPrefix(method.Sel.Name, "Method")))
+							}
+						}
+					}
+				}
+				return true
+			}
+			if template, ok := routeCall(call); ok && !chained[call] {
+				routes[template] = append(routes[template], "")
+			}
+			return true
+		})
+	}
+	return routes
+}
+
+// routeCall returns the template of a h.Handle or h.PathPrefix call.
+func routeCall(call *ast.CallExpr) (string, bool) {
+	selector, ok := call.Fun.(*ast.SelectorExpr)
+	if !ok || (selector.Sel.Name != "Handle" && selector.Sel.Name != "PathPrefix") {
+		return "", false
+	}
+	if ident, ok := selector.X.(*ast.Ident); !ok || ident.Name != "h" || len(call.Args) == 0 {
+		return "", false
+	}
+	literal, ok := call.Args[0].(*ast.BasicLit)
+	if !ok || literal.Kind != token.STRING {
+		return "", false
+	}
+	template, err := strconv.Unquote(literal.Value)
+	if err != nil {
+		return "", false
+	}
+	return routeParameter.ReplaceAllString(template, "{$1}"), true
+}
+
+func TestRegisteredRoutesAreDocumented(t *testing.T) {
+	h := newTestHandler()
+
+	documented := make(map[string]bool)
+	for template, item := range apiDocument.Paths {
+		for method, operation := range item.Operations() {
+			_, prefix := h.subhandler(samplePath(template, item, operation))
+			documented[method+" "+routeTemplate(template, prefix, item)] = true
+		}
+	}
+
+	routes := registeredRoutes(t)
+	require.NotEmpty(t, routes)
+	for template, methods := range routes {
+		for _, method := range methods {
+			if method == "" {
+				// The websocket routes and the Docker proxy match any method.
+				method = http.MethodGet
+			}
+			assert.True(t, documented[method+" "+template], "%s %s is not documented", method, template)
+		}
+	}
+}
+
+func validate(method, path, contentType, body string) (*httptest.ResponseRecorder, bool) {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	w := httptest.NewRecorder()
+	operation, params, ok := findOperation(w, req)
+	if ok && operation != nil {
+		ok = validateOperation(w, req, operation, params)
+	}
+	return w, ok
+}
+
+func TestValidateRequest(t *testing.T) {
+	w, ok := validate(http.MethodPost, "/api/teams", mediaTypeJSON, `{"Name": ""}`)
+	assert.False(t, ok)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	var response struct {
+		httperror.ErrorResponse
+		Details []httperror.FieldError `json:"details"`
+	}
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&response))
+	assert.Equal(t, string(ErrInvalidRequestFormat), response.Err)
+	assert.Equal(t, string(ErrInvalidRequestFormat), response.Message)
+	assert.Equal(t, "invalid_request_format", response.Code)
+	assert.Equal(t, http.StatusBadRequest, response.Status)
+	assert.Equal(t, []httperror.FieldError{{Field: "Name", Message: "is required"}}, response.Details)
+
+	w, ok = validate(http.MethodGet, "/api/users/abc", "", "")
+	assert.False(t, ok)
+	assert.Contains(t, w.Body.String(), `"field":"id"`)
+
+	w, ok = validate(http.MethodPost, "/api/teams", mediaTypeJSON, `{"Name":`)
+	assert.False(t, ok)
+	assert.Contains(t, w.Body.String(), string(ErrInvalidJSON))
+
+	_, ok = validate(http.MethodPost, "/api/teams", mediaTypeJSON, `{"Name": "devs"}`)
+	assert.True(t, ok)
+
+	_, ok = validate(http.MethodGet, "/api/users/admin/check", "", "")
+	assert.True(t, ok)
+
+	_, ok = validate(http.MethodGet, "/index.html", "", "")
+	assert.True(t, ok)
+
+	w, ok = validate(http.MethodGet, "/api/unknown", "", "")
+	assert.False(t, ok)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Contains(t, w.Body.String(), `"code":"route_not_found"`)
+
+	w, ok = validate(http.MethodPatch, "/api/teams", "", "")
+	assert.False(t, ok)
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+	assert.Equal(t, "GET, POST", w.Header().Get("Allow"))
+
+	_, ok = validate(http.MethodHead, "/api/endpoints/1/docker/_ping", "", "")
+	assert.True(t, ok)
+}
+
+func TestValidateRequestBodySize(t *testing.T) {
+	large := `{"Name": "` + strings.Repeat("a", openapi.DefaultMaxBodySize) + `"}`
+
+	w, ok := validate(http.MethodPost, "/api/teams", mediaTypeJSON, large)
+	assert.False(t, ok)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+	assert.Contains(t, w.Body.String(), `"code":"request_body_too_large"`)
+
+	// The import documents include the stack files, their operation accepts larger bodies.
+	w, _ = validate(http.MethodPost, "/api/import", mediaTypeJSON, `{"Version": 1, "Teams": [{"Name": "`+strings.Repeat("a", openapi.DefaultMaxBodySize)+`"}]}`)
+	assert.NotEqual(t, http.StatusRequestEntityTooLarge, w.Code, w.Body.String())
+
+	// The bodies which are not validated are left to the handlers.
+	_, ok = validate(http.MethodPost, "/api/apply", "application/x-yaml", strings.Repeat("a", openapi.DefaultMaxBodySize+1))
+	assert.True(t, ok)
+}
+
+func TestValidateAfterAuthentication(t *testing.T) {
+	jwtService, err := jwt.NewService()
+	require.NoError(t, err)
+	h := newTestHandler()
+	h.RequestBouncer = security.NewRequestBouncer(jwtService, nil, false)
+
+	serve := func(method, path, token, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", mediaTypeJSON)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		return w
+	}
+
+	invalid := `{"Name": 1}`
+	tooLarge := `{"Name": "` + strings.Repeat("a", openapi.DefaultMaxBodySize) + `"}`
+
+	// Neither validated nor read without token.
+	assert.Equal(t, http.StatusUnauthorized, serve(http.MethodPost, "/api/teams", "", invalid).Code)
+	assert.Equal(t, http.StatusUnauthorized, serve(http.MethodPost, "/api/teams", "", tooLarge).Code)
+	assert.Equal(t, http.StatusUnauthorized, serve(http.MethodPost, "/api/import", "", tooLarge).Code)
+
+	userToken, err := jwtService.GenerateToken(&api.TokenData{ID: 2, Username: "alice", Role: api.StandardUserRole})
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusForbidden, serve(http.MethodPost, "/api/teams", userToken, invalid).Code)
+
+	adminToken, err := jwtService.GenerateToken(&api.TokenData{ID: 1, Username: "admin", Role: api.AdministratorRole})
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, serve(http.MethodPost, "/api/teams", adminToken, invalid).Code)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, serve(http.MethodPost, "/api/teams", adminToken, tooLarge).Code)
+
+	// The public operations are validated without token.
+	assert.Equal(t, http.StatusBadRequest, serve(http.MethodPost, "/api/auth", "", `{"Username": 1}`).Code)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, serve(http.MethodPost, "/api/auth", "", tooLarge).Code)
+	assert.Equal(t, http.StatusNotFound, serve(http.MethodGet, "/api/unknown", "", "").Code)
+}
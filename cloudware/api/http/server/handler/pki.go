@@ -0,0 +1,208 @@
+package handler
+
+import (
+	"cloudware/cloudware/api"
+	"cloudware/cloudware/api/file"
+	httperror "cloudware/cloudware/api/http/server/error"
+	"cloudware/cloudware/api/http/server/proxy"
+	"cloudware/cloudware/api/http/server/security"
+
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+
+	"github.com/asaskevich/govalidator"
+	"github.com/gorilla/mux"
+)
+
+// PKIHandler represents an HTTP API handler for managing the built-in certificate authority.
+type PKIHandler struct {
+	*mux.Router
+	PKIService      api.PKIService
+	EndpointService api.EndpointService
+	SettingsService api.SettingsService
+	FileService     api.FileService
+	ProxyManager    *proxy.Manager
+}
+
+// NewPKIHandler returns a new instance of PKIHandler.
+func NewPKIHandler(bouncer *security.RequestBouncer) *PKIHandler {
+	h := &PKIHandler{
+		Router: mux.NewRouter(),
+	}
+	h.Handle("/pki/ca",
+		bouncer.AdministratorAccess(http.HandlerFunc(h.handleGetCA))).Methods(http.MethodGet)
+	h.Handle("/pki/certificates",
+		bouncer.AdministratorAccess(http.HandlerFunc(h.handleGetCertificates))).Methods(http.MethodGet)
+	h.Handle("/pki/server_certificates",
+		bouncer.AdministratorAccess(http.HandlerFunc(h.handlePostServerCertificates))).Methods(http.MethodPost)
+	h.Handle("/pki/endpoints/{id}",
+		bouncer.AdministratorAccess(http.HandlerFunc(h.handlePostEndpointCertificate))).Methods(http.MethodPost)
+	return h
+}
+
+type (
+	postServerCertificatesRequest struct {
+		Hosts        []string `valid:"required"`
+		ValidityDays int      `valid:"-"`
+	}
+
+	certificateReportEntry struct {
+		Source       string               `json:"Source"`
+		EndpointID   api.EndpointID       `json:"EndpointId,omitempty"`
+		EndpointName string               `json:"EndpointName,omitempty"`
+		Managed      bool                 `json:"Managed"`
+		Certificate  *api.CertificateInfo `json:"Certificate,omitempty"`
+		Err          string               `json:"err,omitempty"`
+	}
+)
+
+// handleGetCA handles GET requests on /pki/ca
+func (handler *PKIHandler) handleGetCA(w http.ResponseWriter, r *http.Request) {
+	caCert, err := handler.PKIService.CACertificate()
+	if err == api.ErrPKIDisabled {
+		httperror.WriteErrorResponse(w, r, err, http.StatusServiceUnavailable)
+		return
+	} else if err != nil {
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-pem-file")
+	w.Header().Set("Content-Disposition", "attachment; filename=\"ca.pem\"")
+	io.WriteString(w, caCert)
+}
+
+// handleGetCertificates handles GET requests on /pki/certificates
+// It reports the expiry date of every certificate stored in the TLS store.
+func (handler *PKIHandler) handleGetCertificates(w http.ResponseWriter, r *http.Request) {
+	report := make([]certificateReportEntry, 0)
+
+	caPath, _ := handler.FileService.GetPathForTLSFile(file.PKIStorePath, api.TLSFileCA)
+	if _, err := os.Stat(caPath); err == nil {
+		report = append(report, handler.certificateReportEntry("pki", caPath))
+	}
+
+	endpoints, err := handler.EndpointService.Endpoints()
+	if err != nil {
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
+		return
+	}
+
+	for _, endpoint := range endpoints {
+		if !endpoint.TLSConfig.TLS {
+			continue
+		}
+		for _, certificatePath := range []string{endpoint.TLSConfig.TLSCACertPath, endpoint.TLSConfig.TLSCertPath} {
+			if certificatePath == "" {
+				continue
+			}
+			entry := handler.certificateReportEntry("endpoint", certificatePath)
+			entry.EndpointID = endpoint.ID
+			entry.EndpointName = endpoint.Name
+			entry.Managed = endpoint.ManagedCertificates
+			report = append(report, entry)
+		}
+	}
+
+	settings, err := handler.SettingsService.Settings()
+	if err != nil {
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
+		return
+	}
+
+	if settings.LDAPSettings.TLSConfig.TLSCACertPath != "" {
+		report = append(report, handler.certificateReportEntry("ldap", settings.LDAPSettings.TLSConfig.TLSCACertPath))
+	}
+
+	encodeJSON(w, r, report)
+}
+
+func (handler *PKIHandler) certificateReportEntry(source, certificatePath string) certificateReportEntry {
+	entry := certificateReportEntry{Source: source}
+
+	info, err := handler.PKIService.CertificateInfo(certificatePath)
+	if err != nil {
+		entry.Certificate = &api.CertificateInfo{Path: certificatePath}
+		entry.Err = err.Error()
+		return entry
+	}
+
+	entry.Certificate = info
+	return entry
+}
+
+// handlePostServerCertificates handles POST requests on /pki/server_certificates
+// The issued certificate is not stored, it must be installed on the Docker host.
+func (handler *PKIHandler) handlePostServerCertificates(w http.ResponseWriter, r *http.Request) {
+	var req postServerCertificatesRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		httperror.WriteErrorResponse(w, r, ErrInvalidJSON, http.StatusBadRequest)
+		return
+	}
+
+	_, err := govalidator.ValidateStruct(req)
+	if err != nil || req.ValidityDays < 0 {
+		httperror.WriteErrorResponse(w, r, ErrInvalidRequestFormat, http.StatusBadRequest)
+		return
+	}
+
+	bundle, err := handler.PKIService.IssueServerCertificate(req.Hosts, req.ValidityDays)
+	if err == api.ErrInvalidCertificateHost {
+		httperror.WriteErrorResponse(w, r, err, http.StatusBadRequest)
+		return
+	} else if err == api.ErrPKIDisabled {
+		httperror.WriteErrorResponse(w, r, err, http.StatusServiceUnavailable)
+		return
+	} else if err != nil {
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
+		return
+	}
+
+	encodeJSON(w, r, bundle)
+}
+
+// handlePostEndpointCertificate handles POST requests on /pki/endpoints/:id
+// A client certificate is issued for the endpoint and used to connect to it from now on.
+func (handler *PKIHandler) handlePostEndpointCertificate(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	endpointID, err := strconv.Atoi(id)
+	if err != nil {
+		httperror.WriteErrorResponse(w, r, err, http.StatusBadRequest)
+		return
+	}
+
+	endpoint, err := handler.EndpointService.Endpoint(api.EndpointID(endpointID))
+	if err == api.ErrEndpointNotFound {
+		httperror.WriteErrorResponse(w, r, err, http.StatusNotFound)
+		return
+	} else if err != nil {
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
+		return
+	}
+
+	err = handler.PKIService.IssueEndpointCertificate(endpoint)
+	if err == api.ErrPKIDisabled {
+		httperror.WriteErrorResponse(w, r, err, http.StatusServiceUnavailable)
+		return
+	} else if err != nil {
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
+		return
+	}
+
+	_, err = handler.ProxyManager.CreateAndRegisterProxy(endpoint)
+	if err != nil {
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
+		return
+	}
+
+	err = handler.EndpointService.UpdateEndpoint(endpoint.ID, endpoint)
+	if err != nil {
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
+		return
+	}
+}
@@ -0,0 +1,113 @@
+package handler
+
+import (
+	"cloudware/cloudware/api"
+	httperror "cloudware/cloudware/api/http/server/error"
+	"cloudware/cloudware/api/http/server/security"
+
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+// RecordingHandler represents an HTTP API handler for managing session recordings.
+type RecordingHandler struct {
+	*mux.Router
+	RecordingService api.RecordingService
+	FileService      api.FileService
+}
+
+// NewRecordingHandler returns a new instance of RecordingHandler.
+func NewRecordingHandler(bouncer *security.RequestBouncer) *RecordingHandler {
+	h := &RecordingHandler{
+		Router: mux.NewRouter(),
+	}
+	h.Handle("/recordings",
+		bouncer.AdministratorAccess(http.HandlerFunc(h.handleGetRecordings))).Methods(http.MethodGet)
+	h.Handle("/recordings/{id}",
+		bouncer.AdministratorAccess(http.HandlerFunc(h.handleGetRecording))).Methods(http.MethodGet)
+	h.Handle("/recordings/{id}/file",
+		bouncer.AdministratorAccess(http.HandlerFunc(h.handleGetRecordingFile))).Methods(http.MethodGet)
+	h.Handle("/recordings/{id}",
+		bouncer.AdministratorAccess(http.HandlerFunc(h.handleDeleteRecording))).Methods(http.MethodDelete)
+
+	return h
+}
+
+// handleGetRecordings handles GET requests on /recordings
+func (handler *RecordingHandler) handleGetRecordings(w http.ResponseWriter, r *http.Request) {
+	recordings, err := handler.RecordingService.Recordings()
+	if err != nil {
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
+		return
+	}
+
+	encodeJSON(w, r, recordings)
+}
+
+// handleGetRecording handles GET requests on /recordings/:id
+func (handler *RecordingHandler) handleGetRecording(w http.ResponseWriter, r *http.Request) {
+	recording, ok := handler.retrieveRecording(w, r)
+	if !ok {
+		return
+	}
+
+	encodeJSON(w, r, recording)
+}
+
+// handleGetRecordingFile handles GET requests on /recordings/:id/file
+func (handler *RecordingHandler) handleGetRecordingFile(w http.ResponseWriter, r *http.Request) {
+	recording, ok := handler.retrieveRecording(w, r)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-asciicast")
+	w.Header().Set("Content-Disposition", "attachment; filename=\"recording-"+strconv.Itoa(int(recording.ID))+".cast\"")
+	http.ServeFile(w, r, recording.FilePath)
+}
+
+// handleDeleteRecording handles DELETE requests on /recordings/:id
+func (handler *RecordingHandler) handleDeleteRecording(w http.ResponseWriter, r *http.Request) {
+	recording, ok := handler.retrieveRecording(w, r)
+	if !ok {
+		return
+	}
+
+	err := handler.FileService.DeleteRecordingFile(recording.FilePath)
+	if err != nil {
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
+		return
+	}
+
+	err = handler.RecordingService.DeleteRecording(recording.ID)
+	if err != nil {
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
+		return
+	}
+}
+
+// retrieveRecording returns the recording matching the id route variable.
+// An error response is written and false is returned when it cannot be retrieved.
+func (handler *RecordingHandler) retrieveRecording(w http.ResponseWriter, r *http.Request) (*api.Recording, bool) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	recordingID, err := strconv.Atoi(id)
+	if err != nil {
+		httperror.WriteErrorResponse(w, r, err, http.StatusBadRequest)
+		return nil, false
+	}
+
+	recording, err := handler.RecordingService.Recording(api.RecordingID(recordingID))
+	if err == api.ErrRecordingNotFound {
+		httperror.WriteErrorResponse(w, r, err, http.StatusNotFound)
+		return nil, false
+	} else if err != nil {
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
+		return nil, false
+	}
+
+	return recording, true
+}
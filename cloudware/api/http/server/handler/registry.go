@@ -6,9 +6,7 @@ import (
 	"cloudware/cloudware/api/http/server/security"
 
 	"encoding/json"
-	"log"
 	"net/http"
-	"os"
 	"strconv"
 
 	"github.com/asaskevich/govalidator"
@@ -18,7 +16,6 @@ import (
 // RegistryHandler represents an HTTP API handler for managing Docker registries.
 type RegistryHandler struct {
 	*mux.Router
-	Logger          *log.Logger
 	RegistryService api.RegistryService
 }
 
@@ -26,7 +23,6 @@ type RegistryHandler struct {
 func NewRegistryHandler(bouncer *security.RequestBouncer) *RegistryHandler {
 	h := &RegistryHandler{
 		Router: mux.NewRouter(),
-		Logger: log.New(os.Stderr, "", log.LstdFlags),
 	}
 	h.Handle("/registries",
 		bouncer.AdministratorAccess(http.HandlerFunc(h.handlePostRegistries))).Methods(http.MethodPost)
@@ -40,6 +36,14 @@ func NewRegistryHandler(bouncer *security.RequestBouncer) *RegistryHandler {
 		bouncer.AdministratorAccess(http.HandlerFunc(h.handlePutRegistryAccess))).Methods(http.MethodPut)
 	h.Handle("/registries/{id}",
 		bouncer.AdministratorAccess(http.HandlerFunc(h.handleDeleteRegistry))).Methods(http.MethodDelete)
+	h.Handle("/registries/{id}/repositories",
+		bouncer.RestrictedAccess(http.HandlerFunc(h.handleGetRegistryRepositories))).Methods(http.MethodGet)
+	h.Handle("/registries/{id}/tags",
+		bouncer.RestrictedAccess(http.HandlerFunc(h.handleGetRegistryTags))).Methods(http.MethodGet)
+	h.Handle("/registries/{id}/tags/{tag}",
+		bouncer.AdministratorAccess(http.HandlerFunc(h.handleDeleteRegistryTag))).Methods(http.MethodDelete)
+	h.Handle("/registries/{id}/manifests/{reference}",
+		bouncer.RestrictedAccess(http.HandlerFunc(h.handleGetRegistryManifest))).Methods(http.MethodGet)
 
 	return h
 }
@@ -63,11 +67,11 @@ type (
 	}
 
 	putRegistriesRequest struct {
-		Name           string `valid:"required"`
-		URL            string `valid:"required"`
-		Authentication bool   `valid:""`
-		Username       string `valid:""`
-		Password       string `valid:""`
+		Name           string  `valid:"required"`
+		URL            string  `valid:"required"`
+		Authentication bool    `valid:""`
+		Username       string  `valid:""`
+		Password       *string `valid:"-"`
 	}
 )
 
@@ -75,47 +79,63 @@ type (
 func (handler *RegistryHandler) handleGetRegistries(w http.ResponseWriter, r *http.Request) {
 	securityContext, err := security.RetrieveRestrictedRequestContext(r)
 	if err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusInternalServerError, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
 		return
 	}
 
-	registries, err := handler.RegistryService.Registries()
+	options, err := parseListOptions(r, api.SortByURL)
 	if err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusInternalServerError, handler.Logger)
+		httperror.WriteErrorResponse(w, r, ErrInvalidQueryFormat, http.StatusBadRequest)
 		return
 	}
 
-	filteredRegistries, err := security.FilterRegistries(registries, securityContext)
+	registries, total, err := handler.RegistryService.ListRegistries(&api.RegistryQuery{
+		ListOptions: options,
+		URL:         r.URL.Query().Get("url"),
+		Access:      security.ListAccessFilter(securityContext),
+	})
 	if err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusInternalServerError, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
 		return
 	}
 
-	encodeJSON(w, filteredRegistries, handler.Logger)
+	for idx := range registries {
+		registries[idx].Password = ""
+	}
+
+	writeTotalCount(w, total)
+	encodeJSON(w, r, registries)
 }
 
 // handlePostRegistries handles POST requests on /registries
+// The registry is only checked to be reachable with its credentials when the check query parameter is set.
 func (handler *RegistryHandler) handlePostRegistries(w http.ResponseWriter, r *http.Request) {
+	check, err := parseBoolQuery(r, "check")
+	if err != nil {
+		httperror.WriteErrorResponse(w, r, ErrInvalidQueryFormat, http.StatusBadRequest)
+		return
+	}
+
 	var req postRegistriesRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		httperror.WriteErrorResponse(w, ErrInvalidJSON, http.StatusBadRequest, handler.Logger)
+		httperror.WriteErrorResponse(w, r, ErrInvalidJSON, http.StatusBadRequest)
 		return
 	}
 
-	_, err := govalidator.ValidateStruct(req)
+	_, err = govalidator.ValidateStruct(req)
 	if err != nil {
-		httperror.WriteErrorResponse(w, ErrInvalidRequestFormat, http.StatusBadRequest, handler.Logger)
+		httperror.WriteErrorResponse(w, r, ErrInvalidRequestFormat, http.StatusBadRequest)
 		return
 	}
 
 	registries, err := handler.RegistryService.Registries()
 	if err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusInternalServerError, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
 		return
 	}
-	for _, r := range registries {
-		if r.URL == req.URL {
-			httperror.WriteErrorResponse(w, api.ErrRegistryAlreadyExists, http.StatusConflict, handler.Logger)
+	for _, existing := range registries {
+		if existing.URL == req.URL {
+			httperror.WriteErrorResponse(w, r, api.ErrRegistryAlreadyExists, http.StatusConflict)
 			return
 		}
 	}
@@ -130,13 +150,21 @@ func (handler *RegistryHandler) handlePostRegistries(w http.ResponseWriter, r *h
 		AuthorizedTeams: []api.TeamID{},
 	}
 
+	if check {
+		err = checkRegistry(registry)
+		if err != nil {
+			httperror.WriteErrorResponse(w, r, err, registryErrorStatus(err))
+			return
+		}
+	}
+
 	err = handler.RegistryService.CreateRegistry(registry)
 	if err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusInternalServerError, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
 		return
 	}
 
-	encodeJSON(w, &postRegistriesResponse{ID: int(registry.ID)}, handler.Logger)
+	encodeJSON(w, r, &postRegistriesResponse{ID: int(registry.ID)})
 }
 
 // handleGetRegistry handles GET requests on /registries/:id
@@ -146,20 +174,21 @@ func (handler *RegistryHandler) handleGetRegistry(w http.ResponseWriter, r *http
 
 	registryID, err := strconv.Atoi(id)
 	if err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusBadRequest, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusBadRequest)
 		return
 	}
 
 	registry, err := handler.RegistryService.Registry(api.RegistryID(registryID))
 	if err == api.ErrRegistryNotFound {
-		httperror.WriteErrorResponse(w, err, http.StatusNotFound, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusNotFound)
 		return
 	} else if err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusInternalServerError, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
 		return
 	}
 
-	encodeJSON(w, registry, handler.Logger)
+	registry.Password = ""
+	encodeJSON(w, r, registry)
 }
 
 // handlePutRegistryAccess handles PUT requests on /registries/:id/access
@@ -169,28 +198,28 @@ func (handler *RegistryHandler) handlePutRegistryAccess(w http.ResponseWriter, r
 
 	registryID, err := strconv.Atoi(id)
 	if err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusBadRequest, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusBadRequest)
 		return
 	}
 
 	var req putRegistryAccessRequest
 	if err = json.NewDecoder(r.Body).Decode(&req); err != nil {
-		httperror.WriteErrorResponse(w, ErrInvalidJSON, http.StatusBadRequest, handler.Logger)
+		httperror.WriteErrorResponse(w, r, ErrInvalidJSON, http.StatusBadRequest)
 		return
 	}
 
 	_, err = govalidator.ValidateStruct(req)
 	if err != nil {
-		httperror.WriteErrorResponse(w, ErrInvalidRequestFormat, http.StatusBadRequest, handler.Logger)
+		httperror.WriteErrorResponse(w, r, ErrInvalidRequestFormat, http.StatusBadRequest)
 		return
 	}
 
 	registry, err := handler.RegistryService.Registry(api.RegistryID(registryID))
 	if err == api.ErrRegistryNotFound {
-		httperror.WriteErrorResponse(w, err, http.StatusNotFound, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusNotFound)
 		return
 	} else if err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusInternalServerError, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
 		return
 	}
 
@@ -212,51 +241,58 @@ func (handler *RegistryHandler) handlePutRegistryAccess(w http.ResponseWriter, r
 
 	err = handler.RegistryService.UpdateRegistry(registry.ID, registry)
 	if err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusInternalServerError, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
 		return
 	}
 }
 
 // handlePutRegistry handles PUT requests on /registries/:id
+// The registry is only checked to be reachable with its credentials when the check query parameter is set.
 func (handler *RegistryHandler) handlePutRegistry(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id := vars["id"]
 
 	registryID, err := strconv.Atoi(id)
 	if err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusBadRequest, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusBadRequest)
+		return
+	}
+
+	check, err := parseBoolQuery(r, "check")
+	if err != nil {
+		httperror.WriteErrorResponse(w, r, ErrInvalidQueryFormat, http.StatusBadRequest)
 		return
 	}
 
 	var req putRegistriesRequest
 	if err = json.NewDecoder(r.Body).Decode(&req); err != nil {
-		httperror.WriteErrorResponse(w, ErrInvalidJSON, http.StatusBadRequest, handler.Logger)
+		httperror.WriteErrorResponse(w, r, ErrInvalidJSON, http.StatusBadRequest)
 		return
 	}
 
 	_, err = govalidator.ValidateStruct(req)
 	if err != nil {
-		httperror.WriteErrorResponse(w, ErrInvalidRequestFormat, http.StatusBadRequest, handler.Logger)
+		httperror.WriteErrorResponse(w, r, ErrInvalidRequestFormat, http.StatusBadRequest)
 		return
 	}
 
 	registry, err := handler.RegistryService.Registry(api.RegistryID(registryID))
 	if err == api.ErrRegistryNotFound {
-		httperror.WriteErrorResponse(w, err, http.StatusNotFound, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusNotFound)
 		return
 	} else if err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusInternalServerError, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
 		return
 	}
 
 	registries, err := handler.RegistryService.Registries()
 	if err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusInternalServerError, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
 		return
 	}
-	for _, r := range registries {
-		if r.URL == req.URL && r.ID != registry.ID {
-			httperror.WriteErrorResponse(w, api.ErrRegistryAlreadyExists, http.StatusConflict, handler.Logger)
+	for _, existing := range registries {
+		if existing.URL == req.URL && existing.ID != registry.ID {
+			httperror.WriteErrorResponse(w, r, api.ErrRegistryAlreadyExists, http.StatusConflict)
 			return
 		}
 	}
@@ -272,16 +308,27 @@ func (handler *RegistryHandler) handlePutRegistry(w http.ResponseWriter, r *http
 	if req.Authentication {
 		registry.Authentication = true
 		registry.Username = req.Username
-		registry.Password = req.Password
+		// The password is never sent back to the client, the current one is kept when it is omitted
+		if req.Password != nil {
+			registry.Password = *req.Password
+		}
 	} else {
 		registry.Authentication = false
 		registry.Username = ""
 		registry.Password = ""
 	}
 
+	if check {
+		err = checkRegistry(registry)
+		if err != nil {
+			httperror.WriteErrorResponse(w, r, err, registryErrorStatus(err))
+			return
+		}
+	}
+
 	err = handler.RegistryService.UpdateRegistry(registry.ID, registry)
 	if err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusInternalServerError, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
 		return
 	}
 }
@@ -293,22 +340,22 @@ func (handler *RegistryHandler) handleDeleteRegistry(w http.ResponseWriter, r *h
 
 	registryID, err := strconv.Atoi(id)
 	if err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusBadRequest, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusBadRequest)
 		return
 	}
 
 	_, err = handler.RegistryService.Registry(api.RegistryID(registryID))
 	if err == api.ErrRegistryNotFound {
-		httperror.WriteErrorResponse(w, err, http.StatusNotFound, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusNotFound)
 		return
 	} else if err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusInternalServerError, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
 		return
 	}
 
 	err = handler.RegistryService.DeleteRegistry(api.RegistryID(registryID))
 	if err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusInternalServerError, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
 		return
 	}
 }
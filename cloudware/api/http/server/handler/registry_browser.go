@@ -0,0 +1,175 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"cloudware/cloudware/api"
+	"cloudware/cloudware/api/distribution"
+	httperror "cloudware/cloudware/api/http/server/error"
+	"cloudware/cloudware/api/http/server/security"
+)
+
+// handleGetRegistryRepositories handles GET requests on /registries/:id/repositories
+func (handler *RegistryHandler) handleGetRegistryRepositories(w http.ResponseWriter, r *http.Request) {
+	client, ok := handler.authorizedRegistryClient(w, r)
+	if !ok {
+		return
+	}
+
+	repositories, err := client.Catalog()
+	if err != nil {
+		httperror.WriteErrorResponse(w, r, err, registryErrorStatus(err))
+		return
+	}
+
+	encodeJSON(w, r, repositories)
+}
+
+// handleGetRegistryTags handles GET requests on /registries/:id/tags?repository=<repository>
+func (handler *RegistryHandler) handleGetRegistryTags(w http.ResponseWriter, r *http.Request) {
+	repository := r.FormValue("repository")
+	if repository == "" {
+		httperror.WriteErrorResponse(w, r, ErrInvalidQueryFormat, http.StatusBadRequest)
+		return
+	}
+
+	client, ok := handler.authorizedRegistryClient(w, r)
+	if !ok {
+		return
+	}
+
+	tags, err := client.Tags(repository)
+	if err != nil {
+		httperror.WriteErrorResponse(w, r, err, registryErrorStatus(err))
+		return
+	}
+
+	encodeJSON(w, r, tags)
+}
+
+// handleGetRegistryManifest handles GET requests on /registries/:id/manifests/:reference?repository=<repository>,
+// the reference is either a tag or a digest.
+func (handler *RegistryHandler) handleGetRegistryManifest(w http.ResponseWriter, r *http.Request) {
+	repository := r.FormValue("repository")
+	if repository == "" {
+		httperror.WriteErrorResponse(w, r, ErrInvalidQueryFormat, http.StatusBadRequest)
+		return
+	}
+
+	client, ok := handler.authorizedRegistryClient(w, r)
+	if !ok {
+		return
+	}
+
+	manifest, err := client.Manifest(repository, mux.Vars(r)["reference"])
+	if err != nil {
+		httperror.WriteErrorResponse(w, r, err, registryErrorStatus(err))
+		return
+	}
+
+	encodeJSON(w, r, manifest)
+}
+
+// handleDeleteRegistryTag handles DELETE requests on /registries/:id/tags/:tag?repository=<repository>
+func (handler *RegistryHandler) handleDeleteRegistryTag(w http.ResponseWriter, r *http.Request) {
+	repository := r.FormValue("repository")
+	if repository == "" {
+		httperror.WriteErrorResponse(w, r, ErrInvalidQueryFormat, http.StatusBadRequest)
+		return
+	}
+
+	// The deletion is restricted to the administrators, being authorized to pull from a registry
+	// does not allow to delete its images with the credentials of the registry.
+	registry, ok := handler.requestRegistry(w, r)
+	if !ok {
+		return
+	}
+	client, ok := newRegistryClient(w, r, registry)
+	if !ok {
+		return
+	}
+
+	err := client.DeleteTag(repository, mux.Vars(r)["tag"])
+	if err != nil {
+		httperror.WriteErrorResponse(w, r, err, registryErrorStatus(err))
+		return
+	}
+}
+
+// authorizedRegistryClient returns a client of the registry of the request, it writes the
+// error response when the registry does not exist or when the user is not authorized to use it.
+func (handler *RegistryHandler) authorizedRegistryClient(w http.ResponseWriter, r *http.Request) (*distribution.Client, bool) {
+	securityContext, err := security.RetrieveRestrictedRequestContext(r)
+	if err != nil {
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
+		return nil, false
+	}
+
+	registry, ok := handler.requestRegistry(w, r)
+	if !ok {
+		return nil, false
+	}
+
+	authorizedRegistries, err := security.FilterRegistries([]api.Registry{*registry}, securityContext)
+	if err != nil {
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
+		return nil, false
+	}
+	if len(authorizedRegistries) == 0 {
+		httperror.WriteErrorResponse(w, r, api.ErrRegistryAccessDenied, http.StatusForbidden)
+		return nil, false
+	}
+
+	return newRegistryClient(w, r, registry)
+}
+
+// requestRegistry returns the registry of the request, it writes the error response when the registry does not exist.
+func (handler *RegistryHandler) requestRegistry(w http.ResponseWriter, r *http.Request) (*api.Registry, bool) {
+	registryID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		httperror.WriteErrorResponse(w, r, err, http.StatusBadRequest)
+		return nil, false
+	}
+
+	registry, err := handler.RegistryService.Registry(api.RegistryID(registryID))
+	if err == api.ErrRegistryNotFound {
+		httperror.WriteErrorResponse(w, r, err, http.StatusNotFound)
+		return nil, false
+	} else if err != nil {
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
+		return nil, false
+	}
+	return registry, true
+}
+
+// newRegistryClient returns a client of a registry, it writes the error response when the URL of the registry is invalid.
+func newRegistryClient(w http.ResponseWriter, r *http.Request, registry *api.Registry) (*distribution.Client, bool) {
+	client, err := distribution.NewClient(registry)
+	if err != nil {
+		httperror.WriteErrorResponse(w, r, err, registryErrorStatus(err))
+		return nil, false
+	}
+	return client, true
+}
+
+// checkRegistry checks that a registry is reachable and accepts its credentials.
+func checkRegistry(registry *api.Registry) error {
+	client, err := distribution.NewClient(registry)
+	if err != nil {
+		return err
+	}
+	return client.Ping()
+}
+
+// registryErrorStatus returns the status of the response to an error of a registry.
+func registryErrorStatus(err error) int {
+	switch err {
+	case api.ErrRegistryRepositoryNotFound:
+		return http.StatusNotFound
+	case api.ErrRegistryAuthenticationFailed, api.ErrRegistryDeletionDisabled:
+		return http.StatusBadRequest
+	}
+	return http.StatusBadGateway
+}
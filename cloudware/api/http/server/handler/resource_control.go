@@ -9,9 +9,7 @@ import (
 	httperror "cloudware/cloudware/api/http/server/error"
 	"cloudware/cloudware/api/http/server/security"
 
-	"log"
 	"net/http"
-	"os"
 
 	"github.com/gorilla/mux"
 )
@@ -19,7 +17,6 @@ import (
 // ResourceHandler represents an HTTP API handler for managing resource controls.
 type ResourceHandler struct {
 	*mux.Router
-	Logger                 *log.Logger
 	ResourceControlService api.ResourceControlService
 }
 
@@ -27,8 +24,9 @@ type ResourceHandler struct {
 func NewResourceHandler(bouncer *security.RequestBouncer) *ResourceHandler {
 	h := &ResourceHandler{
 		Router: mux.NewRouter(),
-		Logger: log.New(os.Stderr, "", log.LstdFlags),
 	}
+	h.Handle("/resource_controls",
+		bouncer.RestrictedAccess(http.HandlerFunc(h.handleGetResources))).Methods(http.MethodGet)
 	h.Handle("/resource_controls",
 		bouncer.RestrictedAccess(http.HandlerFunc(h.handlePostResources))).Methods(http.MethodPost)
 	h.Handle("/resource_controls/{id}",
@@ -56,53 +54,85 @@ type (
 	}
 )
 
+// resourceControlTypes maps the names of the resource control types to the types.
+var resourceControlTypes = map[string]api.ResourceControlType{
+	"container": api.ContainerResourceControl,
+	"service":   api.ServiceResourceControl,
+	"volume":    api.VolumeResourceControl,
+	"network":   api.NetworkResourceControl,
+	"secret":    api.SecretResourceControl,
+	"stack":     api.StackResourceControl,
+	"config":    api.ConfigResourceControl,
+}
+
+// handleGetResources handles GET requests on /resource_controls
+func (handler *ResourceHandler) handleGetResources(w http.ResponseWriter, r *http.Request) {
+	securityContext, err := security.RetrieveRestrictedRequestContext(r)
+	if err != nil {
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
+		return
+	}
+
+	options, err := parseListOptions(r, api.SortByType)
+	if err != nil {
+		httperror.WriteErrorResponse(w, r, ErrInvalidQueryFormat, http.StatusBadRequest)
+		return
+	}
+	query := &api.ResourceControlQuery{
+		ListOptions: options,
+		Access:      security.ListAccessFilter(securityContext),
+	}
+	if name := r.URL.Query().Get("type"); name != "" {
+		resourceControlType, ok := resourceControlTypes[name]
+		if !ok {
+			httperror.WriteErrorResponse(w, r, api.ErrInvalidResourceControlType, http.StatusBadRequest)
+			return
+		}
+		query.Type = resourceControlType
+	}
+
+	resourceControls, total, err := handler.ResourceControlService.ListResourceControls(query)
+	if err != nil {
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
+		return
+	}
+
+	writeTotalCount(w, total)
+	encodeJSON(w, r, resourceControls)
+}
+
 // handlePostResources handles POST requests on /resources
 func (handler *ResourceHandler) handlePostResources(w http.ResponseWriter, r *http.Request) {
 	var req postResourcesRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		httperror.WriteErrorResponse(w, ErrInvalidJSON, http.StatusBadRequest, handler.Logger)
+		httperror.WriteErrorResponse(w, r, ErrInvalidJSON, http.StatusBadRequest)
 		return
 	}
 
 	_, err := govalidator.ValidateStruct(req)
 	if err != nil {
-		httperror.WriteErrorResponse(w, ErrInvalidRequestFormat, http.StatusBadRequest, handler.Logger)
+		httperror.WriteErrorResponse(w, r, ErrInvalidRequestFormat, http.StatusBadRequest)
 		return
 	}
 
-	var resourceControlType api.ResourceControlType
-	switch req.Type {
-	case "container":
-		resourceControlType = api.ContainerResourceControl
-	case "service":
-		resourceControlType = api.ServiceResourceControl
-	case "volume":
-		resourceControlType = api.VolumeResourceControl
-	case "network":
-		resourceControlType = api.NetworkResourceControl
-	case "secret":
-		resourceControlType = api.SecretResourceControl
-	case "stack":
-		resourceControlType = api.StackResourceControl
-	case "config":
-		resourceControlType = api.ConfigResourceControl
-	default:
-		httperror.WriteErrorResponse(w, api.ErrInvalidResourceControlType, http.StatusBadRequest, handler.Logger)
+	resourceControlType, ok := resourceControlTypes[req.Type]
+	if !ok {
+		httperror.WriteErrorResponse(w, r, api.ErrInvalidResourceControlType, http.StatusBadRequest)
 		return
 	}
 
 	if len(req.Users) == 0 && len(req.Teams) == 0 && !req.AdministratorsOnly {
-		httperror.WriteErrorResponse(w, ErrInvalidRequestFormat, http.StatusBadRequest, handler.Logger)
+		httperror.WriteErrorResponse(w, r, ErrInvalidRequestFormat, http.StatusBadRequest)
 		return
 	}
 
 	rc, err := handler.ResourceControlService.ResourceControlByResourceID(req.ResourceID)
 	if err != nil && err != api.ErrResourceControlNotFound {
-		httperror.WriteErrorResponse(w, err, http.StatusInternalServerError, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
 		return
 	}
 	if rc != nil {
-		httperror.WriteErrorResponse(w, api.ErrResourceControlAlreadyExists, http.StatusConflict, handler.Logger)
+		httperror.WriteErrorResponse(w, r, api.ErrResourceControlAlreadyExists, http.StatusConflict)
 		return
 	}
 
@@ -135,18 +165,18 @@ func (handler *ResourceHandler) handlePostResources(w http.ResponseWriter, r *ht
 
 	securityContext, err := security.RetrieveRestrictedRequestContext(r)
 	if err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusInternalServerError, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
 		return
 	}
 
 	if !security.AuthorizedResourceControlCreation(&resourceControl, securityContext) {
-		httperror.WriteErrorResponse(w, api.ErrResourceAccessDenied, http.StatusForbidden, handler.Logger)
+		httperror.WriteErrorResponse(w, r, api.ErrResourceAccessDenied, http.StatusForbidden)
 		return
 	}
 
 	err = handler.ResourceControlService.CreateResourceControl(&resourceControl)
 	if err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusInternalServerError, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
 		return
 	}
 
@@ -160,29 +190,29 @@ func (handler *ResourceHandler) handlePutResources(w http.ResponseWriter, r *htt
 
 	resourceControlID, err := strconv.Atoi(id)
 	if err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusBadRequest, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusBadRequest)
 		return
 	}
 
 	var req putResourcesRequest
 	if err = json.NewDecoder(r.Body).Decode(&req); err != nil {
-		httperror.WriteErrorResponse(w, ErrInvalidJSON, http.StatusBadRequest, handler.Logger)
+		httperror.WriteErrorResponse(w, r, ErrInvalidJSON, http.StatusBadRequest)
 		return
 	}
 
 	_, err = govalidator.ValidateStruct(req)
 	if err != nil {
-		httperror.WriteErrorResponse(w, ErrInvalidRequestFormat, http.StatusBadRequest, handler.Logger)
+		httperror.WriteErrorResponse(w, r, ErrInvalidRequestFormat, http.StatusBadRequest)
 		return
 	}
 
 	resourceControl, err := handler.ResourceControlService.ResourceControl(api.ResourceControlID(resourceControlID))
 
 	if err == api.ErrResourceControlNotFound {
-		httperror.WriteErrorResponse(w, err, http.StatusNotFound, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusNotFound)
 		return
 	} else if err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusInternalServerError, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
 		return
 	}
 
@@ -210,18 +240,18 @@ func (handler *ResourceHandler) handlePutResources(w http.ResponseWriter, r *htt
 
 	securityContext, err := security.RetrieveRestrictedRequestContext(r)
 	if err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusInternalServerError, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
 		return
 	}
 
 	if !security.AuthorizedResourceControlUpdate(resourceControl, securityContext) {
-		httperror.WriteErrorResponse(w, api.ErrResourceAccessDenied, http.StatusForbidden, handler.Logger)
+		httperror.WriteErrorResponse(w, r, api.ErrResourceAccessDenied, http.StatusForbidden)
 		return
 	}
 
 	err = handler.ResourceControlService.UpdateResourceControl(resourceControl.ID, resourceControl)
 	if err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusInternalServerError, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
 		return
 	}
 }
@@ -233,34 +263,34 @@ func (handler *ResourceHandler) handleDeleteResources(w http.ResponseWriter, r *
 
 	resourceControlID, err := strconv.Atoi(id)
 	if err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusBadRequest, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusBadRequest)
 		return
 	}
 
 	resourceControl, err := handler.ResourceControlService.ResourceControl(api.ResourceControlID(resourceControlID))
 
 	if err == api.ErrResourceControlNotFound {
-		httperror.WriteErrorResponse(w, err, http.StatusNotFound, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusNotFound)
 		return
 	} else if err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusInternalServerError, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
 		return
 	}
 
 	securityContext, err := security.RetrieveRestrictedRequestContext(r)
 	if err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusInternalServerError, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
 		return
 	}
 
 	if !security.AuthorizedResourceControlDeletion(resourceControl, securityContext) {
-		httperror.WriteErrorResponse(w, api.ErrResourceAccessDenied, http.StatusForbidden, handler.Logger)
+		httperror.WriteErrorResponse(w, r, api.ErrResourceAccessDenied, http.StatusForbidden)
 		return
 	}
 
 	err = handler.ResourceControlService.DeleteResourceControl(api.ResourceControlID(resourceControlID))
 	if err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusInternalServerError, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
 		return
 	}
 }
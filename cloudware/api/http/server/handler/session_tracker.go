@@ -0,0 +1,76 @@
+package handler
+
+import (
+	"context"
+	"io"
+	"sync"
+)
+
+// SessionTracker keeps track of the long running sessions which are no longer
+// visible to the HTTP server once their connection is hijacked, such as the
+// websocket sessions, so that they can be drained when the server shuts down.
+type SessionTracker struct {
+	mu       sync.Mutex
+	sessions map[io.Closer]struct{}
+	closing  bool
+	done     chan struct{}
+}
+
+// NewSessionTracker returns a new instance of SessionTracker.
+func NewSessionTracker() *SessionTracker {
+	return &SessionTracker{
+		sessions: make(map[io.Closer]struct{}),
+	}
+}
+
+// Add registers a session, closed if it is still running when the drain deadline expires.
+// It returns false when the server is shutting down, the session must then not be started.
+func (tracker *SessionTracker) Add(session io.Closer) bool {
+	tracker.mu.Lock()
+	defer tracker.mu.Unlock()
+
+	if tracker.closing {
+		return false
+	}
+	tracker.sessions[session] = struct{}{}
+	return true
+}
+
+// Remove unregisters a session once it is over.
+func (tracker *SessionTracker) Remove(session io.Closer) {
+	tracker.mu.Lock()
+	defer tracker.mu.Unlock()
+
+	delete(tracker.sessions, session)
+	if tracker.closing && len(tracker.sessions) == 0 && tracker.done != nil {
+		close(tracker.done)
+		tracker.done = nil
+	}
+}
+
+// Shutdown refuses the new sessions and waits for the running ones to end.
+// The sessions still running when the context is done are closed and the context error is returned.
+func (tracker *SessionTracker) Shutdown(ctx context.Context) error {
+	tracker.mu.Lock()
+	tracker.closing = true
+	if len(tracker.sessions) == 0 {
+		tracker.mu.Unlock()
+		return nil
+	}
+	done := make(chan struct{})
+	tracker.done = done
+	tracker.mu.Unlock()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+	}
+
+	tracker.mu.Lock()
+	defer tracker.mu.Unlock()
+	for session := range tracker.sessions {
+		session.Close()
+	}
+	return ctx.Err()
+}
@@ -0,0 +1,94 @@
+package handler
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testSession struct {
+	mu     sync.Mutex
+	closed bool
+}
+
+func (session *testSession) Close() error {
+	session.mu.Lock()
+	defer session.mu.Unlock()
+	session.closed = true
+	return nil
+}
+
+func (session *testSession) isClosed() bool {
+	session.mu.Lock()
+	defer session.mu.Unlock()
+	return session.closed
+}
+
+func TestSessionTrackerWithoutSessions(t *testing.T) {
+	tracker := NewSessionTracker()
+	assert.NoError(t, tracker.Shutdown(context.Background()))
+	assert.False(t, tracker.Add(&testSession{}), "expected the sessions to be refused once shutting down")
+}
+
+func TestSessionTrackerDrain(t *testing.T) {
+	tracker := NewSessionTracker()
+	first, second := &testSession{}, &testSession{}
+	assert.True(t, tracker.Add(first))
+	assert.True(t, tracker.Add(second))
+
+	done := make(chan error, 1)
+	go func() {
+		done <- tracker.Shutdown(context.Background())
+	}()
+
+	// Shutdown is waiting once the new sessions are refused.
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		probe := &testSession{}
+		if !tracker.Add(probe) {
+			break
+		}
+		tracker.Remove(probe)
+		if time.Now().After(deadline) {
+			t.Fatal("expected the new sessions to be refused")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	tracker.Remove(first)
+	select {
+	case err := <-done:
+		t.Fatalf("expected Shutdown to wait for the running session, got %v", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	tracker.Remove(second)
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected Shutdown to return once the sessions ended")
+	}
+	assert.False(t, first.isClosed())
+	assert.False(t, second.isClosed())
+}
+
+func TestSessionTrackerDeadline(t *testing.T) {
+	tracker := NewSessionTracker()
+	ended, running := &testSession{}, &testSession{}
+	tracker.Add(ended)
+	tracker.Add(running)
+	tracker.Remove(ended)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, context.DeadlineExceeded, tracker.Shutdown(ctx))
+	assert.True(t, running.isClosed(), "expected the running session to be closed")
+	assert.False(t, ended.isClosed())
+
+	// Removing the closed session once its handler returns does not block.
+	tracker.Remove(running)
+}
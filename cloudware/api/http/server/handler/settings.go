@@ -9,17 +9,15 @@ import (
 	httperror "cloudware/cloudware/api/http/server/error"
 	"cloudware/cloudware/api/http/server/security"
 
-	"log"
 	"net/http"
-	"os"
 
 	"github.com/gorilla/mux"
+	"github.com/robfig/cron"
 )
 
 // SettingsHandler represents an HTTP API handler for managing Settings.
 type SettingsHandler struct {
 	*mux.Router
-	Logger          *log.Logger
 	SettingsService api.SettingsService
 	LDAPService     api.LDAPService
 	FileService     api.FileService
@@ -29,7 +27,6 @@ type SettingsHandler struct {
 func NewSettingsHandler(bouncer *security.RequestBouncer) *SettingsHandler {
 	h := &SettingsHandler{
 		Router: mux.NewRouter(),
-		Logger: log.New(os.Stderr, "", log.LstdFlags),
 	}
 	h.Handle("/settings",
 		bouncer.AdministratorAccess(http.HandlerFunc(h.handleGetSettings))).Methods(http.MethodGet)
@@ -60,13 +57,22 @@ type (
 		DisplayDonationHeader              bool                   `valid:""`
 		DisplayExternalContributors        bool                   `valid:""`
 		AuthenticationMethod               int                    `valid:"required"`
-		LDAPSettings                       api.LDAPSettings `valid:""`
+		LDAPSettings                       ldapSettingsRequest `valid:""`
 		AllowBindMountsForRegularUsers     bool                   `valid:""`
 		AllowPrivilegedModeForRegularUsers bool                   `valid:""`
+		SessionRecordingRetention          int                    `valid:""`
+		BackupSettings                     *api.BackupSettings    `valid:""`
 	}
 
 	putSettingsLDAPCheckRequest struct {
-		LDAPSettings api.LDAPSettings `valid:""`
+		LDAPSettings ldapSettingsRequest `valid:""`
+	}
+
+	// ldapSettingsRequest represents the LDAP settings sent by the client, the current
+	// password is kept when the password is omitted.
+	ldapSettingsRequest struct {
+		api.LDAPSettings
+		Password *string `json:"Password" valid:"-"`
 	}
 )
 
@@ -74,11 +80,14 @@ type (
 func (handler *SettingsHandler) handleGetSettings(w http.ResponseWriter, r *http.Request) {
 	settings, err := handler.SettingsService.Settings()
 	if err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusInternalServerError, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
 		return
 	}
 
-	encodeJSON(w, settings, handler.Logger)
+	settings.LDAPSettings.Password = ""
+	settings.BackupSettings.Password = ""
+	settings.BackupSettings.S3.SecretAccessKey = ""
+	encodeJSON(w, r, settings)
 	return
 }
 
@@ -86,7 +95,7 @@ func (handler *SettingsHandler) handleGetSettings(w http.ResponseWriter, r *http
 func (handler *SettingsHandler) handleGetPublicSettings(w http.ResponseWriter, r *http.Request) {
 	settings, err := handler.SettingsService.Settings()
 	if err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusInternalServerError, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
 		return
 	}
 
@@ -99,7 +108,7 @@ func (handler *SettingsHandler) handleGetPublicSettings(w http.ResponseWriter, r
 		AllowPrivilegedModeForRegularUsers: settings.AllowPrivilegedModeForRegularUsers,
 	}
 
-	encodeJSON(w, publicSettings, handler.Logger)
+	encodeJSON(w, r, publicSettings)
 	return
 }
 
@@ -107,13 +116,13 @@ func (handler *SettingsHandler) handleGetPublicSettings(w http.ResponseWriter, r
 func (handler *SettingsHandler) handlePutSettings(w http.ResponseWriter, r *http.Request) {
 	var req putSettingsRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		httperror.WriteErrorResponse(w, ErrInvalidJSON, http.StatusBadRequest, handler.Logger)
+		httperror.WriteErrorResponse(w, r, ErrInvalidJSON, http.StatusBadRequest)
 		return
 	}
 
 	_, err := govalidator.ValidateStruct(req)
 	if err != nil {
-		httperror.WriteErrorResponse(w, ErrInvalidRequestFormat, http.StatusBadRequest, handler.Logger)
+		httperror.WriteErrorResponse(w, r, ErrInvalidRequestFormat, http.StatusBadRequest)
 		return
 	}
 
@@ -123,9 +132,32 @@ func (handler *SettingsHandler) handlePutSettings(w http.ResponseWriter, r *http
 		BlackListedLabels:                  req.BlackListedLabels,
 		DisplayDonationHeader:              req.DisplayDonationHeader,
 		DisplayExternalContributors:        req.DisplayExternalContributors,
-		LDAPSettings:                       req.LDAPSettings,
+		LDAPSettings:                       req.LDAPSettings.LDAPSettings,
 		AllowBindMountsForRegularUsers:     req.AllowBindMountsForRegularUsers,
 		AllowPrivilegedModeForRegularUsers: req.AllowPrivilegedModeForRegularUsers,
+		SessionRecordingRetention:          req.SessionRecordingRetention,
+	}
+
+	if req.SessionRecordingRetention < 0 {
+		httperror.WriteErrorResponse(w, r, ErrInvalidRequestFormat, http.StatusBadRequest)
+		return
+	}
+
+	err = handler.keepLDAPPassword(&settings.LDAPSettings, req.LDAPSettings.Password)
+	if err != nil {
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
+		return
+	}
+
+	if req.BackupSettings != nil && !isValidBackupSettings(req.BackupSettings) {
+		httperror.WriteErrorResponse(w, r, ErrInvalidRequestFormat, http.StatusBadRequest)
+		return
+	}
+
+	err = handler.keepBackupSettings(settings, req.BackupSettings)
+	if err != nil {
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
+		return
 	}
 
 	if req.AuthenticationMethod == 1 {
@@ -133,7 +165,7 @@ func (handler *SettingsHandler) handlePutSettings(w http.ResponseWriter, r *http
 	} else if req.AuthenticationMethod == 2 {
 		settings.AuthenticationMethod = api.AuthenticationLDAP
 	} else {
-		httperror.WriteErrorResponse(w, ErrInvalidRequestFormat, http.StatusBadRequest, handler.Logger)
+		httperror.WriteErrorResponse(w, r, ErrInvalidRequestFormat, http.StatusBadRequest)
 		return
 	}
 
@@ -144,13 +176,13 @@ func (handler *SettingsHandler) handlePutSettings(w http.ResponseWriter, r *http
 		settings.LDAPSettings.TLSConfig.TLSCACertPath = ""
 		err := handler.FileService.DeleteTLSFiles(file.LDAPStorePath)
 		if err != nil {
-			httperror.WriteErrorResponse(w, err, http.StatusInternalServerError, handler.Logger)
+			httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
 		}
 	}
 
 	err = handler.SettingsService.StoreSettings(settings)
 	if err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusInternalServerError, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
 	}
 }
 
@@ -158,24 +190,97 @@ func (handler *SettingsHandler) handlePutSettings(w http.ResponseWriter, r *http
 func (handler *SettingsHandler) handlePutSettingsLDAPCheck(w http.ResponseWriter, r *http.Request) {
 	var req putSettingsLDAPCheckRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		httperror.WriteErrorResponse(w, ErrInvalidJSON, http.StatusBadRequest, handler.Logger)
+		httperror.WriteErrorResponse(w, r, ErrInvalidJSON, http.StatusBadRequest)
 		return
 	}
 
 	_, err := govalidator.ValidateStruct(req)
 	if err != nil {
-		httperror.WriteErrorResponse(w, ErrInvalidRequestFormat, http.StatusBadRequest, handler.Logger)
+		httperror.WriteErrorResponse(w, r, ErrInvalidRequestFormat, http.StatusBadRequest)
 		return
 	}
 
-	if (req.LDAPSettings.TLSConfig.TLS || req.LDAPSettings.StartTLS) && !req.LDAPSettings.TLSConfig.TLSSkipVerify {
+	ldapSettings := req.LDAPSettings.LDAPSettings
+	if (ldapSettings.TLSConfig.TLS || ldapSettings.StartTLS) && !ldapSettings.TLSConfig.TLSSkipVerify {
 		caCertPath, _ := handler.FileService.GetPathForTLSFile(file.LDAPStorePath, api.TLSFileCA)
-		req.LDAPSettings.TLSConfig.TLSCACertPath = caCertPath
+		ldapSettings.TLSConfig.TLSCACertPath = caCertPath
+	}
+
+	err = handler.keepLDAPPassword(&ldapSettings, req.LDAPSettings.Password)
+	if err != nil {
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
+		return
 	}
 
-	err = handler.LDAPService.TestConnectivity(&req.LDAPSettings)
+	err = handler.LDAPService.TestConnectivity(&ldapSettings)
 	if err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusInternalServerError, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
 		return
 	}
 }
+
+// keepLDAPPassword uses the stored LDAP password when the password is omitted, as the password
+// is never sent back to the client. An empty password clears the stored one.
+func (handler *SettingsHandler) keepLDAPPassword(ldapSettings *api.LDAPSettings, password *string) error {
+	if password != nil {
+		ldapSettings.Password = *password
+		return nil
+	}
+
+	settings, err := handler.SettingsService.Settings()
+	if err != nil {
+		return err
+	}
+
+	ldapSettings.Password = settings.LDAPSettings.Password
+	return nil
+}
+
+// keepBackupSettings uses the stored backup settings when none are specified. Otherwise the stored
+// backup password and S3 secret key are used when they are not specified, as they are never sent back to the client.
+func (handler *SettingsHandler) keepBackupSettings(settings *api.Settings, backupSettings *api.BackupSettings) error {
+	current, err := handler.SettingsService.Settings()
+	if err != nil {
+		return err
+	}
+
+	if backupSettings == nil {
+		settings.BackupSettings = current.BackupSettings
+		return nil
+	}
+
+	settings.BackupSettings = *backupSettings
+	if settings.BackupSettings.Password == "" {
+		settings.BackupSettings.Password = current.BackupSettings.Password
+	}
+	if settings.BackupSettings.S3.SecretAccessKey == "" {
+		settings.BackupSettings.S3.SecretAccessKey = current.BackupSettings.S3.SecretAccessKey
+	}
+	return nil
+}
+
+// isValidBackupSettings checks the schedule and the storage of the backup settings.
+// Backups are disabled when the schedule is empty, the storage is not checked in that case.
+func isValidBackupSettings(backupSettings *api.BackupSettings) bool {
+	if backupSettings.RetentionCount < 0 {
+		return false
+	}
+
+	if backupSettings.Schedule == "" {
+		return true
+	}
+
+	_, err := cron.ParseStandard(backupSettings.Schedule)
+	if err != nil {
+		return false
+	}
+
+	switch backupSettings.StorageType {
+	case api.BackupStorageLocal:
+		return backupSettings.LocalPath != ""
+	case api.BackupStorageS3:
+		s3 := backupSettings.S3
+		return govalidator.IsURL(s3.Endpoint) && s3.Bucket != "" && s3.AccessKeyID != ""
+	}
+	return false
+}
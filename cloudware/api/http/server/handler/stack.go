@@ -14,19 +14,15 @@ import (
 	"cloudware/cloudware/api/http/server/proxy"
 	"cloudware/cloudware/api/http/server/security"
 
-	"log"
 	"net/http"
-	"os"
 
 	"github.com/gorilla/mux"
 )
 
 // StackHandler represents an HTTP API handler for managing Stack.
 type StackHandler struct {
-	stackCreationMutex *sync.Mutex
 	stackDeletionMutex *sync.Mutex
 	*mux.Router
-	Logger                 *log.Logger
 	FileService            api.FileService
 	GitService             api.GitService
 	StackService           api.StackService
@@ -41,9 +37,7 @@ type StackHandler struct {
 func NewStackHandler(bouncer *security.RequestBouncer) *StackHandler {
 	h := &StackHandler{
 		Router:             mux.NewRouter(),
-		stackCreationMutex: &sync.Mutex{},
 		stackDeletionMutex: &sync.Mutex{},
-		Logger:             log.New(os.Stderr, "", log.LstdFlags),
 	}
 	h.Handle("/{endpointId}/stacks",
 		bouncer.RestrictedAccess(http.HandlerFunc(h.handlePostStacks))).Methods(http.MethodPost)
@@ -76,8 +70,14 @@ type (
 		StackFileContent string `json:"StackFileContent"`
 	}
 	putStackRequest struct {
-		StackFileContent string           `valid:"required"`
-		Env              []api.Pair `valid:""`
+		StackFileContent string             `valid:"required"`
+		Env              []stackEnvVariable `valid:""`
+	}
+	// stackEnvVariable represents an environment variable of a stack update, the current
+	// value of the variable is kept when its value is omitted.
+	stackEnvVariable struct {
+		Name  string  `json:"name"`
+		Value *string `json:"value"`
 	}
 )
 
@@ -85,7 +85,7 @@ type (
 func (handler *StackHandler) handlePostStacks(w http.ResponseWriter, r *http.Request) {
 	method := r.FormValue("method")
 	if method == "" {
-		httperror.WriteErrorResponse(w, ErrInvalidQueryFormat, http.StatusBadRequest, handler.Logger)
+		httperror.WriteErrorResponse(w, r, ErrInvalidQueryFormat, http.StatusBadRequest)
 		return
 	}
 
@@ -96,7 +96,7 @@ func (handler *StackHandler) handlePostStacks(w http.ResponseWriter, r *http.Req
 	} else if method == "file" {
 		handler.handlePostStacksFileMethod(w, r)
 	} else {
-		httperror.WriteErrorResponse(w, ErrInvalidRequestFormat, http.StatusBadRequest, handler.Logger)
+		httperror.WriteErrorResponse(w, r, ErrInvalidRequestFormat, http.StatusBadRequest)
 		return
 	}
 }
@@ -105,59 +105,59 @@ func (handler *StackHandler) handlePostStacksStringMethod(w http.ResponseWriter,
 	vars := mux.Vars(r)
 	id, err := strconv.Atoi(vars["endpointId"])
 	if err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusBadRequest, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusBadRequest)
 		return
 	}
 	endpointID := api.EndpointID(id)
 
 	endpoint, err := handler.EndpointService.Endpoint(endpointID)
 	if err == api.ErrEndpointNotFound {
-		httperror.WriteErrorResponse(w, err, http.StatusNotFound, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusNotFound)
 		return
 	} else if err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusInternalServerError, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
 		return
 	}
 
 	var req postStacksRequest
 	if err = json.NewDecoder(r.Body).Decode(&req); err != nil {
-		httperror.WriteErrorResponse(w, ErrInvalidJSON, http.StatusBadRequest, handler.Logger)
+		httperror.WriteErrorResponse(w, r, ErrInvalidJSON, http.StatusBadRequest)
 		return
 	}
 
 	_, err = govalidator.ValidateStruct(req)
 	if err != nil {
-		httperror.WriteErrorResponse(w, ErrInvalidRequestFormat, http.StatusBadRequest, handler.Logger)
+		httperror.WriteErrorResponse(w, r, ErrInvalidRequestFormat, http.StatusBadRequest)
 		return
 	}
 
 	stackName := req.Name
 	if stackName == "" {
-		httperror.WriteErrorResponse(w, ErrInvalidRequestFormat, http.StatusBadRequest, handler.Logger)
+		httperror.WriteErrorResponse(w, r, ErrInvalidRequestFormat, http.StatusBadRequest)
 		return
 	}
 
 	stackFileContent := req.StackFileContent
 	if stackFileContent == "" {
-		httperror.WriteErrorResponse(w, ErrInvalidRequestFormat, http.StatusBadRequest, handler.Logger)
+		httperror.WriteErrorResponse(w, r, ErrInvalidRequestFormat, http.StatusBadRequest)
 		return
 	}
 
 	swarmID := req.SwarmID
 	if swarmID == "" {
-		httperror.WriteErrorResponse(w, ErrInvalidRequestFormat, http.StatusBadRequest, handler.Logger)
+		httperror.WriteErrorResponse(w, r, ErrInvalidRequestFormat, http.StatusBadRequest)
 		return
 	}
 
 	stacks, err := handler.StackService.Stacks()
 	if err != nil && err != api.ErrStackNotFound {
-		httperror.WriteErrorResponse(w, err, http.StatusInternalServerError, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
 		return
 	}
 
 	for _, stack := range stacks {
 		if strings.EqualFold(stack.Name, stackName) {
-			httperror.WriteErrorResponse(w, api.ErrStackAlreadyExists, http.StatusConflict, handler.Logger)
+			httperror.WriteErrorResponse(w, r, api.ErrStackAlreadyExists, http.StatusConflict)
 			return
 		}
 	}
@@ -172,94 +172,94 @@ func (handler *StackHandler) handlePostStacksStringMethod(w http.ResponseWriter,
 
 	projectPath, err := handler.FileService.StoreStackFileFromString(string(stack.ID), stackFileContent)
 	if err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusInternalServerError, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
 		return
 	}
 	stack.ProjectPath = projectPath
 
 	err = handler.StackService.CreateStack(stack)
 	if err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusInternalServerError, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
 		return
 	}
 
 	securityContext, err := security.RetrieveRestrictedRequestContext(r)
 	if err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusInternalServerError, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
 		return
 	}
 
 	dockerhub, err := handler.DockerHubService.DockerHub()
 	if err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusInternalServerError, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
 		return
 	}
 
 	registries, err := handler.RegistryService.Registries()
 	if err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusInternalServerError, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
 		return
 	}
 
 	filteredRegistries, err := security.FilterRegistries(registries, securityContext)
 	if err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusInternalServerError, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
 		return
 	}
 
-	err = handler.deployStack(endpoint, stack, dockerhub, filteredRegistries)
+	err = deployStack(handler.StackManager, endpoint, stack, dockerhub, filteredRegistries)
 	if err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusInternalServerError, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
 		return
 	}
 
-	encodeJSON(w, &postStacksResponse{ID: string(stack.ID)}, handler.Logger)
+	encodeJSON(w, r, &postStacksResponse{ID: string(stack.ID)})
 }
 
 func (handler *StackHandler) handlePostStacksRepositoryMethod(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id, err := strconv.Atoi(vars["endpointId"])
 	if err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusBadRequest, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusBadRequest)
 		return
 	}
 	endpointID := api.EndpointID(id)
 
 	endpoint, err := handler.EndpointService.Endpoint(endpointID)
 	if err == api.ErrEndpointNotFound {
-		httperror.WriteErrorResponse(w, err, http.StatusNotFound, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusNotFound)
 		return
 	} else if err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusInternalServerError, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
 		return
 	}
 
 	var req postStacksRequest
 	if err = json.NewDecoder(r.Body).Decode(&req); err != nil {
-		httperror.WriteErrorResponse(w, ErrInvalidJSON, http.StatusBadRequest, handler.Logger)
+		httperror.WriteErrorResponse(w, r, ErrInvalidJSON, http.StatusBadRequest)
 		return
 	}
 
 	_, err = govalidator.ValidateStruct(req)
 	if err != nil {
-		httperror.WriteErrorResponse(w, ErrInvalidRequestFormat, http.StatusBadRequest, handler.Logger)
+		httperror.WriteErrorResponse(w, r, ErrInvalidRequestFormat, http.StatusBadRequest)
 		return
 	}
 
 	stackName := req.Name
 	if stackName == "" {
-		httperror.WriteErrorResponse(w, ErrInvalidRequestFormat, http.StatusBadRequest, handler.Logger)
+		httperror.WriteErrorResponse(w, r, ErrInvalidRequestFormat, http.StatusBadRequest)
 		return
 	}
 
 	swarmID := req.SwarmID
 	if swarmID == "" {
-		httperror.WriteErrorResponse(w, ErrInvalidRequestFormat, http.StatusBadRequest, handler.Logger)
+		httperror.WriteErrorResponse(w, r, ErrInvalidRequestFormat, http.StatusBadRequest)
 		return
 	}
 
 	if req.GitRepository == "" {
-		httperror.WriteErrorResponse(w, ErrInvalidRequestFormat, http.StatusBadRequest, handler.Logger)
+		httperror.WriteErrorResponse(w, r, ErrInvalidRequestFormat, http.StatusBadRequest)
 		return
 	}
 
@@ -269,13 +269,13 @@ func (handler *StackHandler) handlePostStacksRepositoryMethod(w http.ResponseWri
 
 	stacks, err := handler.StackService.Stacks()
 	if err != nil && err != api.ErrStackNotFound {
-		httperror.WriteErrorResponse(w, err, http.StatusInternalServerError, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
 		return
 	}
 
 	for _, stack := range stacks {
 		if strings.EqualFold(stack.Name, stackName) {
-			httperror.WriteErrorResponse(w, api.ErrStackAlreadyExists, http.StatusConflict, handler.Logger)
+			httperror.WriteErrorResponse(w, r, api.ErrStackAlreadyExists, http.StatusConflict)
 			return
 		}
 	}
@@ -294,108 +294,108 @@ func (handler *StackHandler) handlePostStacksRepositoryMethod(w http.ResponseWri
 	// Ensure projectPath is empty
 	err = handler.FileService.RemoveDirectory(projectPath)
 	if err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusInternalServerError, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
 		return
 	}
 
 	err = handler.GitService.CloneRepository(req.GitRepository, projectPath)
 	if err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusInternalServerError, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
 		return
 	}
 
 	err = handler.StackService.CreateStack(stack)
 	if err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusInternalServerError, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
 		return
 	}
 
 	securityContext, err := security.RetrieveRestrictedRequestContext(r)
 	if err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusInternalServerError, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
 		return
 	}
 
 	dockerhub, err := handler.DockerHubService.DockerHub()
 	if err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusInternalServerError, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
 		return
 	}
 
 	registries, err := handler.RegistryService.Registries()
 	if err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusInternalServerError, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
 		return
 	}
 
 	filteredRegistries, err := security.FilterRegistries(registries, securityContext)
 	if err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusInternalServerError, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
 		return
 	}
 
-	err = handler.deployStack(endpoint, stack, dockerhub, filteredRegistries)
+	err = deployStack(handler.StackManager, endpoint, stack, dockerhub, filteredRegistries)
 	if err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusInternalServerError, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
 		return
 	}
 
-	encodeJSON(w, &postStacksResponse{ID: string(stack.ID)}, handler.Logger)
+	encodeJSON(w, r, &postStacksResponse{ID: string(stack.ID)})
 }
 
 func (handler *StackHandler) handlePostStacksFileMethod(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id, err := strconv.Atoi(vars["endpointId"])
 	if err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusBadRequest, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusBadRequest)
 		return
 	}
 	endpointID := api.EndpointID(id)
 
 	endpoint, err := handler.EndpointService.Endpoint(endpointID)
 	if err == api.ErrEndpointNotFound {
-		httperror.WriteErrorResponse(w, err, http.StatusNotFound, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusNotFound)
 		return
 	} else if err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusInternalServerError, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
 		return
 	}
 
 	stackName := r.FormValue("Name")
 	if stackName == "" {
-		httperror.WriteErrorResponse(w, ErrInvalidRequestFormat, http.StatusBadRequest, handler.Logger)
+		httperror.WriteErrorResponse(w, r, ErrInvalidRequestFormat, http.StatusBadRequest)
 		return
 	}
 
 	swarmID := r.FormValue("SwarmID")
 	if swarmID == "" {
-		httperror.WriteErrorResponse(w, ErrInvalidRequestFormat, http.StatusBadRequest, handler.Logger)
+		httperror.WriteErrorResponse(w, r, ErrInvalidRequestFormat, http.StatusBadRequest)
 		return
 	}
 
 	envParam := r.FormValue("Env")
 	var env []api.Pair
 	if err = json.Unmarshal([]byte(envParam), &env); err != nil {
-		httperror.WriteErrorResponse(w, ErrInvalidRequestFormat, http.StatusBadRequest, handler.Logger)
+		httperror.WriteErrorResponse(w, r, ErrInvalidRequestFormat, http.StatusBadRequest)
 		return
 	}
 
 	stackFile, _, err := r.FormFile("file")
 	if err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusInternalServerError, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
 		return
 	}
 	defer stackFile.Close()
 
 	stacks, err := handler.StackService.Stacks()
 	if err != nil && err != api.ErrStackNotFound {
-		httperror.WriteErrorResponse(w, err, http.StatusInternalServerError, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
 		return
 	}
 
 	for _, stack := range stacks {
 		if strings.EqualFold(stack.Name, stackName) {
-			httperror.WriteErrorResponse(w, api.ErrStackAlreadyExists, http.StatusConflict, handler.Logger)
+			httperror.WriteErrorResponse(w, r, api.ErrStackAlreadyExists, http.StatusConflict)
 			return
 		}
 	}
@@ -410,48 +410,48 @@ func (handler *StackHandler) handlePostStacksFileMethod(w http.ResponseWriter, r
 
 	projectPath, err := handler.FileService.StoreStackFileFromReader(string(stack.ID), stackFile)
 	if err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusInternalServerError, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
 		return
 	}
 	stack.ProjectPath = projectPath
 
 	err = handler.StackService.CreateStack(stack)
 	if err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusInternalServerError, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
 		return
 	}
 
 	securityContext, err := security.RetrieveRestrictedRequestContext(r)
 	if err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusInternalServerError, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
 		return
 	}
 
 	dockerhub, err := handler.DockerHubService.DockerHub()
 	if err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusInternalServerError, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
 		return
 	}
 
 	registries, err := handler.RegistryService.Registries()
 	if err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusInternalServerError, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
 		return
 	}
 
 	filteredRegistries, err := security.FilterRegistries(registries, securityContext)
 	if err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusInternalServerError, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
 		return
 	}
 
-	err = handler.deployStack(endpoint, stack, dockerhub, filteredRegistries)
+	err = deployStack(handler.StackManager, endpoint, stack, dockerhub, filteredRegistries)
 	if err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusInternalServerError, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
 		return
 	}
 
-	encodeJSON(w, &postStacksResponse{ID: string(stack.ID)}, handler.Logger)
+	encodeJSON(w, r, &postStacksResponse{ID: string(stack.ID)})
 }
 
 // handleGetStacks handles GET requests on /:endpointId/stacks?swarmId=<swarmId>
@@ -462,47 +462,59 @@ func (handler *StackHandler) handleGetStacks(w http.ResponseWriter, r *http.Requ
 
 	securityContext, err := security.RetrieveRestrictedRequestContext(r)
 	if err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusInternalServerError, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
 		return
 	}
 
 	id, err := strconv.Atoi(vars["endpointId"])
 	if err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusBadRequest, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusBadRequest)
 		return
 	}
 	endpointID := api.EndpointID(id)
 
 	_, err = handler.EndpointService.Endpoint(endpointID)
 	if err == api.ErrEndpointNotFound {
-		httperror.WriteErrorResponse(w, err, http.StatusNotFound, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusNotFound)
 		return
 	} else if err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusInternalServerError, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
 		return
 	}
 
-	var stacks []api.Stack
-	if swarmID == "" {
-		stacks, err = handler.StackService.Stacks()
-	} else {
-		stacks, err = handler.StackService.StacksBySwarmID(swarmID)
-	}
+	options, err := parseListOptions(r)
 	if err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusInternalServerError, handler.Logger)
+		httperror.WriteErrorResponse(w, r, ErrInvalidQueryFormat, http.StatusBadRequest)
 		return
 	}
 
-	resourceControls, err := handler.ResourceControlService.ResourceControls()
+	stacks, total, err := handler.StackService.ListStacks(&api.StackQuery{
+		ListOptions: options,
+		SwarmID:     swarmID,
+		Access:      security.ListAccessFilter(securityContext),
+	})
 	if err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusInternalServerError, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
 		return
 	}
 
-	filteredStacks := proxy.FilterStacks(stacks, resourceControls, securityContext.IsAdmin,
-		securityContext.UserID, securityContext.UserMemberships)
+	extendedStacks := make([]proxy.ExtendedStack, 0, len(stacks))
+	for _, stack := range stacks {
+		extendedStack := proxy.ExtendedStack{Stack: stack}
+		extendedStack.Env = hideStackEnv(stack.Env)
+
+		resourceControl, err := handler.ResourceControlService.ResourceControlByResourceID(stack.Name)
+		if err == nil {
+			extendedStack.ResourceControl = *resourceControl
+		} else if err != api.ErrResourceControlNotFound {
+			httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
+			return
+		}
+		extendedStacks = append(extendedStacks, extendedStack)
+	}
 
-	encodeJSON(w, filteredStacks, handler.Logger)
+	writeTotalCount(w, total)
+	encodeJSON(w, r, extendedStacks)
 }
 
 // handleGetStack handles GET requests on /:endpointId/stacks/:id
@@ -512,37 +524,37 @@ func (handler *StackHandler) handleGetStack(w http.ResponseWriter, r *http.Reque
 
 	securityContext, err := security.RetrieveRestrictedRequestContext(r)
 	if err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusInternalServerError, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
 		return
 	}
 
 	endpointID, err := strconv.Atoi(vars["endpointId"])
 	if err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusBadRequest, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusBadRequest)
 		return
 	}
 
 	_, err = handler.EndpointService.Endpoint(api.EndpointID(endpointID))
 	if err == api.ErrEndpointNotFound {
-		httperror.WriteErrorResponse(w, err, http.StatusNotFound, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusNotFound)
 		return
 	} else if err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusInternalServerError, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
 		return
 	}
 
 	stack, err := handler.StackService.Stack(api.StackID(stackID))
 	if err == api.ErrStackNotFound {
-		httperror.WriteErrorResponse(w, err, http.StatusNotFound, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusNotFound)
 		return
 	} else if err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusInternalServerError, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
 		return
 	}
 
 	resourceControl, err := handler.ResourceControlService.ResourceControlByResourceID(stack.Name)
 	if err != nil && err != api.ErrResourceControlNotFound {
-		httperror.WriteErrorResponse(w, err, http.StatusInternalServerError, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
 		return
 	}
 
@@ -551,12 +563,13 @@ func (handler *StackHandler) handleGetStack(w http.ResponseWriter, r *http.Reque
 		if securityContext.IsAdmin || proxy.CanAccessStack(stack, resourceControl, securityContext.UserID, securityContext.UserMemberships) {
 			extendedStack.ResourceControl = *resourceControl
 		} else {
-			httperror.WriteErrorResponse(w, api.ErrResourceAccessDenied, http.StatusForbidden, handler.Logger)
+			httperror.WriteErrorResponse(w, r, api.ErrResourceAccessDenied, http.StatusForbidden)
 			return
 		}
 	}
 
-	encodeJSON(w, extendedStack, handler.Logger)
+	extendedStack.Env = hideStackEnv(extendedStack.Env)
+	encodeJSON(w, r, extendedStack)
 }
 
 // handlePutStack handles PUT requests on /:endpointId/stacks/:id
@@ -566,80 +579,80 @@ func (handler *StackHandler) handlePutStack(w http.ResponseWriter, r *http.Reque
 
 	endpointID, err := strconv.Atoi(vars["endpointId"])
 	if err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusBadRequest, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusBadRequest)
 		return
 	}
 
 	endpoint, err := handler.EndpointService.Endpoint(api.EndpointID(endpointID))
 	if err == api.ErrEndpointNotFound {
-		httperror.WriteErrorResponse(w, err, http.StatusNotFound, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusNotFound)
 		return
 	} else if err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusInternalServerError, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
 		return
 	}
 
 	stack, err := handler.StackService.Stack(api.StackID(stackID))
 	if err == api.ErrStackNotFound {
-		httperror.WriteErrorResponse(w, err, http.StatusNotFound, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusNotFound)
 		return
 	} else if err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusInternalServerError, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
 		return
 	}
 
 	var req putStackRequest
 	if err = json.NewDecoder(r.Body).Decode(&req); err != nil {
-		httperror.WriteErrorResponse(w, ErrInvalidJSON, http.StatusBadRequest, handler.Logger)
+		httperror.WriteErrorResponse(w, r, ErrInvalidJSON, http.StatusBadRequest)
 		return
 	}
 
 	_, err = govalidator.ValidateStruct(req)
 	if err != nil {
-		httperror.WriteErrorResponse(w, ErrInvalidRequestFormat, http.StatusBadRequest, handler.Logger)
+		httperror.WriteErrorResponse(w, r, ErrInvalidRequestFormat, http.StatusBadRequest)
 		return
 	}
-	stack.Env = req.Env
+	stack.Env = mergeStackEnv(req.Env, stack.Env)
 
 	_, err = handler.FileService.StoreStackFileFromString(string(stack.ID), req.StackFileContent)
 	if err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusInternalServerError, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
 		return
 	}
 
 	err = handler.StackService.UpdateStack(stack.ID, stack)
 	if err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusInternalServerError, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
 		return
 	}
 
 	securityContext, err := security.RetrieveRestrictedRequestContext(r)
 	if err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusInternalServerError, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
 		return
 	}
 
 	dockerhub, err := handler.DockerHubService.DockerHub()
 	if err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusInternalServerError, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
 		return
 	}
 
 	registries, err := handler.RegistryService.Registries()
 	if err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusInternalServerError, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
 		return
 	}
 
 	filteredRegistries, err := security.FilterRegistries(registries, securityContext)
 	if err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusInternalServerError, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
 		return
 	}
 
-	err = handler.deployStack(endpoint, stack, dockerhub, filteredRegistries)
+	err = deployStack(handler.StackManager, endpoint, stack, dockerhub, filteredRegistries)
 	if err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusInternalServerError, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
 		return
 	}
 }
@@ -651,35 +664,35 @@ func (handler *StackHandler) handleGetStackFile(w http.ResponseWriter, r *http.R
 
 	endpointID, err := strconv.Atoi(vars["endpointId"])
 	if err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusBadRequest, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusBadRequest)
 		return
 	}
 
 	_, err = handler.EndpointService.Endpoint(api.EndpointID(endpointID))
 	if err == api.ErrEndpointNotFound {
-		httperror.WriteErrorResponse(w, err, http.StatusNotFound, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusNotFound)
 		return
 	} else if err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusInternalServerError, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
 		return
 	}
 
 	stack, err := handler.StackService.Stack(api.StackID(stackID))
 	if err == api.ErrStackNotFound {
-		httperror.WriteErrorResponse(w, err, http.StatusNotFound, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusNotFound)
 		return
 	} else if err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusInternalServerError, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
 		return
 	}
 
 	stackFileContent, err := handler.FileService.GetFileContent(path.Join(stack.ProjectPath, stack.EntryPoint))
 	if err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusBadRequest, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusBadRequest)
 		return
 	}
 
-	encodeJSON(w, &getStackFileResponse{StackFileContent: stackFileContent}, handler.Logger)
+	encodeJSON(w, r, &getStackFileResponse{StackFileContent: stackFileContent})
 }
 
 // handleDeleteStack handles DELETE requests on /:endpointId/stacks/:id
@@ -689,70 +702,96 @@ func (handler *StackHandler) handleDeleteStack(w http.ResponseWriter, r *http.Re
 
 	endpointID, err := strconv.Atoi(vars["endpointId"])
 	if err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusBadRequest, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusBadRequest)
 		return
 	}
 
 	endpoint, err := handler.EndpointService.Endpoint(api.EndpointID(endpointID))
 	if err == api.ErrEndpointNotFound {
-		httperror.WriteErrorResponse(w, err, http.StatusNotFound, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusNotFound)
 		return
 	} else if err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusInternalServerError, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
 		return
 	}
 
 	stack, err := handler.StackService.Stack(api.StackID(stackID))
 	if err == api.ErrStackNotFound {
-		httperror.WriteErrorResponse(w, err, http.StatusNotFound, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusNotFound)
 		return
 	} else if err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusInternalServerError, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
 		return
 	}
 
 	handler.stackDeletionMutex.Lock()
 	err = handler.StackManager.Remove(stack, endpoint)
+	handler.stackDeletionMutex.Unlock()
 	if err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusInternalServerError, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
 		return
 	}
-	handler.stackDeletionMutex.Unlock()
 
 	err = handler.StackService.DeleteStack(api.StackID(stackID))
 	if err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusInternalServerError, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
 		return
 	}
 
 	err = handler.FileService.RemoveDirectory(stack.ProjectPath)
 	if err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusInternalServerError, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
 		return
 	}
 }
 
-func (handler *StackHandler) deployStack(endpoint *api.Endpoint, stack *api.Stack, dockerhub *api.DockerHub, registries []api.Registry) error {
-	handler.stackCreationMutex.Lock()
+// hideStackEnv returns the environment variables of a stack without their values,
+// as they can contain secrets that are never sent back to the client.
+func hideStackEnv(env []api.Pair) []api.Pair {
+	hidden := make([]api.Pair, len(env))
+	for idx, pair := range env {
+		hidden[idx] = api.Pair{Name: pair.Name}
+	}
+	return hidden
+}
 
-	err := handler.StackManager.Login(dockerhub, registries, endpoint)
-	if err != nil {
-		handler.stackCreationMutex.Unlock()
-		return err
+// mergeStackEnv keeps the current value of the environment variables specified without value,
+// a variable specified with an empty value is cleared.
+func mergeStackEnv(env []stackEnvVariable, current []api.Pair) []api.Pair {
+	values := make(map[string]string)
+	for _, pair := range current {
+		values[pair.Name] = pair.Value
 	}
 
-	err = handler.StackManager.Deploy(stack, endpoint)
+	merged := make([]api.Pair, len(env))
+	for idx, variable := range env {
+		pair := api.Pair{Name: variable.Name, Value: values[variable.Name]}
+		if variable.Value != nil {
+			pair.Value = *variable.Value
+		}
+		merged[idx] = pair
+	}
+	return merged
+}
+
+// stackDeploymentMutex serializes the stack deployments of the handlers, the registry
+// credentials of a deployment are stored in the configuration shared by the docker commands.
+var stackDeploymentMutex sync.Mutex
+
+// deployStack deploys a stack on an endpoint, logged in the registries.
+func deployStack(manager api.StackManager, endpoint *api.Endpoint, stack *api.Stack, dockerhub *api.DockerHub, registries []api.Registry) error {
+	stackDeploymentMutex.Lock()
+	defer stackDeploymentMutex.Unlock()
+
+	err := manager.Login(dockerhub, registries, endpoint)
 	if err != nil {
-		handler.stackCreationMutex.Unlock()
 		return err
 	}
 
-	err = handler.StackManager.Logout(endpoint)
+	err = manager.Deploy(stack, endpoint)
 	if err != nil {
-		handler.stackCreationMutex.Unlock()
 		return err
 	}
 
-	handler.stackCreationMutex.Unlock()
-	return nil
+	return manager.Logout(endpoint)
 }
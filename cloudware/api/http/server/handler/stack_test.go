@@ -0,0 +1,41 @@
+package handler
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"cloudware/cloudware/api"
+)
+
+func TestMergeStackEnv(t *testing.T) {
+	var req putStackRequest
+	err := json.Unmarshal([]byte(`{"StackFileContent":"version: '3'","Env":[
+		{"name":"KEPT"},
+		{"name":"KEPT_NULL","value":null},
+		{"name":"UPDATED","value":"new"},
+		{"name":"CLEARED","value":""},
+		{"name":"ADDED","value":"added"},
+		{"name":"ADDED_WITHOUT_VALUE"}
+	]}`), &req)
+	require.NoError(t, err)
+
+	current := []api.Pair{
+		{Name: "KEPT", Value: "kept"},
+		{Name: "KEPT_NULL", Value: "kept"},
+		{Name: "UPDATED", Value: "old"},
+		{Name: "CLEARED", Value: "secret"},
+		{Name: "REMOVED", Value: "removed"},
+	}
+
+	assert.Equal(t, []api.Pair{
+		{Name: "KEPT", Value: "kept"},
+		{Name: "KEPT_NULL", Value: "kept"},
+		{Name: "UPDATED", Value: "new"},
+		{Name: "CLEARED", Value: ""},
+		{Name: "ADDED", Value: "added"},
+		{Name: "ADDED_WITHOUT_VALUE", Value: ""},
+	}, mergeStackEnv(req.Env, current))
+}
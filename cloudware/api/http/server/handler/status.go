@@ -4,9 +4,7 @@ import (
 	"cloudware/cloudware/api"
 	"cloudware/cloudware/api/http/server/security"
 
-	"log"
 	"net/http"
-	"os"
 
 	"github.com/gorilla/mux"
 )
@@ -14,7 +12,6 @@ import (
 // StatusHandler represents an HTTP API handler for managing Status.
 type StatusHandler struct {
 	*mux.Router
-	Logger *log.Logger
 	Status *api.Status
 }
 
@@ -22,7 +19,6 @@ type StatusHandler struct {
 func NewStatusHandler(bouncer *security.RequestBouncer, status *api.Status) *StatusHandler {
 	h := &StatusHandler{
 		Router: mux.NewRouter(),
-		Logger: log.New(os.Stderr, "", log.LstdFlags),
 		Status: status,
 	}
 	h.Handle("/status",
@@ -33,6 +29,6 @@ func NewStatusHandler(bouncer *security.RequestBouncer, status *api.Status) *Sta
 
 // handleGetStatus handles GET requests on /status
 func (handler *StatusHandler) handleGetStatus(w http.ResponseWriter, r *http.Request) {
-	encodeJSON(w, handler.Status, handler.Logger)
+	encodeJSON(w, r, handler.Status)
 	return
 }
@@ -8,9 +8,7 @@ import (
 	"cloudware/cloudware/api/http/server/security"
 
 	"encoding/json"
-	"log"
 	"net/http"
-	"os"
 
 	"github.com/asaskevich/govalidator"
 	"github.com/gorilla/mux"
@@ -19,7 +17,6 @@ import (
 // TeamHandler represents an HTTP API handler for managing teams.
 type TeamHandler struct {
 	*mux.Router
-	Logger                 *log.Logger
 	TeamService            api.TeamService
 	TeamMembershipService  api.TeamMembershipService
 	ResourceControlService api.ResourceControlService
@@ -29,7 +26,6 @@ type TeamHandler struct {
 func NewTeamHandler(bouncer *security.RequestBouncer) *TeamHandler {
 	h := &TeamHandler{
 		Router: mux.NewRouter(),
-		Logger: log.New(os.Stderr, "", log.LstdFlags),
 	}
 	h.Handle("/teams",
 		bouncer.AdministratorAccess(http.HandlerFunc(h.handlePostTeams))).Methods(http.MethodPost)
@@ -65,23 +61,23 @@ type (
 func (handler *TeamHandler) handlePostTeams(w http.ResponseWriter, r *http.Request) {
 	var req postTeamsRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		httperror.WriteErrorResponse(w, ErrInvalidJSON, http.StatusBadRequest, handler.Logger)
+		httperror.WriteErrorResponse(w, r, ErrInvalidJSON, http.StatusBadRequest)
 		return
 	}
 
 	_, err := govalidator.ValidateStruct(req)
 	if err != nil {
-		httperror.WriteErrorResponse(w, ErrInvalidRequestFormat, http.StatusBadRequest, handler.Logger)
+		httperror.WriteErrorResponse(w, r, ErrInvalidRequestFormat, http.StatusBadRequest)
 		return
 	}
 
 	team, err := handler.TeamService.TeamByName(req.Name)
 	if err != nil && err != api.ErrTeamNotFound {
-		httperror.WriteErrorResponse(w, err, http.StatusInternalServerError, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
 		return
 	}
 	if team != nil {
-		httperror.WriteErrorResponse(w, api.ErrTeamAlreadyExists, http.StatusConflict, handler.Logger)
+		httperror.WriteErrorResponse(w, r, api.ErrTeamAlreadyExists, http.StatusConflict)
 		return
 	}
 
@@ -91,30 +87,38 @@ func (handler *TeamHandler) handlePostTeams(w http.ResponseWriter, r *http.Reque
 
 	err = handler.TeamService.CreateTeam(team)
 	if err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusInternalServerError, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
 		return
 	}
 
-	encodeJSON(w, &postTeamsResponse{ID: int(team.ID)}, handler.Logger)
+	encodeJSON(w, r, &postTeamsResponse{ID: int(team.ID)})
 }
 
 // handleGetTeams handles GET requests on /teams
 func (handler *TeamHandler) handleGetTeams(w http.ResponseWriter, r *http.Request) {
 	securityContext, err := security.RetrieveRestrictedRequestContext(r)
 	if err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusInternalServerError, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
 		return
 	}
 
-	teams, err := handler.TeamService.Teams()
+	options, err := parseListOptions(r)
 	if err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusInternalServerError, handler.Logger)
+		httperror.WriteErrorResponse(w, r, ErrInvalidQueryFormat, http.StatusBadRequest)
 		return
 	}
 
-	filteredTeams := security.FilterUserTeams(teams, securityContext)
+	teams, total, err := handler.TeamService.ListTeams(&api.TeamQuery{
+		ListOptions: options,
+		TeamIDs:     security.ListTeamIDs(securityContext),
+	})
+	if err != nil {
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
+		return
+	}
 
-	encodeJSON(w, filteredTeams, handler.Logger)
+	writeTotalCount(w, total)
+	encodeJSON(w, r, teams)
 }
 
 // handleGetTeam handles GET requests on /teams/:id
@@ -124,32 +128,32 @@ func (handler *TeamHandler) handleGetTeam(w http.ResponseWriter, r *http.Request
 
 	tid, err := strconv.Atoi(id)
 	if err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusBadRequest, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusBadRequest)
 		return
 	}
 	teamID := api.TeamID(tid)
 
 	securityContext, err := security.RetrieveRestrictedRequestContext(r)
 	if err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusInternalServerError, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
 		return
 	}
 
 	if !security.AuthorizedTeamManagement(teamID, securityContext) {
-		httperror.WriteErrorResponse(w, api.ErrResourceAccessDenied, http.StatusForbidden, handler.Logger)
+		httperror.WriteErrorResponse(w, r, api.ErrResourceAccessDenied, http.StatusForbidden)
 		return
 	}
 
 	team, err := handler.TeamService.Team(teamID)
 	if err == api.ErrTeamNotFound {
-		httperror.WriteErrorResponse(w, err, http.StatusNotFound, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusNotFound)
 		return
 	} else if err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusInternalServerError, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
 		return
 	}
 
-	encodeJSON(w, &team, handler.Logger)
+	encodeJSON(w, r, &team)
 }
 
 // handlePutTeam handles PUT requests on /teams/:id
@@ -159,28 +163,28 @@ func (handler *TeamHandler) handlePutTeam(w http.ResponseWriter, r *http.Request
 
 	teamID, err := strconv.Atoi(id)
 	if err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusBadRequest, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusBadRequest)
 		return
 	}
 
 	var req putTeamRequest
 	if err = json.NewDecoder(r.Body).Decode(&req); err != nil {
-		httperror.WriteErrorResponse(w, ErrInvalidJSON, http.StatusBadRequest, handler.Logger)
+		httperror.WriteErrorResponse(w, r, ErrInvalidJSON, http.StatusBadRequest)
 		return
 	}
 
 	_, err = govalidator.ValidateStruct(req)
 	if err != nil {
-		httperror.WriteErrorResponse(w, ErrInvalidRequestFormat, http.StatusBadRequest, handler.Logger)
+		httperror.WriteErrorResponse(w, r, ErrInvalidRequestFormat, http.StatusBadRequest)
 		return
 	}
 
 	team, err := handler.TeamService.Team(api.TeamID(teamID))
 	if err == api.ErrTeamNotFound {
-		httperror.WriteErrorResponse(w, err, http.StatusNotFound, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusNotFound)
 		return
 	} else if err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusInternalServerError, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
 		return
 	}
 
@@ -190,7 +194,7 @@ func (handler *TeamHandler) handlePutTeam(w http.ResponseWriter, r *http.Request
 
 	err = handler.TeamService.UpdateTeam(team.ID, team)
 	if err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusInternalServerError, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
 		return
 	}
 }
@@ -202,29 +206,29 @@ func (handler *TeamHandler) handleDeleteTeam(w http.ResponseWriter, r *http.Requ
 
 	teamID, err := strconv.Atoi(id)
 	if err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusBadRequest, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusBadRequest)
 		return
 	}
 
 	_, err = handler.TeamService.Team(api.TeamID(teamID))
 
 	if err == api.ErrTeamNotFound {
-		httperror.WriteErrorResponse(w, err, http.StatusNotFound, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusNotFound)
 		return
 	} else if err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusInternalServerError, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
 		return
 	}
 
 	err = handler.TeamService.DeleteTeam(api.TeamID(teamID))
 	if err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusInternalServerError, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
 		return
 	}
 
 	err = handler.TeamMembershipService.DeleteTeamMembershipByTeamID(api.TeamID(teamID))
 	if err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusInternalServerError, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
 		return
 	}
 }
@@ -236,27 +240,27 @@ func (handler *TeamHandler) handleGetMemberships(w http.ResponseWriter, r *http.
 
 	tid, err := strconv.Atoi(id)
 	if err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusBadRequest, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusBadRequest)
 		return
 	}
 	teamID := api.TeamID(tid)
 
 	securityContext, err := security.RetrieveRestrictedRequestContext(r)
 	if err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusInternalServerError, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
 		return
 	}
 
 	if !security.AuthorizedTeamManagement(teamID, securityContext) {
-		httperror.WriteErrorResponse(w, api.ErrResourceAccessDenied, http.StatusForbidden, handler.Logger)
+		httperror.WriteErrorResponse(w, r, api.ErrResourceAccessDenied, http.StatusForbidden)
 		return
 	}
 
 	memberships, err := handler.TeamMembershipService.TeamMembershipsByTeamID(teamID)
 	if err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusInternalServerError, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
 		return
 	}
 
-	encodeJSON(w, memberships, handler.Logger)
+	encodeJSON(w, r, memberships)
 }
@@ -8,9 +8,7 @@ import (
 	"cloudware/cloudware/api/http/server/security"
 
 	"encoding/json"
-	"log"
 	"net/http"
-	"os"
 
 	"github.com/asaskevich/govalidator"
 	"github.com/gorilla/mux"
@@ -19,7 +17,6 @@ import (
 // TeamMembershipHandler represents an HTTP API handler for managing teams.
 type TeamMembershipHandler struct {
 	*mux.Router
-	Logger                 *log.Logger
 	TeamMembershipService  api.TeamMembershipService
 	ResourceControlService api.ResourceControlService
 }
@@ -28,7 +25,6 @@ type TeamMembershipHandler struct {
 func NewTeamMembershipHandler(bouncer *security.RequestBouncer) *TeamMembershipHandler {
 	h := &TeamMembershipHandler{
 		Router: mux.NewRouter(),
-		Logger: log.New(os.Stderr, "", log.LstdFlags),
 	}
 	h.Handle("/team_memberships",
 		bouncer.RestrictedAccess(http.HandlerFunc(h.handlePostTeamMemberships))).Methods(http.MethodPost)
@@ -64,19 +60,19 @@ type (
 func (handler *TeamMembershipHandler) handlePostTeamMemberships(w http.ResponseWriter, r *http.Request) {
 	securityContext, err := security.RetrieveRestrictedRequestContext(r)
 	if err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusInternalServerError, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
 		return
 	}
 
 	var req postTeamMembershipsRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		httperror.WriteErrorResponse(w, ErrInvalidJSON, http.StatusBadRequest, handler.Logger)
+		httperror.WriteErrorResponse(w, r, ErrInvalidJSON, http.StatusBadRequest)
 		return
 	}
 
 	_, err = govalidator.ValidateStruct(req)
 	if err != nil {
-		httperror.WriteErrorResponse(w, ErrInvalidRequestFormat, http.StatusBadRequest, handler.Logger)
+		httperror.WriteErrorResponse(w, r, ErrInvalidRequestFormat, http.StatusBadRequest)
 		return
 	}
 
@@ -85,19 +81,19 @@ func (handler *TeamMembershipHandler) handlePostTeamMemberships(w http.ResponseW
 	role := api.MembershipRole(req.Role)
 
 	if !security.AuthorizedTeamManagement(teamID, securityContext) {
-		httperror.WriteErrorResponse(w, api.ErrResourceAccessDenied, http.StatusForbidden, handler.Logger)
+		httperror.WriteErrorResponse(w, r, api.ErrResourceAccessDenied, http.StatusForbidden)
 		return
 	}
 
 	memberships, err := handler.TeamMembershipService.TeamMembershipsByUserID(userID)
 	if err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusInternalServerError, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
 		return
 	}
 	if len(memberships) > 0 {
 		for _, membership := range memberships {
 			if membership.UserID == userID && membership.TeamID == teamID {
-				httperror.WriteErrorResponse(w, api.ErrTeamMembershipAlreadyExists, http.StatusConflict, handler.Logger)
+				httperror.WriteErrorResponse(w, r, api.ErrTeamMembershipAlreadyExists, http.StatusConflict)
 				return
 			}
 		}
@@ -111,33 +107,33 @@ func (handler *TeamMembershipHandler) handlePostTeamMemberships(w http.ResponseW
 
 	err = handler.TeamMembershipService.CreateTeamMembership(membership)
 	if err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusInternalServerError, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
 		return
 	}
 
-	encodeJSON(w, &postTeamMembershipsResponse{ID: int(membership.ID)}, handler.Logger)
+	encodeJSON(w, r, &postTeamMembershipsResponse{ID: int(membership.ID)})
 }
 
 // handleGetTeamsMemberships handles GET requests on /team_memberships
 func (handler *TeamMembershipHandler) handleGetTeamsMemberships(w http.ResponseWriter, r *http.Request) {
 	securityContext, err := security.RetrieveRestrictedRequestContext(r)
 	if err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusInternalServerError, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
 		return
 	}
 
 	if !securityContext.IsAdmin && !securityContext.IsTeamLeader {
-		httperror.WriteErrorResponse(w, api.ErrResourceAccessDenied, http.StatusForbidden, handler.Logger)
+		httperror.WriteErrorResponse(w, r, api.ErrResourceAccessDenied, http.StatusForbidden)
 		return
 	}
 
 	memberships, err := handler.TeamMembershipService.TeamMemberships()
 	if err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusInternalServerError, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
 		return
 	}
 
-	encodeJSON(w, memberships, handler.Logger)
+	encodeJSON(w, r, memberships)
 }
 
 // handlePutTeamMembership handles PUT requests on /team_memberships/:id
@@ -147,19 +143,19 @@ func (handler *TeamMembershipHandler) handlePutTeamMembership(w http.ResponseWri
 
 	membershipID, err := strconv.Atoi(id)
 	if err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusBadRequest, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusBadRequest)
 		return
 	}
 
 	var req putTeamMembershipRequest
 	if err = json.NewDecoder(r.Body).Decode(&req); err != nil {
-		httperror.WriteErrorResponse(w, ErrInvalidJSON, http.StatusBadRequest, handler.Logger)
+		httperror.WriteErrorResponse(w, r, ErrInvalidJSON, http.StatusBadRequest)
 		return
 	}
 
 	_, err = govalidator.ValidateStruct(req)
 	if err != nil {
-		httperror.WriteErrorResponse(w, ErrInvalidRequestFormat, http.StatusBadRequest, handler.Logger)
+		httperror.WriteErrorResponse(w, r, ErrInvalidRequestFormat, http.StatusBadRequest)
 		return
 	}
 
@@ -169,26 +165,26 @@ func (handler *TeamMembershipHandler) handlePutTeamMembership(w http.ResponseWri
 
 	securityContext, err := security.RetrieveRestrictedRequestContext(r)
 	if err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusInternalServerError, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
 		return
 	}
 
 	if !security.AuthorizedTeamManagement(teamID, securityContext) {
-		httperror.WriteErrorResponse(w, api.ErrResourceAccessDenied, http.StatusForbidden, handler.Logger)
+		httperror.WriteErrorResponse(w, r, api.ErrResourceAccessDenied, http.StatusForbidden)
 		return
 	}
 
 	membership, err := handler.TeamMembershipService.TeamMembership(api.TeamMembershipID(membershipID))
 	if err == api.ErrTeamMembershipNotFound {
-		httperror.WriteErrorResponse(w, err, http.StatusNotFound, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusNotFound)
 		return
 	} else if err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusInternalServerError, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
 		return
 	}
 
 	if securityContext.IsTeamLeader && membership.Role != role {
-		httperror.WriteErrorResponse(w, api.ErrResourceAccessDenied, http.StatusForbidden, handler.Logger)
+		httperror.WriteErrorResponse(w, r, api.ErrResourceAccessDenied, http.StatusForbidden)
 		return
 	}
 
@@ -198,7 +194,7 @@ func (handler *TeamMembershipHandler) handlePutTeamMembership(w http.ResponseWri
 
 	err = handler.TeamMembershipService.UpdateTeamMembership(membership.ID, membership)
 	if err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusInternalServerError, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
 		return
 	}
 }
@@ -210,33 +206,33 @@ func (handler *TeamMembershipHandler) handleDeleteTeamMembership(w http.Response
 
 	membershipID, err := strconv.Atoi(id)
 	if err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusBadRequest, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusBadRequest)
 		return
 	}
 
 	membership, err := handler.TeamMembershipService.TeamMembership(api.TeamMembershipID(membershipID))
 	if err == api.ErrTeamMembershipNotFound {
-		httperror.WriteErrorResponse(w, err, http.StatusNotFound, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusNotFound)
 		return
 	} else if err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusInternalServerError, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
 		return
 	}
 
 	securityContext, err := security.RetrieveRestrictedRequestContext(r)
 	if err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusInternalServerError, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
 		return
 	}
 
 	if !security.AuthorizedTeamManagement(membership.TeamID, securityContext) {
-		httperror.WriteErrorResponse(w, api.ErrResourceAccessDenied, http.StatusForbidden, handler.Logger)
+		httperror.WriteErrorResponse(w, r, api.ErrResourceAccessDenied, http.StatusForbidden)
 		return
 	}
 
 	err = handler.TeamMembershipService.DeleteTeamMembership(api.TeamMembershipID(membershipID))
 	if err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusInternalServerError, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
 		return
 	}
 }
@@ -0,0 +1,589 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"cloudware/cloudware/api"
+	"cloudware/cloudware/api/file"
+	httperror "cloudware/cloudware/api/http/server/error"
+	"cloudware/cloudware/api/http/server/proxy"
+	"cloudware/cloudware/api/http/server/security"
+	"cloudware/cloudware/api/logging"
+)
+
+type (
+	postTemplateDeployRequest struct {
+		Name       string     `valid:""`
+		SwarmID    string     `valid:""`
+		Parameters []api.Pair `valid:"-"`
+	}
+
+	postTemplateDeployResponse struct {
+		ID                string                `json:"Id"`
+		ResourceControlID api.ResourceControlID `json:"ResourceControlId"`
+	}
+
+	containerCreateRequest struct {
+		Image        string              `json:"Image"`
+		Cmd          []string            `json:"Cmd,omitempty"`
+		Env          []string            `json:"Env"`
+		Labels       map[string]string   `json:"Labels"`
+		ExposedPorts map[string]struct{} `json:"ExposedPorts"`
+		Volumes      map[string]struct{} `json:"Volumes"`
+		HostConfig   containerHostConfig `json:"HostConfig"`
+	}
+
+	containerHostConfig struct {
+		PortBindings  map[string][]containerPortBinding `json:"PortBindings"`
+		Binds         []string                          `json:"Binds"`
+		RestartPolicy containerRestartPolicy            `json:"RestartPolicy"`
+	}
+
+	containerPortBinding struct {
+		HostIP   string `json:"HostIp"`
+		HostPort string `json:"HostPort"`
+	}
+
+	containerRestartPolicy struct {
+		Name string `json:"Name"`
+	}
+
+	containerCreateResponse struct {
+		ID string `json:"Id"`
+	}
+
+	// templateDeployment is the container or the stack deployed from a template.
+	templateDeployment struct {
+		ID                  string
+		ResourceID          string
+		ResourceControlType api.ResourceControlType
+		// remove removes the deployment when it cannot be completed, the errors are only logged.
+		remove func()
+	}
+)
+
+// handlePostTemplateDeploy handles POST requests on /endpoints/:endpointId/templates/:id/deploy.
+// The template is deployed with the registry credentials available to the user, the deployed
+// container or stack is owned by the user.
+func (handler *TemplatesHandler) handlePostTemplateDeploy(w http.ResponseWriter, r *http.Request) {
+	endpointID, err := strconv.Atoi(mux.Vars(r)["endpointId"])
+	if err != nil {
+		httperror.WriteErrorResponse(w, r, err, http.StatusBadRequest)
+		return
+	}
+
+	securityContext, err := security.RetrieveRestrictedRequestContext(r)
+	if err != nil {
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
+		return
+	}
+
+	endpoint, err := handler.EndpointService.Endpoint(api.EndpointID(endpointID))
+	if err == api.ErrEndpointNotFound {
+		httperror.WriteErrorResponse(w, r, err, http.StatusNotFound)
+		return
+	} else if err != nil {
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
+		return
+	}
+
+	authorizedEndpoints, err := security.FilterEndpoints([]api.Endpoint{*endpoint}, securityContext)
+	if err != nil {
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	if len(authorizedEndpoints) == 0 {
+		httperror.WriteErrorResponse(w, r, api.ErrEndpointAccessDenied, http.StatusForbidden)
+		return
+	}
+
+	template, ok := handler.visibleTemplate(w, r)
+	if !ok {
+		return
+	}
+
+	var req postTemplateDeployRequest
+	if err = json.NewDecoder(r.Body).Decode(&req); err != nil {
+		httperror.WriteErrorResponse(w, r, ErrInvalidJSON, http.StatusBadRequest)
+		return
+	}
+
+	env, fieldErrors := templateParameterValues(template, req.Parameters)
+	if len(fieldErrors) > 0 {
+		httperror.WriteErrorDetailsResponse(w, r, api.ErrInvalidTemplateParameters, http.StatusBadRequest, fieldErrors)
+		return
+	}
+
+	dockerhub, err := handler.DockerHubService.DockerHub()
+	if err != nil {
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
+		return
+	}
+
+	registries, err := handler.RegistryService.Registries()
+	if err != nil {
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
+		return
+	}
+
+	filteredRegistries, err := security.FilterRegistries(registries, securityContext)
+	if err != nil {
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
+		return
+	}
+
+	var deployment *templateDeployment
+	var code int
+	if template.Type == api.StackTemplate {
+		deployment, code, err = handler.deployStackTemplate(r, endpoint, template, &req, env, dockerhub, filteredRegistries)
+	} else {
+		var settings *api.Settings
+		settings, err = handler.SettingsService.Settings()
+		if err != nil {
+			httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
+			return
+		}
+		allowBindMounts := securityContext.IsAdmin || settings.AllowBindMountsForRegularUsers
+		deployment, code, err = handler.deployContainerTemplate(r, endpoint, template, req.Name, env, allowBindMounts)
+	}
+	if err != nil {
+		httperror.WriteErrorResponse(w, r, err, code)
+		return
+	}
+
+	response := &postTemplateDeployResponse{ID: deployment.ID}
+
+	// Without authentication there is no user to own the deployed resource.
+	if securityContext.UserID != 0 {
+		resourceControl := &api.ResourceControl{
+			ResourceID:     deployment.ResourceID,
+			SubResourceIDs: []string{},
+			Type:           deployment.ResourceControlType,
+			UserAccesses: []api.UserResourceAccess{
+				{UserID: securityContext.UserID, AccessLevel: api.ReadWriteAccessLevel},
+			},
+			TeamAccesses: []api.TeamResourceAccess{},
+		}
+		err = handler.ResourceControlService.CreateResourceControl(resourceControl)
+		if err != nil {
+			// The deployment is removed rather than left without owner.
+			deployment.remove()
+			httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
+			return
+		}
+		response.ResourceControlID = resourceControl.ID
+	}
+
+	encodeJSON(w, r, response)
+}
+
+// deployContainerTemplate pulls the image of a container template, then creates and starts the
+// container. It returns the deployed container, or the status code of the error. The container
+// is removed when it cannot be started.
+func (handler *TemplatesHandler) deployContainerTemplate(r *http.Request, endpoint *api.Endpoint, template *api.Template, name string, env []api.Pair, allowBindMounts bool) (*templateDeployment, int, error) {
+	replacer := templateReplacer(env)
+
+	body, err := newContainerCreateRequest(template, env, replacer)
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+	if len(body.HostConfig.Binds) > 0 && !allowBindMounts {
+		return nil, http.StatusForbidden, api.ErrResourceAccessDenied
+	}
+
+	client, err := handler.ProxyManager.GetClient(endpoint)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+
+	code, err := pullImage(r, client, body.Image)
+	if err != nil {
+		return nil, code, err
+	}
+
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+
+	createPath := "/containers/create"
+	if name != "" {
+		createPath += "?" + url.Values{"name": []string{name}}.Encode()
+	}
+	var created containerCreateResponse
+	code, err = dockerPostRequest(r, client, createPath, nil, data, http.StatusCreated, &created)
+	if err != nil {
+		return nil, code, err
+	}
+
+	deployment := &templateDeployment{
+		ID:                  created.ID,
+		ResourceID:          created.ID,
+		ResourceControlType: api.ContainerResourceControl,
+		remove: func() {
+			err := removeContainer(r, client, created.ID)
+			if err != nil {
+				logging.FromRequest(r).WithError(err).Warnf("Unable to remove the container %s of a failed template deployment", created.ID)
+			}
+		},
+	}
+
+	code, err = dockerPostRequest(r, client, "/containers/"+url.PathEscape(created.ID)+"/start", nil, nil, http.StatusNoContent, nil)
+	if err != nil {
+		deployment.remove()
+		return nil, code, err
+	}
+	return deployment, http.StatusOK, nil
+}
+
+// deployStackTemplate stores and deploys the stack of a stack template on the swarm of the
+// endpoint. The stack file is cloned from the repository of the template when it has no
+// content, the parameters are then only available as environment variables of the stack.
+// It returns the deployed stack, or the status code of the error. The stored stack and its
+// files are removed when the deployment fails.
+func (handler *TemplatesHandler) deployStackTemplate(r *http.Request, endpoint *api.Endpoint, template *api.Template, req *postTemplateDeployRequest, env []api.Pair, dockerhub *api.DockerHub, registries []api.Registry) (*templateDeployment, int, error) {
+	if req.Name == "" || req.SwarmID == "" {
+		return nil, http.StatusBadRequest, ErrInvalidRequestFormat
+	}
+
+	client, err := handler.ProxyManager.GetClient(endpoint)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	swarmID, code, err := endpointSwarmID(r, client)
+	if err != nil {
+		return nil, code, err
+	}
+	if swarmID != req.SwarmID {
+		return nil, http.StatusBadRequest, api.ErrStackSwarmMismatch
+	}
+
+	stacks, err := handler.StackService.Stacks()
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	for _, stack := range stacks {
+		if strings.EqualFold(stack.Name, req.Name) {
+			return nil, http.StatusConflict, api.ErrStackAlreadyExists
+		}
+	}
+
+	resourceControl, err := handler.ResourceControlService.ResourceControlByResourceID(req.Name)
+	if err != nil && err != api.ErrResourceControlNotFound {
+		return nil, http.StatusInternalServerError, err
+	}
+	if resourceControl != nil {
+		return nil, http.StatusConflict, api.ErrResourceControlAlreadyExists
+	}
+
+	stack := &api.Stack{
+		ID:         api.StackID(req.Name + "_" + req.SwarmID),
+		Name:       req.Name,
+		SwarmID:    req.SwarmID,
+		EntryPoint: file.ComposeFileDefaultName,
+		Env:        env,
+	}
+
+	removeFiles := func() {
+		err := handler.FileService.RemoveDirectory(stack.ProjectPath)
+		if err != nil {
+			logging.FromRequest(r).WithError(err).Warnf("Unable to remove the files of the stack %s of a failed template deployment", stack.ID)
+		}
+	}
+
+	if template.StackFileContent != "" {
+		stack.ProjectPath, err = handler.FileService.StoreStackFileFromString(string(stack.ID), templateReplacer(env).Replace(template.StackFileContent))
+		if err != nil {
+			return nil, http.StatusInternalServerError, err
+		}
+	} else {
+		stack.ProjectPath = handler.FileService.GetStackProjectPath(string(stack.ID))
+		if template.Repository.StackFile != "" {
+			stack.EntryPoint = template.Repository.StackFile
+		}
+
+		err = handler.FileService.RemoveDirectory(stack.ProjectPath)
+		if err != nil {
+			return nil, http.StatusInternalServerError, err
+		}
+
+		err = handler.GitService.CloneRepository(template.Repository.URL, stack.ProjectPath)
+		if err != nil {
+			removeFiles()
+			return nil, http.StatusInternalServerError, err
+		}
+	}
+
+	err = handler.StackService.CreateStack(stack)
+	if err != nil {
+		removeFiles()
+		return nil, http.StatusInternalServerError, err
+	}
+
+	deployment := &templateDeployment{
+		ID:                  string(stack.ID),
+		ResourceID:          stack.Name,
+		ResourceControlType: api.StackResourceControl,
+		remove: func() {
+			// The services deployed before a failure are removed along with the stack.
+			err := handler.StackManager.Remove(stack, endpoint)
+			if err != nil {
+				logging.FromRequest(r).WithError(err).Warnf("Unable to remove the stack %s of a failed template deployment", stack.ID)
+			}
+			err = handler.StackService.DeleteStack(stack.ID)
+			if err != nil {
+				logging.FromRequest(r).WithError(err).Warnf("Unable to delete the stack %s of a failed template deployment", stack.ID)
+			}
+			removeFiles()
+		},
+	}
+
+	err = deployStack(handler.StackManager, endpoint, stack, dockerhub, registries)
+	if err != nil {
+		deployment.remove()
+		return nil, http.StatusInternalServerError, err
+	}
+	return deployment, http.StatusOK, nil
+}
+
+// templateParameterValues returns the values of the parameters of a template, the default
+// value of a parameter is used when the request does not specify it.
+func templateParameterValues(template *api.Template, values []api.Pair) ([]api.Pair, []httperror.FieldError) {
+	fieldErrors := make([]httperror.FieldError, 0)
+
+	specified := make(map[string]string)
+	for _, pair := range values {
+		specified[pair.Name] = pair.Value
+	}
+
+	env := make([]api.Pair, 0, len(template.Parameters))
+	for _, parameter := range template.Parameters {
+		field := "Parameters." + parameter.Name
+		value, ok := specified[parameter.Name]
+		delete(specified, parameter.Name)
+		if !ok || value == "" {
+			value = parameter.Default
+		}
+
+		if value == "" && parameter.Required {
+			fieldErrors = append(fieldErrors, httperror.FieldError{Field: field, Message: "is required"})
+			continue
+		}
+		if value != "" && len(parameter.Options) > 0 && !containsString(parameter.Options, value) {
+			fieldErrors = append(fieldErrors, httperror.FieldError{Field: field, Message: "must be one of " + strings.Join(parameter.Options, ", ")})
+			continue
+		}
+		env = append(env, api.Pair{Name: parameter.Name, Value: value})
+	}
+
+	for name := range specified {
+		fieldErrors = append(fieldErrors, httperror.FieldError{Field: "Parameters." + name, Message: "is not a parameter of the template"})
+	}
+	return env, fieldErrors
+}
+
+// templateReplacer replaces the {{Name}} placeholders with the values of the parameters.
+func templateReplacer(env []api.Pair) *strings.Replacer {
+	replacements := make([]string, 0, 2*len(env))
+	for _, pair := range env {
+		replacements = append(replacements, "{{"+pair.Name+"}}", pair.Value)
+	}
+	return strings.NewReplacer(replacements...)
+}
+
+// newContainerCreateRequest returns the body of the request creating the container of a template.
+func newContainerCreateRequest(template *api.Template, env []api.Pair, replacer *strings.Replacer) (*containerCreateRequest, error) {
+	body := &containerCreateRequest{
+		Image:        replacer.Replace(template.Image),
+		Cmd:          strings.Fields(replacer.Replace(template.Command)),
+		Env:          make([]string, 0, len(env)),
+		Labels:       make(map[string]string),
+		ExposedPorts: make(map[string]struct{}),
+		Volumes:      make(map[string]struct{}),
+		HostConfig: containerHostConfig{
+			PortBindings:  make(map[string][]containerPortBinding),
+			Binds:         make([]string, 0),
+			RestartPolicy: containerRestartPolicy{Name: template.RestartPolicy},
+		},
+	}
+
+	for _, pair := range env {
+		body.Env = append(body.Env, pair.Name+"="+pair.Value)
+	}
+	for _, label := range template.Labels {
+		body.Labels[label.Name] = replacer.Replace(label.Value)
+	}
+
+	for _, port := range template.Ports {
+		containerPort, binding, err := parsePortBinding(replacer.Replace(port))
+		if err != nil {
+			return nil, err
+		}
+		body.ExposedPorts[containerPort] = struct{}{}
+		body.HostConfig.PortBindings[containerPort] = append(body.HostConfig.PortBindings[containerPort], binding)
+	}
+
+	for _, volume := range template.Volumes {
+		volume = replacer.Replace(volume)
+		if strings.Contains(volume, ":") {
+			body.HostConfig.Binds = append(body.HostConfig.Binds, volume)
+		} else {
+			body.Volumes[volume] = struct{}{}
+		}
+	}
+	return body, nil
+}
+
+// parsePortBinding parses a port of a template, [[hostIP:]hostPort:]containerPort[/protocol].
+// The host port is chosen by Docker when it is not specified.
+func parsePortBinding(port string) (string, containerPortBinding, error) {
+	protocol := "tcp"
+	if idx := strings.LastIndex(port, "/"); idx != -1 {
+		protocol = port[idx+1:]
+		port = port[:idx]
+	}
+
+	var binding containerPortBinding
+	parts := strings.Split(port, ":")
+	switch len(parts) {
+	case 1:
+	case 2:
+		binding.HostPort = parts[0]
+	case 3:
+		binding.HostIP, binding.HostPort = parts[0], parts[1]
+	default:
+		return "", binding, fmt.Errorf("Invalid port %q", port)
+	}
+
+	containerPort := parts[len(parts)-1]
+	if _, err := strconv.Atoi(containerPort); err != nil || (protocol != "tcp" && protocol != "udp") {
+		return "", binding, fmt.Errorf("Invalid port %q", port)
+	}
+	return containerPort + "/" + protocol, binding, nil
+}
+
+// pullImage pulls an image, the latest tag is pulled when the image has neither tag nor digest.
+// The Docker proxy authenticates the pull with the registry credentials available to the user.
+// Docker reports the errors of a pull in the stream of its progress, after a success status.
+func pullImage(r *http.Request, client *proxy.Client, image string) (int, error) {
+	query := url.Values{"fromImage": []string{image}}
+	if !strings.Contains(image, "@") && !strings.Contains(image[strings.LastIndex(image, "/")+1:], ":") {
+		query.Set("tag", "latest")
+	}
+
+	request, err := http.NewRequest(http.MethodPost, "/images/create?"+query.Encode(), nil)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	response, err := client.Do(request.WithContext(r.Context()))
+	if err != nil {
+		return http.StatusBadGateway, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return response.StatusCode, dockerResponseError(response)
+	}
+
+	decoder := json.NewDecoder(response.Body)
+	for {
+		var message struct {
+			Error string `json:"error"`
+		}
+		err := decoder.Decode(&message)
+		if err == io.EOF {
+			return http.StatusOK, nil
+		} else if err != nil {
+			return http.StatusBadGateway, err
+		}
+		if message.Error != "" {
+			return http.StatusBadGateway, api.Error(message.Error)
+		}
+	}
+}
+
+// endpointSwarmID returns the identifier of the swarm cluster the Docker engine of an endpoint
+// belongs to, it is empty when the engine is not part of a swarm.
+func endpointSwarmID(r *http.Request, client *proxy.Client) (string, int, error) {
+	request, err := http.NewRequest(http.MethodGet, "/info", nil)
+	if err != nil {
+		return "", http.StatusInternalServerError, err
+	}
+	response, err := client.Do(request.WithContext(r.Context()))
+	if err != nil {
+		return "", http.StatusBadGateway, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return "", response.StatusCode, dockerResponseError(response)
+	}
+
+	var info struct {
+		Swarm struct {
+			Cluster struct {
+				ID string
+			}
+		}
+	}
+	err = json.NewDecoder(response.Body).Decode(&info)
+	if err != nil {
+		return "", http.StatusBadGateway, err
+	}
+	return info.Swarm.Cluster.ID, http.StatusOK, nil
+}
+
+// removeContainer removes a container along with its anonymous volumes.
+func removeContainer(r *http.Request, client *proxy.Client, id string) error {
+	request, err := http.NewRequest(http.MethodDelete, "/containers/"+url.PathEscape(id)+"?force=1&v=1", nil)
+	if err != nil {
+		return err
+	}
+	response, err := client.Do(request.WithContext(r.Context()))
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusNoContent && response.StatusCode != http.StatusNotFound {
+		return dockerResponseError(response)
+	}
+	return nil
+}
+
+// dockerPostRequest executes a POST request against the Docker API and decodes the response in v
+// when it is not nil. The returned status code is the one that should be sent to the client in case of error.
+func dockerPostRequest(r *http.Request, client *proxy.Client, path string, header http.Header, body []byte, expectedStatus int, v interface{}) (int, error) {
+	request, err := http.NewRequest(http.MethodPost, path, bytes.NewReader(body))
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if header != nil {
+		request.Header = header
+	}
+	if body != nil {
+		request.Header.Set("Content-Type", "application/json")
+	}
+
+	response, err := client.Do(request.WithContext(r.Context()))
+	if err != nil {
+		return http.StatusBadGateway, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != expectedStatus {
+		return response.StatusCode, dockerResponseError(response)
+	}
+	if v == nil {
+		return http.StatusOK, nil
+	}
+	return http.StatusOK, json.NewDecoder(response.Body).Decode(v)
+}
@@ -0,0 +1,106 @@
+package handler
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"cloudware/cloudware/api"
+)
+
+func TestTemplateParameterValues(t *testing.T) {
+	template := &api.Template{
+		Parameters: []api.TemplateParameter{
+			{Name: "VERSION", Default: "1.0", Options: []string{"1.0", "2.0"}},
+			{Name: "PASSWORD", Required: true},
+			{Name: "PORT", Default: "8080"},
+			{Name: "OPTIONAL"},
+		},
+	}
+
+	env, fieldErrors := templateParameterValues(template, []api.Pair{
+		{Name: "VERSION", Value: "2.0"},
+		{Name: "PASSWORD", Value: "secret"},
+		{Name: "PORT", Value: ""},
+	})
+	assert.Empty(t, fieldErrors)
+	assert.Equal(t, []api.Pair{
+		{Name: "VERSION", Value: "2.0"},
+		{Name: "PASSWORD", Value: "secret"},
+		{Name: "PORT", Value: "8080"},
+		{Name: "OPTIONAL", Value: ""},
+	}, env)
+
+	_, fieldErrors = templateParameterValues(template, []api.Pair{
+		{Name: "VERSION", Value: "3.0"},
+		{Name: "UNKNOWN", Value: "value"},
+	})
+	fields := make(map[string]string)
+	for _, fieldError := range fieldErrors {
+		fields[fieldError.Field] = fieldError.Message
+	}
+	assert.Equal(t, map[string]string{
+		"Parameters.VERSION":  "must be one of 1.0, 2.0",
+		"Parameters.PASSWORD": "is required",
+		"Parameters.UNKNOWN":  "is not a parameter of the template",
+	}, fields)
+}
+
+func TestNewContainerCreateRequest(t *testing.T) {
+	template := &api.Template{
+		Image:         "nginx:{{VERSION}}",
+		Command:       "nginx -g {{OPTIONS}}",
+		RestartPolicy: "always",
+		Labels:        []api.Pair{{Name: "version", Value: "{{VERSION}}"}},
+		Ports:         []string{"{{PORT}}:80", "443/tcp", "127.0.0.1:5353:53/udp"},
+		Volumes:       []string{"/data", "/srv/{{VERSION}}:/usr/share/nginx/html:ro"},
+	}
+	env := []api.Pair{{Name: "VERSION", Value: "1.0"}, {Name: "OPTIONS", Value: "daemon-off"}, {Name: "PORT", Value: "8080"}}
+
+	body, err := newContainerCreateRequest(template, env, templateReplacer(env))
+	require.NoError(t, err)
+
+	assert.Equal(t, "nginx:1.0", body.Image)
+	assert.Equal(t, []string{"nginx", "-g", "daemon-off"}, body.Cmd)
+	assert.Equal(t, []string{"VERSION=1.0", "OPTIONS=daemon-off", "PORT=8080"}, body.Env)
+	assert.Equal(t, map[string]string{"version": "1.0"}, body.Labels)
+	assert.Equal(t, "always", body.HostConfig.RestartPolicy.Name)
+	assert.Equal(t, map[string]struct{}{"80/tcp": {}, "443/tcp": {}, "53/udp": {}}, body.ExposedPorts)
+	assert.Equal(t, map[string][]containerPortBinding{
+		"80/tcp":  {{HostPort: "8080"}},
+		"443/tcp": {{}},
+		"53/udp":  {{HostIP: "127.0.0.1", HostPort: "5353"}},
+	}, body.HostConfig.PortBindings)
+	assert.Equal(t, map[string]struct{}{"/data": {}}, body.Volumes)
+	assert.Equal(t, []string{"/srv/1.0:/usr/share/nginx/html:ro"}, body.HostConfig.Binds)
+
+	template.Ports = []string{"invalid"}
+	_, err = newContainerCreateRequest(template, env, templateReplacer(env))
+	assert.Error(t, err)
+}
+
+func TestParsePortBinding(t *testing.T) {
+	for _, test := range []struct {
+		port          string
+		containerPort string
+		binding       containerPortBinding
+	}{
+		{"80", "80/tcp", containerPortBinding{}},
+		{"53/udp", "53/udp", containerPortBinding{}},
+		{"8080:80", "80/tcp", containerPortBinding{HostPort: "8080"}},
+		{"127.0.0.1:8080:80/tcp", "80/tcp", containerPortBinding{HostIP: "127.0.0.1", HostPort: "8080"}},
+		{"127.0.0.1::80", "80/tcp", containerPortBinding{HostIP: "127.0.0.1"}},
+	} {
+		containerPort, binding, err := parsePortBinding(test.port)
+		if assert.NoError(t, err, test.port) {
+			assert.Equal(t, test.containerPort, containerPort, test.port)
+			assert.Equal(t, test.binding, binding, test.port)
+		}
+	}
+
+	for _, port := range []string{"", "http", "80/sctp", "8080:http", "1:2:3:4"} {
+		_, _, err := parsePortBinding(port)
+		assert.Error(t, err, port)
+	}
+}
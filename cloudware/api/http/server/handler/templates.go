@@ -1,74 +1,456 @@
 package handler
 
 import (
-	"io/ioutil"
-	"log"
+	"encoding/json"
 	"net/http"
-	"os"
+	"regexp"
+	"strconv"
+	"time"
 
+	"github.com/asaskevich/govalidator"
 	"github.com/gorilla/mux"
 	"cloudware/cloudware/api"
 	httperror "cloudware/cloudware/api/http/server/error"
+	"cloudware/cloudware/api/http/server/proxy"
 	"cloudware/cloudware/api/http/server/security"
+	"cloudware/cloudware/api/templates"
 )
 
 // TemplatesHandler represents an HTTP API handler for managing templates.
 type TemplatesHandler struct {
 	*mux.Router
-	Logger          *log.Logger
-	SettingsService api.SettingsService
+	SettingsService        api.SettingsService
+	TemplateService        api.TemplateService
+	TemplateFetcher        *templates.Fetcher
+	EndpointService        api.EndpointService
+	StackService           api.StackService
+	ResourceControlService api.ResourceControlService
+	RegistryService        api.RegistryService
+	DockerHubService       api.DockerHubService
+	FileService            api.FileService
+	GitService             api.GitService
+	StackManager           api.StackManager
+	ProxyManager           *proxy.Manager
 }
 
 const (
 	containerTemplatesURLLinuxServerIo = "https://tools.linuxserver.io/api.json"
+
+	// templatesCacheMaxAge is the time the documents served by key are served from the cache
+	// before being retrieved again.
+	templatesCacheMaxAge = time.Hour
 )
 
+// templateParameterName matches the names of the parameters, they are environment variables.
+var templateParameterName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
 // NewTemplatesHandler returns a new instance of TemplatesHandler.
 func NewTemplatesHandler(bouncer *security.RequestBouncer) *TemplatesHandler {
 	h := &TemplatesHandler{
 		Router: mux.NewRouter(),
-		Logger: log.New(os.Stderr, "", log.LstdFlags),
 	}
 	h.Handle("/templates",
-		bouncer.AuthenticatedAccess(http.HandlerFunc(h.handleGetTemplates))).Methods(http.MethodGet)
+		bouncer.RestrictedAccess(http.HandlerFunc(h.handleGetTemplates))).Methods(http.MethodGet)
+	h.Handle("/templates",
+		bouncer.AdministratorAccess(http.HandlerFunc(h.handlePostTemplates))).Methods(http.MethodPost)
+	h.Handle("/templates/import",
+		bouncer.AdministratorAccess(http.HandlerFunc(h.handlePostTemplatesImport))).Methods(http.MethodPost)
+	h.Handle("/templates/{id}",
+		bouncer.RestrictedAccess(http.HandlerFunc(h.handleGetTemplate))).Methods(http.MethodGet)
+	h.Handle("/templates/{id}",
+		bouncer.AdministratorAccess(http.HandlerFunc(h.handlePutTemplate))).Methods(http.MethodPut)
+	h.Handle("/templates/{id}",
+		bouncer.AdministratorAccess(http.HandlerFunc(h.handleDeleteTemplate))).Methods(http.MethodDelete)
+	h.Handle("/endpoints/{endpointId}/templates/{id}/deploy",
+		bouncer.RestrictedAccess(http.HandlerFunc(h.handlePostTemplateDeploy))).Methods(http.MethodPost)
 	return h
 }
 
-// handleGetTemplates handles GET requests on /templates?key=<key>
+type (
+	templateRequest struct {
+		Type             api.TemplateType        `valid:"required"`
+		Title            string                  `valid:"required"`
+		Description      string                  `valid:""`
+		Note             string                  `valid:""`
+		Categories       []string                `valid:"-"`
+		Platform         string                  `valid:""`
+		Logo             string                  `valid:""`
+		Image            string                  `valid:""`
+		Command          string                  `valid:""`
+		Ports            []string                `valid:"-"`
+		Volumes          []string                `valid:"-"`
+		Labels           []api.Pair              `valid:"-"`
+		RestartPolicy    string                  `valid:""`
+		StackFileContent string                  `valid:""`
+		Repository       api.TemplateRepository  `valid:"-"`
+		Parameters       []api.TemplateParameter `valid:"-"`
+		AuthorizedTeams  []api.TeamID            `valid:"-"`
+	}
+
+	postTemplatesResponse struct {
+		ID int `json:"Id"`
+	}
+
+	postTemplatesImportRequest struct {
+		URL              string `valid:""`
+		GitRepository    string `valid:""`
+		PathInRepository string `valid:""`
+	}
+
+	postTemplatesImportResponse struct {
+		Source string `json:"Source"`
+		Count  int    `json:"Count"`
+		Cached bool   `json:"Cached"`
+	}
+)
+
+// handleGetTemplates handles GET requests on /templates?key=<key>, the templates documents
+// are served as is when a key is specified, the stored templates are listed otherwise.
 func (handler *TemplatesHandler) handleGetTemplates(w http.ResponseWriter, r *http.Request) {
-	key := r.FormValue("key")
-	if key == "" {
-		httperror.WriteErrorResponse(w, ErrInvalidQueryFormat, http.StatusBadRequest, handler.Logger)
+	if key := r.FormValue("key"); key != "" {
+		handler.handleGetTemplatesDocument(w, r, key)
 		return
 	}
 
+	securityContext, err := security.RetrieveRestrictedRequestContext(r)
+	if err != nil {
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
+		return
+	}
+
+	options, err := parseListOptions(r, api.SortByType)
+	if err != nil {
+		httperror.WriteErrorResponse(w, r, ErrInvalidQueryFormat, http.StatusBadRequest)
+		return
+	}
+
+	query := &api.TemplateQuery{
+		ListOptions: options,
+		Category:    r.FormValue("category"),
+		Access:      security.ListAccessFilter(securityContext),
+	}
+	if templateType := r.FormValue("type"); templateType != "" {
+		query.Type, err = parseTemplateType(templateType)
+		if err != nil {
+			httperror.WriteErrorResponse(w, r, ErrInvalidQueryFormat, http.StatusBadRequest)
+			return
+		}
+	}
+
+	list, total, err := handler.TemplateService.ListTemplates(query)
+	if err != nil {
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
+		return
+	}
+
+	writeTotalCount(w, total)
+	encodeJSON(w, r, list)
+}
+
+// handleGetTemplatesDocument serves the templates document associated to a key.
+// The document is cached, the cached copy is served when its source is unreachable.
+func (handler *TemplatesHandler) handleGetTemplatesDocument(w http.ResponseWriter, r *http.Request, key string) {
 	var templatesURL string
 	switch key {
 	case "containers":
 		settings, err := handler.SettingsService.Settings()
 		if err != nil {
-			httperror.WriteErrorResponse(w, err, http.StatusInternalServerError, handler.Logger)
+			httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
 			return
 		}
 		templatesURL = settings.TemplatesURL
 	case "linuxserver.io":
 		templatesURL = containerTemplatesURLLinuxServerIo
 	default:
-		httperror.WriteErrorResponse(w, ErrInvalidQueryFormat, http.StatusBadRequest, handler.Logger)
+		httperror.WriteErrorResponse(w, r, ErrInvalidQueryFormat, http.StatusBadRequest)
+		return
+	}
+
+	content, _, err := handler.TemplateFetcher.Fetch(&templates.Source{URL: templatesURL}, templatesCacheMaxAge)
+	if err == api.ErrTemplateSourceUnavailable {
+		httperror.WriteErrorResponse(w, r, err, http.StatusBadGateway)
+		return
+	} else if err != nil {
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(content)
+}
+
+// handlePostTemplates handles POST requests on /templates
+func (handler *TemplatesHandler) handlePostTemplates(w http.ResponseWriter, r *http.Request) {
+	var req templateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		httperror.WriteErrorResponse(w, r, ErrInvalidJSON, http.StatusBadRequest)
 		return
 	}
 
-	resp, err := http.Get(templatesURL)
+	_, err := govalidator.ValidateStruct(req)
 	if err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusInternalServerError, handler.Logger)
+		httperror.WriteErrorResponse(w, r, ErrInvalidRequestFormat, http.StatusBadRequest)
+		return
+	}
+
+	template := &api.Template{}
+	req.apply(template)
+	if fieldErrors := validateTemplate(template); len(fieldErrors) > 0 {
+		httperror.WriteErrorDetailsResponse(w, r, ErrInvalidRequestFormat, http.StatusBadRequest, fieldErrors)
 		return
 	}
-	defer resp.Body.Close()
-	body, err := ioutil.ReadAll(resp.Body)
+
+	err = handler.TemplateService.CreateTemplate(template)
 	if err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusInternalServerError, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
 		return
 	}
-	w.Header().Set("Content-Type", "application/json")
-	w.Write(body)
+
+	encodeJSON(w, r, &postTemplatesResponse{ID: int(template.ID)})
+}
+
+// handlePostTemplatesImport handles POST requests on /templates/import. The templates of the
+// document replace the templates previously imported from the same source.
+func (handler *TemplatesHandler) handlePostTemplatesImport(w http.ResponseWriter, r *http.Request) {
+	var req postTemplatesImportRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		httperror.WriteErrorResponse(w, r, ErrInvalidJSON, http.StatusBadRequest)
+		return
+	}
+
+	if (req.URL == "") == (req.GitRepository == "") {
+		httperror.WriteErrorResponse(w, r, ErrInvalidRequestFormat, http.StatusBadRequest)
+		return
+	}
+
+	source := &templates.Source{
+		URL:              req.URL,
+		GitRepository:    req.GitRepository,
+		PathInRepository: req.PathInRepository,
+	}
+
+	content, cached, err := handler.TemplateFetcher.Fetch(source, 0)
+	if err == api.ErrTemplateSourceUnavailable {
+		httperror.WriteErrorResponse(w, r, err, http.StatusBadGateway)
+		return
+	} else if err != nil {
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
+		return
+	}
+
+	imported, err := templates.Parse(content)
+	if err != nil {
+		httperror.WriteErrorResponse(w, r, err, http.StatusBadRequest)
+		return
+	}
+
+	err = handler.TemplateService.ReplaceTemplates(source.String(), imported)
+	if err != nil {
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
+		return
+	}
+
+	encodeJSON(w, r, &postTemplatesImportResponse{Source: source.String(), Count: len(imported), Cached: cached})
+}
+
+// handleGetTemplate handles GET requests on /templates/:id
+func (handler *TemplatesHandler) handleGetTemplate(w http.ResponseWriter, r *http.Request) {
+	template, ok := handler.visibleTemplate(w, r)
+	if !ok {
+		return
+	}
+
+	encodeJSON(w, r, template)
+}
+
+// handlePutTemplate handles PUT requests on /templates/:id
+func (handler *TemplatesHandler) handlePutTemplate(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		httperror.WriteErrorResponse(w, r, err, http.StatusBadRequest)
+		return
+	}
+	templateID := api.TemplateID(id)
+
+	var req templateRequest
+	if err = json.NewDecoder(r.Body).Decode(&req); err != nil {
+		httperror.WriteErrorResponse(w, r, ErrInvalidJSON, http.StatusBadRequest)
+		return
+	}
+
+	_, err = govalidator.ValidateStruct(req)
+	if err != nil {
+		httperror.WriteErrorResponse(w, r, ErrInvalidRequestFormat, http.StatusBadRequest)
+		return
+	}
+
+	template, err := handler.TemplateService.Template(templateID)
+	if err == api.ErrTemplateNotFound {
+		httperror.WriteErrorResponse(w, r, err, http.StatusNotFound)
+		return
+	} else if err != nil {
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
+		return
+	}
+
+	req.apply(template)
+	if fieldErrors := validateTemplate(template); len(fieldErrors) > 0 {
+		httperror.WriteErrorDetailsResponse(w, r, ErrInvalidRequestFormat, http.StatusBadRequest, fieldErrors)
+		return
+	}
+
+	err = handler.TemplateService.UpdateTemplate(templateID, template)
+	if err != nil {
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
+		return
+	}
+}
+
+// handleDeleteTemplate handles DELETE requests on /templates/:id
+func (handler *TemplatesHandler) handleDeleteTemplate(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		httperror.WriteErrorResponse(w, r, err, http.StatusBadRequest)
+		return
+	}
+	templateID := api.TemplateID(id)
+
+	_, err = handler.TemplateService.Template(templateID)
+	if err == api.ErrTemplateNotFound {
+		httperror.WriteErrorResponse(w, r, err, http.StatusNotFound)
+		return
+	} else if err != nil {
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
+		return
+	}
+
+	err = handler.TemplateService.DeleteTemplate(templateID)
+	if err != nil {
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
+		return
+	}
+}
+
+// visibleTemplate returns the template identified by the id variable of a request. The error
+// response is written when the template does not exist or is not visible to the user.
+func (handler *TemplatesHandler) visibleTemplate(w http.ResponseWriter, r *http.Request) (*api.Template, bool) {
+	securityContext, err := security.RetrieveRestrictedRequestContext(r)
+	if err != nil {
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
+		return nil, false
+	}
+
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		httperror.WriteErrorResponse(w, r, err, http.StatusBadRequest)
+		return nil, false
+	}
+
+	template, err := handler.TemplateService.Template(api.TemplateID(id))
+	if err == api.ErrTemplateNotFound {
+		httperror.WriteErrorResponse(w, r, err, http.StatusNotFound)
+		return nil, false
+	} else if err != nil {
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
+		return nil, false
+	}
+
+	access := security.ListAccessFilter(securityContext)
+	if access != nil && len(template.AuthorizedTeams) > 0 && !access.Authorized(nil, template.AuthorizedTeams) {
+		httperror.WriteErrorResponse(w, r, api.ErrResourceAccessDenied, http.StatusForbidden)
+		return nil, false
+	}
+	return template, true
+}
+
+// apply sets the fields of a template from a request, the source of the template is kept.
+func (req *templateRequest) apply(template *api.Template) {
+	template.Type = req.Type
+	template.Title = req.Title
+	template.Description = req.Description
+	template.Note = req.Note
+	template.Categories = req.Categories
+	template.Platform = req.Platform
+	template.Logo = req.Logo
+	template.Image = req.Image
+	template.Command = req.Command
+	template.Ports = req.Ports
+	template.Volumes = req.Volumes
+	template.Labels = req.Labels
+	template.RestartPolicy = req.RestartPolicy
+	template.StackFileContent = req.StackFileContent
+	template.Repository = req.Repository
+	template.Parameters = req.Parameters
+	template.AuthorizedTeams = req.AuthorizedTeams
+
+	// The lists are serialized as empty arrays rather than null.
+	if template.Categories == nil {
+		template.Categories = []string{}
+	}
+	if template.Ports == nil {
+		template.Ports = []string{}
+	}
+	if template.Volumes == nil {
+		template.Volumes = []string{}
+	}
+	if template.Labels == nil {
+		template.Labels = []api.Pair{}
+	}
+	if template.Parameters == nil {
+		template.Parameters = []api.TemplateParameter{}
+	}
+	if template.AuthorizedTeams == nil {
+		template.AuthorizedTeams = []api.TeamID{}
+	}
+}
+
+// validateTemplate returns the errors of the fields of a template depending on its type.
+func validateTemplate(template *api.Template) []httperror.FieldError {
+	fieldErrors := make([]httperror.FieldError, 0)
+	switch template.Type {
+	case api.ContainerTemplate:
+		if template.Image == "" {
+			fieldErrors = append(fieldErrors, httperror.FieldError{Field: "Image", Message: "is required by the container templates"})
+		}
+	case api.StackTemplate:
+		if template.StackFileContent == "" && template.Repository.URL == "" {
+			fieldErrors = append(fieldErrors, httperror.FieldError{Field: "StackFileContent", Message: "or Repository.URL is required by the stack templates"})
+		}
+	default:
+		fieldErrors = append(fieldErrors, httperror.FieldError{Field: "Type", Message: "is not a template type"})
+	}
+
+	names := make(map[string]bool)
+	for idx, parameter := range template.Parameters {
+		field := "Parameters[" + strconv.Itoa(idx) + "]"
+		if !templateParameterName.MatchString(parameter.Name) {
+			fieldErrors = append(fieldErrors, httperror.FieldError{Field: field + ".Name", Message: "is not a valid environment variable name"})
+		} else if names[parameter.Name] {
+			fieldErrors = append(fieldErrors, httperror.FieldError{Field: field + ".Name", Message: "is already used by another parameter"})
+		}
+		names[parameter.Name] = true
+
+		if parameter.Default != "" && len(parameter.Options) > 0 && !containsString(parameter.Options, parameter.Default) {
+			fieldErrors = append(fieldErrors, httperror.FieldError{Field: field + ".Default", Message: "is not one of the options"})
+		}
+	}
+	return fieldErrors
+}
+
+func parseTemplateType(value string) (api.TemplateType, error) {
+	switch value {
+	case "container":
+		return api.ContainerTemplate, nil
+	case "stack":
+		return api.StackTemplate, nil
+	}
+	return 0, ErrInvalidQueryFormat
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
 }
@@ -5,9 +5,7 @@ import (
 	httperror "cloudware/cloudware/api/http/server/error"
 	"cloudware/cloudware/api/http/server/security"
 
-	"log"
 	"net/http"
-	"os"
 
 	"github.com/gorilla/mux"
 )
@@ -15,7 +13,6 @@ import (
 // UploadHandler represents an HTTP API handler for managing file uploads.
 type UploadHandler struct {
 	*mux.Router
-	Logger      *log.Logger
 	FileService api.FileService
 }
 
@@ -23,7 +20,6 @@ type UploadHandler struct {
 func NewUploadHandler(bouncer *security.RequestBouncer) *UploadHandler {
 	h := &UploadHandler{
 		Router: mux.NewRouter(),
-		Logger: log.New(os.Stderr, "", log.LstdFlags),
 	}
 	h.Handle("/upload/tls/{certificate:(?:ca|cert|key)}",
 		bouncer.AdministratorAccess(http.HandlerFunc(h.handlePostUploadTLS))).Methods(http.MethodPost)
@@ -37,14 +33,14 @@ func (handler *UploadHandler) handlePostUploadTLS(w http.ResponseWriter, r *http
 
 	folder := r.FormValue("folder")
 	if folder == "" {
-		httperror.WriteErrorResponse(w, ErrInvalidQueryFormat, http.StatusBadRequest, handler.Logger)
+		httperror.WriteErrorResponse(w, r, ErrInvalidQueryFormat, http.StatusBadRequest)
 		return
 	}
 
 	file, _, err := r.FormFile("file")
 	defer file.Close()
 	if err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusInternalServerError, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
 		return
 	}
 
@@ -57,13 +53,13 @@ func (handler *UploadHandler) handlePostUploadTLS(w http.ResponseWriter, r *http
 	case "key":
 		fileType = api.TLSFileKey
 	default:
-		httperror.WriteErrorResponse(w, api.ErrUndefinedTLSFileType, http.StatusInternalServerError, handler.Logger)
+		httperror.WriteErrorResponse(w, r, api.ErrUndefinedTLSFileType, http.StatusInternalServerError)
 		return
 	}
 
 	err = handler.FileService.StoreTLSFile(folder, fileType, file)
 	if err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusInternalServerError, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
 		return
 	}
 }
@@ -9,9 +9,7 @@ import (
 	"cloudware/cloudware/api/http/server/security"
 
 	"encoding/json"
-	"log"
 	"net/http"
-	"os"
 
 	"github.com/asaskevich/govalidator"
 	"github.com/gorilla/mux"
@@ -20,7 +18,6 @@ import (
 // UserHandler represents an HTTP API handler for managing users.
 type UserHandler struct {
 	*mux.Router
-	Logger                 *log.Logger
 	UserService            api.UserService
 	TeamService            api.TeamService
 	TeamMembershipService  api.TeamMembershipService
@@ -33,7 +30,6 @@ type UserHandler struct {
 func NewUserHandler(bouncer *security.RequestBouncer) *UserHandler {
 	h := &UserHandler{
 		Router: mux.NewRouter(),
-		Logger: log.New(os.Stderr, "", log.LstdFlags),
 	}
 	h.Handle("/users",
 		bouncer.RestrictedAccess(http.HandlerFunc(h.handlePostUsers))).Methods(http.MethodPost)
@@ -91,44 +87,44 @@ type (
 func (handler *UserHandler) handlePostUsers(w http.ResponseWriter, r *http.Request) {
 	var req postUsersRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		httperror.WriteErrorResponse(w, ErrInvalidJSON, http.StatusBadRequest, handler.Logger)
+		httperror.WriteErrorResponse(w, r, ErrInvalidJSON, http.StatusBadRequest)
 		return
 	}
 
 	_, err := govalidator.ValidateStruct(req)
 	if err != nil {
-		httperror.WriteErrorResponse(w, ErrInvalidRequestFormat, http.StatusBadRequest, handler.Logger)
+		httperror.WriteErrorResponse(w, r, ErrInvalidRequestFormat, http.StatusBadRequest)
 		return
 	}
 
 	securityContext, err := security.RetrieveRestrictedRequestContext(r)
 	if err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusInternalServerError, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
 		return
 	}
 
 	if !securityContext.IsAdmin && !securityContext.IsTeamLeader {
-		httperror.WriteErrorResponse(w, api.ErrResourceAccessDenied, http.StatusForbidden, nil)
+		httperror.WriteErrorResponse(w, r, api.ErrResourceAccessDenied, http.StatusForbidden)
 		return
 	}
 
 	if securityContext.IsTeamLeader && req.Role == 1 {
-		httperror.WriteErrorResponse(w, api.ErrResourceAccessDenied, http.StatusForbidden, nil)
+		httperror.WriteErrorResponse(w, r, api.ErrResourceAccessDenied, http.StatusForbidden)
 		return
 	}
 
 	if strings.ContainsAny(req.Username, " ") {
-		httperror.WriteErrorResponse(w, api.ErrInvalidUsername, http.StatusBadRequest, handler.Logger)
+		httperror.WriteErrorResponse(w, r, api.ErrInvalidUsername, http.StatusBadRequest)
 		return
 	}
 
 	user, err := handler.UserService.UserByUsername(req.Username)
 	if err != nil && err != api.ErrUserNotFound {
-		httperror.WriteErrorResponse(w, err, http.StatusInternalServerError, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
 		return
 	}
 	if user != nil {
-		httperror.WriteErrorResponse(w, api.ErrUserAlreadyExists, http.StatusConflict, handler.Logger)
+		httperror.WriteErrorResponse(w, r, api.ErrUserAlreadyExists, http.StatusConflict)
 		return
 	}
 
@@ -146,48 +142,65 @@ func (handler *UserHandler) handlePostUsers(w http.ResponseWriter, r *http.Reque
 
 	settings, err := handler.SettingsService.Settings()
 	if err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusInternalServerError, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
 		return
 	}
 
 	if settings.AuthenticationMethod == api.AuthenticationInternal {
 		user.Password, err = handler.CryptoService.Hash(req.Password)
 		if err != nil {
-			httperror.WriteErrorResponse(w, api.ErrCryptoHashFailure, http.StatusBadRequest, handler.Logger)
+			httperror.WriteErrorResponse(w, r, api.ErrCryptoHashFailure, http.StatusBadRequest)
 			return
 		}
 	}
 
 	err = handler.UserService.CreateUser(user)
 	if err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusInternalServerError, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
 		return
 	}
 
-	encodeJSON(w, &postUsersResponse{ID: int(user.ID)}, handler.Logger)
+	encodeJSON(w, r, &postUsersResponse{ID: int(user.ID)})
 }
 
 // handleGetUsers handles GET requests on /users
 func (handler *UserHandler) handleGetUsers(w http.ResponseWriter, r *http.Request) {
 	securityContext, err := security.RetrieveRestrictedRequestContext(r)
 	if err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusInternalServerError, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
 		return
 	}
 
-	users, err := handler.UserService.Users()
+	options, err := parseListOptions(r)
 	if err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusInternalServerError, handler.Logger)
+		httperror.WriteErrorResponse(w, r, ErrInvalidQueryFormat, http.StatusBadRequest)
 		return
 	}
+	query := &api.UserQuery{
+		ListOptions:           options,
+		ExcludeAdministrators: !securityContext.IsAdmin,
+	}
+	if role := r.URL.Query().Get("role"); role != "" {
+		value, err := strconv.Atoi(role)
+		if err != nil {
+			httperror.WriteErrorResponse(w, r, ErrInvalidQueryFormat, http.StatusBadRequest)
+			return
+		}
+		query.Role = api.UserRole(value)
+	}
 
-	filteredUsers := security.FilterUsers(users, securityContext)
+	users, total, err := handler.UserService.ListUsers(query)
+	if err != nil {
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
+		return
+	}
 
-	for i := range filteredUsers {
-		filteredUsers[i].Password = ""
+	for i := range users {
+		users[i].Password = ""
 	}
 
-	encodeJSON(w, filteredUsers, handler.Logger)
+	writeTotalCount(w, total)
+	encodeJSON(w, r, users)
 }
 
 // handlePostUserPasswd handles POST requests on /users/:id/passwd
@@ -197,19 +210,19 @@ func (handler *UserHandler) handlePostUserPasswd(w http.ResponseWriter, r *http.
 
 	userID, err := strconv.Atoi(id)
 	if err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusBadRequest, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusBadRequest)
 		return
 	}
 
 	var req postUserPasswdRequest
 	if err = json.NewDecoder(r.Body).Decode(&req); err != nil {
-		httperror.WriteErrorResponse(w, ErrInvalidJSON, http.StatusBadRequest, handler.Logger)
+		httperror.WriteErrorResponse(w, r, ErrInvalidJSON, http.StatusBadRequest)
 		return
 	}
 
 	_, err = govalidator.ValidateStruct(req)
 	if err != nil {
-		httperror.WriteErrorResponse(w, ErrInvalidRequestFormat, http.StatusBadRequest, handler.Logger)
+		httperror.WriteErrorResponse(w, r, ErrInvalidRequestFormat, http.StatusBadRequest)
 		return
 	}
 
@@ -217,10 +230,10 @@ func (handler *UserHandler) handlePostUserPasswd(w http.ResponseWriter, r *http.
 
 	u, err := handler.UserService.User(api.UserID(userID))
 	if err == api.ErrUserNotFound {
-		httperror.WriteErrorResponse(w, err, http.StatusNotFound, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusNotFound)
 		return
 	} else if err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusInternalServerError, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
 		return
 	}
 
@@ -230,7 +243,7 @@ func (handler *UserHandler) handlePostUserPasswd(w http.ResponseWriter, r *http.
 		valid = false
 	}
 
-	encodeJSON(w, &postUserPasswdResponse{Valid: valid}, handler.Logger)
+	encodeJSON(w, r, &postUserPasswdResponse{Valid: valid})
 }
 
 // handleGetUser handles GET requests on /users/:id
@@ -240,21 +253,21 @@ func (handler *UserHandler) handleGetUser(w http.ResponseWriter, r *http.Request
 
 	userID, err := strconv.Atoi(id)
 	if err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusBadRequest, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusBadRequest)
 		return
 	}
 
 	user, err := handler.UserService.User(api.UserID(userID))
 	if err == api.ErrUserNotFound {
-		httperror.WriteErrorResponse(w, err, http.StatusNotFound, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusNotFound)
 		return
 	} else if err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusInternalServerError, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
 		return
 	}
 
 	user.Password = ""
-	encodeJSON(w, &user, handler.Logger)
+	encodeJSON(w, r, &user)
 }
 
 // handlePutUser handles PUT requests on /users/:id
@@ -264,58 +277,58 @@ func (handler *UserHandler) handlePutUser(w http.ResponseWriter, r *http.Request
 
 	userID, err := strconv.Atoi(id)
 	if err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusBadRequest, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusBadRequest)
 		return
 	}
 
 	tokenData, err := security.RetrieveTokenData(r)
 	if err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusInternalServerError, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
 		return
 	}
 
 	if tokenData.Role != api.AdministratorRole && tokenData.ID != api.UserID(userID) {
-		httperror.WriteErrorResponse(w, api.ErrUnauthorized, http.StatusForbidden, handler.Logger)
+		httperror.WriteErrorResponse(w, r, api.ErrUnauthorized, http.StatusForbidden)
 		return
 	}
 
 	var req putUserRequest
 	if err = json.NewDecoder(r.Body).Decode(&req); err != nil {
-		httperror.WriteErrorResponse(w, ErrInvalidJSON, http.StatusBadRequest, handler.Logger)
+		httperror.WriteErrorResponse(w, r, ErrInvalidJSON, http.StatusBadRequest)
 		return
 	}
 
 	_, err = govalidator.ValidateStruct(req)
 	if err != nil {
-		httperror.WriteErrorResponse(w, ErrInvalidRequestFormat, http.StatusBadRequest, handler.Logger)
+		httperror.WriteErrorResponse(w, r, ErrInvalidRequestFormat, http.StatusBadRequest)
 		return
 	}
 
 	if req.Password == "" && req.Role == 0 {
-		httperror.WriteErrorResponse(w, ErrInvalidRequestFormat, http.StatusBadRequest, handler.Logger)
+		httperror.WriteErrorResponse(w, r, ErrInvalidRequestFormat, http.StatusBadRequest)
 		return
 	}
 
 	user, err := handler.UserService.User(api.UserID(userID))
 	if err == api.ErrUserNotFound {
-		httperror.WriteErrorResponse(w, err, http.StatusNotFound, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusNotFound)
 		return
 	} else if err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusInternalServerError, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
 		return
 	}
 
 	if req.Password != "" {
 		user.Password, err = handler.CryptoService.Hash(req.Password)
 		if err != nil {
-			httperror.WriteErrorResponse(w, api.ErrCryptoHashFailure, http.StatusBadRequest, handler.Logger)
+			httperror.WriteErrorResponse(w, r, api.ErrCryptoHashFailure, http.StatusBadRequest)
 			return
 		}
 	}
 
 	if req.Role != 0 {
 		if tokenData.Role != api.AdministratorRole {
-			httperror.WriteErrorResponse(w, api.ErrUnauthorized, http.StatusForbidden, handler.Logger)
+			httperror.WriteErrorResponse(w, r, api.ErrUnauthorized, http.StatusForbidden)
 			return
 		}
 		if req.Role == 1 {
@@ -327,7 +340,7 @@ func (handler *UserHandler) handlePutUser(w http.ResponseWriter, r *http.Request
 
 	err = handler.UserService.UpdateUser(user.ID, user)
 	if err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusInternalServerError, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
 		return
 	}
 }
@@ -336,11 +349,11 @@ func (handler *UserHandler) handlePutUser(w http.ResponseWriter, r *http.Request
 func (handler *UserHandler) handleGetAdminCheck(w http.ResponseWriter, r *http.Request) {
 	users, err := handler.UserService.UsersByRole(api.AdministratorRole)
 	if err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusInternalServerError, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
 		return
 	}
 	if len(users) == 0 {
-		httperror.WriteErrorResponse(w, api.ErrUserNotFound, http.StatusNotFound, handler.Logger)
+		httperror.WriteErrorResponse(w, r, api.ErrUserNotFound, http.StatusNotFound)
 		return
 	}
 }
@@ -349,19 +362,19 @@ func (handler *UserHandler) handleGetAdminCheck(w http.ResponseWriter, r *http.R
 func (handler *UserHandler) handlePostAdminInit(w http.ResponseWriter, r *http.Request) {
 	var req postAdminInitRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		httperror.WriteErrorResponse(w, ErrInvalidJSON, http.StatusBadRequest, handler.Logger)
+		httperror.WriteErrorResponse(w, r, ErrInvalidJSON, http.StatusBadRequest)
 		return
 	}
 
 	_, err := govalidator.ValidateStruct(req)
 	if err != nil {
-		httperror.WriteErrorResponse(w, ErrInvalidRequestFormat, http.StatusBadRequest, handler.Logger)
+		httperror.WriteErrorResponse(w, r, ErrInvalidRequestFormat, http.StatusBadRequest)
 		return
 	}
 
 	users, err := handler.UserService.UsersByRole(api.AdministratorRole)
 	if err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusInternalServerError, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
 		return
 	}
 	if len(users) == 0 {
@@ -371,17 +384,17 @@ func (handler *UserHandler) handlePostAdminInit(w http.ResponseWriter, r *http.R
 		}
 		user.Password, err = handler.CryptoService.Hash(req.Password)
 		if err != nil {
-			httperror.WriteErrorResponse(w, api.ErrCryptoHashFailure, http.StatusBadRequest, handler.Logger)
+			httperror.WriteErrorResponse(w, r, api.ErrCryptoHashFailure, http.StatusBadRequest)
 			return
 		}
 
 		err = handler.UserService.CreateUser(user)
 		if err != nil {
-			httperror.WriteErrorResponse(w, err, http.StatusInternalServerError, handler.Logger)
+			httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
 			return
 		}
 	} else {
-		httperror.WriteErrorResponse(w, api.ErrAdminAlreadyInitialized, http.StatusConflict, handler.Logger)
+		httperror.WriteErrorResponse(w, r, api.ErrAdminAlreadyInitialized, http.StatusConflict)
 		return
 	}
 }
@@ -393,45 +406,45 @@ func (handler *UserHandler) handleDeleteUser(w http.ResponseWriter, r *http.Requ
 
 	userID, err := strconv.Atoi(id)
 	if err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusBadRequest, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusBadRequest)
 		return
 	}
 
 	if userID == 1 {
-		httperror.WriteErrorResponse(w, api.ErrCannotRemoveAdmin, http.StatusForbidden, handler.Logger)
+		httperror.WriteErrorResponse(w, r, api.ErrCannotRemoveAdmin, http.StatusForbidden)
 		return
 	}
 
 	tokenData, err := security.RetrieveTokenData(r)
 	if err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusInternalServerError, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
 		return
 	}
 
 	if tokenData.ID == api.UserID(userID) {
-		httperror.WriteErrorResponse(w, api.ErrAdminCannotRemoveSelf, http.StatusForbidden, handler.Logger)
+		httperror.WriteErrorResponse(w, r, api.ErrAdminCannotRemoveSelf, http.StatusForbidden)
 		return
 	}
 
 	_, err = handler.UserService.User(api.UserID(userID))
 
 	if err == api.ErrUserNotFound {
-		httperror.WriteErrorResponse(w, err, http.StatusNotFound, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusNotFound)
 		return
 	} else if err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusInternalServerError, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
 		return
 	}
 
 	err = handler.UserService.DeleteUser(api.UserID(userID))
 	if err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusInternalServerError, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
 		return
 	}
 
 	err = handler.TeamMembershipService.DeleteTeamMembershipByUserID(api.UserID(userID))
 	if err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusInternalServerError, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
 		return
 	}
 }
@@ -443,26 +456,26 @@ func (handler *UserHandler) handleGetMemberships(w http.ResponseWriter, r *http.
 
 	userID, err := strconv.Atoi(id)
 	if err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusBadRequest, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusBadRequest)
 		return
 	}
 
 	tokenData, err := security.RetrieveTokenData(r)
 	if err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusInternalServerError, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
 		return
 	}
 
 	if tokenData.Role != api.AdministratorRole && tokenData.ID != api.UserID(userID) {
-		httperror.WriteErrorResponse(w, api.ErrUnauthorized, http.StatusForbidden, handler.Logger)
+		httperror.WriteErrorResponse(w, r, api.ErrUnauthorized, http.StatusForbidden)
 		return
 	}
 
 	memberships, err := handler.TeamMembershipService.TeamMembershipsByUserID(api.UserID(userID))
 	if err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusInternalServerError, handler.Logger)
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
 		return
 	}
 
-	encodeJSON(w, memberships, handler.Logger)
+	encodeJSON(w, r, memberships)
 }
@@ -2,11 +2,11 @@ package handler
 
 import (
 	"bytes"
+	"context"
 	"crypto/tls"
 	"encoding/json"
 	"fmt"
 	"io"
-	"log"
 	"net"
 	"net/http"
 	"net/http/httputil"
@@ -15,120 +15,417 @@ import (
 	"strconv"
 	"time"
 
-	"github.com/gorilla/mux"
 	"cloudware/cloudware/api"
 	"cloudware/cloudware/api/crypto"
+	httperror "cloudware/cloudware/api/http/server/error"
+	"cloudware/cloudware/api/http/server/proxy"
+	"cloudware/cloudware/api/http/server/security"
+	"cloudware/cloudware/api/logging"
+	"cloudware/cloudware/api/recorder"
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
 	"golang.org/x/net/websocket"
 )
 
 // WebSocketHandler represents an HTTP API handler for proxying requests to a web socket.
 type WebSocketHandler struct {
 	*mux.Router
-	Logger          *log.Logger
-	EndpointService api.EndpointService
+	EndpointService       api.EndpointService
+	TeamMembershipService api.TeamMembershipService
+	RecordingService      api.RecordingService
+	FileService           api.FileService
+	ProxyManager          *proxy.Manager
+	Sessions              *SessionTracker
 }
 
+type (
+	webSocketSession struct {
+		endpoint      *api.Endpoint
+		host          string
+		scheme        string
+		tlsConfig     *tls.Config
+		recordingType api.RecordingType
+		containerID   string
+		execID        string
+	}
+
+	execStartConfig struct {
+		Tty    bool
+		Detach bool
+	}
+
+	execInspectResponse struct {
+		ContainerID string `json:"ContainerID"`
+	}
+
+	contextKey int
+)
+
+// sessionContainerKey is the key of the container identifier of a session in the context of its request.
+const sessionContainerKey contextKey = iota
+
 // NewWebSocketHandler returns a new instance of WebSocketHandler.
-func NewWebSocketHandler() *WebSocketHandler {
+func NewWebSocketHandler(bouncer *security.RequestBouncer) *WebSocketHandler {
 	h := &WebSocketHandler{
-		Router: mux.NewRouter(),
-		Logger: log.New(os.Stderr, "", log.LstdFlags),
+		Router:   mux.NewRouter(),
+		Sessions: NewSessionTracker(),
 	}
-	h.Handle("/websocket/exec", websocket.Handler(h.webSocketDockerExec))
+	h.Handle("/websocket/exec",
+		bouncer.WebSocketAccess(h.checkContainerAccess(h.trackSession(h.webSocketDockerExec), true)))
+	h.Handle("/websocket/attach",
+		bouncer.WebSocketAccess(h.checkContainerAccess(h.trackSession(h.webSocketDockerAttach), false)))
+	h.Handle("/websocket/replay",
+		bouncer.AdministratorWebSocketAccess(h.trackSession(h.webSocketRecordingReplay)))
 	return h
 }
 
+// trackSession registers the websocket connections so that the sessions are drained when the server shuts down.
+func (handler *WebSocketHandler) trackSession(session func(ws *websocket.Conn)) websocket.Handler {
+	return func(ws *websocket.Conn) {
+		if !handler.Sessions.Add(ws) {
+			return
+		}
+		defer handler.Sessions.Remove(ws)
+		session(ws)
+	}
+}
+
+// webSocketDockerExec handles websocket connections on /websocket/exec?id=<execId>&endpointId=<endpointId>
 func (handler *WebSocketHandler) webSocketDockerExec(ws *websocket.Conn) {
 	qry := ws.Request().URL.Query()
 	execID := qry.Get("id")
-	edpID := qry.Get("endpointId")
 
-	parsedID, err := strconv.Atoi(edpID)
+	session, err := handler.createSession(ws)
 	if err != nil {
-		log.Printf("Unable to parse endpoint ID: %s", err)
+		logging.FromRequest(ws.Request()).Errorf("Unable to create exec session: %s", err)
 		return
 	}
+	session.recordingType = api.ExecRecording
+	session.execID = execID
+	session.containerID = sessionContainerID(ws.Request())
 
-	endpointID := api.EndpointID(parsedID)
-	endpoint, err := handler.EndpointService.Endpoint(endpointID)
+	buf, err := json.Marshal(&execStartConfig{Tty: true, Detach: false})
 	if err != nil {
-		log.Printf("Unable to retrieve endpoint: %s", err)
+		logging.FromRequest(ws.Request()).Errorf("Unable to marshal exec configuration: %s", err)
 		return
 	}
 
-	endpointURL, err := url.Parse(endpoint.URL)
+	request, err := http.NewRequest(http.MethodPost, "/exec/"+execID+"/start", bytes.NewReader(buf))
 	if err != nil {
-		log.Printf("Unable to parse endpoint URL: %s", err)
+		logging.FromRequest(ws.Request()).Errorf("Unable to create exec start request: %s", err)
 		return
 	}
+	request.Header.Set("Content-Type", "application/json")
 
-	var host string
-	if endpointURL.Scheme == "tcp" {
-		host = endpointURL.Host
-	} else if endpointURL.Scheme == "unix" {
-		host = endpointURL.Path
+	err = handler.streamSession(ws, session, request)
+	if err != nil {
+		logging.FromRequest(ws.Request()).Errorf("Error during exec session: %s", err)
+	}
+}
+
+// webSocketDockerAttach handles websocket connections on /websocket/attach?id=<containerId>&endpointId=<endpointId>
+func (handler *WebSocketHandler) webSocketDockerAttach(ws *websocket.Conn) {
+	qry := ws.Request().URL.Query()
+	containerID := qry.Get("id")
+
+	session, err := handler.createSession(ws)
+	if err != nil {
+		logging.FromRequest(ws.Request()).Errorf("Unable to create attach session: %s", err)
+		return
 	}
+	session.recordingType = api.AttachRecording
+	session.containerID = containerID
 
-	// TODO: Should not be managed here
-	var tlsConfig *tls.Config
-	if endpoint.TLSConfig.TLS {
-		tlsConfig, err = crypto.CreateTLSConfiguration(&endpoint.TLSConfig)
+	request, err := http.NewRequest(http.MethodPost, "/containers/"+containerID+"/attach?stream=1&stdin=1&stdout=1&stderr=1", nil)
+	if err != nil {
+		logging.FromRequest(ws.Request()).Errorf("Unable to create attach request: %s", err)
+		return
+	}
+
+	err = handler.streamSession(ws, session, request)
+	if err != nil {
+		logging.FromRequest(ws.Request()).Errorf("Error during attach session: %s", err)
+	}
+}
+
+// webSocketRecordingReplay handles websocket connections on /websocket/replay?id=<recordingId>&speed=<speed>
+func (handler *WebSocketHandler) webSocketRecordingReplay(ws *websocket.Conn) {
+	qry := ws.Request().URL.Query()
+
+	recordingID, err := strconv.Atoi(qry.Get("id"))
+	if err != nil {
+		logging.FromRequest(ws.Request()).Errorf("Unable to parse recording ID: %s", err)
+		return
+	}
+
+	speed := 1.0
+	if qry.Get("speed") != "" {
+		speed, err = strconv.ParseFloat(qry.Get("speed"), 64)
 		if err != nil {
-			log.Fatalf("Unable to create TLS configuration: %s", err)
+			logging.FromRequest(ws.Request()).Errorf("Unable to parse replay speed: %s", err)
 			return
 		}
 	}
 
-	if err := hijack(host, endpointURL.Scheme, "POST", "/exec/"+execID+"/start", tlsConfig, true, ws, ws, ws, nil, nil); err != nil {
-		log.Fatalf("error during hijack: %s", err)
+	recording, err := handler.RecordingService.Recording(api.RecordingID(recordingID))
+	if err != nil {
+		logging.FromRequest(ws.Request()).Errorf("Unable to retrieve recording: %s", err)
+		return
+	}
+
+	recordingFile, err := os.Open(recording.FilePath)
+	if err != nil {
+		logging.FromRequest(ws.Request()).Errorf("Unable to open recording file: %s", err)
 		return
 	}
+	defer recordingFile.Close()
+
+	err = recorder.Replay(ws, recordingFile, speed, nil)
+	if err != nil {
+		logging.FromRequest(ws.Request()).Errorf("Error during recording replay: %s", err)
+	}
 }
 
-type execConfig struct {
-	Tty    bool
-	Detach bool
+// checkContainerAccess ensures that the user can access the endpoint and the container of an exec or
+// attach session before the connection is upgraded. The container is inspected through the Docker proxy,
+// which denies the access when a resource control of the container, its service or its stack does not
+// authorize the user.
+func (handler *WebSocketHandler) checkContainerAccess(next http.Handler, exec bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		endpoint, err := handler.authorizedEndpoint(r)
+		if err != nil {
+			httperror.WriteErrorResponse(w, r, err, sessionErrorStatus(err))
+			return
+		}
+
+		client, err := handler.ProxyManager.GetClient(endpoint)
+		if err != nil {
+			httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
+			return
+		}
+
+		containerID := r.URL.Query().Get("id")
+		if exec {
+			var inspect execInspectResponse
+			code, err := dockerJSONRequest(r, client, "/exec/"+url.PathEscape(containerID)+"/json", &inspect)
+			if err != nil {
+				httperror.WriteErrorResponse(w, r, err, code)
+				return
+			}
+			containerID = inspect.ContainerID
+		}
+
+		var container map[string]interface{}
+		code, err := dockerJSONRequest(r, client, "/containers/"+url.PathEscape(containerID)+"/json", &container)
+		if code == http.StatusForbidden {
+			httperror.WriteErrorResponse(w, r, api.ErrResourceAccessDenied, http.StatusForbidden)
+			return
+		} else if err != nil {
+			httperror.WriteErrorResponse(w, r, err, code)
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), sessionContainerKey, containerID)))
+	})
 }
 
-// hijack allows to upgrade an HTTP connection to a TCP connection
-// It redirects IO streams for stdin, stdout and stderr to a websocket
-func hijack(addr, scheme, method, path string, tlsConfig *tls.Config, setRawTerminal bool, in io.ReadCloser, stdout, stderr io.Writer, started chan io.Closer, data interface{}) error {
-	execConfig := &execConfig{
-		Tty:    true,
-		Detach: false,
+// sessionContainerID returns the identifier of the container of a session, resolved by checkContainerAccess.
+func sessionContainerID(request *http.Request) string {
+	containerID, _ := request.Context().Value(sessionContainerKey).(string)
+	return containerID
+}
+
+// authorizedEndpoint returns the endpoint of a websocket request, it returns api.ErrEndpointAccessDenied
+// when the user is not authorized to access it.
+func (handler *WebSocketHandler) authorizedEndpoint(request *http.Request) (*api.Endpoint, error) {
+	parsedID, err := strconv.Atoi(request.URL.Query().Get("endpointId"))
+	if err != nil {
+		return nil, ErrInvalidQueryFormat
+	}
+	logging.AddFields(request.Context(), logrus.Fields{"endpoint": parsedID})
+
+	endpoint, err := handler.EndpointService.Endpoint(api.EndpointID(parsedID))
+	if err != nil {
+		return nil, err
+	}
+
+	tokenData, err := security.RetrieveTokenData(request)
+	if err != nil {
+		return nil, err
+	}
+
+	if tokenData.Role != api.AdministratorRole {
+		memberships, err := handler.TeamMembershipService.TeamMembershipsByUserID(tokenData.ID)
+		if err != nil {
+			return nil, err
+		}
+
+		authorizedEndpoints, err := security.FilterEndpoints([]api.Endpoint{*endpoint}, &security.RestrictedRequestContext{
+			UserID:          tokenData.ID,
+			UserMemberships: memberships,
+		})
+		if err != nil {
+			return nil, err
+		}
+		if len(authorizedEndpoints) == 0 {
+			return nil, api.ErrEndpointAccessDenied
+		}
+	}
+
+	return endpoint, nil
+}
+
+// sessionErrorStatus returns the status of the response to an error raised while authorizing a session.
+func sessionErrorStatus(err error) int {
+	switch err {
+	case ErrInvalidQueryFormat:
+		return http.StatusBadRequest
+	case api.ErrEndpointNotFound:
+		return http.StatusNotFound
+	case api.ErrEndpointAccessDenied:
+		return http.StatusForbidden
+	}
+	return http.StatusInternalServerError
+}
+
+// createSession retrieves the endpoint associated to the websocket request, ensures that
+// the user can access it and prepares the connection details used to reach the Docker API.
+func (handler *WebSocketHandler) createSession(ws *websocket.Conn) (*webSocketSession, error) {
+	endpoint, err := handler.authorizedEndpoint(ws.Request())
+	if err != nil {
+		return nil, err
+	}
+
+	endpointURL, err := url.Parse(endpoint.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	session := &webSocketSession{
+		endpoint: endpoint,
+		scheme:   endpointURL.Scheme,
+	}
+
+	if endpointURL.Scheme == "tcp" {
+		session.host = endpointURL.Host
+	} else if endpointURL.Scheme == "unix" {
+		session.host = endpointURL.Path
+	}
+
+	// TODO: Should not be managed here
+	if endpoint.TLSConfig.TLS {
+		session.tlsConfig, err = crypto.CreateTLSConfiguration(&endpoint.TLSConfig)
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	buf, err := json.Marshal(execConfig)
+	return session, nil
+}
+
+// streamSession hijacks the connection to the Docker API and redirects the websocket streams to it.
+// When session recording is enabled on the endpoint, the streams are recorded during the whole session.
+func (handler *WebSocketHandler) streamSession(ws *websocket.Conn, session *webSocketSession, request *http.Request) error {
+	if !session.endpoint.RecordSessions {
+		return session.hijack(request, ws, ws)
+	}
+
+	tokenData, err := security.RetrieveTokenData(ws.Request())
 	if err != nil {
-		return fmt.Errorf("error marshaling exec config: %s", err)
+		return err
 	}
 
-	rdr := bytes.NewReader(buf)
+	recording := &api.Recording{
+		Type:        session.recordingType,
+		UserID:      tokenData.ID,
+		Username:    tokenData.Username,
+		EndpointID:  session.endpoint.ID,
+		ContainerID: session.containerID,
+		ExecID:      session.execID,
+		StartedAt:   time.Now().Unix(),
+	}
 
-	req, err := http.NewRequest(method, path, rdr)
+	err = handler.RecordingService.CreateRecording(recording)
 	if err != nil {
-		return fmt.Errorf("error during hijack request: %s", err)
+		return err
 	}
 
-	req.Header.Set("User-Agent", "Docker-Client")
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Connection", "Upgrade")
-	req.Header.Set("Upgrade", "tcp")
-	req.Host = addr
+	// The file is named after the recording, whose path is saved as soon as the file exists
+	// so that the recording of a session interrupted by a shutdown can still be replayed.
+	fileName := strconv.Itoa(int(recording.ID)) + ".cast"
+	recordingFile, filePath, err := handler.FileService.CreateRecordingFile(fileName)
+	if err != nil {
+		handler.discardRecording(ws, recording)
+		return err
+	}
+	recording.FilePath = filePath
+
+	err = handler.RecordingService.UpdateRecording(recording.ID, recording)
+	if err != nil {
+		recordingFile.Close()
+		handler.discardRecording(ws, recording)
+		return err
+	}
 
+	title := fmt.Sprintf("%s@%s:%s", tokenData.Username, session.endpoint.Name, session.containerID)
+	sessionRecorder, err := recorder.NewRecorder(recordingFile, title)
+	if err != nil {
+		recordingFile.Close()
+		handler.discardRecording(ws, recording)
+		return err
+	}
+
+	sessionErr := session.hijack(request, sessionRecorder.Input(ws), sessionRecorder.Output(ws))
+
+	err = recordingFile.Close()
+	if err != nil {
+		logging.FromRequest(ws.Request()).Errorf("Unable to close recording file: %s", err)
+	}
+	if sessionRecorder.Err() != nil {
+		logging.FromRequest(ws.Request()).Errorf("Session recording incomplete: %s", sessionRecorder.Err())
+	}
+
+	recording.Duration = sessionRecorder.Duration().Seconds()
+	if fileInfo, err := os.Stat(filePath); err == nil {
+		recording.Size = fileInfo.Size()
+	}
+
+	err = handler.RecordingService.UpdateRecording(recording.ID, recording)
+	if err != nil {
+		logging.FromRequest(ws.Request()).Errorf("Unable to persist recording: %s", err)
+	}
+
+	return sessionErr
+}
+
+// discardRecording deletes a recording that could not be started along with its file.
+func (handler *WebSocketHandler) discardRecording(ws *websocket.Conn, recording *api.Recording) {
+	if recording.FilePath != "" {
+		err := handler.FileService.DeleteRecordingFile(recording.FilePath)
+		if err != nil {
+			logging.FromRequest(ws.Request()).Errorf("Unable to delete recording file: %s", err)
+		}
+	}
+	err := handler.RecordingService.DeleteRecording(recording.ID)
+	if err != nil {
+		logging.FromRequest(ws.Request()).Errorf("Unable to delete recording: %s", err)
+	}
+}
+
+func (session *webSocketSession) dial() (net.Conn, error) {
 	var (
 		dial    net.Conn
 		dialErr error
 	)
 
-	if tlsConfig == nil {
-		dial, dialErr = net.Dial(scheme, addr)
+	if session.tlsConfig == nil {
+		dial, dialErr = net.Dial(session.scheme, session.host)
 	} else {
-		dial, dialErr = tls.Dial(scheme, addr, tlsConfig)
+		dial, dialErr = tls.Dial(session.scheme, session.host, session.tlsConfig)
 	}
 
 	if dialErr != nil {
-		return dialErr
+		return nil, dialErr
 	}
 
 	// When we set up a TCP connection for hijack, there could be long periods
@@ -140,57 +437,50 @@ func hijack(addr, scheme, method, path string, tlsConfig *tls.Config, setRawTerm
 		tcpConn.SetKeepAlive(true)
 		tcpConn.SetKeepAlivePeriod(30 * time.Second)
 	}
+
+	return dial, nil
+}
+
+// hijack allows to upgrade an HTTP connection to a TCP connection
+// It redirects IO streams for stdin and stdout to the specified streams
+// and returns once one of the streams is closed.
+func (session *webSocketSession) hijack(request *http.Request, in io.Reader, out io.Writer) error {
+	request.Header.Set("User-Agent", "Docker-Client")
+	request.Header.Set("Connection", "Upgrade")
+	request.Header.Set("Upgrade", "tcp")
+	request.Host = session.host
+
+	dial, err := session.dial()
 	if err != nil {
 		return err
 	}
+
 	clientconn := httputil.NewClientConn(dial, nil)
 	defer clientconn.Close()
 
 	// Server hijacks the connection, error 'connection closed' expected
-	clientconn.Do(req)
+	clientconn.Do(request)
 
 	rwc, br := clientconn.Hijack()
 	defer rwc.Close()
 
-	if started != nil {
-		started <- rwc
-	}
-
-	var receiveStdout chan error
+	errChan := make(chan error, 2)
 
-	if stdout != nil || stderr != nil {
-		go func() (err error) {
-			if setRawTerminal && stdout != nil {
-				_, err = io.Copy(stdout, br)
-			}
-			return err
-		}()
-	}
+	go func() {
+		_, err := io.Copy(out, br)
+		errChan <- err
+	}()
 
-	go func() error {
-		if in != nil {
-			io.Copy(rwc, in)
-		}
+	go func() {
+		_, err := io.Copy(rwc, in)
 
 		if conn, ok := rwc.(interface {
 			CloseWrite() error
 		}); ok {
-			if err := conn.CloseWrite(); err != nil {
-			}
-		}
-		return nil
-	}()
-
-	if stdout != nil || stderr != nil {
-		if err := <-receiveStdout; err != nil {
-			return err
-		}
-	}
-	go func() {
-		for {
-			fmt.Println(br)
+			conn.CloseWrite()
 		}
+		errChan <- err
 	}()
 
-	return nil
+	return <-errChan
 }
@@ -1,7 +1,7 @@
 package server
 
 import (
-	"log"
+	"time"
 
 	"cloudware/cloudware/api"
 	"cloudware/cloudware/api/file"
@@ -10,30 +10,40 @@ import (
 	"cloudware/cloudware/api/exec"
 	"cloudware/cloudware/api/crypto"
 	"cloudware/cloudware/api/cron"
+	"cloudware/cloudware/api/pki"
+	"cloudware/cloudware/api/backup"
+
+	"github.com/sirupsen/logrus"
 )
 
 func initFileService(dataStorePath string) api.FileService {
 	fileService, err := file.NewService(dataStorePath, "")
 	if err != nil {
-		log.Fatal(err)
+		logrus.Fatal(err)
 	}
 	return fileService
 }
 
-func initStore(dataStorePath string) *bolt.Store {
+func initStore(dataStorePath string, encryptionService api.EncryptionService) *bolt.Store {
 	store, err := bolt.NewStore(dataStorePath)
 	if err != nil {
-		log.Fatal(err)
+		logrus.Fatal(err)
 	}
+	store.EncryptionService = encryptionService
 
 	err = store.Open()
 	if err != nil {
-		log.Fatal(err)
+		logrus.Fatal(err)
 	}
 
 	err = store.MigrateData()
 	if err != nil {
-		log.Fatal(err)
+		logrus.Fatal(err)
+	}
+
+	err = store.EncryptSecrets()
+	if err != nil {
+		logrus.Fatal(err)
 	}
 	return store
 }
@@ -42,11 +52,15 @@ func initStackManager(assetsPath string) api.StackManager {
 	return exec.NewStackManager(assetsPath)
 }
 
+func initGitService() api.GitService {
+	return exec.NewGitService()
+}
+
 func initJWTService(authenticationEnabled bool) api.JWTService {
 	if authenticationEnabled {
 		jwtService, err := jwt.NewService()
 		if err != nil {
-			log.Fatal(err)
+			logrus.Fatal(err)
 		}
 		return jwtService
 	}
@@ -57,20 +71,67 @@ func initCryptoService() api.CryptoService {
 	return &crypto.Service{}
 }
 
-func initEndpointWatcher(endpointService api.EndpointService, externalEnpointFile string, syncInterval string) bool {
+func initEncryptionService(keyFile, previousKeyFile string) api.EncryptionService {
+	key, err := crypto.LoadEncryptionKey(keyFile, crypto.EncryptionKeyEnvVar)
+	if err != nil {
+		logrus.Fatal(err)
+	}
+
+	previousKey, err := crypto.LoadEncryptionKey(previousKeyFile, crypto.PreviousEncryptionKeyEnvVar)
+	if err != nil {
+		logrus.Fatal(err)
+	}
+
+	if key == nil {
+		if previousKey != nil {
+			logrus.Fatal("A previous encryption key can only be used alongside an encryption key.")
+		}
+		logrus.Info("No encryption key configured. Secrets will be stored in clear text.")
+		return nil
+	}
+
+	encryptionService, err := crypto.NewEncryptionService(key, previousKey)
+	if err != nil {
+		logrus.Fatal(err)
+	}
+	return encryptionService
+}
+
+func initEndpointWatcher(watcher *cron.Watcher, externalEnpointFile string) bool {
 	authorizeEndpointMgmt := true
 	if externalEnpointFile != "" {
 		authorizeEndpointMgmt = false
-		log.Println("Using external endpoint definition. Endpoint management via the API will be disabled.")
-		endpointWatcher := cron.NewWatcher(endpointService, syncInterval)
-		err := endpointWatcher.WatchEndpointFile(externalEnpointFile)
+		logrus.Info("Using external endpoint definition. Endpoint management via the API will be disabled.")
+		err := watcher.WatchEndpointFile(externalEnpointFile)
 		if err != nil {
-			log.Fatal(err)
+			logrus.Fatal(err)
 		}
 	}
 	return authorizeEndpointMgmt
 }
 
+func initRecordingRetention(watcher *cron.Watcher, recordingService api.RecordingService, settingsService api.SettingsService, fileService api.FileService) {
+	err := watcher.WatchRecordingRetention(recordingService, settingsService, fileService)
+	if err != nil {
+		logrus.Fatal(err)
+	}
+}
+
+func initBackupSchedule(watcher *cron.Watcher, settingsService api.SettingsService, backupStatusService api.BackupStatusService, backupService api.BackupService) {
+	err := watcher.WatchBackups(settingsService, backupStatusService, backupService)
+	if err != nil {
+		logrus.Fatal(err)
+	}
+}
+
+func initPKIService(fileService api.FileService, enabled bool) api.PKIService {
+	return pki.NewService(fileService, enabled)
+}
+
+func initBackupService(dataStorePath string, store *bolt.Store, watcher *cron.Watcher, encryptionService api.EncryptionService) api.BackupService {
+	return backup.NewService(dataStorePath, store, watcher, encryptionService)
+}
+
 func initStatus(authorizeEndpointMgmt bool, flags *api.HttpCliFlags) *api.Status {
 	return &api.Status{
 		Analytics:          !flags.NoAnalytics,
@@ -134,10 +195,21 @@ func initSettings(settingsService api.SettingsService, flags *api.HttpCliFlags)
 	return nil
 }
 
+func initTimeout(name, value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	timeout, err := time.ParseDuration(value)
+	if err != nil {
+		logrus.Fatalf("Invalid %s: %s", name, value)
+	}
+	return timeout
+}
+
 func retrieveFirstEndpointFromDatabase(endpointService api.EndpointService) *api.Endpoint {
 	endpoints, err := endpointService.Endpoints()
 	if err != nil {
-		log.Fatal(err)
+		logrus.Fatal(err)
 	}
 	return &endpoints[0]
 }
@@ -145,8 +217,9 @@ func retrieveFirstEndpointFromDatabase(endpointService api.EndpointService) *api
 func New(flags * api.HttpCliFlags) *Server{
 	fileService := initFileService(flags.Data)
 
-	store := initStore(flags.Data)
-	defer store.Close()
+	encryptionService := initEncryptionService(flags.EncryptionKeyFile, flags.PreviousEncryptionKeyFile)
+
+	store := initStore(flags.Data, encryptionService)
 
 	stackManager := initStackManager(flags.Assets)
 
@@ -154,24 +227,34 @@ func New(flags * api.HttpCliFlags) *Server{
 
 	cryptoService := initCryptoService()
 
-	authorizeEndpointMgmt := initEndpointWatcher(store.EndpointService, flags.ExternalEndpoints, flags.SyncInterval)
+	pkiService := initPKIService(fileService, !flags.NoPKI)
+
+	watcher := cron.NewWatcher(store.EndpointService, store.UserService, store.TeamService, flags.SyncInterval)
+
+	backupService := initBackupService(flags.Data, store, watcher, encryptionService)
+
+	authorizeEndpointMgmt := initEndpointWatcher(watcher, flags.ExternalEndpoints)
 
 	err := initSettings(store.SettingsService, flags)
 	if err != nil {
-		log.Fatal(err)
+		logrus.Fatal(err)
 	}
 
 	err = initDockerHub(store.DockerHubService)
 	if err != nil {
-		log.Fatal(err)
+		logrus.Fatal(err)
 	}
 
+	initRecordingRetention(watcher, store.RecordingService, store.SettingsService, fileService)
+
+	initBackupSchedule(watcher, store.SettingsService, store.BackupStatusService, backupService)
+
 	applicationStatus := initStatus(authorizeEndpointMgmt, flags)
 
 	if flags.Endpoint != "" {
 		endpoints, err := store.EndpointService.Endpoints()
 		if err != nil {
-			log.Fatal(err)
+			logrus.Fatal(err)
 		}
 		if len(endpoints) == 0 {
 			endpoint := &api.Endpoint{
@@ -189,10 +272,10 @@ func New(flags * api.HttpCliFlags) *Server{
 			}
 			err = store.EndpointService.CreateEndpoint(endpoint)
 			if err != nil {
-				log.Fatal(err)
+				logrus.Fatal(err)
 			}
 		} else {
-			log.Println("Instance already has defined endpoints. Skipping the endpoint defined via CLI.")
+			logrus.Info("Instance already has defined endpoints. Skipping the endpoint defined via CLI.")
 		}
 	}
 
@@ -200,11 +283,11 @@ func New(flags * api.HttpCliFlags) *Server{
 	if flags.AdminPasswordFile != "" {
 		content, err := fileService.GetFileContent(flags.AdminPasswordFile)
 		if err != nil {
-			log.Fatal(err)
+			logrus.Fatal(err)
 		}
 		adminPasswordHash, err = cryptoService.Hash(content)
 		if err != nil {
-			log.Fatal(err)
+			logrus.Fatal(err)
 		}
 	} else if flags.AdminPassword != "" {
 		adminPasswordHash = flags.AdminPassword
@@ -213,11 +296,11 @@ func New(flags * api.HttpCliFlags) *Server{
 	if adminPasswordHash != "" {
 		users, err := store.UserService.UsersByRole(api.AdministratorRole)
 		if err != nil {
-			log.Fatal(err)
+			logrus.Fatal(err)
 		}
 
 		if len(users) == 0 {
-			log.Printf("Creating admin user with password hash %s", adminPasswordHash)
+			logrus.Infof("Creating admin user with password hash %s", adminPasswordHash)
 			user := &api.User{
 				Username: "admin",
 				Role:     api.AdministratorRole,
@@ -225,16 +308,17 @@ func New(flags * api.HttpCliFlags) *Server{
 			}
 			err := store.UserService.CreateUser(user)
 			if err != nil {
-				log.Fatal(err)
+				logrus.Fatal(err)
 			}
 		} else {
-			log.Println("Instance already has an administrator user defined. Skipping admin password related flags.")
+			logrus.Info("Instance already has an administrator user defined. Skipping admin password related flags.")
 		}
 	}
 
 	return &Server{
 		Status:                 applicationStatus,
 		BindAddress:            flags.Addr,
+		MetricsAddress:         flags.MetricsAddr,
 		AssetsPath:             flags.Assets,
 		AuthDisabled:           flags.NoAuth,
 		EndpointManagement:     authorizeEndpointMgmt,
@@ -247,13 +331,25 @@ func New(flags * api.HttpCliFlags) *Server{
 		RegistryService:        store.RegistryService,
 		DockerHubService:       store.DockerHubService,
 		StackService:           store.StackService,
+		RecordingService:       store.RecordingService,
+		TemplateService:        store.TemplateService,
 		StackManager:           stackManager,
+		GitService:             initGitService(),
+		DataStore:              store,
 		CryptoService:          cryptoService,
+		PKIService:             pkiService,
+		BackupService:          backupService,
+		BackupStatusService:    store.BackupStatusService,
+		Watcher:                watcher,
 		JWTService:             jwtService,
 		FileService:            fileService,
 		SSL:                    flags.SSL,
 		SSLCert:                flags.SSLCert,
 		SSLKey:                 flags.SSLKey,
+		ReadTimeout:            initTimeout("read timeout", flags.ReadTimeout),
+		WriteTimeout:           initTimeout("write timeout", flags.WriteTimeout),
+		IdleTimeout:            initTimeout("idle timeout", flags.IdleTimeout),
+		ShutdownTimeout:        initTimeout("shutdown timeout", flags.ShutdownTimeout),
 	}
-	//log.Printf("Starting Cloudware %s on %s", api.APIVersion, flags.Addr)
+	//logrus.Infof("Starting Cloudware %s on %s", api.APIVersion, flags.Addr)
 }
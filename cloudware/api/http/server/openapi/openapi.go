@@ -0,0 +1,202 @@
+// Package openapi describes the REST API with an OpenAPI 3 document and validates
+// the requests against it.
+package openapi
+
+import (
+	"net/http"
+	"strings"
+)
+
+// Version is the version of the OpenAPI specification the documents conform to.
+const Version = "3.0.3"
+
+type (
+	// Document is the root of an OpenAPI document.
+	Document struct {
+		OpenAPI    string               `json:"openapi"`
+		Info       Info                 `json:"info"`
+		Servers    []Server             `json:"servers,omitempty"`
+		Paths      map[string]*PathItem `json:"paths"`
+		Components *Components          `json:"components,omitempty"`
+	}
+
+	// Info describes the API.
+	Info struct {
+		Title       string `json:"title"`
+		Description string `json:"description,omitempty"`
+		Version     string `json:"version"`
+	}
+
+	// Server is a base URL of the API.
+	Server struct {
+		URL string `json:"url"`
+	}
+
+	// PathItem describes the operations available on a path. When Prefix is set, the
+	// last parameter of the path matches the rest of the path, slashes included.
+	PathItem struct {
+		Prefix bool       `json:"x-path-prefix,omitempty"`
+		Get    *Operation `json:"get,omitempty"`
+		Put    *Operation `json:"put,omitempty"`
+		Post   *Operation `json:"post,omitempty"`
+		Delete *Operation `json:"delete,omitempty"`
+	}
+
+	// Operation describes a single operation on a path. Access is the access level
+	// required by the operation, as enforced by the request bouncer.
+	Operation struct {
+		OperationID string                `json:"operationId"`
+		Summary     string                `json:"summary"`
+		Tags        []string              `json:"tags,omitempty"`
+		Access      string                `json:"x-access,omitempty"`
+		Parameters  []*Parameter          `json:"parameters,omitempty"`
+		RequestBody *RequestBody          `json:"requestBody,omitempty"`
+		Responses   map[string]*Response  `json:"responses"`
+		Security    []SecurityRequirement `json:"security,omitempty"`
+	}
+
+	// Parameter describes a parameter of an operation, located in the path or in the query.
+	Parameter struct {
+		Name        string  `json:"name"`
+		In          string  `json:"in"`
+		Description string  `json:"description,omitempty"`
+		Required    bool    `json:"required,omitempty"`
+		Schema      *Schema `json:"schema"`
+	}
+
+	// RequestBody describes the body of a request, by media type. MaxSize is the maximum size of
+	// a JSON body read by the validation, DefaultMaxBodySize when it is 0.
+	RequestBody struct {
+		Required bool                  `json:"required,omitempty"`
+		Content  map[string]*MediaType `json:"content"`
+		MaxSize  int64                 `json:"-"`
+	}
+
+	// MediaType describes the content of a body for a media type.
+	MediaType struct {
+		Schema *Schema `json:"schema,omitempty"`
+	}
+
+	// Response describes a response of an operation.
+	Response struct {
+		Description string                `json:"description"`
+		Headers     map[string]*Header    `json:"headers,omitempty"`
+		Content     map[string]*MediaType `json:"content,omitempty"`
+	}
+
+	// Header describes a header of a response.
+	Header struct {
+		Description string  `json:"description,omitempty"`
+		Schema      *Schema `json:"schema"`
+	}
+
+	// Components holds the reusable objects of the document.
+	Components struct {
+		Schemas         map[string]*Schema         `json:"schemas,omitempty"`
+		SecuritySchemes map[string]*SecurityScheme `json:"securitySchemes,omitempty"`
+	}
+
+	// SecurityScheme describes how the requests are authenticated.
+	SecurityScheme struct {
+		Type         string `json:"type"`
+		Scheme       string `json:"scheme,omitempty"`
+		BearerFormat string `json:"bearerFormat,omitempty"`
+	}
+
+	// SecurityRequirement lists the security schemes required by an operation.
+	SecurityRequirement map[string][]string
+)
+
+// Parameter locations, the header parameters are only documented.
+const (
+	InPath   = "path"
+	InQuery  = "query"
+	InHeader = "header"
+)
+
+// Operations returns the operations of the path item indexed by HTTP method.
+func (item *PathItem) Operations() map[string]*Operation {
+	operations := make(map[string]*Operation)
+	for method, operation := range map[string]*Operation{
+		http.MethodGet:    item.Get,
+		http.MethodPut:    item.Put,
+		http.MethodPost:   item.Post,
+		http.MethodDelete: item.Delete,
+	} {
+		if operation != nil {
+			operations[method] = operation
+		}
+	}
+	return operations
+}
+
+// Operation returns the operation of the path item for an HTTP method, or nil when there is none.
+func (item *PathItem) Operation(method string) *Operation {
+	return item.Operations()[method]
+}
+
+// FindOperation returns the operation matching the method and the path of a request along
+// with the values of the path parameters. The returned operation is nil when no path
+// matches, or when the path matches but the method does not.
+func (document *Document) FindOperation(method, path string) (*Operation, map[string]string) {
+	item, params := document.FindPath(path)
+	if item == nil {
+		return nil, nil
+	}
+	return item.Operation(method), params
+}
+
+// FindPath returns the path item matching the path of a request along with the values of
+// the path parameters, or nil when no path matches. The paths without parameters take
+// precedence, so that /users/admin/check is not matched by /users/{id}.
+func (document *Document) FindPath(path string) (*PathItem, map[string]string) {
+	segments := splitPath(path)
+
+	var (
+		best       *PathItem
+		bestParams map[string]string
+		bestScore  = -1
+	)
+	for template, item := range document.Paths {
+		params, score, ok := matchTemplate(splitPath(template), segments, item.Prefix)
+		if ok && score > bestScore {
+			best, bestParams, bestScore = item, params, score
+		}
+	}
+
+	return best, bestParams
+}
+
+func splitPath(path string) []string {
+	return strings.Split(strings.Trim(path, "/"), "/")
+}
+
+// matchTemplate matches the segments of a path against the segments of a template. The
+// score is the number of literal segments matched, the most specific template wins.
+func matchTemplate(template, segments []string, prefix bool) (map[string]string, int, bool) {
+	if len(segments) < len(template) || (!prefix && len(segments) != len(template)) {
+		return nil, 0, false
+	}
+
+	params := make(map[string]string)
+	score := 0
+	for idx, part := range template {
+		if strings.HasPrefix(part, "{") && strings.HasSuffix(part, "}") {
+			name := part[1 : len(part)-1]
+			value := segments[idx]
+			if prefix && idx == len(template)-1 {
+				value = strings.Join(segments[idx:], "/")
+			}
+			if value == "" {
+				return nil, 0, false
+			}
+			params[name] = value
+			continue
+		}
+		if part != segments[idx] {
+			return nil, 0, false
+		}
+		score++
+	}
+	return params, score, true
+}
@@ -0,0 +1,222 @@
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Schema types.
+const (
+	TypeObject  = "object"
+	TypeArray   = "array"
+	TypeString  = "string"
+	TypeInteger = "integer"
+	TypeNumber  = "number"
+	TypeBoolean = "boolean"
+)
+
+// Schema describes a value, it supports the subset of the JSON schema used by the API.
+type Schema struct {
+	Ref         string             `json:"$ref,omitempty"`
+	Type        string             `json:"type,omitempty"`
+	Format      string             `json:"format,omitempty"`
+	Description string             `json:"description,omitempty"`
+	Properties  map[string]*Schema `json:"properties,omitempty"`
+	Required    []string           `json:"required,omitempty"`
+	Items       *Schema            `json:"items,omitempty"`
+	Enum        []interface{}      `json:"enum,omitempty"`
+	Minimum     *float64           `json:"minimum,omitempty"`
+	MinLength   *int               `json:"minLength,omitempty"`
+	Nullable    bool               `json:"nullable,omitempty"`
+}
+
+// FieldError describes why a field of a request does not match the schema.
+type FieldError struct {
+	Field   string
+	Message string
+}
+
+// Error returns the description of the field error.
+func (err FieldError) Error() string {
+	if err.Field == "" {
+		return err.Message
+	}
+	return err.Field + ": " + err.Message
+}
+
+// Ref returns a schema referencing a schema of the components.
+func Ref(name string) *Schema {
+	return &Schema{Ref: "#/components/schemas/" + name}
+}
+
+// Min returns a pointer to a minimum value.
+func Min(value float64) *float64 {
+	return &value
+}
+
+// MinLen returns a pointer to a minimum length.
+func MinLen(length int) *int {
+	return &length
+}
+
+// resolve follows the reference of a schema to the schemas of the components.
+func (document *Document) resolve(schema *Schema) *Schema {
+	for schema != nil && schema.Ref != "" {
+		if document.Components == nil {
+			return nil
+		}
+		schema = document.Components.Schemas[strings.TrimPrefix(schema.Ref, "#/components/schemas/")]
+	}
+	return schema
+}
+
+// validateValue validates a value decoded from JSON, with numbers decoded as json.Number.
+func (document *Document) validateValue(schema *Schema, value interface{}, field string) []FieldError {
+	schema = document.resolve(schema)
+	if schema == nil {
+		return nil
+	}
+
+	if value == nil {
+		if schema.Nullable || schema.Type == TypeObject || schema.Type == TypeArray {
+			return nil
+		}
+		return []FieldError{{field, "must not be null"}}
+	}
+
+	var errors []FieldError
+	switch schema.Type {
+	case TypeObject:
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return []FieldError{{field, "must be an object"}}
+		}
+		for _, name := range schema.Required {
+			property, ok := object[name]
+			if !ok || isZero(property) {
+				errors = append(errors, FieldError{joinField(field, name), "is required"})
+			}
+		}
+		names := make([]string, 0, len(object))
+		for name := range object {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if property, ok := schema.Properties[name]; ok {
+				errors = append(errors, document.validateValue(property, object[name], joinField(field, name))...)
+			}
+		}
+		return errors
+	case TypeArray:
+		array, ok := value.([]interface{})
+		if !ok {
+			return []FieldError{{field, "must be an array"}}
+		}
+		for idx, item := range array {
+			errors = append(errors, document.validateValue(schema.Items, item, fmt.Sprintf("%s[%d]", field, idx))...)
+		}
+		return errors
+	case TypeString:
+		s, ok := value.(string)
+		if !ok {
+			return []FieldError{{field, "must be a string"}}
+		}
+		if schema.MinLength != nil && len(s) < *schema.MinLength {
+			errors = append(errors, FieldError{field, fmt.Sprintf("must be at least %d characters long", *schema.MinLength)})
+		}
+	case TypeInteger, TypeNumber:
+		number, ok := value.(json.Number)
+		if !ok {
+			return []FieldError{{field, "must be a number"}}
+		}
+		if schema.Type == TypeInteger {
+			if _, err := number.Int64(); err != nil {
+				return []FieldError{{field, "must be an integer"}}
+			}
+		}
+		n, err := number.Float64()
+		if err != nil {
+			return []FieldError{{field, "must be a number"}}
+		}
+		if schema.Minimum != nil && n < *schema.Minimum {
+			errors = append(errors, FieldError{field, fmt.Sprintf("must be greater than or equal to %v", *schema.Minimum)})
+		}
+	case TypeBoolean:
+		if _, ok := value.(bool); !ok {
+			return []FieldError{{field, "must be a boolean"}}
+		}
+	}
+
+	if len(schema.Enum) > 0 && !inEnum(schema.Enum, value) {
+		errors = append(errors, FieldError{field, fmt.Sprintf("must be one of %s", formatEnum(schema.Enum))})
+	}
+	return errors
+}
+
+// validateParameter validates the raw value of a path or query parameter.
+func (document *Document) validateParameter(schema *Schema, raw, field string) []FieldError {
+	schema = document.resolve(schema)
+	if schema == nil {
+		return nil
+	}
+
+	var value interface{} = raw
+	switch schema.Type {
+	case TypeInteger, TypeNumber:
+		if _, err := strconv.ParseFloat(raw, 64); err != nil {
+			return []FieldError{{field, "must be a number"}}
+		}
+		value = json.Number(raw)
+	case TypeBoolean:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return []FieldError{{field, "must be a boolean"}}
+		}
+		value = b
+	}
+	return document.validateValue(schema, value, field)
+}
+
+func joinField(parent, name string) string {
+	if parent == "" {
+		return name
+	}
+	return parent + "." + name
+}
+
+// isZero reports whether a required value is missing, as the API does not accept
+// empty strings or zero identifiers for the required fields.
+func isZero(value interface{}) bool {
+	switch v := value.(type) {
+	case nil:
+		return true
+	case string:
+		return v == ""
+	case json.Number:
+		return v.String() == "0"
+	case []interface{}:
+		return len(v) == 0
+	}
+	return false
+}
+
+func inEnum(enum []interface{}, value interface{}) bool {
+	for _, allowed := range enum {
+		if fmt.Sprint(allowed) == fmt.Sprint(value) {
+			return true
+		}
+	}
+	return false
+}
+
+func formatEnum(enum []interface{}) string {
+	values := make([]string, len(enum))
+	for idx, value := range enum {
+		values[idx] = fmt.Sprint(value)
+	}
+	return strings.Join(values, ", ")
+}
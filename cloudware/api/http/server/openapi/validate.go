@@ -0,0 +1,105 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"mime"
+	"net/http"
+)
+
+const mediaTypeJSON = "application/json"
+
+// DefaultMaxBodySize is the maximum size of the JSON bodies read by the validation, unless
+// the operation sets another one.
+const DefaultMaxBodySize = 1 << 20
+
+var (
+	// ErrInvalidBody defines an error raised when the body of a request cannot be decoded as JSON.
+	ErrInvalidBody = errors.New("the body is not valid JSON")
+	// ErrBodyTooLarge defines an error raised when the body of a request exceeds the maximum size of its operation.
+	ErrBodyTooLarge = errors.New("the body is too large")
+)
+
+// ValidateRequest validates the parameters and the JSON body of a request against an operation,
+// params are the values of the path parameters. The body is only read when the operation
+// describes a JSON body, up to the maximum size of the operation, it is then restored so that
+// the handler can read it again. The body is validated as JSON unless its media type is another
+// one accepted by the operation, such as a multipart form. An error is returned when the body
+// is too large or is not valid JSON.
+func (document *Document) ValidateRequest(w http.ResponseWriter, operation *Operation, params map[string]string, r *http.Request) ([]FieldError, error) {
+	var fieldErrors []FieldError
+
+	query := r.URL.Query()
+	for _, parameter := range operation.Parameters {
+		switch parameter.In {
+		case InPath:
+			fieldErrors = append(fieldErrors, document.validateParameter(parameter.Schema, params[parameter.Name], parameter.Name)...)
+		case InQuery:
+			values, ok := query[parameter.Name]
+			if !ok || len(values) == 0 || values[0] == "" {
+				if parameter.Required {
+					fieldErrors = append(fieldErrors, FieldError{parameter.Name, "is required"})
+				}
+				continue
+			}
+			fieldErrors = append(fieldErrors, document.validateParameter(parameter.Schema, values[0], parameter.Name)...)
+		}
+	}
+
+	schema := jsonBodySchema(operation, r)
+	if schema == nil {
+		return fieldErrors, nil
+	}
+
+	maxSize := operation.RequestBody.MaxSize
+	if maxSize == 0 {
+		maxSize = DefaultMaxBodySize
+	}
+	data, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxSize))
+	r.Body.Close()
+	r.Body = ioutil.NopCloser(bytes.NewReader(data))
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		return nil, ErrBodyTooLarge
+	} else if err != nil {
+		return nil, err
+	}
+
+	if len(bytes.TrimSpace(data)) == 0 {
+		if operation.RequestBody.Required {
+			fieldErrors = append(fieldErrors, FieldError{"", "the body is required"})
+		}
+		return fieldErrors, nil
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var body interface{}
+	if err := decoder.Decode(&body); err != nil {
+		return nil, ErrInvalidBody
+	}
+
+	return append(fieldErrors, document.validateValue(schema, body, "")...), nil
+}
+
+// jsonBodySchema returns the schema of the JSON body of a request, or nil when the body
+// is not validated.
+func jsonBodySchema(operation *Operation, r *http.Request) *Schema {
+	if operation.RequestBody == nil {
+		return nil
+	}
+	content, ok := operation.RequestBody.Content[mediaTypeJSON]
+	if !ok || content.Schema == nil {
+		return nil
+	}
+
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err == nil && mediaType != mediaTypeJSON {
+		if _, ok := operation.RequestBody.Content[mediaType]; ok {
+			return nil
+		}
+	}
+	return content.Schema
+}
@@ -34,13 +34,17 @@ func applyResourceAccessControlFromLabel(labelsObject, resourceObject map[string
 // Returns the original object and authorized access (true) when no resource control is found for the specified
 // resource identifier.
 // Returns the original object and denied access (false) when a resource control is associated to the resource
-// and the user cannot access the resource.
+// and the user cannot access the resource, or when the resource control cannot be retrieved.
 func applyResourceAccessControl(resourceObject map[string]interface{}, resourceIdentifier string,
 	context *restrictedOperationContext) (map[string]interface{}, bool) {
 
 	authorizedAccess := true
 
-	resourceControl := getResourceControlByResourceID(resourceIdentifier, context.resourceControls)
+	resourceControl, err := context.resourceControl(resourceIdentifier)
+	if err != nil {
+		return resourceObject, false
+	}
+
 	if resourceControl != nil {
 		if context.isAdmin || canUserAccessResource(context.userID, context.userTeamIDs, resourceControl) {
 			resourceObject = decorateObject(resourceObject, resourceControl)
@@ -57,11 +61,11 @@ func applyResourceAccessControl(resourceObject map[string]interface{}, resourceI
 // decorated. If no identifier can be found in the labels or no resource control is associated to the identifier, the resource
 // object will not be changed.
 func decorateResourceWithAccessControlFromLabel(labelsObject, resourceObject map[string]interface{}, labelIdentifier string,
-	resourceControls []api.ResourceControl) map[string]interface{} {
+	context *restrictedOperationContext) map[string]interface{} {
 
 	if labelsObject != nil && labelsObject[labelIdentifier] != nil {
 		resourceIdentifier := labelsObject[labelIdentifier].(string)
-		resourceObject = decorateResourceWithAccessControl(resourceObject, resourceIdentifier, resourceControls)
+		resourceObject = decorateResourceWithAccessControl(resourceObject, resourceIdentifier, context)
 	}
 
 	return resourceObject
@@ -70,10 +74,10 @@ func decorateResourceWithAccessControlFromLabel(labelsObject, resourceObject map
 // decorateResourceWithAccessControl will check if a resource control is associated to the specified resource identifier.
 // If a resource control is found, the resource object will be decorated, otherwise it will not be changed.
 func decorateResourceWithAccessControl(resourceObject map[string]interface{}, resourceIdentifier string,
-	resourceControls []api.ResourceControl) map[string]interface{} {
+	context *restrictedOperationContext) map[string]interface{} {
 
-	resourceControl := getResourceControlByResourceID(resourceIdentifier, resourceControls)
-	if resourceControl != nil {
+	resourceControl, err := context.resourceControl(resourceIdentifier)
+	if err == nil && resourceControl != nil {
 		return decorateObject(resourceObject, resourceControl)
 	}
 	return resourceObject
@@ -104,20 +108,6 @@ func decorateObject(object map[string]interface{}, resourceControl *api.Resource
 	return object
 }
 
-func getResourceControlByResourceID(resourceID string, resourceControls []api.ResourceControl) *api.ResourceControl {
-	for _, resourceControl := range resourceControls {
-		if resourceID == resourceControl.ResourceID {
-			return &resourceControl
-		}
-		for _, subResourceID := range resourceControl.SubResourceIDs {
-			if resourceID == subResourceID {
-				return &resourceControl
-			}
-		}
-	}
-	return nil
-}
-
 // CanAccessStack checks if a user can access a stack
 func CanAccessStack(stack *api.Stack, resourceControl *api.ResourceControl, userID api.UserID, memberships []api.TeamMembership) bool {
 	userTeamIDs := make([]api.TeamID, 0)
@@ -132,27 +122,3 @@ func CanAccessStack(stack *api.Stack, resourceControl *api.ResourceControl, user
 	return false
 }
 
-// FilterStacks filters stacks based on user role and resource controls.
-func FilterStacks(stacks []api.Stack, resourceControls []api.ResourceControl, isAdmin bool,
-	userID api.UserID, memberships []api.TeamMembership) []ExtendedStack {
-
-	filteredStacks := make([]ExtendedStack, 0)
-
-	userTeamIDs := make([]api.TeamID, 0)
-	for _, membership := range memberships {
-		userTeamIDs = append(userTeamIDs, membership.TeamID)
-	}
-
-	for _, stack := range stacks {
-		extendedStack := ExtendedStack{stack, api.ResourceControl{}}
-		resourceControl := getResourceControlByResourceID(stack.Name, resourceControls)
-		if resourceControl == nil {
-			filteredStacks = append(filteredStacks, extendedStack)
-		} else if resourceControl != nil && (isAdmin || canUserAccessResource(userID, userTeamIDs, resourceControl)) {
-			extendedStack.ResourceControl = *resourceControl
-			filteredStacks = append(filteredStacks, extendedStack)
-		}
-	}
-
-	return filteredStacks
-}
@@ -0,0 +1,67 @@
+package proxy
+
+import (
+	"net/http"
+	"net/url"
+
+	"cloudware/cloudware/api"
+	"cloudware/cloudware/api/crypto"
+)
+
+// Client executes requests against the Docker API of an endpoint.
+// Requests go through the same transport as the reverse proxies so that
+// resource control filtering is applied based on the token data stored
+// in the request context.
+type Client struct {
+	transport *proxyTransport
+	scheme    string
+	host      string
+}
+
+func (factory *proxyFactory) newClient(endpoint *api.Endpoint) (*Client, error) {
+	endpointURL, err := url.Parse(endpoint.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	transport := &proxyTransport{
+		ResourceControlService: factory.ResourceControlService,
+		TeamMembershipService:  factory.TeamMembershipService,
+		SettingsService:        factory.SettingsService,
+		RegistryService:        factory.RegistryService,
+		DockerHubService:       factory.DockerHubService,
+	}
+
+	client := &Client{
+		transport: transport,
+		scheme:    "http",
+		host:      endpointURL.Host,
+	}
+
+	if endpointURL.Scheme == "tcp" {
+		transport.dockerTransport = newHTTPTransport()
+		if endpoint.TLSConfig.TLS {
+			config, err := crypto.CreateTLSConfiguration(&endpoint.TLSConfig)
+			if err != nil {
+				return nil, err
+			}
+			transport.dockerTransport.TLSClientConfig = config
+			client.scheme = "https"
+		}
+	} else {
+		// Assume unix:// scheme
+		transport.dockerTransport = newSocketTransport(endpointURL.Path)
+		client.host = "unixsocket"
+	}
+
+	return client, nil
+}
+
+// Do sends a request to the Docker API. The URL of the request must only contain
+// the path and the query of the Docker API operation, e.g. /containers/json?all=1.
+func (client *Client) Do(request *http.Request) (*http.Response, error) {
+	request.URL.Scheme = client.scheme
+	request.URL.Host = client.host
+	request.Host = client.host
+	return client.transport.proxyDockerRequest(request)
+}
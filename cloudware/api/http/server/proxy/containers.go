@@ -26,7 +26,7 @@ func containerListOperation(request *http.Request, response *http.Response, exec
 	}
 
 	if executor.operationContext.isAdmin {
-		responseArray, err = decorateContainerList(responseArray, executor.operationContext.resourceControls)
+		responseArray, err = decorateContainerList(responseArray, executor.operationContext)
 	} else {
 		responseArray, err = filterContainerList(responseArray, executor.operationContext)
 	}
@@ -102,7 +102,7 @@ func extractContainerLabelsFromContainerListObject(responseObject map[string]int
 // decorateContainerList loops through all containers and decorates any container with an existing resource control.
 // Resource controls checks are based on: resource identifier, service identifier (from label), stack identifier (from label).
 // Container object schema reference: https://docs.docker.com/engine/api/v1.28/#operation/ContainerList
-func decorateContainerList(containerData []interface{}, resourceControls []api.ResourceControl) ([]interface{}, error) {
+func decorateContainerList(containerData []interface{}, context *restrictedOperationContext) ([]interface{}, error) {
 	decoratedContainerData := make([]interface{}, 0)
 
 	for _, container := range containerData {
@@ -113,11 +113,11 @@ func decorateContainerList(containerData []interface{}, resourceControls []api.R
 		}
 
 		containerID := containerObject[containerIdentifier].(string)
-		containerObject = decorateResourceWithAccessControl(containerObject, containerID, resourceControls)
+		containerObject = decorateResourceWithAccessControl(containerObject, containerID, context)
 
 		containerLabels := extractContainerLabelsFromContainerListObject(containerObject)
-		containerObject = decorateResourceWithAccessControlFromLabel(containerLabels, containerObject, containerLabelForServiceIdentifier, resourceControls)
-		containerObject = decorateResourceWithAccessControlFromLabel(containerLabels, containerObject, containerLabelForStackIdentifier, resourceControls)
+		containerObject = decorateResourceWithAccessControlFromLabel(containerLabels, containerObject, containerLabelForServiceIdentifier, context)
+		containerObject = decorateResourceWithAccessControlFromLabel(containerLabels, containerObject, containerLabelForStackIdentifier, context)
 
 		decoratedContainerData = append(decoratedContainerData, containerObject)
 	}
@@ -15,6 +15,8 @@ type proxyFactory struct {
 	ResourceControlService api.ResourceControlService
 	TeamMembershipService  api.TeamMembershipService
 	SettingsService        api.SettingsService
+	RegistryService        api.RegistryService
+	DockerHubService       api.DockerHubService
 }
 
 func (factory *proxyFactory) newHTTPProxy(u *url.URL) http.Handler {
@@ -40,6 +42,8 @@ func (factory *proxyFactory) newSocketProxy(path string) http.Handler {
 		ResourceControlService: factory.ResourceControlService,
 		TeamMembershipService:  factory.TeamMembershipService,
 		SettingsService:        factory.SettingsService,
+		RegistryService:        factory.RegistryService,
+		DockerHubService:       factory.DockerHubService,
 		dockerTransport:        newSocketTransport(path),
 	}
 	proxy.Transport = transport
@@ -52,6 +56,8 @@ func (factory *proxyFactory) createReverseProxy(u *url.URL) *httputil.ReversePro
 		ResourceControlService: factory.ResourceControlService,
 		TeamMembershipService:  factory.TeamMembershipService,
 		SettingsService:        factory.SettingsService,
+		RegistryService:        factory.RegistryService,
+		DockerHubService:       factory.DockerHubService,
 		dockerTransport:        newHTTPTransport(),
 	}
 	proxy.Transport = transport
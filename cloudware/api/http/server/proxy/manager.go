@@ -1,32 +1,44 @@
 package proxy
 
 import (
+	"context"
+	"fmt"
 	"net/http"
 	"net/url"
+	"strconv"
+	"time"
 
 	"github.com/orcaman/concurrent-map"
 	"cloudware/cloudware/api"
 )
 
+// pingTimeout is the maximum duration of a health check of an endpoint.
+const pingTimeout = 10 * time.Second
+
 // Manager represents a service used to manage Docker proxies.
 type Manager struct {
 	proxyFactory *proxyFactory
 	proxies      cmap.ConcurrentMap
+	clients      cmap.ConcurrentMap
 }
 
 // NewManager initializes a new proxy Service
-func NewManager(resourceControlService api.ResourceControlService, teamMembershipService api.TeamMembershipService, settingsService api.SettingsService) *Manager {
+func NewManager(resourceControlService api.ResourceControlService, teamMembershipService api.TeamMembershipService, settingsService api.SettingsService, registryService api.RegistryService, dockerHubService api.DockerHubService) *Manager {
 	return &Manager{
 		proxies: cmap.New(),
+		clients: cmap.New(),
 		proxyFactory: &proxyFactory{
 			ResourceControlService: resourceControlService,
 			TeamMembershipService:  teamMembershipService,
 			SettingsService:        settingsService,
+			RegistryService:        registryService,
+			DockerHubService:       dockerHubService,
 		},
 	}
 }
 
-// CreateAndRegisterProxy creates a new HTTP reverse proxy and adds it to the registered proxies.
+// CreateAndRegisterProxy creates a new HTTP reverse proxy and adds it to the registered proxies,
+// the proxies are registered under the decimal representation of the endpoint identifier.
 // It can also be used to create a new HTTP reverse proxy and replace an already registered proxy.
 func (manager *Manager) CreateAndRegisterProxy(endpoint *api.Endpoint) (http.Handler, error) {
 	var proxy http.Handler
@@ -50,7 +62,8 @@ func (manager *Manager) CreateAndRegisterProxy(endpoint *api.Endpoint) (http.Han
 		proxy = manager.proxyFactory.newSocketProxy(endpointURL.Path)
 	}
 
-	manager.proxies.Set(string(endpoint.ID), proxy)
+	manager.proxies.Set(strconv.Itoa(int(endpoint.ID)), proxy)
+	manager.clients.Remove(strconv.Itoa(int(endpoint.ID)))
 	return proxy, nil
 }
 
@@ -66,4 +79,58 @@ func (manager *Manager) GetProxy(key string) http.Handler {
 // DeleteProxy deletes the proxy associated to a key
 func (manager *Manager) DeleteProxy(key string) {
 	manager.proxies.Remove(key)
+	manager.clients.Remove(key)
+}
+
+// DeleteProxies deletes all the registered proxies, they are recreated on their next use.
+func (manager *Manager) DeleteProxies() {
+	for _, key := range manager.proxies.Keys() {
+		manager.proxies.Remove(key)
+	}
+	for _, key := range manager.clients.Keys() {
+		manager.clients.Remove(key)
+	}
+}
+
+// GetClient returns the Docker API client associated to an endpoint, the client is created
+// and registered on first use.
+func (manager *Manager) GetClient(endpoint *api.Endpoint) (*Client, error) {
+	key := strconv.Itoa(int(endpoint.ID))
+	if client, ok := manager.clients.Get(key); ok {
+		return client.(*Client), nil
+	}
+
+	client, err := manager.proxyFactory.newClient(endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	manager.clients.Set(key, client)
+	return client, nil
+}
+
+// Ping checks that the Docker API of an endpoint answers.
+func (manager *Manager) Ping(endpoint *api.Endpoint) error {
+	client, err := manager.GetClient(endpoint)
+	if err != nil {
+		return err
+	}
+
+	request, err := http.NewRequest(http.MethodGet, "/_ping", nil)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), pingTimeout)
+	defer cancel()
+
+	response, err := client.Do(request.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status code: %d", response.StatusCode)
+	}
+	return nil
 }
@@ -0,0 +1,35 @@
+package proxy
+
+import (
+	"strconv"
+	"testing"
+
+	"cloudware/cloudware/api"
+)
+
+func TestManagerProxyKeys(t *testing.T) {
+	manager := NewManager(nil, nil, nil, nil, nil)
+
+	// Identifiers which are not valid code points must not share a proxy.
+	identifiers := []api.EndpointID{1, 0xD800, 0xD801}
+	for _, ID := range identifiers {
+		_, err := manager.CreateAndRegisterProxy(&api.Endpoint{ID: ID, URL: "tcp://10.0.0." + strconv.Itoa(int(ID%256)) + ":2375"})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, ID := range identifiers {
+		if manager.GetProxy(strconv.Itoa(int(ID))) == nil {
+			t.Errorf("expected the proxy of endpoint %d to be registered", ID)
+		}
+	}
+
+	manager.DeleteProxy(strconv.Itoa(0xD800))
+	if manager.GetProxy(strconv.Itoa(0xD800)) != nil {
+		t.Error("expected the proxy to be deleted")
+	}
+	if manager.GetProxy(strconv.Itoa(0xD801)) == nil {
+		t.Error("expected the proxies of the other endpoints to be kept")
+	}
+}
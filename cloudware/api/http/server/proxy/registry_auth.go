@@ -0,0 +1,190 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"cloudware/cloudware/api"
+	"cloudware/cloudware/api/http/server/security"
+)
+
+// registryAuthHeader is the header holding the credentials of the registry of an image.
+const registryAuthHeader = "X-Registry-Auth"
+
+// dockerHubDomains are the registry hosts of the images of the Docker Hub.
+var dockerHubDomains = map[string]bool{"docker.io": true, "index.docker.io": true, "registry-1.docker.io": true}
+
+// maxServiceSpecSize caps the size of the service specifications read to find their image.
+const maxServiceSpecSize = 1 << 20
+
+type (
+	// registryAuthConfig represents the credentials sent to the Docker API in the X-Registry-Auth header.
+	registryAuthConfig struct {
+		Username      string `json:"username,omitempty"`
+		Password      string `json:"password,omitempty"`
+		ServerAddress string `json:"serveraddress,omitempty"`
+		IdentityToken string `json:"identitytoken,omitempty"`
+		RegistryToken string `json:"registrytoken,omitempty"`
+	}
+
+	serviceSpec struct {
+		TaskTemplate struct {
+			ContainerSpec struct {
+				Image string `json:"Image"`
+			} `json:"ContainerSpec"`
+		} `json:"TaskTemplate"`
+	}
+)
+
+// RegistryAuthentication returns the X-Registry-Auth header used to pull an image, the credentials
+// are the ones of the registry hosting the image, or the ones of the Docker Hub. It returns an empty
+// string when no credentials are defined for the registry.
+func RegistryAuthentication(image string, dockerhub *api.DockerHub, registries []api.Registry) string {
+	var config *registryAuthConfig
+	if domain := imageRegistry(image); domain != "" && !dockerHubDomains[domain] {
+		for _, registry := range registries {
+			if registry.Authentication && registryHost(registry.URL) == domain {
+				config = &registryAuthConfig{Username: registry.Username, Password: registry.Password, ServerAddress: domain}
+				break
+			}
+		}
+	} else if dockerhub != nil && dockerhub.Authentication {
+		config = &registryAuthConfig{Username: dockerhub.Username, Password: dockerhub.Password}
+	}
+	if config == nil {
+		return ""
+	}
+
+	data, err := json.Marshal(config)
+	if err != nil {
+		return ""
+	}
+	return base64.URLEncoding.EncodeToString(data)
+}
+
+// imageRegistry returns the registry hosting an image, it is empty for the images of the Docker Hub.
+func imageRegistry(image string) string {
+	idx := strings.Index(image, "/")
+	if idx == -1 {
+		return ""
+	}
+	domain := image[:idx]
+	if strings.ContainsAny(domain, ".:") || domain == "localhost" {
+		return domain
+	}
+	return ""
+}
+
+// registryHost returns the host of the URL of a registry, the URLs are stored with or without scheme.
+func registryHost(registryURL string) string {
+	host := strings.TrimPrefix(strings.TrimPrefix(registryURL, "https://"), "http://")
+	return strings.SplitN(host, "/", 2)[0]
+}
+
+// hasRegistryCredentials reports whether an X-Registry-Auth header holds credentials, the
+// Docker client sends an empty configuration for the registries it has no credentials for.
+func hasRegistryCredentials(header string) bool {
+	if header == "" {
+		return false
+	}
+
+	data, err := base64.URLEncoding.DecodeString(header)
+	if err != nil {
+		data, err = base64.StdEncoding.DecodeString(header)
+	}
+	if err != nil {
+		return true
+	}
+
+	var config registryAuthConfig
+	if json.Unmarshal(data, &config) != nil {
+		return true
+	}
+	return config.Username != "" || config.IdentityToken != "" || config.RegistryToken != ""
+}
+
+// injectRegistryAuthentication sets the X-Registry-Auth header of a request pulling an image
+// with the credentials of its registry, among the registries the user is authorized to use.
+// The credentials sent by the client take precedence.
+func (p *proxyTransport) injectRegistryAuthentication(request *http.Request, image string) error {
+	if image == "" || hasRegistryCredentials(request.Header.Get(registryAuthHeader)) {
+		return nil
+	}
+
+	tokenData, err := security.RetrieveTokenData(request)
+	if err != nil {
+		return err
+	}
+
+	context := &security.RestrictedRequestContext{
+		IsAdmin: tokenData.Role == api.AdministratorRole,
+		UserID:  tokenData.ID,
+	}
+	if !context.IsAdmin {
+		context.UserMemberships, err = p.TeamMembershipService.TeamMembershipsByUserID(tokenData.ID)
+		if err != nil {
+			return err
+		}
+	}
+
+	registries, err := p.RegistryService.Registries()
+	if err != nil {
+		return err
+	}
+	authorizedRegistries, err := security.FilterRegistries(registries, context)
+	if err != nil {
+		return err
+	}
+
+	dockerhub, err := p.DockerHubService.DockerHub()
+	if err != nil && err != api.ErrDockerHubNotFound {
+		return err
+	}
+
+	if header := RegistryAuthentication(image, dockerhub, authorizedRegistries); header != "" {
+		request.Header.Set(registryAuthHeader, header)
+	}
+	return nil
+}
+
+// injectServiceRegistryAuthentication sets the X-Registry-Auth header of a request creating or
+// updating a service with the credentials of the registry of the image of the service.
+func (p *proxyTransport) injectServiceRegistryAuthentication(request *http.Request) error {
+	image, err := serviceImage(request)
+	if err != nil {
+		return err
+	}
+	return p.injectRegistryAuthentication(request, image)
+}
+
+// serviceImage returns the image of the specification of a service sent in the body of a
+// request, the body is restored to be sent to the Docker API.
+func serviceImage(request *http.Request) (string, error) {
+	if request.Body == nil {
+		return "", nil
+	}
+
+	body, err := ioutil.ReadAll(io.LimitReader(request.Body, maxServiceSpecSize+1))
+	if err != nil {
+		return "", err
+	}
+	request.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(body), request.Body), request.Body}
+
+	if len(body) > maxServiceSpecSize {
+		return "", nil
+	}
+	var spec serviceSpec
+	if json.Unmarshal(body, &spec) != nil {
+		// The Docker API reports the invalid specifications.
+		return "", nil
+	}
+	return spec.TaskTemplate.ContainerSpec.Image, nil
+}
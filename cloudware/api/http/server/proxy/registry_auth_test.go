@@ -0,0 +1,148 @@
+package proxy
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"cloudware/cloudware/api"
+	"cloudware/cloudware/api/http/server/security"
+	"cloudware/cloudware/bolt"
+)
+
+// dockerRequest is a request received by the stub Docker API.
+type dockerRequest struct {
+	registryAuth string
+	body         string
+}
+
+// newRegistryAuthProxy returns a handler proxying the requests of a regular user to a stub Docker API,
+// the user is authorized to use the "authorized.example.com" registry but not the "private.example.com"
+// one. The requests received by the stub are sent to the returned channel.
+func newRegistryAuthProxy(t *testing.T) (http.Handler, <-chan dockerRequest, func()) {
+	store, cleanup := bolt.NewTestStore(t)
+
+	user := &api.User{Username: "user", Role: api.StandardUserRole}
+	err := store.UserService.CreateUser(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, registry := range []*api.Registry{
+		{Name: "authorized", URL: "https://authorized.example.com", Authentication: true, Username: "alice", Password: "secret", AuthorizedUsers: []api.UserID{user.ID}},
+		{Name: "private", URL: "private.example.com", Authentication: true, Username: "admin", Password: "secret"},
+	} {
+		err = store.RegistryService.CreateRegistry(registry)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err = store.DockerHubService.StoreDockerHub(&api.DockerHub{Authentication: true, Username: "hub", Password: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	requests := make(chan dockerRequest, 1)
+	docker := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		requests <- dockerRequest{registryAuth: r.Header.Get(registryAuthHeader), body: string(body)}
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	transport := &proxyTransport{
+		ResourceControlService: store.ResourceControlService,
+		TeamMembershipService:  store.TeamMembershipService,
+		SettingsService:        store.SettingsService,
+		RegistryService:        store.RegistryService,
+		DockerHubService:       store.DockerHubService,
+		dockerTransport:        newHTTPTransport(),
+	}
+
+	jwtService := &tokenService{tokenData: &api.TokenData{ID: user.ID, Username: user.Username, Role: user.Role}}
+	bouncer := security.NewRequestBouncer(jwtService, store.TeamMembershipService, false)
+	handler := bouncer.AuthenticatedAccess(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.URL.Scheme = "http"
+		r.URL.Host = docker.Listener.Addr().String()
+		r.RequestURI = ""
+		response, err := transport.RoundTrip(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer response.Body.Close()
+		w.WriteHeader(response.StatusCode)
+		io.Copy(w, response.Body)
+	}))
+
+	return handler, requests, func() {
+		docker.Close()
+		cleanup()
+	}
+}
+
+func registryAuthUsername(t *testing.T, header string) string {
+	if header == "" {
+		return ""
+	}
+	data, err := base64.URLEncoding.DecodeString(header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var config registryAuthConfig
+	if err := json.Unmarshal(data, &config); err != nil {
+		t.Fatal(err)
+	}
+	return config.Username
+}
+
+func TestRegistryAuthenticationInjection(t *testing.T) {
+	handler, requests, cleanup := newRegistryAuthProxy(t)
+	defer cleanup()
+
+	clientAuth := base64.URLEncoding.EncodeToString([]byte(`{"username":"bob","password":"pass"}`))
+	emptyAuth := base64.URLEncoding.EncodeToString([]byte(`{}`))
+	serviceSpec := `{"Name":"web","TaskTemplate":{"ContainerSpec":{"Image":"authorized.example.com/team/web:1.0"}}}`
+
+	for _, test := range []struct {
+		path             string
+		body             string
+		registryAuth     string
+		expectedUsername string
+	}{
+		{"/images/create?fromImage=authorized.example.com/team/web&tag=1.0", "", "", "alice"},
+		{"/images/create?fromImage=private.example.com/web&tag=1.0", "", "", ""},
+		{"/images/create?fromImage=nginx&tag=latest", "", "", "hub"},
+		{"/images/create?fromImage=docker.io/library/nginx&tag=latest", "", "", "hub"},
+		{"/images/create?fromImage=unknown.example.com/web&tag=latest", "", "", ""},
+		{"/images/create?fromImage=authorized.example.com/team/web&tag=1.0", "", emptyAuth, "alice"},
+		{"/images/create?fromImage=authorized.example.com/team/web&tag=1.0", "", clientAuth, "bob"},
+		{"/services/create", serviceSpec, "", "alice"},
+		{"/services/web/update?version=1", serviceSpec, "", "alice"},
+		{"/services/create", `{"TaskTemplate":{"ContainerSpec":{"Image":"private.example.com/web"}}}`, "", ""},
+	} {
+		request := httptest.NewRequest(http.MethodPost, test.path, strings.NewReader(test.body))
+		request.Header.Set("Authorization", "Bearer token")
+		if test.registryAuth != "" {
+			request.Header.Set(registryAuthHeader, test.registryAuth)
+		}
+		recorder := httptest.NewRecorder()
+
+		handler.ServeHTTP(recorder, request)
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("%s: expected status %d, got %d: %s", test.path, http.StatusOK, recorder.Code, recorder.Body.String())
+		}
+
+		received := <-requests
+		if username := registryAuthUsername(t, received.registryAuth); username != test.expectedUsername {
+			t.Errorf("%s %s: expected the credentials of %q, got %q", test.path, test.body, test.expectedUsername, username)
+		}
+		if received.body != test.body {
+			t.Errorf("%s: expected the body %q to be forwarded, got %q", test.path, test.body, received.body)
+		}
+	}
+}
@@ -3,18 +3,45 @@ package proxy
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
+	"io"
 	"io/ioutil"
+	"net"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"cloudware/cloudware/api"
+	httperror "cloudware/cloudware/api/http/server/error"
 )
 
 const (
 	// ErrEmptyResponseBody defines an error raised when api excepts to parse the body of a HTTP response and there is nothing to parse
 	ErrEmptyResponseBody = api.Error("Empty response body")
+	// ErrDockerAPIError defines an error raised when the Docker API of an endpoint returned an error without message
+	ErrDockerAPIError = api.Error("The Docker API returned an error")
+	// ErrDockerUnreachable defines an error raised when the Docker API of an endpoint cannot be reached
+	ErrDockerUnreachable = api.Error("Unable to reach the Docker API of the endpoint")
+
+	// maxDockerErrorSize is the maximum size of the body of a Docker error response kept in the details.
+	maxDockerErrorSize = 1 << 20
 )
 
+func init() {
+	httperror.RegisterErrorCodes(map[api.Error]string{
+		ErrEmptyResponseBody:                   "empty_docker_response",
+		ErrDockerAPIError:                      "docker_error",
+		ErrDockerUnreachable:                   "docker_unreachable",
+		ErrDockerContainerIdentifierNotFound:   "docker_container_id_not_found",
+		ErrDockerServiceIdentifierNotFound:     "docker_service_id_not_found",
+		ErrDockerVolumeIdentifierNotFound:      "docker_volume_id_not_found",
+		ErrDockerNetworkIdentifierNotFound:     "docker_network_id_not_found",
+		ErrDockerSecretIdentifierNotFound:      "docker_secret_id_not_found",
+		ErrDockerConfigIdentifierNotFound:      "docker_config_id_not_found",
+		ErrDockerTaskServiceIdentifierNotFound: "docker_task_service_id_not_found",
+	})
+}
+
 func extractJSONField(jsonObject map[string]interface{}, key string) map[string]interface{} {
 	object := jsonObject[key]
 	if object != nil {
@@ -68,12 +95,51 @@ func getResponseBodyAsGenericJSON(response *http.Response) (interface{}, error)
 
 func writeAccessDeniedResponse() (*http.Response, error) {
 	response := &http.Response{}
-	err := rewriteResponse(response, api.ErrResourceAccessDenied, http.StatusForbidden)
+	err := rewriteAccessDeniedResponse(response)
 	return response, err
 }
 
 func rewriteAccessDeniedResponse(response *http.Response) error {
-	return rewriteResponse(response, api.ErrResourceAccessDenied, http.StatusForbidden)
+	return rewriteResponse(response, httperror.NewErrorResponse(api.ErrResourceAccessDenied, http.StatusForbidden, nil), http.StatusForbidden)
+}
+
+// rewriteDockerErrorResponse rewrites an error response of the Docker API as an error response
+// of the API. The message of the Docker error is kept and its body is returned in the details.
+func rewriteDockerErrorResponse(response *http.Response) error {
+	body, err := ioutil.ReadAll(io.LimitReader(response.Body, maxDockerErrorSize))
+	response.Body.Close()
+	if err != nil {
+		return err
+	}
+
+	errorResponse := httperror.NewErrorResponse(ErrDockerAPIError, response.StatusCode, nil)
+
+	var dockerError struct {
+		Message string `json:"message"`
+	}
+	text := strings.TrimSpace(string(body))
+	if json.Unmarshal(body, &dockerError) == nil {
+		errorResponse.Details = json.RawMessage(body)
+		text = dockerError.Message
+	} else if text != "" {
+		errorResponse.Details = text
+	}
+	if text != "" {
+		errorResponse.Err = text
+		errorResponse.Message = text
+	}
+
+	return rewriteResponse(response, errorResponse, response.StatusCode)
+}
+
+// writeProxyErrorResponse writes the error of a request that could not be proxied to the Docker API.
+func writeProxyErrorResponse(w http.ResponseWriter, r *http.Request, err error) {
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		httperror.WriteErrorDetailsResponse(w, r, ErrDockerUnreachable, http.StatusBadGateway, err.Error())
+		return
+	}
+	httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
 }
 
 func rewriteResponse(response *http.Response, newResponseData interface{}, statusCode int) error {
@@ -90,6 +156,7 @@ func rewriteResponse(response *http.Response, newResponseData interface{}, statu
 		response.Header = make(http.Header)
 	}
 	response.Header.Set("Content-Length", strconv.Itoa(len(jsonData)))
+	response.Header.Set("Content-Type", "application/json")
 
 	return nil
 }
@@ -0,0 +1,104 @@
+package proxy
+
+import (
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	httperror "cloudware/cloudware/api/http/server/error"
+	"cloudware/cloudware/api/http/server/security"
+)
+
+func TestRewriteDockerErrorResponse(t *testing.T) {
+	for _, test := range []struct {
+		body            string
+		expectedMessage string
+		expectedDetails string
+	}{
+		{`{"message":"No such container: web"}`, "No such container: web", `{"message":"No such container: web"}`},
+		{"page not found\n", "page not found", `"page not found"`},
+		{"", ErrDockerAPIError.Error(), ""},
+	} {
+		response := &http.Response{
+			StatusCode: http.StatusNotFound,
+			Header:     make(http.Header),
+			Body:       ioutil.NopCloser(strings.NewReader(test.body)),
+		}
+		if err := rewriteDockerErrorResponse(response); err != nil {
+			t.Fatal(err)
+		}
+
+		var body struct {
+			httperror.ErrorResponse
+			Details json.RawMessage `json:"details"`
+		}
+		if err := json.NewDecoder(response.Body).Decode(&body); err != nil {
+			t.Fatal(err)
+		}
+		if body.Code != "docker_error" || body.Status != http.StatusNotFound || response.StatusCode != http.StatusNotFound {
+			t.Errorf("unexpected code %q and status %d for %q", body.Code, body.Status, test.body)
+		}
+		if body.Message != test.expectedMessage || body.Err != test.expectedMessage {
+			t.Errorf("expected message %q, got %q", test.expectedMessage, body.Message)
+		}
+		if string(body.Details) != test.expectedDetails {
+			t.Errorf("expected details %s, got %s", test.expectedDetails, body.Details)
+		}
+		if response.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("unexpected content type %q", response.Header.Get("Content-Type"))
+		}
+	}
+}
+
+func TestRewriteOperationErrorResponse(t *testing.T) {
+	docker := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/services":
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, `{"message":"invalid filter 'unknown'"}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			io.WriteString(w, `{"message":"No such volume: web"}`)
+		}
+	}))
+	defer docker.Close()
+
+	// The administrator operations are not filtered, no store is needed.
+	transport := &proxyTransport{dockerTransport: newHTTPTransport()}
+	handler := security.NewRequestBouncer(nil, nil, true).AuthenticatedAccess(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.URL.Scheme = "http"
+		r.URL.Host = docker.Listener.Addr().String()
+		r.RequestURI = ""
+		response, err := transport.RoundTrip(r)
+		if err != nil {
+			t.Fatalf("%s: expected the error response of the Docker API, got %s", r.URL.Path, err)
+		}
+		defer response.Body.Close()
+		w.WriteHeader(response.StatusCode)
+		io.Copy(w, response.Body)
+	}))
+
+	for _, test := range []struct {
+		path            string
+		expectedStatus  int
+		expectedMessage string
+	}{
+		{"/services?filters=unknown", http.StatusBadRequest, "invalid filter 'unknown'"},
+		{"/volumes/web", http.StatusNotFound, "No such volume: web"},
+	} {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, test.path, nil))
+
+		var body httperror.ErrorResponse
+		if err := json.NewDecoder(recorder.Body).Decode(&body); err != nil {
+			t.Fatal(err)
+		}
+		if recorder.Code != test.expectedStatus || body.Code != "docker_error" || body.Message != test.expectedMessage {
+			t.Errorf("%s: unexpected status %d and error %+v", test.path, recorder.Code, body)
+		}
+	}
+}
@@ -9,7 +9,8 @@ import (
 
 // NewSingleHostReverseProxyWithHostHeader is based on NewSingleHostReverseProxy
 // from golang.org/src/net/http/httputil/reverseproxy.go and merely sets the Host
-// HTTP header, which NewSingleHostReverseProxy deliberately preserves.
+// HTTP header, which NewSingleHostReverseProxy deliberately preserves. The requests
+// which cannot be proxied are answered with an error response of the API.
 func newSingleHostReverseProxyWithHostHeader(target *url.URL) *httputil.ReverseProxy {
 	targetQuery := target.RawQuery
 	director := func(req *http.Request) {
@@ -27,7 +28,7 @@ func newSingleHostReverseProxyWithHostHeader(target *url.URL) *httputil.ReverseP
 			req.Header.Set("User-Agent", "")
 		}
 	}
-	return &httputil.ReverseProxy{Director: director}
+	return &httputil.ReverseProxy{Director: director, ErrorHandler: writeProxyErrorResponse}
 }
 
 // singleJoiningSlash from golang.org/src/net/http/httputil/reverseproxy.go
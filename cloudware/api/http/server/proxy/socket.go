@@ -20,11 +20,7 @@ func (proxy *socketProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 	res, err := proxy.Transport.proxyDockerRequest(r)
 	if err != nil {
-		code := http.StatusInternalServerError
-		if res != nil && res.StatusCode != 0 {
-			code = res.StatusCode
-		}
-		httperror.WriteErrorResponse(w, err, code, nil)
+		writeProxyErrorResponse(w, r, err)
 		return
 	}
 	defer res.Body.Close()
@@ -38,6 +34,6 @@ func (proxy *socketProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(res.StatusCode)
 
 	if _, err := io.Copy(w, res.Body); err != nil {
-		httperror.WriteErrorResponse(w, err, http.StatusInternalServerError, nil)
+		httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
 	}
 }
@@ -15,12 +15,14 @@ type (
 		ResourceControlService api.ResourceControlService
 		TeamMembershipService  api.TeamMembershipService
 		SettingsService        api.SettingsService
+		RegistryService        api.RegistryService
+		DockerHubService       api.DockerHubService
 	}
 	restrictedOperationContext struct {
-		isAdmin          bool
-		userID           api.UserID
-		userTeamIDs      []api.TeamID
-		resourceControls []api.ResourceControl
+		isAdmin                bool
+		userID                 api.UserID
+		userTeamIDs            []api.TeamID
+		resourceControlService api.ResourceControlService
 	}
 	operationExecutor struct {
 		operationContext *restrictedOperationContext
@@ -33,8 +35,18 @@ func (p *proxyTransport) RoundTrip(request *http.Request) (*http.Response, error
 	return p.proxyDockerRequest(request)
 }
 
+// executeDockerRequest sends a request to the Docker API, the error responses of the
+// Docker API are rewritten as error responses of the API.
 func (p *proxyTransport) executeDockerRequest(request *http.Request) (*http.Response, error) {
-	return p.dockerTransport.RoundTrip(request)
+	response, err := p.dockerTransport.RoundTrip(request)
+	if err != nil {
+		return response, err
+	}
+
+	if response.StatusCode >= http.StatusBadRequest && request.Method != http.MethodHead {
+		err = rewriteDockerErrorResponse(response)
+	}
+	return response, err
 }
 
 func (p *proxyTransport) proxyDockerRequest(request *http.Request) (*http.Response, error) {
@@ -45,6 +57,8 @@ func (p *proxyTransport) proxyDockerRequest(request *http.Request) (*http.Respon
 		return p.proxyConfigRequest(request)
 	case strings.HasPrefix(path, "/containers"):
 		return p.proxyContainerRequest(request)
+	case strings.HasPrefix(path, "/images"):
+		return p.proxyImageRequest(request)
 	case strings.HasPrefix(path, "/services"):
 		return p.proxyServiceRequest(request)
 	case strings.HasPrefix(path, "/volumes"):
@@ -113,9 +127,23 @@ func (p *proxyTransport) proxyContainerRequest(request *http.Request) (*http.Res
 	}
 }
 
+func (p *proxyTransport) proxyImageRequest(request *http.Request) (*http.Response, error) {
+	if request.URL.Path == "/images/create" {
+		err := p.injectRegistryAuthentication(request, request.URL.Query().Get("fromImage"))
+		if err != nil {
+			return nil, err
+		}
+	}
+	return p.executeDockerRequest(request)
+}
+
 func (p *proxyTransport) proxyServiceRequest(request *http.Request) (*http.Response, error) {
 	switch requestPath := request.URL.Path; requestPath {
 	case "/services/create":
+		err := p.injectServiceRegistryAuthentication(request)
+		if err != nil {
+			return nil, err
+		}
 		return p.executeDockerRequest(request)
 
 	case "/services":
@@ -126,6 +154,12 @@ func (p *proxyTransport) proxyServiceRequest(request *http.Request) (*http.Respo
 		if match, _ := path.Match("/services/*/*", requestPath); match {
 			// Handle /services/{id}/{action} requests
 			serviceID := path.Base(path.Dir(requestPath))
+			if path.Base(requestPath) == "update" {
+				err := p.injectServiceRegistryAuthentication(request)
+				if err != nil {
+					return nil, err
+				}
+			}
 			return p.restrictedOperation(request, serviceID)
 		} else if match, _ := path.Match("/services/*", requestPath); match {
 			// Handle /services/{id} requests
@@ -239,6 +273,13 @@ func (p *proxyTransport) restrictedOperation(request *http.Request, resourceID s
 
 	if tokenData.Role != api.AdministratorRole {
 
+		resourceControl, err := p.ResourceControlService.ResourceControlByResourceID(resourceID)
+		if err == api.ErrResourceControlNotFound {
+			return p.executeDockerRequest(request)
+		} else if err != nil {
+			return nil, err
+		}
+
 		teamMemberships, err := p.TeamMembershipService.TeamMembershipsByUserID(tokenData.ID)
 		if err != nil {
 			return nil, err
@@ -249,13 +290,7 @@ func (p *proxyTransport) restrictedOperation(request *http.Request, resourceID s
 			userTeamIDs = append(userTeamIDs, membership.TeamID)
 		}
 
-		resourceControls, err := p.ResourceControlService.ResourceControls()
-		if err != nil {
-			return nil, err
-		}
-
-		resourceControl := getResourceControlByResourceID(resourceID, resourceControls)
-		if resourceControl != nil && !canUserAccessResource(tokenData.ID, userTeamIDs, resourceControl) {
+		if !canUserAccessResource(tokenData.ID, userTeamIDs, resourceControl) {
 			return writeAccessDeniedResponse()
 		}
 	}
@@ -306,6 +341,11 @@ func (p *proxyTransport) executeRequestAndRewriteResponse(request *http.Request,
 		return response, err
 	}
 
+	// Error responses are returned as is, they do not contain any resource to decorate or filter
+	if response.StatusCode >= http.StatusBadRequest {
+		return response, nil
+	}
+
 	err = operation(request, response, executor)
 	return response, err
 }
@@ -332,15 +372,10 @@ func (p *proxyTransport) createOperationContext(request *http.Request) (*restric
 		return nil, err
 	}
 
-	resourceControls, err := p.ResourceControlService.ResourceControls()
-	if err != nil {
-		return nil, err
-	}
-
 	operationContext := &restrictedOperationContext{
-		isAdmin:          true,
-		userID:           tokenData.ID,
-		resourceControls: resourceControls,
+		isAdmin:                true,
+		userID:                 tokenData.ID,
+		resourceControlService: p.ResourceControlService,
 	}
 
 	if tokenData.Role != api.AdministratorRole {
@@ -360,3 +395,12 @@ func (p *proxyTransport) createOperationContext(request *http.Request) (*restric
 
 	return operationContext, nil
 }
+
+// resourceControl returns the resource control associated to a resource identifier, or nil if there is none.
+func (context *restrictedOperationContext) resourceControl(resourceID string) (*api.ResourceControl, error) {
+	resourceControl, err := context.resourceControlService.ResourceControlByResourceID(resourceID)
+	if err == api.ErrResourceControlNotFound {
+		return nil, nil
+	}
+	return resourceControl, err
+}
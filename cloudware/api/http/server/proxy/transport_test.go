@@ -0,0 +1,133 @@
+package proxy
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"cloudware/cloudware/api"
+	"cloudware/cloudware/api/http/server/security"
+	"cloudware/cloudware/bolt"
+)
+
+// tokenService accepts any token as the token of the same user.
+type tokenService struct {
+	tokenData *api.TokenData
+}
+
+func (service *tokenService) GenerateToken(data *api.TokenData) (string, error) {
+	return "token", nil
+}
+
+func (service *tokenService) ParseAndVerifyToken(token string) (*api.TokenData, error) {
+	return service.tokenData, nil
+}
+
+// newBenchmarkProxy returns a handler proxying the requests of a regular user to a stub Docker API,
+// along with the URL of the stub. The database contains the specified number of resource controls,
+// the user is granted access to the one associated to the "target" resource.
+func newBenchmarkProxy(b *testing.B, resourceControlCount int) (http.Handler, string, func()) {
+	store, cleanup := bolt.NewTestStore(b)
+
+	err := store.SettingsService.StoreSettings(&api.Settings{})
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	user := &api.User{Username: "user", Role: api.StandardUserRole}
+	err = store.UserService.CreateUser(user)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	for idx := 0; idx < 3; idx++ {
+		err = store.TeamMembershipService.CreateTeamMembership(&api.TeamMembership{UserID: user.ID, TeamID: api.TeamID(idx + 1), Role: api.TeamMember})
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	for idx := 0; idx < resourceControlCount; idx++ {
+		resourceControl := &api.ResourceControl{
+			ResourceID:     "resource-" + strconv.Itoa(idx),
+			SubResourceIDs: []string{"sub-resource-" + strconv.Itoa(idx)},
+			Type:           api.ContainerResourceControl,
+			TeamAccesses:   []api.TeamResourceAccess{{TeamID: 10, AccessLevel: api.ReadWriteAccessLevel}},
+		}
+		if idx == resourceControlCount-1 {
+			resourceControl.ResourceID = "target"
+			resourceControl.UserAccesses = []api.UserResourceAccess{{UserID: user.ID, AccessLevel: api.ReadWriteAccessLevel}}
+		}
+		err = store.ResourceControlService.CreateResourceControl(resourceControl)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	docker := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/containers/json" {
+			io.WriteString(w, `[{"Id":"target","Labels":{}},{"Id":"unknown-1","Labels":{}},{"Id":"unknown-2","Labels":{}}]`)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	transport := &proxyTransport{
+		ResourceControlService: store.ResourceControlService,
+		TeamMembershipService:  store.TeamMembershipService,
+		SettingsService:        store.SettingsService,
+		dockerTransport:        newHTTPTransport(),
+	}
+
+	jwtService := &tokenService{tokenData: &api.TokenData{ID: user.ID, Username: user.Username, Role: user.Role}}
+	bouncer := security.NewRequestBouncer(jwtService, store.TeamMembershipService, false)
+	handler := bouncer.AuthenticatedAccess(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		response, err := transport.RoundTrip(r)
+		if err != nil {
+			b.Fatal(err)
+		}
+		defer response.Body.Close()
+		w.WriteHeader(response.StatusCode)
+		io.Copy(w, response.Body)
+	}))
+
+	return handler, docker.URL, func() {
+		docker.Close()
+		cleanup()
+	}
+}
+
+func benchmarkProxy(b *testing.B, method, path string, expectedStatus int) {
+	for _, count := range []int{10, 100, 1000} {
+		b.Run(strconv.Itoa(count)+"ResourceControls", func(b *testing.B) {
+			handler, dockerURL, cleanup := newBenchmarkProxy(b, count)
+			defer cleanup()
+
+			b.ResetTimer()
+			for idx := 0; idx < b.N; idx++ {
+				request := httptest.NewRequest(method, dockerURL+path, nil)
+				request.Header.Set("Authorization", "Bearer token")
+				recorder := httptest.NewRecorder()
+
+				handler.ServeHTTP(recorder, request)
+				if recorder.Code != expectedStatus {
+					b.Fatalf("expected status %d, got %d: %s", expectedStatus, recorder.Code, recorder.Body.String())
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkRestrictedOperation measures the overhead of an operation on a single resource.
+// It must not depend on the number of resource controls.
+func BenchmarkRestrictedOperation(b *testing.B) {
+	benchmarkProxy(b, http.MethodPost, "/containers/target/start", http.StatusNoContent)
+}
+
+// BenchmarkRewriteOperation measures the overhead of filtering a list of resources.
+// It must depend on the number of listed resources only.
+func BenchmarkRewriteOperation(b *testing.B) {
+	benchmarkProxy(b, http.MethodGet, "/containers/json", http.StatusOK)
+}
@@ -6,6 +6,8 @@ import (
 
 	"cloudware/cloudware/api"
 	httperror "cloudware/cloudware/api/http/server/error"
+	"cloudware/cloudware/api/logging"
+	"github.com/sirupsen/logrus"
 )
 
 type (
@@ -45,7 +47,16 @@ func (bouncer *RequestBouncer) PublicAccess(h http.Handler) http.Handler {
 // AuthenticatedAccess defines a security check for private endpoints.
 // Authentication is required to access these endpoints.
 func (bouncer *RequestBouncer) AuthenticatedAccess(h http.Handler) http.Handler {
-	h = bouncer.mwCheckAuthentication(h)
+	h = bouncer.mwCheckAuthentication(h, false)
+	h = mwSecureHeaders(h)
+	return h
+}
+
+// WebSocketAccess defines a security check for websocket endpoints.
+// Authentication is required to access these endpoints, browsers cannot set headers
+// on websocket connections so the token can also be sent in the token query parameter.
+func (bouncer *RequestBouncer) WebSocketAccess(h http.Handler) http.Handler {
+	h = bouncer.mwCheckAuthentication(h, true)
 	h = mwSecureHeaders(h)
 	return h
 }
@@ -68,6 +79,14 @@ func (bouncer *RequestBouncer) AdministratorAccess(h http.Handler) http.Handler
 	return h
 }
 
+// AdministratorWebSocketAccess defines a security check for websocket endpoints restricted
+// to administrators, the token can be sent in the token query parameter.
+func (bouncer *RequestBouncer) AdministratorWebSocketAccess(h http.Handler) http.Handler {
+	h = mwCheckAdministratorRole(h)
+	h = bouncer.WebSocketAccess(h)
+	return h
+}
+
 // mwSecureHeaders provides secure headers middleware for handlers.
 func mwSecureHeaders(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -83,13 +102,13 @@ func (bouncer *RequestBouncer) mwUpgradeToRestrictedRequest(next http.Handler) h
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		tokenData, err := RetrieveTokenData(r)
 		if err != nil {
-			httperror.WriteErrorResponse(w, api.ErrResourceAccessDenied, http.StatusForbidden, nil)
+			httperror.WriteErrorResponse(w, r, api.ErrResourceAccessDenied, http.StatusForbidden)
 			return
 		}
 
 		requestContext, err := bouncer.newRestrictedContextRequest(tokenData.ID, tokenData.Role)
 		if err != nil {
-			httperror.WriteErrorResponse(w, err, http.StatusInternalServerError, nil)
+			httperror.WriteErrorResponse(w, r, err, http.StatusInternalServerError)
 			return
 		}
 
@@ -103,7 +122,7 @@ func mwCheckAdministratorRole(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		tokenData, err := RetrieveTokenData(r)
 		if err != nil || tokenData.Role != api.AdministratorRole {
-			httperror.WriteErrorResponse(w, api.ErrResourceAccessDenied, http.StatusForbidden, nil)
+			httperror.WriteErrorResponse(w, r, api.ErrResourceAccessDenied, http.StatusForbidden)
 			return
 		}
 
@@ -111,8 +130,9 @@ func mwCheckAdministratorRole(next http.Handler) http.Handler {
 	})
 }
 
-// mwCheckAuthentication provides Authentication middleware for handlers
-func (bouncer *RequestBouncer) mwCheckAuthentication(next http.Handler) http.Handler {
+// mwCheckAuthentication provides Authentication middleware for handlers. The token query parameter
+// is only read when allowQueryToken is set, tokens in URLs end up in the logs of the proxies.
+func (bouncer *RequestBouncer) mwCheckAuthentication(next http.Handler, allowQueryToken bool) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		var tokenData *api.TokenData
 		if !bouncer.authDisabled {
@@ -125,17 +145,23 @@ func (bouncer *RequestBouncer) mwCheckAuthentication(next http.Handler) http.Han
 				token = strings.TrimPrefix(token, "Bearer ")
 			}
 
+			// Browsers cannot set headers on websocket connections, fall back to the token query parameter
+			if token == "" && allowQueryToken {
+				token = r.URL.Query().Get("token")
+			}
+
 			if token == "" {
-				httperror.WriteErrorResponse(w, api.ErrUnauthorized, http.StatusUnauthorized, nil)
+				httperror.WriteErrorResponse(w, r, api.ErrUnauthorized, http.StatusUnauthorized)
 				return
 			}
 
 			var err error
 			tokenData, err = bouncer.jwtService.ParseAndVerifyToken(token)
 			if err != nil {
-				httperror.WriteErrorResponse(w, err, http.StatusUnauthorized, nil)
+				httperror.WriteErrorResponse(w, r, err, http.StatusUnauthorized)
 				return
 			}
+			logging.AddFields(r.Context(), logrus.Fields{"user": tokenData.Username, "user_id": tokenData.ID})
 		} else {
 			tokenData = &api.TokenData{
 				Role: api.AdministratorRole,
@@ -2,26 +2,6 @@ package security
 
 import "cloudware/cloudware/api"
 
-// FilterUserTeams filters teams based on user role.
-// non-administrator users only have access to team they are member of.
-func FilterUserTeams(teams []api.Team, context *RestrictedRequestContext) []api.Team {
-	filteredTeams := teams
-
-	if !context.IsAdmin {
-		filteredTeams = make([]api.Team, 0)
-		for _, membership := range context.UserMemberships {
-			for _, team := range teams {
-				if team.ID == membership.TeamID {
-					filteredTeams = append(filteredTeams, team)
-					break
-				}
-			}
-		}
-	}
-
-	return filteredTeams
-}
-
 // FilterLeaderTeams filters teams based on user role.
 // Team leaders only have access to team they lead.
 func FilterLeaderTeams(teams []api.Team, context *RestrictedRequestContext) []api.Team {
@@ -42,24 +22,6 @@ func FilterLeaderTeams(teams []api.Team, context *RestrictedRequestContext) []ap
 	return filteredTeams
 }
 
-// FilterUsers filters users based on user role.
-// Non-administrator users only have access to non-administrator users.
-func FilterUsers(users []api.User, context *RestrictedRequestContext) []api.User {
-	filteredUsers := users
-
-	if !context.IsAdmin {
-		filteredUsers = make([]api.User, 0)
-
-		for _, user := range users {
-			if user.Role != api.AdministratorRole {
-				filteredUsers = append(filteredUsers, user)
-			}
-		}
-	}
-
-	return filteredUsers
-}
-
 // FilterRegistries filters registries based on user role and team memberships.
 // Non administrator users only have access to authorized registries.
 func FilterRegistries(registries []api.Registry, context *RestrictedRequestContext) ([]api.Registry, error) {
@@ -127,3 +89,33 @@ func isEndpointAccessAuthorized(endpoint *api.Endpoint, userID api.UserID, membe
 	}
 	return false
 }
+
+// ListAccessFilter returns the filter restricting a list to the objects the user of a request
+// is authorized to access, or nil for the administrators who can access every object.
+func ListAccessFilter(context *RestrictedRequestContext) *api.AccessFilter {
+	if context.IsAdmin {
+		return nil
+	}
+	return &api.AccessFilter{
+		UserID:  context.UserID,
+		TeamIDs: membershipTeamIDs(context.UserMemberships),
+	}
+}
+
+// ListTeamIDs returns the teams listed to the user of a request, or nil for the
+// administrators who can list every team. Non-administrator users only list the teams
+// they are member of.
+func ListTeamIDs(context *RestrictedRequestContext) []api.TeamID {
+	if context.IsAdmin {
+		return nil
+	}
+	return membershipTeamIDs(context.UserMemberships)
+}
+
+func membershipTeamIDs(memberships []api.TeamMembership) []api.TeamID {
+	teamIDs := make([]api.TeamID, 0, len(memberships))
+	for _, membership := range memberships {
+		teamIDs = append(teamIDs, membership.TeamID)
+	}
+	return teamIDs
+}
@@ -1,19 +1,30 @@
 package server
 
 import (
+	"context"
+	"crypto/tls"
+	"net"
 	"net/http"
 	"path/filepath"
+	"sync"
+	"time"
 
 	"cloudware/cloudware/api"
+	"cloudware/cloudware/api/apply"
+	"cloudware/cloudware/api/cron"
+	"cloudware/cloudware/api/export"
 	"cloudware/cloudware/api/http/server/handler"
 	"cloudware/cloudware/api/http/server/security"
 	"cloudware/cloudware/api/http/server/proxy"
+	"cloudware/cloudware/api/metrics"
+	"cloudware/cloudware/api/templates"
 	"github.com/sirupsen/logrus"
 )
 
 // Server implements the cloudware.Server interface
 type Server struct {
 	BindAddress            string
+	MetricsAddress         string
 	AssetsPath             string
 	AuthDisabled           bool
 	EndpointManagement     bool
@@ -25,22 +36,54 @@ type Server struct {
 	ResourceControlService api.ResourceControlService
 	SettingsService        api.SettingsService
 	CryptoService          api.CryptoService
+	PKIService             api.PKIService
+	BackupService          api.BackupService
+	BackupStatusService    api.BackupStatusService
+	Watcher                *cron.Watcher
 	JWTService             api.JWTService
 	FileService            api.FileService
 	RegistryService        api.RegistryService
 	DockerHubService       api.DockerHubService
 	StackService           api.StackService
+	RecordingService       api.RecordingService
+	TemplateService        api.TemplateService
+	GitService             api.GitService
 	StackManager           api.StackManager
+	DataStore              api.DataStore
 	Handler                *handler.Handler
 	SSL                    bool
 	SSLCert                string
 	SSLKey                 string
+	ReadTimeout            time.Duration
+	WriteTimeout           time.Duration
+	IdleTimeout            time.Duration
+	ShutdownTimeout        time.Duration
+
+	httpServer       *http.Server
+	metricsServer    *http.Server
+	ctx              context.Context
+	cancel           context.CancelFunc
+	sessions         *handler.SessionTracker
+	certificateMutex sync.RWMutex
+	certificate      *tls.Certificate
 }
 
 // Start starts the HTTP server
 func (server *Server) Start() error {
 	requestBouncer := security.NewRequestBouncer(server.JWTService, server.TeamMembershipService, server.AuthDisabled)
-	proxyManager := proxy.NewManager(server.ResourceControlService, server.TeamMembershipService, server.SettingsService)
+	proxyManager := proxy.NewManager(server.ResourceControlService, server.TeamMembershipService, server.SettingsService, server.RegistryService, server.DockerHubService)
+
+	if server.Watcher != nil {
+		err := server.Watcher.WatchCertificates(server.PKIService, proxyManager)
+		if err != nil {
+			return err
+		}
+
+		err = server.Watcher.WatchEndpointHealth(proxyManager)
+		if err != nil {
+			return err
+		}
+	}
 
 	var fileHandler = handler.NewFileHandler(filepath.Join(server.AssetsPath, "public"))
 	var authHandler = handler.NewAuthHandler(requestBouncer, server.AuthDisabled)
@@ -61,17 +104,75 @@ func (server *Server) Start() error {
 	var teamMembershipHandler = handler.NewTeamMembershipHandler(requestBouncer)
 	teamMembershipHandler.TeamMembershipService = server.TeamMembershipService
 	var statusHandler = handler.NewStatusHandler(requestBouncer, server.Status)
+	var loggingHandler = handler.NewLoggingHandler(requestBouncer)
 	var settingsHandler = handler.NewSettingsHandler(requestBouncer)
 	settingsHandler.SettingsService = server.SettingsService
 	settingsHandler.FileService = server.FileService
 	var templatesHandler = handler.NewTemplatesHandler(requestBouncer)
 	templatesHandler.SettingsService = server.SettingsService
+	templatesHandler.TemplateService = server.TemplateService
+	templatesHandler.TemplateFetcher = templates.NewFetcher(server.FileService, server.GitService)
+	templatesHandler.EndpointService = server.EndpointService
+	templatesHandler.StackService = server.StackService
+	templatesHandler.ResourceControlService = server.ResourceControlService
+	templatesHandler.RegistryService = server.RegistryService
+	templatesHandler.DockerHubService = server.DockerHubService
+	templatesHandler.FileService = server.FileService
+	templatesHandler.GitService = server.GitService
+	templatesHandler.StackManager = server.StackManager
+	templatesHandler.ProxyManager = proxyManager
 	var dockerHandler = handler.NewDockerHandler(requestBouncer)
 	dockerHandler.EndpointService = server.EndpointService
 	dockerHandler.TeamMembershipService = server.TeamMembershipService
 	dockerHandler.ProxyManager = proxyManager
-	var websocketHandler = handler.NewWebSocketHandler()
+	var aggregateHandler = handler.NewAggregateHandler(requestBouncer)
+	aggregateHandler.EndpointService = server.EndpointService
+	aggregateHandler.ProxyManager = proxyManager
+	var logsHandler = handler.NewLogsHandler(requestBouncer)
+	logsHandler.EndpointService = server.EndpointService
+	logsHandler.ProxyManager = proxyManager
+	var websocketHandler = handler.NewWebSocketHandler(requestBouncer)
 	websocketHandler.EndpointService = server.EndpointService
+	websocketHandler.TeamMembershipService = server.TeamMembershipService
+	websocketHandler.RecordingService = server.RecordingService
+	websocketHandler.FileService = server.FileService
+	websocketHandler.ProxyManager = proxyManager
+	var pkiHandler = handler.NewPKIHandler(requestBouncer)
+	pkiHandler.PKIService = server.PKIService
+	pkiHandler.EndpointService = server.EndpointService
+	pkiHandler.SettingsService = server.SettingsService
+	pkiHandler.FileService = server.FileService
+	pkiHandler.ProxyManager = proxyManager
+	var backupHandler = handler.NewBackupHandler(requestBouncer)
+	backupHandler.BackupService = server.BackupService
+	backupHandler.BackupStatusService = server.BackupStatusService
+	backupHandler.ProxyManager = proxyManager
+	var applyHandler = handler.NewApplyHandler(requestBouncer, server.EndpointManagement)
+	applyHandler.ApplyService = &apply.Service{
+		UserService:           server.UserService,
+		TeamService:           server.TeamService,
+		TeamMembershipService: server.TeamMembershipService,
+		EndpointService:       server.EndpointService,
+		RegistryService:       server.RegistryService,
+		SettingsService:       server.SettingsService,
+		CryptoService:         server.CryptoService,
+		FileService:           server.FileService,
+	}
+	applyHandler.ProxyManager = proxyManager
+	var exportHandler = handler.NewExportHandler(requestBouncer)
+	exportHandler.ExportService = &export.Service{
+		UserService:            server.UserService,
+		TeamService:            server.TeamService,
+		TeamMembershipService:  server.TeamMembershipService,
+		EndpointService:        server.EndpointService,
+		RegistryService:        server.RegistryService,
+		ResourceControlService: server.ResourceControlService,
+		StackService:           server.StackService,
+		FileService:            server.FileService,
+	}
+	var recordingHandler = handler.NewRecordingHandler(requestBouncer)
+	recordingHandler.RecordingService = server.RecordingService
+	recordingHandler.FileService = server.FileService
 	var endpointHandler = handler.NewEndpointHandler(requestBouncer, server.EndpointManagement)
 	endpointHandler.EndpointService = server.EndpointService
 	endpointHandler.FileService = server.FileService
@@ -92,9 +193,12 @@ func (server *Server) Start() error {
 	stackHandler.StackManager = server.StackManager
 	stackHandler.RegistryService = server.RegistryService
 	stackHandler.DockerHubService = server.DockerHubService
+	stackHandler.GitService = server.GitService
 
 	server.Handler = &handler.Handler{
+		RequestBouncer:        requestBouncer,
 		AuthHandler:           authHandler,
+		ApplyHandler:          applyHandler,
 		UserHandler:           userHandler,
 		TeamHandler:           teamHandler,
 		TeamMembershipHandler: teamMembershipHandler,
@@ -107,28 +211,153 @@ func (server *Server) Start() error {
 		StackHandler:          stackHandler,
 		TemplatesHandler:      templatesHandler,
 		DockerHandler:         dockerHandler,
+		LogsHandler:           logsHandler,
+		AggregateHandler:      aggregateHandler,
 		WebSocketHandler:      websocketHandler,
+		RecordingHandler:      recordingHandler,
+		PKIHandler:            pkiHandler,
+		LoggingHandler:        loggingHandler,
+		BackupHandler:         backupHandler,
+		ExportHandler:         exportHandler,
 		FileHandler:           fileHandler,
 		UploadHandler:         uploadHandler,
 	}
+	if server.MetricsAddress == "" {
+		// The metrics expose the endpoints and their traffic, they are restricted to the administrators on the API listener.
+		server.Handler.MetricsHandler = requestBouncer.AdministratorAccess(metrics.Handler())
+	} else {
+		metricsMux := http.NewServeMux()
+		metricsMux.Handle("/metrics", metrics.Handler())
+		server.metricsServer = &http.Server{
+			Addr:    server.MetricsAddress,
+			Handler: metricsMux,
+		}
+	}
+
+	// The requests are served with a context cancelled when the server fails to drain them on shutdown.
+	server.ctx, server.cancel = context.WithCancel(context.Background())
+	server.sessions = websocketHandler.Sessions
+	server.httpServer = &http.Server{
+		Addr:         server.BindAddress,
+		Handler:      server.Handler,
+		ReadTimeout:  server.ReadTimeout,
+		WriteTimeout: server.WriteTimeout,
+		IdleTimeout:  server.IdleTimeout,
+		TLSConfig:    &tls.Config{GetCertificate: server.getCertificate},
+		BaseContext: func(net.Listener) context.Context {
+			return server.ctx
+		},
+	}
 
 	return nil
 }
 
+// Wait serves the API until the server is shut down, the error preventing
+// the server from running is sent on waitChan, nil after a shutdown.
 func (server *Server) Wait(waitChan chan error) {
+	if server.metricsServer != nil {
+		go func() {
+			err := server.metricsServer.ListenAndServe()
+			if err != nil && err != http.ErrServerClosed {
+				logrus.Errorf("Metrics listener error: %v", err)
+			}
+		}()
+	}
+
 	var err error
 	if server.SSL {
-		err = http.ListenAndServeTLS(server.BindAddress, server.SSLCert, server.SSLKey, server.Handler)
+		err = server.LoadCertificate(server.SSLCert, server.SSLKey)
+		if err == nil {
+			err = server.httpServer.ListenAndServeTLS("", "")
+		}
 	} else {
-		err = http.ListenAndServe(server.BindAddress, server.Handler)
+		err = server.httpServer.ListenAndServe()
+	}
+	if err == http.ErrServerClosed {
+		err = nil
 	}
 	if err != nil {
 		logrus.Errorf("ServeAPI error: %v", err)
-		waitChan <- err
 	}
-	waitChan <- nil
+	waitChan <- err
 }
 
-func (server *Server) Close() error {
+// Shutdown stops the server gracefully. The new connections are refused and the requests and
+// websocket sessions in progress are given until the context is done to end, the stack commands,
+// requests and sessions still running are then cancelled. The cron jobs are stopped and the data store is closed last,
+// once the jobs already running, such as a backup, have ended or the context is done.
+func (server *Server) Shutdown(ctx context.Context) error {
+	var drainErr error
+	if server.httpServer != nil {
+		errChan := make(chan error, 2)
+		go func() {
+			errChan <- server.httpServer.Shutdown(ctx)
+		}()
+		go func() {
+			errChan <- server.sessions.Shutdown(ctx)
+		}()
+
+		for i := 0; i < 2; i++ {
+			if err := <-errChan; err != nil && drainErr == nil {
+				drainErr = err
+			}
+		}
+
+		if drainErr != nil {
+			logrus.Warnf("Unable to drain the requests in progress, cancelling them: %v", drainErr)
+			if server.StackManager != nil {
+				server.StackManager.Cancel()
+			}
+			server.httpServer.Close()
+		}
+		server.cancel()
+	}
+
+	if server.metricsServer != nil {
+		server.metricsServer.Close()
+	}
+
+	if server.Watcher != nil {
+		err := server.Watcher.Stop(ctx)
+		if err != nil {
+			logrus.Warnf("Unable to wait for the cron jobs in progress, closing the data store: %v", err)
+		}
+	}
+
+	if server.DataStore != nil {
+		err := server.DataStore.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return drainErr
+}
+
+// LoadCertificate loads the SSL certificate served by the API. It can be called while
+// the server is running to serve a renewed certificate without restarting.
+func (server *Server) LoadCertificate(certPath, keyPath string) error {
+	certificate, err := tls.LoadX509KeyPair(certPath, keyPath)
+	if err != nil {
+		return err
+	}
+
+	server.certificateMutex.Lock()
+	defer server.certificateMutex.Unlock()
+	server.certificate = &certificate
+	server.SSLCert = certPath
+	server.SSLKey = keyPath
 	return nil
-}
\ No newline at end of file
+}
+
+func (server *Server) getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	server.certificateMutex.RLock()
+	defer server.certificateMutex.RUnlock()
+	return server.certificate, nil
+}
+
+// Close stops the server immediately, without draining the requests in progress.
+func (server *Server) Close() error {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	return server.Shutdown(ctx)
+}
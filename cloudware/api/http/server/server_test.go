@@ -0,0 +1,148 @@
+package server
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"cloudware/cloudware/api/http/server/handler"
+)
+
+// shutdownEvents records the order in which the parts of the server end.
+type shutdownEvents struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (recorder *shutdownEvents) record(event string) {
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+	recorder.events = append(recorder.events, event)
+}
+
+func (recorder *shutdownEvents) list() []string {
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+	return append([]string(nil), recorder.events...)
+}
+
+type recordingDataStore struct {
+	events *shutdownEvents
+}
+
+func (store recordingDataStore) Open() error        { return nil }
+func (store recordingDataStore) MigrateData() error { return nil }
+func (store recordingDataStore) Close() error {
+	store.events.record("store closed")
+	return nil
+}
+
+type recordingSession struct {
+	events *shutdownEvents
+}
+
+func (session recordingSession) Close() error {
+	session.events.record("session closed")
+	return nil
+}
+
+// newShutdownServer returns a server serving a request blocked until release is closed.
+func newShutdownServer(t *testing.T, events *shutdownEvents, requestStarted, release chan struct{}) *Server {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := &Server{
+		DataStore: recordingDataStore{events: events},
+		sessions:  handler.NewSessionTracker(),
+	}
+	server.ctx, server.cancel = context.WithCancel(context.Background())
+	server.httpServer = &http.Server{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			close(requestStarted)
+			select {
+			case <-release:
+			case <-r.Context().Done():
+			}
+			events.record("request ended")
+		}),
+		BaseContext: func(net.Listener) context.Context {
+			return server.ctx
+		},
+	}
+	go server.httpServer.Serve(listener)
+
+	go http.Get("http://" + listener.Addr().String())
+	<-requestStarted
+	return server
+}
+
+func TestShutdownOrder(t *testing.T) {
+	events := &shutdownEvents{}
+	requestStarted, release := make(chan struct{}), make(chan struct{})
+	server := newShutdownServer(t, events, requestStarted, release)
+
+	session := recordingSession{events: events}
+	server.sessions.Add(session)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- server.Shutdown(context.Background())
+	}()
+
+	time.Sleep(20 * time.Millisecond)
+	if len(events.list()) != 0 {
+		t.Fatalf("expected the shutdown to wait for the request and the session, got %v", events.list())
+	}
+
+	close(release)
+	time.Sleep(20 * time.Millisecond)
+	events.record("session ended")
+	server.sessions.Remove(session)
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the server to shut down")
+	}
+
+	expected := []string{"request ended", "session ended", "store closed"}
+	if got := events.list(); len(got) != len(expected) || got[0] != expected[0] || got[1] != expected[1] || got[2] != expected[2] {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+}
+
+func TestShutdownDeadline(t *testing.T) {
+	events := &shutdownEvents{}
+	requestStarted, release := make(chan struct{}), make(chan struct{})
+	defer close(release)
+	server := newShutdownServer(t, events, requestStarted, release)
+	server.sessions.Add(recordingSession{events: events})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	err := server.Shutdown(ctx)
+	if err != context.DeadlineExceeded {
+		t.Fatalf("expected the deadline to expire, got %v", err)
+	}
+
+	// The request is cancelled and the session closed before the store.
+	got := events.list()
+	if len(got) == 0 || got[len(got)-1] != "store closed" {
+		t.Fatalf("expected the store to be closed last, got %v", got)
+	}
+	closed := map[string]bool{}
+	for _, event := range got {
+		closed[event] = true
+	}
+	if !closed["session closed"] {
+		t.Fatalf("expected the running session to be closed, got %v", got)
+	}
+}
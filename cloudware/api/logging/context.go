@@ -0,0 +1,90 @@
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+// RequestIDHeader is the header carrying the identifier of a request, it is
+// reused when sent by the client and always set on the response.
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength bounds the length of the request identifiers accepted from the clients.
+const maxRequestIDLength = 64
+
+type contextKey int
+
+const contextLoggerKey contextKey = iota
+
+// requestLogger holds the logger of a request. The fields added by the inner
+// handlers, such as the authenticated user, are visible to the outer ones.
+type requestLogger struct {
+	mu    sync.Mutex
+	entry *logrus.Entry
+}
+
+// NewContext returns a context carrying a request logger built from entry.
+func NewContext(ctx context.Context, entry *logrus.Entry) context.Context {
+	return context.WithValue(ctx, contextLoggerKey, &requestLogger{entry: entry})
+}
+
+// FromContext returns the request logger stored in the context, or the logger
+// of the daemon when there is none.
+func FromContext(ctx context.Context) *logrus.Entry {
+	logger, ok := ctx.Value(contextLoggerKey).(*requestLogger)
+	if !ok {
+		return logrus.NewEntry(logrus.StandardLogger())
+	}
+	logger.mu.Lock()
+	defer logger.mu.Unlock()
+	return logger.entry
+}
+
+// FromRequest returns the logger of a request.
+func FromRequest(r *http.Request) *logrus.Entry {
+	return FromContext(r.Context())
+}
+
+// AddFields adds fields to the request logger stored in the context, it does nothing when there is none.
+func AddFields(ctx context.Context, fields logrus.Fields) {
+	logger, ok := ctx.Value(contextLoggerKey).(*requestLogger)
+	if !ok {
+		return
+	}
+	logger.mu.Lock()
+	defer logger.mu.Unlock()
+	logger.entry = logger.entry.WithFields(fields)
+}
+
+// RequestID returns the identifier sent by the client of a request when it is
+// valid, or a new random identifier otherwise.
+func RequestID(r *http.Request) string {
+	if id := r.Header.Get(RequestIDHeader); validRequestID(id) {
+		return id
+	}
+
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "unknown"
+	}
+	return hex.EncodeToString(b)
+}
+
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '-', c == '_', c == '.':
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,39 @@
+package logging
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestID(t *testing.T) {
+	request := httptest.NewRequest("GET", "/api/status", nil)
+	request.Header.Set(RequestIDHeader, "abc-123")
+	assert.Equal(t, "abc-123", RequestID(request))
+
+	for _, id := range []string{"", "with space", "line\nbreak", strings.Repeat("a", maxRequestIDLength+1)} {
+		request.Header.Set(RequestIDHeader, id)
+		generated := RequestID(request)
+		assert.NotEqual(t, id, generated)
+		assert.Len(t, generated, 32)
+	}
+}
+
+func TestAddFields(t *testing.T) {
+	ctx := NewContext(context.Background(), logrus.WithField("request_id", "abc"))
+	inner := context.WithValue(ctx, contextKey(42), "inner")
+
+	AddFields(inner, logrus.Fields{"user": "admin"})
+
+	entry := FromContext(ctx)
+	assert.Equal(t, "abc", entry.Data["request_id"])
+	assert.Equal(t, "admin", entry.Data["user"])
+
+	// Without a request logger, the logger of the daemon is used and left untouched.
+	AddFields(context.Background(), logrus.Fields{"user": "admin"})
+	assert.Empty(t, FromContext(context.Background()).Data)
+}
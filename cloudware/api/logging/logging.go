@@ -0,0 +1,57 @@
+// Package logging configures the structured logger of the daemon and carries
+// the request-scoped loggers of the API.
+package logging
+
+import (
+	"errors"
+
+	"github.com/sirupsen/logrus"
+
+	"cloudware/cloudware/cli/debug"
+)
+
+const (
+	// FormatText logs human readable lines.
+	FormatText = "text"
+	// FormatJSON logs one JSON object per line.
+	FormatJSON = "json"
+)
+
+// ErrInvalidFormat defines an error raised when the log format is neither text nor json.
+var ErrInvalidFormat = errors.New("invalid log format, expected text or json")
+
+// SetFormat sets the format of the logs of the daemon.
+func SetFormat(format string) error {
+	switch format {
+	case "", FormatText:
+		logrus.SetFormatter(&logrus.TextFormatter{FullTimestamp: true})
+	case FormatJSON:
+		logrus.SetFormatter(&logrus.JSONFormatter{})
+	default:
+		return ErrInvalidFormat
+	}
+	return nil
+}
+
+// Format returns the format of the logs of the daemon.
+func Format() string {
+	if _, ok := logrus.StandardLogger().Formatter.(*logrus.JSONFormatter); ok {
+		return FormatJSON
+	}
+	return FormatText
+}
+
+// SetLevel sets the level of the logs of the daemon and keeps the debug mode in sync with it.
+func SetLevel(level logrus.Level) {
+	if level == logrus.DebugLevel {
+		debug.Enable()
+		return
+	}
+	debug.Disable()
+	logrus.SetLevel(level)
+}
+
+// WithComponent returns the logger of a component of the daemon, such as a background job.
+func WithComponent(component string) *logrus.Entry {
+	return logrus.WithField("component", component)
+}
@@ -0,0 +1,166 @@
+// Package metrics exposes the metrics of the daemon in the Prometheus text format.
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var (
+	// DefaultBuckets are the histogram buckets used for the durations of the requests, in seconds.
+	DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+	// LongBuckets are the histogram buckets used for the durations of the long running operations, in seconds.
+	LongBuckets = []float64{1, 2.5, 5, 10, 30, 60, 120, 300, 600}
+)
+
+var (
+	httpRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "cloudware_http_requests_total",
+		Help: "Number of HTTP requests served by the API.",
+	}, []string{"handler", "method", "code"})
+	httpRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "cloudware_http_request_duration_seconds",
+		Help:    "Duration of the HTTP requests served by the API.",
+		Buckets: DefaultBuckets,
+	}, []string{"handler"})
+
+	dockerProxyRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "cloudware_docker_proxy_requests_total",
+		Help: "Number of requests proxied to the Docker API of the endpoints.",
+	}, []string{"endpoint", "group", "code"})
+	dockerProxyErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "cloudware_docker_proxy_errors_total",
+		Help: "Number of requests proxied to the Docker API of the endpoints which failed with a server error.",
+	}, []string{"endpoint", "group"})
+	dockerProxyDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "cloudware_docker_proxy_request_duration_seconds",
+		Help:    "Duration of the requests proxied to the Docker API of the endpoints.",
+		Buckets: DefaultBuckets,
+	}, []string{"endpoint", "group"})
+
+	stackDeploys = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "cloudware_stack_deploys_total",
+		Help: "Number of stack deployments.",
+	}, []string{"result"})
+	stackDeployDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "cloudware_stack_deploy_duration_seconds",
+		Help:    "Duration of the stack deployments.",
+		Buckets: LongBuckets,
+	})
+
+	endpointSyncRuns = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "cloudware_endpoint_sync_runs_total",
+		Help: "Number of synchronizations of the endpoints with the external endpoints source.",
+	}, []string{"result"})
+	endpointSyncDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "cloudware_endpoint_sync_duration_seconds",
+		Help:    "Duration of the synchronizations of the endpoints with the external endpoints source.",
+		Buckets: DefaultBuckets,
+	})
+	endpointSyncLastSuccess = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "cloudware_endpoint_sync_last_success_timestamp_seconds",
+		Help: "Time of the last successful synchronization of the endpoints, as a Unix timestamp.",
+	})
+
+	endpointUp = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "cloudware_endpoint_up",
+		Help: "Whether the Docker API of an endpoint answered the last health check, 1 when it did and 0 otherwise.",
+	}, []string{"endpoint"})
+
+	boltTransactionDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "cloudware_bolt_transaction_duration_seconds",
+		Help:    "Duration of the transactions of the database, by type: read or write.",
+		Buckets: DefaultBuckets,
+	}, []string{"type"})
+	boltDatabaseSize = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "cloudware_bolt_database_size_bytes",
+		Help: "Size of the database, as of the last write transaction.",
+	})
+)
+
+// Registry is the registry of the metrics of the daemon, along with the metrics of the Go runtime and of the process.
+var Registry = prometheus.NewRegistry()
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		httpRequests, httpRequestDuration,
+		dockerProxyRequests, dockerProxyErrors, dockerProxyDuration,
+		stackDeploys, stackDeployDuration,
+		endpointSyncRuns, endpointSyncDuration, endpointSyncLastSuccess,
+		endpointUp,
+		boltTransactionDuration, boltDatabaseSize,
+	)
+}
+
+// Handler returns the handler serving the metrics of the daemon.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+}
+
+func result(err error) string {
+	if err != nil {
+		return "failure"
+	}
+	return "success"
+}
+
+// ObserveHTTPRequest records a request served by the API handler named handler.
+func ObserveHTTPRequest(handler, method string, code int, duration time.Duration) {
+	httpRequests.WithLabelValues(handler, method, strconv.Itoa(code)).Inc()
+	httpRequestDuration.WithLabelValues(handler).Observe(duration.Seconds())
+}
+
+// ObserveDockerProxyRequest records a request proxied to the Docker API of an endpoint,
+// group is the kind of resource targeted by the request, such as containers.
+func ObserveDockerProxyRequest(endpoint, group string, code int, duration time.Duration) {
+	dockerProxyRequests.WithLabelValues(endpoint, group, strconv.Itoa(code)).Inc()
+	if code >= http.StatusInternalServerError {
+		dockerProxyErrors.WithLabelValues(endpoint, group).Inc()
+	}
+	dockerProxyDuration.WithLabelValues(endpoint, group).Observe(duration.Seconds())
+}
+
+// ObserveStackDeploy records a stack deployment.
+func ObserveStackDeploy(duration time.Duration, err error) {
+	stackDeploys.WithLabelValues(result(err)).Inc()
+	stackDeployDuration.Observe(duration.Seconds())
+}
+
+// ObserveEndpointSync records a synchronization of the endpoints with the external endpoints source.
+func ObserveEndpointSync(duration time.Duration, err error) {
+	endpointSyncRuns.WithLabelValues(result(err)).Inc()
+	endpointSyncDuration.Observe(duration.Seconds())
+	if err == nil {
+		endpointSyncLastSuccess.Set(float64(time.Now().Unix()))
+	}
+}
+
+// SetEndpointsUp replaces the health of the endpoints, indexed by endpoint identifier.
+func SetEndpointsUp(endpoints map[string]bool) {
+	endpointUp.Reset()
+	for endpoint, up := range endpoints {
+		value := 0.0
+		if up {
+			value = 1
+		}
+		endpointUp.WithLabelValues(endpoint).Set(value)
+	}
+}
+
+// ObserveBoltTransaction records a transaction of the database, size is the size of
+// the database seen by a write transaction.
+func ObserveBoltTransaction(writable bool, duration time.Duration, size int64) {
+	if !writable {
+		boltTransactionDuration.WithLabelValues("read").Observe(duration.Seconds())
+		return
+	}
+	boltTransactionDuration.WithLabelValues("write").Observe(duration.Seconds())
+	boltDatabaseSize.Set(float64(size))
+}
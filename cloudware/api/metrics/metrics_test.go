@@ -0,0 +1,48 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func serveMetrics(t *testing.T) string {
+	recorder := httptest.NewRecorder()
+	Handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Contains(t, recorder.Header().Get("Content-Type"), "text/plain")
+	return recorder.Body.String()
+}
+
+func TestHandler(t *testing.T) {
+	ObserveHTTPRequest("users", http.MethodGet, http.StatusOK, 20*time.Millisecond)
+	ObserveDockerProxyRequest("1", "containers", http.StatusBadGateway, time.Second)
+	ObserveStackDeploy(time.Minute, nil)
+	ObserveBoltTransaction(true, time.Millisecond, 32768)
+
+	body := serveMetrics(t)
+	assert.Contains(t, body, "# TYPE cloudware_http_requests_total counter")
+	assert.Contains(t, body, `cloudware_http_requests_total{code="200",handler="users",method="GET"} 1`)
+	assert.Contains(t, body, `cloudware_http_request_duration_seconds_bucket{handler="users",le="0.025"} 1`)
+	assert.Contains(t, body, `cloudware_docker_proxy_errors_total{endpoint="1",group="containers"} 1`)
+	assert.Contains(t, body, `cloudware_stack_deploys_total{result="success"} 1`)
+	assert.Contains(t, body, "cloudware_stack_deploy_duration_seconds_count 1")
+	assert.Contains(t, body, "cloudware_bolt_database_size_bytes 32768")
+	assert.Contains(t, body, "go_goroutines")
+}
+
+func TestSetEndpointsUp(t *testing.T) {
+	SetEndpointsUp(map[string]bool{"1": true, "2": false})
+	body := serveMetrics(t)
+	assert.Contains(t, body, `cloudware_endpoint_up{endpoint="1"} 1`)
+	assert.Contains(t, body, `cloudware_endpoint_up{endpoint="2"} 0`)
+
+	// The endpoints removed since the last check are no longer exposed.
+	SetEndpointsUp(map[string]bool{"1": false})
+	body = serveMetrics(t)
+	assert.Contains(t, body, `cloudware_endpoint_up{endpoint="1"} 0`)
+	assert.NotContains(t, body, `endpoint="2"`)
+}
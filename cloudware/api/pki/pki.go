@@ -0,0 +1,339 @@
+package pki
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"cloudware/cloudware/api"
+	"cloudware/cloudware/api/file"
+)
+
+const (
+	// DefaultValidityDays is the validity period of the certificates issued by the certificate authority.
+	DefaultValidityDays = 365
+	caValidityDays      = 10 * 365
+	caCommonName        = "Cloudware CA"
+	serialNumberBits    = 128
+)
+
+// Service implements the PKIService interface. The certificate authority is created
+// the first time a certificate is issued and is stored in the PKIStorePath of the TLS store.
+// When the service is disabled, no certificate authority is created and only the details
+// of the existing certificates are available.
+type Service struct {
+	mu          sync.Mutex
+	disabled    bool
+	fileService api.FileService
+	caCert      *x509.Certificate
+	caKey       *ecdsa.PrivateKey
+	caPEM       []byte
+	// caFile describes the certificate file the certificate authority was loaded from.
+	caFile os.FileInfo
+}
+
+// NewService initializes a new service, the certificate authority is only available when enabled.
+func NewService(fileService api.FileService, enabled bool) *Service {
+	return &Service{
+		disabled:    !enabled,
+		fileService: fileService,
+	}
+}
+
+// CACertificate returns the PEM encoded certificate of the certificate authority.
+func (service *Service) CACertificate() (string, error) {
+	if service.disabled {
+		return "", api.ErrPKIDisabled
+	}
+
+	service.mu.Lock()
+	defer service.mu.Unlock()
+
+	err := service.loadOrCreateCA()
+	if err != nil {
+		return "", err
+	}
+	return string(service.caPEM), nil
+}
+
+// IssueServerCertificate issues a certificate that can be used by a Docker daemon to serve its API over TLS.
+// Hosts can be IP addresses or DNS names.
+func (service *Service) IssueServerCertificate(hosts []string, validityDays int) (*api.CertificateBundle, error) {
+	if len(hosts) == 0 {
+		return nil, api.ErrInvalidCertificateHost
+	}
+
+	template, err := newCertificateTemplate(hosts[0], validityDays)
+	if err != nil {
+		return nil, err
+	}
+	template.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}
+
+	for _, host := range hosts {
+		if ip := net.ParseIP(host); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else if host != "" {
+			template.DNSNames = append(template.DNSNames, host)
+		} else {
+			return nil, api.ErrInvalidCertificateHost
+		}
+	}
+
+	return service.issueCertificate(template)
+}
+
+// IssueEndpointCertificate issues a client certificate for an endpoint, stores it in the TLS folder
+// of the endpoint and updates its TLS configuration. The CA certificate used to verify the Docker API
+// of the endpoint is left untouched, the certificate of the certificate authority is only stored
+// in the folder when the endpoint does not define one. The endpoint is not persisted.
+func (service *Service) IssueEndpointCertificate(endpoint *api.Endpoint) error {
+	template, err := newCertificateTemplate("cloudware-endpoint-"+strconv.Itoa(int(endpoint.ID)), DefaultValidityDays)
+	if err != nil {
+		return err
+	}
+	template.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}
+
+	bundle, err := service.issueCertificate(template)
+	if err != nil {
+		return err
+	}
+
+	folder := strconv.Itoa(int(endpoint.ID))
+	files := []struct {
+		fileType api.TLSFileType
+		content  string
+	}{
+		{api.TLSFileCert, bundle.Cert},
+		{api.TLSFileKey, bundle.Key},
+	}
+
+	// An endpoint switching to TLS serves a certificate issued by the certificate authority
+	verifyWithCA := !endpoint.TLSConfig.TLS || (!endpoint.TLSConfig.TLSSkipVerify && endpoint.TLSConfig.TLSCACertPath == "")
+	if verifyWithCA {
+		files = append(files, struct {
+			fileType api.TLSFileType
+			content  string
+		}{api.TLSFileCA, bundle.CACert})
+	}
+
+	for _, f := range files {
+		err = service.fileService.StoreTLSFile(folder, f.fileType, bytes.NewReader([]byte(f.content)))
+		if err != nil {
+			return err
+		}
+	}
+
+	if verifyWithCA {
+		endpoint.TLSConfig.TLSSkipVerify = false
+		endpoint.TLSConfig.TLSCACertPath, _ = service.fileService.GetPathForTLSFile(folder, api.TLSFileCA)
+	}
+	endpoint.TLSConfig.TLS = true
+	endpoint.TLSConfig.TLSCertPath, _ = service.fileService.GetPathForTLSFile(folder, api.TLSFileCert)
+	endpoint.TLSConfig.TLSKeyPath, _ = service.fileService.GetPathForTLSFile(folder, api.TLSFileKey)
+	endpoint.ManagedCertificates = true
+	return nil
+}
+
+// CertificateInfo returns the details of the first certificate stored in a PEM file.
+func (service *Service) CertificateInfo(certificatePath string) (*api.CertificateInfo, error) {
+	data, err := ioutil.ReadFile(certificatePath)
+	if err != nil {
+		return nil, err
+	}
+
+	certificate, err := parseCertificate(data)
+	if err != nil {
+		return nil, err
+	}
+
+	return &api.CertificateInfo{
+		Path:     certificatePath,
+		Subject:  certificate.Subject.CommonName,
+		Issuer:   certificate.Issuer.CommonName,
+		NotAfter: certificate.NotAfter.Unix(),
+		Expired:  time.Now().After(certificate.NotAfter),
+	}, nil
+}
+
+func (service *Service) issueCertificate(template *x509.Certificate) (*api.CertificateBundle, error) {
+	if service.disabled {
+		return nil, api.ErrPKIDisabled
+	}
+
+	service.mu.Lock()
+	defer service.mu.Unlock()
+
+	err := service.loadOrCreateCA()
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, service.caCert, &key.PublicKey, service.caKey)
+	if err != nil {
+		return nil, err
+	}
+
+	keyPEM, err := encodeKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return &api.CertificateBundle{
+		CACert: string(service.caPEM),
+		Cert:   string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})),
+		Key:    string(keyPEM),
+	}, nil
+}
+
+// loadOrCreateCA loads the certificate authority from the file store, it is created when it does not exist.
+// It is loaded again when its files are replaced, as when a backup is restored.
+func (service *Service) loadOrCreateCA() error {
+	certPath, err := service.fileService.GetPathForTLSFile(file.PKIStorePath, api.TLSFileCA)
+	if err != nil {
+		return err
+	}
+	keyPath, err := service.fileService.GetPathForTLSFile(file.PKIStorePath, api.TLSFileKey)
+	if err != nil {
+		return err
+	}
+
+	info, err := os.Stat(certPath)
+	if os.IsNotExist(err) {
+		return service.createCA()
+	} else if err != nil {
+		return err
+	}
+	if service.caCert != nil && service.caFile != nil && os.SameFile(info, service.caFile) && info.ModTime().Equal(service.caFile.ModTime()) {
+		return nil
+	}
+
+	certPEM, err := ioutil.ReadFile(certPath)
+	if err != nil {
+		return err
+	}
+
+	keyPEM, err := ioutil.ReadFile(keyPath)
+	if err != nil {
+		return err
+	}
+
+	certificate, err := parseCertificate(certPEM)
+	if err != nil {
+		return err
+	}
+
+	block, _ := pem.Decode(keyPEM)
+	if block == nil {
+		return api.ErrInvalidCertificate
+	}
+	key, err := x509.ParseECPrivateKey(block.Bytes)
+	if err != nil {
+		return err
+	}
+
+	service.caCert = certificate
+	service.caKey = key
+	service.caPEM = certPEM
+	service.caFile = info
+	return nil
+}
+
+func (service *Service) createCA() error {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return err
+	}
+
+	template, err := newCertificateTemplate(caCommonName, caValidityDays)
+	if err != nil {
+		return err
+	}
+	template.IsCA = true
+	template.BasicConstraintsValid = true
+	template.KeyUsage = x509.KeyUsageCertSign | x509.KeyUsageCRLSign | x509.KeyUsageDigitalSignature
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return err
+	}
+
+	certificate, err := x509.ParseCertificate(der)
+	if err != nil {
+		return err
+	}
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM, err := encodeKey(key)
+	if err != nil {
+		return err
+	}
+
+	err = service.fileService.StoreTLSFile(file.PKIStorePath, api.TLSFileKey, bytes.NewReader(keyPEM))
+	if err != nil {
+		return err
+	}
+	err = service.fileService.StoreTLSFile(file.PKIStorePath, api.TLSFileCA, bytes.NewReader(certPEM))
+	if err != nil {
+		return err
+	}
+
+	service.caCert = certificate
+	service.caKey = key
+	service.caPEM = certPEM
+	return nil
+}
+
+func newCertificateTemplate(commonName string, validityDays int) (*x509.Certificate, error) {
+	if validityDays <= 0 {
+		validityDays = DefaultValidityDays
+	}
+
+	serialNumber, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), serialNumberBits))
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	return &x509.Certificate{
+		SerialNumber: serialNumber,
+		Subject: pkix.Name{
+			CommonName:   commonName,
+			Organization: []string{"Cloudware"},
+		},
+		NotBefore: now.Add(-5 * time.Minute),
+		NotAfter:  now.AddDate(0, 0, validityDays),
+		KeyUsage:  x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+	}, nil
+}
+
+func parseCertificate(data []byte) (*x509.Certificate, error) {
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil, api.ErrInvalidCertificate
+	}
+	return x509.ParseCertificate(block.Bytes)
+}
+
+func encodeKey(key *ecdsa.PrivateKey) ([]byte, error) {
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}), nil
+}
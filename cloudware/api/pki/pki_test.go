@@ -0,0 +1,245 @@
+package pki
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"cloudware/cloudware/api"
+	"cloudware/cloudware/api/file"
+)
+
+// newFileService returns a file service storing its files in a temporary directory.
+func newFileService(t *testing.T) (*file.Service, func()) {
+	dataPath, err := ioutil.TempDir("", "pki")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fileService, err := file.NewService(dataPath, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return fileService, func() {
+		os.RemoveAll(dataPath)
+	}
+}
+
+// verifyCertificate checks that a certificate is signed by the certificate authority for the specified usage.
+func verifyCertificate(t *testing.T, caPEM, certPEM, keyPEM string, usage x509.ExtKeyUsage, dnsName string) {
+	pair, err := tls.X509KeyPair([]byte(certPEM), []byte(keyPEM))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certificate, err := x509.ParseCertificate(pair.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM([]byte(caPEM)) {
+		t.Fatal("unable to read the certificate authority")
+	}
+
+	_, err = certificate.Verify(x509.VerifyOptions{Roots: roots, DNSName: dnsName, KeyUsages: []x509.ExtKeyUsage{usage}})
+	if err != nil {
+		t.Errorf("expected the certificate to be verified by the certificate authority: %s", err)
+	}
+}
+
+func TestIssueServerCertificate(t *testing.T) {
+	fileService, cleanup := newFileService(t)
+	defer cleanup()
+
+	service := NewService(fileService, true)
+	bundle, err := service.IssueServerCertificate([]string{"docker.example.com", "10.0.0.1"}, 30)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	verifyCertificate(t, bundle.CACert, bundle.Cert, bundle.Key, x509.ExtKeyUsageServerAuth, "docker.example.com")
+	verifyCertificate(t, bundle.CACert, bundle.Cert, bundle.Key, x509.ExtKeyUsageServerAuth, "10.0.0.1")
+
+	for _, hosts := range [][]string{nil, {"docker.example.com", ""}} {
+		_, err = service.IssueServerCertificate(hosts, 30)
+		if err != api.ErrInvalidCertificateHost {
+			t.Errorf("%v: expected %q, got %v", hosts, api.ErrInvalidCertificateHost, err)
+		}
+	}
+}
+
+func TestCertificateAuthorityIsPersisted(t *testing.T) {
+	fileService, cleanup := newFileService(t)
+	defer cleanup()
+
+	caCert, err := NewService(fileService, true).CACertificate()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	service := NewService(fileService, true)
+	reloadedCACert, err := service.CACertificate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reloadedCACert != caCert {
+		t.Error("expected the stored certificate authority to be reused")
+	}
+
+	bundle, err := service.IssueServerCertificate([]string{"docker.example.com"}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifyCertificate(t, caCert, bundle.Cert, bundle.Key, x509.ExtKeyUsageServerAuth, "docker.example.com")
+}
+
+func TestIssueEndpointCertificate(t *testing.T) {
+	fileService, cleanup := newFileService(t)
+	defer cleanup()
+
+	service := NewService(fileService, true)
+	caCert, err := service.CACertificate()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	uploadedCA := []byte("uploaded CA")
+	err = fileService.StoreTLSFile("2", api.TLSFileCA, bytes.NewReader(uploadedCA))
+	if err != nil {
+		t.Fatal(err)
+	}
+	uploadedCAPath, _ := fileService.GetPathForTLSFile("2", api.TLSFileCA)
+
+	for _, test := range []struct {
+		name                  string
+		endpoint              *api.Endpoint
+		expectedCA            []byte
+		expectedTLSSkipVerify bool
+	}{
+		{
+			name:       "endpoint without TLS",
+			endpoint:   &api.Endpoint{ID: 1, TLSConfig: api.TLSConfiguration{TLSSkipVerify: true}},
+			expectedCA: []byte(caCert),
+		},
+		{
+			name:       "endpoint with an uploaded CA",
+			endpoint:   &api.Endpoint{ID: 2, TLSConfig: api.TLSConfiguration{TLS: true, TLSCACertPath: uploadedCAPath}},
+			expectedCA: uploadedCA,
+		},
+		{
+			name:                  "endpoint skipping the server verification",
+			endpoint:              &api.Endpoint{ID: 3, TLSConfig: api.TLSConfiguration{TLS: true, TLSSkipVerify: true}},
+			expectedTLSSkipVerify: true,
+		},
+	} {
+		err := service.IssueEndpointCertificate(test.endpoint)
+		if err != nil {
+			t.Fatalf("%s: %s", test.name, err)
+		}
+
+		config := test.endpoint.TLSConfig
+		if !config.TLS || !test.endpoint.ManagedCertificates {
+			t.Errorf("%s: expected TLS with managed certificates, got %+v", test.name, test.endpoint)
+		}
+		if config.TLSSkipVerify != test.expectedTLSSkipVerify {
+			t.Errorf("%s: expected TLSSkipVerify to be %v", test.name, test.expectedTLSSkipVerify)
+		}
+
+		folder := filepath.Dir(config.TLSCertPath)
+		if filepath.Dir(config.TLSKeyPath) != folder || filepath.Base(folder) != strconv.Itoa(int(test.endpoint.ID)) {
+			t.Errorf("%s: expected the certificate to be stored in the folder of the endpoint, got %s and %s", test.name, config.TLSCertPath, config.TLSKeyPath)
+		}
+
+		if test.expectedCA == nil {
+			if config.TLSCACertPath != "" {
+				t.Errorf("%s: expected no CA certificate, got %s", test.name, config.TLSCACertPath)
+			}
+		} else {
+			content, err := ioutil.ReadFile(config.TLSCACertPath)
+			if err != nil {
+				t.Fatalf("%s: %s", test.name, err)
+			}
+			if !bytes.Equal(content, test.expectedCA) {
+				t.Errorf("%s: expected the CA certificate %q, got %q", test.name, test.expectedCA, content)
+			}
+		}
+
+		certPEM, _ := ioutil.ReadFile(config.TLSCertPath)
+		keyPEM, _ := ioutil.ReadFile(config.TLSKeyPath)
+		verifyCertificate(t, caCert, string(certPEM), string(keyPEM), x509.ExtKeyUsageClientAuth, "")
+	}
+}
+
+func TestRenewEndpointCertificate(t *testing.T) {
+	fileService, cleanup := newFileService(t)
+	defer cleanup()
+
+	service := NewService(fileService, true)
+	endpoint := &api.Endpoint{ID: 1}
+	err := service.IssueEndpointCertificate(endpoint)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	firstCert, _ := ioutil.ReadFile(endpoint.TLSConfig.TLSCertPath)
+	caPath := endpoint.TLSConfig.TLSCACertPath
+
+	err = service.IssueEndpointCertificate(endpoint)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	secondCert, _ := ioutil.ReadFile(endpoint.TLSConfig.TLSCertPath)
+	if bytes.Equal(firstCert, secondCert) {
+		t.Error("expected a new certificate to be issued")
+	}
+	if endpoint.TLSConfig.TLSCACertPath != caPath || endpoint.TLSConfig.TLSSkipVerify {
+		t.Errorf("expected the server verification to be unchanged, got %+v", endpoint.TLSConfig)
+	}
+
+	info, err := service.CertificateInfo(endpoint.TLSConfig.TLSCertPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Subject != "cloudware-endpoint-1" || info.Issuer != caCommonName || info.Expired {
+		t.Errorf("unexpected certificate details %+v", info)
+	}
+}
+
+func TestDisabledService(t *testing.T) {
+	fileService, cleanup := newFileService(t)
+	defer cleanup()
+
+	service := NewService(fileService, false)
+
+	_, err := service.CACertificate()
+	if err != api.ErrPKIDisabled {
+		t.Errorf("expected %q, got %v", api.ErrPKIDisabled, err)
+	}
+
+	_, err = service.IssueServerCertificate([]string{"docker.example.com"}, 30)
+	if err != api.ErrPKIDisabled {
+		t.Errorf("expected %q, got %v", api.ErrPKIDisabled, err)
+	}
+
+	endpoint := &api.Endpoint{ID: 1}
+	err = service.IssueEndpointCertificate(endpoint)
+	if err != api.ErrPKIDisabled {
+		t.Errorf("expected %q, got %v", api.ErrPKIDisabled, err)
+	}
+	if endpoint.TLSConfig.TLS || endpoint.ManagedCertificates {
+		t.Errorf("expected the endpoint to be left untouched, got %+v", endpoint)
+	}
+
+	caPath, _ := fileService.GetPathForTLSFile(file.PKIStorePath, api.TLSFileCA)
+	if _, err := os.Stat(caPath); !os.IsNotExist(err) {
+		t.Error("expected no certificate authority to be created")
+	}
+}
@@ -0,0 +1,149 @@
+package api
+
+import "strings"
+
+// Sort fields of the lists, each list supports the identifier and the name
+// along with the fields listed in its query.
+const (
+	SortByID   = "id"
+	SortByName = "name"
+	SortByURL  = "url"
+	SortByType = "type"
+)
+
+type (
+	// ListOptions selects a page of a list. The objects are sorted by identifier unless
+	// Sort is set, all the objects from Offset are returned when Limit is 0. Search
+	// restricts the list to the objects whose name contains it, ignoring the case.
+	ListOptions struct {
+		Offset     int
+		Limit      int
+		Search     string
+		Sort       string
+		Descending bool
+	}
+
+	// AccessFilter restricts a list to the objects a user is authorized to access, either
+	// directly or through one of the teams of the user.
+	AccessFilter struct {
+		UserID  UserID
+		TeamIDs []TeamID
+	}
+
+	// UserQuery selects users, the name of a user is the username. Role restricts the list
+	// to a role when set, ExcludeAdministrators hides the administrators.
+	UserQuery struct {
+		ListOptions
+		Role                  UserRole
+		ExcludeAdministrators bool
+	}
+
+	// TeamQuery selects teams, the list is restricted to TeamIDs unless it is nil.
+	TeamQuery struct {
+		ListOptions
+		TeamIDs []TeamID
+	}
+
+	// EndpointQuery selects endpoints, they can be sorted by URL. URL restricts the
+	// list to the endpoints with this URL when set.
+	EndpointQuery struct {
+		ListOptions
+		URL    string
+		Access *AccessFilter
+	}
+
+	// RegistryQuery selects registries, they can be sorted by URL. URL restricts the
+	// list to the registries with this URL when set.
+	RegistryQuery struct {
+		ListOptions
+		URL    string
+		Access *AccessFilter
+	}
+
+	// ResourceControlQuery selects resource controls, the name of a resource control is
+	// the identifier of its resource. They can be sorted by type, Type restricts the list
+	// to a type when set.
+	ResourceControlQuery struct {
+		ListOptions
+		Type   ResourceControlType
+		Access *AccessFilter
+	}
+
+	// TemplateQuery selects templates, the name of a template is its title. They can be sorted
+	// by type, Type and Category restrict the list when set. Access restricts the list to the
+	// templates visible to every user and to the ones restricted to a team of the user.
+	TemplateQuery struct {
+		ListOptions
+		Type     TemplateType
+		Category string
+		Access   *AccessFilter
+	}
+
+	// StackQuery selects stacks. SwarmID restricts the list to the stacks of a swarm when set,
+	// Access restricts it to the stacks without a resource control or with a resource
+	// control granting access to the user.
+	StackQuery struct {
+		ListOptions
+		SwarmID string
+		Access  *AccessFilter
+	}
+)
+
+// MatchName reports whether a name contains the searched text, ignoring the case.
+func (options *ListOptions) MatchName(name string) bool {
+	return options.Search == "" || strings.Contains(strings.ToLower(name), strings.ToLower(options.Search))
+}
+
+// InPage reports whether the object at an index of the sorted list belongs to the page.
+func (options *ListOptions) InPage(index int) bool {
+	return index >= options.Offset && (options.Limit == 0 || index < options.Offset+options.Limit)
+}
+
+// Bounds returns the bounds of the page within a sorted list of count objects.
+func (options *ListOptions) Bounds(count int) (int, int) {
+	start := options.Offset
+	if start > count {
+		start = count
+	}
+	end := count
+	if options.Limit > 0 && start+options.Limit < count {
+		end = start + options.Limit
+	}
+	return start, end
+}
+
+// Authorized reports whether the user or one of the teams of the user is part of
+// the authorized users and teams of an object.
+func (access *AccessFilter) Authorized(users []UserID, teams []TeamID) bool {
+	for _, userID := range users {
+		if userID == access.UserID {
+			return true
+		}
+	}
+	for _, teamID := range teams {
+		for _, userTeamID := range access.TeamIDs {
+			if teamID == userTeamID {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// AuthorizedResourceControl reports whether a resource control grants access to the user
+// or to one of the teams of the user.
+func (access *AccessFilter) AuthorizedResourceControl(resourceControl *ResourceControl) bool {
+	for _, userAccess := range resourceControl.UserAccesses {
+		if userAccess.UserID == access.UserID {
+			return true
+		}
+	}
+	for _, teamAccess := range resourceControl.TeamAccesses {
+		for _, teamID := range access.TeamIDs {
+			if teamAccess.TeamID == teamID {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,212 @@
+package recorder
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+
+	"cloudware/cloudware/api"
+)
+
+const (
+	// ErrInvalidRecording defines an error raised when a recording does not follow the asciicast v2 format.
+	ErrInvalidRecording = api.Error("Invalid asciicast recording")
+	asciicastVersion    = 2
+	outputEvent         = "o"
+	inputEvent          = "i"
+	defaultWidth        = 80
+	defaultHeight       = 24
+	maxEventSize        = 1024 * 1024
+)
+
+type (
+	// Header represents the first line of an asciicast v2 file.
+	// Format reference: https://github.com/asciinema/asciinema/blob/develop/doc/asciicast-v2.md
+	Header struct {
+		Version   int               `json:"version"`
+		Width     int               `json:"width"`
+		Height    int               `json:"height"`
+		Timestamp int64             `json:"timestamp"`
+		Title     string            `json:"title,omitempty"`
+		Env       map[string]string `json:"env,omitempty"`
+	}
+
+	// Event represents a single input or output event of an asciicast v2 file.
+	Event struct {
+		Time float64
+		Type string
+		Data string
+	}
+
+	// Recorder writes the streams of an interactive session to an asciicast v2 stream.
+	Recorder struct {
+		mu      sync.Mutex
+		encoder *json.Encoder
+		start   time.Time
+		err     error
+	}
+)
+
+// NewRecorder initializes a new Recorder and writes the asciicast header to w.
+func NewRecorder(w io.Writer, title string) (*Recorder, error) {
+	recorder := &Recorder{
+		encoder: json.NewEncoder(w),
+		start:   time.Now(),
+	}
+
+	header := &Header{
+		Version:   asciicastVersion,
+		Width:     defaultWidth,
+		Height:    defaultHeight,
+		Timestamp: recorder.start.Unix(),
+		Title:     title,
+		Env: map[string]string{
+			"TERM": "xterm",
+		},
+	}
+
+	err := recorder.encoder.Encode(header)
+	if err != nil {
+		return nil, err
+	}
+
+	return recorder, nil
+}
+
+// Output returns a writer that records everything written to w as output events.
+func (recorder *Recorder) Output(w io.Writer) io.Writer {
+	return &recordingWriter{recorder: recorder, writer: w}
+}
+
+// Input returns a reader that records everything read from r as input events.
+func (recorder *Recorder) Input(r io.Reader) io.Reader {
+	return &recordingReader{recorder: recorder, reader: r}
+}
+
+// Duration returns the time elapsed since the beginning of the recording.
+func (recorder *Recorder) Duration() time.Duration {
+	return time.Since(recorder.start)
+}
+
+// Err returns the first error that occurred while writing events, if any.
+func (recorder *Recorder) Err() error {
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+	return recorder.err
+}
+
+func (recorder *Recorder) record(eventType string, data []byte) {
+	elapsed := time.Since(recorder.start).Seconds()
+
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+
+	// Once the underlying writer failed, stop recording but never
+	// interrupt the session itself.
+	if recorder.err != nil {
+		return
+	}
+	recorder.err = recorder.encoder.Encode([]interface{}{elapsed, eventType, string(data)})
+}
+
+type recordingWriter struct {
+	recorder *Recorder
+	writer   io.Writer
+}
+
+func (w *recordingWriter) Write(p []byte) (int, error) {
+	n, err := w.writer.Write(p)
+	if n > 0 {
+		w.recorder.record(outputEvent, p[:n])
+	}
+	return n, err
+}
+
+type recordingReader struct {
+	recorder *Recorder
+	reader   io.Reader
+}
+
+func (r *recordingReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	if n > 0 {
+		r.recorder.record(inputEvent, p[:n])
+	}
+	return n, err
+}
+
+// Replay reads an asciicast v2 stream from r and writes the output events to w,
+// respecting the original timing of the session divided by speed.
+// The optional flush function is called after each event.
+func Replay(w io.Writer, r io.Reader, speed float64, flush func()) error {
+	if speed <= 0 {
+		speed = 1
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxEventSize)
+
+	if !scanner.Scan() {
+		return ErrInvalidRecording
+	}
+
+	var header Header
+	err := json.Unmarshal(scanner.Bytes(), &header)
+	if err != nil || header.Version != asciicastVersion {
+		return ErrInvalidRecording
+	}
+
+	var elapsed float64
+	for scanner.Scan() {
+		event, err := parseEvent(scanner.Bytes())
+		if err != nil {
+			return err
+		}
+
+		if event.Type != outputEvent {
+			continue
+		}
+
+		delay := (event.Time - elapsed) / speed
+		if delay > 0 {
+			time.Sleep(time.Duration(delay * float64(time.Second)))
+		}
+		elapsed = event.Time
+
+		_, err = io.WriteString(w, event.Data)
+		if err != nil {
+			return err
+		}
+		if flush != nil {
+			flush()
+		}
+	}
+
+	return scanner.Err()
+}
+
+// parseEvent decodes an event line. Events are stored as [time, type, data] JSON arrays.
+func parseEvent(line []byte) (*Event, error) {
+	var fields []interface{}
+	err := json.Unmarshal(line, &fields)
+	if err != nil || len(fields) != 3 {
+		return nil, ErrInvalidRecording
+	}
+
+	eventTime, ok := fields[0].(float64)
+	if !ok {
+		return nil, ErrInvalidRecording
+	}
+	eventType, ok := fields[1].(string)
+	if !ok {
+		return nil, ErrInvalidRecording
+	}
+	eventData, ok := fields[2].(string)
+	if !ok {
+		return nil, ErrInvalidRecording
+	}
+
+	return &Event{Time: eventTime, Type: eventType, Data: eventData}, nil
+}
@@ -0,0 +1,127 @@
+package recorder
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRecordAndReplay(t *testing.T) {
+	var cast bytes.Buffer
+	recorder, err := NewRecorder(&cast, "alice@local:web")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	input, err := ioutil.ReadAll(recorder.Input(strings.NewReader("ls\n")))
+	if err != nil || string(input) != "ls\n" {
+		t.Fatalf("expected the input to be passed through, got %q, %v", input, err)
+	}
+	time.Sleep(20 * time.Millisecond)
+	var output bytes.Buffer
+	_, err = recorder.Output(&output).Write([]byte("file\n"))
+	if err != nil || output.String() != "file\n" {
+		t.Fatalf("expected the output to be passed through, got %q, %v", output.String(), err)
+	}
+	if recorder.Err() != nil {
+		t.Fatal(recorder.Err())
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(cast.Bytes()))
+	if !scanner.Scan() {
+		t.Fatal("expected a header")
+	}
+	var header Header
+	err = json.Unmarshal(scanner.Bytes(), &header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if header.Version != 2 || header.Width != defaultWidth || header.Height != defaultHeight ||
+		header.Title != "alice@local:web" || header.Timestamp == 0 || header.Env["TERM"] != "xterm" {
+		t.Errorf("unexpected header %+v", header)
+	}
+
+	events := make([]*Event, 0)
+	for scanner.Scan() {
+		event, err := parseEvent(scanner.Bytes())
+		if err != nil {
+			t.Fatal(err)
+		}
+		events = append(events, event)
+	}
+	if len(events) != 2 || events[0].Type != inputEvent || events[0].Data != "ls\n" ||
+		events[1].Type != outputEvent || events[1].Data != "file\n" {
+		t.Fatalf("unexpected events %+v", events)
+	}
+	if events[1].Time-events[0].Time < 0.02 {
+		t.Errorf("expected the events to be recorded with their timing, got %f and %f", events[0].Time, events[1].Time)
+	}
+
+	var replayed bytes.Buffer
+	flushes := 0
+	err = Replay(&replayed, &cast, 1, func() { flushes++ })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if replayed.String() != "file\n" || flushes != 1 {
+		t.Errorf("expected only the output to be replayed, got %q with %d flushes", replayed.String(), flushes)
+	}
+}
+
+func TestReplayTiming(t *testing.T) {
+	cast := `{"version":2,"width":80,"height":24}
+[0.1,"o","a"]
+[0.2,"i","x"]
+[0.3,"o","b"]
+`
+	start := time.Now()
+	var replayed bytes.Buffer
+	err := Replay(&replayed, strings.NewReader(cast), 2, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	elapsed := time.Since(start)
+
+	if replayed.String() != "ab" {
+		t.Errorf("unexpected output %q", replayed.String())
+	}
+	if elapsed < 150*time.Millisecond || elapsed > time.Second {
+		t.Errorf("expected the replay to last half of the session, got %s", elapsed)
+	}
+}
+
+func TestReplayInvalidRecordings(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		cast     string
+		replayed string
+	}{
+		{"empty", ``, ""},
+		{"invalid header", "{\"version\":\n", ""},
+		{"unsupported version", "{\"version\":1}\n", ""},
+		{"truncated event", "{\"version\":2}\n[0,\"o\",\"a\"]\n[0,\"o\",\"b", "a"},
+		{"event with two fields", "{\"version\":2}\n[0,\"o\"]\n", ""},
+		{"event with a wrong time", "{\"version\":2}\n[\"0\",\"o\",\"a\"]\n", ""},
+		{"event with a wrong type", "{\"version\":2}\n[0,1,\"a\"]\n", ""},
+		{"event with wrong data", "{\"version\":2}\n[0,\"o\",1]\n", ""},
+	} {
+		var replayed bytes.Buffer
+		err := Replay(&replayed, strings.NewReader(test.cast), 1, nil)
+		if err != ErrInvalidRecording {
+			t.Errorf("%s: expected the recording to be rejected, got %v", test.name, err)
+		}
+		if replayed.String() != test.replayed {
+			t.Errorf("%s: expected %q to be replayed before the error, got %q", test.name, test.replayed, replayed.String())
+		}
+	}
+
+	long := "{\"version\":2}\n[0,\"o\",\"" + strings.Repeat("a", maxEventSize) + "\"]\n"
+	err := Replay(ioutil.Discard, strings.NewReader(long), 1, nil)
+	if err != bufio.ErrTooLong {
+		t.Errorf("expected an event exceeding the maximum size to be rejected, got %v", err)
+	}
+}
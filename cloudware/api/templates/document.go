@@ -0,0 +1,194 @@
+package templates
+
+import (
+	"encoding/json"
+	"strings"
+
+	"cloudware/cloudware/api"
+)
+
+type (
+	// document is a templates document, either a list of templates or an object
+	// holding the list of templates along with the version of the format.
+	document struct {
+		Version   string     `json:"version"`
+		Templates []template `json:"templates"`
+	}
+
+	template struct {
+		Type          json.RawMessage `json:"type"`
+		Title         string          `json:"title"`
+		Description   string          `json:"description"`
+		Note          string          `json:"note"`
+		Categories    []string        `json:"categories"`
+		Platform      string          `json:"platform"`
+		Logo          string          `json:"logo"`
+		Registry      string          `json:"registry"`
+		Image         string          `json:"image"`
+		Command       string          `json:"command"`
+		Env           []parameter     `json:"env"`
+		Ports         []string        `json:"ports"`
+		Volumes       []volume        `json:"volumes"`
+		Labels        []api.Pair      `json:"labels"`
+		RestartPolicy string          `json:"restart_policy"`
+		Repository    struct {
+			URL       string `json:"url"`
+			StackFile string `json:"stackfile"`
+		} `json:"repository"`
+	}
+
+	parameter struct {
+		Name        string `json:"name"`
+		Label       string `json:"label"`
+		Description string `json:"description"`
+		Default     string `json:"default"`
+		Preset      bool   `json:"preset"`
+		Select      []struct {
+			Text    string `json:"text"`
+			Value   string `json:"value"`
+			Default bool   `json:"default"`
+		} `json:"select"`
+	}
+
+	// volume is either the path of a volume in the container, or an object
+	// binding a path of the host to a path of the container.
+	volume struct {
+		Container string `json:"container"`
+		Bind      string `json:"bind"`
+		ReadOnly  bool   `json:"readonly"`
+	}
+)
+
+// Types of the templates in the documents, the compose stacks are not supported.
+const (
+	documentContainerType  = 1
+	documentSwarmStackType = 2
+)
+
+func (v *volume) UnmarshalJSON(data []byte) error {
+	if len(data) > 0 && data[0] == '"' {
+		return json.Unmarshal(data, &v.Container)
+	}
+	type object volume
+	return json.Unmarshal(data, (*object)(v))
+}
+
+func (v *volume) String() string {
+	if v.Bind == "" {
+		return v.Container
+	}
+	binding := v.Bind + ":" + v.Container
+	if v.ReadOnly {
+		binding += ":ro"
+	}
+	return binding
+}
+
+// Parse parses a templates document. The templates of an unsupported type are skipped.
+func Parse(content []byte) ([]api.Template, error) {
+	var doc document
+	trimmed := strings.TrimSpace(string(content))
+	if strings.HasPrefix(trimmed, "[") {
+		err := json.Unmarshal(content, &doc.Templates)
+		if err != nil {
+			return nil, api.ErrInvalidTemplatesDocument
+		}
+	} else {
+		err := json.Unmarshal(content, &doc)
+		if err != nil || doc.Templates == nil {
+			return nil, api.ErrInvalidTemplatesDocument
+		}
+	}
+
+	templates := make([]api.Template, 0, len(doc.Templates))
+	for _, t := range doc.Templates {
+		templateType := parseType(t.Type)
+		if templateType == 0 || t.Title == "" {
+			continue
+		}
+
+		image := t.Image
+		if t.Registry != "" && image != "" {
+			image = strings.TrimSuffix(t.Registry, "/") + "/" + image
+		}
+
+		volumes := make([]string, 0, len(t.Volumes))
+		for _, v := range t.Volumes {
+			volumes = append(volumes, v.String())
+		}
+
+		templates = append(templates, api.Template{
+			Type:          templateType,
+			Title:         t.Title,
+			Description:   t.Description,
+			Note:          t.Note,
+			Categories:    t.Categories,
+			Platform:      t.Platform,
+			Logo:          t.Logo,
+			Image:         image,
+			Command:       t.Command,
+			Ports:         t.Ports,
+			Volumes:       volumes,
+			Labels:        t.Labels,
+			RestartPolicy: t.RestartPolicy,
+			Repository: api.TemplateRepository{
+				URL:       t.Repository.URL,
+				StackFile: t.Repository.StackFile,
+			},
+			Parameters:      parseParameters(t.Env),
+			AuthorizedTeams: []api.TeamID{},
+		})
+	}
+	return templates, nil
+}
+
+// parseType parses the type of a template, it is a number in the recent documents
+// and a name in the older ones. It returns 0 for the unsupported types.
+func parseType(data json.RawMessage) api.TemplateType {
+	var name string
+	if json.Unmarshal(data, &name) == nil {
+		switch name {
+		case "container":
+			return api.ContainerTemplate
+		case "stack":
+			return api.StackTemplate
+		}
+		return 0
+	}
+
+	var number int
+	if json.Unmarshal(data, &number) == nil {
+		switch number {
+		case documentContainerType:
+			return api.ContainerTemplate
+		case documentSwarmStackType:
+			return api.StackTemplate
+		}
+	}
+	return 0
+}
+
+// parseParameters converts the environment variables of a template to parameters.
+// A preset variable can only take its default value.
+func parseParameters(env []parameter) []api.TemplateParameter {
+	parameters := make([]api.TemplateParameter, 0, len(env))
+	for _, e := range env {
+		p := api.TemplateParameter{
+			Name:        e.Name,
+			Label:       e.Label,
+			Description: e.Description,
+			Default:     e.Default,
+		}
+		for _, option := range e.Select {
+			p.Options = append(p.Options, option.Value)
+			if option.Default {
+				p.Default = option.Value
+			}
+		}
+		if e.Preset && len(p.Options) == 0 {
+			p.Options = []string{p.Default}
+		}
+		parameters = append(parameters, p)
+	}
+	return parameters
+}
@@ -0,0 +1,147 @@
+package templates
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"cloudware/cloudware/api"
+)
+
+const (
+	// DefaultPathInRepository is the path of the templates document in a Git repository when it is not specified.
+	DefaultPathInRepository = "templates.json"
+
+	fetchTimeout = 30 * time.Second
+	// maxDocumentSize caps the size of a templates document.
+	maxDocumentSize = 10 << 20
+)
+
+type (
+	// Source identifies a templates document, either by its URL or by its path in a Git repository.
+	Source struct {
+		URL              string
+		GitRepository    string
+		PathInRepository string
+	}
+
+	// Fetcher retrieves the templates documents. The last document retrieved from a source is
+	// cached, it is used when the source is unreachable.
+	Fetcher struct {
+		FileService api.FileService
+		GitService  api.GitService
+		Client      *http.Client
+	}
+)
+
+// NewFetcher initializes a new Fetcher.
+func NewFetcher(fileService api.FileService, gitService api.GitService) *Fetcher {
+	return &Fetcher{
+		FileService: fileService,
+		GitService:  gitService,
+		Client:      &http.Client{Timeout: fetchTimeout},
+	}
+}
+
+// String returns the identifier of a source, it is the source of the templates imported from it.
+func (source *Source) String() string {
+	if source.GitRepository == "" {
+		return source.URL
+	}
+	return source.GitRepository + "#" + source.pathInRepository()
+}
+
+func (source *Source) pathInRepository() string {
+	if source.PathInRepository == "" {
+		return DefaultPathInRepository
+	}
+	return source.PathInRepository
+}
+
+// Fetch returns the templates document of a source. The cached document is returned without
+// retrieving the source when it is more recent than maxAge, and when the source is unreachable
+// whatever its age; cached reports whether the cached document was returned.
+func (fetcher *Fetcher) Fetch(source *Source, maxAge time.Duration) (content []byte, cached bool, err error) {
+	identifier := source.String()
+
+	cachedContent, storedAt, cacheErr := fetcher.FileService.GetTemplatesCache(identifier)
+	if cacheErr != nil && !os.IsNotExist(cacheErr) {
+		logrus.WithError(cacheErr).WithField("source", identifier).Warn("Unable to read the cached templates")
+	}
+	hasCache := cacheErr == nil
+	if hasCache && maxAge > 0 && time.Since(storedAt) < maxAge {
+		return cachedContent, true, nil
+	}
+
+	content, err = fetcher.retrieve(source)
+	if err != nil {
+		if !hasCache {
+			logrus.WithError(err).WithField("source", identifier).Warn("Unable to retrieve the templates")
+			return nil, false, api.ErrTemplateSourceUnavailable
+		}
+		logrus.WithError(err).WithField("source", identifier).Warn("Unable to retrieve the templates, using the cached copy")
+		return cachedContent, true, nil
+	}
+
+	err = fetcher.FileService.StoreTemplatesCache(identifier, content)
+	if err != nil {
+		logrus.WithError(err).WithField("source", identifier).Warn("Unable to cache the templates")
+	}
+	return content, false, nil
+}
+
+func (fetcher *Fetcher) retrieve(source *Source) ([]byte, error) {
+	if source.GitRepository != "" {
+		return fetcher.retrieveFromRepository(source)
+	}
+
+	response, err := fetcher.Client.Get(source.URL)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d", response.StatusCode)
+	}
+	return readDocument(response.Body)
+}
+
+func (fetcher *Fetcher) retrieveFromRepository(source *Source) ([]byte, error) {
+	// The clone directory must not exist, it is created by git inside a temporary directory.
+	directory, err := ioutil.TempDir("", "templates")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(directory)
+
+	clonePath := path.Join(directory, "repository")
+	err = fetcher.GitService.CloneRepository(source.GitRepository, clonePath)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(path.Join(clonePath, path.Clean("/"+source.pathInRepository())))
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return readDocument(file)
+}
+
+func readDocument(r io.Reader) ([]byte, error) {
+	content, err := ioutil.ReadAll(io.LimitReader(r, maxDocumentSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(content) > maxDocumentSize {
+		return nil, api.ErrInvalidTemplatesDocument
+	}
+	return content, nil
+}
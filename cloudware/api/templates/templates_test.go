@@ -0,0 +1,87 @@
+package templates
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"cloudware/cloudware/api"
+	"cloudware/cloudware/api/file"
+)
+
+func TestParse(t *testing.T) {
+	templates, err := Parse([]byte(`[
+		{"type": "container", "title": "Nginx", "registry": "quay.io/", "image": "nginx",
+		 "volumes": ["/data", {"container": "/etc/nginx", "bind": "/srv/nginx", "readonly": true}],
+		 "env": [
+			{"name": "MODE", "select": [{"value": "dev"}, {"value": "prod", "default": true}]},
+			{"name": "USER", "default": "nginx", "preset": true}
+		 ]},
+		{"type": "stack", "title": "Elastic", "repository": {"url": "https://example.com/stacks", "stackfile": "elastic.yml"}},
+		{"type": "unknown", "title": "Skipped"}
+	]`))
+	require.NoError(t, err)
+	require.Len(t, templates, 2)
+
+	nginx := templates[0]
+	assert.Equal(t, api.ContainerTemplate, nginx.Type)
+	assert.Equal(t, "quay.io/nginx", nginx.Image)
+	assert.Equal(t, []string{"/data", "/srv/nginx:/etc/nginx:ro"}, nginx.Volumes)
+	require.Len(t, nginx.Parameters, 2)
+	assert.Equal(t, []string{"dev", "prod"}, nginx.Parameters[0].Options)
+	assert.Equal(t, "prod", nginx.Parameters[0].Default)
+	assert.Equal(t, []string{"nginx"}, nginx.Parameters[1].Options)
+
+	assert.Equal(t, api.StackTemplate, templates[1].Type)
+	assert.Equal(t, "elastic.yml", templates[1].Repository.StackFile)
+
+	templates, err = Parse([]byte(`{"version": "2", "templates": [{"type": 1, "title": "Redis", "image": "redis"}, {"type": 3, "title": "Compose"}]}`))
+	require.NoError(t, err)
+	require.Len(t, templates, 1)
+	assert.Equal(t, "Redis", templates[0].Title)
+
+	_, err = Parse([]byte(`{"version": "2"}`))
+	assert.Equal(t, api.ErrInvalidTemplatesDocument, err)
+}
+
+func TestFetchFallsBackToTheCache(t *testing.T) {
+	dataPath, err := ioutil.TempDir("", "templates")
+	require.NoError(t, err)
+	defer os.RemoveAll(dataPath)
+
+	fileService, err := file.NewService(dataPath, "")
+	require.NoError(t, err)
+	fetcher := NewFetcher(fileService, nil)
+
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Write([]byte(`[]`))
+	}))
+	source := &Source{URL: server.URL}
+
+	content, cached, err := fetcher.Fetch(source, 0)
+	require.NoError(t, err)
+	assert.False(t, cached)
+	assert.Equal(t, "[]", string(content))
+
+	_, cached, err = fetcher.Fetch(source, time.Hour)
+	require.NoError(t, err)
+	assert.True(t, cached)
+	assert.Equal(t, 1, requests, "a recent cached copy is served without retrieving the source")
+
+	server.Close()
+	content, cached, err = fetcher.Fetch(source, 0)
+	require.NoError(t, err)
+	assert.True(t, cached)
+	assert.Equal(t, "[]", string(content))
+
+	_, _, err = fetcher.Fetch(&Source{URL: server.URL + "/other"}, 0)
+	assert.Equal(t, api.ErrTemplateSourceUnavailable, err)
+}
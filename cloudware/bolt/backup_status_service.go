@@ -0,0 +1,51 @@
+package bolt
+
+import (
+	"cloudware/cloudware/api"
+	"cloudware/cloudware/bolt/internal"
+
+	"github.com/boltdb/bolt"
+)
+
+// BackupStatusService represents a service for managing the status of the scheduled backups.
+type BackupStatusService struct {
+	store *Store
+}
+
+const (
+	dbBackupStatusKey = "STATUS"
+)
+
+// BackupStatus returns the status of the scheduled backups. An empty status
+// is returned when no backup was scheduled yet.
+func (service *BackupStatusService) BackupStatus() (*api.BackupStatus, error) {
+	var status api.BackupStatus
+	err := service.store.view(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(backupStatusBucketName))
+		value := bucket.Get([]byte(dbBackupStatusKey))
+		if value == nil {
+			return nil
+		}
+
+		return internal.UnmarshalBackupStatus(value, &status)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &status, nil
+}
+
+// StoreBackupStatus persists the status of the scheduled backups.
+func (service *BackupStatusService) StoreBackupStatus(status *api.BackupStatus) error {
+	return service.store.update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(backupStatusBucketName))
+
+		data, err := internal.MarshalBackupStatus(status)
+		if err != nil {
+			return err
+		}
+
+		return bucket.Put([]byte(dbBackupStatusKey), data)
+	})
+}
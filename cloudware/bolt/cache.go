@@ -0,0 +1,55 @@
+package bolt
+
+import "sync"
+
+// maxCacheEntries is the maximum number of values kept by a cache. The keys of the lookups
+// come from the clients, the misses being cached as well the cache must not grow with them.
+const maxCacheEntries = 1024
+
+// cache is an in-memory cache of the lookups of a service. It is invalidated by
+// every write of the service.
+type cache struct {
+	mu         sync.RWMutex
+	generation uint64
+	entries    map[string]interface{}
+}
+
+func newCache() *cache {
+	return &cache{entries: make(map[string]interface{})}
+}
+
+// get returns the cached value of a key and the current generation of the cache.
+// The generation must be passed to set once the value has been read from the database.
+func (c *cache) get(key string) (interface{}, bool, uint64) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	value, ok := c.entries[key]
+	return value, ok, c.generation
+}
+
+// set caches a value unless the cache was invalidated since the generation was returned by get,
+// in which case the value may have been read before a write and is discarded.
+// An arbitrary value is evicted when the cache is full.
+func (c *cache) set(key string, value interface{}, generation uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if generation != c.generation {
+		return
+	}
+
+	if _, ok := c.entries[key]; !ok && len(c.entries) >= maxCacheEntries {
+		for evicted := range c.entries {
+			delete(c.entries, evicted)
+			break
+		}
+	}
+	c.entries[key] = value
+}
+
+// invalidate removes all the cached values.
+func (c *cache) invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.generation++
+	c.entries = make(map[string]interface{})
+}
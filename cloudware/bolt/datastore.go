@@ -1,13 +1,18 @@
 package bolt
 
 import (
-	"log"
+	"fmt"
+	"io"
 	"os"
+	"path/filepath"
+	"sync"
 	"time"
 
 	"github.com/boltdb/bolt"
+	"github.com/sirupsen/logrus"
 
 	"cloudware/cloudware/api"
+	"cloudware/cloudware/api/metrics"
 )
 
 // Store defines the implementation of api.DataStore using
@@ -27,7 +32,16 @@ type Store struct {
 	RegistryService        *RegistryService
 	DockerHubService       *DockerHubService
 	StackService           *StackService
+	RecordingService       *RecordingService
+	BackupStatusService    *BackupStatusService
+	TemplateService        *TemplateService
 
+	// EncryptionService is used to encrypt the secrets stored in the database,
+	// secrets are stored in clear text when it is not set.
+	EncryptionService api.EncryptionService
+
+	// mu guards db, which is replaced when the database is compacted or reopened.
+	mu                    sync.RWMutex
 	db                    *bolt.DB
 	checkForDataMigration bool
 }
@@ -44,22 +58,32 @@ const (
 	registryBucketName        = "registries"
 	dockerhubBucketName       = "dockerhub"
 	stackBucketName           = "stacks"
+	recordingBucketName       = "recordings"
+	backupStatusBucketName    = "backup_status"
+	templateBucketName        = "templates"
+
+	// unnumberedMigrationsDBVersion is the version of the data model reached by the migrations
+	// applied before the migrations were numbered.
+	unnumberedMigrationsDBVersion = 7
 )
 
 // NewStore initializes a new Store and the associated services
 func NewStore(storePath string) (*Store, error) {
 	store := &Store{
 		Path:                   storePath,
-		UserService:            &UserService{},
+		UserService:            &UserService{cache: newCache()},
 		TeamService:            &TeamService{},
-		TeamMembershipService:  &TeamMembershipService{},
+		TeamMembershipService:  &TeamMembershipService{cache: newCache()},
 		EndpointService:        &EndpointService{},
-		ResourceControlService: &ResourceControlService{},
+		ResourceControlService: &ResourceControlService{cache: newCache()},
 		VersionService:         &VersionService{},
 		SettingsService:        &SettingsService{},
 		RegistryService:        &RegistryService{},
 		DockerHubService:       &DockerHubService{},
 		StackService:           &StackService{},
+		RecordingService:       &RecordingService{},
+		BackupStatusService:    &BackupStatusService{},
+		TemplateService:        &TemplateService{},
 	}
 	store.UserService.store = store
 	store.TeamService.store = store
@@ -71,6 +95,9 @@ func NewStore(storePath string) (*Store, error) {
 	store.RegistryService.store = store
 	store.DockerHubService.store = store
 	store.StackService.store = store
+	store.RecordingService.store = store
+	store.BackupStatusService.store = store
+	store.TemplateService.store = store
 
 	_, err := os.Stat(storePath + "/" + databaseFileName)
 	if err != nil && os.IsNotExist(err) {
@@ -92,13 +119,23 @@ func (store *Store) Open() error {
 	if err != nil {
 		return err
 	}
+
+	store.mu.Lock()
 	store.db = db
+	// The database may have been replaced, cached lookups are discarded.
+	store.invalidateCaches()
+	store.mu.Unlock()
 
 	bucketsToCreate := []string{versionBucketName, userBucketName, teamBucketName, endpointBucketName,
 		resourceControlBucketName, teamMembershipBucketName, settingsBucketName,
-		registryBucketName, dockerhubBucketName, stackBucketName}
+		registryBucketName, dockerhubBucketName, stackBucketName, recordingBucketName, backupStatusBucketName,
+		templateBucketName}
 
-	return db.Update(func(tx *bolt.Tx) error {
+	return store.update(func(tx *bolt.Tx) error {
+		err := numberDBVersion(tx)
+		if err != nil {
+			return err
+		}
 
 		for _, bucket := range bucketsToCreate {
 			_, err := tx.CreateBucketIfNotExists([]byte(bucket))
@@ -107,43 +144,149 @@ func (store *Store) Open() error {
 			}
 		}
 
-		return nil
+		return rebuildIndexes(tx)
 	})
 }
 
+// OpenReadOnly opens the BoltDB database without initializing it, the database is not modified and
+// only the read operations can be used.
+func (store *Store) OpenReadOnly() error {
+	path := store.Path + "/" + databaseFileName
+
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: 1 * time.Second, ReadOnly: true})
+	if err != nil {
+		return err
+	}
+
+	store.mu.Lock()
+	store.db = db
+	store.invalidateCaches()
+	store.mu.Unlock()
+	return nil
+}
+
 // Close closes the BoltDB database.
 func (store *Store) Close() error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
 	if store.db != nil {
 		return store.db.Close()
 	}
 	return nil
 }
 
-// MigrateData automatically migrate the data based on the DBVersion.
-func (store *Store) MigrateData() error {
+// update executes fn in a read-write transaction, its duration is recorded in the metrics.
+func (store *Store) update(fn func(tx *bolt.Tx) error) error {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	start := time.Now()
+	var size int64
+	err := store.db.Update(func(tx *bolt.Tx) error {
+		err := fn(tx)
+		size = tx.Size()
+		return err
+	})
+	metrics.ObserveBoltTransaction(true, time.Since(start), size)
+	return err
+}
+
+// view executes fn in a read-only transaction, its duration is recorded in the metrics.
+func (store *Store) view(fn func(tx *bolt.Tx) error) error {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	start := time.Now()
+	err := store.db.View(fn)
+	metrics.ObserveBoltTransaction(false, time.Since(start), 0)
+	return err
+}
+
+// BackupDatabase calls fn with a consistent snapshot of the database taken in a read transaction.
+func (store *Store) BackupDatabase(fn func(size int64, snapshot io.WriterTo) error) error {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	return store.db.View(func(tx *bolt.Tx) error {
+		return fn(tx.Size(), tx)
+	})
+}
+
+// Replace closes the database and calls fn to replace its file, then opens the file found afterwards.
+// The transactions are blocked until the database is opened again, the new file must already be
+// migrated and indexed.
+func (store *Store) Replace(fn func() error) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	err := store.db.Close()
+	if err != nil {
+		return err
+	}
+
+	replaceErr := fn()
+
+	db, err := bolt.Open(store.Path+"/"+databaseFileName, 0600, &bolt.Options{Timeout: 1 * time.Second})
+	if err != nil {
+		return err
+	}
+	store.db = db
+	store.invalidateCaches()
+
+	return replaceErr
+}
+
+// Migrator returns a migrator bringing the database to the most recent data model. An error is returned
+// when the database was created by a more recent version of the application.
+func (store *Store) Migrator() (*Migrator, error) {
 	if !store.checkForDataMigration {
-		err := store.VersionService.StoreDBVersion(api.DBVersion)
-		if err != nil {
-			return err
-		}
-		return nil
+		return NewMigrator(store, api.DBVersion), nil
 	}
 
 	version, err := store.VersionService.DBVersion()
 	if err == api.ErrDBVersionNotFound {
 		version = 0
 	} else if err != nil {
+		return nil, err
+	}
+
+	if version > api.DBVersion {
+		return nil, api.ErrDBVersionTooRecent
+	}
+	return NewMigrator(store, version), nil
+}
+
+// MigrateData automatically migrate the data based on the DBVersion.
+// A copy of the database is created before the migrations are run.
+func (store *Store) MigrateData() error {
+	migrator, err := store.Migrator()
+	if err != nil {
 		return err
 	}
 
-	if version < api.DBVersion {
-		log.Printf("Migrating database from version %v to %v.\n", version, api.DBVersion)
-		migrator := NewMigrator(store, version)
+	if len(migrator.Plan()) > 0 {
+		backupPath := filepath.Join(store.Path, fmt.Sprintf("%s.v%d-%s.bak", databaseFileName, migrator.CurrentDBVersion, time.Now().Format("20060102-150405")))
+		err = store.view(func(tx *bolt.Tx) error {
+			return tx.CopyFile(backupPath, 0600)
+		})
+		if err != nil {
+			return err
+		}
+
+		logrus.Infof("Migrating database from version %v to %v, the current database was copied to %s.", migrator.CurrentDBVersion, api.DBVersion, backupPath)
 		err = migrator.Migrate()
 		if err != nil {
 			return err
 		}
 	}
 
-	return nil
+	return store.VersionService.StoreDBVersion(api.DBVersion)
+}
+
+// invalidateCaches discards the cached lookups of the services.
+func (store *Store) invalidateCaches() {
+	store.UserService.cache.invalidate()
+	store.TeamMembershipService.cache.invalidate()
+	store.ResourceControlService.cache.invalidate()
 }
@@ -19,7 +19,7 @@ const (
 // DockerHub returns the DockerHub object.
 func (service *DockerHubService) DockerHub() (*api.DockerHub, error) {
 	var data []byte
-	err := service.store.db.View(func(tx *bolt.Tx) error {
+	err := service.store.view(func(tx *bolt.Tx) error {
 		bucket := tx.Bucket([]byte(dockerhubBucketName))
 		value := bucket.Get([]byte(dbDockerHubKey))
 		if value == nil {
@@ -39,15 +39,25 @@ func (service *DockerHubService) DockerHub() (*api.DockerHub, error) {
 	if err != nil {
 		return nil, err
 	}
+
+	err = service.store.decryptDockerHub(&dockerhub)
+	if err != nil {
+		return nil, err
+	}
 	return &dockerhub, nil
 }
 
 // StoreDockerHub persists a DockerHub object.
 func (service *DockerHubService) StoreDockerHub(dockerhub *api.DockerHub) error {
-	return service.store.db.Update(func(tx *bolt.Tx) error {
+	encrypted, err := service.store.encryptDockerHub(dockerhub)
+	if err != nil {
+		return err
+	}
+
+	return service.store.update(func(tx *bolt.Tx) error {
 		bucket := tx.Bucket([]byte(dockerhubBucketName))
 
-		data, err := internal.MarshalDockerHub(dockerhub)
+		data, err := internal.MarshalDockerHub(encrypted)
 		if err != nil {
 			return err
 		}
@@ -0,0 +1,383 @@
+package bolt
+
+import (
+	"os"
+	"strings"
+	"time"
+
+	"cloudware/cloudware/api"
+	"cloudware/cloudware/bolt/internal"
+
+	"github.com/boltdb/bolt"
+	"github.com/sirupsen/logrus"
+)
+
+// encryptSecret encrypts a secret before it is stored. Secrets are stored
+// in clear text when no encryption service is configured.
+func (store *Store) encryptSecret(value string) (string, error) {
+	if store.EncryptionService == nil {
+		return value, nil
+	}
+	return store.EncryptionService.Encrypt(value)
+}
+
+// decryptSecret decrypts a secret after it is retrieved.
+func (store *Store) decryptSecret(value string) (string, error) {
+	if store.EncryptionService == nil {
+		if strings.HasPrefix(value, api.EncryptedSecretPrefix) {
+			return "", api.ErrEncryptionKeyRequired
+		}
+		return value, nil
+	}
+	return store.EncryptionService.Decrypt(value)
+}
+
+func (store *Store) encryptRegistry(registry *api.Registry) (*api.Registry, error) {
+	encrypted := *registry
+	password, err := store.encryptSecret(registry.Password)
+	if err != nil {
+		return nil, err
+	}
+	encrypted.Password = password
+	return &encrypted, nil
+}
+
+func (store *Store) decryptRegistry(registry *api.Registry) error {
+	password, err := store.decryptSecret(registry.Password)
+	if err != nil {
+		return err
+	}
+	registry.Password = password
+	return nil
+}
+
+func (store *Store) encryptDockerHub(dockerhub *api.DockerHub) (*api.DockerHub, error) {
+	encrypted := *dockerhub
+	password, err := store.encryptSecret(dockerhub.Password)
+	if err != nil {
+		return nil, err
+	}
+	encrypted.Password = password
+	return &encrypted, nil
+}
+
+func (store *Store) decryptDockerHub(dockerhub *api.DockerHub) error {
+	password, err := store.decryptSecret(dockerhub.Password)
+	if err != nil {
+		return err
+	}
+	dockerhub.Password = password
+	return nil
+}
+
+// settingsSecrets returns the secrets stored in the settings.
+func settingsSecrets(settings *api.Settings) []*string {
+	return []*string{
+		&settings.LDAPSettings.Password,
+		&settings.BackupSettings.Password,
+		&settings.BackupSettings.S3.SecretAccessKey,
+	}
+}
+
+func (store *Store) encryptSettings(settings *api.Settings) (*api.Settings, error) {
+	encrypted := *settings
+	for _, secret := range settingsSecrets(&encrypted) {
+		value, err := store.encryptSecret(*secret)
+		if err != nil {
+			return nil, err
+		}
+		*secret = value
+	}
+	return &encrypted, nil
+}
+
+func (store *Store) decryptSettings(settings *api.Settings) error {
+	for _, secret := range settingsSecrets(settings) {
+		value, err := store.decryptSecret(*secret)
+		if err != nil {
+			return err
+		}
+		*secret = value
+	}
+	return nil
+}
+
+func (store *Store) encryptStack(stack *api.Stack) (*api.Stack, error) {
+	encrypted := *stack
+	if stack.Env != nil {
+		encrypted.Env = make([]api.Pair, len(stack.Env))
+	}
+	for idx, pair := range stack.Env {
+		value, err := store.encryptSecret(pair.Value)
+		if err != nil {
+			return nil, err
+		}
+		encrypted.Env[idx] = api.Pair{Name: pair.Name, Value: value}
+	}
+	return &encrypted, nil
+}
+
+func (store *Store) decryptStack(stack *api.Stack) error {
+	for idx := range stack.Env {
+		value, err := store.decryptSecret(stack.Env[idx].Value)
+		if err != nil {
+			return err
+		}
+		stack.Env[idx].Value = value
+	}
+	return nil
+}
+
+// EncryptSecrets encrypts the secrets stored in clear text or with the previous key
+// using the current key of the encryption service. It can safely be run on every start.
+// When no encryption service is configured, it ensures that no secret is encrypted.
+func (store *Store) EncryptSecrets() error {
+	count := 0
+
+	reencrypt := func(value *string) error {
+		if store.EncryptionService == nil {
+			if strings.HasPrefix(*value, api.EncryptedSecretPrefix) {
+				return api.ErrEncryptionKeyRequired
+			}
+			return nil
+		}
+
+		encrypted, updated, err := store.EncryptionService.Reencrypt(*value)
+		if err != nil {
+			return err
+		}
+		if updated {
+			*value = encrypted
+			count++
+		}
+		return nil
+	}
+
+	err := store.update(func(tx *bolt.Tx) error {
+		updates := make(map[string][]byte)
+		bucket := tx.Bucket([]byte(registryBucketName))
+		cursor := bucket.Cursor()
+		for k, v := cursor.First(); k != nil; k, v = cursor.Next() {
+			var registry api.Registry
+			err := internal.UnmarshalRegistry(v, &registry)
+			if err != nil {
+				return err
+			}
+
+			previous := count
+			err = reencrypt(&registry.Password)
+			if err != nil {
+				return err
+			}
+			if count == previous {
+				continue
+			}
+
+			data, err := internal.MarshalRegistry(&registry)
+			if err != nil {
+				return err
+			}
+			updates[string(k)] = data
+		}
+
+		err := putAll(bucket, updates)
+		if err != nil {
+			return err
+		}
+
+		bucket = tx.Bucket([]byte(dockerhubBucketName))
+		if value := bucket.Get([]byte(dbDockerHubKey)); value != nil {
+			var dockerhub api.DockerHub
+			err := internal.UnmarshalDockerHub(value, &dockerhub)
+			if err != nil {
+				return err
+			}
+
+			previous := count
+			err = reencrypt(&dockerhub.Password)
+			if err != nil {
+				return err
+			}
+			if count != previous {
+				data, err := internal.MarshalDockerHub(&dockerhub)
+				if err != nil {
+					return err
+				}
+				err = bucket.Put([]byte(dbDockerHubKey), data)
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		bucket = tx.Bucket([]byte(settingsBucketName))
+		if value := bucket.Get([]byte(dbSettingsKey)); value != nil {
+			var settings api.Settings
+			err := internal.UnmarshalSettings(value, &settings)
+			if err != nil {
+				return err
+			}
+
+			previous := count
+			for _, secret := range settingsSecrets(&settings) {
+				err = reencrypt(secret)
+				if err != nil {
+					return err
+				}
+			}
+			if count != previous {
+				data, err := internal.MarshalSettings(&settings)
+				if err != nil {
+					return err
+				}
+				err = bucket.Put([]byte(dbSettingsKey), data)
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		updates = make(map[string][]byte)
+		bucket = tx.Bucket([]byte(stackBucketName))
+		cursor = bucket.Cursor()
+		for k, v := cursor.First(); k != nil; k, v = cursor.Next() {
+			var stack api.Stack
+			err := internal.UnmarshalStack(v, &stack)
+			if err != nil {
+				return err
+			}
+
+			previous := count
+			for idx := range stack.Env {
+				err = reencrypt(&stack.Env[idx].Value)
+				if err != nil {
+					return err
+				}
+			}
+			if count == previous {
+				continue
+			}
+
+			data, err := internal.MarshalStack(&stack)
+			if err != nil {
+				return err
+			}
+			updates[string(k)] = data
+		}
+
+		return putAll(bucket, updates)
+	})
+	if err != nil {
+		return err
+	}
+
+	if count == 0 {
+		return nil
+	}
+
+	logrus.Infof("Encrypted %d stored secrets with the current encryption key.", count)
+
+	// The pages freed by the update still contain the previous values of the secrets.
+	return store.compact()
+}
+
+// compact rewrites the database into a new file that does not contain the free pages
+// of the current one, and replaces the current database with it. The transactions
+// are blocked until the database is replaced.
+func (store *Store) compact() error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	path := store.Path + "/" + databaseFileName
+	compactPath := path + ".compact"
+
+	target, err := bolt.Open(compactPath, 0600, &bolt.Options{Timeout: 1 * time.Second})
+	if err != nil {
+		return err
+	}
+
+	err = copyBuckets(store.db, target)
+	if closeErr := target.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(compactPath)
+		return err
+	}
+
+	err = store.db.Close()
+	if err != nil {
+		return err
+	}
+
+	err = os.Rename(compactPath, path)
+	if err != nil {
+		return err
+	}
+
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: 1 * time.Second})
+	if err != nil {
+		return err
+	}
+	store.db = db
+	return nil
+}
+
+// copyBuckets copies the buckets of the source database and their sequence into the target database.
+// The sequence of a bucket can only be read by incrementing it, so the source transaction is never committed.
+func copyBuckets(source, target *bolt.DB) error {
+	tx, err := source.Begin(true)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	return target.Update(func(targetTx *bolt.Tx) error {
+		return tx.ForEach(func(name []byte, bucket *bolt.Bucket) error {
+			targetBucket, err := targetTx.CreateBucket(name)
+			if err != nil {
+				return err
+			}
+			return copyBucket(bucket, targetBucket)
+		})
+	})
+}
+
+// copyBucket copies the records, the sequence and the nested buckets of a bucket.
+func copyBucket(bucket, target *bolt.Bucket) error {
+	sequence, err := bucket.NextSequence()
+	if err != nil {
+		return err
+	}
+	for idx := uint64(1); idx < sequence; idx++ {
+		_, err = target.NextSequence()
+		if err != nil {
+			return err
+		}
+	}
+
+	return bucket.ForEach(func(k, v []byte) error {
+		// Nested buckets are listed without value
+		if v != nil {
+			return target.Put(k, v)
+		}
+
+		nestedTarget, err := target.CreateBucket(k)
+		if err != nil {
+			return err
+		}
+		return copyBucket(bucket.Bucket(k), nestedTarget)
+	})
+}
+
+// putAll stores the updated records once the bucket traversal is done,
+// as modifying a bucket invalidates its cursors.
+func putAll(bucket *bolt.Bucket, updates map[string][]byte) error {
+	for key, data := range updates {
+		err := bucket.Put([]byte(key), data)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,279 @@
+package bolt
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"cloudware/cloudware/api"
+	"cloudware/cloudware/api/crypto"
+	"cloudware/cloudware/bolt/internal"
+
+	"github.com/boltdb/bolt"
+)
+
+// newEncryptionStore returns an opened store using an encryption service with the specified keys,
+// no encryption service is used when the key is empty.
+func newEncryptionStore(t *testing.T, dataPath, key, previousKey string) *Store {
+	store := OpenTestStore(t, dataPath)
+
+	if key != "" {
+		var previous []byte
+		if previousKey != "" {
+			previous = []byte(previousKey)
+		}
+		var err error
+		store.EncryptionService, err = crypto.NewEncryptionService([]byte(key), previous)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return store
+}
+
+// storedRegistryPassword returns the password of a registry as stored in the database.
+func storedRegistryPassword(t *testing.T, store *Store, ID api.RegistryID) string {
+	var registry api.Registry
+	err := store.view(func(tx *bolt.Tx) error {
+		return internal.UnmarshalRegistry(tx.Bucket([]byte(registryBucketName)).Get(internal.Itob(int(ID))), &registry)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return registry.Password
+}
+
+// databaseContains reports whether the database file contains a value, including in its free pages.
+func databaseContains(t *testing.T, dataPath, value string) bool {
+	data, err := ioutil.ReadFile(filepath.Join(dataPath, databaseFileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return bytes.Contains(data, []byte(value))
+}
+
+func TestEncryptSecrets(t *testing.T) {
+	store, cleanup := NewTestStore(t)
+	defer cleanup()
+	dataPath := store.Path
+	registry := &api.Registry{Name: "private", URL: "registry.example.com", Authentication: true, Username: "user", Password: "clear-text-password"}
+	err := store.RegistryService.CreateRegistry(registry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = store.StackService.CreateStack(&api.Stack{ID: "web_1", Name: "web", Env: []api.Pair{{Name: "TOKEN", Value: "clear-text-token"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.Close()
+
+	// The secrets stored in clear text are encrypted once a key is configured
+	store = newEncryptionStore(t, dataPath, "first key", "")
+	err = store.EncryptSecrets()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	password := storedRegistryPassword(t, store, registry.ID)
+	if !strings.HasPrefix(password, api.EncryptedSecretPrefix) {
+		t.Fatalf("expected the password to be encrypted, got %q", password)
+	}
+
+	stored, err := store.RegistryService.Registry(registry.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stack, err := store.StackService.Stack("web_1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if registry.Password != stored.Password || stack.Env[0].Value != "clear-text-token" {
+		t.Errorf("expected the secrets to be decrypted, got %q and %q", stored.Password, stack.Env[0].Value)
+	}
+	store.Close()
+
+	for _, value := range []string{"clear-text-password", "clear-text-token"} {
+		if databaseContains(t, dataPath, value) {
+			t.Errorf("expected %q to be removed from the database file", value)
+		}
+	}
+
+	// The secrets are encrypted again with the new key during a rotation
+	store = newEncryptionStore(t, dataPath, "second key", "first key")
+	previous := storedRegistryPassword(t, store, registry.ID)
+	err = store.EncryptSecrets()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if storedRegistryPassword(t, store, registry.ID) == previous {
+		t.Error("expected the password to be encrypted with the new key")
+	}
+	store.Close()
+
+	store = newEncryptionStore(t, dataPath, "second key", "")
+	defer store.Close()
+	stored, err = store.RegistryService.Registry(registry.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Password != "clear-text-password" {
+		t.Errorf("expected the password to be decrypted with the new key, got %q", stored.Password)
+	}
+
+	// The secrets can be cleared
+	stored.Password = ""
+	err = store.RegistryService.UpdateRegistry(stored.ID, stored)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if password := storedRegistryPassword(t, store, registry.ID); password != "" {
+		t.Errorf("expected the password to be cleared, got %q", password)
+	}
+}
+
+func TestEncryptSecretsRequiresKey(t *testing.T) {
+	store, cleanup := NewTestStore(t)
+	defer cleanup()
+	dataPath := store.Path
+
+	var err error
+	store.EncryptionService, err = crypto.NewEncryptionService([]byte("key"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	registry := &api.Registry{Name: "private", URL: "registry.example.com", Authentication: true, Username: "user", Password: "secret"}
+	err = store.RegistryService.CreateRegistry(registry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.Close()
+
+	store = newEncryptionStore(t, dataPath, "", "")
+	err = store.EncryptSecrets()
+	if err != api.ErrEncryptionKeyRequired {
+		t.Errorf("expected %q, got %v", api.ErrEncryptionKeyRequired, err)
+	}
+	_, err = store.RegistryService.Registry(registry.ID)
+	if err != api.ErrEncryptionKeyRequired {
+		t.Errorf("expected %q, got %v", api.ErrEncryptionKeyRequired, err)
+	}
+
+	store.Close()
+
+	store = newEncryptionStore(t, dataPath, "other key", "")
+	defer store.Close()
+	_, err = store.RegistryService.Registry(registry.ID)
+	if err != api.ErrEncryptionKeyMismatch {
+		t.Errorf("expected %q, got %v", api.ErrEncryptionKeyMismatch, err)
+	}
+}
+
+func TestCompact(t *testing.T) {
+	store, cleanup := newListStore(t, "admin", "alice")
+	defer cleanup()
+
+	err := store.update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucket([]byte("nested"))
+		if err != nil {
+			return err
+		}
+		for idx := 0; idx < 3; idx++ {
+			_, err = bucket.NextSequence()
+			if err != nil {
+				return err
+			}
+		}
+		err = bucket.Put([]byte("key"), []byte("value"))
+		if err != nil {
+			return err
+		}
+
+		child, err := bucket.CreateBucket([]byte("child"))
+		if err != nil {
+			return err
+		}
+		_, err = child.NextSequence()
+		if err != nil {
+			return err
+		}
+		grandChild, err := child.CreateBucket([]byte("grandchild"))
+		if err != nil {
+			return err
+		}
+		return grandChild.Put([]byte("deep"), []byte("value"))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Transactions run while the database is compacted
+	var wg sync.WaitGroup
+	errors := make(chan error, 100)
+	for idx := 0; idx < 4; idx++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for count := 0; count < 25; count++ {
+				_, err := store.UserService.Users()
+				if err != nil {
+					errors <- err
+				}
+			}
+		}()
+	}
+
+	err = store.compact()
+	if err != nil {
+		t.Fatal(err)
+	}
+	wg.Wait()
+	close(errors)
+	for err := range errors {
+		t.Errorf("expected the transactions to succeed during the compaction, got %s", err)
+	}
+
+	users, err := store.UserService.Users()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(users) != 2 {
+		t.Errorf("expected 2 users, got %d", len(users))
+	}
+
+	err = store.update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte("nested"))
+		if bucket == nil {
+			t.Fatal("expected the bucket to be copied")
+		}
+		if value := bucket.Get([]byte("key")); string(value) != "value" {
+			t.Errorf("expected the records to be copied, got %q", value)
+		}
+		if sequence, _ := bucket.NextSequence(); sequence != 4 {
+			t.Errorf("expected the sequence to be copied, got %d", sequence)
+		}
+
+		child := bucket.Bucket([]byte("child"))
+		if child == nil {
+			t.Fatal("expected the nested bucket to be copied")
+		}
+		if sequence, _ := child.NextSequence(); sequence != 2 {
+			t.Errorf("expected the sequence of the nested bucket to be copied, got %d", sequence)
+		}
+		grandChild := child.Bucket([]byte("grandchild"))
+		if grandChild == nil || string(grandChild.Get([]byte("deep"))) != "value" {
+			t.Error("expected the buckets to be copied recursively")
+		}
+
+		userID, _ := tx.Bucket([]byte(userBucketName)).NextSequence()
+		if userID != 3 {
+			t.Errorf("expected the user sequence to be copied, got %d", userID)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
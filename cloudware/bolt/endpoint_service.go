@@ -15,7 +15,7 @@ type EndpointService struct {
 // Endpoint returns an endpoint by ID.
 func (service *EndpointService) Endpoint(ID api.EndpointID) (*api.Endpoint, error) {
 	var data []byte
-	err := service.store.db.View(func(tx *bolt.Tx) error {
+	err := service.store.view(func(tx *bolt.Tx) error {
 		bucket := tx.Bucket([]byte(endpointBucketName))
 		value := bucket.Get(internal.Itob(int(ID)))
 		if value == nil {
@@ -41,7 +41,7 @@ func (service *EndpointService) Endpoint(ID api.EndpointID) (*api.Endpoint, erro
 // Endpoints return an array containing all the endpoints.
 func (service *EndpointService) Endpoints() ([]api.Endpoint, error) {
 	var endpoints = make([]api.Endpoint, 0)
-	err := service.store.db.View(func(tx *bolt.Tx) error {
+	err := service.store.view(func(tx *bolt.Tx) error {
 		bucket := tx.Bucket([]byte(endpointBucketName))
 
 		cursor := bucket.Cursor()
@@ -63,9 +63,45 @@ func (service *EndpointService) Endpoints() ([]api.Endpoint, error) {
 	return endpoints, nil
 }
 
+// ListEndpoints returns the page of the endpoints matching a query, along with the total number of matching endpoints.
+func (service *EndpointService) ListEndpoints(query *api.EndpointQuery) ([]api.Endpoint, int, error) {
+	var endpoints = make([]api.Endpoint, 0)
+	pager := newPager(&query.ListOptions)
+	err := service.store.view(func(tx *bolt.Tx) error {
+		return pager.scan(tx.Bucket([]byte(endpointBucketName)), func(v []byte) error {
+			var endpoint api.Endpoint
+			err := internal.UnmarshalEndpoint(v, &endpoint)
+			if err != nil {
+				return err
+			}
+
+			if !query.MatchName(endpoint.Name) ||
+				(query.URL != "" && endpoint.URL != query.URL) ||
+				(query.Access != nil && !query.Access.Authorized(endpoint.AuthorizedUsers, endpoint.AuthorizedTeams)) {
+				return nil
+			}
+			if pager.keep() {
+				endpoints = append(endpoints, endpoint)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	start, end := pager.sort(len(endpoints), func(i, j int) { endpoints[i], endpoints[j] = endpoints[j], endpoints[i] }, func(i, j int) bool {
+		if query.Sort == api.SortByURL {
+			return lessFold(endpoints[i].URL, endpoints[j].URL, int(endpoints[i].ID), int(endpoints[j].ID))
+		}
+		return lessFold(endpoints[i].Name, endpoints[j].Name, int(endpoints[i].ID), int(endpoints[j].ID))
+	})
+	return endpoints[start:end], pager.total, nil
+}
+
 // Synchronize creates, updates and deletes endpoints inside a single transaction.
 func (service *EndpointService) Synchronize(toCreate, toUpdate, toDelete []*api.Endpoint) error {
-	return service.store.db.Update(func(tx *bolt.Tx) error {
+	return service.store.update(func(tx *bolt.Tx) error {
 		bucket := tx.Bucket([]byte(endpointBucketName))
 
 		for _, endpoint := range toCreate {
@@ -95,7 +131,7 @@ func (service *EndpointService) Synchronize(toCreate, toUpdate, toDelete []*api.
 
 // CreateEndpoint assign an ID to a new endpoint and saves it.
 func (service *EndpointService) CreateEndpoint(endpoint *api.Endpoint) error {
-	return service.store.db.Update(func(tx *bolt.Tx) error {
+	return service.store.update(func(tx *bolt.Tx) error {
 		bucket := tx.Bucket([]byte(endpointBucketName))
 		err := storeNewEndpoint(endpoint, bucket)
 		if err != nil {
@@ -112,7 +148,7 @@ func (service *EndpointService) UpdateEndpoint(ID api.EndpointID, endpoint *api.
 		return err
 	}
 
-	return service.store.db.Update(func(tx *bolt.Tx) error {
+	return service.store.update(func(tx *bolt.Tx) error {
 		bucket := tx.Bucket([]byte(endpointBucketName))
 		err = bucket.Put(internal.Itob(int(ID)), data)
 		if err != nil {
@@ -124,7 +160,7 @@ func (service *EndpointService) UpdateEndpoint(ID api.EndpointID, endpoint *api.
 
 // DeleteEndpoint deletes an endpoint.
 func (service *EndpointService) DeleteEndpoint(ID api.EndpointID) error {
-	return service.store.db.Update(func(tx *bolt.Tx) error {
+	return service.store.update(func(tx *bolt.Tx) error {
 		bucket := tx.Bucket([]byte(endpointBucketName))
 		err := bucket.Delete(internal.Itob(int(ID)))
 		if err != nil {
@@ -0,0 +1,138 @@
+package bolt
+
+import (
+	"bytes"
+
+	"cloudware/cloudware/api"
+	"cloudware/cloudware/bolt/internal"
+
+	"github.com/boltdb/bolt"
+)
+
+// Index buckets map a field to the key of the indexed object. They are maintained by the services
+// in the transaction writing the object and rebuilt each time the database is opened.
+const (
+	userUsernameIndexBucketName            = "users_username_index"
+	resourceControlResourceIndexBucketName = "resource_control_resource_index"
+	teamMembershipUserIndexBucketName      = "team_membership_user_index"
+)
+
+var indexBucketNames = []string{userUsernameIndexBucketName, resourceControlResourceIndexBucketName, teamMembershipUserIndexBucketName}
+
+// rebuildIndexes recreates the index buckets from the content of the database.
+func rebuildIndexes(tx *bolt.Tx) error {
+	for _, name := range indexBucketNames {
+		if tx.Bucket([]byte(name)) != nil {
+			err := tx.DeleteBucket([]byte(name))
+			if err != nil {
+				return err
+			}
+		}
+
+		_, err := tx.CreateBucket([]byte(name))
+		if err != nil {
+			return err
+		}
+	}
+
+	err := tx.Bucket([]byte(userBucketName)).ForEach(func(k, v []byte) error {
+		var user api.User
+		err := internal.UnmarshalUser(v, &user)
+		if err != nil {
+			return err
+		}
+		return tx.Bucket([]byte(userUsernameIndexBucketName)).Put([]byte(user.Username), k)
+	})
+	if err != nil {
+		return err
+	}
+
+	err = tx.Bucket([]byte(resourceControlBucketName)).ForEach(func(k, v []byte) error {
+		var resourceControl api.ResourceControl
+		err := internal.UnmarshalResourceControl(v, &resourceControl)
+		if err != nil {
+			return err
+		}
+		return putResourceControlIndex(tx, &resourceControl)
+	})
+	if err != nil {
+		return err
+	}
+
+	return tx.Bucket([]byte(teamMembershipBucketName)).ForEach(func(k, v []byte) error {
+		var membership api.TeamMembership
+		err := internal.UnmarshalTeamMembership(v, &membership)
+		if err != nil {
+			return err
+		}
+		return putTeamMembershipIndex(tx, &membership)
+	})
+}
+
+// updateUserIndex replaces the username indexed for a user.
+func updateUserIndex(tx *bolt.Tx, previous, user *api.User) error {
+	index := tx.Bucket([]byte(userUsernameIndexBucketName))
+	if previous != nil {
+		err := deleteIndexEntry(index, []byte(previous.Username), internal.Itob(int(previous.ID)))
+		if err != nil {
+			return err
+		}
+	}
+
+	if user == nil {
+		return nil
+	}
+	return index.Put([]byte(user.Username), internal.Itob(int(user.ID)))
+}
+
+// resourceIDs returns the resource identifiers associated to a resource control.
+func resourceIDs(resourceControl *api.ResourceControl) []string {
+	return append([]string{resourceControl.ResourceID}, resourceControl.SubResourceIDs...)
+}
+
+func putResourceControlIndex(tx *bolt.Tx, resourceControl *api.ResourceControl) error {
+	index := tx.Bucket([]byte(resourceControlResourceIndexBucketName))
+	for _, resourceID := range resourceIDs(resourceControl) {
+		err := index.Put([]byte(resourceID), internal.Itob(int(resourceControl.ID)))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func deleteResourceControlIndex(tx *bolt.Tx, resourceControl *api.ResourceControl) error {
+	index := tx.Bucket([]byte(resourceControlResourceIndexBucketName))
+	for _, resourceID := range resourceIDs(resourceControl) {
+		err := deleteIndexEntry(index, []byte(resourceID), internal.Itob(int(resourceControl.ID)))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// teamMembershipIndexKey returns the key of a membership in the user index, composed of
+// the user identifier followed by the membership identifier.
+func teamMembershipIndexKey(membership *api.TeamMembership) []byte {
+	return append(internal.Itob(int(membership.UserID)), internal.Itob(int(membership.ID))...)
+}
+
+func putTeamMembershipIndex(tx *bolt.Tx, membership *api.TeamMembership) error {
+	index := tx.Bucket([]byte(teamMembershipUserIndexBucketName))
+	return index.Put(teamMembershipIndexKey(membership), internal.Itob(int(membership.ID)))
+}
+
+func deleteTeamMembershipIndex(tx *bolt.Tx, membership *api.TeamMembership) error {
+	index := tx.Bucket([]byte(teamMembershipUserIndexBucketName))
+	return index.Delete(teamMembershipIndexKey(membership))
+}
+
+// deleteIndexEntry removes an index entry if it still refers to the specified object,
+// the entry may have been taken over by another object sharing the same indexed value.
+func deleteIndexEntry(index *bolt.Bucket, key, objectKey []byte) error {
+	if !bytes.Equal(index.Get(key), objectKey) {
+		return nil
+	}
+	return index.Delete(key)
+}
@@ -0,0 +1,293 @@
+package bolt
+
+import (
+	"strconv"
+	"testing"
+
+	"cloudware/cloudware/api"
+
+	"github.com/boltdb/bolt"
+)
+
+func TestUserIndex(t *testing.T) {
+	store, cleanup := newListStore(t, "admin", "alice")
+	defer cleanup()
+
+	alice, err := store.UserService.UserByUsername("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The lookup of the previous username is cached before the rename
+	alice.Username = "alicia"
+	err = store.UserService.UpdateUser(alice.ID, alice)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = store.UserService.UserByUsername("alice")
+	if err != api.ErrUserNotFound {
+		t.Errorf("expected the previous username to be removed from the index, got %v", err)
+	}
+	renamed, err := store.UserService.UserByUsername("alicia")
+	if err != nil || renamed.ID != alice.ID {
+		t.Errorf("expected the new username to be indexed, got %+v and %v", renamed, err)
+	}
+
+	// A cached user cannot be modified by the caller
+	renamed.Username = "modified"
+	cached, err := store.UserService.UserByUsername("alicia")
+	if err != nil || cached.Username != "alicia" {
+		t.Errorf("expected the cached user to be copied, got %+v and %v", cached, err)
+	}
+
+	err = store.UserService.DeleteUser(alice.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = store.UserService.UserByUsername("alicia")
+	if err != api.ErrUserNotFound {
+		t.Errorf("expected the deleted user to be removed from the index, got %v", err)
+	}
+
+	// The username of a deleted user can be used again
+	err = store.UserService.CreateUser(&api.User{Username: "alice", Role: api.StandardUserRole})
+	if err != nil {
+		t.Fatal(err)
+	}
+	created, err := store.UserService.UserByUsername("alice")
+	if err != nil || created.ID == alice.ID {
+		t.Errorf("expected the new user to be indexed, got %+v and %v", created, err)
+	}
+}
+
+func TestResourceControlIndex(t *testing.T) {
+	store, cleanup := newListStore(t)
+	defer cleanup()
+
+	// Missing resource controls are cached before the creation
+	_, err := store.ResourceControlService.ResourceControlByResourceID("service")
+	if err != api.ErrResourceControlNotFound {
+		t.Fatalf("expected %q, got %v", api.ErrResourceControlNotFound, err)
+	}
+
+	resourceControl := &api.ResourceControl{ResourceID: "service", SubResourceIDs: []string{"task-1", "task-2"}, Type: api.ServiceResourceControl}
+	err = store.ResourceControlService.CreateResourceControl(resourceControl)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, resourceID := range []string{"service", "task-1", "task-2"} {
+		found, err := store.ResourceControlService.ResourceControlByResourceID(resourceID)
+		if err != nil || found.ID != resourceControl.ID {
+			t.Errorf("%s: expected the resource control to be indexed, got %+v and %v", resourceID, found, err)
+		}
+	}
+
+	resourceControl.SubResourceIDs = []string{"task-2", "task-3"}
+	err = store.ResourceControlService.UpdateResourceControl(resourceControl.ID, resourceControl)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = store.ResourceControlService.ResourceControlByResourceID("task-1")
+	if err != api.ErrResourceControlNotFound {
+		t.Errorf("expected the removed sub resource to be removed from the index, got %v", err)
+	}
+	found, err := store.ResourceControlService.ResourceControlByResourceID("task-3")
+	if err != nil || found.ID != resourceControl.ID {
+		t.Errorf("expected the added sub resource to be indexed, got %+v and %v", found, err)
+	}
+
+	// A resource shared by two resource controls stays indexed when the first one is deleted
+	other := &api.ResourceControl{ResourceID: "other", SubResourceIDs: []string{"task-2"}, Type: api.ServiceResourceControl}
+	err = store.ResourceControlService.CreateResourceControl(other)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = store.ResourceControlService.DeleteResourceControl(resourceControl.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, resourceID := range []string{"service", "task-3"} {
+		_, err = store.ResourceControlService.ResourceControlByResourceID(resourceID)
+		if err != api.ErrResourceControlNotFound {
+			t.Errorf("%s: expected the deleted resource control to be removed from the index, got %v", resourceID, err)
+		}
+	}
+	found, err = store.ResourceControlService.ResourceControlByResourceID("task-2")
+	if err != nil || found.ID != other.ID {
+		t.Errorf("expected the resource shared with another resource control to stay indexed, got %+v and %v", found, err)
+	}
+}
+
+func TestTeamMembershipIndex(t *testing.T) {
+	store, cleanup := newListStore(t, "admin", "alice", "bob")
+	defer cleanup()
+
+	alice, _ := store.UserService.UserByUsername("alice")
+	bob, _ := store.UserService.UserByUsername("bob")
+
+	memberships := []*api.TeamMembership{
+		{UserID: alice.ID, TeamID: 1, Role: api.TeamMember},
+		{UserID: alice.ID, TeamID: 2, Role: api.TeamLeader},
+		{UserID: bob.ID, TeamID: 1, Role: api.TeamMember},
+	}
+	for _, membership := range memberships {
+		err := store.TeamMembershipService.CreateTeamMembership(membership)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	expectMemberships := func(userID api.UserID, expected int) {
+		found, err := store.TeamMembershipService.TeamMembershipsByUserID(userID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(found) != expected {
+			t.Errorf("user %d: expected %d memberships, got %+v", userID, expected, found)
+		}
+		for _, membership := range found {
+			if membership.UserID != userID {
+				t.Errorf("user %d: unexpected membership %+v", userID, membership)
+			}
+		}
+	}
+
+	expectMemberships(alice.ID, 2)
+	expectMemberships(bob.ID, 1)
+
+	// Moving a membership to another user updates the index of both users
+	memberships[1].UserID = bob.ID
+	err := store.TeamMembershipService.UpdateTeamMembership(memberships[1].ID, memberships[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectMemberships(alice.ID, 1)
+	expectMemberships(bob.ID, 2)
+
+	err = store.TeamMembershipService.DeleteTeamMembershipByTeamID(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectMemberships(alice.ID, 0)
+	expectMemberships(bob.ID, 1)
+
+	err = store.TeamMembershipService.DeleteTeamMembershipByUserID(bob.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectMemberships(bob.ID, 0)
+}
+
+func TestIndexesAreRebuilt(t *testing.T) {
+	store, cleanup := newListStore(t, "admin", "alice")
+	defer cleanup()
+
+	alice, _ := store.UserService.UserByUsername("alice")
+	err := store.ResourceControlService.CreateResourceControl(&api.ResourceControl{ResourceID: "container", Type: api.ContainerResourceControl})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = store.TeamMembershipService.CreateTeamMembership(&api.TeamMembership{UserID: alice.ID, TeamID: 1, Role: api.TeamMember})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The indexes are emptied, as if the objects were written by a previous version
+	err = store.update(func(tx *bolt.Tx) error {
+		for _, name := range indexBucketNames {
+			err := tx.DeleteBucket([]byte(name))
+			if err != nil {
+				return err
+			}
+			_, err = tx.CreateBucket([]byte(name))
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store.Close()
+	err = store.Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = store.UserService.UserByUsername("alice")
+	if err != nil {
+		t.Errorf("expected the user index to be rebuilt, got %v", err)
+	}
+	_, err = store.ResourceControlService.ResourceControlByResourceID("container")
+	if err != nil {
+		t.Errorf("expected the resource control index to be rebuilt, got %v", err)
+	}
+	memberships, err := store.TeamMembershipService.TeamMembershipsByUserID(alice.ID)
+	if err != nil || len(memberships) != 1 {
+		t.Errorf("expected the team membership index to be rebuilt, got %+v and %v", memberships, err)
+	}
+}
+
+func TestCacheDiscardsStaleValues(t *testing.T) {
+	c := newCache()
+
+	_, ok, generation := c.get("key")
+	if ok {
+		t.Fatal("expected the cache to be empty")
+	}
+
+	// The value was read before a write invalidating the cache
+	c.invalidate()
+	c.set("key", "stale", generation)
+	if _, ok, _ := c.get("key"); ok {
+		t.Error("expected a value read before an invalidation to be discarded")
+	}
+
+	_, _, generation = c.get("key")
+	c.set("key", "value", generation)
+	value, ok, _ := c.get("key")
+	if !ok || value != "value" {
+		t.Errorf("expected the value to be cached, got %v", value)
+	}
+
+	c.invalidate()
+	if _, ok, _ := c.get("key"); ok {
+		t.Error("expected the cache to be emptied")
+	}
+}
+
+func TestCacheIsBounded(t *testing.T) {
+	store, cleanup := newListStore(t)
+	defer cleanup()
+
+	resourceControl := &api.ResourceControl{ResourceID: "container", Type: api.ContainerResourceControl}
+	err := store.ResourceControlService.CreateResourceControl(resourceControl)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Missing resource controls requested by a client
+	for i := 0; i < 2*maxCacheEntries; i++ {
+		_, err := store.ResourceControlService.ResourceControlByResourceID("missing-" + strconv.Itoa(i))
+		if err != api.ErrResourceControlNotFound {
+			t.Fatalf("expected %q, got %v", api.ErrResourceControlNotFound, err)
+		}
+	}
+	if size := len(store.ResourceControlService.cache.entries); size != maxCacheEntries {
+		t.Errorf("expected the cache to hold at most %d values, got %d", maxCacheEntries, size)
+	}
+
+	found, err := store.ResourceControlService.ResourceControlByResourceID("container")
+	if err != nil || found.ID != resourceControl.ID {
+		t.Errorf("expected the resource control to be found once the cache is full, got %+v and %v", found, err)
+	}
+	if _, ok, _ := store.ResourceControlService.cache.get("container"); !ok {
+		t.Error("expected the resource control to be cached once the cache is full")
+	}
+}
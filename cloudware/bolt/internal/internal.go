@@ -67,6 +67,16 @@ func UnmarshalRegistry(data []byte, registry *api.Registry) error {
 	return json.Unmarshal(data, registry)
 }
 
+// MarshalTemplate encodes a template to binary format.
+func MarshalTemplate(template *api.Template) ([]byte, error) {
+	return json.Marshal(template)
+}
+
+// UnmarshalTemplate decodes a template from a binary data.
+func UnmarshalTemplate(data []byte, template *api.Template) error {
+	return json.Unmarshal(data, template)
+}
+
 // MarshalResourceControl encodes a resource control object to binary format.
 func MarshalResourceControl(rc *api.ResourceControl) ([]byte, error) {
 	return json.Marshal(rc)
@@ -97,6 +107,26 @@ func UnmarshalDockerHub(data []byte, settings *api.DockerHub) error {
 	return json.Unmarshal(data, settings)
 }
 
+// MarshalRecording encodes a recording to binary format.
+func MarshalRecording(recording *api.Recording) ([]byte, error) {
+	return json.Marshal(recording)
+}
+
+// UnmarshalRecording decodes a recording from a binary data.
+func UnmarshalRecording(data []byte, recording *api.Recording) error {
+	return json.Unmarshal(data, recording)
+}
+
+// MarshalBackupStatus encodes a backup status to binary format.
+func MarshalBackupStatus(status *api.BackupStatus) ([]byte, error) {
+	return json.Marshal(status)
+}
+
+// UnmarshalBackupStatus decodes a backup status from a binary data.
+func UnmarshalBackupStatus(data []byte, status *api.BackupStatus) error {
+	return json.Unmarshal(data, status)
+}
+
 // Itob returns an 8-byte big endian representation of v.
 // This function is typically used for encoding integer IDs to byte slices
 // so that they can be used as BoltDB keys.
@@ -0,0 +1,89 @@
+package bolt
+
+import (
+	"sort"
+	"strings"
+
+	"cloudware/cloudware/api"
+
+	"github.com/boltdb/bolt"
+)
+
+// pager selects the page of a list while a bucket is scanned. When the list is sorted by
+// identifier, the bucket is scanned in the order of the list and only the objects of the
+// page are kept. Otherwise every matching object is kept to be sorted once the scan is over.
+type pager struct {
+	options *api.ListOptions
+	byKey   bool
+	total   int
+}
+
+func newPager(options *api.ListOptions) *pager {
+	return &pager{
+		options: options,
+		byKey:   options.Sort == "" || options.Sort == api.SortByID,
+	}
+}
+
+// scan calls fn with the values of a bucket, in the order of the list when it is sorted by identifier.
+func (p *pager) scan(bucket *bolt.Bucket, fn func(v []byte) error) error {
+	cursor := bucket.Cursor()
+	if p.byKey && p.options.Descending {
+		for k, v := cursor.Last(); k != nil; k, v = cursor.Prev() {
+			if err := fn(v); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	for k, v := cursor.First(); k != nil; k, v = cursor.Next() {
+		if err := fn(v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// keep counts a matching object and reports whether it must be kept.
+func (p *pager) keep() bool {
+	index := p.total
+	p.total++
+	return !p.byKey || p.options.InPage(index)
+}
+
+// sort sorts the kept objects with less when the list is not sorted by identifier, and
+// returns the bounds of the page within them.
+func (p *pager) sort(count int, swap func(i, j int), less func(i, j int) bool) (int, int) {
+	if p.byKey {
+		return 0, count
+	}
+
+	sort.Stable(&sorter{count: count, swap: swap, less: less, descending: p.options.Descending})
+	return p.options.Bounds(count)
+}
+
+type sorter struct {
+	count      int
+	swap       func(i, j int)
+	less       func(i, j int) bool
+	descending bool
+}
+
+func (s *sorter) Len() int      { return s.count }
+func (s *sorter) Swap(i, j int) { s.swap(i, j) }
+func (s *sorter) Less(i, j int) bool {
+	if s.descending {
+		return s.less(j, i)
+	}
+	return s.less(i, j)
+}
+
+// lessFold compares two strings ignoring the case, the identifiers break the ties.
+func lessFold(a, b string, aID, bID int) bool {
+	a, b = strings.ToLower(a), strings.ToLower(b)
+	if a != b {
+		return a < b
+	}
+	return aID < bID
+}
@@ -0,0 +1,204 @@
+package bolt
+
+import (
+	"testing"
+
+	"cloudware/cloudware/api"
+)
+
+// newListStore returns an opened store containing the specified users.
+func newListStore(t *testing.T, usernames ...string) (*Store, func()) {
+	store, cleanup := NewTestStore(t)
+
+	for idx, username := range usernames {
+		role := api.StandardUserRole
+		if idx == 0 {
+			role = api.AdministratorRole
+		}
+		err := store.UserService.CreateUser(&api.User{Username: username, Role: role})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return store, cleanup
+}
+
+func usernames(users []api.User) []string {
+	names := make([]string, len(users))
+	for idx, user := range users {
+		names[idx] = user.Username
+	}
+	return names
+}
+
+func TestListUsers(t *testing.T) {
+	store, cleanup := newListStore(t, "admin", "dave", "Carol", "bob", "alice", "bobby")
+	defer cleanup()
+
+	for _, test := range []struct {
+		name     string
+		query    api.UserQuery
+		expected []string
+		total    int
+	}{
+		{"all", api.UserQuery{}, []string{"admin", "dave", "Carol", "bob", "alice", "bobby"}, 6},
+		{"page", api.UserQuery{ListOptions: api.ListOptions{Offset: 1, Limit: 2}}, []string{"dave", "Carol"}, 6},
+		{"descending page", api.UserQuery{ListOptions: api.ListOptions{Limit: 2, Descending: true}}, []string{"bobby", "alice"}, 6},
+		{"offset past the end", api.UserQuery{ListOptions: api.ListOptions{Offset: 10}}, []string{}, 6},
+		{"sorted by name", api.UserQuery{ListOptions: api.ListOptions{Sort: api.SortByName, Offset: 1, Limit: 3}}, []string{"alice", "bob", "bobby"}, 6},
+		{"sorted by name descending", api.UserQuery{ListOptions: api.ListOptions{Sort: api.SortByName, Descending: true, Limit: 2}}, []string{"dave", "Carol"}, 6},
+		{"search", api.UserQuery{ListOptions: api.ListOptions{Search: "BOB", Limit: 1}}, []string{"bob"}, 2},
+		{"role", api.UserQuery{Role: api.AdministratorRole}, []string{"admin"}, 1},
+		{"without administrators", api.UserQuery{ListOptions: api.ListOptions{Search: "a"}, ExcludeAdministrators: true}, []string{"dave", "Carol", "alice"}, 3},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			users, total, err := store.UserService.ListUsers(&test.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if total != test.total {
+				t.Errorf("expected a total of %d, got %d", test.total, total)
+			}
+			names := usernames(users)
+			if len(names) != len(test.expected) {
+				t.Fatalf("expected %v, got %v", test.expected, names)
+			}
+			for idx := range names {
+				if names[idx] != test.expected[idx] {
+					t.Fatalf("expected %v, got %v", test.expected, names)
+				}
+			}
+		})
+	}
+}
+
+func TestListAccess(t *testing.T) {
+	store, cleanup := newListStore(t)
+	defer cleanup()
+
+	access := &api.AccessFilter{UserID: 2, TeamIDs: []api.TeamID{3}}
+
+	for _, endpoint := range []*api.Endpoint{
+		{Name: "public"},
+		{Name: "user", AuthorizedUsers: []api.UserID{2}},
+		{Name: "team", AuthorizedTeams: []api.TeamID{3}},
+		{Name: "other", AuthorizedUsers: []api.UserID{4}, AuthorizedTeams: []api.TeamID{5}},
+	} {
+		err := store.EndpointService.CreateEndpoint(endpoint)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	endpoints, total, err := store.EndpointService.ListEndpoints(&api.EndpointQuery{Access: access})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 2 || len(endpoints) != 2 || endpoints[0].Name != "user" || endpoints[1].Name != "team" {
+		t.Errorf("unexpected authorized endpoints %v (total %d)", endpoints, total)
+	}
+
+	for _, stack := range []*api.Stack{
+		{ID: "free_swarm", Name: "free", SwarmID: "swarm"},
+		{ID: "granted_swarm", Name: "granted", SwarmID: "swarm"},
+		{ID: "restricted_swarm", Name: "restricted", SwarmID: "swarm"},
+		{ID: "other_cluster", Name: "other", SwarmID: "cluster"},
+	} {
+		err := store.StackService.CreateStack(stack)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, resourceControl := range []*api.ResourceControl{
+		{ResourceID: "granted", Type: api.StackResourceControl, TeamAccesses: []api.TeamResourceAccess{{TeamID: 3}}},
+		{ResourceID: "restricted", Type: api.StackResourceControl, AdministratorsOnly: true},
+	} {
+		err := store.ResourceControlService.CreateResourceControl(resourceControl)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	stacks, total, err := store.StackService.ListStacks(&api.StackQuery{SwarmID: "swarm", Access: access})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 2 || len(stacks) != 2 || stacks[0].Name != "free" || stacks[1].Name != "granted" {
+		t.Errorf("unexpected authorized stacks %v (total %d)", stacks, total)
+	}
+
+	stacks, total, err = store.StackService.ListStacks(&api.StackQuery{SwarmID: "swarm"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 3 || len(stacks) != 3 {
+		t.Errorf("expected the administrators to list every stack of the swarm, got %v", stacks)
+	}
+
+	resourceControls, total, err := store.ResourceControlService.ListResourceControls(&api.ResourceControlQuery{Access: access})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 1 || len(resourceControls) != 1 || resourceControls[0].ResourceID != "granted" {
+		t.Errorf("unexpected authorized resource controls %v", resourceControls)
+	}
+}
+
+func TestListTemplates(t *testing.T) {
+	store, cleanup := newListStore(t)
+	defer cleanup()
+
+	for _, template := range []*api.Template{
+		{Type: api.ContainerTemplate, Title: "nginx", Categories: []string{"web"}},
+		{Type: api.StackTemplate, Title: "Elastic", Categories: []string{"search"}, AuthorizedTeams: []api.TeamID{3}},
+		{Type: api.ContainerTemplate, Title: "postgres", Categories: []string{"database"}, AuthorizedTeams: []api.TeamID{5}},
+	} {
+		err := store.TemplateService.CreateTemplate(template)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	access := &api.AccessFilter{UserID: 2, TeamIDs: []api.TeamID{3}}
+	templates, total, err := store.TemplateService.ListTemplates(&api.TemplateQuery{Access: access})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 2 || len(templates) != 2 || templates[0].Title != "nginx" || templates[1].Title != "Elastic" {
+		t.Errorf("unexpected visible templates %v (total %d)", templates, total)
+	}
+
+	templates, total, err = store.TemplateService.ListTemplates(&api.TemplateQuery{Type: api.ContainerTemplate, Category: "database"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 1 || len(templates) != 1 || templates[0].Title != "postgres" {
+		t.Errorf("unexpected database templates %v (total %d)", templates, total)
+	}
+
+	err = store.TemplateService.ReplaceTemplates("source", []api.Template{{Type: api.ContainerTemplate, Title: "redis"}, {Type: api.ContainerTemplate, Title: "mysql"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	imported, _, err := store.TemplateService.ListTemplates(&api.TemplateQuery{ListOptions: api.ListOptions{Search: "redis"}})
+	if err != nil || len(imported) != 1 {
+		t.Fatalf("expected the imported template, got %v, %v", imported, err)
+	}
+
+	err = store.TemplateService.ReplaceTemplates("source", []api.Template{{Type: api.ContainerTemplate, Title: "redis"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	templates, total, err = store.TemplateService.ListTemplates(&api.TemplateQuery{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 4 {
+		t.Errorf("expected the templates no longer in the source to be removed, got %v", templates)
+	}
+	redis, err := store.TemplateService.Template(imported[0].ID)
+	if err != nil || redis.Source != "source" {
+		t.Errorf("expected the identifier of a reimported template to be kept, got %v, %v", redis, err)
+	}
+}
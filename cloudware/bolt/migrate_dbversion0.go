@@ -1,39 +1,47 @@
 package bolt
 
 import (
-	"github.com/boltdb/bolt"
 	"cloudware/cloudware/api"
+	"cloudware/cloudware/bolt/internal"
+
+	"github.com/boltdb/bolt"
 )
 
-func (m *Migrator) updateAdminUserToDBVersion1() error {
-	u, err := m.UserService.UserByUsername("admin")
-	if err == nil {
-		admin := &api.User{
-			Username: "admin",
-			Password: u.Password,
-			Role:     api.AdministratorRole,
-		}
-		err = m.UserService.CreateUser(admin)
-		if err != nil {
-			return err
-		}
-		err = m.removeLegacyAdminUser()
-		if err != nil {
-			return err
-		}
-	} else if err != nil && err != api.ErrUserNotFound {
+// migrateAdminUserToDBVersion1 replaces the legacy administrator user, stored under its username,
+// with a user stored under a generated identifier.
+func migrateAdminUserToDBVersion1(tx *bolt.Tx) error {
+	bucket := tx.Bucket([]byte(userBucketName))
+	value := bucket.Get([]byte("admin"))
+	if value == nil {
+		return nil
+	}
+
+	var legacyAdmin api.User
+	err := internal.UnmarshalUser(value, &legacyAdmin)
+	if err != nil {
 		return err
 	}
-	return nil
-}
 
-func (m *Migrator) removeLegacyAdminUser() error {
-	return m.store.db.Update(func(tx *bolt.Tx) error {
-		bucket := tx.Bucket([]byte(userBucketName))
-		err := bucket.Delete([]byte("admin"))
-		if err != nil {
-			return err
-		}
-		return nil
-	})
+	id, err := bucket.NextSequence()
+	if err != nil {
+		return err
+	}
+
+	admin := &api.User{
+		ID:       api.UserID(id),
+		Username: "admin",
+		Password: legacyAdmin.Password,
+		Role:     api.AdministratorRole,
+	}
+
+	data, err := internal.MarshalUser(admin)
+	if err != nil {
+		return err
+	}
+
+	err = bucket.Put(internal.Itob(int(admin.ID)), data)
+	if err != nil {
+		return err
+	}
+	return bucket.Delete([]byte("admin"))
 }
@@ -1,24 +1,41 @@
 package bolt
 
 import (
-	"github.com/boltdb/bolt"
 	"cloudware/cloudware/api"
 	"cloudware/cloudware/bolt/internal"
+
+	"github.com/boltdb/bolt"
 )
 
-func (m *Migrator) updateResourceControlsToDBVersion2() error {
-	legacyResourceControls, err := m.retrieveLegacyResourceControls()
+// legacyResourceControlBuckets associates the buckets of the legacy resource controls to their type.
+var legacyResourceControlBuckets = []struct {
+	name         string
+	resourceType api.ResourceControlType
+}{
+	{"containerResourceControl", api.ContainerResourceControl},
+	{"serviceResourceControl", api.ServiceResourceControl},
+	{"volumeResourceControl", api.VolumeResourceControl},
+}
+
+// migrateToDBVersion2 converts the legacy resource controls, owned by a single user,
+// and initializes the teams authorized to access the endpoints.
+func migrateToDBVersion2(tx *bolt.Tx) error {
+	legacyResourceControls, err := retrieveLegacyResourceControls(tx)
 	if err != nil {
 		return err
 	}
 
+	users := tx.Bucket([]byte(userBucketName))
+	bucket := tx.Bucket([]byte(resourceControlBucketName))
 	for _, resourceControl := range legacyResourceControls {
 		resourceControl.SubResourceIDs = []string{}
 		resourceControl.TeamAccesses = []api.TeamResourceAccess{}
 
-		owner, err := m.UserService.User(resourceControl.OwnerID)
+		owner, err := userByKey(users, internal.Itob(int(resourceControl.OwnerID)))
 		if err != nil {
 			return err
+		} else if owner == nil {
+			return api.ErrUserNotFound
 		}
 
 		if owner.Role == api.AdministratorRole {
@@ -33,71 +50,49 @@ func (m *Migrator) updateResourceControlsToDBVersion2() error {
 			resourceControl.UserAccesses = []api.UserResourceAccess{userAccess}
 		}
 
-		err = m.ResourceControlService.CreateResourceControl(&resourceControl)
+		id, err := bucket.NextSequence()
 		if err != nil {
 			return err
 		}
-	}
-
-	return nil
-}
+		resourceControl.ID = api.ResourceControlID(id)
 
-func (m *Migrator) updateEndpointsToDBVersion2() error {
-	legacyEndpoints, err := m.EndpointService.Endpoints()
-	if err != nil {
-		return err
-	}
+		data, err := internal.MarshalResourceControl(&resourceControl)
+		if err != nil {
+			return err
+		}
 
-	for _, endpoint := range legacyEndpoints {
-		endpoint.AuthorizedTeams = []api.TeamID{}
-		err = m.EndpointService.UpdateEndpoint(endpoint.ID, &endpoint)
+		err = bucket.Put(internal.Itob(int(resourceControl.ID)), data)
 		if err != nil {
 			return err
 		}
 	}
 
-	return nil
+	return updateEndpoints(tx, func(endpoint *api.Endpoint) {
+		endpoint.AuthorizedTeams = []api.TeamID{}
+	})
 }
 
-func (m *Migrator) retrieveLegacyResourceControls() ([]api.ResourceControl, error) {
+func retrieveLegacyResourceControls(tx *bolt.Tx) ([]api.ResourceControl, error) {
 	legacyResourceControls := make([]api.ResourceControl, 0)
-	err := m.store.db.View(func(tx *bolt.Tx) error {
-		bucket := tx.Bucket([]byte("containerResourceControl"))
-		cursor := bucket.Cursor()
-		for k, v := cursor.First(); k != nil; k, v = cursor.Next() {
-			var resourceControl api.ResourceControl
-			err := internal.UnmarshalResourceControl(v, &resourceControl)
-			if err != nil {
-				return err
-			}
-			resourceControl.Type = api.ContainerResourceControl
-			legacyResourceControls = append(legacyResourceControls, resourceControl)
+	for _, legacyBucket := range legacyResourceControlBuckets {
+		bucket := tx.Bucket([]byte(legacyBucket.name))
+		if bucket == nil {
+			continue
 		}
 
-		bucket = tx.Bucket([]byte("serviceResourceControl"))
-		cursor = bucket.Cursor()
-		for k, v := cursor.First(); k != nil; k, v = cursor.Next() {
+		err := bucket.ForEach(func(k, v []byte) error {
 			var resourceControl api.ResourceControl
 			err := internal.UnmarshalResourceControl(v, &resourceControl)
 			if err != nil {
 				return err
 			}
-			resourceControl.Type = api.ServiceResourceControl
-			legacyResourceControls = append(legacyResourceControls, resourceControl)
-		}
-
-		bucket = tx.Bucket([]byte("volumeResourceControl"))
-		cursor = bucket.Cursor()
-		for k, v := cursor.First(); k != nil; k, v = cursor.Next() {
-			var resourceControl api.ResourceControl
-			err := internal.UnmarshalResourceControl(v, &resourceControl)
-			if err != nil {
-				return err
-			}
-			resourceControl.Type = api.VolumeResourceControl
+			resourceControl.Type = legacyBucket.resourceType
 			legacyResourceControls = append(legacyResourceControls, resourceControl)
+			return nil
+		})
+		if err != nil {
+			return nil, err
 		}
-		return nil
-	})
-	return legacyResourceControls, err
+	}
+	return legacyResourceControls, nil
 }
@@ -1,25 +1,19 @@
 package bolt
 
-import "cloudware/cloudware/api"
+import (
+	"cloudware/cloudware/api"
 
-func (m *Migrator) updateSettingsToDBVersion3() error {
-	legacySettings, err := m.SettingsService.Settings()
-	if err != nil {
-		return err
-	}
+	"github.com/boltdb/bolt"
+)
 
-	legacySettings.AuthenticationMethod = api.AuthenticationInternal
-	legacySettings.LDAPSettings = api.LDAPSettings{
-		TLSConfig: api.TLSConfiguration{},
-		SearchSettings: []api.LDAPSearchSettings{
-			api.LDAPSearchSettings{},
-		},
-	}
-
-	err = m.SettingsService.StoreSettings(legacySettings)
-	if err != nil {
-		return err
-	}
-
-	return nil
+func migrateSettingsToDBVersion3(tx *bolt.Tx) error {
+	return updateSettings(tx, func(settings *api.Settings) {
+		settings.AuthenticationMethod = api.AuthenticationInternal
+		settings.LDAPSettings = api.LDAPSettings{
+			TLSConfig: api.TLSConfiguration{},
+			SearchSettings: []api.LDAPSearchSettings{
+				api.LDAPSearchSettings{},
+			},
+		}
+	})
 }
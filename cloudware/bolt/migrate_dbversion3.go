@@ -1,14 +1,13 @@
 package bolt
 
-import "cloudware/cloudware/api"
+import (
+	"cloudware/cloudware/api"
 
-func (m *Migrator) updateEndpointsToDBVersion4() error {
-	legacyEndpoints, err := m.EndpointService.Endpoints()
-	if err != nil {
-		return err
-	}
+	"github.com/boltdb/bolt"
+)
 
-	for _, endpoint := range legacyEndpoints {
+func migrateEndpointsToDBVersion4(tx *bolt.Tx) error {
+	return updateEndpoints(tx, func(endpoint *api.Endpoint) {
 		endpoint.TLSConfig = api.TLSConfiguration{}
 		if endpoint.TLS {
 			endpoint.TLSConfig.TLS = true
@@ -17,11 +16,5 @@ func (m *Migrator) updateEndpointsToDBVersion4() error {
 			endpoint.TLSConfig.TLSCertPath = endpoint.TLSCertPath
 			endpoint.TLSConfig.TLSKeyPath = endpoint.TLSKeyPath
 		}
-		err = m.EndpointService.UpdateEndpoint(endpoint.ID, &endpoint)
-		if err != nil {
-			return err
-		}
-	}
-
-	return nil
+	})
 }
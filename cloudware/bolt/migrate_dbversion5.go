@@ -1,16 +1,13 @@
 package bolt
 
-func (m *Migrator) updateSettingsToVersion6() error {
-	legacySettings, err := m.SettingsService.Settings()
-	if err != nil {
-		return err
-	}
-	legacySettings.AllowPrivilegedModeForRegularUsers = true
+import (
+	"cloudware/cloudware/api"
 
-	err = m.SettingsService.StoreSettings(legacySettings)
-	if err != nil {
-		return err
-	}
+	"github.com/boltdb/bolt"
+)
 
-	return nil
+func migrateSettingsToDBVersion6(tx *bolt.Tx) error {
+	return updateSettings(tx, func(settings *api.Settings) {
+		settings.AllowPrivilegedModeForRegularUsers = true
+	})
 }
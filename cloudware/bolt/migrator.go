@@ -1,97 +1,140 @@
 package bolt
 
-import "cloudware/cloudware/api"
+import (
+	"cloudware/cloudware/api"
+	"cloudware/cloudware/bolt/internal"
 
-// Migrator defines a service to migrate data after a Portainer version update.
+	"github.com/boltdb/bolt"
+	"github.com/sirupsen/logrus"
+)
+
+// migration represents a numbered change of the data model. A migration is run in a single
+// transaction which also stores its version as the version of the database, so that a failed
+// migration leaves the database at the previous version.
+type migration struct {
+	version     int
+	description string
+	migrate     func(tx *bolt.Tx) error
+}
+
+// migrations contains all the migrations sorted by version.
+// The version of the last migration must be equal to api.DBVersion.
+var migrations = []migration{
+	{1, "Recreate the legacy administrator user", migrateAdminUserToDBVersion1},
+	{2, "Convert the legacy resource controls and add team access to the endpoints", migrateToDBVersion2},
+	{3, "Initialize the authentication settings", migrateSettingsToDBVersion3},
+	{4, "Move the TLS settings of the endpoints to their TLS configuration", migrateEndpointsToDBVersion4},
+	{5, "Allow bind mounts for regular users", migrateSettingsToDBVersion5},
+	{6, "Allow privileged mode for regular users", migrateSettingsToDBVersion6},
+	{7, "Display the donation header", migrateSettingsToDBVersion7},
+}
+
+// MigrationStep describes a migration that will be run on the database.
+type MigrationStep struct {
+	Version     int
+	Description string
+}
+
+// Migrator defines a service to migrate data after a version update.
 type Migrator struct {
-	UserService            *UserService
-	EndpointService        *EndpointService
-	ResourceControlService *ResourceControlService
-	SettingsService        *SettingsService
-	VersionService         *VersionService
-	CurrentDBVersion       int
-	store                  *Store
+	CurrentDBVersion int
+	store            *Store
 }
 
 // NewMigrator creates a new Migrator.
 func NewMigrator(store *Store, version int) *Migrator {
 	return &Migrator{
-		UserService:            store.UserService,
-		EndpointService:        store.EndpointService,
-		ResourceControlService: store.ResourceControlService,
-		SettingsService:        store.SettingsService,
-		VersionService:         store.VersionService,
-		CurrentDBVersion:       version,
-		store:                  store,
+		CurrentDBVersion: version,
+		store:            store,
 	}
 }
 
-// Migrate checks the database version and migrate the existing data to the most recent data model.
-func (m *Migrator) Migrate() error {
-
-	// Portainer < 1.12
-	if m.CurrentDBVersion < 1 {
-		err := m.updateAdminUserToDBVersion1()
-		if err != nil {
-			return err
+// Plan returns the migrations required to bring the database to the most recent data model.
+func (m *Migrator) Plan() []MigrationStep {
+	steps := make([]MigrationStep, 0)
+	for _, migration := range migrations {
+		if migration.version > m.CurrentDBVersion {
+			steps = append(steps, MigrationStep{Version: migration.version, Description: migration.description})
 		}
 	}
+	return steps
+}
 
-	// Portainer 1.12.x
-	if m.CurrentDBVersion < 2 {
-		err := m.updateResourceControlsToDBVersion2()
-		if err != nil {
-			return err
+// Migrate runs the pending migrations in order, each one in its own transaction.
+func (m *Migrator) Migrate() error {
+	for _, migration := range migrations {
+		if migration.version <= m.CurrentDBVersion {
+			continue
 		}
-		err = m.updateEndpointsToDBVersion2()
+
+		logrus.Infof("Migrating database to version %v: %s.", migration.version, migration.description)
+		err := m.store.update(func(tx *bolt.Tx) error {
+			err := migration.migrate(tx)
+			if err != nil {
+				return err
+			}
+			return storeDBVersion(tx, migration.version)
+		})
 		if err != nil {
 			return err
 		}
+		m.CurrentDBVersion = migration.version
 	}
 
-	// Portainer 1.13.x
-	if m.CurrentDBVersion < 3 {
-		err := m.updateSettingsToDBVersion3()
-		if err != nil {
-			return err
-		}
+	// Migrations write the objects directly, the indexes and the cached lookups are refreshed once they are done.
+	err := m.store.update(rebuildIndexes)
+	if err != nil {
+		return err
 	}
+	m.store.invalidateCaches()
+	return nil
+}
 
-	// Portainer 1.14.0
-	if m.CurrentDBVersion < 4 {
-		err := m.updateEndpointsToDBVersion4()
-		if err != nil {
-			return err
-		}
+// updateSettings applies fn to the stored settings, if any.
+func updateSettings(tx *bolt.Tx, fn func(settings *api.Settings)) error {
+	bucket := tx.Bucket([]byte(settingsBucketName))
+	value := bucket.Get([]byte(dbSettingsKey))
+	if value == nil {
+		return nil
 	}
 
-	// https://github.com/api/api/issues/1235
-	if m.CurrentDBVersion < 5 {
-		err := m.updateSettingsToVersion5()
-		if err != nil {
-			return err
-		}
+	var settings api.Settings
+	err := internal.UnmarshalSettings(value, &settings)
+	if err != nil {
+		return err
 	}
+	fn(&settings)
 
-	// https://github.com/api/api/issues/1236
-	if m.CurrentDBVersion < 6 {
-		err := m.updateSettingsToVersion6()
+	data, err := internal.MarshalSettings(&settings)
+	if err != nil {
+		return err
+	}
+	return bucket.Put([]byte(dbSettingsKey), data)
+}
+
+// updateEndpoints applies fn to each stored endpoint.
+func updateEndpoints(tx *bolt.Tx, fn func(endpoint *api.Endpoint)) error {
+	bucket := tx.Bucket([]byte(endpointBucketName))
+
+	updates := make(map[string][]byte)
+	err := bucket.ForEach(func(k, v []byte) error {
+		var endpoint api.Endpoint
+		err := internal.UnmarshalEndpoint(v, &endpoint)
 		if err != nil {
 			return err
 		}
-	}
+		fn(&endpoint)
 
-	// https://github.com/api/api/issues/1449
-	if m.CurrentDBVersion < 7 {
-		err := m.updateSettingsToVersion7()
+		data, err := internal.MarshalEndpoint(&endpoint)
 		if err != nil {
 			return err
 		}
-	}
-
-	err := m.VersionService.StoreDBVersion(api.DBVersion)
+		updates[string(k)] = data
+		return nil
+	})
 	if err != nil {
 		return err
 	}
-	return nil
+
+	return putAll(bucket, updates)
 }
@@ -0,0 +1,420 @@
+package bolt
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"cloudware/cloudware/api"
+	"cloudware/cloudware/bolt/internal"
+
+	"github.com/boltdb/bolt"
+)
+
+// newMigrationStore returns an opened store whose database is at the specified version.
+func newMigrationStore(t *testing.T, version int) (*Store, func()) {
+	store, cleanup := NewTestStore(t)
+	store.checkForDataMigration = true
+
+	err := store.VersionService.StoreDBVersion(version)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return store, cleanup
+}
+
+// put stores an object in a bucket, creating the bucket if needed.
+func put(t *testing.T, store *Store, bucketName string, key []byte, data []byte) {
+	err := store.update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists([]byte(bucketName))
+		if err != nil {
+			return err
+		}
+		return bucket.Put(key, data)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// migrateTo runs the migrations between two versions and refreshes the indexes as done by Migrate.
+func migrateTo(t *testing.T, store *Store, from, to int) {
+	for _, migration := range migrations {
+		if migration.version > from && migration.version <= to {
+			err := store.update(migration.migrate)
+			if err != nil {
+				t.Fatalf("migration to version %d failed: %s", migration.version, err)
+			}
+		}
+	}
+
+	err := store.update(rebuildIndexes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.invalidateCaches()
+}
+
+func TestMigrationsAreNumbered(t *testing.T) {
+	for idx, migration := range migrations {
+		if migration.version != idx+1 {
+			t.Fatalf("expected migration %d to have version %d, got %d", idx, idx+1, migration.version)
+		}
+		if migration.description == "" {
+			t.Fatalf("expected a description for migration %d", migration.version)
+		}
+	}
+
+	if last := migrations[len(migrations)-1].version; last != api.DBVersion {
+		t.Fatalf("expected the last migration to have version %d, got %d", api.DBVersion, last)
+	}
+}
+
+func TestMigrateAdminUserToDBVersion1(t *testing.T) {
+	store, cleanup := newMigrationStore(t, 0)
+	defer cleanup()
+
+	data, _ := internal.MarshalUser(&api.User{Username: "admin", Password: "hash"})
+	put(t, store, userBucketName, []byte("admin"), data)
+
+	migrateTo(t, store, 0, 1)
+
+	users, err := store.UserService.Users()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(users) != 1 {
+		t.Fatalf("expected 1 user, got %d", len(users))
+	}
+
+	admin := users[0]
+	if admin.ID != 1 || admin.Username != "admin" || admin.Password != "hash" || admin.Role != api.AdministratorRole {
+		t.Fatalf("unexpected administrator user: %+v", admin)
+	}
+}
+
+func TestMigrateToDBVersion2(t *testing.T) {
+	store, cleanup := newMigrationStore(t, 1)
+	defer cleanup()
+
+	admin := &api.User{Username: "admin", Role: api.AdministratorRole}
+	user := &api.User{Username: "user", Role: api.StandardUserRole}
+	for _, u := range []*api.User{admin, user} {
+		err := store.UserService.CreateUser(u)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	data, _ := internal.MarshalResourceControl(&api.ResourceControl{ResourceID: "container", OwnerID: admin.ID})
+	put(t, store, "containerResourceControl", []byte("container"), data)
+	data, _ = internal.MarshalResourceControl(&api.ResourceControl{ResourceID: "volume", OwnerID: user.ID})
+	put(t, store, "volumeResourceControl", []byte("volume"), data)
+
+	err := store.EndpointService.CreateEndpoint(&api.Endpoint{Name: "endpoint"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	migrateTo(t, store, 1, 2)
+
+	container, err := store.ResourceControlService.ResourceControlByResourceID("container")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if container.Type != api.ContainerResourceControl || !container.AdministratorsOnly || len(container.UserAccesses) != 0 {
+		t.Fatalf("unexpected container resource control: %+v", container)
+	}
+
+	volume, err := store.ResourceControlService.ResourceControlByResourceID("volume")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if volume.Type != api.VolumeResourceControl || volume.AdministratorsOnly ||
+		len(volume.UserAccesses) != 1 || volume.UserAccesses[0].UserID != user.ID {
+		t.Fatalf("unexpected volume resource control: %+v", volume)
+	}
+
+	endpoints, err := store.EndpointService.Endpoints()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if endpoints[0].AuthorizedTeams == nil {
+		t.Fatal("expected the authorized teams of the endpoint to be initialized")
+	}
+}
+
+func TestMigrateToDBVersion2WithoutOwner(t *testing.T) {
+	store, cleanup := newMigrationStore(t, 1)
+	defer cleanup()
+
+	data, _ := internal.MarshalResourceControl(&api.ResourceControl{ResourceID: "container", OwnerID: 42})
+	put(t, store, "containerResourceControl", []byte("container"), data)
+
+	err := NewMigrator(store, 1).Migrate()
+	if err != api.ErrUserNotFound {
+		t.Fatalf("expected error %s, got %v", api.ErrUserNotFound, err)
+	}
+
+	// The failed migration is rolled back entirely.
+	version, err := store.VersionService.DBVersion()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version != 1 {
+		t.Fatalf("expected version 1 after a failed migration, got %d", version)
+	}
+
+	resourceControls, err := store.ResourceControlService.ResourceControls()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resourceControls) != 0 {
+		t.Fatalf("expected no resource control after a failed migration, got %d", len(resourceControls))
+	}
+}
+
+func TestMigrateSettingsToDBVersion3(t *testing.T) {
+	store, cleanup := newMigrationStore(t, 2)
+	defer cleanup()
+
+	err := store.SettingsService.StoreSettings(&api.Settings{TemplatesURL: "templates"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	migrateTo(t, store, 2, 3)
+
+	settings, err := store.SettingsService.Settings()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if settings.AuthenticationMethod != api.AuthenticationInternal || len(settings.LDAPSettings.SearchSettings) != 1 {
+		t.Fatalf("unexpected authentication settings: %+v", settings)
+	}
+	if settings.TemplatesURL != "templates" {
+		t.Fatalf("expected the other settings to be kept, got %+v", settings)
+	}
+}
+
+func TestMigrateEndpointsToDBVersion4(t *testing.T) {
+	store, cleanup := newMigrationStore(t, 3)
+	defer cleanup()
+
+	tlsEndpoint := &api.Endpoint{Name: "tls", TLS: true, TLSCACertPath: "ca", TLSCertPath: "cert", TLSKeyPath: "key"}
+	endpoint := &api.Endpoint{Name: "plain", TLSConfig: api.TLSConfiguration{TLSSkipVerify: true}}
+	for _, e := range []*api.Endpoint{tlsEndpoint, endpoint} {
+		err := store.EndpointService.CreateEndpoint(e)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	migrateTo(t, store, 3, 4)
+
+	migrated, err := store.EndpointService.Endpoint(tlsEndpoint.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := api.TLSConfiguration{TLS: true, TLSCACertPath: "ca", TLSCertPath: "cert", TLSKeyPath: "key"}
+	if migrated.TLSConfig != expected {
+		t.Fatalf("expected TLS configuration %+v, got %+v", expected, migrated.TLSConfig)
+	}
+
+	migrated, err = store.EndpointService.Endpoint(endpoint.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if migrated.TLSConfig != (api.TLSConfiguration{}) {
+		t.Fatalf("expected an empty TLS configuration, got %+v", migrated.TLSConfig)
+	}
+}
+
+func TestMigrateSettingsToDBVersion5To7(t *testing.T) {
+	tests := []struct {
+		version int
+		enabled func(settings *api.Settings) bool
+	}{
+		{5, func(settings *api.Settings) bool { return settings.AllowBindMountsForRegularUsers }},
+		{6, func(settings *api.Settings) bool { return settings.AllowPrivilegedModeForRegularUsers }},
+		{7, func(settings *api.Settings) bool { return settings.DisplayDonationHeader }},
+	}
+
+	for _, test := range tests {
+		store, cleanup := newMigrationStore(t, test.version-1)
+
+		err := store.SettingsService.StoreSettings(&api.Settings{})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		migrateTo(t, store, test.version-1, test.version)
+
+		settings, err := store.SettingsService.Settings()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !test.enabled(settings) {
+			t.Fatalf("expected migration to version %d to enable its setting, got %+v", test.version, settings)
+		}
+		cleanup()
+	}
+}
+
+func TestMigrateDataCreatesBackup(t *testing.T) {
+	store, cleanup := newMigrationStore(t, 4)
+	defer cleanup()
+
+	err := store.MigrateData()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	version, err := store.VersionService.DBVersion()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version != api.DBVersion {
+		t.Fatalf("expected version %d, got %d", api.DBVersion, version)
+	}
+
+	backups, err := filepath.Glob(filepath.Join(store.Path, databaseFileName+".v4-*.bak"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backups) != 1 {
+		t.Fatalf("expected a copy of the database, got %v", backups)
+	}
+}
+
+func TestMigrateDataRefusesRecentDatabase(t *testing.T) {
+	store, cleanup := newMigrationStore(t, api.DBVersion+1)
+	defer cleanup()
+
+	err := store.MigrateData()
+	if err != api.ErrDBVersionTooRecent {
+		t.Fatalf("expected error %s, got %v", api.ErrDBVersionTooRecent, err)
+	}
+}
+
+// newLegacyDatabase writes a database stamped with version 1 before the migrations were numbered,
+// containing the specified buckets.
+func newLegacyDatabase(t *testing.T, buckets ...string) string {
+	dataPath, err := ioutil.TempDir("", "migration")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := bolt.Open(filepath.Join(dataPath, databaseFileName), 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucket([]byte(versionBucketName))
+		if err != nil {
+			return err
+		}
+		err = bucket.Put([]byte(dBVersionKey), []byte("1"))
+		if err != nil {
+			return err
+		}
+
+		for _, name := range buckets {
+			_, err = tx.CreateBucket([]byte(name))
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dataPath
+}
+
+// plannedMigrations opens the store and returns the number of migrations planned for its database.
+func plannedMigrations(t *testing.T, store *Store, readOnly bool) int {
+	var err error
+	if readOnly {
+		err = store.OpenReadOnly()
+	} else {
+		err = store.Open()
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	migrator, err := store.Migrator()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return len(migrator.Plan())
+}
+
+func TestMigratorUnnumberedDatabase(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		buckets  []string
+		expected int
+	}{
+		{"database stamped before the migrations were numbered", []string{userBucketName, teamBucketName, teamMembershipBucketName}, 0},
+		{"legacy database at version 1", []string{userBucketName}, api.DBVersion - 1},
+	} {
+		dataPath := newLegacyDatabase(t, test.buckets...)
+		defer os.RemoveAll(dataPath)
+
+		store, err := NewStore(dataPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// The version is detected from the content of the database, whether it was opened before or not.
+		for _, readOnly := range []bool{true, false, false, true} {
+			if planned := plannedMigrations(t, store, readOnly); planned != test.expected {
+				t.Errorf("%s: expected %d migrations, got %d (read-only: %v)", test.name, test.expected, planned, readOnly)
+			}
+		}
+	}
+}
+
+func TestOpenReadOnly(t *testing.T) {
+	dataPath := newLegacyDatabase(t, userBucketName)
+	defer os.RemoveAll(dataPath)
+
+	store, err := NewStore(dataPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = store.OpenReadOnly()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = store.UserService.CreateUser(&api.User{Username: "admin", Role: api.AdministratorRole})
+	if err != bolt.ErrDatabaseReadOnly {
+		t.Errorf("expected %q, got %v", bolt.ErrDatabaseReadOnly, err)
+	}
+	store.Close()
+
+	db, err := bolt.Open(filepath.Join(dataPath, databaseFileName), 0600, &bolt.Options{ReadOnly: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	err = db.View(func(tx *bolt.Tx) error {
+		if tx.Bucket([]byte(teamMembershipBucketName)) != nil || tx.Bucket([]byte(versionBucketName)).Get([]byte(numberedDBVersionKey)) != nil {
+			t.Error("expected the database to be left untouched")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
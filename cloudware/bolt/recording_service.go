@@ -0,0 +1,114 @@
+package bolt
+
+import (
+	"cloudware/cloudware/api"
+	"cloudware/cloudware/bolt/internal"
+
+	"github.com/boltdb/bolt"
+)
+
+// RecordingService represents a service for managing session recordings.
+type RecordingService struct {
+	store *Store
+}
+
+// Recording returns a recording by ID.
+func (service *RecordingService) Recording(ID api.RecordingID) (*api.Recording, error) {
+	var data []byte
+	err := service.store.view(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(recordingBucketName))
+		value := bucket.Get(internal.Itob(int(ID)))
+		if value == nil {
+			return api.ErrRecordingNotFound
+		}
+
+		data = make([]byte, len(value))
+		copy(data, value)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var recording api.Recording
+	err = internal.UnmarshalRecording(data, &recording)
+	if err != nil {
+		return nil, err
+	}
+	return &recording, nil
+}
+
+// Recordings returns an array containing all the recordings.
+func (service *RecordingService) Recordings() ([]api.Recording, error) {
+	var recordings = make([]api.Recording, 0)
+	err := service.store.view(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(recordingBucketName))
+
+		cursor := bucket.Cursor()
+		for k, v := cursor.First(); k != nil; k, v = cursor.Next() {
+			var recording api.Recording
+			err := internal.UnmarshalRecording(v, &recording)
+			if err != nil {
+				return err
+			}
+			recordings = append(recordings, recording)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return recordings, nil
+}
+
+// CreateRecording creates a new recording.
+func (service *RecordingService) CreateRecording(recording *api.Recording) error {
+	return service.store.update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(recordingBucketName))
+
+		id, _ := bucket.NextSequence()
+		recording.ID = api.RecordingID(id)
+
+		data, err := internal.MarshalRecording(recording)
+		if err != nil {
+			return err
+		}
+
+		err = bucket.Put(internal.Itob(int(recording.ID)), data)
+		if err != nil {
+			return err
+		}
+		return nil
+	})
+}
+
+// UpdateRecording updates a recording.
+func (service *RecordingService) UpdateRecording(ID api.RecordingID, recording *api.Recording) error {
+	data, err := internal.MarshalRecording(recording)
+	if err != nil {
+		return err
+	}
+
+	return service.store.update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(recordingBucketName))
+		err = bucket.Put(internal.Itob(int(ID)), data)
+		if err != nil {
+			return err
+		}
+		return nil
+	})
+}
+
+// DeleteRecording deletes a recording.
+func (service *RecordingService) DeleteRecording(ID api.RecordingID) error {
+	return service.store.update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(recordingBucketName))
+		err := bucket.Delete(internal.Itob(int(ID)))
+		if err != nil {
+			return err
+		}
+		return nil
+	})
+}
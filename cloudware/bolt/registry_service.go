@@ -15,7 +15,7 @@ type RegistryService struct {
 // Registry returns an registry by ID.
 func (service *RegistryService) Registry(ID api.RegistryID) (*api.Registry, error) {
 	var data []byte
-	err := service.store.db.View(func(tx *bolt.Tx) error {
+	err := service.store.view(func(tx *bolt.Tx) error {
 		bucket := tx.Bucket([]byte(registryBucketName))
 		value := bucket.Get(internal.Itob(int(ID)))
 		if value == nil {
@@ -35,13 +35,18 @@ func (service *RegistryService) Registry(ID api.RegistryID) (*api.Registry, erro
 	if err != nil {
 		return nil, err
 	}
+
+	err = service.store.decryptRegistry(&registry)
+	if err != nil {
+		return nil, err
+	}
 	return &registry, nil
 }
 
 // Registries returns an array containing all the registries.
 func (service *RegistryService) Registries() ([]api.Registry, error) {
 	var registries = make([]api.Registry, 0)
-	err := service.store.db.View(func(tx *bolt.Tx) error {
+	err := service.store.view(func(tx *bolt.Tx) error {
 		bucket := tx.Bucket([]byte(registryBucketName))
 
 		cursor := bucket.Cursor()
@@ -51,6 +56,11 @@ func (service *RegistryService) Registries() ([]api.Registry, error) {
 			if err != nil {
 				return err
 			}
+
+			err = service.store.decryptRegistry(&registry)
+			if err != nil {
+				return err
+			}
 			registries = append(registries, registry)
 		}
 
@@ -63,15 +73,65 @@ func (service *RegistryService) Registries() ([]api.Registry, error) {
 	return registries, nil
 }
 
+// ListRegistries returns the page of the registries matching a query, along with the total number of matching registries.
+func (service *RegistryService) ListRegistries(query *api.RegistryQuery) ([]api.Registry, int, error) {
+	var registries = make([]api.Registry, 0)
+	pager := newPager(&query.ListOptions)
+	err := service.store.view(func(tx *bolt.Tx) error {
+		return pager.scan(tx.Bucket([]byte(registryBucketName)), func(v []byte) error {
+			var registry api.Registry
+			err := internal.UnmarshalRegistry(v, &registry)
+			if err != nil {
+				return err
+			}
+
+			if !query.MatchName(registry.Name) ||
+				(query.URL != "" && registry.URL != query.URL) ||
+				(query.Access != nil && !query.Access.Authorized(registry.AuthorizedUsers, registry.AuthorizedTeams)) {
+				return nil
+			}
+			if pager.keep() {
+				registries = append(registries, registry)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	start, end := pager.sort(len(registries), func(i, j int) { registries[i], registries[j] = registries[j], registries[i] }, func(i, j int) bool {
+		if query.Sort == api.SortByURL {
+			return lessFold(registries[i].URL, registries[j].URL, int(registries[i].ID), int(registries[j].ID))
+		}
+		return lessFold(registries[i].Name, registries[j].Name, int(registries[i].ID), int(registries[j].ID))
+	})
+	registries = registries[start:end]
+
+	// Only the registries of the page are decrypted.
+	for idx := range registries {
+		err := service.store.decryptRegistry(&registries[idx])
+		if err != nil {
+			return nil, 0, err
+		}
+	}
+	return registries, pager.total, nil
+}
+
 // CreateRegistry creates a new registry.
 func (service *RegistryService) CreateRegistry(registry *api.Registry) error {
-	return service.store.db.Update(func(tx *bolt.Tx) error {
+	return service.store.update(func(tx *bolt.Tx) error {
 		bucket := tx.Bucket([]byte(registryBucketName))
 
 		id, _ := bucket.NextSequence()
 		registry.ID = api.RegistryID(id)
 
-		data, err := internal.MarshalRegistry(registry)
+		encrypted, err := service.store.encryptRegistry(registry)
+		if err != nil {
+			return err
+		}
+
+		data, err := internal.MarshalRegistry(encrypted)
 		if err != nil {
 			return err
 		}
@@ -86,12 +146,17 @@ func (service *RegistryService) CreateRegistry(registry *api.Registry) error {
 
 // UpdateRegistry updates an registry.
 func (service *RegistryService) UpdateRegistry(ID api.RegistryID, registry *api.Registry) error {
-	data, err := internal.MarshalRegistry(registry)
+	encrypted, err := service.store.encryptRegistry(registry)
+	if err != nil {
+		return err
+	}
+
+	data, err := internal.MarshalRegistry(encrypted)
 	if err != nil {
 		return err
 	}
 
-	return service.store.db.Update(func(tx *bolt.Tx) error {
+	return service.store.update(func(tx *bolt.Tx) error {
 		bucket := tx.Bucket([]byte(registryBucketName))
 		err = bucket.Put(internal.Itob(int(ID)), data)
 		if err != nil {
@@ -103,7 +168,7 @@ func (service *RegistryService) UpdateRegistry(ID api.RegistryID, registry *api.
 
 // DeleteRegistry deletes an registry.
 func (service *RegistryService) DeleteRegistry(ID api.RegistryID) error {
-	return service.store.db.Update(func(tx *bolt.Tx) error {
+	return service.store.update(func(tx *bolt.Tx) error {
 		bucket := tx.Bucket([]byte(registryBucketName))
 		err := bucket.Delete(internal.Itob(int(ID)))
 		if err != nil {
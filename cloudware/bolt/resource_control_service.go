@@ -10,12 +10,13 @@ import (
 // ResourceControlService represents a service for managing resource controls.
 type ResourceControlService struct {
 	store *Store
+	cache *cache
 }
 
 // ResourceControl returns a ResourceControl object by ID
 func (service *ResourceControlService) ResourceControl(ID api.ResourceControlID) (*api.ResourceControl, error) {
 	var data []byte
-	err := service.store.db.View(func(tx *bolt.Tx) error {
+	err := service.store.view(func(tx *bolt.Tx) error {
 		bucket := tx.Bucket([]byte(resourceControlBucketName))
 		value := bucket.Get(internal.Itob(int(ID)))
 		if value == nil {
@@ -41,42 +42,50 @@ func (service *ResourceControlService) ResourceControl(ID api.ResourceControlID)
 // ResourceControlByResourceID returns a ResourceControl object by checking if the resourceID is equal
 // to the main ResourceID or in SubResourceIDs
 func (service *ResourceControlService) ResourceControlByResourceID(resourceID string) (*api.ResourceControl, error) {
-	var resourceControl *api.ResourceControl
+	cached, ok, generation := service.cache.get(resourceID)
+	if ok {
+		return copyResourceControl(cached)
+	}
 
-	err := service.store.db.View(func(tx *bolt.Tx) error {
-		bucket := tx.Bucket([]byte(resourceControlBucketName))
-		cursor := bucket.Cursor()
-		for k, v := cursor.First(); k != nil; k, v = cursor.Next() {
-			var rc api.ResourceControl
-			err := internal.UnmarshalResourceControl(v, &rc)
-			if err != nil {
-				return err
-			}
-			if rc.ResourceID == resourceID {
-				resourceControl = &rc
-			}
-			for _, subResourceID := range rc.SubResourceIDs {
-				if subResourceID == resourceID {
-					resourceControl = &rc
-				}
-			}
+	var resourceControl *api.ResourceControl
+	err := service.store.view(func(tx *bolt.Tx) error {
+		key := tx.Bucket([]byte(resourceControlResourceIndexBucketName)).Get([]byte(resourceID))
+		if key == nil {
+			return nil
 		}
 
-		if resourceControl == nil {
-			return api.ErrResourceControlNotFound
-		}
-		return nil
+		var err error
+		resourceControl, err = resourceControlByKey(tx.Bucket([]byte(resourceControlBucketName)), key)
+		return err
 	})
 	if err != nil {
 		return nil, err
 	}
-	return resourceControl, nil
+
+	// Missing resource controls are cached as well, as most resources are not associated to one.
+	// The cache is bounded as the resource IDs come from the clients.
+	service.cache.set(resourceID, resourceControl, generation)
+	return copyResourceControl(resourceControl)
+}
+
+// copyResourceControl returns a copy of a cached resource control so that it cannot be modified by the caller.
+func copyResourceControl(cached interface{}) (*api.ResourceControl, error) {
+	resourceControl := cached.(*api.ResourceControl)
+	if resourceControl == nil {
+		return nil, api.ErrResourceControlNotFound
+	}
+
+	copied := *resourceControl
+	copied.SubResourceIDs = append([]string(nil), resourceControl.SubResourceIDs...)
+	copied.UserAccesses = append([]api.UserResourceAccess(nil), resourceControl.UserAccesses...)
+	copied.TeamAccesses = append([]api.TeamResourceAccess(nil), resourceControl.TeamAccesses...)
+	return &copied, nil
 }
 
 // ResourceControls returns all the ResourceControl objects
 func (service *ResourceControlService) ResourceControls() ([]api.ResourceControl, error) {
 	var rcs = make([]api.ResourceControl, 0)
-	err := service.store.db.View(func(tx *bolt.Tx) error {
+	err := service.store.view(func(tx *bolt.Tx) error {
 		bucket := tx.Bucket([]byte(resourceControlBucketName))
 
 		cursor := bucket.Cursor()
@@ -98,9 +107,49 @@ func (service *ResourceControlService) ResourceControls() ([]api.ResourceControl
 	return rcs, nil
 }
 
+// ListResourceControls returns the page of the resource controls matching a query, along with the total
+// number of matching resource controls.
+func (service *ResourceControlService) ListResourceControls(query *api.ResourceControlQuery) ([]api.ResourceControl, int, error) {
+	var resourceControls = make([]api.ResourceControl, 0)
+	pager := newPager(&query.ListOptions)
+	err := service.store.view(func(tx *bolt.Tx) error {
+		return pager.scan(tx.Bucket([]byte(resourceControlBucketName)), func(v []byte) error {
+			var resourceControl api.ResourceControl
+			err := internal.UnmarshalResourceControl(v, &resourceControl)
+			if err != nil {
+				return err
+			}
+
+			if !query.MatchName(resourceControl.ResourceID) ||
+				(query.Type != 0 && resourceControl.Type != query.Type) ||
+				(query.Access != nil && !query.Access.AuthorizedResourceControl(&resourceControl)) {
+				return nil
+			}
+			if pager.keep() {
+				resourceControls = append(resourceControls, resourceControl)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	start, end := pager.sort(len(resourceControls), func(i, j int) {
+		resourceControls[i], resourceControls[j] = resourceControls[j], resourceControls[i]
+	}, func(i, j int) bool {
+		if query.Sort == api.SortByType && resourceControls[i].Type != resourceControls[j].Type {
+			return resourceControls[i].Type < resourceControls[j].Type
+		}
+		return lessFold(resourceControls[i].ResourceID, resourceControls[j].ResourceID, int(resourceControls[i].ID), int(resourceControls[j].ID))
+	})
+	return resourceControls[start:end], pager.total, nil
+}
+
 // CreateResourceControl creates a new ResourceControl object
 func (service *ResourceControlService) CreateResourceControl(resourceControl *api.ResourceControl) error {
-	return service.store.db.Update(func(tx *bolt.Tx) error {
+	defer service.cache.invalidate()
+	return service.store.update(func(tx *bolt.Tx) error {
 		bucket := tx.Bucket([]byte(resourceControlBucketName))
 		id, _ := bucket.NextSequence()
 		resourceControl.ID = api.ResourceControlID(id)
@@ -113,7 +162,7 @@ func (service *ResourceControlService) CreateResourceControl(resourceControl *ap
 		if err != nil {
 			return err
 		}
-		return nil
+		return putResourceControlIndex(tx, resourceControl)
 	})
 }
 
@@ -124,25 +173,65 @@ func (service *ResourceControlService) UpdateResourceControl(ID api.ResourceCont
 		return err
 	}
 
-	return service.store.db.Update(func(tx *bolt.Tx) error {
+	defer service.cache.invalidate()
+	return service.store.update(func(tx *bolt.Tx) error {
 		bucket := tx.Bucket([]byte(resourceControlBucketName))
-		err = bucket.Put(internal.Itob(int(ID)), data)
 
+		err := service.deleteIndex(tx, bucket, ID)
 		if err != nil {
 			return err
 		}
-		return nil
+
+		err = bucket.Put(internal.Itob(int(ID)), data)
+		if err != nil {
+			return err
+		}
+
+		updated := *resourceControl
+		updated.ID = ID
+		return putResourceControlIndex(tx, &updated)
 	})
 }
 
 // DeleteResourceControl deletes a ResourceControl object by ID
 func (service *ResourceControlService) DeleteResourceControl(ID api.ResourceControlID) error {
-	return service.store.db.Update(func(tx *bolt.Tx) error {
+	defer service.cache.invalidate()
+	return service.store.update(func(tx *bolt.Tx) error {
 		bucket := tx.Bucket([]byte(resourceControlBucketName))
-		err := bucket.Delete(internal.Itob(int(ID)))
+
+		err := service.deleteIndex(tx, bucket, ID)
+		if err != nil {
+			return err
+		}
+
+		err = bucket.Delete(internal.Itob(int(ID)))
 		if err != nil {
 			return err
 		}
 		return nil
 	})
 }
+
+// deleteIndex removes the index entries of the stored version of a resource control.
+func (service *ResourceControlService) deleteIndex(tx *bolt.Tx, bucket *bolt.Bucket, ID api.ResourceControlID) error {
+	previous, err := resourceControlByKey(bucket, internal.Itob(int(ID)))
+	if err != nil || previous == nil {
+		return err
+	}
+	return deleteResourceControlIndex(tx, previous)
+}
+
+// resourceControlByKey returns the resource control stored under a key, or nil if there is none.
+func resourceControlByKey(bucket *bolt.Bucket, key []byte) (*api.ResourceControl, error) {
+	value := bucket.Get(key)
+	if value == nil {
+		return nil, nil
+	}
+
+	var resourceControl api.ResourceControl
+	err := internal.UnmarshalResourceControl(value, &resourceControl)
+	if err != nil {
+		return nil, err
+	}
+	return &resourceControl, nil
+}
@@ -19,7 +19,7 @@ const (
 // Settings retrieve the settings object.
 func (service *SettingsService) Settings() (*api.Settings, error) {
 	var data []byte
-	err := service.store.db.View(func(tx *bolt.Tx) error {
+	err := service.store.view(func(tx *bolt.Tx) error {
 		bucket := tx.Bucket([]byte(settingsBucketName))
 		value := bucket.Get([]byte(dbSettingsKey))
 		if value == nil {
@@ -39,15 +39,25 @@ func (service *SettingsService) Settings() (*api.Settings, error) {
 	if err != nil {
 		return nil, err
 	}
+
+	err = service.store.decryptSettings(&settings)
+	if err != nil {
+		return nil, err
+	}
 	return &settings, nil
 }
 
 // StoreSettings persists a Settings object.
 func (service *SettingsService) StoreSettings(settings *api.Settings) error {
-	return service.store.db.Update(func(tx *bolt.Tx) error {
+	encrypted, err := service.store.encryptSettings(settings)
+	if err != nil {
+		return err
+	}
+
+	return service.store.update(func(tx *bolt.Tx) error {
 		bucket := tx.Bucket([]byte(settingsBucketName))
 
-		data, err := internal.MarshalSettings(settings)
+		data, err := internal.MarshalSettings(encrypted)
 		if err != nil {
 			return err
 		}
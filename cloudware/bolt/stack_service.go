@@ -1,6 +1,8 @@
 package bolt
 
 import (
+	"strings"
+
 	"cloudware/cloudware/api"
 	"cloudware/cloudware/bolt/internal"
 
@@ -15,7 +17,7 @@ type StackService struct {
 // Stack returns a stack object by ID.
 func (service *StackService) Stack(ID api.StackID) (*api.Stack, error) {
 	var data []byte
-	err := service.store.db.View(func(tx *bolt.Tx) error {
+	err := service.store.view(func(tx *bolt.Tx) error {
 		bucket := tx.Bucket([]byte(stackBucketName))
 		value := bucket.Get([]byte(ID))
 		if value == nil {
@@ -35,13 +37,18 @@ func (service *StackService) Stack(ID api.StackID) (*api.Stack, error) {
 	if err != nil {
 		return nil, err
 	}
+
+	err = service.store.decryptStack(&stack)
+	if err != nil {
+		return nil, err
+	}
 	return &stack, nil
 }
 
 // Stacks returns an array containing all the stacks.
 func (service *StackService) Stacks() ([]api.Stack, error) {
 	var stacks = make([]api.Stack, 0)
-	err := service.store.db.View(func(tx *bolt.Tx) error {
+	err := service.store.view(func(tx *bolt.Tx) error {
 		bucket := tx.Bucket([]byte(stackBucketName))
 
 		cursor := bucket.Cursor()
@@ -51,6 +58,11 @@ func (service *StackService) Stacks() ([]api.Stack, error) {
 			if err != nil {
 				return err
 			}
+
+			err = service.store.decryptStack(&stack)
+			if err != nil {
+				return err
+			}
 			stacks = append(stacks, stack)
 		}
 
@@ -66,7 +78,7 @@ func (service *StackService) Stacks() ([]api.Stack, error) {
 // StacksBySwarmID return an array containing all the stacks related to the specified Swarm ID.
 func (service *StackService) StacksBySwarmID(id string) ([]api.Stack, error) {
 	var stacks = make([]api.Stack, 0)
-	err := service.store.db.View(func(tx *bolt.Tx) error {
+	err := service.store.view(func(tx *bolt.Tx) error {
 		bucket := tx.Bucket([]byte(stackBucketName))
 
 		cursor := bucket.Cursor()
@@ -76,6 +88,11 @@ func (service *StackService) StacksBySwarmID(id string) ([]api.Stack, error) {
 			if err != nil {
 				return err
 			}
+
+			err = service.store.decryptStack(&stack)
+			if err != nil {
+				return err
+			}
 			if stack.SwarmID == id {
 				stacks = append(stacks, stack)
 			}
@@ -90,12 +107,77 @@ func (service *StackService) StacksBySwarmID(id string) ([]api.Stack, error) {
 	return stacks, nil
 }
 
+// ListStacks returns the page of the stacks matching a query, along with the total number of matching stacks.
+// The access of a stack is checked against the resource control of its name, as indexed by the resource
+// control service.
+func (service *StackService) ListStacks(query *api.StackQuery) ([]api.Stack, int, error) {
+	var stacks = make([]api.Stack, 0)
+	pager := newPager(&query.ListOptions)
+	err := service.store.view(func(tx *bolt.Tx) error {
+		resourceControls := tx.Bucket([]byte(resourceControlBucketName))
+		index := tx.Bucket([]byte(resourceControlResourceIndexBucketName))
+
+		return pager.scan(tx.Bucket([]byte(stackBucketName)), func(v []byte) error {
+			var stack api.Stack
+			err := internal.UnmarshalStack(v, &stack)
+			if err != nil {
+				return err
+			}
+
+			if !query.MatchName(stack.Name) || (query.SwarmID != "" && stack.SwarmID != query.SwarmID) {
+				return nil
+			}
+			if query.Access != nil {
+				if key := index.Get([]byte(stack.Name)); key != nil {
+					resourceControl, err := resourceControlByKey(resourceControls, key)
+					if err != nil {
+						return err
+					}
+					if resourceControl != nil && !query.Access.AuthorizedResourceControl(resourceControl) {
+						return nil
+					}
+				}
+			}
+			if pager.keep() {
+				stacks = append(stacks, stack)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	start, end := pager.sort(len(stacks), func(i, j int) { stacks[i], stacks[j] = stacks[j], stacks[i] }, func(i, j int) bool {
+		a, b := strings.ToLower(stacks[i].Name), strings.ToLower(stacks[j].Name)
+		if a != b {
+			return a < b
+		}
+		return stacks[i].ID < stacks[j].ID
+	})
+	stacks = stacks[start:end]
+
+	// Only the stacks of the page are decrypted.
+	for idx := range stacks {
+		err := service.store.decryptStack(&stacks[idx])
+		if err != nil {
+			return nil, 0, err
+		}
+	}
+	return stacks, pager.total, nil
+}
+
 // CreateStack creates a new stack.
 func (service *StackService) CreateStack(stack *api.Stack) error {
-	return service.store.db.Update(func(tx *bolt.Tx) error {
+	encrypted, err := service.store.encryptStack(stack)
+	if err != nil {
+		return err
+	}
+
+	return service.store.update(func(tx *bolt.Tx) error {
 		bucket := tx.Bucket([]byte(stackBucketName))
 
-		data, err := internal.MarshalStack(stack)
+		data, err := internal.MarshalStack(encrypted)
 		if err != nil {
 			return err
 		}
@@ -110,12 +192,17 @@ func (service *StackService) CreateStack(stack *api.Stack) error {
 
 // UpdateStack updates an stack.
 func (service *StackService) UpdateStack(ID api.StackID, stack *api.Stack) error {
-	data, err := internal.MarshalStack(stack)
+	encrypted, err := service.store.encryptStack(stack)
+	if err != nil {
+		return err
+	}
+
+	data, err := internal.MarshalStack(encrypted)
 	if err != nil {
 		return err
 	}
 
-	return service.store.db.Update(func(tx *bolt.Tx) error {
+	return service.store.update(func(tx *bolt.Tx) error {
 		bucket := tx.Bucket([]byte(stackBucketName))
 		err = bucket.Put([]byte(ID), data)
 		if err != nil {
@@ -127,7 +214,7 @@ func (service *StackService) UpdateStack(ID api.StackID, stack *api.Stack) error
 
 // DeleteStack deletes an stack.
 func (service *StackService) DeleteStack(ID api.StackID) error {
-	return service.store.db.Update(func(tx *bolt.Tx) error {
+	return service.store.update(func(tx *bolt.Tx) error {
 		bucket := tx.Bucket([]byte(stackBucketName))
 		err := bucket.Delete([]byte(ID))
 		if err != nil {
@@ -1,6 +1,9 @@
 package bolt
 
 import (
+	"bytes"
+	"strconv"
+
 	"cloudware/cloudware/api"
 	"cloudware/cloudware/bolt/internal"
 
@@ -10,12 +13,13 @@ import (
 // TeamMembershipService represents a service for managing TeamMembership objects.
 type TeamMembershipService struct {
 	store *Store
+	cache *cache
 }
 
 // TeamMembership returns a TeamMembership object by ID
 func (service *TeamMembershipService) TeamMembership(ID api.TeamMembershipID) (*api.TeamMembership, error) {
 	var data []byte
-	err := service.store.db.View(func(tx *bolt.Tx) error {
+	err := service.store.view(func(tx *bolt.Tx) error {
 		bucket := tx.Bucket([]byte(teamMembershipBucketName))
 		value := bucket.Get(internal.Itob(int(ID)))
 		if value == nil {
@@ -41,7 +45,7 @@ func (service *TeamMembershipService) TeamMembership(ID api.TeamMembershipID) (*
 // TeamMemberships return an array containing all the TeamMembership objects.
 func (service *TeamMembershipService) TeamMemberships() ([]api.TeamMembership, error) {
 	var memberships = make([]api.TeamMembership, 0)
-	err := service.store.db.View(func(tx *bolt.Tx) error {
+	err := service.store.view(func(tx *bolt.Tx) error {
 		bucket := tx.Bucket([]byte(teamMembershipBucketName))
 
 		cursor := bucket.Cursor()
@@ -65,20 +69,30 @@ func (service *TeamMembershipService) TeamMemberships() ([]api.TeamMembership, e
 
 // TeamMembershipsByUserID return an array containing all the TeamMembership objects where the specified userID is present.
 func (service *TeamMembershipService) TeamMembershipsByUserID(userID api.UserID) ([]api.TeamMembership, error) {
+	cacheKey := strconv.Itoa(int(userID))
+	cached, ok, generation := service.cache.get(cacheKey)
+	if ok {
+		return append([]api.TeamMembership(nil), cached.([]api.TeamMembership)...), nil
+	}
+
 	var memberships = make([]api.TeamMembership, 0)
-	err := service.store.db.View(func(tx *bolt.Tx) error {
+	err := service.store.view(func(tx *bolt.Tx) error {
 		bucket := tx.Bucket([]byte(teamMembershipBucketName))
 
-		cursor := bucket.Cursor()
-		for k, v := cursor.First(); k != nil; k, v = cursor.Next() {
+		prefix := internal.Itob(int(userID))
+		cursor := tx.Bucket([]byte(teamMembershipUserIndexBucketName)).Cursor()
+		for k, v := cursor.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = cursor.Next() {
+			value := bucket.Get(v)
+			if value == nil {
+				continue
+			}
+
 			var membership api.TeamMembership
-			err := internal.UnmarshalTeamMembership(v, &membership)
+			err := internal.UnmarshalTeamMembership(value, &membership)
 			if err != nil {
 				return err
 			}
-			if membership.UserID == userID {
-				memberships = append(memberships, membership)
-			}
+			memberships = append(memberships, membership)
 		}
 
 		return nil
@@ -87,13 +101,14 @@ func (service *TeamMembershipService) TeamMembershipsByUserID(userID api.UserID)
 		return nil, err
 	}
 
-	return memberships, nil
+	service.cache.set(cacheKey, memberships, generation)
+	return append([]api.TeamMembership(nil), memberships...), nil
 }
 
 // TeamMembershipsByTeamID return an array containing all the TeamMembership objects where the specified teamID is present.
 func (service *TeamMembershipService) TeamMembershipsByTeamID(teamID api.TeamID) ([]api.TeamMembership, error) {
 	var memberships = make([]api.TeamMembership, 0)
-	err := service.store.db.View(func(tx *bolt.Tx) error {
+	err := service.store.view(func(tx *bolt.Tx) error {
 		bucket := tx.Bucket([]byte(teamMembershipBucketName))
 
 		cursor := bucket.Cursor()
@@ -124,20 +139,30 @@ func (service *TeamMembershipService) UpdateTeamMembership(ID api.TeamMembership
 		return err
 	}
 
-	return service.store.db.Update(func(tx *bolt.Tx) error {
+	defer service.cache.invalidate()
+	return service.store.update(func(tx *bolt.Tx) error {
 		bucket := tx.Bucket([]byte(teamMembershipBucketName))
-		err = bucket.Put(internal.Itob(int(ID)), data)
 
+		err := deleteTeamMembership(tx, bucket, internal.Itob(int(ID)))
 		if err != nil {
 			return err
 		}
-		return nil
+
+		err = bucket.Put(internal.Itob(int(ID)), data)
+		if err != nil {
+			return err
+		}
+
+		updated := *membership
+		updated.ID = ID
+		return putTeamMembershipIndex(tx, &updated)
 	})
 }
 
 // CreateTeamMembership creates a new TeamMembership object.
 func (service *TeamMembershipService) CreateTeamMembership(membership *api.TeamMembership) error {
-	return service.store.db.Update(func(tx *bolt.Tx) error {
+	defer service.cache.invalidate()
+	return service.store.update(func(tx *bolt.Tx) error {
 		bucket := tx.Bucket([]byte(teamMembershipBucketName))
 
 		id, _ := bucket.NextSequence()
@@ -152,66 +177,82 @@ func (service *TeamMembershipService) CreateTeamMembership(membership *api.TeamM
 		if err != nil {
 			return err
 		}
-		return nil
+		return putTeamMembershipIndex(tx, membership)
 	})
 }
 
 // DeleteTeamMembership deletes a TeamMembership object.
 func (service *TeamMembershipService) DeleteTeamMembership(ID api.TeamMembershipID) error {
-	return service.store.db.Update(func(tx *bolt.Tx) error {
+	defer service.cache.invalidate()
+	return service.store.update(func(tx *bolt.Tx) error {
 		bucket := tx.Bucket([]byte(teamMembershipBucketName))
-		err := bucket.Delete(internal.Itob(int(ID)))
-		if err != nil {
-			return err
-		}
-		return nil
+		return deleteTeamMembership(tx, bucket, internal.Itob(int(ID)))
 	})
 }
 
 // DeleteTeamMembershipByUserID deletes all the TeamMembership object associated to a UserID.
 func (service *TeamMembershipService) DeleteTeamMembershipByUserID(userID api.UserID) error {
-	return service.store.db.Update(func(tx *bolt.Tx) error {
-		bucket := tx.Bucket([]byte(teamMembershipBucketName))
-
-		cursor := bucket.Cursor()
-		for k, v := cursor.First(); k != nil; k, v = cursor.Next() {
-			var membership api.TeamMembership
-			err := internal.UnmarshalTeamMembership(v, &membership)
-			if err != nil {
-				return err
-			}
-			if membership.UserID == userID {
-				err := bucket.Delete(internal.Itob(int(membership.ID)))
-				if err != nil {
-					return err
-				}
-			}
-		}
-
-		return nil
+	return service.deleteTeamMemberships(func(membership *api.TeamMembership) bool {
+		return membership.UserID == userID
 	})
 }
 
 // DeleteTeamMembershipByTeamID deletes all the TeamMembership object associated to a TeamID.
 func (service *TeamMembershipService) DeleteTeamMembershipByTeamID(teamID api.TeamID) error {
-	return service.store.db.Update(func(tx *bolt.Tx) error {
+	return service.deleteTeamMemberships(func(membership *api.TeamMembership) bool {
+		return membership.TeamID == teamID
+	})
+}
+
+// deleteTeamMemberships deletes the TeamMembership objects matching the filter. The keys are collected
+// first as deleting while iterating with a cursor skips entries.
+func (service *TeamMembershipService) deleteTeamMemberships(filter func(membership *api.TeamMembership) bool) error {
+	defer service.cache.invalidate()
+	return service.store.update(func(tx *bolt.Tx) error {
 		bucket := tx.Bucket([]byte(teamMembershipBucketName))
 
-		cursor := bucket.Cursor()
-		for k, v := cursor.First(); k != nil; k, v = cursor.Next() {
+		keys := make([][]byte, 0)
+		err := bucket.ForEach(func(k, v []byte) error {
 			var membership api.TeamMembership
 			err := internal.UnmarshalTeamMembership(v, &membership)
 			if err != nil {
 				return err
 			}
-			if membership.TeamID == teamID {
-				err := bucket.Delete(internal.Itob(int(membership.ID)))
-				if err != nil {
-					return err
-				}
+			if filter(&membership) {
+				keys = append(keys, append([]byte(nil), k...))
 			}
+			return nil
+		})
+		if err != nil {
+			return err
 		}
 
+		for _, key := range keys {
+			err = deleteTeamMembership(tx, bucket, key)
+			if err != nil {
+				return err
+			}
+		}
 		return nil
 	})
 }
+
+// deleteTeamMembership deletes the TeamMembership object stored under a key along with its index entry.
+func deleteTeamMembership(tx *bolt.Tx, bucket *bolt.Bucket, key []byte) error {
+	value := bucket.Get(key)
+	if value == nil {
+		return nil
+	}
+
+	var membership api.TeamMembership
+	err := internal.UnmarshalTeamMembership(value, &membership)
+	if err != nil {
+		return err
+	}
+
+	err = deleteTeamMembershipIndex(tx, &membership)
+	if err != nil {
+		return err
+	}
+	return bucket.Delete(key)
+}
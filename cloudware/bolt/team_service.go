@@ -15,7 +15,7 @@ type TeamService struct {
 // Team returns a Team by ID
 func (service *TeamService) Team(ID api.TeamID) (*api.Team, error) {
 	var data []byte
-	err := service.store.db.View(func(tx *bolt.Tx) error {
+	err := service.store.view(func(tx *bolt.Tx) error {
 		bucket := tx.Bucket([]byte(teamBucketName))
 		value := bucket.Get(internal.Itob(int(ID)))
 		if value == nil {
@@ -42,7 +42,7 @@ func (service *TeamService) Team(ID api.TeamID) (*api.Team, error) {
 func (service *TeamService) TeamByName(name string) (*api.Team, error) {
 	var team *api.Team
 
-	err := service.store.db.View(func(tx *bolt.Tx) error {
+	err := service.store.view(func(tx *bolt.Tx) error {
 		bucket := tx.Bucket([]byte(teamBucketName))
 		cursor := bucket.Cursor()
 		for k, v := cursor.First(); k != nil; k, v = cursor.Next() {
@@ -70,7 +70,7 @@ func (service *TeamService) TeamByName(name string) (*api.Team, error) {
 // Teams return an array containing all the teams.
 func (service *TeamService) Teams() ([]api.Team, error) {
 	var teams = make([]api.Team, 0)
-	err := service.store.db.View(func(tx *bolt.Tx) error {
+	err := service.store.view(func(tx *bolt.Tx) error {
 		bucket := tx.Bucket([]byte(teamBucketName))
 
 		cursor := bucket.Cursor()
@@ -92,6 +92,46 @@ func (service *TeamService) Teams() ([]api.Team, error) {
 	return teams, nil
 }
 
+// ListTeams returns the page of the teams matching a query, along with the total number of matching teams.
+func (service *TeamService) ListTeams(query *api.TeamQuery) ([]api.Team, int, error) {
+	var teams = make([]api.Team, 0)
+	pager := newPager(&query.ListOptions)
+	err := service.store.view(func(tx *bolt.Tx) error {
+		return pager.scan(tx.Bucket([]byte(teamBucketName)), func(v []byte) error {
+			var team api.Team
+			err := internal.UnmarshalTeam(v, &team)
+			if err != nil {
+				return err
+			}
+
+			if !query.MatchName(team.Name) || (query.TeamIDs != nil && !containsTeamID(query.TeamIDs, team.ID)) {
+				return nil
+			}
+			if pager.keep() {
+				teams = append(teams, team)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	start, end := pager.sort(len(teams), func(i, j int) { teams[i], teams[j] = teams[j], teams[i] }, func(i, j int) bool {
+		return lessFold(teams[i].Name, teams[j].Name, int(teams[i].ID), int(teams[j].ID))
+	})
+	return teams[start:end], pager.total, nil
+}
+
+func containsTeamID(teamIDs []api.TeamID, teamID api.TeamID) bool {
+	for _, id := range teamIDs {
+		if id == teamID {
+			return true
+		}
+	}
+	return false
+}
+
 // UpdateTeam saves a Team.
 func (service *TeamService) UpdateTeam(ID api.TeamID, team *api.Team) error {
 	data, err := internal.MarshalTeam(team)
@@ -99,7 +139,7 @@ func (service *TeamService) UpdateTeam(ID api.TeamID, team *api.Team) error {
 		return err
 	}
 
-	return service.store.db.Update(func(tx *bolt.Tx) error {
+	return service.store.update(func(tx *bolt.Tx) error {
 		bucket := tx.Bucket([]byte(teamBucketName))
 		err = bucket.Put(internal.Itob(int(ID)), data)
 
@@ -112,7 +152,7 @@ func (service *TeamService) UpdateTeam(ID api.TeamID, team *api.Team) error {
 
 // CreateTeam creates a new Team.
 func (service *TeamService) CreateTeam(team *api.Team) error {
-	return service.store.db.Update(func(tx *bolt.Tx) error {
+	return service.store.update(func(tx *bolt.Tx) error {
 		bucket := tx.Bucket([]byte(teamBucketName))
 
 		id, _ := bucket.NextSequence()
@@ -133,7 +173,7 @@ func (service *TeamService) CreateTeam(team *api.Team) error {
 
 // DeleteTeam deletes a Team.
 func (service *TeamService) DeleteTeam(ID api.TeamID) error {
-	return service.store.db.Update(func(tx *bolt.Tx) error {
+	return service.store.update(func(tx *bolt.Tx) error {
 		bucket := tx.Bucket([]byte(teamBucketName))
 		err := bucket.Delete(internal.Itob(int(ID)))
 		if err != nil {
@@ -0,0 +1,206 @@
+package bolt
+
+import (
+	"cloudware/cloudware/api"
+	"cloudware/cloudware/bolt/internal"
+
+	"github.com/boltdb/bolt"
+)
+
+// TemplateService represents a service for managing application templates.
+type TemplateService struct {
+	store *Store
+}
+
+// Template returns a template by ID.
+func (service *TemplateService) Template(ID api.TemplateID) (*api.Template, error) {
+	var data []byte
+	err := service.store.view(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(templateBucketName))
+		value := bucket.Get(internal.Itob(int(ID)))
+		if value == nil {
+			return api.ErrTemplateNotFound
+		}
+
+		data = make([]byte, len(value))
+		copy(data, value)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var template api.Template
+	err = internal.UnmarshalTemplate(data, &template)
+	if err != nil {
+		return nil, err
+	}
+	return &template, nil
+}
+
+// Templates returns an array containing all the templates.
+func (service *TemplateService) Templates() ([]api.Template, error) {
+	var templates = make([]api.Template, 0)
+	err := service.store.view(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(templateBucketName))
+
+		cursor := bucket.Cursor()
+		for k, v := cursor.First(); k != nil; k, v = cursor.Next() {
+			var template api.Template
+			err := internal.UnmarshalTemplate(v, &template)
+			if err != nil {
+				return err
+			}
+			templates = append(templates, template)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return templates, nil
+}
+
+// ListTemplates returns the page of the templates matching a query, along with the total number of matching templates.
+func (service *TemplateService) ListTemplates(query *api.TemplateQuery) ([]api.Template, int, error) {
+	var templates = make([]api.Template, 0)
+	pager := newPager(&query.ListOptions)
+	err := service.store.view(func(tx *bolt.Tx) error {
+		return pager.scan(tx.Bucket([]byte(templateBucketName)), func(v []byte) error {
+			var template api.Template
+			err := internal.UnmarshalTemplate(v, &template)
+			if err != nil {
+				return err
+			}
+
+			if !query.MatchName(template.Title) ||
+				(query.Type != 0 && template.Type != query.Type) ||
+				(query.Category != "" && !containsCategory(template.Categories, query.Category)) ||
+				(query.Access != nil && len(template.AuthorizedTeams) > 0 && !query.Access.Authorized(nil, template.AuthorizedTeams)) {
+				return nil
+			}
+			if pager.keep() {
+				templates = append(templates, template)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	start, end := pager.sort(len(templates), func(i, j int) { templates[i], templates[j] = templates[j], templates[i] }, func(i, j int) bool {
+		if query.Sort == api.SortByType && templates[i].Type != templates[j].Type {
+			return templates[i].Type < templates[j].Type
+		}
+		return lessFold(templates[i].Title, templates[j].Title, int(templates[i].ID), int(templates[j].ID))
+	})
+	return templates[start:end], pager.total, nil
+}
+
+// CreateTemplate creates a new template.
+func (service *TemplateService) CreateTemplate(template *api.Template) error {
+	return service.store.update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(templateBucketName))
+
+		id, _ := bucket.NextSequence()
+		template.ID = api.TemplateID(id)
+
+		data, err := internal.MarshalTemplate(template)
+		if err != nil {
+			return err
+		}
+
+		return bucket.Put(internal.Itob(int(template.ID)), data)
+	})
+}
+
+// UpdateTemplate updates a template.
+func (service *TemplateService) UpdateTemplate(ID api.TemplateID, template *api.Template) error {
+	data, err := internal.MarshalTemplate(template)
+	if err != nil {
+		return err
+	}
+
+	return service.store.update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(templateBucketName))
+		return bucket.Put(internal.Itob(int(ID)), data)
+	})
+}
+
+// DeleteTemplate deletes a template.
+func (service *TemplateService) DeleteTemplate(ID api.TemplateID) error {
+	return service.store.update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(templateBucketName))
+		return bucket.Delete(internal.Itob(int(ID)))
+	})
+}
+
+// ReplaceTemplates replaces the templates imported from a source in a single transaction.
+// A template with the title of a template previously imported from the source keeps its
+// identifier and its authorized teams, the templates no longer part of the source are deleted.
+func (service *TemplateService) ReplaceTemplates(source string, templates []api.Template) error {
+	return service.store.update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(templateBucketName))
+
+		existing := make(map[string]api.Template)
+		stale := make(map[api.TemplateID]bool)
+		cursor := bucket.Cursor()
+		for k, v := cursor.First(); k != nil; k, v = cursor.Next() {
+			var template api.Template
+			err := internal.UnmarshalTemplate(v, &template)
+			if err != nil {
+				return err
+			}
+			if template.Source != source {
+				continue
+			}
+			if _, ok := existing[template.Title]; !ok {
+				existing[template.Title] = template
+			}
+			stale[template.ID] = true
+		}
+
+		for idx := range templates {
+			template := &templates[idx]
+			template.Source = source
+			if previous, ok := existing[template.Title]; ok {
+				template.ID = previous.ID
+				template.AuthorizedTeams = previous.AuthorizedTeams
+				delete(existing, template.Title)
+				delete(stale, template.ID)
+			} else {
+				id, _ := bucket.NextSequence()
+				template.ID = api.TemplateID(id)
+			}
+
+			data, err := internal.MarshalTemplate(template)
+			if err != nil {
+				return err
+			}
+			err = bucket.Put(internal.Itob(int(template.ID)), data)
+			if err != nil {
+				return err
+			}
+		}
+
+		for ID := range stale {
+			err := bucket.Delete(internal.Itob(int(ID)))
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func containsCategory(categories []string, category string) bool {
+	for _, c := range categories {
+		if c == category {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,36 @@
+package bolt
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+// NewTestStore opens a store in a new temporary directory for the tests, the directory is the Path
+// of the store. The returned function closes the store and removes the directory.
+func NewTestStore(t testing.TB) (*Store, func()) {
+	dataPath, err := ioutil.TempDir("", "store")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store := OpenTestStore(t, dataPath)
+	return store, func() {
+		store.Close()
+		os.RemoveAll(dataPath)
+	}
+}
+
+// OpenTestStore opens the store of a data directory for the tests.
+func OpenTestStore(t testing.TB, dataPath string) *Store {
+	store, err := NewStore(dataPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = store.Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return store
+}
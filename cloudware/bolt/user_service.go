@@ -10,12 +10,13 @@ import (
 // UserService represents a service for managing users.
 type UserService struct {
 	store *Store
+	cache *cache
 }
 
 // User returns a user by ID
 func (service *UserService) User(ID api.UserID) (*api.User, error) {
 	var data []byte
-	err := service.store.db.View(func(tx *bolt.Tx) error {
+	err := service.store.view(func(tx *bolt.Tx) error {
 		bucket := tx.Bucket([]byte(userBucketName))
 		value := bucket.Get(internal.Itob(int(ID)))
 		if value == nil {
@@ -40,37 +41,48 @@ func (service *UserService) User(ID api.UserID) (*api.User, error) {
 
 // UserByUsername returns a user by username.
 func (service *UserService) UserByUsername(username string) (*api.User, error) {
-	var user *api.User
+	cached, ok, generation := service.cache.get(username)
+	if ok {
+		return copyUser(cached)
+	}
 
-	err := service.store.db.View(func(tx *bolt.Tx) error {
-		bucket := tx.Bucket([]byte(userBucketName))
-		cursor := bucket.Cursor()
-		for k, v := cursor.First(); k != nil; k, v = cursor.Next() {
-			var u api.User
-			err := internal.UnmarshalUser(v, &u)
-			if err != nil {
-				return err
-			}
-			if u.Username == username {
-				user = &u
-			}
+	var user *api.User
+	err := service.store.view(func(tx *bolt.Tx) error {
+		key := tx.Bucket([]byte(userUsernameIndexBucketName)).Get([]byte(username))
+		if key == nil {
+			return nil
 		}
 
-		if user == nil {
-			return api.ErrUserNotFound
+		value := tx.Bucket([]byte(userBucketName)).Get(key)
+		if value == nil {
+			return nil
 		}
-		return nil
+
+		user = &api.User{}
+		return internal.UnmarshalUser(value, user)
 	})
 	if err != nil {
 		return nil, err
 	}
-	return user, nil
+
+	service.cache.set(username, user, generation)
+	return copyUser(user)
+}
+
+// copyUser returns a copy of a cached user so that it cannot be modified by the caller.
+func copyUser(cached interface{}) (*api.User, error) {
+	user := cached.(*api.User)
+	if user == nil {
+		return nil, api.ErrUserNotFound
+	}
+	copied := *user
+	return &copied, nil
 }
 
 // Users return an array containing all the users.
 func (service *UserService) Users() ([]api.User, error) {
 	var users = make([]api.User, 0)
-	err := service.store.db.View(func(tx *bolt.Tx) error {
+	err := service.store.view(func(tx *bolt.Tx) error {
 		bucket := tx.Bucket([]byte(userBucketName))
 
 		cursor := bucket.Cursor()
@@ -95,7 +107,7 @@ func (service *UserService) Users() ([]api.User, error) {
 // UsersByRole return an array containing all the users with the specified role.
 func (service *UserService) UsersByRole(role api.UserRole) ([]api.User, error) {
 	var users = make([]api.User, 0)
-	err := service.store.db.View(func(tx *bolt.Tx) error {
+	err := service.store.view(func(tx *bolt.Tx) error {
 		bucket := tx.Bucket([]byte(userBucketName))
 
 		cursor := bucket.Cursor()
@@ -118,6 +130,39 @@ func (service *UserService) UsersByRole(role api.UserRole) ([]api.User, error) {
 	return users, nil
 }
 
+// ListUsers returns the page of the users matching a query, along with the total number of matching users.
+func (service *UserService) ListUsers(query *api.UserQuery) ([]api.User, int, error) {
+	var users = make([]api.User, 0)
+	pager := newPager(&query.ListOptions)
+	err := service.store.view(func(tx *bolt.Tx) error {
+		return pager.scan(tx.Bucket([]byte(userBucketName)), func(v []byte) error {
+			var user api.User
+			err := internal.UnmarshalUser(v, &user)
+			if err != nil {
+				return err
+			}
+
+			if !query.MatchName(user.Username) ||
+				(query.Role != 0 && user.Role != query.Role) ||
+				(query.ExcludeAdministrators && user.Role == api.AdministratorRole) {
+				return nil
+			}
+			if pager.keep() {
+				users = append(users, user)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	start, end := pager.sort(len(users), func(i, j int) { users[i], users[j] = users[j], users[i] }, func(i, j int) bool {
+		return lessFold(users[i].Username, users[j].Username, int(users[i].ID), int(users[j].ID))
+	})
+	return users[start:end], pager.total, nil
+}
+
 // UpdateUser saves a user.
 func (service *UserService) UpdateUser(ID api.UserID, user *api.User) error {
 	data, err := internal.MarshalUser(user)
@@ -125,20 +170,30 @@ func (service *UserService) UpdateUser(ID api.UserID, user *api.User) error {
 		return err
 	}
 
-	return service.store.db.Update(func(tx *bolt.Tx) error {
+	defer service.cache.invalidate()
+	return service.store.update(func(tx *bolt.Tx) error {
 		bucket := tx.Bucket([]byte(userBucketName))
-		err = bucket.Put(internal.Itob(int(ID)), data)
 
+		previous, err := userByKey(bucket, internal.Itob(int(ID)))
 		if err != nil {
 			return err
 		}
-		return nil
+
+		err = bucket.Put(internal.Itob(int(ID)), data)
+		if err != nil {
+			return err
+		}
+
+		updated := *user
+		updated.ID = ID
+		return updateUserIndex(tx, previous, &updated)
 	})
 }
 
 // CreateUser creates a new user.
 func (service *UserService) CreateUser(user *api.User) error {
-	return service.store.db.Update(func(tx *bolt.Tx) error {
+	defer service.cache.invalidate()
+	return service.store.update(func(tx *bolt.Tx) error {
 		bucket := tx.Bucket([]byte(userBucketName))
 
 		id, _ := bucket.NextSequence()
@@ -153,18 +208,40 @@ func (service *UserService) CreateUser(user *api.User) error {
 		if err != nil {
 			return err
 		}
-		return nil
+		return updateUserIndex(tx, nil, user)
 	})
 }
 
 // DeleteUser deletes a user.
 func (service *UserService) DeleteUser(ID api.UserID) error {
-	return service.store.db.Update(func(tx *bolt.Tx) error {
+	defer service.cache.invalidate()
+	return service.store.update(func(tx *bolt.Tx) error {
 		bucket := tx.Bucket([]byte(userBucketName))
-		err := bucket.Delete(internal.Itob(int(ID)))
+
+		previous, err := userByKey(bucket, internal.Itob(int(ID)))
 		if err != nil {
 			return err
 		}
-		return nil
+
+		err = bucket.Delete(internal.Itob(int(ID)))
+		if err != nil {
+			return err
+		}
+		return updateUserIndex(tx, previous, nil)
 	})
 }
+
+// userByKey returns the user stored under a key, or nil if there is none.
+func userByKey(bucket *bolt.Bucket, key []byte) (*api.User, error) {
+	value := bucket.Get(key)
+	if value == nil {
+		return nil, nil
+	}
+
+	var user api.User
+	err := internal.UnmarshalUser(value, &user)
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
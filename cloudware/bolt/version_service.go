@@ -15,44 +15,78 @@ type VersionService struct {
 
 const (
 	dBVersionKey = "DB_VERSION"
+	// numberedDBVersionKey marks the versions stored since the migrations are numbered.
+	numberedDBVersionKey = "NUMBERED_DB_VERSION"
 )
 
 // DBVersion retrieves the stored database version.
 func (service *VersionService) DBVersion() (int, error) {
-	var data []byte
-	err := service.store.db.View(func(tx *bolt.Tx) error {
-		bucket := tx.Bucket([]byte(versionBucketName))
-		value := bucket.Get([]byte(dBVersionKey))
-		if value == nil {
-			return api.ErrDBVersionNotFound
-		}
-
-		data = make([]byte, len(value))
-		copy(data, value)
-		return nil
+	var version int
+	err := service.store.view(func(tx *bolt.Tx) error {
+		var err error
+		version, err = dbVersion(tx)
+		return err
 	})
-	if err != nil {
-		return 0, err
+	return version, err
+}
+
+// dbVersion returns the version of the data model of the database. Before the migrations were numbered,
+// databases were stamped with version 1 once all the migrations were applied. Unlike the databases
+// actually at version 1, they contain the team membership bucket, which must therefore be checked
+// before the buckets are created by Open.
+func dbVersion(tx *bolt.Tx) (int, error) {
+	bucket := tx.Bucket([]byte(versionBucketName))
+	if bucket == nil {
+		return 0, api.ErrDBVersionNotFound
 	}
 
-	dbVersion, err := strconv.Atoi(string(data))
+	value := bucket.Get([]byte(dBVersionKey))
+	if value == nil {
+		return 0, api.ErrDBVersionNotFound
+	}
+
+	version, err := strconv.Atoi(string(value))
 	if err != nil {
 		return 0, err
 	}
 
-	return dbVersion, nil
+	if version == 1 && bucket.Get([]byte(numberedDBVersionKey)) == nil && tx.Bucket([]byte(teamMembershipBucketName)) != nil {
+		return unnumberedMigrationsDBVersion, nil
+	}
+	return version, nil
+}
+
+// numberDBVersion stores the version of a database stamped before the migrations were numbered
+// along with the numbered version marker.
+func numberDBVersion(tx *bolt.Tx) error {
+	bucket := tx.Bucket([]byte(versionBucketName))
+	if bucket == nil || bucket.Get([]byte(numberedDBVersionKey)) != nil {
+		return nil
+	}
+
+	version, err := dbVersion(tx)
+	if err == api.ErrDBVersionNotFound {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	return storeDBVersion(tx, version)
 }
 
 // StoreDBVersion store the database version.
 func (service *VersionService) StoreDBVersion(version int) error {
-	return service.store.db.Update(func(tx *bolt.Tx) error {
-		bucket := tx.Bucket([]byte(versionBucketName))
-
-		data := []byte(strconv.Itoa(version))
-		err := bucket.Put([]byte(dBVersionKey), data)
-		if err != nil {
-			return err
-		}
-		return nil
+	return service.store.update(func(tx *bolt.Tx) error {
+		return storeDBVersion(tx, version)
 	})
 }
+
+func storeDBVersion(tx *bolt.Tx, version int) error {
+	bucket := tx.Bucket([]byte(versionBucketName))
+
+	data := []byte(strconv.Itoa(version))
+	err := bucket.Put([]byte(dBVersionKey), data)
+	if err != nil {
+		return err
+	}
+	return bucket.Put([]byte(numberedDBVersionKey), []byte("1"))
+}
@@ -2,6 +2,7 @@ package config
 
 import (
 	"os"
+	"path/filepath"
 )
 
 var (
@@ -9,11 +10,15 @@ var (
 	configFileDir = ".cloudware"
 )
 
-// Dir returns the path to the configuration directory as specified by the DOCKER_CONFIG environment variable.
-// TODO: this was copied from cli/config/configfile and should be removed once cmd/dockerd moves
+// Dir returns the path to the configuration directory as specified by the CLOUDWARE_CONFIG environment variable,
+// ~/.cloudware when it is not set.
 func Dir() string {
 	return configDir
 }
 
 func init() {
+	if configDir == "" {
+		home, _ := os.UserHomeDir()
+		configDir = filepath.Join(home, configFileDir)
+	}
 }
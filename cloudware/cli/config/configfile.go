@@ -0,0 +1,98 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+const (
+	// ConfigFileName is the name of the configuration file of the command-line client.
+	ConfigFileName = "config.json"
+)
+
+type (
+	// ConfigFile represents the configuration of the command-line client, the instances it can
+	// connect to are stored as named contexts.
+	ConfigFile struct {
+		CurrentContext string              `json:"CurrentContext,omitempty"`
+		Contexts       map[string]*Context `json:"Contexts"`
+		Filename       string              `json:"-"`
+	}
+
+	// Context represents an instance along with the token of the logged in user.
+	Context struct {
+		URL                string `json:"URL"`
+		InsecureSkipVerify bool   `json:"InsecureSkipVerify,omitempty"`
+		Username           string `json:"Username,omitempty"`
+		Token              string `json:"Token,omitempty"`
+	}
+)
+
+// Load reads the configuration file from the specified directory, the configuration directory
+// is used when it is empty. An empty configuration is returned when the file does not exist.
+func Load(dir string) (*ConfigFile, error) {
+	if dir == "" {
+		dir = Dir()
+	}
+
+	configFile := &ConfigFile{
+		Contexts: make(map[string]*Context),
+		Filename: filepath.Join(dir, ConfigFileName),
+	}
+
+	data, err := ioutil.ReadFile(configFile.Filename)
+	if os.IsNotExist(err) {
+		return configFile, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	err = json.Unmarshal(data, configFile)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid configuration file %s: %v", configFile.Filename, err)
+	}
+	if configFile.Contexts == nil {
+		configFile.Contexts = make(map[string]*Context)
+	}
+	return configFile, nil
+}
+
+// Save writes the configuration file, it is only readable by its owner as it contains tokens.
+func (configFile *ConfigFile) Save() error {
+	data, err := json.MarshalIndent(configFile, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(configFile.Filename), 0700)
+	if err != nil {
+		return err
+	}
+
+	// The file is replaced atomically so that a failed write does not lose the existing contexts.
+	temporary := configFile.Filename + ".tmp"
+	err = ioutil.WriteFile(temporary, data, 0600)
+	if err != nil {
+		return err
+	}
+	return os.Rename(temporary, configFile.Filename)
+}
+
+// Context returns the context with the specified name, the current context when name is empty.
+func (configFile *ConfigFile) Context(name string) (*Context, error) {
+	if name == "" {
+		name = configFile.CurrentContext
+	}
+	if name == "" {
+		return nil, fmt.Errorf("No context is selected, log in to an instance with 'cloudware login URL'")
+	}
+
+	context, ok := configFile.Contexts[name]
+	if !ok {
+		return nil, fmt.Errorf("Context %s not found", name)
+	}
+	return context, nil
+}
@@ -0,0 +1,142 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadMissingFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	configFile, err := Load(filepath.Join(dir, "missing"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if configFile.CurrentContext != "" || configFile.Contexts == nil || len(configFile.Contexts) != 0 {
+		t.Fatalf("expected an empty configuration, got %+v", configFile)
+	}
+	if configFile.Filename != filepath.Join(dir, "missing", ConfigFileName) {
+		t.Fatalf("unexpected filename %s", configFile.Filename)
+	}
+}
+
+func TestSaveAndLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// The directory is created on save.
+	configFile, err := Load(filepath.Join(dir, "nested"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	configFile.Contexts["prod"] = &Context{URL: "https://prod.example.com", InsecureSkipVerify: true, Username: "admin", Token: "token"}
+	configFile.Contexts["dev"] = &Context{URL: "http://localhost:9000"}
+	configFile.CurrentContext = "prod"
+
+	err = configFile.Save()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := os.Stat(configFile.Filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Fatalf("expected the file to be only readable by its owner, got %v", info.Mode().Perm())
+	}
+	if _, err := os.Stat(configFile.Filename + ".tmp"); !os.IsNotExist(err) {
+		t.Fatalf("expected the temporary file to be renamed, got %v", err)
+	}
+
+	loaded, err := Load(filepath.Join(dir, "nested"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.CurrentContext != "prod" || len(loaded.Contexts) != 2 {
+		t.Fatalf("unexpected configuration %+v", loaded)
+	}
+	if context := loaded.Contexts["prod"]; *context != *configFile.Contexts["prod"] {
+		t.Fatalf("expected the context to be restored, got %+v", context)
+	}
+
+	// Saving again replaces the file.
+	delete(loaded.Contexts, "dev")
+	err = loaded.Save()
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded, err = Load(filepath.Join(dir, "nested"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := loaded.Contexts["dev"]; ok || len(loaded.Contexts) != 1 {
+		t.Fatalf("expected the removed context to be gone, got %+v", loaded.Contexts)
+	}
+}
+
+func TestLoadInvalidFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	err = ioutil.WriteFile(filepath.Join(dir, ConfigFileName), []byte("{"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = Load(dir)
+	if err == nil {
+		t.Fatal("expected an invalid file to be rejected")
+	}
+
+	err = ioutil.WriteFile(filepath.Join(dir, ConfigFileName), []byte(`{"CurrentContext":"prod"}`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	configFile, err := Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if configFile.Contexts == nil {
+		t.Fatal("expected the contexts to be initialized")
+	}
+}
+
+func TestContext(t *testing.T) {
+	configFile := &ConfigFile{Contexts: map[string]*Context{
+		"prod": {URL: "https://prod.example.com"},
+		"dev":  {URL: "http://localhost:9000"},
+	}}
+
+	_, err := configFile.Context("")
+	if err == nil {
+		t.Fatal("expected an error without selected context")
+	}
+
+	configFile.CurrentContext = "prod"
+	context, err := configFile.Context("")
+	if err != nil || context.URL != "https://prod.example.com" {
+		t.Fatalf("expected the current context, got %+v, %v", context, err)
+	}
+
+	context, err = configFile.Context("dev")
+	if err != nil || context.URL != "http://localhost:9000" {
+		t.Fatalf("expected the named context, got %+v, %v", context, err)
+	}
+
+	_, err = configFile.Context("staging")
+	if err == nil {
+		t.Fatal("expected an error for an unknown context")
+	}
+}
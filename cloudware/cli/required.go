@@ -25,3 +25,20 @@ func NoArgs(cmd *cobra.Command, args []string) error {
 		cmd.Short,
 	)
 }
+
+// ExactArgs returns an error if there is not the exact number of args
+func ExactArgs(number int) func(cmd *cobra.Command, args []string) error {
+	return func(cmd *cobra.Command, args []string) error {
+		if len(args) == number {
+			return nil
+		}
+		return errors.Errorf(
+			"\"%s\" requires exactly %d argument(s).\nSee '%s --help'.\n\nUsage:  %s\n\n%s",
+			cmd.CommandPath(),
+			number,
+			cmd.CommandPath(),
+			cmd.UseLine(),
+			cmd.Short,
+		)
+	}
+}
@@ -0,0 +1,56 @@
+package client
+
+import (
+	"net/http"
+	"net/url"
+
+	"cloudware/cloudware/api"
+)
+
+type (
+	// AggregateResponse represents the Docker resources of all the endpoints accessible by the user.
+	// Each item is annotated with the endpoint it comes from, the endpoints which cannot be reached
+	// are reported in Errors.
+	AggregateResponse struct {
+		Items  []map[string]interface{} `json:"Items"`
+		Errors []AggregateError         `json:"Errors"`
+	}
+
+	// AggregateError represents an endpoint which could not be queried.
+	AggregateError struct {
+		Endpoint struct {
+			ID   api.EndpointID `json:"Id"`
+			Name string         `json:"Name"`
+		} `json:"Endpoint"`
+		Err string `json:"err"`
+	}
+)
+
+func (client *Client) aggregate(path string, query url.Values) (*AggregateResponse, error) {
+	var response AggregateResponse
+	err := client.do(http.MethodGet, path, query, nil, &response)
+	if err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// Containers returns the containers of all the endpoints, the query is forwarded to the Docker API.
+func (client *Client) Containers(query url.Values) (*AggregateResponse, error) {
+	return client.aggregate("/containers", query)
+}
+
+// Services returns the services of all the endpoints, the query is forwarded to the Docker API.
+func (client *Client) Services(query url.Values) (*AggregateResponse, error) {
+	return client.aggregate("/services", query)
+}
+
+// Volumes returns the volumes of all the endpoints, the query is forwarded to the Docker API.
+func (client *Client) Volumes(query url.Values) (*AggregateResponse, error) {
+	return client.aggregate("/volumes", query)
+}
+
+// Images returns the images of all the endpoints, the query is forwarded to the Docker API.
+func (client *Client) Images(query url.Values) (*AggregateResponse, error) {
+	return client.aggregate("/images", query)
+}
@@ -0,0 +1,71 @@
+package client
+
+import (
+	"io"
+	"mime/multipart"
+	"net/http"
+
+	"cloudware/cloudware/api"
+)
+
+// Backup writes a backup archive of the instance to w. The archive is encrypted when password is not empty.
+func (client *Client) Backup(password string, w io.Writer) error {
+	request, err := client.newRequest(http.MethodGet, "/backup", nil, nil, "")
+	if err != nil {
+		return err
+	}
+	if password != "" {
+		request.Header.Set("X-Backup-Password", password)
+	}
+
+	response, err := client.send(request)
+	if err != nil {
+		return err
+	}
+	response, err = checkResponse(response)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	_, err = io.Copy(w, response.Body)
+	return err
+}
+
+// BackupStatus returns the outcome of the scheduled backups.
+func (client *Client) BackupStatus() (*api.BackupStatus, error) {
+	var status api.BackupStatus
+	err := client.do(http.MethodGet, "/backup/status", nil, nil, &status)
+	if err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+// Restore replaces the data of the instance with a backup archive. The password is only required
+// for encrypted archives. As the archive is streamed, the request is not retried when the token has expired.
+func (client *Client) Restore(archive io.Reader, password string) error {
+	body, writer := io.Pipe()
+	form := multipart.NewWriter(writer)
+	go func() {
+		err := form.WriteField("Password", password)
+		if err == nil {
+			var part io.Writer
+			part, err = form.CreateFormFile("file", "backup.tar.gz")
+			if err == nil {
+				_, err = io.Copy(part, archive)
+			}
+		}
+		if err == nil {
+			err = form.Close()
+		}
+		writer.CloseWithError(err)
+	}()
+
+	response, err := client.request(http.MethodPost, "/restore", nil, body, form.FormDataContentType())
+	body.Close()
+	if err != nil {
+		return err
+	}
+	return response.Body.Close()
+}
@@ -0,0 +1,246 @@
+// Package client provides typed access to the REST API of a cloudware instance.
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// Client is a client for the REST API of a cloudware instance. It is safe for concurrent use once
+// Token is set or Authenticate has returned.
+type Client struct {
+	// URL is the address of the instance, the API is served under URL/api.
+	URL string
+	// Token is the JWT sent with each request, it is set by Authenticate.
+	Token      string
+	HTTPClient *http.Client
+
+	mu       sync.Mutex
+	username string
+	password string
+}
+
+// Error represents an error returned by the API. Code is the machine-readable code of
+// the error, Details holds the raw details of the error when the API returned some.
+type Error struct {
+	StatusCode int
+	Code       string
+	Message    string
+	Details    json.RawMessage
+}
+
+// errorResponse is the body of an error response.
+type errorResponse struct {
+	Err     string          `json:"err"`
+	Code    string          `json:"code"`
+	Message string          `json:"message"`
+	Details json.RawMessage `json:"details"`
+}
+
+// idResponse is the body of the response to a creation request.
+type idResponse struct {
+	ID int `json:"Id"`
+}
+
+func (err *Error) Error() string {
+	return fmt.Sprintf("%s (code=%d)", err.Message, err.StatusCode)
+}
+
+// NewClient returns a client for the instance at the specified address. The default HTTP client is used
+// when httpClient is nil.
+func NewClient(address string, httpClient *http.Client) *Client {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return &Client{
+		URL:        strings.TrimRight(address, "/"),
+		HTTPClient: httpClient,
+	}
+}
+
+// newRequest returns a request on the API. The body is sent as is when it is a reader, it is encoded
+// in JSON otherwise.
+func (client *Client) newRequest(method, path string, query url.Values, body interface{}, contentType string) (*http.Request, error) {
+	var reader io.Reader
+	if r, ok := body.(io.Reader); ok {
+		reader = r
+	} else if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(data)
+		contentType = "application/json"
+	}
+
+	address := client.URL + "/api" + path
+	if len(query) > 0 {
+		address += "?" + query.Encode()
+	}
+
+	request, err := http.NewRequest(method, address, reader)
+	if err != nil {
+		return nil, err
+	}
+	if contentType != "" {
+		request.Header.Set("Content-Type", contentType)
+	}
+	return request, nil
+}
+
+// send sends a request with the current token. When the token is rejected and the client was authenticated
+// with Authenticate, a new token is retrieved and the request is sent again, unless its body cannot be read twice.
+func (client *Client) send(request *http.Request) (*http.Response, error) {
+	client.mu.Lock()
+	token := client.Token
+	client.mu.Unlock()
+
+	response, err := client.sendWithToken(request, token)
+	if err != nil || response.StatusCode != http.StatusUnauthorized {
+		return response, err
+	}
+
+	client.mu.Lock()
+	canRefresh := client.username != "" && (request.Body == nil || request.GetBody != nil)
+	client.mu.Unlock()
+	if !canRefresh {
+		return response, nil
+	}
+	response.Body.Close()
+
+	token, err = client.refreshToken(token)
+	if err != nil {
+		return nil, err
+	}
+
+	if request.GetBody != nil {
+		request.Body, err = request.GetBody()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return client.sendWithToken(request, token)
+}
+
+func (client *Client) sendWithToken(request *http.Request, token string) (*http.Response, error) {
+	if token != "" {
+		request.Header.Set("Authorization", "Bearer "+token)
+	}
+	return client.HTTPClient.Do(request)
+}
+
+// refreshToken authenticates again with the stored credentials, unless another request already replaced
+// the rejected token.
+func (client *Client) refreshToken(rejected string) (string, error) {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+
+	if client.Token != rejected {
+		return client.Token, nil
+	}
+
+	token, err := client.authenticate(client.username, client.password)
+	if err != nil {
+		return "", err
+	}
+	client.Token = token
+	return token, nil
+}
+
+// request sends a request to the API and returns the response when its status is successful.
+func (client *Client) request(method, path string, query url.Values, body interface{}, contentType string) (*http.Response, error) {
+	request, err := client.newRequest(method, path, query, body, contentType)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := client.send(request)
+	if err != nil {
+		return nil, err
+	}
+	return checkResponse(response)
+}
+
+// checkResponse returns the response when its status is successful, the decoded error otherwise.
+func checkResponse(response *http.Response) (*http.Response, error) {
+	if response.StatusCode >= http.StatusBadRequest {
+		defer response.Body.Close()
+		return nil, decodeError(response)
+	}
+	return response, nil
+}
+
+// decodeError converts an error response, the status is used as message when the body is not an error response.
+func decodeError(response *http.Response) error {
+	data, _ := ioutil.ReadAll(response.Body)
+
+	var body errorResponse
+	if json.Unmarshal(data, &body) != nil || body.Err == "" {
+		body.Err = http.StatusText(response.StatusCode)
+	}
+	return &Error{StatusCode: response.StatusCode, Code: body.Code, Message: body.Err, Details: body.Details}
+}
+
+// do sends a request and decodes the JSON response in v, unless v is nil.
+func (client *Client) do(method, path string, query url.Values, body, v interface{}) error {
+	response, err := client.request(method, path, query, body, "")
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if v == nil {
+		return nil
+	}
+	return json.NewDecoder(response.Body).Decode(v)
+}
+
+// Authenticate retrieves a token for the specified credentials and uses it for the next requests.
+// The credentials are kept to retrieve a new token when the current one expires.
+func (client *Client) Authenticate(username, password string) (string, error) {
+	token, err := client.authenticate(username, password)
+	if err != nil {
+		return "", err
+	}
+
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	client.Token = token
+	client.username = username
+	client.password = password
+	return token, nil
+}
+
+func (client *Client) authenticate(username, password string) (string, error) {
+	var response struct {
+		JWT string `json:"jwt"`
+	}
+
+	request, err := client.newRequest(http.MethodPost, "/auth", nil, struct {
+		Username string
+		Password string
+	}{username, password}, "")
+	if err != nil {
+		return "", err
+	}
+
+	httpResponse, err := client.sendWithToken(request, "")
+	if err != nil {
+		return "", err
+	}
+	httpResponse, err = checkResponse(httpResponse)
+	if err != nil {
+		return "", err
+	}
+	defer httpResponse.Body.Close()
+
+	err = json.NewDecoder(httpResponse.Body).Decode(&response)
+	return response.JWT, err
+}
@@ -0,0 +1,1219 @@
+package client
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"cloudware/cloudware/api"
+	"cloudware/cloudware/api/apply"
+	"cloudware/cloudware/api/cron"
+	"cloudware/cloudware/api/crypto"
+	"cloudware/cloudware/api/distribution/distributiontest"
+	"cloudware/cloudware/api/exec"
+	"cloudware/cloudware/api/file"
+	"cloudware/cloudware/api/http/server"
+	"cloudware/cloudware/api/http/server/jwt"
+	"cloudware/cloudware/api/pki"
+	"cloudware/cloudware/bolt"
+)
+
+const (
+	adminUsername = "admin"
+	adminPassword = "password"
+)
+
+type testServer struct {
+	*httptest.Server
+	store       *bolt.Store
+	fileService *file.Service
+	dataPath    string
+}
+
+// newTestServer starts an in-process instance with an administrator account and no endpoint.
+func newTestServer(t *testing.T) *testServer {
+	dataPath, err := ioutil.TempDir("", "client-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fileService, err := file.NewService(dataPath, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store, err := bolt.NewStore(dataPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = store.Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = store.MigrateData()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = store.SettingsService.StoreSettings(&api.Settings{
+		TemplatesURL:         api.DefaultTemplatesURL,
+		AuthenticationMethod: api.AuthenticationInternal,
+		BlackListedLabels:    []api.Pair{},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = store.DockerHubService.StoreDockerHub(&api.DockerHub{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cryptoService := &crypto.Service{}
+	hash, err := cryptoService.Hash(adminPassword)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = store.UserService.CreateUser(&api.User{Username: adminUsername, Password: hash, Role: api.AdministratorRole})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	jwtService, err := jwt.NewService()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	instance := &server.Server{
+		Status:                 &api.Status{Authentication: true, EndpointManagement: true, Version: api.APIVersion},
+		EndpointManagement:     true,
+		UserService:            store.UserService,
+		TeamService:            store.TeamService,
+		TeamMembershipService:  store.TeamMembershipService,
+		EndpointService:        store.EndpointService,
+		ResourceControlService: store.ResourceControlService,
+		SettingsService:        store.SettingsService,
+		RegistryService:        store.RegistryService,
+		DockerHubService:       store.DockerHubService,
+		StackService:           store.StackService,
+		RecordingService:       store.RecordingService,
+		TemplateService:        store.TemplateService,
+		BackupStatusService:    store.BackupStatusService,
+		CryptoService:          cryptoService,
+		PKIService:             pki.NewService(fileService, true),
+		Watcher:                cron.NewWatcher(store.EndpointService, store.UserService, store.TeamService, "60s"),
+		JWTService:             jwtService,
+		FileService:            fileService,
+		// There is no docker binary in the data directory, the stack deployments fail.
+		StackManager: exec.NewStackManager(dataPath),
+	}
+	err = instance.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &testServer{
+		Server:      httptest.NewServer(instance.Handler),
+		store:       store,
+		fileService: fileService,
+		dataPath:    dataPath,
+	}
+}
+
+func (server *testServer) Close() {
+	server.Server.Close()
+	server.store.Close()
+	os.RemoveAll(server.dataPath)
+}
+
+func stringPointer(value string) *string {
+	return &value
+}
+
+// newAdminClient returns a client authenticated as the administrator.
+func newAdminClient(t *testing.T, server *testServer) *Client {
+	client := NewClient(server.URL, nil)
+	_, err := client.Authenticate(adminUsername, adminPassword)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+func TestAuthenticate(t *testing.T) {
+	server := newTestServer(t)
+	defer server.Close()
+
+	client := NewClient(server.URL, nil)
+	_, err := client.Users()
+	if apiErr, ok := err.(*Error); !ok || apiErr.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected an unauthorized error without token, got %v", err)
+	}
+
+	_, err = client.Authenticate(adminUsername, "wrong")
+	if apiErr, ok := err.(*Error); !ok || apiErr.StatusCode != http.StatusUnprocessableEntity || apiErr.Message == "" {
+		t.Fatalf("expected the error of the API for invalid credentials, got %v", err)
+	}
+
+	token, err := client.Authenticate(adminUsername, adminPassword)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token == "" || client.Token != token {
+		t.Fatalf("expected the token to be used by the client, got %q", client.Token)
+	}
+}
+
+func TestTokenRefresh(t *testing.T) {
+	server := newTestServer(t)
+	defer server.Close()
+
+	client := newAdminClient(t, server)
+	client.Token = "expired"
+
+	ID, err := client.CreateTeam("refreshed")
+	if err != nil {
+		t.Fatalf("expected the request to be sent again with a new token, got %v", err)
+	}
+	if client.Token == "expired" {
+		t.Fatal("expected the token to be replaced")
+	}
+
+	team, err := client.Team(ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if team.Name != "refreshed" {
+		t.Fatalf("expected the team to be created once, got %+v", team)
+	}
+
+	// Without credentials, the token is not refreshed.
+	anonymous := NewClient(server.URL, nil)
+	anonymous.Token = "expired"
+	_, err = anonymous.Teams()
+	if apiErr, ok := err.(*Error); !ok || apiErr.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected an unauthorized error, got %v", err)
+	}
+}
+
+func TestMetricsAccess(t *testing.T) {
+	server := newTestServer(t)
+	defer server.Close()
+
+	admin := newAdminClient(t, server)
+	_, err := admin.CreateUser(&UserCreateRequest{Username: "alice", Password: "secret", Role: api.StandardUserRole})
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := NewClient(server.URL, nil)
+	_, err = user.Authenticate("alice", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		name   string
+		token  string
+		status int
+	}{
+		{"anonymous", "", http.StatusUnauthorized},
+		{"user", user.Token, http.StatusForbidden},
+		{"administrator", admin.Token, http.StatusOK},
+	} {
+		request, _ := http.NewRequest(http.MethodGet, server.URL+"/metrics", nil)
+		if test.token != "" {
+			request.Header.Set("Authorization", "Bearer "+test.token)
+		}
+		response, err := http.DefaultClient.Do(request)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := ioutil.ReadAll(response.Body)
+		response.Body.Close()
+
+		if response.StatusCode != test.status {
+			t.Errorf("%s: expected status %d, got %d", test.name, test.status, response.StatusCode)
+		}
+		if test.status == http.StatusOK && !strings.Contains(string(body), "cloudware_http_requests_total") {
+			t.Errorf("%s: expected the metrics to be served, got %s", test.name, body)
+		}
+	}
+}
+
+func TestErrorDecoding(t *testing.T) {
+	server := newTestServer(t)
+	defer server.Close()
+
+	client := newAdminClient(t, server)
+
+	_, err := client.User(42)
+	apiErr, ok := err.(*Error)
+	if !ok {
+		t.Fatalf("expected an API error, got %v", err)
+	}
+	if apiErr.StatusCode != http.StatusNotFound || apiErr.Code != "user_not_found" || apiErr.Message != api.ErrUserNotFound.Error() {
+		t.Fatalf("unexpected error %+v", apiErr)
+	}
+
+	_, err = client.CreateUser(&UserCreateRequest{Username: adminUsername, Password: "password", Role: api.StandardUserRole})
+	if apiErr, ok := err.(*Error); !ok || apiErr.StatusCode != http.StatusConflict || apiErr.Code != "user_already_exists" || apiErr.Message != api.ErrUserAlreadyExists.Error() {
+		t.Fatalf("expected a conflict, got %v", err)
+	}
+}
+
+func TestUsersAndTeams(t *testing.T) {
+	server := newTestServer(t)
+	defer server.Close()
+
+	client := newAdminClient(t, server)
+
+	exists, err := client.AdministratorExists()
+	if err != nil || !exists {
+		t.Fatalf("expected the administrator to exist, got %v, %v", exists, err)
+	}
+
+	userID, err := client.CreateUser(&UserCreateRequest{Username: "alice", Password: "secret", Role: api.StandardUserRole})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = client.UpdateUser(userID, &UserUpdateRequest{Role: api.AdministratorRole})
+	if err != nil {
+		t.Fatal(err)
+	}
+	user, err := client.User(userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Username != "alice" || user.Role != api.AdministratorRole {
+		t.Fatalf("unexpected user %+v", user)
+	}
+
+	valid, err := client.CheckPassword(userID, "secret")
+	if err != nil || !valid {
+		t.Fatalf("expected the password to be valid, got %v, %v", valid, err)
+	}
+
+	teamID, err := client.CreateTeam("developers")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = client.UpdateTeam(teamID, "operators")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	membershipID, err := client.CreateTeamMembership(&TeamMembershipRequest{UserID: userID, TeamID: teamID, Role: api.TeamMember})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = client.UpdateTeamMembership(membershipID, &TeamMembershipRequest{UserID: userID, TeamID: teamID, Role: api.TeamLeader})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	memberships, err := client.TeamMemberships(teamID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(memberships) != 1 || memberships[0].UserID != userID || memberships[0].Role != api.TeamLeader {
+		t.Fatalf("unexpected memberships %+v", memberships)
+	}
+	memberships, err = client.UserMemberships(userID)
+	if err != nil || len(memberships) != 1 {
+		t.Fatalf("expected the membership of the user, got %+v, %v", memberships, err)
+	}
+	memberships, err = client.Memberships()
+	if err != nil || len(memberships) != 1 {
+		t.Fatalf("expected a single membership, got %+v, %v", memberships, err)
+	}
+
+	err = client.DeleteTeamMembership(membershipID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = client.DeleteTeam(teamID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = client.DeleteUser(userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	users, err := client.Users()
+	if err != nil {
+		t.Fatal(err)
+	}
+	teams, err := client.Teams()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(users) != 1 || len(teams) != 0 {
+		t.Fatalf("expected only the administrator to remain, got %+v and %+v", users, teams)
+	}
+}
+
+func TestEndpointsAndRegistries(t *testing.T) {
+	server := newTestServer(t)
+	defer server.Close()
+
+	client := newAdminClient(t, server)
+
+	teamID, err := client.CreateTeam("developers")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	endpointID, err := client.CreateEndpoint(&EndpointCreateRequest{Name: "local", URL: "tcp://127.0.0.1:2375"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	recordSessions := true
+	err = client.UpdateEndpoint(endpointID, &EndpointUpdateRequest{PublicURL: "10.0.0.1", RecordSessions: &recordSessions})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = client.UpdateEndpointAccess(endpointID, &AccessRequest{AuthorizedUsers: []api.UserID{}, AuthorizedTeams: []api.TeamID{teamID}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	endpoint, err := client.Endpoint(endpointID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if endpoint.Name != "local" || endpoint.PublicURL != "10.0.0.1" || !endpoint.RecordSessions ||
+		len(endpoint.AuthorizedTeams) != 1 || endpoint.AuthorizedTeams[0] != teamID {
+		t.Fatalf("unexpected endpoint %+v", endpoint)
+	}
+
+	registry := distributiontest.NewServer()
+	defer registry.Close()
+
+	registryID, err := client.CreateRegistry(&RegistryRequest{Name: "private", URL: registry.URL, Authentication: true, Username: "user", Password: stringPointer("secret")}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = client.UpdateRegistry(registryID, &RegistryRequest{Name: "renamed", URL: registry.URL}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = client.UpdateRegistryAccess(registryID, &AccessRequest{AuthorizedUsers: []api.UserID{}, AuthorizedTeams: []api.TeamID{teamID}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	registries, err := client.Registries()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(registries) != 1 || registries[0].Name != "renamed" || registries[0].Authentication {
+		t.Fatalf("unexpected registries %+v", registries)
+	}
+
+	err = client.DeleteRegistry(registryID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = client.DeleteEndpoint(endpointID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = client.Endpoint(endpointID)
+	if apiErr, ok := err.(*Error); !ok || apiErr.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected the endpoint to be deleted, got %v", err)
+	}
+}
+
+func TestStacksAndResourceControls(t *testing.T) {
+	server := newTestServer(t)
+	defer server.Close()
+
+	client := newAdminClient(t, server)
+
+	endpointID, err := client.CreateEndpoint(&EndpointCreateRequest{Name: "local", URL: "tcp://127.0.0.1:2375"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Deploying a stack requires a Docker daemon, the stack is stored directly.
+	stackID := api.StackID("web_swarm")
+	projectPath, err := server.fileService.StoreStackFileFromString(string(stackID), "version: \"3\"\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = server.store.StackService.CreateStack(&api.Stack{ID: stackID, Name: "web", SwarmID: "swarm", EntryPoint: file.ComposeFileDefaultName, ProjectPath: projectPath})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stacks, err := client.Stacks(endpointID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stacks) != 1 || stacks[0].ID != stackID {
+		t.Fatalf("unexpected stacks %+v", stacks)
+	}
+	content, err := client.StackFile(endpointID, stackID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(content, "version") {
+		t.Fatalf("unexpected stack file %q", content)
+	}
+
+	err = client.CreateResourceControl(&ResourceControlCreateRequest{ResourceID: "web", Type: "stack", AdministratorsOnly: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stack, err := client.Stack(endpointID, stackID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stack.Name != "web" || stack.ResourceControl.ID == 0 || !stack.ResourceControl.AdministratorsOnly {
+		t.Fatalf("expected the stack along with its resource control, got %+v", stack)
+	}
+
+	err = client.UpdateResourceControl(stack.ResourceControl.ID, &ResourceControlUpdateRequest{Users: []api.UserID{1}, Teams: []api.TeamID{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = client.DeleteResourceControl(stack.ResourceControl.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = client.DeleteResourceControl(stack.ResourceControl.ID)
+	if apiErr, ok := err.(*Error); !ok || apiErr.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected the resource control to be deleted, got %v", err)
+	}
+}
+
+func TestSettingsAndStatus(t *testing.T) {
+	server := newTestServer(t)
+	defer server.Close()
+
+	status, err := NewClient(server.URL, nil).Status()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Version != api.APIVersion || !status.Authentication {
+		t.Fatalf("unexpected status %+v", status)
+	}
+
+	client := newAdminClient(t, server)
+
+	settings, err := client.Settings()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = client.UpdateSettings(&SettingsUpdateRequest{
+		TemplatesURL:         settings.TemplatesURL,
+		LogoURL:              "https://example.com/logo.png",
+		BlackListedLabels:    []api.Pair{{Name: "internal", Value: "true"}},
+		AuthenticationMethod: settings.AuthenticationMethod,
+		LDAPSettings:         LDAPSettingsRequest{LDAPSettings: settings.LDAPSettings},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	public, err := NewClient(server.URL, nil).PublicSettings()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if public.LogoURL != "https://example.com/logo.png" {
+		t.Fatalf("unexpected public settings %+v", public)
+	}
+
+	settings, err = client.Settings()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(settings.BlackListedLabels) != 1 || settings.BlackListedLabels[0].Name != "internal" {
+		t.Fatalf("unexpected settings %+v", settings)
+	}
+
+	err = client.UpdateDockerHub(&DockerHubRequest{Authentication: true, Username: "user", Password: stringPointer("secret")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dockerhub, err := client.DockerHub()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !dockerhub.Authentication || dockerhub.Username != "user" {
+		t.Fatalf("unexpected Docker Hub settings %+v", dockerhub)
+	}
+}
+
+func TestSecretsUpdate(t *testing.T) {
+	server := newTestServer(t)
+	defer server.Close()
+
+	client := newAdminClient(t, server)
+
+	dockerHubPassword := func() string {
+		dockerhub, err := server.store.DockerHubService.DockerHub()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return dockerhub.Password
+	}
+	ldapPassword := func() string {
+		settings, err := server.store.SettingsService.Settings()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return settings.LDAPSettings.Password
+	}
+	updateSettings := func(password *string) {
+		err := client.UpdateSettings(&SettingsUpdateRequest{
+			TemplatesURL:         api.DefaultTemplatesURL,
+			AuthenticationMethod: api.AuthenticationInternal,
+			LDAPSettings:         LDAPSettingsRequest{LDAPSettings: api.LDAPSettings{ReaderDN: "cn=reader"}, Password: password},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, test := range []struct {
+		password *string
+		expected string
+	}{
+		{stringPointer("secret"), "secret"},
+		{nil, "secret"},
+		{stringPointer(""), ""},
+	} {
+		err := client.UpdateDockerHub(&DockerHubRequest{Authentication: true, Username: "user", Password: test.password})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if password := dockerHubPassword(); password != test.expected {
+			t.Errorf("expected the Docker Hub password %q, got %q", test.expected, password)
+		}
+
+		updateSettings(test.password)
+		if password := ldapPassword(); password != test.expected {
+			t.Errorf("expected the LDAP password %q, got %q", test.expected, password)
+		}
+	}
+}
+
+func TestExportAndApply(t *testing.T) {
+	server := newTestServer(t)
+	defer server.Close()
+
+	client := newAdminClient(t, server)
+
+	_, err := client.CreateTeam("developers")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	document, err := client.Export(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(document.Users) != 1 || len(document.Teams) != 1 || document.Users[0].Password != "" {
+		t.Fatalf("unexpected export document %+v", document)
+	}
+
+	report, err := client.Import(document, false, true)
+	if err != api.ErrImportConflicts {
+		t.Fatalf("expected the conflicts to be reported, got %v", err)
+	}
+	if report == nil || len(report.Conflicts) != 2 {
+		t.Fatalf("expected a conflict for the user and the team, got %+v", report)
+	}
+
+	configuration := &apply.Document{Teams: []apply.Team{{Name: "developers"}, {Name: "operators"}}}
+	plan, err := client.Apply(configuration, true, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.Changes) != 1 || plan.Changes[0].Action != apply.ActionCreate || plan.Changes[0].Name != "operators" {
+		t.Fatalf("unexpected plan %+v", plan)
+	}
+
+	_, err = client.Apply(configuration, false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	teams, err := client.Teams()
+	if err != nil || len(teams) != 2 {
+		t.Fatalf("expected the configuration to be applied, got %+v, %v", teams, err)
+	}
+}
+
+func TestTemplates(t *testing.T) {
+	server := newTestServer(t)
+	defer server.Close()
+
+	client := newAdminClient(t, server)
+
+	teamID, err := client.CreateTeam("developers")
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherTeamID, err := client.CreateTeam("operators")
+	if err != nil {
+		t.Fatal(err)
+	}
+	userID, err := client.CreateUser(&UserCreateRequest{Username: "alice", Password: "secret", Role: api.StandardUserRole})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = client.CreateTeamMembership(&TeamMembershipRequest{UserID: userID, TeamID: teamID, Role: api.TeamMember})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	registry := distributiontest.NewServer()
+	defer registry.Close()
+
+	webID, err := client.CreateTemplate(&TemplateRequest{
+		Type:       api.ContainerTemplate,
+		Title:      "Web",
+		Categories: []string{"web"},
+		Image:      registry.Host() + "/web:{{VERSION}}",
+		Ports:      []string{"8080:80"},
+		Parameters: []api.TemplateParameter{{Name: "VERSION", Options: []string{"1.0", "2.0"}, Default: "1.0"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = client.CreateTemplate(&TemplateRequest{
+		Type:            api.ContainerTemplate,
+		Title:           "Database",
+		Categories:      []string{"database"},
+		Image:           "postgres",
+		AuthorizedTeams: []api.TeamID{otherTeamID},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = client.CreateTemplate(&TemplateRequest{Type: api.ContainerTemplate, Title: "Invalid"})
+	if apiErr, ok := err.(*Error); !ok || apiErr.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected a template without image to be rejected, got %v", err)
+	}
+
+	templates, err := client.ListTemplates("web")
+	if err != nil || len(templates) != 1 || templates[0].ID != webID {
+		t.Fatalf("expected the web template, got %+v, %v", templates, err)
+	}
+
+	userClient := NewClient(server.URL, nil)
+	_, err = userClient.Authenticate("alice", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	templates, err = userClient.ListTemplates("")
+	if err != nil || len(templates) != 1 || templates[0].Title != "Web" {
+		t.Fatalf("expected the template restricted to another team to be hidden, got %+v, %v", templates, err)
+	}
+
+	// The pulls, creations and starts are answered by a fake Docker daemon.
+	var created struct {
+		Image      string
+		Env        []string
+		HostConfig struct {
+			PortBindings map[string][]struct{ HostPort string }
+		}
+	}
+	var registryAuth string
+	docker := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/images/create":
+			registryAuth = r.Header.Get("X-Registry-Auth")
+			w.Write([]byte(`{"status":"Pulling"}`))
+		case "/containers/create":
+			json.NewDecoder(r.Body).Decode(&created)
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"Id":"container"}`))
+		case "/containers/container/start":
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer docker.Close()
+
+	endpointID, err := client.CreateEndpoint(&EndpointCreateRequest{Name: "local", URL: "tcp://" + docker.Listener.Addr().String()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = client.UpdateEndpointAccess(endpointID, &AccessRequest{AuthorizedUsers: []api.UserID{}, AuthorizedTeams: []api.TeamID{teamID}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	registryID, err := client.CreateRegistry(&RegistryRequest{Name: "private", URL: registry.URL, Authentication: true, Username: "user", Password: stringPointer("secret")}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = client.UpdateRegistryAccess(registryID, &AccessRequest{AuthorizedUsers: []api.UserID{}, AuthorizedTeams: []api.TeamID{teamID}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = userClient.DeployTemplate(endpointID, webID, &TemplateDeployRequest{Parameters: []api.Pair{{Name: "VERSION", Value: "3.0"}}})
+	if apiErr, ok := err.(*Error); !ok || apiErr.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected a value outside of the options to be rejected, got %v", err)
+	}
+
+	deployed, err := userClient.DeployTemplate(endpointID, webID, &TemplateDeployRequest{Parameters: []api.Pair{{Name: "VERSION", Value: "2.0"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deployed.ID != "container" || created.Image != registry.Host()+"/web:2.0" ||
+		len(created.Env) != 1 || created.Env[0] != "VERSION=2.0" ||
+		len(created.HostConfig.PortBindings["80/tcp"]) != 1 || created.HostConfig.PortBindings["80/tcp"][0].HostPort != "8080" {
+		t.Fatalf("unexpected deployment %+v of %+v", deployed, created)
+	}
+
+	data, err := base64.URLEncoding.DecodeString(registryAuth)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var auth struct {
+		Username      string `json:"username"`
+		ServerAddress string `json:"serveraddress"`
+	}
+	if json.Unmarshal(data, &auth) != nil || auth.Username != "user" || auth.ServerAddress != registry.Host() {
+		t.Fatalf("expected the credentials of the registry, got %q", data)
+	}
+
+	resourceControl, err := server.store.ResourceControlService.ResourceControl(deployed.ResourceControlID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resourceControl.ResourceID != "container" || len(resourceControl.UserAccesses) != 1 || resourceControl.UserAccesses[0].UserID != userID {
+		t.Fatalf("expected the container to be owned by the user, got %+v", resourceControl)
+	}
+}
+
+func TestTemplateDeploymentFailures(t *testing.T) {
+	server := newTestServer(t)
+	defer server.Close()
+
+	client := newAdminClient(t, server)
+
+	userID, err := client.CreateUser(&UserCreateRequest{Username: "bob", Password: "secret", Role: api.StandardUserRole})
+	if err != nil {
+		t.Fatal(err)
+	}
+	userClient := NewClient(server.URL, nil)
+	_, err = userClient.Authenticate("bob", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The fake Docker daemon belongs to a swarm and fails to start the containers.
+	removed := false
+	docker := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method + " " + r.URL.Path {
+		case "GET /info":
+			w.Write([]byte(`{"Swarm":{"Cluster":{"ID":"swarm"}}}`))
+		case "POST /images/create":
+			w.Write([]byte(`{"status":"Pulling"}`))
+		case "POST /containers/create":
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"Id":"container"}`))
+		case "POST /containers/container/start":
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(`{"message":"port is already allocated"}`))
+		case "DELETE /containers/container":
+			removed = r.URL.Query().Get("force") == "1"
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer docker.Close()
+
+	endpointID, err := client.CreateEndpoint(&EndpointCreateRequest{Name: "local", URL: "tcp://" + docker.Listener.Addr().String()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = client.UpdateEndpointAccess(endpointID, &AccessRequest{AuthorizedUsers: []api.UserID{userID}, AuthorizedTeams: []api.TeamID{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	socketID, err := client.CreateTemplate(&TemplateRequest{
+		Type:    api.ContainerTemplate,
+		Title:   "Socket",
+		Image:   "agent",
+		Volumes: []string{"/var/run/docker.sock:/var/run/docker.sock"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = userClient.DeployTemplate(endpointID, socketID, &TemplateDeployRequest{})
+	if apiErr, ok := err.(*Error); !ok || apiErr.StatusCode != http.StatusForbidden {
+		t.Fatalf("expected the bind mounts to be denied to the users, got %v", err)
+	}
+
+	_, err = client.DeployTemplate(endpointID, socketID, &TemplateDeployRequest{})
+	if apiErr, ok := err.(*Error); !ok || apiErr.StatusCode != http.StatusInternalServerError {
+		t.Fatalf("expected the start failure to be reported, got %v", err)
+	}
+	if !removed {
+		t.Fatal("expected the container that failed to start to be removed")
+	}
+	resourceControls, err := server.store.ResourceControlService.ResourceControls()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resourceControls) != 0 {
+		t.Fatalf("expected no resource control for the failed deployment, got %+v", resourceControls)
+	}
+
+	stackTemplateID, err := client.CreateTemplate(&TemplateRequest{
+		Type:             api.StackTemplate,
+		Title:            "Stack",
+		StackFileContent: "version: \"3\"\n",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = userClient.DeployTemplate(endpointID, stackTemplateID, &TemplateDeployRequest{Name: "web", SwarmID: "other"})
+	if apiErr, ok := err.(*Error); !ok || apiErr.StatusCode != http.StatusBadRequest || apiErr.Code != "stack_swarm_mismatch" {
+		t.Fatalf("expected a swarm of another endpoint to be rejected, got %v", err)
+	}
+
+	_, err = userClient.DeployTemplate(endpointID, stackTemplateID, &TemplateDeployRequest{Name: "web", SwarmID: "swarm"})
+	if apiErr, ok := err.(*Error); !ok || apiErr.StatusCode != http.StatusInternalServerError {
+		t.Fatalf("expected the deployment failure to be reported, got %v", err)
+	}
+	stacks, err := server.store.StackService.Stacks()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stacks) != 0 {
+		t.Fatalf("expected the stack of the failed deployment to be deleted, got %+v", stacks)
+	}
+	_, err = os.Stat(server.fileService.GetStackProjectPath("web_swarm"))
+	if !os.IsNotExist(err) {
+		t.Fatalf("expected the files of the failed deployment to be removed, got %v", err)
+	}
+}
+
+func TestTemplatesImport(t *testing.T) {
+	server := newTestServer(t)
+	defer server.Close()
+
+	client := newAdminClient(t, server)
+
+	document := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"version": "2", "templates": [
+			{"type": 1, "title": "Nginx", "image": "nginx:latest", "categories": ["web"]},
+			{"type": 3, "title": "Compose"}
+		]}`))
+	}))
+
+	imported, err := client.ImportTemplates(&TemplatesImportRequest{URL: document.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if imported.Count != 1 || imported.Cached {
+		t.Fatalf("expected the swarm and container templates to be imported, got %+v", imported)
+	}
+	templates, err := client.ListTemplates("")
+	if err != nil || len(templates) != 1 || templates[0].Source != document.URL {
+		t.Fatalf("expected the imported template, got %+v, %v", templates, err)
+	}
+
+	// The templates are imported from the cached copy when the source is unreachable.
+	document.Close()
+	imported, err = client.ImportTemplates(&TemplatesImportRequest{URL: document.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	reimported, err := client.ListTemplates("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !imported.Cached || len(reimported) != 1 || reimported[0].ID != templates[0].ID {
+		t.Fatalf("expected the templates to be imported again from the cache, got %+v and %+v", imported, reimported)
+	}
+
+	_, err = client.ImportTemplates(&TemplatesImportRequest{URL: "http://127.0.0.1:1/templates.json"})
+	if apiErr, ok := err.(*Error); !ok || apiErr.StatusCode != http.StatusBadGateway {
+		t.Fatalf("expected an unreachable source without cache to fail, got %v", err)
+	}
+}
+
+func TestRegistryBrowser(t *testing.T) {
+	server := newTestServer(t)
+	defer server.Close()
+
+	client := newAdminClient(t, server)
+
+	registry := distributiontest.NewServer()
+	defer registry.Close()
+	registry.Username, registry.Password = "user", "secret"
+	registry.TokenAuthentication = true
+	digest := registry.PushImage("team/web", "1.0", time.Now(), 10, 20)
+	registry.PushImage("team/web", "latest", time.Now(), 30)
+	registry.PushImage("db", "latest", time.Now(), 40)
+
+	_, err := client.CreateRegistry(&RegistryRequest{Name: "private", URL: registry.URL, Authentication: true, Username: "user", Password: stringPointer("wrong")}, true)
+	if apiErr, ok := err.(*Error); !ok || apiErr.StatusCode != http.StatusBadRequest || apiErr.Code != "registry_authentication_failed" {
+		t.Fatalf("expected the credentials to be rejected, got %v", err)
+	}
+	registryID, err := client.CreateRegistry(&RegistryRequest{Name: "private", URL: registry.URL, Authentication: true, Username: "user", Password: stringPointer("secret")}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	repositories, err := client.RegistryRepositories(registryID)
+	if err != nil || len(repositories) != 2 || repositories[0] != "db" || repositories[1] != "team/web" {
+		t.Fatalf("unexpected repositories %v, %v", repositories, err)
+	}
+	tags, err := client.RegistryTags(registryID, "team/web")
+	if err != nil || len(tags) != 2 || tags[0] != "1.0" {
+		t.Fatalf("unexpected tags %v, %v", tags, err)
+	}
+	manifest, err := client.RegistryManifest(registryID, "team/web", "1.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if manifest.Digest != digest || len(manifest.Layers) != 2 || manifest.Created.IsZero() {
+		t.Fatalf("unexpected manifest %+v", manifest)
+	}
+	_, err = client.RegistryManifest(registryID, "team/web", "missing")
+	if apiErr, ok := err.(*Error); !ok || apiErr.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected a missing tag to be reported, got %v", err)
+	}
+
+	userID, err := client.CreateUser(&UserCreateRequest{Username: "alice", Password: "secret", Role: api.StandardUserRole})
+	if err != nil {
+		t.Fatal(err)
+	}
+	userClient := NewClient(server.URL, nil)
+	_, err = userClient.Authenticate("alice", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = userClient.RegistryRepositories(registryID)
+	if apiErr, ok := err.(*Error); !ok || apiErr.StatusCode != http.StatusForbidden {
+		t.Fatalf("expected an unauthorized user to be denied, got %v", err)
+	}
+
+	err = client.UpdateRegistryAccess(registryID, &AccessRequest{AuthorizedUsers: []api.UserID{userID}, AuthorizedTeams: []api.TeamID{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = userClient.DeleteRegistryTag(registryID, "team/web", "1.0")
+	if apiErr, ok := err.(*Error); !ok || apiErr.StatusCode != http.StatusForbidden {
+		t.Fatalf("expected the deletion to be restricted to the administrators, got %v", err)
+	}
+	err = client.DeleteRegistryTag(registryID, "team/web", "1.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tags, err = userClient.RegistryTags(registryID, "team/web")
+	if err != nil || len(tags) != 1 || tags[0] != "latest" {
+		t.Fatalf("expected the tag to be deleted, got %v, %v", tags, err)
+	}
+
+	registry.DeletionDisabled = true
+	err = client.DeleteRegistryTag(registryID, "db", "latest")
+	if apiErr, ok := err.(*Error); !ok || apiErr.Code != "registry_deletion_disabled" {
+		t.Fatalf("expected the deletion to be refused by the registry, got %v", err)
+	}
+
+	// The registry is only checked when requested, an offline registry can still be renamed
+	registry.Close()
+	err = client.UpdateRegistry(registryID, &RegistryRequest{Name: "offline", URL: registry.URL}, true)
+	if apiErr, ok := err.(*Error); !ok || apiErr.StatusCode != http.StatusBadGateway {
+		t.Fatalf("expected the offline registry to be reported, got %v", err)
+	}
+	err = client.UpdateRegistry(registryID, &RegistryRequest{Name: "offline", URL: registry.URL}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestConsoleSessionAccess(t *testing.T) {
+	server := newTestServer(t)
+	defer server.Close()
+
+	client := newAdminClient(t, server)
+	userID, err := client.CreateUser(&UserCreateRequest{Username: "bob", Password: "secret", Role: api.StandardUserRole})
+	if err != nil {
+		t.Fatal(err)
+	}
+	userClient := NewClient(server.URL, nil)
+	_, err = userClient.Authenticate("bob", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	docker := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/containers/private/json":
+			w.Write([]byte(`{"Id":"private","Config":{"Labels":{}}}`))
+		case "/containers/public/json":
+			w.Write([]byte(`{"Id":"public","Config":{"Labels":{}}}`))
+		case "/exec/exec/json":
+			w.Write([]byte(`{"ContainerID":"private"}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer docker.Close()
+
+	endpointID, err := client.CreateEndpoint(&EndpointCreateRequest{Name: "local", URL: "tcp://" + docker.Listener.Addr().String()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = client.UpdateEndpointAccess(endpointID, &AccessRequest{AuthorizedUsers: []api.UserID{userID}, AuthorizedTeams: []api.TeamID{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = client.CreateResourceControl(&ResourceControlCreateRequest{ResourceID: "private", Type: "container", AdministratorsOnly: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The access is checked before the upgrade, a plain request is answered with the status of the check.
+	session := func(path, id, token string) int {
+		response, err := http.Get(server.URL + "/api/websocket/" + path + "?endpointId=" + strconv.Itoa(int(endpointID)) + "&id=" + id + "&token=" + token)
+		if err != nil {
+			t.Fatal(err)
+		}
+		response.Body.Close()
+		return response.StatusCode
+	}
+
+	if code := session("attach", "private", userClient.Token); code != http.StatusForbidden {
+		t.Fatalf("expected the attach to a container of the administrators to be denied, got %d", code)
+	}
+	if code := session("exec", "exec", userClient.Token); code != http.StatusForbidden {
+		t.Fatalf("expected an exec in a container of the administrators to be denied, got %d", code)
+	}
+	if code := session("attach", "public", userClient.Token); code == http.StatusForbidden || code == http.StatusUnauthorized {
+		t.Fatalf("expected the attach to a container without resource control to be authorized, got %d", code)
+	}
+	if code := session("attach", "private", client.Token); code == http.StatusForbidden || code == http.StatusUnauthorized {
+		t.Fatalf("expected the administrator to attach to any container, got %d", code)
+	}
+
+	// The token query parameter is only accepted on the websocket routes.
+	response, err := http.Get(server.URL + "/api/users?token=" + client.Token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	response.Body.Close()
+	if response.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected the token query parameter to be ignored, got %d", response.StatusCode)
+	}
+}
+
+func TestAggregatedViews(t *testing.T) {
+	server := newTestServer(t)
+	defer server.Close()
+
+	client := newAdminClient(t, server)
+	userID, err := client.CreateUser(&UserCreateRequest{Username: "bob", Password: "secret", Role: api.StandardUserRole})
+	if err != nil {
+		t.Fatal(err)
+	}
+	userClient := NewClient(server.URL, nil)
+	_, err = userClient.Authenticate("bob", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var containersQuery string
+	docker := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/containers/json":
+			containersQuery = r.URL.RawQuery
+			w.Write([]byte(`[{"Id":"web","Labels":{}},{"Id":"private","Labels":{}}]`))
+		case "/services":
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte(`{"message":"This node is not a swarm manager."}`))
+		case "/volumes":
+			w.Write([]byte(`{"Volumes":[{"Name":"data"}],"Warnings":null}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer docker.Close()
+
+	localID, err := client.CreateEndpoint(&EndpointCreateRequest{Name: "local", URL: "tcp://" + docker.Listener.Addr().String()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	unreachableID, err := client.CreateEndpoint(&EndpointCreateRequest{Name: "unreachable", URL: "tcp://127.0.0.1:1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = client.CreateEndpoint(&EndpointCreateRequest{Name: "restricted", URL: "tcp://" + docker.Listener.Addr().String()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, endpointID := range []api.EndpointID{localID, unreachableID} {
+		err = client.UpdateEndpointAccess(endpointID, &AccessRequest{AuthorizedUsers: []api.UserID{userID}, AuthorizedTeams: []api.TeamID{}})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = client.CreateResourceControl(&ResourceControlCreateRequest{ResourceID: "private", Type: "container", AdministratorsOnly: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	containers, err := userClient.Containers(url.Values{"all": {"1"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if containersQuery != "all=1" {
+		t.Fatalf("expected the query to be forwarded, got %q", containersQuery)
+	}
+	if len(containers.Items) != 1 || containers.Items[0]["Id"] != "web" {
+		t.Fatalf("expected the containers of the authorized endpoints filtered by resource control, got %+v", containers.Items)
+	}
+	if endpoint, ok := containers.Items[0]["Endpoint"].(map[string]interface{}); !ok || endpoint["Id"] != float64(localID) || endpoint["Name"] != "local" {
+		t.Fatalf("expected the container to be annotated with its endpoint, got %+v", containers.Items[0])
+	}
+	if len(containers.Errors) != 1 || containers.Errors[0].Endpoint.ID != unreachableID || containers.Errors[0].Err == "" {
+		t.Fatalf("expected the unreachable endpoint to be reported, got %+v", containers.Errors)
+	}
+
+	services, err := userClient.Services(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(services.Items) != 0 || len(services.Errors) != 1 || services.Errors[0].Endpoint.ID != unreachableID {
+		t.Fatalf("expected the standalone endpoint to be skipped, got %+v", services)
+	}
+
+	volumes, err := userClient.Volumes(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(volumes.Items) != 1 || volumes.Items[0]["Name"] != "data" {
+		t.Fatalf("expected the volumes to be extracted from the Docker response, got %+v", volumes.Items)
+	}
+
+	containers, err = client.Containers(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(containers.Items) != 4 {
+		t.Fatalf("expected the administrator to list every container of every endpoint, got %+v", containers.Items)
+	}
+}
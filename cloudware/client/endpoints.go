@@ -0,0 +1,108 @@
+package client
+
+import (
+	"bytes"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"cloudware/cloudware/api"
+)
+
+type (
+	// EndpointCreateRequest represents the settings of a new endpoint.
+	// The TLS files are uploaded with UploadTLSFile once the endpoint is created.
+	EndpointCreateRequest struct {
+		Name                string
+		URL                 string
+		PublicURL           string
+		TLS                 bool
+		TLSSkipVerify       bool
+		TLSSkipClientVerify bool
+		RecordSessions      bool
+	}
+
+	// EndpointUpdateRequest represents the settings of an endpoint, the empty fields are left untouched.
+	EndpointUpdateRequest struct {
+		Name                string
+		URL                 string
+		PublicURL           string
+		TLS                 bool
+		TLSSkipVerify       bool
+		TLSSkipClientVerify bool
+		RecordSessions      *bool
+	}
+
+	// AccessRequest represents the users and teams authorized to use an endpoint or a registry.
+	AccessRequest struct {
+		AuthorizedUsers []api.UserID
+		AuthorizedTeams []api.TeamID
+	}
+)
+
+// Endpoints returns the endpoints the user can access.
+func (client *Client) Endpoints() ([]api.Endpoint, error) {
+	var endpoints []api.Endpoint
+	err := client.do(http.MethodGet, "/endpoints", nil, nil, &endpoints)
+	return endpoints, err
+}
+
+// Endpoint returns an endpoint.
+func (client *Client) Endpoint(ID api.EndpointID) (*api.Endpoint, error) {
+	var endpoint api.Endpoint
+	err := client.do(http.MethodGet, "/endpoints/"+strconv.Itoa(int(ID)), nil, nil, &endpoint)
+	if err != nil {
+		return nil, err
+	}
+	return &endpoint, nil
+}
+
+// CreateEndpoint creates an endpoint and returns its identifier.
+func (client *Client) CreateEndpoint(request *EndpointCreateRequest) (api.EndpointID, error) {
+	var response idResponse
+	err := client.do(http.MethodPost, "/endpoints", nil, request, &response)
+	return api.EndpointID(response.ID), err
+}
+
+// UpdateEndpoint updates the settings of an endpoint.
+func (client *Client) UpdateEndpoint(ID api.EndpointID, request *EndpointUpdateRequest) error {
+	return client.do(http.MethodPut, "/endpoints/"+strconv.Itoa(int(ID)), nil, request, nil)
+}
+
+// UpdateEndpointAccess replaces the users and teams authorized to use an endpoint.
+func (client *Client) UpdateEndpointAccess(ID api.EndpointID, request *AccessRequest) error {
+	return client.do(http.MethodPut, "/endpoints/"+strconv.Itoa(int(ID))+"/access", nil, request, nil)
+}
+
+// DeleteEndpoint deletes an endpoint.
+func (client *Client) DeleteEndpoint(ID api.EndpointID) error {
+	return client.do(http.MethodDelete, "/endpoints/"+strconv.Itoa(int(ID)), nil, nil, nil)
+}
+
+// UploadTLSFile uploads a TLS file of an endpoint, the certificate is one of ca, cert or key
+// and the folder is the identifier of the endpoint.
+func (client *Client) UploadTLSFile(folder, certificate string, r io.Reader) error {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	part, err := writer.CreateFormFile("file", certificate+".pem")
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(part, r)
+	if err != nil {
+		return err
+	}
+	err = writer.Close()
+	if err != nil {
+		return err
+	}
+
+	query := url.Values{"folder": []string{folder}}
+	response, err := client.request(http.MethodPost, "/upload/tls/"+certificate, query, &body, writer.FormDataContentType())
+	if err != nil {
+		return err
+	}
+	return response.Body.Close()
+}
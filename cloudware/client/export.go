@@ -0,0 +1,78 @@
+package client
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"cloudware/cloudware/api"
+	"cloudware/cloudware/api/apply"
+	"cloudware/cloudware/api/export"
+)
+
+// Export returns all the entities of the instance. The password hashes, registry passwords and
+// TLS keys are left out when omitSecrets is set.
+func (client *Client) Export(omitSecrets bool) (*export.Document, error) {
+	var document export.Document
+	query := url.Values{"omitSecrets": {strconv.FormatBool(omitSecrets)}}
+	err := client.do(http.MethodGet, "/export", query, nil, &document)
+	if err != nil {
+		return nil, err
+	}
+	return &document, nil
+}
+
+// Import creates the entities of an export document, nothing is created when dryRun is set. When failOnConflict
+// is set and conflicts are found, nothing is created either and the report is returned along with api.ErrImportConflicts.
+func (client *Client) Import(document *export.Document, dryRun, failOnConflict bool) (*export.ImportReport, error) {
+	query := url.Values{
+		"dryRun":         {strconv.FormatBool(dryRun)},
+		"failOnConflict": {strconv.FormatBool(failOnConflict)},
+	}
+	request, err := client.newRequest(http.MethodPost, "/import", query, document, "")
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := client.send(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	var report export.ImportReport
+	switch {
+	case response.StatusCode == http.StatusConflict:
+		err = json.NewDecoder(response.Body).Decode(&report)
+		if err != nil {
+			return nil, err
+		}
+		return &report, api.ErrImportConflicts
+	case response.StatusCode >= http.StatusBadRequest:
+		return nil, decodeError(response)
+	}
+
+	err = json.NewDecoder(response.Body).Decode(&report)
+	if err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
+
+// Apply reconciles the users, teams, endpoints, registries and settings with a configuration document.
+// The changes are only planned when dryRun is set, the entities missing from the specified sections
+// of the document are deleted when prune is set.
+func (client *Client) Apply(document *apply.Document, dryRun, prune bool) (*apply.Plan, error) {
+	query := url.Values{
+		"dryRun": {strconv.FormatBool(dryRun)},
+		"prune":  {strconv.FormatBool(prune)},
+	}
+
+	var plan apply.Plan
+	err := client.do(http.MethodPost, "/apply", query, document, &plan)
+	if err != nil {
+		return nil, err
+	}
+	return &plan, nil
+}
@@ -0,0 +1,110 @@
+package client
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"cloudware/cloudware/api"
+)
+
+type (
+	// LogOptions selects the containers and the entries of a log stream. Exactly one of Containers,
+	// Service or Stack must be specified.
+	LogOptions struct {
+		Containers []string
+		Service    string
+		Stack      string
+		Since      string
+		Until      string
+		// Tail is the number of entries per container, or all.
+		Tail   string
+		Follow bool
+		Grep   string
+	}
+
+	// LogEntry represents a line written by a container.
+	LogEntry struct {
+		ContainerID   string    `json:"ContainerId"`
+		ContainerName string    `json:"ContainerName"`
+		Stream        string    `json:"Stream"`
+		Timestamp     time.Time `json:"Timestamp"`
+		Message       string    `json:"Message"`
+	}
+)
+
+func (options *LogOptions) query() url.Values {
+	query := url.Values{}
+	if len(options.Containers) > 0 {
+		query.Set("containers", strings.Join(options.Containers, ","))
+	}
+	if options.Service != "" {
+		query.Set("service", options.Service)
+	}
+	if options.Stack != "" {
+		query.Set("stack", options.Stack)
+	}
+	if options.Since != "" {
+		query.Set("since", options.Since)
+	}
+	if options.Until != "" {
+		query.Set("until", options.Until)
+	}
+	if options.Tail != "" {
+		query.Set("tail", options.Tail)
+	}
+	if options.Grep != "" {
+		query.Set("grep", options.Grep)
+	}
+	query.Set("follow", strconv.FormatBool(options.Follow))
+	return query
+}
+
+// Logs streams the logs of containers of an endpoint, fn is called with each entry in chronological order.
+// It returns when the stream ends, when fn returns an error or when the server reports an error.
+func (client *Client) Logs(endpointID api.EndpointID, options *LogOptions, fn func(entry *LogEntry) error) error {
+	response, err := client.request(http.MethodGet, "/endpoints/"+strconv.Itoa(int(endpointID))+"/logs", options.query(), nil, "")
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	// The logs are sent as server-sent events, an event is ended by an empty line.
+	scanner := bufio.NewScanner(response.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	event := ""
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "event: "):
+			event = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			data := []byte(strings.TrimPrefix(line, "data: "))
+			switch event {
+			case "end":
+				return nil
+			case "error":
+				var body errorResponse
+				json.Unmarshal(data, &body)
+				return &Error{StatusCode: http.StatusOK, Message: body.Err}
+			}
+
+			var entry LogEntry
+			err := json.Unmarshal(data, &entry)
+			if err != nil {
+				return err
+			}
+			err = fn(&entry)
+			if err != nil {
+				return err
+			}
+		case line == "":
+			event = ""
+		}
+	}
+	return scanner.Err()
+}
@@ -0,0 +1,58 @@
+package client
+
+import (
+	"io/ioutil"
+	"net/http"
+	"strconv"
+
+	"cloudware/cloudware/api"
+)
+
+// CertificateReportEntry represents a certificate used by the instance.
+// Source is pki for the built-in certificate authority, endpoint or ldap.
+type CertificateReportEntry struct {
+	Source       string               `json:"Source"`
+	EndpointID   api.EndpointID       `json:"EndpointId,omitempty"`
+	EndpointName string               `json:"EndpointName,omitempty"`
+	Managed      bool                 `json:"Managed"`
+	Certificate  *api.CertificateInfo `json:"Certificate,omitempty"`
+	Err          string               `json:"err,omitempty"`
+}
+
+// CACertificate returns the PEM encoded certificate of the built-in certificate authority.
+func (client *Client) CACertificate() (string, error) {
+	response, err := client.request(http.MethodGet, "/pki/ca", nil, nil, "")
+	if err != nil {
+		return "", err
+	}
+	defer response.Body.Close()
+
+	data, err := ioutil.ReadAll(response.Body)
+	return string(data), err
+}
+
+// Certificates returns the certificates used by the instance along with their expiry.
+func (client *Client) Certificates() ([]CertificateReportEntry, error) {
+	var report []CertificateReportEntry
+	err := client.do(http.MethodGet, "/pki/certificates", nil, nil, &report)
+	return report, err
+}
+
+// IssueServerCertificate issues a certificate for a Docker daemon, signed by the built-in certificate authority.
+// The default validity is used when validityDays is 0.
+func (client *Client) IssueServerCertificate(hosts []string, validityDays int) (*api.CertificateBundle, error) {
+	var bundle api.CertificateBundle
+	err := client.do(http.MethodPost, "/pki/server_certificates", nil, struct {
+		Hosts        []string
+		ValidityDays int
+	}{hosts, validityDays}, &bundle)
+	if err != nil {
+		return nil, err
+	}
+	return &bundle, nil
+}
+
+// IssueEndpointCertificate issues a client certificate used by the instance to connect to an endpoint.
+func (client *Client) IssueEndpointCertificate(endpointID api.EndpointID) error {
+	return client.do(http.MethodPost, "/pki/endpoints/"+strconv.Itoa(int(endpointID)), nil, nil, nil)
+}
@@ -0,0 +1,43 @@
+package client
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+
+	"cloudware/cloudware/api"
+)
+
+// Recordings returns the recorded terminal sessions.
+func (client *Client) Recordings() ([]api.Recording, error) {
+	var recordings []api.Recording
+	err := client.do(http.MethodGet, "/recordings", nil, nil, &recordings)
+	return recordings, err
+}
+
+// Recording returns a recorded terminal session.
+func (client *Client) Recording(ID api.RecordingID) (*api.Recording, error) {
+	var recording api.Recording
+	err := client.do(http.MethodGet, "/recordings/"+strconv.Itoa(int(ID)), nil, nil, &recording)
+	if err != nil {
+		return nil, err
+	}
+	return &recording, nil
+}
+
+// RecordingFile writes the asciicast file of a recorded terminal session to w.
+func (client *Client) RecordingFile(ID api.RecordingID, w io.Writer) error {
+	response, err := client.request(http.MethodGet, "/recordings/"+strconv.Itoa(int(ID))+"/file", nil, nil, "")
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	_, err = io.Copy(w, response.Body)
+	return err
+}
+
+// DeleteRecording deletes a recorded terminal session.
+func (client *Client) DeleteRecording(ID api.RecordingID) error {
+	return client.do(http.MethodDelete, "/recordings/"+strconv.Itoa(int(ID)), nil, nil, nil)
+}
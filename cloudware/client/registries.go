@@ -0,0 +1,94 @@
+package client
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"cloudware/cloudware/api"
+)
+
+// RegistryRequest represents the settings of a registry. When a registry is updated,
+// the current password is kept when Password is nil.
+type RegistryRequest struct {
+	Name           string
+	URL            string
+	Authentication bool
+	Username       string
+	Password       *string `json:",omitempty"`
+}
+
+// Registries returns the registries the user can access.
+func (client *Client) Registries() ([]api.Registry, error) {
+	var registries []api.Registry
+	err := client.do(http.MethodGet, "/registries", nil, nil, &registries)
+	return registries, err
+}
+
+// Registry returns a registry.
+func (client *Client) Registry(ID api.RegistryID) (*api.Registry, error) {
+	var registry api.Registry
+	err := client.do(http.MethodGet, "/registries/"+strconv.Itoa(int(ID)), nil, nil, &registry)
+	if err != nil {
+		return nil, err
+	}
+	return &registry, nil
+}
+
+// CreateRegistry creates a registry and returns its identifier. When check is set, the registry
+// is only created once it is reachable and accepts its credentials.
+func (client *Client) CreateRegistry(request *RegistryRequest, check bool) (api.RegistryID, error) {
+	var response idResponse
+	query := url.Values{"check": {strconv.FormatBool(check)}}
+	err := client.do(http.MethodPost, "/registries", query, request, &response)
+	return api.RegistryID(response.ID), err
+}
+
+// UpdateRegistry replaces the settings of a registry, the password is kept when it is empty.
+// When check is set, the registry is only updated once it is reachable and accepts its credentials.
+func (client *Client) UpdateRegistry(ID api.RegistryID, request *RegistryRequest, check bool) error {
+	query := url.Values{"check": {strconv.FormatBool(check)}}
+	return client.do(http.MethodPut, "/registries/"+strconv.Itoa(int(ID)), query, request, nil)
+}
+
+// UpdateRegistryAccess replaces the users and teams authorized to use a registry.
+func (client *Client) UpdateRegistryAccess(ID api.RegistryID, request *AccessRequest) error {
+	return client.do(http.MethodPut, "/registries/"+strconv.Itoa(int(ID))+"/access", nil, request, nil)
+}
+
+// DeleteRegistry deletes a registry.
+func (client *Client) DeleteRegistry(ID api.RegistryID) error {
+	return client.do(http.MethodDelete, "/registries/"+strconv.Itoa(int(ID)), nil, nil, nil)
+}
+
+// RegistryRepositories returns the repositories of a registry.
+func (client *Client) RegistryRepositories(ID api.RegistryID) ([]string, error) {
+	var repositories []string
+	err := client.do(http.MethodGet, "/registries/"+strconv.Itoa(int(ID))+"/repositories", nil, nil, &repositories)
+	return repositories, err
+}
+
+// RegistryTags returns the tags of a repository of a registry.
+func (client *Client) RegistryTags(ID api.RegistryID, repository string) ([]string, error) {
+	var tags []string
+	err := client.do(http.MethodGet, "/registries/"+strconv.Itoa(int(ID))+"/tags", url.Values{"repository": {repository}}, nil, &tags)
+	return tags, err
+}
+
+// RegistryManifest returns the manifest of an image of a registry, identified by a tag or a digest.
+func (client *Client) RegistryManifest(ID api.RegistryID, repository, reference string) (*api.RegistryManifest, error) {
+	var manifest api.RegistryManifest
+	path := "/registries/" + strconv.Itoa(int(ID)) + "/manifests/" + url.PathEscape(reference)
+	err := client.do(http.MethodGet, path, url.Values{"repository": {repository}}, nil, &manifest)
+	if err != nil {
+		return nil, err
+	}
+	return &manifest, nil
+}
+
+// DeleteRegistryTag deletes the image a tag refers to, the other tags of the image are deleted along with it.
+// It is restricted to the administrators.
+func (client *Client) DeleteRegistryTag(ID api.RegistryID, repository, tag string) error {
+	path := "/registries/" + strconv.Itoa(int(ID)) + "/tags/" + url.PathEscape(tag)
+	return client.do(http.MethodDelete, path, url.Values{"repository": {repository}}, nil, nil)
+}
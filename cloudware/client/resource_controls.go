@@ -0,0 +1,43 @@
+package client
+
+import (
+	"net/http"
+	"strconv"
+
+	"cloudware/cloudware/api"
+)
+
+type (
+	// ResourceControlCreateRequest represents the access control of a Docker resource.
+	// Type is one of container, service, volume, network, secret, stack or config.
+	ResourceControlCreateRequest struct {
+		ResourceID         string
+		Type               string
+		AdministratorsOnly bool
+		Users              []api.UserID
+		Teams              []api.TeamID
+		SubResourceIDs     []string
+	}
+
+	// ResourceControlUpdateRequest represents the new access control of a Docker resource.
+	ResourceControlUpdateRequest struct {
+		AdministratorsOnly bool
+		Users              []api.UserID
+		Teams              []api.TeamID
+	}
+)
+
+// CreateResourceControl restricts the access to a Docker resource.
+func (client *Client) CreateResourceControl(request *ResourceControlCreateRequest) error {
+	return client.do(http.MethodPost, "/resource_controls", nil, request, nil)
+}
+
+// UpdateResourceControl replaces the access control of a Docker resource.
+func (client *Client) UpdateResourceControl(ID api.ResourceControlID, request *ResourceControlUpdateRequest) error {
+	return client.do(http.MethodPut, "/resource_controls/"+strconv.Itoa(int(ID)), nil, request, nil)
+}
+
+// DeleteResourceControl removes the access control of a Docker resource.
+func (client *Client) DeleteResourceControl(ID api.ResourceControlID) error {
+	return client.do(http.MethodDelete, "/resource_controls/"+strconv.Itoa(int(ID)), nil, nil, nil)
+}
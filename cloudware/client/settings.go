@@ -0,0 +1,116 @@
+package client
+
+import (
+	"net/http"
+	"net/url"
+
+	"cloudware/cloudware/api"
+)
+
+type (
+	// SettingsUpdateRequest represents the new settings of the instance. The backup settings are left
+	// untouched when BackupSettings is nil.
+	SettingsUpdateRequest struct {
+		TemplatesURL                       string
+		LogoURL                            string
+		BlackListedLabels                  []api.Pair
+		DisplayDonationHeader              bool
+		DisplayExternalContributors        bool
+		AuthenticationMethod               api.AuthenticationMethod
+		LDAPSettings                       LDAPSettingsRequest
+		AllowBindMountsForRegularUsers     bool
+		AllowPrivilegedModeForRegularUsers bool
+		SessionRecordingRetention          int
+		BackupSettings                     *api.BackupSettings
+	}
+
+	// LDAPSettingsRequest represents the LDAP settings of a settings update,
+	// the current password is kept when Password is nil.
+	LDAPSettingsRequest struct {
+		api.LDAPSettings
+		Password *string `json:",omitempty"`
+	}
+
+	// DockerHubRequest represents the credentials used to pull images from the Docker Hub,
+	// the current password is kept when Password is nil.
+	DockerHubRequest struct {
+		Authentication bool
+		Username       string
+		Password       *string `json:",omitempty"`
+	}
+
+	// PublicSettings represents the settings available to the users which are not authenticated.
+	PublicSettings struct {
+		LogoURL                            string                   `json:"LogoURL"`
+		DisplayDonationHeader              bool                     `json:"DisplayDonationHeader"`
+		DisplayExternalContributors        bool                     `json:"DisplayExternalContributors"`
+		AuthenticationMethod               api.AuthenticationMethod `json:"AuthenticationMethod"`
+		AllowBindMountsForRegularUsers     bool                     `json:"AllowBindMountsForRegularUsers"`
+		AllowPrivilegedModeForRegularUsers bool                     `json:"AllowPrivilegedModeForRegularUsers"`
+	}
+)
+
+// Settings returns the settings of the instance.
+func (client *Client) Settings() (*api.Settings, error) {
+	var settings api.Settings
+	err := client.do(http.MethodGet, "/settings", nil, nil, &settings)
+	if err != nil {
+		return nil, err
+	}
+	return &settings, nil
+}
+
+// UpdateSettings replaces the settings of the instance.
+func (client *Client) UpdateSettings(request *SettingsUpdateRequest) error {
+	return client.do(http.MethodPut, "/settings", nil, request, nil)
+}
+
+// PublicSettings returns the settings available without authentication.
+func (client *Client) PublicSettings() (*PublicSettings, error) {
+	var settings PublicSettings
+	err := client.do(http.MethodGet, "/settings/public", nil, nil, &settings)
+	if err != nil {
+		return nil, err
+	}
+	return &settings, nil
+}
+
+// CheckLDAPSettings verifies that the instance can connect to a LDAP server with the specified settings.
+func (client *Client) CheckLDAPSettings(settings *LDAPSettingsRequest) error {
+	return client.do(http.MethodPut, "/settings/authentication/checkLDAP", nil, struct {
+		LDAPSettings *LDAPSettingsRequest
+	}{settings}, nil)
+}
+
+// Status returns the version and the enabled features of the instance.
+func (client *Client) Status() (*api.Status, error) {
+	var status api.Status
+	err := client.do(http.MethodGet, "/status", nil, nil, &status)
+	if err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+// DockerHub returns the credentials used to pull images from the Docker Hub.
+func (client *Client) DockerHub() (*api.DockerHub, error) {
+	var dockerhub api.DockerHub
+	err := client.do(http.MethodGet, "/dockerhub", nil, nil, &dockerhub)
+	if err != nil {
+		return nil, err
+	}
+	return &dockerhub, nil
+}
+
+// UpdateDockerHub replaces the credentials used to pull images from the Docker Hub.
+func (client *Client) UpdateDockerHub(dockerhub *DockerHubRequest) error {
+	return client.do(http.MethodPut, "/dockerhub", nil, dockerhub, nil)
+}
+
+// Templates returns the application templates of a source, either containers for the templates
+// configured in the settings or linuxserver.io.
+func (client *Client) Templates(key string) ([]map[string]interface{}, error) {
+	var templates []map[string]interface{}
+	err := client.do(http.MethodGet, "/templates", url.Values{"key": {key}}, nil, &templates)
+	return templates, err
+}
@@ -0,0 +1,104 @@
+package client
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"cloudware/cloudware/api"
+)
+
+type (
+	// StackCreateRequest represents a new stack. The stack file is either specified by its content,
+	// or by the path of the file in a git repository.
+	StackCreateRequest struct {
+		Name             string
+		SwarmID          string
+		StackFileContent string
+		GitRepository    string
+		PathInRepository string
+		Env              []api.Pair
+	}
+
+	// StackUpdateRequest represents the new content of a stack.
+	StackUpdateRequest struct {
+		StackFileContent string
+		Env              []StackEnvVariable
+	}
+
+	// StackEnvVariable represents an environment variable of a stack update,
+	// the current value of the variable is kept when Value is nil.
+	StackEnvVariable struct {
+		Name  string  `json:"name"`
+		Value *string `json:"value,omitempty"`
+	}
+
+	// ExtendedStack represents a stack along with its resource control.
+	ExtendedStack struct {
+		api.Stack
+		ResourceControl api.ResourceControl `json:"ResourceControl"`
+	}
+)
+
+func stacksPath(endpointID api.EndpointID) string {
+	return "/endpoints/" + strconv.Itoa(int(endpointID)) + "/stacks"
+}
+
+// Stacks returns the stacks of an endpoint the user can access.
+func (client *Client) Stacks(endpointID api.EndpointID) ([]api.Stack, error) {
+	var stacks []api.Stack
+	err := client.do(http.MethodGet, stacksPath(endpointID), nil, nil, &stacks)
+	return stacks, err
+}
+
+// Stack returns a stack of an endpoint.
+func (client *Client) Stack(endpointID api.EndpointID, ID api.StackID) (*ExtendedStack, error) {
+	var stack ExtendedStack
+	err := client.do(http.MethodGet, stacksPath(endpointID)+"/"+url.PathEscape(string(ID)), nil, nil, &stack)
+	if err != nil {
+		return nil, err
+	}
+	return &stack, nil
+}
+
+// StackFile returns the content of the stack file of a stack.
+func (client *Client) StackFile(endpointID api.EndpointID, ID api.StackID) (string, error) {
+	var response struct {
+		StackFileContent string `json:"StackFileContent"`
+	}
+	err := client.do(http.MethodGet, stacksPath(endpointID)+"/"+url.PathEscape(string(ID))+"/stackfile", nil, nil, &response)
+	return response.StackFileContent, err
+}
+
+// CreateStack deploys a new stack on an endpoint and returns its identifier.
+func (client *Client) CreateStack(endpointID api.EndpointID, request *StackCreateRequest) (api.StackID, error) {
+	method := "string"
+	if request.GitRepository != "" {
+		method = "repository"
+	}
+
+	var response struct {
+		ID string `json:"Id"`
+	}
+	err := client.do(http.MethodPost, stacksPath(endpointID), url.Values{"method": []string{method}}, request, &response)
+	return api.StackID(response.ID), err
+}
+
+// UpdateStack redeploys a stack with a new stack file.
+func (client *Client) UpdateStack(endpointID api.EndpointID, ID api.StackID, request *StackUpdateRequest) error {
+	return client.do(http.MethodPut, stacksPath(endpointID)+"/"+url.PathEscape(string(ID)), nil, request, nil)
+}
+
+// DeleteStack removes a stack from an endpoint.
+func (client *Client) DeleteStack(endpointID api.EndpointID, ID api.StackID) error {
+	return client.do(http.MethodDelete, stacksPath(endpointID)+"/"+url.PathEscape(string(ID)), nil, nil, nil)
+}
+
+// SwarmID returns the identifier of the swarm cluster of an endpoint, as reported by the Docker API.
+func (client *Client) SwarmID(endpointID api.EndpointID) (string, error) {
+	var swarm struct {
+		ID string `json:"ID"`
+	}
+	err := client.do(http.MethodGet, "/endpoints/"+strconv.Itoa(int(endpointID))+"/docker/swarm", nil, nil, &swarm)
+	return swarm.ID, err
+}
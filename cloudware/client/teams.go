@@ -0,0 +1,80 @@
+package client
+
+import (
+	"net/http"
+	"strconv"
+
+	"cloudware/cloudware/api"
+)
+
+// TeamMembershipRequest represents the membership of a user in a team.
+type TeamMembershipRequest struct {
+	UserID api.UserID
+	TeamID api.TeamID
+	Role   api.MembershipRole
+}
+
+// Teams returns the teams.
+func (client *Client) Teams() ([]api.Team, error) {
+	var teams []api.Team
+	err := client.do(http.MethodGet, "/teams", nil, nil, &teams)
+	return teams, err
+}
+
+// Team returns a team.
+func (client *Client) Team(ID api.TeamID) (*api.Team, error) {
+	var team api.Team
+	err := client.do(http.MethodGet, "/teams/"+strconv.Itoa(int(ID)), nil, nil, &team)
+	if err != nil {
+		return nil, err
+	}
+	return &team, nil
+}
+
+// CreateTeam creates a team and returns its identifier.
+func (client *Client) CreateTeam(name string) (api.TeamID, error) {
+	var response idResponse
+	err := client.do(http.MethodPost, "/teams", nil, struct{ Name string }{name}, &response)
+	return api.TeamID(response.ID), err
+}
+
+// UpdateTeam renames a team.
+func (client *Client) UpdateTeam(ID api.TeamID, name string) error {
+	return client.do(http.MethodPut, "/teams/"+strconv.Itoa(int(ID)), nil, struct{ Name string }{name}, nil)
+}
+
+// DeleteTeam deletes a team and its memberships.
+func (client *Client) DeleteTeam(ID api.TeamID) error {
+	return client.do(http.MethodDelete, "/teams/"+strconv.Itoa(int(ID)), nil, nil, nil)
+}
+
+// TeamMemberships returns the memberships of a team.
+func (client *Client) TeamMemberships(ID api.TeamID) ([]api.TeamMembership, error) {
+	var memberships []api.TeamMembership
+	err := client.do(http.MethodGet, "/teams/"+strconv.Itoa(int(ID))+"/memberships", nil, nil, &memberships)
+	return memberships, err
+}
+
+// CreateTeamMembership adds a user to a team and returns the identifier of the membership.
+func (client *Client) CreateTeamMembership(request *TeamMembershipRequest) (api.TeamMembershipID, error) {
+	var response idResponse
+	err := client.do(http.MethodPost, "/team_memberships", nil, request, &response)
+	return api.TeamMembershipID(response.ID), err
+}
+
+// DeleteTeamMembership removes a user from a team.
+func (client *Client) DeleteTeamMembership(ID api.TeamMembershipID) error {
+	return client.do(http.MethodDelete, "/team_memberships/"+strconv.Itoa(int(ID)), nil, nil, nil)
+}
+
+// Memberships returns the memberships of all the teams.
+func (client *Client) Memberships() ([]api.TeamMembership, error) {
+	var memberships []api.TeamMembership
+	err := client.do(http.MethodGet, "/team_memberships", nil, nil, &memberships)
+	return memberships, err
+}
+
+// UpdateTeamMembership replaces a membership, it is mostly used to change the role of a user in a team.
+func (client *Client) UpdateTeamMembership(ID api.TeamMembershipID, request *TeamMembershipRequest) error {
+	return client.do(http.MethodPut, "/team_memberships/"+strconv.Itoa(int(ID)), nil, request, nil)
+}
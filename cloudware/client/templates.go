@@ -0,0 +1,124 @@
+package client
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"cloudware/cloudware/api"
+)
+
+type (
+	// TemplateRequest represents the content of a template.
+	TemplateRequest struct {
+		Type             api.TemplateType
+		Title            string
+		Description      string
+		Note             string
+		Categories       []string
+		Platform         string
+		Logo             string
+		Image            string
+		Command          string
+		Ports            []string
+		Volumes          []string
+		Labels           []api.Pair
+		RestartPolicy    string
+		StackFileContent string
+		Repository       api.TemplateRepository
+		Parameters       []api.TemplateParameter
+		AuthorizedTeams  []api.TeamID
+	}
+
+	// TemplatesImportRequest represents the source of a templates document, either its URL
+	// or its path in a git repository.
+	TemplatesImportRequest struct {
+		URL              string
+		GitRepository    string
+		PathInRepository string
+	}
+
+	// TemplatesImportResponse represents the result of an import. Cached reports whether the
+	// source was unreachable and the templates were imported from the last copy retrieved.
+	TemplatesImportResponse struct {
+		Source string `json:"Source"`
+		Count  int    `json:"Count"`
+		Cached bool   `json:"Cached"`
+	}
+
+	// TemplateDeployRequest represents the deployment of a template, the name and the swarm
+	// are required by the stack templates.
+	TemplateDeployRequest struct {
+		Name       string
+		SwarmID    string
+		Parameters []api.Pair
+	}
+
+	// TemplateDeployResponse identifies the deployed container or stack, along with the
+	// resource control restricting it to the user.
+	TemplateDeployResponse struct {
+		ID                string                `json:"Id"`
+		ResourceControlID api.ResourceControlID `json:"ResourceControlId"`
+	}
+)
+
+// ListTemplates returns the stored templates the user can see, optionally restricted to a category.
+func (client *Client) ListTemplates(category string) ([]api.Template, error) {
+	var query url.Values
+	if category != "" {
+		query = url.Values{"category": []string{category}}
+	}
+
+	var templates []api.Template
+	err := client.do(http.MethodGet, "/templates", query, nil, &templates)
+	return templates, err
+}
+
+// Template returns a template.
+func (client *Client) Template(ID api.TemplateID) (*api.Template, error) {
+	var template api.Template
+	err := client.do(http.MethodGet, "/templates/"+strconv.Itoa(int(ID)), nil, nil, &template)
+	if err != nil {
+		return nil, err
+	}
+	return &template, nil
+}
+
+// CreateTemplate creates a template and returns its identifier.
+func (client *Client) CreateTemplate(request *TemplateRequest) (api.TemplateID, error) {
+	var response idResponse
+	err := client.do(http.MethodPost, "/templates", nil, request, &response)
+	return api.TemplateID(response.ID), err
+}
+
+// UpdateTemplate replaces the content of a template.
+func (client *Client) UpdateTemplate(ID api.TemplateID, request *TemplateRequest) error {
+	return client.do(http.MethodPut, "/templates/"+strconv.Itoa(int(ID)), nil, request, nil)
+}
+
+// DeleteTemplate deletes a template.
+func (client *Client) DeleteTemplate(ID api.TemplateID) error {
+	return client.do(http.MethodDelete, "/templates/"+strconv.Itoa(int(ID)), nil, nil, nil)
+}
+
+// ImportTemplates imports the templates of a templates document, they replace the templates
+// previously imported from the same source.
+func (client *Client) ImportTemplates(request *TemplatesImportRequest) (*TemplatesImportResponse, error) {
+	var response TemplatesImportResponse
+	err := client.do(http.MethodPost, "/templates/import", nil, request, &response)
+	if err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// DeployTemplate deploys a template on an endpoint.
+func (client *Client) DeployTemplate(endpointID api.EndpointID, ID api.TemplateID, request *TemplateDeployRequest) (*TemplateDeployResponse, error) {
+	var response TemplateDeployResponse
+	path := "/endpoints/" + strconv.Itoa(int(endpointID)) + "/templates/" + strconv.Itoa(int(ID)) + "/deploy"
+	err := client.do(http.MethodPost, path, nil, request, &response)
+	if err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
@@ -0,0 +1,90 @@
+package client
+
+import (
+	"net/http"
+	"strconv"
+
+	"cloudware/cloudware/api"
+)
+
+type (
+	// UserCreateRequest represents a new user. The password can be empty when the users authenticate with LDAP.
+	UserCreateRequest struct {
+		Username string
+		Password string
+		Role     api.UserRole
+	}
+
+	// UserUpdateRequest represents the new password or role of a user, the empty fields are left untouched.
+	UserUpdateRequest struct {
+		Password string
+		Role     api.UserRole
+	}
+)
+
+// Users returns the users.
+func (client *Client) Users() ([]api.User, error) {
+	var users []api.User
+	err := client.do(http.MethodGet, "/users", nil, nil, &users)
+	return users, err
+}
+
+// User returns a user.
+func (client *Client) User(ID api.UserID) (*api.User, error) {
+	var user api.User
+	err := client.do(http.MethodGet, "/users/"+strconv.Itoa(int(ID)), nil, nil, &user)
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// CreateUser creates a user and returns its identifier.
+func (client *Client) CreateUser(request *UserCreateRequest) (api.UserID, error) {
+	var response idResponse
+	err := client.do(http.MethodPost, "/users", nil, request, &response)
+	return api.UserID(response.ID), err
+}
+
+// UpdateUser updates the password or the role of a user.
+func (client *Client) UpdateUser(ID api.UserID, request *UserUpdateRequest) error {
+	return client.do(http.MethodPut, "/users/"+strconv.Itoa(int(ID)), nil, request, nil)
+}
+
+// DeleteUser deletes a user.
+func (client *Client) DeleteUser(ID api.UserID) error {
+	return client.do(http.MethodDelete, "/users/"+strconv.Itoa(int(ID)), nil, nil, nil)
+}
+
+// UserMemberships returns the team memberships of a user.
+func (client *Client) UserMemberships(ID api.UserID) ([]api.TeamMembership, error) {
+	var memberships []api.TeamMembership
+	err := client.do(http.MethodGet, "/users/"+strconv.Itoa(int(ID))+"/memberships", nil, nil, &memberships)
+	return memberships, err
+}
+
+// CheckPassword reports whether password is the current password of a user.
+func (client *Client) CheckPassword(ID api.UserID, password string) (bool, error) {
+	var response struct {
+		Valid bool `json:"valid"`
+	}
+	err := client.do(http.MethodPost, "/users/"+strconv.Itoa(int(ID))+"/passwd", nil, struct{ Password string }{password}, &response)
+	return response.Valid, err
+}
+
+// AdministratorExists reports whether an administrator account was created on the instance.
+func (client *Client) AdministratorExists() (bool, error) {
+	err := client.do(http.MethodGet, "/users/admin/check", nil, nil, nil)
+	if apiErr, ok := err.(*Error); ok && apiErr.StatusCode == http.StatusNotFound {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// InitAdministrator creates the first administrator account of the instance.
+func (client *Client) InitAdministrator(username, password string) error {
+	return client.do(http.MethodPost, "/users/admin/init", nil, struct {
+		Username string
+		Password string
+	}{username, password}, nil)
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/spf13/cobra"
+
+	"cloudware/cloudware/api"
+	"cloudware/cloudware/cli"
+	"cloudware/cloudware/client"
+)
+
+type accessOptions struct {
+	users []string
+	teams []string
+}
+
+func parseID(kind, arg string) (int, error) {
+	ID, err := strconv.Atoi(arg)
+	if err != nil {
+		return 0, fmt.Errorf("Invalid %s identifier %s", kind, arg)
+	}
+	return ID, nil
+}
+
+// newAccessCommand returns the command replacing the users and teams authorized to use an endpoint or a registry.
+func newAccessCommand(cloudwareCli *cloudwareCli, kind string, update func(c *client.Client, ID int, request *client.AccessRequest) error) *cobra.Command {
+	var opts accessOptions
+
+	cmd := &cobra.Command{
+		Use:   fmt.Sprintf("access [OPTIONS] %s", kindArg(kind)),
+		Short: fmt.Sprintf("Replace the users and teams authorized to use a %s", kind),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := cli.ExactArgs(1)(cmd, args); err != nil {
+				return err
+			}
+			c, err := cloudwareCli.Client()
+			if err != nil {
+				return err
+			}
+			ID, err := parseID(kind, args[0])
+			if err != nil {
+				return err
+			}
+
+			request := &client.AccessRequest{}
+			request.AuthorizedUsers, err = resolveUsers(c, opts.users)
+			if err != nil {
+				return err
+			}
+			request.AuthorizedTeams, err = resolveTeams(c, opts.teams)
+			if err != nil {
+				return err
+			}
+			return update(c, ID, request)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringSliceVar(&opts.users, "user", nil, "Name or identifier of an authorized user")
+	flags.StringSliceVar(&opts.teams, "team", nil, "Name or identifier of an authorized team")
+	return cmd
+}
+
+func kindArg(kind string) string {
+	switch kind {
+	case "endpoint":
+		return "ENDPOINT"
+	case "registry":
+		return "REGISTRY"
+	}
+	return "ID"
+}
+
+// resolveUsers returns the identifiers of users specified by name or identifier.
+func resolveUsers(c *client.Client, args []string) ([]api.UserID, error) {
+	IDs := make([]api.UserID, 0, len(args))
+	if len(args) == 0 {
+		return IDs, nil
+	}
+
+	users, err := c.Users()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, arg := range args {
+		ID, err := resolveUser(users, arg)
+		if err != nil {
+			return nil, err
+		}
+		IDs = append(IDs, ID)
+	}
+	return IDs, nil
+}
+
+func resolveUser(users []api.User, arg string) (api.UserID, error) {
+	for _, user := range users {
+		if user.Username == arg || strconv.Itoa(int(user.ID)) == arg {
+			return user.ID, nil
+		}
+	}
+	return 0, fmt.Errorf("User %s not found", arg)
+}
+
+// resolveTeams returns the identifiers of teams specified by name or identifier.
+func resolveTeams(c *client.Client, args []string) ([]api.TeamID, error) {
+	IDs := make([]api.TeamID, 0, len(args))
+	if len(args) == 0 {
+		return IDs, nil
+	}
+
+	teams, err := c.Teams()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, arg := range args {
+		ID, err := resolveTeam(teams, arg)
+		if err != nil {
+			return nil, err
+		}
+		IDs = append(IDs, ID)
+	}
+	return IDs, nil
+}
+
+func resolveTeam(teams []api.Team, arg string) (api.TeamID, error) {
+	for _, team := range teams {
+		if team.Name == arg || strconv.Itoa(int(team.ID)) == arg {
+			return team.ID, nil
+		}
+	}
+	return 0, fmt.Errorf("Team %s not found", arg)
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"cloudware/cloudware/cli"
+	"cloudware/cloudware/cli/config"
+	"cloudware/cloudware/client"
+	"cloudware/cloudware/pkg/term"
+)
+
+const (
+	version = "0.1"
+)
+
+type globalOptions struct {
+	configDir string
+	context   string
+	format    string
+}
+
+// cloudwareCli holds the streams and the global options shared by the commands.
+type cloudwareCli struct {
+	opts globalOptions
+	in   io.ReadCloser
+	out  io.Writer
+	err  io.Writer
+}
+
+func newCloudwareCommand(cloudwareCli *cloudwareCli) *cobra.Command {
+	var showVersion bool
+
+	cmd := &cobra.Command{
+		Use:           "cloudware [OPTIONS] COMMAND",
+		Short:         "A command-line client for the cloudware management platform.",
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			return validateFormat(cloudwareCli.opts.format)
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if showVersion {
+				fmt.Fprintf(cloudwareCli.out, "Cloudware client version %s\n", version)
+				return nil
+			}
+			if err := cli.NoArgs(cmd, args); err != nil {
+				return err
+			}
+			return cmd.Help()
+		},
+	}
+	cli.SetupRootCommand(cmd)
+
+	flags := cmd.Flags()
+	flags.BoolVarP(&showVersion, "version", "v", false, "Print version information and quit")
+
+	persistentFlags := cmd.PersistentFlags()
+	persistentFlags.StringVar(&cloudwareCli.opts.configDir, "config", config.Dir(), "Location of the client configuration files")
+	persistentFlags.StringVar(&cloudwareCli.opts.context, "context", "", "Name of the context to use, defaults to the current context")
+	persistentFlags.StringVar(&cloudwareCli.opts.format, "format", formatTable, "Output format: table, json or yaml")
+
+	cmd.AddCommand(
+		newLoginCommand(cloudwareCli),
+		newLogoutCommand(cloudwareCli),
+		newContextCommand(cloudwareCli),
+		newEndpointCommand(cloudwareCli),
+		newStackCommand(cloudwareCli),
+		newUserCommand(cloudwareCli),
+		newTeamCommand(cloudwareCli),
+		newRegistryCommand(cloudwareCli),
+		newResourceControlCommand(cloudwareCli),
+	)
+	return cmd
+}
+
+// ConfigFile loads the configuration file of the client.
+func (cloudwareCli *cloudwareCli) ConfigFile() (*config.ConfigFile, error) {
+	return config.Load(cloudwareCli.opts.configDir)
+}
+
+// Client returns a client authenticated with the token of the selected context.
+func (cloudwareCli *cloudwareCli) Client() (*client.Client, error) {
+	configFile, err := cloudwareCli.ConfigFile()
+	if err != nil {
+		return nil, err
+	}
+
+	context, err := configFile.Context(cloudwareCli.opts.context)
+	if err != nil {
+		return nil, err
+	}
+	if context.Token == "" {
+		return nil, fmt.Errorf("Not logged in to %s, run 'cloudware login %s'", context.URL, context.URL)
+	}
+
+	c := client.NewClient(context.URL, newHTTPClient(context.InsecureSkipVerify))
+	c.Token = context.Token
+	return c, nil
+}
+
+func newHTTPClient(insecureSkipVerify bool) *http.Client {
+	transport := &http.Transport{
+		Proxy:           http.ProxyFromEnvironment,
+		TLSClientConfig: &tls.Config{InsecureSkipVerify: insecureSkipVerify},
+	}
+	// No timeout is set as the logs are streamed until the user interrupts the command.
+	return &http.Client{Transport: transport}
+}
+
+func main() {
+	stdin, stdout, stderr := term.StdStreams()
+
+	cloudwareCli := &cloudwareCli{in: stdin, out: stdout, err: stderr}
+	cmd := newCloudwareCommand(cloudwareCli)
+	cmd.SetOutput(stdout)
+	if err := cmd.Execute(); err != nil {
+		if apiErr, ok := err.(*client.Error); ok && apiErr.StatusCode == http.StatusUnauthorized {
+			fmt.Fprintln(stderr, "The session has expired, log in again with 'cloudware login'")
+		}
+		fmt.Fprintf(stderr, "%s\n", err)
+		os.Exit(1)
+	}
+}
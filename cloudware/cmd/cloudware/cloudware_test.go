@@ -0,0 +1,244 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"cloudware/cloudware/api"
+	"cloudware/cloudware/cli/config"
+	"cloudware/cloudware/client"
+)
+
+// fakeInstance serves the authentication and the team routes, each authentication issues a new token
+// and the previous ones are rejected.
+type fakeInstance struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	token    string
+	issued   int
+	password string
+}
+
+func newFakeInstance() *fakeInstance {
+	instance := &fakeInstance{password: "password"}
+	instance.Server = httptest.NewServer(http.HandlerFunc(instance.serveHTTP))
+	return instance
+}
+
+func (instance *fakeInstance) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	instance.mu.Lock()
+	defer instance.mu.Unlock()
+
+	if r.URL.Path == "/api/auth" {
+		var credentials struct {
+			Username string
+			Password string
+		}
+		json.NewDecoder(r.Body).Decode(&credentials)
+		if credentials.Username != "admin" || credentials.Password != instance.password {
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			json.NewEncoder(w).Encode(map[string]string{"err": "Invalid credentials"})
+			return
+		}
+		instance.issued++
+		instance.token = "token-" + strconv.Itoa(instance.issued)
+		json.NewEncoder(w).Encode(map[string]string{"jwt": instance.token})
+		return
+	}
+
+	if r.Header.Get("Authorization") != "Bearer "+instance.token {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]string{"err": "Invalid JWT token"})
+		return
+	}
+	if r.URL.Path == "/api/teams" {
+		json.NewEncoder(w).Encode([]api.Team{{ID: 1, Name: "dev"}, {ID: 12, Name: "operations"}})
+		return
+	}
+	w.WriteHeader(http.StatusNotFound)
+}
+
+// expire invalidates the token issued last.
+func (instance *fakeInstance) expire() {
+	instance.mu.Lock()
+	defer instance.mu.Unlock()
+	instance.token = ""
+}
+
+// runCommand runs the command-line client with the specified arguments and standard input.
+func runCommand(t *testing.T, configDir, stdin string, args ...string) (string, error) {
+	var out bytes.Buffer
+	cloudwareCli := &cloudwareCli{in: ioutil.NopCloser(strings.NewReader(stdin)), out: &out, err: &out}
+
+	cmd := newCloudwareCommand(cloudwareCli)
+	cmd.SetOutput(&out)
+	cmd.SetArgs(append([]string{"--config", configDir}, args...))
+	err := cmd.Execute()
+	return out.String(), err
+}
+
+func newConfigDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "cloudware")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestLogin(t *testing.T) {
+	instance := newFakeInstance()
+	defer instance.Close()
+	configDir := newConfigDir(t)
+	defer os.RemoveAll(configDir)
+
+	_, err := runCommand(t, configDir, "", "login", "--username", "admin", "--password", "wrong", instance.URL)
+	if apiErr, ok := err.(*client.Error); !ok || apiErr.StatusCode != http.StatusUnprocessableEntity {
+		t.Fatalf("expected the credentials to be rejected, got %v", err)
+	}
+
+	output, err := runCommand(t, configDir, "admin\npassword\n", "login", "--name", "local", instance.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(output, "Username: ") || !strings.Contains(output, "Password: ") || !strings.Contains(output, "context local selected") {
+		t.Fatalf("expected the credentials to be prompted, got %q", output)
+	}
+
+	configFile, err := config.Load(configDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	context := configFile.Contexts["local"]
+	if configFile.CurrentContext != "local" || context == nil || context.URL != instance.URL || context.Username != "admin" || context.Token != "token-1" {
+		t.Fatalf("expected the token to be stored in the context, got %+v", configFile)
+	}
+
+	_, err = runCommand(t, configDir, "", "login", "--password", "password", "ftp:/nohost")
+	if err == nil {
+		t.Fatal("expected an invalid URL to be rejected")
+	}
+
+	// The password is read from the standard input, the context is named after the host by default.
+	_, err = runCommand(t, configDir, "password\n", "login", "-u", "admin", "--password-stdin", instance.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	configFile, _ = config.Load(configDir)
+	host := strings.TrimPrefix(instance.URL, "http://")
+	if configFile.CurrentContext != host || configFile.Contexts[host].Token != "token-2" || len(configFile.Contexts) != 2 {
+		t.Fatalf("expected a second context, got %+v", configFile)
+	}
+}
+
+func TestLoginAgainAfterExpiry(t *testing.T) {
+	instance := newFakeInstance()
+	defer instance.Close()
+	configDir := newConfigDir(t)
+	defer os.RemoveAll(configDir)
+
+	_, err := runCommand(t, configDir, "", "login", "-u", "admin", "-p", "password", "--name", "local", instance.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = runCommand(t, configDir, "", "team", "ls")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	instance.expire()
+	_, err = runCommand(t, configDir, "", "team", "ls")
+	if apiErr, ok := err.(*client.Error); !ok || apiErr.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected the expired token to be rejected, got %v", err)
+	}
+
+	// Without URL, the user logs in again to the instance of the selected context with the stored username.
+	_, err = runCommand(t, configDir, "password\n", "login", "--password-stdin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	configFile, _ := config.Load(configDir)
+	if len(configFile.Contexts) != 1 || configFile.Contexts["local"].Token != "token-2" || configFile.Contexts["local"].URL != instance.URL {
+		t.Fatalf("expected the token of the context to be replaced, got %+v", configFile.Contexts)
+	}
+	_, err = runCommand(t, configDir, "", "team", "ls")
+	if err != nil {
+		t.Fatalf("expected the new token to be used, got %v", err)
+	}
+
+	output, err := runCommand(t, configDir, "", "logout")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(output, "Logged out of "+instance.URL) {
+		t.Fatalf("unexpected output %q", output)
+	}
+	_, err = runCommand(t, configDir, "", "team", "ls")
+	if err == nil || !strings.Contains(err.Error(), "Not logged in") {
+		t.Fatalf("expected the command to require a login, got %v", err)
+	}
+}
+
+func TestOutputFormats(t *testing.T) {
+	instance := newFakeInstance()
+	defer instance.Close()
+	configDir := newConfigDir(t)
+	defer os.RemoveAll(configDir)
+
+	_, err := runCommand(t, configDir, "", "login", "-u", "admin", "-p", "password", "--name", "local", instance.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	output, err := runCommand(t, configDir, "", "team", "ls")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "ID   NAME\n" +
+		"1    dev\n" +
+		"12   operations\n"
+	if output != expected {
+		t.Fatalf("expected the columns to be aligned, got\n%s", output)
+	}
+
+	output, err = runCommand(t, configDir, "", "--format", "json", "team", "ls")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var teams []api.Team
+	err = json.Unmarshal([]byte(output), &teams)
+	if err != nil || len(teams) != 2 || teams[1].Name != "operations" {
+		t.Fatalf("expected the teams in JSON, got %q", output)
+	}
+
+	output, err = runCommand(t, configDir, "", "--format", "yaml", "team", "ls")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(output, "- Id: 12\n  Name: operations\n") {
+		t.Fatalf("expected the teams in YAML with the JSON keys, got\n%s", output)
+	}
+
+	_, err = runCommand(t, configDir, "", "--format", "xml", "team", "ls")
+	if err == nil {
+		t.Fatal("expected an invalid format to be rejected")
+	}
+
+	output, err = runCommand(t, configDir, "", "context", "ls")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	if len(lines) != 2 || strings.Join(strings.Fields(lines[1]), " ") != "local * "+instance.URL+" admin yes" ||
+		strings.Index(lines[0], "URL") != strings.Index(lines[1], instance.URL) {
+		t.Fatalf("unexpected contexts\n%s", output)
+	}
+}
@@ -0,0 +1,270 @@
+package main
+
+import (
+	"os"
+	"strconv"
+
+	"github.com/spf13/cobra"
+
+	"cloudware/cloudware/api"
+	"cloudware/cloudware/cli"
+	"cloudware/cloudware/client"
+)
+
+type endpointOptions struct {
+	publicURL           string
+	tls                 bool
+	tlsSkipVerify       bool
+	tlsSkipClientVerify bool
+	tlsCACert           string
+	tlsCert             string
+	tlsKey              string
+	recordSessions      bool
+}
+
+func newEndpointCommand(cloudwareCli *cloudwareCli) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "endpoint",
+		Short: "Manage the endpoints",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return cli.NoArgs(cmd, args)
+		},
+	}
+
+	cmd.AddCommand(
+		&cobra.Command{
+			Use:     "ls",
+			Aliases: []string{"list"},
+			Short:   "List the endpoints",
+			RunE: func(cmd *cobra.Command, args []string) error {
+				if err := cli.NoArgs(cmd, args); err != nil {
+					return err
+				}
+				return runEndpointList(cloudwareCli)
+			},
+		},
+		&cobra.Command{
+			Use:   "inspect ENDPOINT",
+			Short: "Display the details of an endpoint",
+			RunE: func(cmd *cobra.Command, args []string) error {
+				if err := cli.ExactArgs(1)(cmd, args); err != nil {
+					return err
+				}
+				return runEndpointInspect(cloudwareCli, args[0])
+			},
+		},
+		newEndpointCreateCommand(cloudwareCli),
+		newEndpointUpdateCommand(cloudwareCli),
+		newAccessCommand(cloudwareCli, "endpoint", func(c *client.Client, ID int, request *client.AccessRequest) error {
+			return c.UpdateEndpointAccess(api.EndpointID(ID), request)
+		}),
+		&cobra.Command{
+			Use:     "rm ENDPOINT",
+			Aliases: []string{"remove"},
+			Short:   "Remove an endpoint",
+			RunE: func(cmd *cobra.Command, args []string) error {
+				if err := cli.ExactArgs(1)(cmd, args); err != nil {
+					return err
+				}
+				c, err := cloudwareCli.Client()
+				if err != nil {
+					return err
+				}
+				ID, err := parseID("endpoint", args[0])
+				if err != nil {
+					return err
+				}
+				return c.DeleteEndpoint(api.EndpointID(ID))
+			},
+		},
+	)
+	return cmd
+}
+
+func addEndpointFlags(cmd *cobra.Command, opts *endpointOptions) {
+	flags := cmd.Flags()
+	flags.StringVar(&opts.publicURL, "public-url", "", "URL used to access the published ports of the endpoint")
+	flags.BoolVar(&opts.tls, "tls", false, "Connect to the endpoint with TLS")
+	flags.BoolVar(&opts.tlsSkipVerify, "tls-skip-verify", false, "Do not verify the certificate of the endpoint")
+	flags.BoolVar(&opts.tlsSkipClientVerify, "tls-skip-client-verify", false, "Do not authenticate with a client certificate")
+	flags.StringVar(&opts.tlsCACert, "tls-ca-cert", "", "Path to the CA certificate of the endpoint")
+	flags.StringVar(&opts.tlsCert, "tls-cert", "", "Path to the client certificate")
+	flags.StringVar(&opts.tlsKey, "tls-key", "", "Path to the key of the client certificate")
+	flags.BoolVar(&opts.recordSessions, "record-sessions", false, "Record the terminal sessions opened on the containers of the endpoint")
+}
+
+func newEndpointCreateCommand(cloudwareCli *cloudwareCli) *cobra.Command {
+	var opts endpointOptions
+
+	cmd := &cobra.Command{
+		Use:   "create [OPTIONS] NAME URL",
+		Short: "Create an endpoint",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := cli.ExactArgs(2)(cmd, args); err != nil {
+				return err
+			}
+			c, err := cloudwareCli.Client()
+			if err != nil {
+				return err
+			}
+
+			ID, err := c.CreateEndpoint(&client.EndpointCreateRequest{
+				Name:                args[0],
+				URL:                 args[1],
+				PublicURL:           opts.publicURL,
+				TLS:                 opts.tls,
+				TLSSkipVerify:       opts.tlsSkipVerify,
+				TLSSkipClientVerify: opts.tlsSkipClientVerify,
+				RecordSessions:      opts.recordSessions,
+			})
+			if err != nil {
+				return err
+			}
+
+			err = uploadTLSFiles(c, ID, opts)
+			if err != nil {
+				return err
+			}
+			return cloudwareCli.printID(ID)
+		},
+	}
+	addEndpointFlags(cmd, &opts)
+	return cmd
+}
+
+func newEndpointUpdateCommand(cloudwareCli *cloudwareCli) *cobra.Command {
+	var opts endpointOptions
+	var name, address string
+
+	cmd := &cobra.Command{
+		Use:   "update [OPTIONS] ENDPOINT",
+		Short: "Update an endpoint",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := cli.ExactArgs(1)(cmd, args); err != nil {
+				return err
+			}
+			c, err := cloudwareCli.Client()
+			if err != nil {
+				return err
+			}
+			ID, err := parseID("endpoint", args[0])
+			if err != nil {
+				return err
+			}
+
+			// The TLS settings are always replaced by the API, the current ones are kept unless specified.
+			endpoint, err := c.Endpoint(api.EndpointID(ID))
+			if err != nil {
+				return err
+			}
+
+			flags := cmd.Flags()
+			request := &client.EndpointUpdateRequest{
+				Name:                name,
+				URL:                 address,
+				PublicURL:           opts.publicURL,
+				TLS:                 endpoint.TLSConfig.TLS,
+				TLSSkipVerify:       endpoint.TLSConfig.TLSSkipVerify,
+				TLSSkipClientVerify: endpoint.TLSConfig.TLS && endpoint.TLSConfig.TLSCertPath == "",
+			}
+			if flags.Changed("tls") {
+				request.TLS = opts.tls
+			}
+			if flags.Changed("tls-skip-verify") {
+				request.TLSSkipVerify = opts.tlsSkipVerify
+			}
+			if flags.Changed("tls-skip-client-verify") {
+				request.TLSSkipClientVerify = opts.tlsSkipClientVerify
+			}
+			if flags.Changed("record-sessions") {
+				request.RecordSessions = &opts.recordSessions
+			}
+
+			// The files are uploaded first so that the update finds them.
+			err = uploadTLSFiles(c, endpoint.ID, opts)
+			if err != nil {
+				return err
+			}
+			return c.UpdateEndpoint(endpoint.ID, request)
+		},
+	}
+	addEndpointFlags(cmd, &opts)
+	flags := cmd.Flags()
+	flags.StringVar(&name, "name", "", "New name of the endpoint")
+	flags.StringVar(&address, "url", "", "New URL of the endpoint")
+	return cmd
+}
+
+func uploadTLSFiles(c *client.Client, ID api.EndpointID, opts endpointOptions) error {
+	files := []struct {
+		certificate string
+		path        string
+	}{
+		{"ca", opts.tlsCACert},
+		{"cert", opts.tlsCert},
+		{"key", opts.tlsKey},
+	}
+
+	for _, file := range files {
+		if file.path == "" {
+			continue
+		}
+
+		f, err := os.Open(file.path)
+		if err != nil {
+			return err
+		}
+		err = c.UploadTLSFile(strconv.Itoa(int(ID)), file.certificate, f)
+		f.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func runEndpointList(cloudwareCli *cloudwareCli) error {
+	c, err := cloudwareCli.Client()
+	if err != nil {
+		return err
+	}
+
+	endpoints, err := c.Endpoints()
+	if err != nil {
+		return err
+	}
+
+	t := newTable("ID", "NAME", "URL", "PUBLIC URL", "TLS")
+	for _, endpoint := range endpoints {
+		t.append(endpoint.ID, endpoint.Name, endpoint.URL, endpoint.PublicURL, yesNo(endpoint.TLSConfig.TLS))
+	}
+	return cloudwareCli.print(endpoints, t)
+}
+
+func runEndpointInspect(cloudwareCli *cloudwareCli, arg string) error {
+	c, err := cloudwareCli.Client()
+	if err != nil {
+		return err
+	}
+	ID, err := parseID("endpoint", arg)
+	if err != nil {
+		return err
+	}
+
+	endpoint, err := c.Endpoint(api.EndpointID(ID))
+	if err != nil {
+		return err
+	}
+
+	t := newTable("FIELD", "VALUE")
+	t.append("ID", endpoint.ID)
+	t.append("Name", endpoint.Name)
+	t.append("URL", endpoint.URL)
+	t.append("Public URL", endpoint.PublicURL)
+	t.append("TLS", yesNo(endpoint.TLSConfig.TLS))
+	t.append("TLS skip verify", yesNo(endpoint.TLSConfig.TLSSkipVerify))
+	t.append("Record sessions", yesNo(endpoint.RecordSessions))
+	t.append("Authorized users", endpoint.AuthorizedUsers)
+	t.append("Authorized teams", endpoint.AuthorizedTeams)
+	return cloudwareCli.print(endpoint, t)
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	formatTable = "table"
+	formatJSON  = "json"
+	formatYAML  = "yaml"
+)
+
+func validateFormat(format string) error {
+	switch format {
+	case formatTable, formatJSON, formatYAML:
+		return nil
+	}
+	return fmt.Errorf("Invalid output format %s, expected table, json or yaml", format)
+}
+
+// table represents the rows printed when the table format is selected.
+type table struct {
+	headers []string
+	rows    [][]string
+}
+
+func newTable(headers ...string) *table {
+	return &table{headers: headers}
+}
+
+func (t *table) append(values ...interface{}) {
+	row := make([]string, 0, len(values))
+	for _, value := range values {
+		row = append(row, fmt.Sprint(value))
+	}
+	t.rows = append(t.rows, row)
+}
+
+// print writes v in the selected format, the table is used for the table format.
+func (cloudwareCli *cloudwareCli) print(v interface{}, t *table) error {
+	switch cloudwareCli.opts.format {
+	case formatJSON:
+		data, err := json.MarshalIndent(v, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(cloudwareCli.out, string(data))
+		return err
+	case formatYAML:
+		// The value goes through JSON first so that the YAML keys match the JSON ones.
+		data, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		var generic interface{}
+		err = yaml.Unmarshal(data, &generic)
+		if err != nil {
+			return err
+		}
+		data, err = yaml.Marshal(generic)
+		if err != nil {
+			return err
+		}
+		_, err = cloudwareCli.out.Write(data)
+		return err
+	}
+
+	w := tabwriter.NewWriter(cloudwareCli.out, 0, 4, 3, ' ', 0)
+	fmt.Fprintln(w, strings.Join(t.headers, "\t"))
+	for _, row := range t.rows {
+		fmt.Fprintln(w, strings.Join(row, "\t"))
+	}
+	return w.Flush()
+}
+
+// printID writes the identifier of a created entity.
+func (cloudwareCli *cloudwareCli) printID(ID interface{}) error {
+	t := newTable("ID")
+	t.append(ID)
+	return cloudwareCli.print(struct {
+		ID interface{} `json:"Id"`
+	}{ID}, t)
+}
+
+func yesNo(value bool) string {
+	if value {
+		return "yes"
+	}
+	return "no"
+}
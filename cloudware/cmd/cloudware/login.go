@@ -0,0 +1,310 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"cloudware/cloudware/cli"
+	"cloudware/cloudware/cli/config"
+	"cloudware/cloudware/client"
+	"cloudware/cloudware/pkg/term"
+)
+
+type loginOptions struct {
+	name               string
+	username           string
+	password           string
+	passwordStdin      bool
+	insecureSkipVerify bool
+}
+
+func newLoginCommand(cloudwareCli *cloudwareCli) *cobra.Command {
+	var opts loginOptions
+
+	cmd := &cobra.Command{
+		Use:   "login [OPTIONS] [URL]",
+		Short: "Log in to an instance and store the token in a context",
+		Long:  "Log in to an instance and store the token in a context. Without URL, the user logs in again to the instance of the selected context.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) > 1 {
+				return cli.ExactArgs(1)(cmd, args)
+			}
+			address := ""
+			if len(args) == 1 {
+				address = args[0]
+			}
+			return runLogin(cloudwareCli, opts, address)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVarP(&opts.username, "username", "u", "", "Username")
+	flags.StringVarP(&opts.password, "password", "p", "", "Password")
+	flags.BoolVar(&opts.passwordStdin, "password-stdin", false, "Read the password from the standard input")
+	flags.StringVar(&opts.name, "name", "", "Name of the context, defaults to the host of the instance")
+	flags.BoolVar(&opts.insecureSkipVerify, "insecure", false, "Do not verify the certificate of the instance")
+	return cmd
+}
+
+func runLogin(cloudwareCli *cloudwareCli, opts loginOptions, address string) error {
+	configFile, err := cloudwareCli.ConfigFile()
+	if err != nil {
+		return err
+	}
+
+	name := opts.name
+	if name == "" {
+		name = cloudwareCli.opts.context
+	}
+
+	context := &config.Context{}
+	if address == "" {
+		existing, err := configFile.Context(name)
+		if err != nil {
+			return err
+		}
+		*context = *existing
+		if name == "" {
+			name = configFile.CurrentContext
+		}
+	} else {
+		parsed, err := url.Parse(address)
+		if err != nil || parsed.Scheme == "" || parsed.Host == "" {
+			return fmt.Errorf("Invalid instance URL %s, expected scheme://host[:port]", address)
+		}
+		if name == "" {
+			name = parsed.Host
+		}
+		if existing, ok := configFile.Contexts[name]; ok && existing.URL == address {
+			*context = *existing
+		}
+		context.URL = address
+	}
+	if opts.insecureSkipVerify {
+		context.InsecureSkipVerify = true
+	}
+
+	if opts.username != "" {
+		context.Username = opts.username
+	}
+	reader := bufio.NewReader(cloudwareCli.in)
+	if context.Username == "" {
+		fmt.Fprint(cloudwareCli.out, "Username: ")
+		context.Username, err = readLine(reader)
+		if err != nil {
+			return err
+		}
+	}
+
+	password, err := readPassword(cloudwareCli, opts, reader)
+	if err != nil {
+		return err
+	}
+
+	c := client.NewClient(context.URL, newHTTPClient(context.InsecureSkipVerify))
+	context.Token, err = c.Authenticate(context.Username, password)
+	if err != nil {
+		return err
+	}
+
+	configFile.Contexts[name] = context
+	configFile.CurrentContext = name
+	err = configFile.Save()
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(cloudwareCli.out, "Logged in to %s as %s, context %s selected.\n", context.URL, context.Username, name)
+	return nil
+}
+
+// readPassword returns the password from the options, the standard input, or a prompt without echo
+// when the standard input is a terminal.
+func readPassword(cloudwareCli *cloudwareCli, opts loginOptions, reader *bufio.Reader) (string, error) {
+	if opts.password != "" {
+		if opts.passwordStdin {
+			return "", fmt.Errorf("--password and --password-stdin are mutually exclusive")
+		}
+		return opts.password, nil
+	}
+
+	if opts.passwordStdin {
+		data, err := ioutil.ReadAll(reader)
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimRight(string(data), "\r\n"), nil
+	}
+
+	fmt.Fprint(cloudwareCli.out, "Password: ")
+	if fd, isTerminal := term.GetFdInfo(cloudwareCli.in); isTerminal {
+		state, err := term.SaveState(fd)
+		if err != nil {
+			return "", err
+		}
+		term.DisableEcho(fd, state)
+		defer term.RestoreTerminal(fd, state)
+		defer fmt.Fprintln(cloudwareCli.out)
+	}
+	return readLine(reader)
+}
+
+func readLine(reader *bufio.Reader) (string, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil && (err != io.EOF || line == "") {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+func newLogoutCommand(cloudwareCli *cloudwareCli) *cobra.Command {
+	return &cobra.Command{
+		Use:   "logout [CONTEXT]",
+		Short: "Remove the token stored in a context, the selected context by default",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) > 1 {
+				return cli.ExactArgs(1)(cmd, args)
+			}
+
+			configFile, err := cloudwareCli.ConfigFile()
+			if err != nil {
+				return err
+			}
+
+			name := cloudwareCli.opts.context
+			if len(args) == 1 {
+				name = args[0]
+			}
+			if name == "" {
+				name = configFile.CurrentContext
+			}
+			context, err := configFile.Context(name)
+			if err != nil {
+				return err
+			}
+
+			context.Token = ""
+			err = configFile.Save()
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(cloudwareCli.out, "Logged out of %s.\n", context.URL)
+			return nil
+		},
+	}
+}
+
+func newContextCommand(cloudwareCli *cloudwareCli) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "context",
+		Short: "Manage the contexts",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return cli.NoArgs(cmd, args)
+		},
+	}
+
+	cmd.AddCommand(
+		&cobra.Command{
+			Use:     "ls",
+			Aliases: []string{"list"},
+			Short:   "List the contexts",
+			RunE: func(cmd *cobra.Command, args []string) error {
+				if err := cli.NoArgs(cmd, args); err != nil {
+					return err
+				}
+				return runContextList(cloudwareCli)
+			},
+		},
+		&cobra.Command{
+			Use:   "use CONTEXT",
+			Short: "Select the context used by the next commands",
+			RunE: func(cmd *cobra.Command, args []string) error {
+				if err := cli.ExactArgs(1)(cmd, args); err != nil {
+					return err
+				}
+
+				configFile, err := cloudwareCli.ConfigFile()
+				if err != nil {
+					return err
+				}
+				if _, err := configFile.Context(args[0]); err != nil {
+					return err
+				}
+				configFile.CurrentContext = args[0]
+				return configFile.Save()
+			},
+		},
+		&cobra.Command{
+			Use:     "rm CONTEXT",
+			Aliases: []string{"remove"},
+			Short:   "Remove a context",
+			RunE: func(cmd *cobra.Command, args []string) error {
+				if err := cli.ExactArgs(1)(cmd, args); err != nil {
+					return err
+				}
+
+				configFile, err := cloudwareCli.ConfigFile()
+				if err != nil {
+					return err
+				}
+				if _, err := configFile.Context(args[0]); err != nil {
+					return err
+				}
+				delete(configFile.Contexts, args[0])
+				if configFile.CurrentContext == args[0] {
+					configFile.CurrentContext = ""
+				}
+				return configFile.Save()
+			},
+		},
+	)
+	return cmd
+}
+
+func runContextList(cloudwareCli *cloudwareCli) error {
+	configFile, err := cloudwareCli.ConfigFile()
+	if err != nil {
+		return err
+	}
+
+	names := make([]string, 0, len(configFile.Contexts))
+	for name := range configFile.Contexts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	type contextEntry struct {
+		Name     string `json:"Name"`
+		URL      string `json:"URL"`
+		Username string `json:"Username"`
+		LoggedIn bool   `json:"LoggedIn"`
+		Current  bool   `json:"Current"`
+	}
+
+	entries := make([]contextEntry, 0, len(names))
+	t := newTable("NAME", "URL", "USERNAME", "LOGGED IN")
+	for _, name := range names {
+		context := configFile.Contexts[name]
+		entry := contextEntry{
+			Name:     name,
+			URL:      context.URL,
+			Username: context.Username,
+			LoggedIn: context.Token != "",
+			Current:  name == configFile.CurrentContext,
+		}
+		entries = append(entries, entry)
+
+		if entry.Current {
+			name += " *"
+		}
+		t.append(name, entry.URL, entry.Username, yesNo(entry.LoggedIn))
+	}
+	return cloudwareCli.print(entries, t)
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"github.com/spf13/cobra"
+
+	"cloudware/cloudware/api"
+	"cloudware/cloudware/cli"
+	"cloudware/cloudware/client"
+)
+
+type registryOptions struct {
+	name     string
+	url      string
+	username string
+	password string
+	check    bool
+}
+
+func newRegistryCommand(cloudwareCli *cloudwareCli) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "registry",
+		Short: "Manage the registries",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return cli.NoArgs(cmd, args)
+		},
+	}
+
+	cmd.AddCommand(
+		&cobra.Command{
+			Use:     "ls",
+			Aliases: []string{"list"},
+			Short:   "List the registries",
+			RunE: func(cmd *cobra.Command, args []string) error {
+				if err := cli.NoArgs(cmd, args); err != nil {
+					return err
+				}
+				return runRegistryList(cloudwareCli)
+			},
+		},
+		&cobra.Command{
+			Use:   "inspect REGISTRY",
+			Short: "Display the details of a registry",
+			RunE: func(cmd *cobra.Command, args []string) error {
+				if err := cli.ExactArgs(1)(cmd, args); err != nil {
+					return err
+				}
+				return runRegistryInspect(cloudwareCli, args[0])
+			},
+		},
+		newRegistryCreateCommand(cloudwareCli),
+		newRegistryUpdateCommand(cloudwareCli),
+		newAccessCommand(cloudwareCli, "registry", func(c *client.Client, ID int, request *client.AccessRequest) error {
+			return c.UpdateRegistryAccess(api.RegistryID(ID), request)
+		}),
+		&cobra.Command{
+			Use:     "rm REGISTRY",
+			Aliases: []string{"remove"},
+			Short:   "Remove a registry",
+			RunE: func(cmd *cobra.Command, args []string) error {
+				if err := cli.ExactArgs(1)(cmd, args); err != nil {
+					return err
+				}
+				c, err := cloudwareCli.Client()
+				if err != nil {
+					return err
+				}
+				ID, err := parseID("registry", args[0])
+				if err != nil {
+					return err
+				}
+				return c.DeleteRegistry(api.RegistryID(ID))
+			},
+		},
+	)
+	return cmd
+}
+
+func newRegistryCreateCommand(cloudwareCli *cloudwareCli) *cobra.Command {
+	var opts registryOptions
+
+	cmd := &cobra.Command{
+		Use:   "create [OPTIONS] NAME URL",
+		Short: "Create a registry",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := cli.ExactArgs(2)(cmd, args); err != nil {
+				return err
+			}
+			c, err := cloudwareCli.Client()
+			if err != nil {
+				return err
+			}
+
+			ID, err := c.CreateRegistry(&client.RegistryRequest{
+				Name:           args[0],
+				URL:            args[1],
+				Authentication: opts.username != "",
+				Username:       opts.username,
+				Password:       &opts.password,
+			}, opts.check)
+			if err != nil {
+				return err
+			}
+			return cloudwareCli.printID(ID)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVarP(&opts.username, "username", "u", "", "Username used to authenticate against the registry")
+	flags.StringVarP(&opts.password, "password", "p", "", "Password used to authenticate against the registry")
+	flags.BoolVar(&opts.check, "check", false, "Check that the registry is reachable with the credentials")
+	return cmd
+}
+
+func newRegistryUpdateCommand(cloudwareCli *cloudwareCli) *cobra.Command {
+	var opts registryOptions
+	var noAuthentication bool
+
+	cmd := &cobra.Command{
+		Use:   "update [OPTIONS] REGISTRY",
+		Short: "Update a registry",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := cli.ExactArgs(1)(cmd, args); err != nil {
+				return err
+			}
+			c, err := cloudwareCli.Client()
+			if err != nil {
+				return err
+			}
+			ID, err := parseID("registry", args[0])
+			if err != nil {
+				return err
+			}
+
+			// All the settings are replaced by the API, the current ones are kept unless specified.
+			registry, err := c.Registry(api.RegistryID(ID))
+			if err != nil {
+				return err
+			}
+
+			request := &client.RegistryRequest{
+				Name:           registry.Name,
+				URL:            registry.URL,
+				Authentication: registry.Authentication,
+				Username:       registry.Username,
+			}
+			if cmd.Flags().Changed("password") {
+				request.Password = &opts.password
+			}
+			if opts.name != "" {
+				request.Name = opts.name
+			}
+			if opts.url != "" {
+				request.URL = opts.url
+			}
+			if opts.username != "" {
+				request.Authentication = true
+				request.Username = opts.username
+			}
+			if noAuthentication {
+				request.Authentication = false
+			}
+			return c.UpdateRegistry(registry.ID, request, opts.check)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&opts.name, "name", "", "New name of the registry")
+	flags.StringVar(&opts.url, "url", "", "New URL of the registry")
+	flags.StringVarP(&opts.username, "username", "u", "", "Username used to authenticate against the registry")
+	flags.StringVarP(&opts.password, "password", "p", "", "Password used to authenticate against the registry")
+	flags.BoolVar(&noAuthentication, "no-auth", false, "Access the registry anonymously")
+	flags.BoolVar(&opts.check, "check", false, "Check that the registry is reachable with the credentials")
+	return cmd
+}
+
+func runRegistryList(cloudwareCli *cloudwareCli) error {
+	c, err := cloudwareCli.Client()
+	if err != nil {
+		return err
+	}
+
+	registries, err := c.Registries()
+	if err != nil {
+		return err
+	}
+
+	t := newTable("ID", "NAME", "URL", "AUTHENTICATION")
+	for _, registry := range registries {
+		t.append(registry.ID, registry.Name, registry.URL, yesNo(registry.Authentication))
+	}
+	return cloudwareCli.print(registries, t)
+}
+
+func runRegistryInspect(cloudwareCli *cloudwareCli, arg string) error {
+	c, err := cloudwareCli.Client()
+	if err != nil {
+		return err
+	}
+	ID, err := parseID("registry", arg)
+	if err != nil {
+		return err
+	}
+
+	registry, err := c.Registry(api.RegistryID(ID))
+	if err != nil {
+		return err
+	}
+
+	t := newTable("FIELD", "VALUE")
+	t.append("ID", registry.ID)
+	t.append("Name", registry.Name)
+	t.append("URL", registry.URL)
+	t.append("Authentication", yesNo(registry.Authentication))
+	t.append("Username", registry.Username)
+	t.append("Authorized users", registry.AuthorizedUsers)
+	t.append("Authorized teams", registry.AuthorizedTeams)
+	return cloudwareCli.print(registry, t)
+}
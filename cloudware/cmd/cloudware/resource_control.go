@@ -0,0 +1,135 @@
+package main
+
+import (
+	"github.com/spf13/cobra"
+
+	"cloudware/cloudware/api"
+	"cloudware/cloudware/cli"
+	"cloudware/cloudware/client"
+)
+
+type resourceControlOptions struct {
+	resourceType       string
+	administratorsOnly bool
+	users              []string
+	teams              []string
+	subResources       []string
+}
+
+func newResourceControlCommand(cloudwareCli *cloudwareCli) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "resource-control",
+		Aliases: []string{"rc"},
+		Short:   "Manage the access control of the Docker resources",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return cli.NoArgs(cmd, args)
+		},
+	}
+
+	cmd.AddCommand(
+		newResourceControlCreateCommand(cloudwareCli),
+		newResourceControlUpdateCommand(cloudwareCli),
+		&cobra.Command{
+			Use:     "rm RESOURCE-CONTROL",
+			Aliases: []string{"remove"},
+			Short:   "Remove the access control of a resource",
+			RunE: func(cmd *cobra.Command, args []string) error {
+				if err := cli.ExactArgs(1)(cmd, args); err != nil {
+					return err
+				}
+				c, err := cloudwareCli.Client()
+				if err != nil {
+					return err
+				}
+				ID, err := parseID("resource control", args[0])
+				if err != nil {
+					return err
+				}
+				return c.DeleteResourceControl(api.ResourceControlID(ID))
+			},
+		},
+	)
+	return cmd
+}
+
+func addResourceControlFlags(cmd *cobra.Command, opts *resourceControlOptions) {
+	flags := cmd.Flags()
+	flags.BoolVar(&opts.administratorsOnly, "admin-only", false, "Restrict the access to the administrators")
+	flags.StringSliceVar(&opts.users, "user", nil, "Name or identifier of a user allowed to access the resource")
+	flags.StringSliceVar(&opts.teams, "team", nil, "Name or identifier of a team allowed to access the resource")
+}
+
+func newResourceControlCreateCommand(cloudwareCli *cloudwareCli) *cobra.Command {
+	var opts resourceControlOptions
+
+	cmd := &cobra.Command{
+		Use:   "create [OPTIONS] RESOURCE",
+		Short: "Restrict the access to a Docker resource",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := cli.ExactArgs(1)(cmd, args); err != nil {
+				return err
+			}
+			c, err := cloudwareCli.Client()
+			if err != nil {
+				return err
+			}
+
+			request := &client.ResourceControlCreateRequest{
+				ResourceID:         args[0],
+				Type:               opts.resourceType,
+				AdministratorsOnly: opts.administratorsOnly,
+				SubResourceIDs:     opts.subResources,
+			}
+			request.Users, err = resolveUsers(c, opts.users)
+			if err != nil {
+				return err
+			}
+			request.Teams, err = resolveTeams(c, opts.teams)
+			if err != nil {
+				return err
+			}
+			return c.CreateResourceControl(request)
+		},
+	}
+
+	addResourceControlFlags(cmd, &opts)
+	flags := cmd.Flags()
+	flags.StringVar(&opts.resourceType, "type", "container", "Type of the resource: container, service, volume, network, secret, stack or config")
+	flags.StringSliceVar(&opts.subResources, "sub-resource", nil, "Identifier of a resource inheriting the access control, such as a task of a service")
+	return cmd
+}
+
+func newResourceControlUpdateCommand(cloudwareCli *cloudwareCli) *cobra.Command {
+	var opts resourceControlOptions
+
+	cmd := &cobra.Command{
+		Use:   "update [OPTIONS] RESOURCE-CONTROL",
+		Short: "Replace the users and teams allowed to access a resource",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := cli.ExactArgs(1)(cmd, args); err != nil {
+				return err
+			}
+			c, err := cloudwareCli.Client()
+			if err != nil {
+				return err
+			}
+			ID, err := parseID("resource control", args[0])
+			if err != nil {
+				return err
+			}
+
+			request := &client.ResourceControlUpdateRequest{AdministratorsOnly: opts.administratorsOnly}
+			request.Users, err = resolveUsers(c, opts.users)
+			if err != nil {
+				return err
+			}
+			request.Teams, err = resolveTeams(c, opts.teams)
+			if err != nil {
+				return err
+			}
+			return c.UpdateResourceControl(api.ResourceControlID(ID), request)
+		},
+	}
+	addResourceControlFlags(cmd, &opts)
+	return cmd
+}
@@ -0,0 +1,395 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"cloudware/cloudware/api"
+	"cloudware/cloudware/cli"
+	"cloudware/cloudware/client"
+)
+
+type stackOptions struct {
+	endpoint    int
+	composeFile string
+	gitURL      string
+	gitPath     string
+	swarmID     string
+	env         []string
+}
+
+type stackLogsOptions struct {
+	endpoint   int
+	since      string
+	until      string
+	tail       string
+	follow     bool
+	grep       string
+	timestamps bool
+}
+
+func newStackCommand(cloudwareCli *cloudwareCli) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "stack",
+		Short: "Manage the stacks of an endpoint",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return cli.NoArgs(cmd, args)
+		},
+	}
+
+	cmd.AddCommand(
+		newStackListCommand(cloudwareCli),
+		newStackInspectCommand(cloudwareCli),
+		newStackDeployCommand(cloudwareCli),
+		newStackUpdateCommand(cloudwareCli),
+		newStackRemoveCommand(cloudwareCli),
+		newStackLogsCommand(cloudwareCli),
+	)
+	return cmd
+}
+
+func addEndpointFlag(cmd *cobra.Command, endpoint *int) {
+	cmd.Flags().IntVarP(endpoint, "endpoint", "e", 0, "Identifier of the endpoint")
+}
+
+func requireEndpoint(endpoint int) (api.EndpointID, error) {
+	if endpoint == 0 {
+		return 0, fmt.Errorf("An endpoint must be specified with --endpoint")
+	}
+	return api.EndpointID(endpoint), nil
+}
+
+// parseEnv converts KEY=VALUE arguments to environment variables of a stack.
+func parseEnv(env []string) ([]api.Pair, error) {
+	pairs := make([]api.Pair, 0, len(env))
+	for _, variable := range env {
+		parts := strings.SplitN(variable, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, fmt.Errorf("Invalid environment variable %s, expected KEY=VALUE", variable)
+		}
+		pairs = append(pairs, api.Pair{Name: parts[0], Value: parts[1]})
+	}
+	return pairs, nil
+}
+
+func newStackListCommand(cloudwareCli *cloudwareCli) *cobra.Command {
+	var endpoint int
+
+	cmd := &cobra.Command{
+		Use:     "ls [OPTIONS]",
+		Aliases: []string{"list"},
+		Short:   "List the stacks of an endpoint",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := cli.NoArgs(cmd, args); err != nil {
+				return err
+			}
+			endpointID, err := requireEndpoint(endpoint)
+			if err != nil {
+				return err
+			}
+			c, err := cloudwareCli.Client()
+			if err != nil {
+				return err
+			}
+
+			stacks, err := c.Stacks(endpointID)
+			if err != nil {
+				return err
+			}
+
+			t := newTable("ID", "NAME", "SWARM ID", "ENTRY POINT")
+			for _, stack := range stacks {
+				t.append(stack.ID, stack.Name, stack.SwarmID, stack.EntryPoint)
+			}
+			return cloudwareCli.print(stacks, t)
+		},
+	}
+	addEndpointFlag(cmd, &endpoint)
+	return cmd
+}
+
+func newStackInspectCommand(cloudwareCli *cloudwareCli) *cobra.Command {
+	var endpoint int
+	var file bool
+
+	cmd := &cobra.Command{
+		Use:   "inspect [OPTIONS] STACK",
+		Short: "Display the details or the stack file of a stack",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := cli.ExactArgs(1)(cmd, args); err != nil {
+				return err
+			}
+			endpointID, err := requireEndpoint(endpoint)
+			if err != nil {
+				return err
+			}
+			c, err := cloudwareCli.Client()
+			if err != nil {
+				return err
+			}
+
+			if file {
+				content, err := c.StackFile(endpointID, api.StackID(args[0]))
+				if err != nil {
+					return err
+				}
+				_, err = fmt.Fprint(cloudwareCli.out, content)
+				return err
+			}
+
+			stack, err := c.Stack(endpointID, api.StackID(args[0]))
+			if err != nil {
+				return err
+			}
+
+			t := newTable("FIELD", "VALUE")
+			t.append("ID", stack.ID)
+			t.append("Name", stack.Name)
+			t.append("Swarm ID", stack.SwarmID)
+			t.append("Entry point", stack.EntryPoint)
+			for _, pair := range stack.Env {
+				t.append("Env", pair.Name+"="+pair.Value)
+			}
+			return cloudwareCli.print(stack, t)
+		},
+	}
+	addEndpointFlag(cmd, &endpoint)
+	cmd.Flags().BoolVar(&file, "file", false, "Print the content of the stack file")
+	return cmd
+}
+
+func newStackDeployCommand(cloudwareCli *cloudwareCli) *cobra.Command {
+	var opts stackOptions
+
+	cmd := &cobra.Command{
+		Use:   "deploy [OPTIONS] NAME",
+		Short: "Deploy a new stack from a Compose file or a git repository",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := cli.ExactArgs(1)(cmd, args); err != nil {
+				return err
+			}
+			return runStackDeploy(cloudwareCli, opts, args[0])
+		},
+	}
+
+	addEndpointFlag(cmd, &opts.endpoint)
+	flags := cmd.Flags()
+	flags.StringVarP(&opts.composeFile, "compose-file", "c", "", "Path to the Compose file, - to read it from the standard input")
+	flags.StringVar(&opts.gitURL, "git-url", "", "URL of the git repository containing the Compose file")
+	flags.StringVar(&opts.gitPath, "git-path", "docker-compose.yml", "Path of the Compose file in the git repository")
+	flags.StringVar(&opts.swarmID, "swarm-id", "", "Identifier of the swarm cluster, defaults to the cluster of the endpoint")
+	flags.StringSliceVar(&opts.env, "env", nil, "Environment variable of the stack, as KEY=VALUE")
+	return cmd
+}
+
+func runStackDeploy(cloudwareCli *cloudwareCli, opts stackOptions, name string) error {
+	if (opts.composeFile == "") == (opts.gitURL == "") {
+		return fmt.Errorf("Either --compose-file or --git-url must be specified")
+	}
+	endpointID, err := requireEndpoint(opts.endpoint)
+	if err != nil {
+		return err
+	}
+	env, err := parseEnv(opts.env)
+	if err != nil {
+		return err
+	}
+
+	request := &client.StackCreateRequest{
+		Name:    name,
+		SwarmID: opts.swarmID,
+		Env:     env,
+	}
+	if opts.composeFile != "" {
+		request.StackFileContent, err = readComposeFile(cloudwareCli, opts.composeFile)
+		if err != nil {
+			return err
+		}
+	} else {
+		request.GitRepository = opts.gitURL
+		request.PathInRepository = opts.gitPath
+	}
+
+	c, err := cloudwareCli.Client()
+	if err != nil {
+		return err
+	}
+	if request.SwarmID == "" {
+		request.SwarmID, err = c.SwarmID(endpointID)
+		if err != nil {
+			return err
+		}
+	}
+
+	ID, err := c.CreateStack(endpointID, request)
+	if err != nil {
+		return err
+	}
+	return cloudwareCli.printID(ID)
+}
+
+func readComposeFile(cloudwareCli *cloudwareCli, path string) (string, error) {
+	var data []byte
+	var err error
+	if path == "-" {
+		data, err = ioutil.ReadAll(cloudwareCli.in)
+	} else {
+		data, err = ioutil.ReadFile(path)
+	}
+	return string(data), err
+}
+
+func newStackUpdateCommand(cloudwareCli *cloudwareCli) *cobra.Command {
+	var opts stackOptions
+
+	cmd := &cobra.Command{
+		Use:   "update [OPTIONS] STACK",
+		Short: "Redeploy a stack with a new Compose file or environment",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := cli.ExactArgs(1)(cmd, args); err != nil {
+				return err
+			}
+			endpointID, err := requireEndpoint(opts.endpoint)
+			if err != nil {
+				return err
+			}
+			c, err := cloudwareCli.Client()
+			if err != nil {
+				return err
+			}
+
+			// The stack file and the environment are both replaced, the current ones are kept unless specified.
+			ID := api.StackID(args[0])
+			stack, err := c.Stack(endpointID, ID)
+			if err != nil {
+				return err
+			}
+			// The values of the variables are not sent back by the API, the current ones are kept
+			request := &client.StackUpdateRequest{Env: make([]client.StackEnvVariable, len(stack.Env))}
+			for idx, pair := range stack.Env {
+				request.Env[idx] = client.StackEnvVariable{Name: pair.Name}
+			}
+
+			if opts.composeFile != "" {
+				request.StackFileContent, err = readComposeFile(cloudwareCli, opts.composeFile)
+			} else {
+				request.StackFileContent, err = c.StackFile(endpointID, ID)
+			}
+			if err != nil {
+				return err
+			}
+
+			if cmd.Flags().Changed("env") {
+				env, err := parseEnv(opts.env)
+				if err != nil {
+					return err
+				}
+				request.Env = make([]client.StackEnvVariable, len(env))
+				for idx := range env {
+					request.Env[idx] = client.StackEnvVariable{Name: env[idx].Name, Value: &env[idx].Value}
+				}
+			}
+			return c.UpdateStack(endpointID, ID, request)
+		},
+	}
+
+	addEndpointFlag(cmd, &opts.endpoint)
+	flags := cmd.Flags()
+	flags.StringVarP(&opts.composeFile, "compose-file", "c", "", "Path to the new Compose file, - to read it from the standard input")
+	flags.StringSliceVar(&opts.env, "env", nil, "Environment variable of the stack, as KEY=VALUE, replacing the current ones")
+	return cmd
+}
+
+func newStackRemoveCommand(cloudwareCli *cloudwareCli) *cobra.Command {
+	var endpoint int
+
+	cmd := &cobra.Command{
+		Use:     "rm [OPTIONS] STACK",
+		Aliases: []string{"remove"},
+		Short:   "Remove a stack",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := cli.ExactArgs(1)(cmd, args); err != nil {
+				return err
+			}
+			endpointID, err := requireEndpoint(endpoint)
+			if err != nil {
+				return err
+			}
+			c, err := cloudwareCli.Client()
+			if err != nil {
+				return err
+			}
+			return c.DeleteStack(endpointID, api.StackID(args[0]))
+		},
+	}
+	addEndpointFlag(cmd, &endpoint)
+	return cmd
+}
+
+func newStackLogsCommand(cloudwareCli *cloudwareCli) *cobra.Command {
+	var opts stackLogsOptions
+
+	cmd := &cobra.Command{
+		Use:   "logs [OPTIONS] STACK",
+		Short: "Fetch the logs of the containers of a stack",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := cli.ExactArgs(1)(cmd, args); err != nil {
+				return err
+			}
+			return runStackLogs(cloudwareCli, opts, api.StackID(args[0]))
+		},
+	}
+
+	addEndpointFlag(cmd, &opts.endpoint)
+	flags := cmd.Flags()
+	flags.StringVar(&opts.since, "since", "", "Show the logs since a timestamp or a relative duration")
+	flags.StringVar(&opts.until, "until", "", "Show the logs before a timestamp or a relative duration")
+	flags.StringVar(&opts.tail, "tail", "all", "Number of lines to show from the end of the logs of each container")
+	flags.BoolVarP(&opts.follow, "follow", "f", false, "Follow the log output")
+	flags.StringVar(&opts.grep, "grep", "", "Only show the lines matching a regular expression")
+	flags.BoolVarP(&opts.timestamps, "timestamps", "t", false, "Show the timestamps")
+	return cmd
+}
+
+func runStackLogs(cloudwareCli *cloudwareCli, opts stackLogsOptions, ID api.StackID) error {
+	endpointID, err := requireEndpoint(opts.endpoint)
+	if err != nil {
+		return err
+	}
+	c, err := cloudwareCli.Client()
+	if err != nil {
+		return err
+	}
+
+	// The containers of the stack are selected by the name of the stack.
+	stack, err := c.Stack(endpointID, ID)
+	if err != nil {
+		return err
+	}
+
+	options := &client.LogOptions{
+		Stack:  stack.Name,
+		Since:  opts.since,
+		Until:  opts.until,
+		Tail:   opts.tail,
+		Follow: opts.follow,
+		Grep:   opts.grep,
+	}
+
+	return c.Logs(endpointID, options, func(entry *client.LogEntry) error {
+		if cloudwareCli.opts.format != formatTable {
+			return cloudwareCli.print(entry, nil)
+		}
+
+		prefix := entry.ContainerName + " | "
+		if opts.timestamps {
+			prefix += entry.Timestamp.Format(time.RFC3339Nano) + " "
+		}
+		_, err := fmt.Fprintln(cloudwareCli.out, prefix+strings.TrimRight(entry.Message, "\n"))
+		return err
+	})
+}
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"cloudware/cloudware/api"
+	"cloudware/cloudware/cli"
+	"cloudware/cloudware/client"
+)
+
+func newTeamCommand(cloudwareCli *cloudwareCli) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "team",
+		Short: "Manage the teams",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return cli.NoArgs(cmd, args)
+		},
+	}
+
+	cmd.AddCommand(
+		&cobra.Command{
+			Use:     "ls",
+			Aliases: []string{"list"},
+			Short:   "List the teams",
+			RunE: func(cmd *cobra.Command, args []string) error {
+				if err := cli.NoArgs(cmd, args); err != nil {
+					return err
+				}
+				return runTeamList(cloudwareCli)
+			},
+		},
+		&cobra.Command{
+			Use:   "create NAME",
+			Short: "Create a team",
+			RunE: func(cmd *cobra.Command, args []string) error {
+				if err := cli.ExactArgs(1)(cmd, args); err != nil {
+					return err
+				}
+				c, err := cloudwareCli.Client()
+				if err != nil {
+					return err
+				}
+				ID, err := c.CreateTeam(args[0])
+				if err != nil {
+					return err
+				}
+				return cloudwareCli.printID(ID)
+			},
+		},
+		&cobra.Command{
+			Use:   "rename TEAM NAME",
+			Short: "Rename a team",
+			RunE: func(cmd *cobra.Command, args []string) error {
+				if err := cli.ExactArgs(2)(cmd, args); err != nil {
+					return err
+				}
+				c, err := cloudwareCli.Client()
+				if err != nil {
+					return err
+				}
+				IDs, err := resolveTeams(c, args[:1])
+				if err != nil {
+					return err
+				}
+				return c.UpdateTeam(IDs[0], args[1])
+			},
+		},
+		&cobra.Command{
+			Use:     "rm TEAM",
+			Aliases: []string{"remove"},
+			Short:   "Remove a team and its memberships",
+			RunE: func(cmd *cobra.Command, args []string) error {
+				if err := cli.ExactArgs(1)(cmd, args); err != nil {
+					return err
+				}
+				c, err := cloudwareCli.Client()
+				if err != nil {
+					return err
+				}
+				IDs, err := resolveTeams(c, args)
+				if err != nil {
+					return err
+				}
+				return c.DeleteTeam(IDs[0])
+			},
+		},
+		newTeamMemberCommand(cloudwareCli),
+	)
+	return cmd
+}
+
+func newTeamMemberCommand(cloudwareCli *cloudwareCli) *cobra.Command {
+	var leader bool
+
+	cmd := &cobra.Command{
+		Use:   "member",
+		Short: "Manage the members of the teams",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return cli.NoArgs(cmd, args)
+		},
+	}
+
+	add := &cobra.Command{
+		Use:   "add [OPTIONS] TEAM USER",
+		Short: "Add a user to a team",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := cli.ExactArgs(2)(cmd, args); err != nil {
+				return err
+			}
+			c, err := cloudwareCli.Client()
+			if err != nil {
+				return err
+			}
+			teamIDs, err := resolveTeams(c, args[:1])
+			if err != nil {
+				return err
+			}
+			userIDs, err := resolveUsers(c, args[1:])
+			if err != nil {
+				return err
+			}
+
+			request := &client.TeamMembershipRequest{UserID: userIDs[0], TeamID: teamIDs[0], Role: api.TeamMember}
+			if leader {
+				request.Role = api.TeamLeader
+			}
+			ID, err := c.CreateTeamMembership(request)
+			if err != nil {
+				return err
+			}
+			return cloudwareCli.printID(ID)
+		},
+	}
+	add.Flags().BoolVar(&leader, "leader", false, "Make the user a leader of the team")
+
+	cmd.AddCommand(
+		&cobra.Command{
+			Use:     "ls TEAM",
+			Aliases: []string{"list"},
+			Short:   "List the members of a team",
+			RunE: func(cmd *cobra.Command, args []string) error {
+				if err := cli.ExactArgs(1)(cmd, args); err != nil {
+					return err
+				}
+				return runTeamMemberList(cloudwareCli, args[0])
+			},
+		},
+		add,
+		&cobra.Command{
+			Use:     "rm TEAM USER",
+			Aliases: []string{"remove"},
+			Short:   "Remove a user from a team",
+			RunE: func(cmd *cobra.Command, args []string) error {
+				if err := cli.ExactArgs(2)(cmd, args); err != nil {
+					return err
+				}
+				c, err := cloudwareCli.Client()
+				if err != nil {
+					return err
+				}
+				teamIDs, err := resolveTeams(c, args[:1])
+				if err != nil {
+					return err
+				}
+				userIDs, err := resolveUsers(c, args[1:])
+				if err != nil {
+					return err
+				}
+
+				memberships, err := c.TeamMemberships(teamIDs[0])
+				if err != nil {
+					return err
+				}
+				for _, membership := range memberships {
+					if membership.UserID == userIDs[0] {
+						return c.DeleteTeamMembership(membership.ID)
+					}
+				}
+				return fmt.Errorf("User %s is not a member of team %s", args[1], args[0])
+			},
+		},
+	)
+	return cmd
+}
+
+func membershipRoleName(role api.MembershipRole) string {
+	if role == api.TeamLeader {
+		return "leader"
+	}
+	return "member"
+}
+
+func runTeamList(cloudwareCli *cloudwareCli) error {
+	c, err := cloudwareCli.Client()
+	if err != nil {
+		return err
+	}
+
+	teams, err := c.Teams()
+	if err != nil {
+		return err
+	}
+
+	t := newTable("ID", "NAME")
+	for _, team := range teams {
+		t.append(team.ID, team.Name)
+	}
+	return cloudwareCli.print(teams, t)
+}
+
+func runTeamMemberList(cloudwareCli *cloudwareCli, arg string) error {
+	c, err := cloudwareCli.Client()
+	if err != nil {
+		return err
+	}
+	IDs, err := resolveTeams(c, []string{arg})
+	if err != nil {
+		return err
+	}
+
+	memberships, err := c.TeamMemberships(IDs[0])
+	if err != nil {
+		return err
+	}
+	users, err := c.Users()
+	if err != nil {
+		return err
+	}
+	usernames := make(map[api.UserID]string)
+	for _, user := range users {
+		usernames[user.ID] = user.Username
+	}
+
+	t := newTable("ID", "USER ID", "USERNAME", "ROLE")
+	for _, membership := range memberships {
+		t.append(membership.ID, membership.UserID, usernames[membership.UserID], membershipRoleName(membership.Role))
+	}
+	return cloudwareCli.print(memberships, t)
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"cloudware/cloudware/api"
+	"cloudware/cloudware/cli"
+	"cloudware/cloudware/client"
+)
+
+type userOptions struct {
+	password string
+	role     string
+}
+
+func newUserCommand(cloudwareCli *cloudwareCli) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "user",
+		Short: "Manage the users",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return cli.NoArgs(cmd, args)
+		},
+	}
+
+	cmd.AddCommand(
+		&cobra.Command{
+			Use:     "ls",
+			Aliases: []string{"list"},
+			Short:   "List the users",
+			RunE: func(cmd *cobra.Command, args []string) error {
+				if err := cli.NoArgs(cmd, args); err != nil {
+					return err
+				}
+				return runUserList(cloudwareCli)
+			},
+		},
+		&cobra.Command{
+			Use:   "inspect USER",
+			Short: "Display the details of a user",
+			RunE: func(cmd *cobra.Command, args []string) error {
+				if err := cli.ExactArgs(1)(cmd, args); err != nil {
+					return err
+				}
+				return runUserInspect(cloudwareCli, args[0])
+			},
+		},
+		newUserCreateCommand(cloudwareCli),
+		newUserUpdateCommand(cloudwareCli),
+		&cobra.Command{
+			Use:     "rm USER",
+			Aliases: []string{"remove"},
+			Short:   "Remove a user",
+			RunE: func(cmd *cobra.Command, args []string) error {
+				if err := cli.ExactArgs(1)(cmd, args); err != nil {
+					return err
+				}
+				c, err := cloudwareCli.Client()
+				if err != nil {
+					return err
+				}
+				IDs, err := resolveUsers(c, args)
+				if err != nil {
+					return err
+				}
+				return c.DeleteUser(IDs[0])
+			},
+		},
+	)
+	return cmd
+}
+
+func newUserCreateCommand(cloudwareCli *cloudwareCli) *cobra.Command {
+	var opts userOptions
+
+	cmd := &cobra.Command{
+		Use:   "create [OPTIONS] USERNAME",
+		Short: "Create a user",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := cli.ExactArgs(1)(cmd, args); err != nil {
+				return err
+			}
+			role, err := parseUserRole(opts.role)
+			if err != nil {
+				return err
+			}
+			c, err := cloudwareCli.Client()
+			if err != nil {
+				return err
+			}
+
+			ID, err := c.CreateUser(&client.UserCreateRequest{
+				Username: args[0],
+				Password: opts.password,
+				Role:     role,
+			})
+			if err != nil {
+				return err
+			}
+			return cloudwareCli.printID(ID)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVarP(&opts.password, "password", "p", "", "Password of the user, it can be omitted when the users authenticate with LDAP")
+	flags.StringVar(&opts.role, "role", "user", "Role of the user: administrator or user")
+	return cmd
+}
+
+func newUserUpdateCommand(cloudwareCli *cloudwareCli) *cobra.Command {
+	var opts userOptions
+
+	cmd := &cobra.Command{
+		Use:   "update [OPTIONS] USER",
+		Short: "Update the password or the role of a user",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := cli.ExactArgs(1)(cmd, args); err != nil {
+				return err
+			}
+			request := &client.UserUpdateRequest{Password: opts.password}
+			if opts.role != "" {
+				role, err := parseUserRole(opts.role)
+				if err != nil {
+					return err
+				}
+				request.Role = role
+			}
+
+			c, err := cloudwareCli.Client()
+			if err != nil {
+				return err
+			}
+			IDs, err := resolveUsers(c, args)
+			if err != nil {
+				return err
+			}
+			return c.UpdateUser(IDs[0], request)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVarP(&opts.password, "password", "p", "", "New password of the user")
+	flags.StringVar(&opts.role, "role", "", "New role of the user: administrator or user")
+	return cmd
+}
+
+func parseUserRole(role string) (api.UserRole, error) {
+	switch role {
+	case "administrator", "admin":
+		return api.AdministratorRole, nil
+	case "user":
+		return api.StandardUserRole, nil
+	}
+	return 0, fmt.Errorf("Invalid role %s, expected administrator or user", role)
+}
+
+func userRoleName(role api.UserRole) string {
+	if role == api.AdministratorRole {
+		return "administrator"
+	}
+	return "user"
+}
+
+func runUserList(cloudwareCli *cloudwareCli) error {
+	c, err := cloudwareCli.Client()
+	if err != nil {
+		return err
+	}
+
+	users, err := c.Users()
+	if err != nil {
+		return err
+	}
+
+	t := newTable("ID", "USERNAME", "ROLE")
+	for _, user := range users {
+		t.append(user.ID, user.Username, userRoleName(user.Role))
+	}
+	return cloudwareCli.print(users, t)
+}
+
+func runUserInspect(cloudwareCli *cloudwareCli, arg string) error {
+	c, err := cloudwareCli.Client()
+	if err != nil {
+		return err
+	}
+	IDs, err := resolveUsers(c, []string{arg})
+	if err != nil {
+		return err
+	}
+
+	user, err := c.User(IDs[0])
+	if err != nil {
+		return err
+	}
+	memberships, err := c.UserMemberships(user.ID)
+	if err != nil {
+		return err
+	}
+
+	t := newTable("FIELD", "VALUE")
+	t.append("ID", user.ID)
+	t.append("Username", user.Username)
+	t.append("Role", userRoleName(user.Role))
+	for _, membership := range memberships {
+		t.append("Team", fmt.Sprintf("%d (%s)", membership.TeamID, membershipRoleName(membership.Role)))
+	}
+	return cloudwareCli.print(struct {
+		*api.User
+		Memberships []api.TeamMembership `json:"Memberships"`
+	}{user, memberships}, t)
+}
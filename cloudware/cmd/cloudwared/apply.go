@@ -0,0 +1,179 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"cloudware/cloudware/api"
+	"cloudware/cloudware/api/apply"
+	"cloudware/cloudware/api/crypto"
+	"cloudware/cloudware/api/file"
+	"cloudware/cloudware/bolt"
+	"cloudware/cloudware/cli"
+)
+
+type applyOptions struct {
+	data              string
+	file              string
+	prune             bool
+	dryRun            bool
+	encryptionKeyFile string
+}
+
+func newApplyCommand() *cobra.Command {
+	var opts applyOptions
+
+	cmd := &cobra.Command{
+		Use:   "apply [OPTIONS] -f FILE",
+		Short: "Reconcile the users, teams, endpoints, registries and settings of a stopped instance with a configuration document",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := cli.NoArgs(cmd, args); err != nil {
+				return err
+			}
+			return runApply(opts, os.Stdin, cmd.OutOrStdout())
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVarP(&opts.file, "file", "f", "", "Path to the YAML or JSON configuration document, - to read it from the standard input")
+	flags.StringVar(&opts.data, "data", defaultRestoreDataPath, "Path to the data directory of the instance")
+	flags.BoolVar(&opts.prune, "prune", false, "Delete the entities of the specified sections which are not listed in the document")
+	flags.BoolVar(&opts.dryRun, "dry-run", false, "Report the planned changes without applying them")
+	flags.StringVar(&opts.encryptionKeyFile, "encryption-key-file", "", "Path to the file containing the master key used to encrypt the stored secrets, defaults to the CLOUDWARE_ENCRYPTION_KEY environment variable.")
+	return cmd
+}
+
+func runApply(opts applyOptions, in io.Reader, out io.Writer) error {
+	if opts.file == "" {
+		return fmt.Errorf("A configuration document must be specified with --file")
+	}
+
+	var data []byte
+	var err error
+	if opts.file == "-" {
+		data, err = ioutil.ReadAll(in)
+	} else {
+		data, err = ioutil.ReadFile(opts.file)
+	}
+	if err != nil {
+		return err
+	}
+
+	document, err := apply.Decode(data)
+	if err != nil {
+		return err
+	}
+
+	var encryptionService api.EncryptionService
+	key, err := crypto.LoadEncryptionKey(opts.encryptionKeyFile, crypto.EncryptionKeyEnvVar)
+	if err != nil {
+		return err
+	}
+	if key != nil {
+		encryptionService, err = crypto.NewEncryptionService(key, nil)
+		if err != nil {
+			return err
+		}
+	}
+
+	fileService, err := file.NewService(opts.data, "")
+	if err != nil {
+		return err
+	}
+
+	// Opening the database fails when it is locked by a running instance.
+	store, err := bolt.NewStore(opts.data)
+	if err != nil {
+		return err
+	}
+	store.EncryptionService = encryptionService
+
+	// The document is reconciled with the current data model, the dry run leaves the database untouched
+	// and requires it to be migrated already.
+	if opts.dryRun {
+		err = store.OpenReadOnly()
+	} else {
+		err = store.Open()
+	}
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	if opts.dryRun {
+		migrator, err := store.Migrator()
+		if err != nil {
+			return err
+		}
+		if len(migrator.Plan()) > 0 {
+			return fmt.Errorf("The database is at version %d and must be migrated to version %d first, run the migrate command", migrator.CurrentDBVersion, api.DBVersion)
+		}
+	} else {
+		err = store.MigrateData()
+		if err != nil {
+			return err
+		}
+	}
+
+	service := &apply.Service{
+		UserService:           store.UserService,
+		TeamService:           store.TeamService,
+		TeamMembershipService: store.TeamMembershipService,
+		EndpointService:       store.EndpointService,
+		RegistryService:       store.RegistryService,
+		SettingsService:       store.SettingsService,
+		CryptoService:         &crypto.Service{},
+		FileService:           fileService,
+	}
+
+	var plan *apply.Plan
+	if opts.dryRun {
+		plan, err = service.Plan(document, opts.prune)
+	} else {
+		plan, err = service.Apply(document, opts.prune)
+	}
+	if plan != nil {
+		printPlan(plan, out)
+	}
+	if err != nil {
+		return err
+	}
+
+	if !opts.dryRun && !plan.Empty() {
+		logrus.Infof("Configuration %s applied to %s", opts.file, opts.data)
+	}
+	return nil
+}
+
+// printPlan writes the changes of a plan, prefixed like a diff.
+func printPlan(plan *apply.Plan, out io.Writer) {
+	if plan.Empty() {
+		fmt.Fprintln(out, "The instance matches the configuration.")
+		return
+	}
+
+	counts := make(map[apply.Action]int)
+	for _, change := range plan.Changes {
+		prefix := "~"
+		switch change.Action {
+		case apply.ActionCreate:
+			prefix = "+"
+		case apply.ActionDelete:
+			prefix = "-"
+		}
+
+		fmt.Fprintf(out, "%s %s %s\n", prefix, change.Kind, change.Name)
+		for _, line := range change.Diff {
+			fmt.Fprintf(out, "    %s\n", line)
+		}
+		counts[change.Action]++
+	}
+
+	fmt.Fprintf(out, "%d to create, %d to update, %d to delete.\n",
+		counts[apply.ActionCreate], counts[apply.ActionUpdate], counts[apply.ActionDelete])
+}
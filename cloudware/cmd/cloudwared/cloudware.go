@@ -27,8 +27,13 @@ func newDaemonCommand() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:           "cloudward [OPTIONS]",
 		Short:         "A management platform for containers.",
+		SilenceUsage:  true,
+		SilenceErrors: true,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			opts.flags = cmd.Flags()
+			if err := apiCli.ApplyEnvironment(opts.flags); err != nil {
+				return err
+			}
 			return runDaemon(opts)
 		},
 	}
@@ -36,8 +41,17 @@ func newDaemonCommand() *cobra.Command {
 
 	flags := cmd.Flags()
 	flags.BoolVarP(&opts.version, "version", "v", false, "Print version information and quit")
+	flags.StringVar(&opts.configFile, "config-file", defaultDaemonConfigFile, "Daemon configuration file")
+
+	opts.InstallFlags(flags)
 
 	opts.httpCliFlags, _ = apiCli.InstallHttpServerFlags(flags);
+	opts.daemonConfig.HttpCliFlags = opts.httpCliFlags
+	apiCli.BindEnvironment(flags, "version", "help")
+
+	cmd.AddCommand(newRestoreCommand())
+	cmd.AddCommand(newMigrateCommand())
+	cmd.AddCommand(newApplyCommand())
 	return cmd
 }
 
@@ -1,13 +1,17 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"os"
 
 	"github.com/spf13/pflag"
 	"github.com/sirupsen/logrus"
 
 	"cloudware/cloudware/pkg/pidfile"
+	apiCli "cloudware/cloudware/api/cli"
 	"cloudware/cloudware/api/http/server"
+	"cloudware/cloudware/api/logging"
 	"cloudware/cloudware/daemon"
 	"cloudware/cloudware/daemon/config"
 	"cloudware/cloudware/cli/debug"
@@ -20,6 +24,7 @@ type DaemonCli struct {
 	flags      *pflag.FlagSet
 	api        *server.Server
 	d          *daemon.Daemon
+	stopped    chan struct{}
 }
 
 // NewDaemonCli returns a daemon CLI
@@ -34,8 +39,14 @@ func (cli *DaemonCli) start(opts *daemonOptions) (err error) {
 	if cli.Config, err = loadDaemonCliConfig(opts); err != nil {
 		return err
 	}
+	cli.configFile = &opts.configFile
 	cli.flags = opts.flags
 
+	validator := &apiCli.Service{}
+	if err := validator.ValidateFlags(cli.HttpCliFlags); err != nil {
+		return fmt.Errorf("Invalid configuration: %v", err)
+	}
+
 	if cli.Config.Debug {
 		debug.Enable()
 	}
@@ -53,12 +64,18 @@ func (cli *DaemonCli) start(opts *daemonOptions) (err error) {
 	}
 
 	api := server.New(opts.httpCliFlags)
+	err = api.Start()
+	if err != nil {
+		return fmt.Errorf("Error starting daemon: %v", err)
+	}
 	cli.api = api
+	cli.stopped = make(chan struct{})
 
 	signal.Trap(func() {
 		cli.stop()
 		<-stopc // wait for daemonCli.start() to return
 	}, logrus.StandardLogger())
+	signal.TrapReload(cli.reloadConfig, logrus.StandardLogger())
 
 	// Notify that the API is active, but before daemon is set up.
 	preNotifySystem()
@@ -78,20 +95,100 @@ func (cli *DaemonCli) start(opts *daemonOptions) (err error) {
 		return fmt.Errorf("Shutting down due to ServeAPI error: %v", errAPI)
 	}
 
+	// The API stops being served as soon as the shutdown starts, wait for the drain to complete.
+	<-cli.stopped
 	return nil
 }
 
 func (cli *DaemonCli) stop() {
-	cli.api.Close()
+	defer close(cli.stopped)
+
+	ctx := context.Background()
+	if cli.api.ShutdownTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cli.api.ShutdownTimeout)
+		defer cancel()
+	}
+
+	err := cli.api.Shutdown(ctx)
+	if err != nil {
+		logrus.Errorf("Error during shutdown: %v", err)
+	}
+}
+
+// reloadConfig applies the reloadable options of the configuration file: the log level and format,
+// the synchronization interval of the external endpoints and the SSL certificate.
+func (cli *DaemonCli) reloadConfig() {
+	reload := func(conf *config.Config) {
+		if conf.IsValueSet("debug") {
+			cli.Config.Debug = conf.Debug
+		}
+		if conf.IsValueSet("log-level") {
+			cli.Config.LogLevel = conf.LogLevel
+		}
+		if conf.IsValueSet("log-format") {
+			cli.Config.LogFormat = conf.LogFormat
+			logging.SetFormat(cli.Config.LogFormat)
+		}
+		if cli.Config.Debug {
+			debug.Enable()
+		} else {
+			setLogLevel(cli.Config.LogLevel)
+		}
+
+		if conf.IsValueSet("sync-interval") && conf.SyncInterval != cli.Config.SyncInterval {
+			err := cli.api.Watcher.SetSyncInterval(conf.SyncInterval)
+			if err != nil {
+				logrus.Errorf("Unable to change the synchronization interval: %v", err)
+			} else {
+				cli.Config.SyncInterval = conf.SyncInterval
+			}
+		}
+
+		if conf.IsValueSet("sslcert") {
+			cli.Config.SSLCert = conf.SSLCert
+		}
+		if conf.IsValueSet("sslkey") {
+			cli.Config.SSLKey = conf.SSLKey
+		}
+		if cli.Config.SSL {
+			err := cli.api.LoadCertificate(cli.Config.SSLCert, cli.Config.SSLKey)
+			if err != nil {
+				logrus.Errorf("Unable to reload the SSL certificate: %v", err)
+			}
+		}
+
+		logrus.Info("Reloaded configuration")
+	}
+
+	if err := config.Reload(*cli.configFile, cli.flags, reload); err != nil {
+		logrus.Errorf("Unable to reload the configuration: %v", err)
+	}
 }
 
 func loadDaemonCliConfig(opts *daemonOptions) (*config.Config, error) {
 	conf := opts.daemonConfig
 	conf.Debug = opts.Debug
 	conf.LogLevel = opts.LogLevel
+	conf.LogFormat = opts.LogFormat
+
+	// The configuration file is optional unless it is set explicitly.
+	flags := opts.flags
+	if opts.configFile != "" {
+		_, err := os.Stat(opts.configFile)
+		if err == nil || flags.Changed("config-file") {
+			conf, err = config.MergeDaemonConfigurations(conf, flags, opts.configFile)
+			if err != nil {
+				return nil, fmt.Errorf("unable to configure the Cloudware daemon with file %s: %v", opts.configFile, err)
+			}
+		}
+	}
 
-	// ensure that the log level is the one set after merging configurations
+	// ensure that the log level and format are the ones set after merging configurations
 	setLogLevel(conf.LogLevel)
+	if err := logging.SetFormat(conf.LogFormat); err != nil {
+		return nil, err
+	}
 
 	return conf, nil
 }
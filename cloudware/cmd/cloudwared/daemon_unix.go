@@ -2,6 +2,9 @@
 
 package main
 
+// defaultDaemonConfigFile is the configuration file read when --config-file is not set, it may not exist.
+const defaultDaemonConfigFile = "/etc/cloudware/daemon.json"
+
 // notifyShutdown is called after the daemon shuts down but before the process exits.
 func notifyShutdown(err error) {
 }
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"cloudware/cloudware/api"
+	"cloudware/cloudware/bolt"
+	"cloudware/cloudware/cli"
+)
+
+type migrateOptions struct {
+	data   string
+	dryRun bool
+}
+
+func newMigrateCommand() *cobra.Command {
+	var opts migrateOptions
+
+	cmd := &cobra.Command{
+		Use:   "migrate [OPTIONS]",
+		Short: "Migrate the database of a stopped instance to the data model of this version",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := cli.NoArgs(cmd, args); err != nil {
+				return err
+			}
+			return runMigrate(opts, cmd.OutOrStdout())
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&opts.data, "data", defaultRestoreDataPath, "Path to the data directory of the instance")
+	flags.BoolVar(&opts.dryRun, "dry-run", false, "Report the planned migrations without running them")
+	return cmd
+}
+
+func runMigrate(opts migrateOptions, out io.Writer) error {
+	databasePath := filepath.Join(opts.data, "api.db")
+	if _, err := os.Stat(databasePath); err != nil {
+		return err
+	}
+
+	// Opening the database fails when it is locked by a running instance.
+	store, err := bolt.NewStore(opts.data)
+	if err != nil {
+		return err
+	}
+
+	// The dry run must leave the database untouched, Open initializes it.
+	if opts.dryRun {
+		err = store.OpenReadOnly()
+	} else {
+		err = store.Open()
+	}
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	migrator, err := store.Migrator()
+	if err != nil {
+		return err
+	}
+
+	steps := migrator.Plan()
+	fmt.Fprintf(out, "Database version: %d\n", migrator.CurrentDBVersion)
+	fmt.Fprintf(out, "Target version: %d\n", api.DBVersion)
+	if len(steps) == 0 {
+		fmt.Fprintln(out, "The database is up to date.")
+	}
+	for _, step := range steps {
+		fmt.Fprintf(out, "  %d: %s\n", step.Version, step.Description)
+	}
+
+	if opts.dryRun {
+		return nil
+	}
+
+	err = store.MigrateData()
+	if err != nil {
+		return err
+	}
+
+	logrus.Infof("Database in %s migrated to version %d", opts.data, api.DBVersion)
+	return nil
+}
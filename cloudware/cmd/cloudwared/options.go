@@ -7,6 +7,7 @@ import (
 	"github.com/sirupsen/logrus"
 	"cloudware/cloudware/daemon/config"
 	"cloudware/cloudware/api"
+	"cloudware/cloudware/api/logging"
 )
 
 type daemonOptions struct {
@@ -15,6 +16,7 @@ type daemonOptions struct {
 	version      bool
 	Debug		 bool
 	LogLevel     string
+	LogFormat    string
 	RunMode		 string
 	flags        *pflag.FlagSet
 	httpCliFlags *api.HttpCliFlags
@@ -30,6 +32,7 @@ func newDaemonOptions(config *config.Config) *daemonOptions {
 func (o *daemonOptions) InstallFlags(flags *pflag.FlagSet) {
 	flags.BoolVarP(&o.Debug, "debug", "D", false, "Enable debug mode")
 	flags.StringVarP(&o.LogLevel, "log-level", "l", "info", `Set the logging level ("debug"|"info"|"warn"|"error"|"fatal")`)
+	flags.StringVar(&o.LogFormat, "log-format", "text", `Set the format of the logs ("text"|"json")`)
 }
 
 // SetDefaultOptions sets default values for options after flag parsing is
@@ -45,8 +48,8 @@ func setLogLevel(logLevel string) {
 			fmt.Fprintf(os.Stderr, "Unable to parse logging level: %s\n", logLevel)
 			os.Exit(1)
 		}
-		logrus.SetLevel(lvl)
+		logging.SetLevel(lvl)
 	} else {
-		logrus.SetLevel(logrus.InfoLevel)
+		logging.SetLevel(logrus.InfoLevel)
 	}
 }
\ No newline at end of file
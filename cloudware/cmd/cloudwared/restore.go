@@ -0,0 +1,97 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"cloudware/cloudware/api"
+	"cloudware/cloudware/api/backup"
+	"cloudware/cloudware/api/crypto"
+	"cloudware/cloudware/bolt"
+	"cloudware/cloudware/cli"
+)
+
+const (
+	defaultRestoreDataPath = "/data"
+)
+
+type restoreOptions struct {
+	data              string
+	passwordFile      string
+	encryptionKeyFile string
+}
+
+func newRestoreCommand() *cobra.Command {
+	var opts restoreOptions
+
+	cmd := &cobra.Command{
+		Use:   "restore [OPTIONS] ARCHIVE",
+		Short: "Restore a backup archive in the data directory of a stopped instance",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := cli.ExactArgs(1)(cmd, args); err != nil {
+				return err
+			}
+			return runRestore(opts, args[0])
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&opts.data, "data", defaultRestoreDataPath, "Path to the data directory of the instance")
+	flags.StringVar(&opts.passwordFile, "password-file", "", "Path to the file containing the password of an encrypted archive")
+	flags.StringVar(&opts.encryptionKeyFile, "encryption-key-file", "", "Path to the file containing the master key used to encrypt the stored secrets, defaults to the CLOUDWARE_ENCRYPTION_KEY environment variable.")
+	return cmd
+}
+
+func runRestore(opts restoreOptions, archivePath string) error {
+	password := ""
+	if opts.passwordFile != "" {
+		content, err := ioutil.ReadFile(opts.passwordFile)
+		if err != nil {
+			return err
+		}
+		password = strings.TrimSpace(string(content))
+	}
+
+	var encryptionService api.EncryptionService
+	key, err := crypto.LoadEncryptionKey(opts.encryptionKeyFile, crypto.EncryptionKeyEnvVar)
+	if err != nil {
+		return err
+	}
+	if key != nil {
+		encryptionService, err = crypto.NewEncryptionService(key, nil)
+		if err != nil {
+			return err
+		}
+	}
+
+	archive, err := os.Open(archivePath)
+	if err != nil {
+		return err
+	}
+	defer archive.Close()
+
+	// Opening the database fails when it is locked by a running instance.
+	store, err := bolt.NewStore(opts.data)
+	if err != nil {
+		return err
+	}
+	store.EncryptionService = encryptionService
+
+	err = store.Open()
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	err = backup.NewService(opts.data, store, nil, encryptionService).RestoreBackup(archive, password)
+	if err != nil {
+		return err
+	}
+
+	logrus.Infof("Backup %s restored in %s", archivePath, opts.data)
+	return nil
+}
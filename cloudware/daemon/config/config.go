@@ -1,14 +1,199 @@
 package config
 
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/pflag"
+
+	"cloudware/cloudware/api"
+	"cloudware/cloudware/api/logging"
+)
+
+// Config represents the configuration of the daemon. The keys of the
+// configuration file are the names of the corresponding flags.
 type Config struct {
-	Pidfile string `json:"pidfile,omitempty"`
-	Root   string  `json:"data-root,omitempty"`
-	Debug   bool   `json:"debug,omitempty"`
-	LogLevel  string   `json:"log-level,omitempty"`
+	*api.HttpCliFlags
+	Pidfile   string `json:"pidfile,omitempty"`
+	Root      string `json:"data-root,omitempty"`
+	Debug     bool   `json:"debug,omitempty"`
+	LogLevel  string `json:"log-level,omitempty"`
+	LogFormat string `json:"log-format,omitempty"`
+
+	// ValuesSet contains the keys defined in the configuration file.
+	ValuesSet map[string]interface{} `json:"-"`
 }
 
 // New returns a new fully initialized Config struct
 func New() *Config {
-	config := Config{}
+	config := Config{
+		HttpCliFlags: &api.HttpCliFlags{},
+	}
 	return &config
 }
+
+// IsValueSet returns true if a configuration key is defined in the configuration file.
+func (config *Config) IsValueSet(name string) bool {
+	if config.ValuesSet == nil {
+		return false
+	}
+	_, ok := config.ValuesSet[name]
+	return ok
+}
+
+// MergeDaemonConfigurations reads the configuration file on top of the configuration
+// built from the flags. A key of the file cannot also be set by a flag on the command line.
+func MergeDaemonConfigurations(flagsConfig *Config, flags *pflag.FlagSet, configFile string) (*Config, error) {
+	content, valuesSet, err := getConflictFreeConfiguration(configFile, flags)
+	if err != nil {
+		return nil, err
+	}
+
+	if content != nil {
+		err = json.Unmarshal(content, flagsConfig)
+		if err != nil {
+			return nil, fmt.Errorf("invalid configuration file %s: %v", configFile, err)
+		}
+	}
+	flagsConfig.ValuesSet = valuesSet
+
+	err = Validate(flagsConfig)
+	if err != nil {
+		return nil, err
+	}
+	return flagsConfig, nil
+}
+
+// Reload reads the configuration file again and calls reload with the new configuration,
+// where only the keys of the file are set. Nothing is reloaded when the file is invalid.
+func Reload(configFile string, flags *pflag.FlagSet, reload func(*Config)) error {
+	content, valuesSet, err := getConflictFreeConfiguration(configFile, flags)
+	if err != nil {
+		return err
+	}
+
+	newConfig := New()
+	if content != nil {
+		err = json.Unmarshal(content, newConfig)
+		if err != nil {
+			return fmt.Errorf("invalid configuration file %s: %v", configFile, err)
+		}
+	}
+	newConfig.ValuesSet = valuesSet
+
+	err = Validate(newConfig)
+	if err != nil {
+		return err
+	}
+
+	reload(newConfig)
+	return nil
+}
+
+// Validate checks the values of the configuration which can be reloaded.
+func Validate(config *Config) error {
+	if config.LogLevel != "" {
+		if _, err := logrus.ParseLevel(config.LogLevel); err != nil {
+			return fmt.Errorf("invalid log level: %s", config.LogLevel)
+		}
+	}
+
+	switch config.LogFormat {
+	case "", logging.FormatText, logging.FormatJSON:
+	default:
+		return fmt.Errorf("invalid log format: %s", config.LogFormat)
+	}
+
+	if config.HttpCliFlags != nil && config.SyncInterval != "" {
+		if _, err := time.ParseDuration(config.SyncInterval); err != nil {
+			return fmt.Errorf("invalid synchronization interval: %s", config.SyncInterval)
+		}
+	}
+	return nil
+}
+
+// getConflictFreeConfiguration reads the configuration file and returns its content along
+// with its keys. It fails when a key is unknown or is also set by a flag.
+func getConflictFreeConfiguration(configFile string, flags *pflag.FlagSet) ([]byte, map[string]interface{}, error) {
+	content, err := ioutil.ReadFile(configFile)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	content = bytes.TrimSpace(content)
+	if len(content) == 0 {
+		return nil, map[string]interface{}{}, nil
+	}
+
+	var valuesSet map[string]interface{}
+	err = json.Unmarshal(content, &valuesSet)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid configuration file %s: %v", configFile, err)
+	}
+
+	err = findConfigurationConflicts(valuesSet, flags)
+	if err != nil {
+		return nil, nil, err
+	}
+	return content, valuesSet, nil
+}
+
+func findConfigurationConflicts(valuesSet map[string]interface{}, flags *pflag.FlagSet) error {
+	knownKeys := configurationKeys(reflect.TypeOf(Config{}))
+
+	var unknownKeys []string
+	for key := range valuesSet {
+		if !knownKeys[key] {
+			unknownKeys = append(unknownKeys, key)
+		}
+	}
+	if len(unknownKeys) > 0 {
+		sort.Strings(unknownKeys)
+		return fmt.Errorf("the following directives don't match any configuration option: %s", strings.Join(unknownKeys, ", "))
+	}
+
+	var conflicts []string
+	if flags != nil {
+		flags.Visit(func(f *pflag.Flag) {
+			if value, ok := valuesSet[f.Name]; ok {
+				conflicts = append(conflicts, fmt.Sprintf("%s: (from flag: %v, from file: %v)", f.Name, f.Value.String(), value))
+			}
+		})
+	}
+	if len(conflicts) > 0 {
+		return fmt.Errorf("the following directives are specified both as a flag and in the configuration file: %s", strings.Join(conflicts, ", "))
+	}
+	return nil
+}
+
+// configurationKeys returns the JSON keys of a configuration struct, including
+// the keys of its embedded structs.
+func configurationKeys(t reflect.Type) map[string]bool {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	keys := make(map[string]bool)
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.Anonymous {
+			for key := range configurationKeys(field.Type) {
+				keys[key] = true
+			}
+			continue
+		}
+
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if name != "" && name != "-" {
+			keys[name] = true
+		}
+	}
+	return keys
+}
@@ -0,0 +1,113 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeConfigFile(t *testing.T, content string) (string, func()) {
+	dir, err := ioutil.TempDir("", "cloudware-config")
+	require.NoError(t, err)
+
+	configFile := filepath.Join(dir, "daemon.json")
+	err = ioutil.WriteFile(configFile, []byte(content), 0600)
+	require.NoError(t, err)
+	return configFile, func() { os.RemoveAll(dir) }
+}
+
+func newTestFlags(conf *Config) *pflag.FlagSet {
+	flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	flags.StringVar(&conf.Addr, "bind", ":9000", "")
+	flags.StringVar(&conf.Pidfile, "pidfile", "", "")
+	return flags
+}
+
+func TestMergeDaemonConfigurations(t *testing.T) {
+	configFile, cleanup := writeConfigFile(t, `{"data": "/var/lib/cloudware", "log-level": "warn", "ssl": true}`)
+	defer cleanup()
+
+	conf := New()
+	flags := newTestFlags(conf)
+	require.NoError(t, flags.Parse([]string{"--pidfile", "/run/cloudware.pid"}))
+
+	conf, err := MergeDaemonConfigurations(conf, flags, configFile)
+	require.NoError(t, err)
+	assert.Equal(t, ":9000", conf.Addr)
+	assert.Equal(t, "/run/cloudware.pid", conf.Pidfile)
+	assert.Equal(t, "/var/lib/cloudware", conf.Data)
+	assert.Equal(t, "warn", conf.LogLevel)
+	assert.True(t, conf.SSL)
+	assert.True(t, conf.IsValueSet("data"))
+	assert.False(t, conf.IsValueSet("bind"))
+}
+
+func TestMergeDaemonConfigurationsConflicts(t *testing.T) {
+	configFile, cleanup := writeConfigFile(t, `{"bind": ":8000"}`)
+	defer cleanup()
+
+	conf := New()
+	flags := newTestFlags(conf)
+	require.NoError(t, flags.Parse([]string{"--bind", ":7000"}))
+
+	_, err := MergeDaemonConfigurations(conf, flags, configFile)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "bind: (from flag: :7000, from file: :8000)")
+}
+
+func TestMergeDaemonConfigurationsInvalid(t *testing.T) {
+	tests := []struct {
+		content string
+		message string
+	}{
+		{`{"bnid": ":8000"}`, "don't match any configuration option: bnid"},
+		{`{"log-level": "verbose"}`, "invalid log level"},
+		{`{"log-format": "xml"}`, "invalid log format"},
+		{`{"sync-interval": "often"}`, "invalid synchronization interval"},
+		{`{"ssl": "yes"}`, "invalid configuration file"},
+	}
+
+	for _, test := range tests {
+		configFile, cleanup := writeConfigFile(t, test.content)
+		conf := New()
+		_, err := MergeDaemonConfigurations(conf, newTestFlags(conf), configFile)
+		cleanup()
+
+		require.Error(t, err, test.content)
+		assert.Contains(t, err.Error(), test.message)
+	}
+}
+
+func TestReload(t *testing.T) {
+	configFile, cleanup := writeConfigFile(t, `{"log-level": "debug", "sync-interval": "5m"}`)
+	defer cleanup()
+
+	conf := New()
+	flags := newTestFlags(conf)
+
+	var reloaded *Config
+	err := Reload(configFile, flags, func(newConfig *Config) {
+		reloaded = newConfig
+	})
+	require.NoError(t, err)
+	require.NotNil(t, reloaded)
+	assert.Equal(t, "debug", reloaded.LogLevel)
+	assert.Equal(t, "5m", reloaded.SyncInterval)
+	assert.True(t, reloaded.IsValueSet("sync-interval"))
+	assert.False(t, reloaded.IsValueSet("sslcert"))
+
+	err = ioutil.WriteFile(configFile, []byte(`{"log-level": "loud"}`), 0600)
+	require.NoError(t, err)
+
+	reloaded = nil
+	err = Reload(configFile, flags, func(newConfig *Config) {
+		reloaded = newConfig
+	})
+	assert.Error(t, err)
+	assert.Nil(t, reloaded)
+}
@@ -68,6 +68,22 @@ func Trap(cleanup func(), logger interface {
 	}()
 }
 
+// TrapReload calls `reload` every time a SIGHUP is received, the signal
+// conventionally used to make a daemon reload its configuration without
+// restarting. Signals received while `reload` runs are coalesced.
+func TrapReload(reload func(), logger interface {
+	Info(args ...interface{})
+}) {
+	c := make(chan os.Signal, 1)
+	gosignal.Notify(c, syscall.SIGHUP)
+	go func() {
+		for sig := range c {
+			logger.Info(fmt.Sprintf("Processing signal '%v'", sig))
+			reload()
+		}
+	}()
+}
+
 const stacksLogNameTemplate = "goroutine-stacks-%s.log"
 
 // DumpStacks appends the runtime stack into file in dir and returns full path